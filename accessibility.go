@@ -0,0 +1,333 @@
+package gofpdi
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// Structure trees deeper than this are not read
+const maxStructDepth = 256
+
+// The accessibility structures of a document (see PDF/UA), and what of them
+// survives importing its pages, see Importer.GetAccessibilityReport
+type AccessibilityReport struct {
+	// The document is marked as tagged (/MarkInfo with /Marked true)
+	Tagged bool
+	// The document has a structure tree (/StructTreeRoot)
+	StructTree bool
+	// Natural language of the document (/Lang of the catalog)
+	Lang string
+	// Number of structure elements by structure type, e.g. "/P" or "/Figure"
+	StructElements map[string]int
+	// Custom structure types and the standard types they map to (/RoleMap)
+	RoleMap map[string]string
+	// Structure elements with alternate text (/Alt), replacement text
+	// (/ActualText) and a language of their own (/Lang)
+	AltTexts    int
+	ActualTexts int
+	Languages   int
+	// Pages with marked content linked to the structure tree (with an /MCID)
+	MarkedPages []int
+	// Marked content property lists in page content with /Alt, /ActualText
+	// or /Lang, which are imported with the content
+	ContentProperties int
+}
+
+// Get what importing pages keeps of the accessibility structures of a document
+func (report *AccessibilityReport) Kept() []string {
+	kept := make([]string, 0)
+	if len(report.MarkedPages) > 0 {
+		kept = append(kept, fmt.Sprintf("marked content of %d pages", len(report.MarkedPages)))
+	}
+	if report.ContentProperties > 0 {
+		kept = append(kept, fmt.Sprintf("%d marked content property lists with alternate text or language", report.ContentProperties))
+	}
+	if report.Lang != "" {
+		kept = append(kept, "the document language, if written by a Document with SetCarryAccessibility")
+	}
+	return kept
+}
+
+// Get what importing pages loses of the accessibility structures of a
+// document.  Imported pages become form xobjects, which are not part of a
+// structure tree, so the tags and everything attached to them is lost.
+func (report *AccessibilityReport) Lost() []string {
+	lost := make([]string, 0)
+	if report.Tagged {
+		lost = append(lost, "the tagged document mark")
+	}
+	n := 0
+	for _, count := range report.StructElements {
+		n += count
+	}
+	if n > 0 {
+		lost = append(lost, fmt.Sprintf("%d structure elements", n))
+	}
+	if len(report.RoleMap) > 0 {
+		lost = append(lost, fmt.Sprintf("role map with %d entries", len(report.RoleMap)))
+	}
+	if report.AltTexts > 0 {
+		lost = append(lost, fmt.Sprintf("%d alternate texts", report.AltTexts))
+	}
+	if report.ActualTexts > 0 {
+		lost = append(lost, fmt.Sprintf("%d replacement texts", report.ActualTexts))
+	}
+	if report.Languages > 0 {
+		lost = append(lost, fmt.Sprintf("%d element languages", report.Languages))
+	}
+	return lost
+}
+
+// Get the accessibility report of the document
+func (pdfReader *PdfReader) getAccessibilityReport() (*AccessibilityReport, error) {
+	catalog := pdfReader.catalog.Value.Dictionary
+	report := &AccessibilityReport{
+		StructElements: make(map[string]int, 0),
+		RoleMap:        make(map[string]string, 0),
+		MarkedPages:    make([]int, 0),
+	}
+
+	markInfo, err := pdfReader.resolveDirect(catalog["/MarkInfo"])
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to resolve /MarkInfo")
+	}
+	if markInfo != nil && markInfo.Type == PDF_TYPE_DICTIONARY {
+		marked, err := pdfReader.resolveDirect(markInfo.Dictionary["/Marked"])
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to resolve /Marked")
+		}
+		report.Tagged = marked != nil && marked.Type == PDF_TYPE_BOOLEAN && marked.Bool
+	}
+
+	report.Lang, err = pdfReader.getLang()
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := pdfReader.resolveDirect(catalog["/StructTreeRoot"])
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to resolve /StructTreeRoot")
+	}
+	if root != nil && root.Type == PDF_TYPE_DICTIONARY {
+		report.StructTree = true
+
+		roleMap, err := pdfReader.resolveDirect(root.Dictionary["/RoleMap"])
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to resolve /RoleMap")
+		}
+		if roleMap != nil && roleMap.Type == PDF_TYPE_DICTIONARY {
+			for custom, standard := range roleMap.Dictionary {
+				if standard.Type == PDF_TYPE_TOKEN {
+					report.RoleMap[custom] = standard.Token
+				}
+			}
+		}
+
+		err = pdfReader.readStructElements(report, root.Dictionary["/K"], make(map[int]bool, 0), 0)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for pageno := 1; pageno <= len(pdfReader.pages); pageno++ {
+		marked, properties, err := pdfReader.getMarkedContent(pageno)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("Failed to read marked content of page %d", pageno))
+		}
+		if marked {
+			report.MarkedPages = append(report.MarkedPages, pageno)
+		}
+		report.ContentProperties += properties
+	}
+
+	return report, nil
+}
+
+// Get the natural language of the document, "" if it has none
+func (pdfReader *PdfReader) getLang() (string, error) {
+	lang, err := pdfReader.resolveDirect(pdfReader.catalog.Value.Dictionary["/Lang"])
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to resolve /Lang")
+	}
+	if lang == nil || (lang.Type != PDF_TYPE_STRING && lang.Type != PDF_TYPE_HEX) {
+		return "", nil
+	}
+	return decodeTextString(stringBytes(lang)), nil
+}
+
+// Count the structure elements below a /K entry of the structure tree.
+// Marked content and object references are not elements; elements that are
+// reached twice are only counted once.
+func (pdfReader *PdfReader) readStructElements(report *AccessibilityReport, kids *PdfValue, visited map[int]bool, depth int) error {
+	if kids == nil || depth > maxStructDepth {
+		return nil
+	}
+
+	if kids.Type == PDF_TYPE_OBJREF {
+		if visited[kids.Id] {
+			return nil
+		}
+		visited[kids.Id] = true
+	}
+
+	kids, err := pdfReader.resolveDirect(kids)
+	if err != nil {
+		return errors.Wrap(err, "Failed to resolve structure element")
+	}
+	if kids == nil {
+		return nil
+	}
+
+	switch kids.Type {
+	case PDF_TYPE_ARRAY:
+		for _, kid := range kids.Array {
+			err = pdfReader.readStructElements(report, kid, visited, depth+1)
+			if err != nil {
+				return err
+			}
+		}
+	case PDF_TYPE_DICTIONARY:
+		s, ok := kids.Dictionary["/S"]
+		if !ok || s.Type != PDF_TYPE_TOKEN {
+			// A marked content or object reference
+			return nil
+		}
+
+		report.StructElements[s.Token]++
+		if _, ok := kids.Dictionary["/Alt"]; ok {
+			report.AltTexts++
+		}
+		if _, ok := kids.Dictionary["/ActualText"]; ok {
+			report.ActualTexts++
+		}
+		if _, ok := kids.Dictionary["/Lang"]; ok {
+			report.Languages++
+		}
+
+		return pdfReader.readStructElements(report, kids.Dictionary["/K"], visited, depth+1)
+	}
+
+	return nil
+}
+
+// Determine if the content of a page has marked content linked to the
+// structure tree, and count its property lists with alternate text or language
+func (pdfReader *PdfReader) getMarkedContent(pageno int) (bool, int, error) {
+	content, err := pdfReader.getContent(pageno)
+	if err != nil {
+		return false, 0, err
+	}
+
+	ops, err := ParseContentStream(content)
+	if err != nil {
+		return false, 0, err
+	}
+
+	var properties *PdfValue
+	marked := false
+	n := 0
+	for _, op := range ops {
+		if op.Operator != "BDC" || len(op.Operands) < 2 {
+			continue
+		}
+
+		list := op.Operands[1]
+		if list.Type == PDF_TYPE_TOKEN {
+			// A named property list of the resources
+			if properties == nil {
+				properties, err = pdfReader.getPageProperties(pageno)
+				if err != nil {
+					return false, 0, err
+				}
+			}
+			list, err = pdfReader.resolveDirect(properties.Dictionary[list.Token])
+			if err != nil {
+				return false, 0, errors.Wrap(err, "Failed to resolve property list")
+			}
+		}
+		if list == nil || list.Type != PDF_TYPE_DICTIONARY {
+			continue
+		}
+
+		if _, ok := list.Dictionary["/MCID"]; ok {
+			marked = true
+		}
+		for _, key := range []string{"/Alt", "/ActualText", "/Lang"} {
+			if _, ok := list.Dictionary[key]; ok {
+				n++
+				break
+			}
+		}
+	}
+
+	return marked, n, nil
+}
+
+// Get the named property lists of the resources of a page, an empty
+// dictionary if there are none
+func (pdfReader *PdfReader) getPageProperties(pageno int) (*PdfValue, error) {
+	empty := &PdfValue{Type: PDF_TYPE_DICTIONARY, Dictionary: make(map[string]*PdfValue, 0)}
+
+	resources, err := pdfReader.getPageResources(pageno)
+	if err != nil {
+		return nil, err
+	}
+	resources, err = pdfReader.resolveDirect(resources)
+	if err != nil || resources == nil || resources.Type != PDF_TYPE_DICTIONARY {
+		return empty, err
+	}
+
+	properties, err := pdfReader.resolveDirect(resources.Dictionary["/Properties"])
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to resolve /Properties")
+	}
+	if properties == nil || properties.Type != PDF_TYPE_DICTIONARY {
+		return empty, nil
+	}
+	return properties, nil
+}
+
+// Get which accessibility structures (tags, languages, alternate texts, role
+// maps) the current source has, and which of them importing its pages keeps
+// and loses (see AccessibilityReport.Kept and Lost), e.g. to quantify what
+// merging tagged documents loses
+func (importer *Importer) GetAccessibilityReport() (*AccessibilityReport, error) {
+	return importer.GetReader().getAccessibilityReport()
+}
+
+// Carry what can be carried of the accessibility structures of the imported
+// pages: the language of the document of the first page is written as the
+// language of the document, and templates of documents in other languages are
+// drawn in marked content with their language, so screen readers read them
+// in the right language (set it before drawing the templates).  The structure trees of the imported documents are
+// not carried, see Importer.GetAccessibilityReport.
+func (doc *Document) SetCarryAccessibility(b bool) {
+	doc.carryAccessibility = b
+}
+
+// Get the language of the document of template tplid, "" if it has none
+func (doc *Document) templateLang(tplid int) string {
+	tplInfo := doc.importer.tplMap[tplid]
+	tpl := tplInfo.Writer.tpls[tplInfo.TemplateId]
+	if tpl.Reader == nil {
+		return ""
+	}
+
+	lang, err := tpl.Reader.getLang()
+	if err != nil {
+		return ""
+	}
+	return lang
+}
+
+// Get the language of the document: the language of the document of the
+// first template drawn
+func (doc *Document) documentLang() string {
+	for _, page := range doc.pages {
+		if len(page.tpls) > 0 {
+			return doc.templateLang(page.tpls[0])
+		}
+	}
+	return ""
+}
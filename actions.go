@@ -0,0 +1,421 @@
+package gofpdi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// An action of the document, e.g. JavaScript that runs when it is opened or a
+// link that launches a program
+type Action struct {
+	// What holds the action: "Document", "Page", "Annotation", "Field",
+	// "Outline" or "JavaScript" (the /JavaScript name tree)
+	Location string
+	// The entry that triggers the action: /OpenAction or /A, or the key of an
+	// additional-actions (/AA) dictionary, e.g. /WC (will close) or /K
+	// (keystroke); "" for the /JavaScript name tree
+	Event string
+	// Page of page actions and annotations, 0 for the others
+	PageNo int
+	// Action type, the /S entry without slash, e.g. "JavaScript", "URI",
+	// "Launch", "SubmitForm" or "GoTo"
+	Type string
+	// What the action acts on: the script of JavaScript actions, the URI, the
+	// file launched, opened or submitted to, the named destination or the
+	// named action (e.g. "Print"); "" for other actions
+	Target string
+	// Page a GoTo action goes to, 0 if it is not a page of the document
+	TargetPage int
+	// Key of the /JavaScript name tree, title of the outline item or name of
+	// the field
+	Name string
+}
+
+// Reads the actions of a document, each action object once
+type actionReader struct {
+	reader  *PdfReader
+	pageNos map[int]int
+	visited map[int]bool
+	actions []*Action
+}
+
+// Get every action of the document: the open action and additional actions of
+// the catalog, the /JavaScript name tree, the additional actions of pages,
+// the actions of annotations, form fields and outline items, and the actions
+// they chain with /Next
+func (pdfReader *PdfReader) getActions() ([]*Action, error) {
+	pageNos, err := pdfReader.getPageNumbers()
+	if err != nil {
+		return nil, err
+	}
+	ar := &actionReader{reader: pdfReader, pageNos: pageNos, visited: make(map[int]bool, 0), actions: make([]*Action, 0)}
+	catalog := pdfReader.catalog.Value
+
+	err = ar.add(catalog.Dictionary["/OpenAction"], Action{Location: "Document", Event: "/OpenAction"})
+	if err != nil {
+		return nil, err
+	}
+	err = ar.addAdditional(catalog.Dictionary["/AA"], Action{Location: "Document"})
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := pdfReader.resolveDirect(catalog.Dictionary["/Names"])
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to resolve /Names")
+	}
+	if names != nil && names.Type == PDF_TYPE_DICTIONARY {
+		err = pdfReader.walkNameTree(names.Dictionary["/JavaScript"], func(key string, value *PdfValue) error {
+			return ar.add(value, Action{Location: "JavaScript", Name: key})
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to read /JavaScript name tree")
+		}
+	}
+
+	for pageno := 1; pageno <= len(pageNos); pageno++ {
+		err = ar.addPage(pageno)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("Failed to read actions of page %d", pageno))
+		}
+	}
+
+	acroForm, err := pdfReader.resolveDirect(catalog.Dictionary["/AcroForm"])
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to resolve /AcroForm")
+	}
+	if acroForm != nil && acroForm.Type == PDF_TYPE_DICTIONARY {
+		fields, err := pdfReader.resolveDirect(acroForm.Dictionary["/Fields"])
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to resolve /Fields")
+		}
+		if fields != nil {
+			for _, field := range fields.Array {
+				err = ar.addField(field, "", 0)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	outlines, err := pdfReader.resolveDirect(catalog.Dictionary["/Outlines"])
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to resolve /Outlines")
+	}
+	if outlines != nil && outlines.Type == PDF_TYPE_DICTIONARY {
+		err = ar.addOutlineItems(outlines.Dictionary["/First"], 0)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ar.actions, nil
+}
+
+// Add the actions of a page and of its annotations
+func (ar *actionReader) addPage(pageno int) error {
+	page, err := ar.reader.getPage(pageno)
+	if err != nil {
+		return err
+	}
+	ar.visited[page.Id] = true
+
+	err = ar.addAdditional(page.Value.Dictionary["/AA"], Action{Location: "Page", PageNo: pageno})
+	if err != nil {
+		return err
+	}
+
+	annots, err := ar.reader.resolveDirect(page.Value.Dictionary["/Annots"])
+	if err != nil {
+		return errors.Wrap(err, "Failed to resolve /Annots")
+	}
+	if annots == nil {
+		return nil
+	}
+	for _, ref := range annots.Array {
+		if ref.Type == PDF_TYPE_OBJREF {
+			if ar.visited[ref.Id] {
+				continue
+			}
+			ar.visited[ref.Id] = true
+		}
+		annot, err := ar.reader.resolveDirect(ref)
+		if err != nil {
+			return errors.Wrap(err, "Failed to resolve annotation")
+		}
+		if annot == nil || annot.Type != PDF_TYPE_DICTIONARY {
+			continue
+		}
+
+		name := ar.text(annot.Dictionary["/T"])
+		err = ar.add(annot.Dictionary["/A"], Action{Location: "Annotation", Event: "/A", PageNo: pageno, Name: name})
+		if err != nil {
+			return err
+		}
+		err = ar.addAdditional(annot.Dictionary["/AA"], Action{Location: "Annotation", PageNo: pageno, Name: name})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Add the additional actions of a field and its kids that are not widget
+// annotations of a page, which were added with the page
+func (ar *actionReader) addField(ref *PdfValue, parentName string, depth int) error {
+	if depth > maxOutlineDepth {
+		return nil
+	}
+	if ref.Type == PDF_TYPE_OBJREF {
+		if ar.visited[ref.Id] {
+			return nil
+		}
+		ar.visited[ref.Id] = true
+	}
+
+	field, err := ar.reader.resolveDirect(ref)
+	if err != nil {
+		return errors.Wrap(err, "Failed to resolve field")
+	}
+	if field == nil || field.Type != PDF_TYPE_DICTIONARY {
+		return nil
+	}
+
+	name := parentName
+	if partial := ar.text(field.Dictionary["/T"]); partial != "" {
+		if name != "" {
+			name += "."
+		}
+		name += partial
+	}
+
+	err = ar.addAdditional(field.Dictionary["/AA"], Action{Location: "Field", Name: name})
+	if err != nil {
+		return err
+	}
+
+	kids, err := ar.reader.resolveDirect(field.Dictionary["/Kids"])
+	if err != nil {
+		return errors.Wrap(err, "Failed to resolve /Kids")
+	}
+	if kids != nil {
+		for _, kid := range kids.Array {
+			err = ar.addField(kid, name, depth+1)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Add the actions of outline items and their siblings and children
+func (ar *actionReader) addOutlineItems(ref *PdfValue, depth int) error {
+	for ref != nil && depth <= maxOutlineDepth {
+		if ref.Type == PDF_TYPE_OBJREF {
+			if ar.visited[ref.Id] {
+				return nil
+			}
+			ar.visited[ref.Id] = true
+		}
+
+		item, err := ar.reader.resolveDirect(ref)
+		if err != nil {
+			return errors.Wrap(err, "Failed to resolve outline item")
+		}
+		if item == nil || item.Type != PDF_TYPE_DICTIONARY {
+			return nil
+		}
+
+		err = ar.add(item.Dictionary["/A"], Action{Location: "Outline", Event: "/A", Name: ar.text(item.Dictionary["/Title"])})
+		if err != nil {
+			return err
+		}
+		err = ar.addOutlineItems(item.Dictionary["/First"], depth+1)
+		if err != nil {
+			return err
+		}
+		ref = item.Dictionary["/Next"]
+	}
+	return nil
+}
+
+// Add the actions of an additional-actions dictionary, by trigger
+func (ar *actionReader) addAdditional(ref *PdfValue, template Action) error {
+	aa, err := ar.reader.resolveDirect(ref)
+	if err != nil {
+		return errors.Wrap(err, "Failed to resolve /AA")
+	}
+	if aa == nil || aa.Type != PDF_TYPE_DICTIONARY {
+		return nil
+	}
+
+	events := make([]string, 0, len(aa.Dictionary))
+	for event := range aa.Dictionary {
+		events = append(events, event)
+	}
+	sort.Strings(events)
+
+	for _, event := range events {
+		action := template
+		action.Event = event
+		err = ar.add(aa.Dictionary[event], action)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Add an action and the actions it chains with /Next.  Explicit destinations
+// of /OpenAction are not actions and are left out.
+func (ar *actionReader) add(ref *PdfValue, template Action) error {
+	if ref == nil {
+		return nil
+	}
+	if ref.Type == PDF_TYPE_OBJREF {
+		if ar.visited[ref.Id] {
+			return nil
+		}
+		ar.visited[ref.Id] = true
+	}
+
+	dict, err := ar.reader.resolveDirect(ref)
+	if err != nil {
+		return errors.Wrap(err, "Failed to resolve action")
+	}
+	if dict == nil || dict.Type != PDF_TYPE_DICTIONARY {
+		return nil
+	}
+
+	action := template
+	if s, ok := dict.Dictionary["/S"]; ok && s.Type == PDF_TYPE_TOKEN {
+		action.Type = strings.TrimPrefix(decodeName(s.Token), "/")
+	}
+	err = ar.readTarget(&action, dict)
+	if err != nil {
+		return err
+	}
+	ar.actions = append(ar.actions, &action)
+
+	next, err := ar.reader.resolveDirect(dict.Dictionary["/Next"])
+	if err != nil {
+		return errors.Wrap(err, "Failed to resolve /Next")
+	}
+	if next == nil {
+		return nil
+	}
+	if next.Type == PDF_TYPE_ARRAY {
+		for _, v := range next.Array {
+			err = ar.add(v, template)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return ar.add(dict.Dictionary["/Next"], template)
+}
+
+// Set the target of an action from its dictionary
+func (ar *actionReader) readTarget(action *Action, dict *PdfValue) error {
+	switch action.Type {
+	case "JavaScript":
+		js, err := ar.reader.resolveDirect(dict.Dictionary["/JS"])
+		if err != nil {
+			return errors.Wrap(err, "Failed to resolve /JS")
+		}
+		if js == nil {
+			return nil
+		}
+		if js.Type == PDF_TYPE_STREAM {
+			data, err := ar.reader.rebuildContentStream(js)
+			if err != nil {
+				return errors.Wrap(err, "Failed to decode /JS")
+			}
+			action.Target = decodeTextString(data)
+		} else {
+			action.Target = ar.text(js)
+		}
+	case "URI":
+		action.Target = ar.text(dict.Dictionary["/URI"])
+	case "Named":
+		if n, ok := dict.Dictionary["/N"]; ok && n.Type == PDF_TYPE_TOKEN {
+			action.Target = strings.TrimPrefix(decodeName(n.Token), "/")
+		}
+	case "GoTo":
+		dest, err := ar.reader.resolveDirect(dict.Dictionary["/D"])
+		if err != nil {
+			return errors.Wrap(err, "Failed to resolve /D")
+		}
+		if dest != nil && dest.Type == PDF_TYPE_TOKEN {
+			action.Target = strings.TrimPrefix(decodeName(dest.Token), "/")
+		} else if dest != nil && dest.Type != PDF_TYPE_ARRAY {
+			action.Target = ar.text(dest)
+		}
+		action.TargetPage, err = ar.reader.destinationPage(dest, ar.pageNos)
+		if err != nil {
+			return err
+		}
+	case "Launch", "GoToR", "GoToE", "SubmitForm", "ImportData", "Thread", "Sound", "Movie":
+		spec := dict.Dictionary["/F"]
+		if spec == nil {
+			// Launch actions of Windows
+			win, err := ar.reader.resolveDirect(dict.Dictionary["/Win"])
+			if err != nil {
+				return errors.Wrap(err, "Failed to resolve /Win")
+			}
+			if win != nil && win.Type == PDF_TYPE_DICTIONARY {
+				spec = win.Dictionary["/F"]
+			}
+		}
+		name, err := ar.fileName(spec)
+		if err != nil {
+			return err
+		}
+		action.Target = name
+	}
+	return nil
+}
+
+// Get the file name of a file specification: a string, or a dictionary with
+// /UF, /F or, for URLs, /FS /URL
+func (ar *actionReader) fileName(ref *PdfValue) (string, error) {
+	spec, err := ar.reader.resolveDirect(ref)
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to resolve file specification")
+	}
+	if spec == nil {
+		return "", nil
+	}
+	if spec.Type != PDF_TYPE_DICTIONARY {
+		return ar.text(spec), nil
+	}
+	for _, key := range []string{"/UF", "/F", "/Unix", "/DOS", "/Mac"} {
+		if name := ar.text(spec.Dictionary[key]); name != "" {
+			return name, nil
+		}
+	}
+	return "", nil
+}
+
+// Get the text of a string, "" for other values
+func (ar *actionReader) text(ref *PdfValue) string {
+	v, err := ar.reader.resolveDirect(ref)
+	if err != nil || v == nil || (v.Type != PDF_TYPE_STRING && v.Type != PDF_TYPE_HEX) {
+		return ""
+	}
+	return decodeTextString(stringBytes(v))
+}
+
+// Get every action of the current source, see Action, e.g. to find JavaScript
+// and actions that launch programs or submit data before merging a document
+// that comes from users.  Actions reached from more than one place are listed
+// once.
+func (importer *Importer) GetActions() ([]*Action, error) {
+	return importer.GetReader().getActions()
+}
@@ -0,0 +1,318 @@
+package gofpdi
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// A file embedded in the document
+type Attachment struct {
+	// Key in the /EmbeddedFiles name tree
+	Name string
+	// File name from the file specification (/UF or /F)
+	FileName    string
+	Description string
+	// Mime type of the embedded file, e.g. text/xml
+	MimeType string
+	// Relationship to the document for associated files, e.g. Alternative or Data
+	AFRelationship string
+	Data           []byte
+	// Page the file is associated with, see GetAssociatedFiles; 0 for
+	// files of the document
+	PageNo int
+}
+
+// Walk a name tree and call fn for each key and value
+func (pdfReader *PdfReader) walkNameTree(node *PdfValue, fn func(key string, value *PdfValue) error) error {
+	return pdfReader.walkNameTreeNode(node, fn, make(map[int]bool, 0))
+}
+
+func (pdfReader *PdfReader) walkNameTreeNode(node *PdfValue, fn func(key string, value *PdfValue) error, visited map[int]bool) error {
+	if node != nil && node.Type == PDF_TYPE_OBJREF {
+		if visited[node.Id] {
+			return nil
+		}
+		visited[node.Id] = true
+	}
+
+	node, err := pdfReader.resolveDirect(node)
+	if err != nil {
+		return errors.Wrap(err, "Failed to resolve name tree node")
+	}
+	if node == nil || node.Type != PDF_TYPE_DICTIONARY {
+		return nil
+	}
+
+	names, err := pdfReader.resolveDirect(node.Dictionary["/Names"])
+	if err != nil {
+		return errors.Wrap(err, "Failed to resolve /Names")
+	}
+	if names != nil {
+		for i := 0; i+1 < len(names.Array); i += 2 {
+			err = fn(decodeTextString(stringBytes(names.Array[i])), names.Array[i+1])
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	kids, err := pdfReader.resolveDirect(node.Dictionary["/Kids"])
+	if err != nil {
+		return errors.Wrap(err, "Failed to resolve /Kids")
+	}
+	if kids != nil {
+		for _, kid := range kids.Array {
+			err = pdfReader.walkNameTreeNode(kid, fn, visited)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Read the attachment described by a file specification
+func (pdfReader *PdfReader) readFileSpec(name string, ref *PdfValue) (*Attachment, error) {
+	spec, err := pdfReader.resolveDirect(ref)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to resolve file specification")
+	}
+	if spec == nil || spec.Type != PDF_TYPE_DICTIONARY {
+		return nil, nil
+	}
+
+	attachment := &Attachment{Name: name}
+
+	for _, key := range []string{"/UF", "/F"} {
+		if v, ok := spec.Dictionary[key]; ok && (v.Type == PDF_TYPE_STRING || v.Type == PDF_TYPE_HEX) {
+			attachment.FileName = decodeTextString(stringBytes(v))
+			break
+		}
+	}
+	if attachment.Name == "" {
+		attachment.Name = attachment.FileName
+	}
+
+	if v, ok := spec.Dictionary["/Desc"]; ok {
+		attachment.Description = decodeTextString(stringBytes(v))
+	}
+	if v, ok := spec.Dictionary["/AFRelationship"]; ok {
+		attachment.AFRelationship = strings.TrimPrefix(decodeName(v.Token), "/")
+	}
+
+	ef, err := pdfReader.resolveDirect(spec.Dictionary["/EF"])
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to resolve /EF")
+	}
+	if ef == nil {
+		return attachment, nil
+	}
+
+	fileRef := ef.Dictionary["/UF"]
+	if fileRef == nil {
+		fileRef = ef.Dictionary["/F"]
+	}
+
+	file, err := pdfReader.resolveDirect(fileRef)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to resolve embedded file")
+	}
+	if file == nil || file.Type != PDF_TYPE_STREAM {
+		return attachment, nil
+	}
+
+	if v, ok := file.Value.Dictionary["/Subtype"]; ok {
+		attachment.MimeType = strings.TrimPrefix(decodeName(v.Token), "/")
+	}
+
+	attachment.Data, err = pdfReader.rebuildContentStream(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to decode embedded file")
+	}
+
+	return attachment, nil
+}
+
+// Get the files embedded in the document (/EmbeddedFiles name tree)
+func (pdfReader *PdfReader) getAttachments() ([]*Attachment, error) {
+	result := make([]*Attachment, 0)
+
+	names, err := pdfReader.resolveDirect(pdfReader.catalog.Value.Dictionary["/Names"])
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to resolve /Names")
+	}
+	if names == nil {
+		return result, nil
+	}
+
+	err = pdfReader.walkNameTree(names.Dictionary["/EmbeddedFiles"], func(key string, value *PdfValue) error {
+		attachment, err := pdfReader.readFileSpec(key, value)
+		if err != nil {
+			return errors.Wrap(err, "Failed to read attachment "+key)
+		}
+		if attachment != nil {
+			result = append(result, attachment)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Get the files embedded in the current source
+func (importer *Importer) GetAttachments() ([]*Attachment, error) {
+	return importer.GetReader().getAttachments()
+}
+
+// Get the associated files (/AF) of the document and of its pages
+func (pdfReader *PdfReader) getAssociatedFiles() ([]*Attachment, error) {
+	result := make([]*Attachment, 0)
+
+	read := func(af *PdfValue, pageno int) error {
+		af, err := pdfReader.resolveDirect(af)
+		if err != nil {
+			return errors.Wrap(err, "Failed to resolve /AF")
+		}
+		if af == nil || af.Type != PDF_TYPE_ARRAY {
+			return nil
+		}
+		for _, spec := range af.Array {
+			attachment, err := pdfReader.readFileSpec("", spec)
+			if err != nil {
+				return errors.Wrap(err, "Failed to read associated file")
+			}
+			if attachment != nil {
+				attachment.PageNo = pageno
+				result = append(result, attachment)
+			}
+		}
+		return nil
+	}
+
+	err := read(pdfReader.catalog.Value.Dictionary["/AF"], 0)
+	if err != nil {
+		return nil, err
+	}
+	for pageno := 1; pageno <= len(pdfReader.pages); pageno++ {
+		af, err := pdfReader.getPageAssociatedFiles(pageno)
+		if err != nil {
+			return nil, err
+		}
+		err = read(af, pageno)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("Page %d", pageno))
+		}
+	}
+
+	return result, nil
+}
+
+// Get the files associated with the current source (/AF, PDF 2.0 and
+// PDF/A-3): those of the document, which are usually also attachments (see
+// GetAttachments), and those of its pages, by page number
+func (importer *Importer) GetAssociatedFiles() ([]*Attachment, error) {
+	return importer.GetReader().getAssociatedFiles()
+}
+
+// Embed a file in the document, in the /EmbeddedFiles name tree under
+// attachment.Name (its FileName if Name is "").  A name that is taken is
+// made unique by adding a number, e.g. "invoice (2).xml", and the file name
+// is renamed with it if it was the same.  Returns the name the file is
+// embedded under.  Files with an AFRelationship are also associated with the
+// document (/AF).
+func (doc *Document) AddAttachment(attachment *Attachment) (string, error) {
+	a := *attachment
+	if a.Name == "" {
+		a.Name = a.FileName
+	}
+	if a.Name == "" {
+		return "", errors.New("Attachment has no name")
+	}
+	if a.FileName == "" {
+		a.FileName = a.Name
+	}
+
+	taken := make(map[string]bool, len(doc.attachments))
+	for _, other := range doc.attachments {
+		taken[other.Name] = true
+	}
+	name := a.Name
+	ext := path.Ext(name)
+	for n := 2; taken[name]; n++ {
+		name = fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(a.Name, ext), n, ext)
+	}
+	if a.FileName == a.Name {
+		a.FileName = name
+	}
+	a.Name = name
+	a.PageNo = 0
+
+	doc.attachments = append(doc.attachments, &a)
+	return name, nil
+}
+
+// Write the embedded files of the document from object id nextId.  Returns
+// the catalog entries for them and the next free object id.
+func (doc *Document) putAttachments(objects map[int][]byte, nextId int) (string, int) {
+	if len(doc.attachments) == 0 {
+		return "", nextId
+	}
+
+	// Name trees are sorted by the bytes of their keys
+	attachments := make([]*Attachment, len(doc.attachments))
+	copy(attachments, doc.attachments)
+	key := func(a *Attachment) string {
+		return string(stringBytes(encodeTextString(a.Name)))
+	}
+	sort.SliceStable(attachments, func(i, j int) bool {
+		return key(attachments[i]) < key(attachments[j])
+	})
+
+	var names bytes.Buffer
+	associated := make([]int, 0)
+	for _, a := range attachments {
+		specId, fileId := nextId, nextId+1
+		nextId += 2
+
+		subtype := ""
+		if a.MimeType != "" {
+			subtype = " /Subtype " + escapeName("/"+a.MimeType)
+		}
+		dict := fmt.Sprintf("/Type /EmbeddedFile%s /Params << /Size %d >> /Filter /FlateDecode", subtype, len(a.Data))
+		objects[fileId] = append(pdfStream(dict, deflate(a.Data)), "\nendobj\n"...)
+
+		var spec bytes.Buffer
+		spec.WriteString("<< /Type /Filespec /F ")
+		writeContentValue(&spec, encodeTextString(a.FileName))
+		spec.WriteString(" /UF ")
+		writeContentValue(&spec, encodeTextString(a.FileName))
+		if a.Description != "" {
+			spec.WriteString(" /Desc ")
+			writeContentValue(&spec, encodeTextString(a.Description))
+		}
+		if a.AFRelationship != "" {
+			spec.WriteString(" /AFRelationship " + escapeName("/"+a.AFRelationship))
+			associated = append(associated, specId)
+		}
+		spec.WriteString(fmt.Sprintf(" /EF << /F %d 0 R /UF %d 0 R >> >>\nendobj\n", fileId, fileId))
+		objects[specId] = spec.Bytes()
+
+		writeContentValue(&names, encodeTextString(a.Name))
+		names.WriteString(fmt.Sprintf(" %d 0 R ", specId))
+	}
+
+	entries := fmt.Sprintf(" /Names << /EmbeddedFiles << /Names [%s] >> >>", names.String())
+	if len(associated) > 0 {
+		entries += " /AF [" + objectRefs(associated) + "]"
+	}
+	return entries, nextId
+}
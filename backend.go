@@ -0,0 +1,180 @@
+package gofpdi
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// OutputBackend receives the objects that make up imported templates.
+// A pdf generation library can integrate gofpdi by implementing these three
+// methods and passing the backend to Importer.PutFormXobjectsTo, which
+// writes each object into it as soon as it is serialized, referring to other
+// objects by the ids the backend allocated for them.
+type OutputBackend interface {
+	// AllocateObjectID returns an unused object id in the output document
+	AllocateObjectID() int
+
+	// WriteObject stores the contents of object id.  The contents are
+	// everything following the "<id> 0 obj" header, up to and including "endobj".
+	WriteObject(id int, data []byte) error
+
+	// MapPlaceholder associates a template name (e.g. /GOFPDITPL1) with the
+	// object id of its form xobject
+	MapPlaceholder(name string, id int)
+}
+
+// MapBackend is an OutputBackend that collects objects in memory, the same
+// way PutFormXobjects and GetImportedObjects return them
+type MapBackend struct {
+	NextId    int
+	Objects   map[int][]byte
+	Templates map[string]int
+}
+
+// Create a new MapBackend that allocates object ids starting at nextId
+func NewMapBackend(nextId int) *MapBackend {
+	return &MapBackend{
+		NextId:    nextId,
+		Objects:   make(map[int][]byte, 0),
+		Templates: make(map[string]int, 0),
+	}
+}
+
+func (backend *MapBackend) AllocateObjectID() int {
+	id := backend.NextId
+	backend.NextId++
+	return id
+}
+
+func (backend *MapBackend) WriteObject(id int, data []byte) error {
+	backend.Objects[id] = data
+	return nil
+}
+
+func (backend *MapBackend) MapPlaceholder(name string, id int) {
+	backend.Templates[name] = id
+}
+
+// Put form xobjects of the current source into backend.  Objects already
+// written by a previous call are not written again, see AddOutputBackend for
+// putting them into more than one backend.  The objects of a source are
+// written into one backend: once they have been, PutFormXobjects and other
+// backends return an error for the source.
+func (importer *Importer) PutFormXobjectsTo(backend OutputBackend) error {
+	return importer.putFormXobjectsTo(importer.sourceFile, backend)
+}
+
+// Put form xobjects of a source into backend, see PutFormXobjectsTo.  The
+// writer of the source writes its objects into backend directly, unless the
+// backend was added with AddOutputBackend and shares the serialized objects
+// with the other backends.
+func (importer *Importer) putFormXobjectsTo(source string, backend OutputBackend) error {
+	if target := importer.outputTarget(backend); target != nil {
+		return importer.putFormXobjectsToTargets(source, []*outputTarget{target})
+	}
+
+	writer := importer.GetWriterForFile(source)
+	err := writer.PutFormXobjectsTo(importer.GetReaderForFile(source), backend)
+	if err != nil {
+		return errors.Wrap(err, "Failed to put form xobjects of "+source)
+	}
+
+	// The ids the backend gave the objects, see GetObjectIdMap
+	for pdfObjId := range writer.backend_objs {
+		importer.backendIds[pdfObjId.hash] = pdfObjId.id
+	}
+
+	return importer.autoCloseSource(source)
+}
+
+// The OutputBackend of a writer that has not been given another one by
+// PutFormXobjectsTo.  It keeps the written objects in the writer, with the
+// hashes of the objects they refer to and their positions, which
+// PutFormXobjects, GetImportedObjects and GetImportedObjHashPos return.  The
+// objects are numbered by the writer, see SetRenumbering.
+type writerBackend struct {
+	writer *PdfWriter
+}
+
+func (backend *writerBackend) AllocateObjectID() int {
+	return backend.writer.allocObjId(0)
+}
+
+// Keep the data of the current object of the writer, spilling it if the
+// writer keeps too much in memory, see SetSpill
+func (backend *writerBackend) WriteObject(id int, data []byte) error {
+	writer := backend.writer
+	pdfObjId := writer.current_obj.id
+
+	spilled, err := writer.spill.add(data)
+	if err != nil {
+		writer.warn(fmt.Sprintf("Object %d could not be spilled: %s", id, err))
+	}
+	if spilled != nil {
+		writer.spilled_objs[pdfObjId] = spilled
+		data = nil
+	}
+	writer.written_objs[pdfObjId] = data
+	return nil
+}
+
+// Templates are returned by PutFormXobjects
+func (backend *writerBackend) MapPlaceholder(name string, id int) {
+}
+
+// Output the form xobjects of all templates and the objects they use into
+// backend as they are written, with object ids allocated by the backend,
+// instead of keeping them for GetImportedObjects.  Like PutFormXobjects, it
+// can be called again after importing more pages, with the same backend:
+// since the objects refer to each other by the ids of the backend, a writer
+// that has written objects cannot write into another one.  Backends must be
+// comparable, e.g. pointers.
+func (pdfWriter *PdfWriter) PutFormXobjectsTo(reader *PdfReader, backend OutputBackend) error {
+	if backend == nil {
+		return errors.New("No output backend")
+	}
+	if backend != pdfWriter.backend {
+		// Objects restored from a checkpoint have been written before
+		if len(pdfWriter.written_obj_pos) > 0 || len(pdfWriter.cleared_objs) > 0 {
+			return errors.New("Objects have already been written into another backend")
+		}
+		pdfWriter.backend = backend
+	}
+
+	_, err := pdfWriter.putFormXobjects(reader, pdfWriter.templateIds())
+	return err
+}
+
+// Check whether the writer writes into a backend given to PutFormXobjectsTo
+func (pdfWriter *PdfWriter) hasOutputBackend() bool {
+	_, ok := pdfWriter.backend.(*writerBackend)
+	return !ok
+}
+
+// Replace the object hashes at the given positions with the object ids they map to
+func replaceObjHashes(data []byte, posHash map[int]string, ids map[string]int) ([]byte, error) {
+	positions := make([]int, 0, len(posHash))
+	for pos := range posHash {
+		positions = append(positions, pos)
+	}
+	sort.Ints(positions)
+
+	var buf bytes.Buffer
+	last := 0
+	for _, pos := range positions {
+		hash := posHash[pos]
+		id, ok := ids[hash]
+		if !ok {
+			return nil, errors.New("Unknown object reference: " + hash)
+		}
+		buf.Write(data[last:pos])
+		buf.WriteString(fmt.Sprintf("%d", id))
+		last = pos + len(hash)
+	}
+	buf.Write(data[last:])
+
+	return buf.Bytes(), nil
+}
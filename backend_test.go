@@ -0,0 +1,196 @@
+package gofpdi
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// Check that backend holds every object its objects refer to
+func checkBackendReferences(t *testing.T, backend *MapBackend) {
+	t.Helper()
+
+	for id, data := range backend.Objects {
+		value, err := fuzzReadValue(data)
+		if err != nil {
+			t.Fatalf("object %d: %s", id, err)
+		}
+		values := []*PdfValue{value}
+		for len(values) > 0 {
+			v := values[len(values)-1]
+			values = values[:len(values)-1]
+			switch v.Type {
+			case PDF_TYPE_OBJREF:
+				if _, ok := backend.Objects[v.Id]; !ok {
+					t.Errorf("object %d references %d 0 R, which has not been written", id, v.Id)
+				}
+			case PDF_TYPE_DICTIONARY:
+				for _, entry := range v.Dictionary {
+					values = append(values, entry)
+				}
+			case PDF_TYPE_ARRAY:
+				values = append(values, v.Array...)
+			}
+		}
+	}
+}
+
+// A backend that counts how often each object is written and can fail
+type countingBackend struct {
+	*MapBackend
+	writes map[int]int
+	err    error
+}
+
+func newCountingBackend(nextId int) *countingBackend {
+	return &countingBackend{MapBackend: NewMapBackend(nextId), writes: make(map[int]int, 0)}
+}
+
+func (backend *countingBackend) WriteObject(id int, data []byte) error {
+	if backend.err != nil {
+		return backend.err
+	}
+	backend.writes[id]++
+	return backend.MapBackend.WriteObject(id, data)
+}
+
+// The writer writes its objects into the backend as they are written, with
+// the ids of the backend, and only once
+func TestPutFormXobjectsTo(t *testing.T) {
+	importer := newTestImporter(t, testDocument(3))
+	backend := newCountingBackend(100)
+
+	for pageno := 1; pageno <= 3; pageno++ {
+		if _, err := importer.ImportPage(pageno, MediaBox); err != nil {
+			t.Fatal(err)
+		}
+		if err := importer.PutFormXobjectsTo(backend); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 3 forms, the shared font and image
+	if len(backend.Objects) != 5 || backend.NextId != 105 {
+		t.Errorf("backend has %d objects and next id %d, want 5 and 105", len(backend.Objects), backend.NextId)
+	}
+	for id, n := range backend.writes {
+		if n != 1 {
+			t.Errorf("object %d was written %d times", id, n)
+		}
+		if placeholders := ParsePlaceholders(backend.Objects[id]); len(placeholders) > 0 {
+			t.Errorf("object %d has placeholders %v", id, placeholders)
+		}
+	}
+	if len(backend.Templates) != 3 {
+		t.Errorf("backend has templates %v, want 3", backend.Templates)
+	}
+	for name, id := range backend.Templates {
+		if !bytes.Contains(backend.Objects[id], []byte("/Subtype /Form")) {
+			t.Errorf("template %s is object %d, which is not a form xobject: %s", name, id, backend.Objects[id])
+		}
+	}
+	checkBackendReferences(t, backend.MapBackend)
+
+	for ref, id := range importer.GetObjectIdMap() {
+		if _, ok := backend.Objects[id]; !ok {
+			t.Errorf("%v is mapped to %d, which is not in the backend", ref, id)
+		}
+	}
+
+	// Objects refer to the ids of the backend, so it cannot be changed
+	if _, err := importer.ImportPage(1, CropBox); err != nil {
+		t.Fatal(err)
+	}
+	if err := importer.PutFormXobjectsTo(NewMapBackend(1)); err == nil {
+		t.Error("objects were put into a second backend")
+	}
+	if _, err := importer.PutFormXobjects(); err == nil {
+		t.Error("objects were put into the writer after a backend")
+	}
+}
+
+// Errors of the backend are returned
+func TestPutFormXobjectsToError(t *testing.T) {
+	importer := newTestImporter(t, testDocument(1))
+	if _, err := importer.ImportPage(1, MediaBox); err != nil {
+		t.Fatal(err)
+	}
+
+	backend := newCountingBackend(1)
+	backend.err = errors.New("disk full")
+	err := importer.PutFormXobjectsTo(backend)
+	if err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("got error %v, want disk full", err)
+	}
+}
+
+// Backends added with AddOutputBackend each get every object, numbered by
+// themselves
+func TestPutFormXobjectsToAll(t *testing.T) {
+	importer := newTestImporter(t, testDocument(2))
+	first, second := NewMapBackend(1), NewMapBackend(50)
+	importer.AddOutputBackend(first)
+	importer.AddOutputBackend(second)
+
+	for pageno := 1; pageno <= 2; pageno++ {
+		if _, err := importer.ImportPage(pageno, MediaBox); err != nil {
+			t.Fatal(err)
+		}
+		if err := importer.PutFormXobjectsToAll(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// An added backend may be put into by itself too
+	if err := importer.PutFormXobjectsTo(first); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, backend := range []*MapBackend{first, second} {
+		if len(backend.Objects) != 4 || len(backend.Templates) != 2 {
+			t.Errorf("backend has %d objects and templates %v, want 4 and 2", len(backend.Objects), backend.Templates)
+		}
+		checkBackendReferences(t, backend)
+	}
+}
+
+// A merge resumed from a checkpoint refers to the objects that were put into
+// the backend before it
+func TestPutFormXobjectsToResumed(t *testing.T) {
+	data := testDocument(3)
+	backend := NewMapBackend(10)
+
+	first := newTestImporter(t, data)
+	if _, err := first.ImportPage(1, MediaBox); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.PutFormXobjectsTo(backend); err != nil {
+		t.Fatal(err)
+	}
+	var cp bytes.Buffer
+	if err := first.WriteCheckpoint(&cp); err != nil {
+		t.Fatal(err)
+	}
+	written := len(backend.Objects)
+
+	second := NewImporter()
+	if err := second.ResumeCheckpoint(&cp); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.SetSourceReader("test", readTestPDF(t, data)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := second.ImportPage(2, MediaBox); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.PutFormXobjectsTo(backend); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the form of page 2 is new
+	if len(backend.Objects) != written+1 {
+		t.Errorf("backend has %d objects, want %d", len(backend.Objects), written+1)
+	}
+	checkBackendReferences(t, backend)
+}
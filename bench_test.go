@@ -0,0 +1,101 @@
+package gofpdi
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+// Pages of the documents of the benchmarks
+const benchmarkPages = 100
+
+// Open a document, which finds startxref at its end and reads the
+// cross-reference table and the trailer; also with a trailer of 16 KiB and
+// with 1 KiB of junk after the document, as some generators write
+func BenchmarkOpen(b *testing.B) {
+	data := testDocument(benchmarkPages)
+	large := bytes.Replace(data, []byte("/Root 1 0 R"), []byte("/Root 1 0 R /Pad ("+strings.Repeat("x", 16<<10)+")"), 1)
+	for _, bench := range []struct {
+		name string
+		data []byte
+	}{
+		{"plain", data},
+		{"large-trailer", large},
+		{"trailing-junk", append(append([]byte(nil), data...), bytes.Repeat([]byte(" \n"), 500)...)},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.SetBytes(int64(len(bench.data)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, err := NewPdfReaderFromStream(bytes.NewReader(bench.data))
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// Import every page of a document from a parsed reader
+func BenchmarkImportPage(b *testing.B) {
+	data := testDocument(benchmarkPages)
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		importer := newTestImporter(b, data)
+		for pageno := 1; pageno <= benchmarkPages; pageno++ {
+			if _, err := importer.ImportPage(pageno, MediaBox); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// Write the form xobjects of the imported pages and the objects they use
+func BenchmarkPutFormXobjects(b *testing.B) {
+	data := testDocument(benchmarkPages)
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		importer := newTestImporter(b, data)
+		for pageno := 1; pageno <= benchmarkPages; pageno++ {
+			if _, err := importer.ImportPage(pageno, MediaBox); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.StartTimer()
+
+		if _, err := importer.PutFormXobjects(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Import every page of a document and write them to a new document
+func BenchmarkWriteDocument(b *testing.B) {
+	data := testDocument(benchmarkPages)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		doc := NewDocument()
+		importer := doc.Importer()
+		if err := importer.SetSourceReader("bench", readTestPDF(b, data)); err != nil {
+			b.Fatal(err)
+		}
+		for pageno := 1; pageno <= benchmarkPages; pageno++ {
+			tplid, err := importer.ImportPage(pageno, MediaBox)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if err := doc.AddTemplatePage(tplid); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if _, err := doc.WriteTo(io.Discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
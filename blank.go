@@ -0,0 +1,278 @@
+package gofpdi
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// Image samples lighter than this count as paper, not as marks
+const blankPixelThreshold = 230
+
+// Adds up the area of the marks made by a content stream: everything that is
+// painted in a color other than white, within the page and the clipping path
+type inkHandler struct {
+	nopContentHandler
+	reader *PdfReader
+	page   [4]float64
+	area   float64
+	// Visible text has been shown on the page
+	text bool
+	// The bounding box of the marks, if there are any
+	bounds [4]float64
+	marked bool
+	// Collects the colors of the marks, see GetColorUsage
+	colors *colorCollector
+}
+
+// Add the area of a device space box that is painted
+func (handler *inkHandler) mark(it *contentInterpreter, box [4]float64, fraction float64) {
+	clip := handler.page
+	for _, c := range it.gs.clips {
+		if b, ok := pathBounds(c.path); ok {
+			clip = [4]float64{math.Max(clip[0], b[0]), math.Max(clip[1], b[1]), math.Min(clip[2], b[2]), math.Min(clip[3], b[3])}
+		}
+	}
+
+	w := math.Min(box[2], clip[2]) - math.Max(box[0], clip[0])
+	h := math.Min(box[3], clip[3]) - math.Max(box[1], clip[1])
+	if w <= 0 || h <= 0 || fraction <= 0 {
+		return
+	}
+	handler.area += w * h * fraction
+
+	marked := [4]float64{math.Max(box[0], clip[0]), math.Max(box[1], clip[1]), math.Min(box[2], clip[2]), math.Min(box[3], clip[3])}
+	if handler.marked {
+		marked = [4]float64{
+			math.Min(marked[0], handler.bounds[0]),
+			math.Min(marked[1], handler.bounds[1]),
+			math.Max(marked[2], handler.bounds[2]),
+			math.Max(marked[3], handler.bounds[3]),
+		}
+	}
+	handler.bounds = marked
+	handler.marked = true
+}
+
+// Determine if a color leaves no ink: white, or no tint of a spot color
+func isWhite(space string, color []float64, alpha float64) bool {
+	if alpha == 0 {
+		return true
+	}
+
+	switch space {
+	case "/Pattern":
+		return false
+	case "/DeviceCMYK", "/Separation", "/DeviceN":
+		for _, c := range color {
+			if c != 0 {
+				return false
+			}
+		}
+		return true
+	case "/Indexed", "/Lab", "/CalRGB", "/CalGray":
+		return false
+	}
+
+	// Gray, RGB and ICC based colors by their number of components
+	if len(color) == 4 {
+		return isWhite("/DeviceCMYK", color, alpha)
+	}
+	for _, c := range color {
+		if c < 1 {
+			return false
+		}
+	}
+	return len(color) > 0
+}
+
+func (handler *inkHandler) paintPath(it *contentInterpreter, path []pathSegment, op string) {
+	gs := it.gs
+	box, ok := pathBounds(path)
+	if !ok {
+		return
+	}
+
+	fill := op != "S" && op != "n"
+	stroke := op == "S" || op == "s" || op == "B" || op == "B*" || op == "b" || op == "b*"
+
+	if fill {
+		handler.paint(it, box, gs.fillSpace, gs.fillColor, gs.fillAlpha)
+	}
+	if stroke {
+		// Thin lines still cover some area
+		d := math.Max(gs.lineWidth*gs.ctm.scale(), 1) / 2
+		handler.paint(it, [4]float64{box[0] - d, box[1] - d, box[2] + d, box[3] + d}, gs.strokeSpace, gs.strokeColor, gs.strokeAlpha)
+	}
+}
+
+// Mark a device space box painted in a color, unless it leaves no ink.
+// Returns true if the marks cover more of the page than before.
+func (handler *inkHandler) paint(it *contentInterpreter, box [4]float64, space string, color []float64, alpha float64) bool {
+	if handler.colors != nil {
+		handler.colors.use(it, space, len(color), alpha)
+	}
+	if isWhite(space, color, alpha) {
+		return false
+	}
+
+	area := handler.area
+	handler.mark(it, box, 1)
+	if handler.colors != nil {
+		handler.colors.addInk(space, color, handler.area-area)
+	}
+	return handler.area > area
+}
+
+func (handler *inkHandler) showText(it *contentInterpreter, run *textRun) {
+	gs := it.gs
+
+	// Invisible and clip-only text
+	if gs.render == 3 || gs.render == 7 {
+		return
+	}
+
+	blank := true
+	for _, code := range run.codes {
+		if run.font.twoByte || code != 32 {
+			blank = false
+		}
+	}
+	if blank {
+		return
+	}
+
+	space, color, alpha := gs.fillSpace, gs.fillColor, gs.fillAlpha
+	if gs.render == 1 || gs.render == 5 {
+		space, color, alpha = gs.strokeSpace, gs.strokeColor, gs.strokeAlpha
+	}
+	corners := run.corners()
+	box, _ := pathBounds([]pathSegment{{'m', corners[:]}})
+	if handler.paint(it, box, space, color, alpha) {
+		handler.text = true
+	}
+}
+
+func (handler *inkHandler) drawImage(it *contentInterpreter, image *PdfValue, inline *ContentOperation) {
+	m := it.gs.ctm
+	corners := []point{m.transform(0, 0), m.transform(1, 0), m.transform(1, 1), m.transform(0, 1)}
+	box, _ := pathBounds([]pathSegment{{'m', corners}})
+
+	fraction := 1.0
+	if image != nil {
+		fraction = handler.reader.imageInk(image)
+	}
+	handler.mark(it, box, fraction)
+	if handler.colors != nil {
+		handler.colors.useImage(it, image, inline)
+	}
+}
+
+func (handler *inkHandler) paintShading(it *contentInterpreter, shading *PdfValue) {
+	handler.mark(it, handler.page, 1)
+	if handler.colors != nil {
+		handler.colors.useShading(it, shading)
+	}
+}
+
+// Get the fraction of the samples of an image that are darker than paper.
+// Images whose samples cannot be decoded, e.g. masks and bilevel scans, are
+// all ink.
+func (pdfReader *PdfReader) imageInk(image *PdfValue) float64 {
+	dict := image.Value.Dictionary
+	for _, key := range []string{"/ImageMask", "/Decode"} {
+		if _, ok := dict[key]; ok {
+			return 1
+		}
+	}
+
+	width := imageDictInt(dict, "/Width", "/W")
+	height := imageDictInt(dict, "/Height", "/H")
+	components := pdfReader.imageComponents(dict["/ColorSpace"])
+	if width <= 0 || height <= 0 || components == 0 {
+		return 1
+	}
+
+	pixels, err := pdfReader.imagePixels(image, width, height, components)
+	if err != nil {
+		return 1
+	}
+
+	dark := 0
+	for i := 0; i < width*height; i++ {
+		for c := 0; c < components; c++ {
+			if pixels[i*components+c] < blankPixelThreshold {
+				dark++
+				break
+			}
+		}
+	}
+	return float64(dark) / float64(width*height)
+}
+
+// Determine if a page is blank: if the marks its content makes within the
+// crop box cover no more than tolerance (a fraction of the area of the page,
+// e.g. 0.001 for specks of dust) of it.  Paths and text painted in white, or
+// with no tint of a spot color, make no marks, so pages with only a white
+// rectangle are blank; visible text, however small, makes a page not blank.
+// Images count by their samples darker than paper if they are 8 bit gray or
+// RGB (e.g. a scanned page), by their whole area otherwise; shadings cover
+// the page.  Annotations are not considered.
+func (pdfReader *PdfReader) IsPageBlank(pageno int, tolerance float64) (bool, error) {
+	handler, err := pdfReader.pageInk(pageno, nil)
+	if err != nil {
+		return false, err
+	}
+
+	page := handler.page
+	area := (page[2] - page[0]) * (page[3] - page[1])
+	return !handler.text && handler.area <= tolerance*area, nil
+}
+
+// Find the marks that the content of a page makes within its crop box,
+// collecting their colors into colors if it is not nil
+func (pdfReader *PdfReader) pageInk(pageno int, colors *colorCollector) (*inkHandler, error) {
+	boxes, err := pdfReader.getPageBoxes(pageno, 1)
+	if err != nil {
+		return nil, err
+	}
+	box := boxes["/CropBox"]
+	if len(box) == 0 {
+		box = boxes["/MediaBox"]
+	}
+	page := [4]float64{box["llx"], box["lly"], box["urx"], box["ury"]}
+
+	resources, err := pdfReader.getPageResources(pageno)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get page resources")
+	}
+	resources, err = pdfReader.resolveDirect(resources)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to resolve page resources")
+	}
+
+	content, err := pdfReader.getContent(pageno)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get content")
+	}
+
+	handler := &inkHandler{reader: pdfReader, page: page, colors: colors}
+	it := newContentInterpreter(pdfReader, resources, identityMatrix, handler)
+	err = it.run(content)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler, nil
+}
+
+// Determine if page pageno of the current source is blank, see
+// PdfReader.IsPageBlank, e.g. to skip the blank pages a scanner inserted
+func (importer *Importer) IsPageBlank(pageno int, tolerance float64) (bool, error) {
+	blank, err := importer.GetReader().IsPageBlank(pageno, tolerance)
+	if err != nil {
+		return false, errors.Wrap(err, fmt.Sprintf("Failed to analyze page %d", pageno))
+	}
+	return blank, nil
+}
@@ -0,0 +1,57 @@
+package gofpdi
+
+import (
+	"strings"
+)
+
+// The name of a page box, used to choose the area of a page that is imported.
+// Box names are accepted with or without the leading slash, e.g. "/TrimBox"
+// or "TrimBox", so string constants of older code keep working.
+type BoxName string
+
+const (
+	MediaBox BoxName = "/MediaBox"
+	CropBox  BoxName = "/CropBox"
+	BleedBox BoxName = "/BleedBox"
+	TrimBox  BoxName = "/TrimBox"
+	ArtBox   BoxName = "/ArtBox"
+)
+
+// All page boxes, in the order in which they are nested
+var boxNames = []BoxName{MediaBox, CropBox, BleedBox, TrimBox, ArtBox}
+
+// Returned when a box name is not one of the page boxes
+type UnknownBoxError struct {
+	Name string
+}
+
+func (err *UnknownBoxError) Error() string {
+	return "Unknown page box: " + err.Name
+}
+
+// Get the box name for a string, e.g. "TrimBox" or "/TrimBox".  Returns an
+// *UnknownBoxError for names that are not page boxes.
+func ParseBoxName(name string) (BoxName, error) {
+	return BoxName(name).normalize()
+}
+
+// Get the box name with a leading slash, as it is used in page dictionaries
+func (name BoxName) normalize() (BoxName, error) {
+	key := string(name)
+	if !strings.HasPrefix(key, "/") {
+		key = "/" + key
+	}
+
+	for _, box := range boxNames {
+		if string(box) == key {
+			return box, nil
+		}
+	}
+
+	return "", &UnknownBoxError{Name: string(name)}
+}
+
+// Get the name of the box as it is used in page dictionaries, e.g. "/TrimBox"
+func (name BoxName) String() string {
+	return string(name)
+}
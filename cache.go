@@ -0,0 +1,104 @@
+package gofpdi
+
+import (
+	"container/list"
+	"sync"
+)
+
+// A least recently used cache of objects by object id.  A size of 0 means the
+// cache is unbounded.  It is safe for concurrent use, so readers of the same
+// source can share it.
+type objectCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[int]*list.Element
+	order   *list.List
+	// Estimated memory held by the cached objects
+	bytes int64
+}
+
+type objectCacheEntry struct {
+	id    int
+	obj   *PdfValue
+	bytes int64
+}
+
+func newObjectCache(size int) *objectCache {
+	return &objectCache{
+		size:    size,
+		entries: make(map[int]*list.Element, 0),
+		order:   list.New(),
+	}
+}
+
+// Get a cached object and mark it as recently used
+func (cache *objectCache) get(id int) (*PdfValue, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	e, ok := cache.entries[id]
+	if !ok {
+		return nil, false
+	}
+
+	cache.order.MoveToFront(e)
+	return e.Value.(*objectCacheEntry).obj, true
+}
+
+// Add or replace an object, evicting the least recently used objects if the
+// cache is full
+func (cache *objectCache) put(id int, obj *PdfValue) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	size := valueSize(obj)
+	cache.bytes += size
+
+	if e, ok := cache.entries[id]; ok {
+		entry := e.Value.(*objectCacheEntry)
+		cache.bytes -= entry.bytes
+		entry.obj, entry.bytes = obj, size
+		cache.order.MoveToFront(e)
+		return
+	}
+
+	cache.entries[id] = cache.order.PushFront(&objectCacheEntry{id: id, obj: obj, bytes: size})
+	cache.evict()
+}
+
+// Change the maximum number of objects, evicting objects if there are more
+func (cache *objectCache) setSize(size int) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.size = size
+	cache.evict()
+}
+
+func (cache *objectCache) evict() {
+	for cache.size > 0 && cache.order.Len() > cache.size {
+		e := cache.order.Back()
+		cache.order.Remove(e)
+		entry := e.Value.(*objectCacheEntry)
+		delete(cache.entries, entry.id)
+		cache.bytes -= entry.bytes
+	}
+}
+
+// Get the number of cached objects and their estimated memory
+func (cache *objectCache) stats() (int, int64) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	return cache.order.Len(), cache.bytes
+}
+
+// Remove all cached objects
+func (cache *objectCache) clear() {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.entries = make(map[int]*list.Element, 0)
+	cache.order.Init()
+	cache.bytes = 0
+}
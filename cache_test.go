@@ -0,0 +1,134 @@
+package gofpdi
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Get the ids cached by an object cache, most recently used first
+func cachedIds(cache *objectCache) []int {
+	ids := make([]int, 0, cache.order.Len())
+	for e := cache.order.Front(); e != nil; e = e.Next() {
+		ids = append(ids, e.Value.(*objectCacheEntry).id)
+	}
+	return ids
+}
+
+// A cached test object
+func cacheObject(id int) *PdfValue {
+	return &PdfValue{Type: PDF_TYPE_OBJECT, Id: id, Value: &PdfValue{Type: PDF_TYPE_STRING, String: fmt.Sprintf("object %d", id)}}
+}
+
+// The least recently used objects are evicted first, and getting an object
+// marks it as used
+func TestObjectCacheEviction(t *testing.T) {
+	cache := newObjectCache(3)
+	for id := 1; id <= 3; id++ {
+		cache.put(id, cacheObject(id))
+	}
+	if _, ok := cache.get(1); !ok {
+		t.Fatal("object 1 is not cached")
+	}
+	cache.put(4, cacheObject(4))
+	// Replacing an object marks it as used without evicting another one
+	cache.put(3, cacheObject(3))
+
+	if got, want := fmt.Sprint(cachedIds(cache)), "[3 4 1]"; got != want {
+		t.Errorf("cached %s, want %s", got, want)
+	}
+	if _, ok := cache.get(2); ok {
+		t.Error("object 2 was not evicted")
+	}
+
+	n, bytes := cache.stats()
+	want := valueSize(cacheObject(1)) + valueSize(cacheObject(3)) + valueSize(cacheObject(4))
+	if n != 3 || bytes != want {
+		t.Errorf("stats are %d objects of %d bytes, want 3 of %d", n, bytes, want)
+	}
+}
+
+// Reducing the size evicts the least recently used objects, and a size of 0
+// does not bound the cache
+func TestObjectCacheSetSize(t *testing.T) {
+	cache := newObjectCache(0)
+	for id := 1; id <= 5; id++ {
+		cache.put(id, cacheObject(id))
+	}
+	cache.setSize(2)
+	if got, want := fmt.Sprint(cachedIds(cache)), "[5 4]"; got != want {
+		t.Errorf("cached %s, want %s", got, want)
+	}
+	cache.put(6, cacheObject(6))
+	if got, want := fmt.Sprint(cachedIds(cache)), "[6 5]"; got != want {
+		t.Errorf("cached %s, want %s", got, want)
+	}
+
+	cache.setSize(0)
+	for id := 7; id <= 10; id++ {
+		cache.put(id, cacheObject(id))
+	}
+	if n, _ := cache.stats(); n != 6 {
+		t.Errorf("cached %d objects, want 6", n)
+	}
+	cache.clear()
+	if n, bytes := cache.stats(); n != 0 || bytes != 0 {
+		t.Errorf("stats are %d objects of %d bytes after clear", n, bytes)
+	}
+}
+
+// A reader keeps at most SetCacheSize objects, and objects evicted from its
+// cache are parsed again when they are resolved
+func TestReaderCacheSize(t *testing.T) {
+	reader := readTestPDF(t, testDocument(5))
+	reader.SetCacheSize(2)
+
+	resolve := func(id int) *PdfValue {
+		t.Helper()
+		obj, err := reader.resolveObject(&PdfValue{Type: PDF_TYPE_OBJREF, Id: id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return obj
+	}
+	first := make(map[int]*PdfValue, 0)
+	for id := range reader.xref {
+		if id > 0 {
+			first[id] = resolve(id)
+		}
+	}
+	if n, _ := reader.objects.stats(); n > 2 {
+		t.Fatalf("reader caches %d objects, want at most 2", n)
+	}
+
+	reparsed := 0
+	for id, obj := range first {
+		evicted := !cachedObject(reader, id)
+		again := resolve(id)
+		if !cachedObject(reader, id) {
+			t.Errorf("object %d is not cached after it was resolved", id)
+		}
+		if evicted {
+			if again == obj {
+				t.Errorf("object %d was not parsed again", id)
+			}
+			reparsed++
+		}
+		if got, want := valueText(again), valueText(obj); got != want {
+			t.Errorf("object %d is %s after it was evicted, want %s", id, got, want)
+		}
+	}
+	if reparsed < len(first)-2 {
+		t.Errorf("%d of %d objects were parsed again, want at least %d", reparsed, len(first), len(first)-2)
+	}
+}
+
+// Check whether a reader caches object id
+func cachedObject(reader *PdfReader, id int) bool {
+	_, ok := reader.objects.get(id)
+	return ok
+}
+
+// Get the value of an object as it is written
+func valueText(obj *PdfValue) string {
+	return string(fuzzWriteValue(obj.Value))
+}
@@ -0,0 +1,224 @@
+package gofpdi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// The state of an importer that continuing a merge depends on, see
+// Importer.WriteCheckpoint
+type checkpoint struct {
+	// The next template id
+	NextTemplateId int
+	// Template ids of the imported pages, by source, page number and box
+	ImportedPages map[string]int
+	// The templates whose form xobjects have been written, by template id
+	Templates map[int]*checkpointTemplate
+	// Objects written once for all sources (see SetMergeFontFiles), by digest
+	SharedObjects map[string]checkpointObject
+	// The objects written of each source, by source name
+	Sources map[string]*checkpointSource
+	// Ids of the objects put into backends, by hash (see PutFormXobjectsTo)
+	BackendIds map[string]int
+}
+
+// A written template: its name and form xobject, and what UseTemplate needs
+type checkpointTemplate struct {
+	Source string
+	PageNo int
+	Name   string
+	Object checkpointObject
+	X      float64
+	Y      float64
+	W      float64
+	H      float64
+	K      float64
+}
+
+// An output object
+type checkpointObject struct {
+	Id   int
+	Hash string
+}
+
+// The objects written of a source
+type checkpointSource struct {
+	NextObjectId int
+	// Output id and generation of the source objects, by source object number
+	Objects map[int][2]int
+}
+
+// Write the state a merge needs to continue after a restart to w: the
+// object ids written for every source, the digests of the objects written
+// once for all of them and the templates that have been written.  Take the
+// checkpoint after PutFormXobjects, when its objects have been stored with
+// the output; a new importer that resumes it (see ResumeCheckpoint) refers to
+// those objects instead of writing them again, and numbers new objects after
+// them.  The objects themselves are not part of the checkpoint, and neither
+// are templates that have not been written.
+func (importer *Importer) WriteCheckpoint(w io.Writer) error {
+	cp := &checkpoint{
+		NextTemplateId: importer.tplN,
+		ImportedPages:  make(map[string]int, 0),
+		Templates:      make(map[int]*checkpointTemplate, 0),
+		SharedObjects:  make(map[string]checkpointObject, len(importer.sharedStreams)),
+		Sources:        make(map[string]*checkpointSource, len(importer.writers)),
+		BackendIds:     importer.backendIds,
+	}
+
+	for tplid, tplInfo := range importer.tplMap {
+		tpl := tplInfo.Writer.tpls[tplInfo.TemplateId]
+		if tpl.objId == nil {
+			continue
+		}
+		cp.Templates[tplid] = &checkpointTemplate{
+			Source: tplInfo.SourceFile,
+			PageNo: tpl.PageNo,
+			Name:   tplInfo.Writer.templateName(tplInfo.TemplateId),
+			Object: checkpointObject{Id: tpl.objId.id, Hash: tpl.objId.hash},
+			X:      tpl.X,
+			Y:      tpl.Y,
+			W:      tpl.W,
+			H:      tpl.H,
+			K:      tpl.k,
+		}
+	}
+
+	// Pages of templates that have not been written are imported again
+	for page, tplid := range importer.importedPages {
+		if _, ok := cp.Templates[tplid]; ok {
+			cp.ImportedPages[page] = tplid
+		}
+	}
+
+	for digest, pdfObjId := range importer.sharedStreams {
+		cp.SharedObjects[digest] = checkpointObject{Id: pdfObjId.id, Hash: pdfObjId.hash}
+	}
+
+	for source, writer := range importer.writers {
+		if len(writer.obj_stack) > 0 {
+			return errors.New("Objects of " + source + " are being written, take the checkpoint after PutFormXobjects")
+		}
+
+		state := &checkpointSource{NextObjectId: writer.GetNextObjectID(), Objects: make(map[int][2]int, len(writer.don_obj_stack))}
+		for id, v := range writer.don_obj_stack {
+			state.Objects[id] = [2]int{v.NewId, v.Gen}
+		}
+		cp.Sources[source] = state
+	}
+
+	// Sources resumed but not used again
+	for source, state := range importer.resumedSources {
+		if _, ok := cp.Sources[source]; !ok {
+			cp.Sources[source] = state
+		}
+	}
+
+	err := json.NewEncoder(w).Encode(cp)
+	if err != nil {
+		return errors.Wrap(err, "Failed to write checkpoint")
+	}
+	return nil
+}
+
+// Continue a merge from a checkpoint written by WriteCheckpoint, after the
+// options of the importer are set and before any source is.  The templates
+// of the checkpoint keep their ids and can be drawn with UseTemplate, and
+// importing one of their pages again returns them; new templates, objects
+// of the sources and identical objects of new sources refer to the objects
+// written before the checkpoint.  Objects that were added to the output
+// after the checkpoint was taken are written again.
+func (importer *Importer) ResumeCheckpoint(r io.Reader) error {
+	if len(importer.writers) > 0 || importer.tplN > 0 {
+		return errors.New("Checkpoints can only be resumed by a new importer")
+	}
+
+	cp := &checkpoint{}
+	err := json.NewDecoder(r).Decode(cp)
+	if err != nil {
+		return errors.Wrap(err, "Failed to read checkpoint")
+	}
+
+	// Templates are kept by a writer of their own, which is never written
+	writer, err := NewPdfWriter("")
+	if err != nil {
+		return err
+	}
+	writer.SetOrigin(importer.options.origin)
+
+	tplids := make([]int, 0, len(cp.Templates))
+	for tplid := range cp.Templates {
+		if tplid < 0 || tplid >= cp.NextTemplateId {
+			return errors.New(fmt.Sprintf("Invalid template id %d in checkpoint", tplid))
+		}
+		tplids = append(tplids, tplid)
+	}
+	sort.Ints(tplids)
+
+	for _, tplid := range tplids {
+		t := cp.Templates[tplid]
+		if t.W <= 0 || t.H <= 0 || t.K <= 0 || t.Name == "" {
+			return errors.New(fmt.Sprintf("Invalid template %d in checkpoint", tplid))
+		}
+		tpl := &PdfTemplate{
+			PageNo: t.PageNo,
+			X:      t.X,
+			Y:      t.Y,
+			W:      t.W,
+			H:      t.H,
+			k:      t.K,
+			objId:  cp.backendObjectId(t.Object),
+			name:   t.Name,
+		}
+		importer.tplMap[tplid] = &TplInfo{SourceFile: t.Source, TemplateId: writer.addTemplate(tpl), Writer: writer}
+	}
+
+	for page, tplid := range cp.ImportedPages {
+		if _, ok := importer.tplMap[tplid]; ok {
+			importer.importedPages[page] = tplid
+		}
+	}
+
+	for digest, obj := range cp.SharedObjects {
+		importer.sharedStreams[digest] = cp.backendObjectId(obj)
+	}
+	for hash, id := range cp.BackendIds {
+		importer.backendIds[hash] = id
+	}
+
+	importer.tplN = cp.NextTemplateId
+	importer.resumedWriter = writer
+	importer.resumedSources = cp.Sources
+	return nil
+}
+
+// Continue numbering the objects of a source where a checkpoint left off,
+// referring to the objects written before it.  hashSource is the one of the
+// reader of the source, see PdfReader.hashSource.  Objects that were put
+// into a backend are referred to by the ids in backendIds, which the backend
+// gave them, as the writer writes into the backend directly.
+func (pdfWriter *PdfWriter) resume(hashSource string, state *checkpointSource, backendIds map[string]int) {
+	pdfWriter.SetNextObjectID(state.NextObjectId)
+	for id, obj := range state.Objects {
+		newId := obj[0]
+		if backendId, ok := backendIds[objectHash(newId, hashSource)]; ok {
+			newId = backendId
+			backendIds[objectHash(newId, hashSource)] = newId
+		}
+		pdfWriter.don_obj_stack[id] = &PdfValue{Type: PDF_TYPE_OBJREF, Id: id, Gen: obj[1], NewId: newId}
+		pdfWriter.restored_objs[objectHash(newId, hashSource)] = true
+	}
+}
+
+// Get a written object of a checkpoint, with the id a backend gave it if it
+// was put into one
+func (cp *checkpoint) backendObjectId(obj checkpointObject) *PdfObjectId {
+	if id, ok := cp.BackendIds[obj.Hash]; ok {
+		return &PdfObjectId{id: id, hash: obj.Hash}
+	}
+	return &PdfObjectId{id: obj.Id, hash: obj.Hash}
+}
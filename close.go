@@ -0,0 +1,102 @@
+package gofpdi
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// The source of a closed reader, which fails every read
+type closedSource struct{}
+
+func (closedSource) Read(p []byte) (int, error) {
+	return 0, errors.New("Reader has been closed")
+}
+
+func (closedSource) ReadAt(p []byte, off int64) (int, error) {
+	return 0, errors.New("Reader has been closed")
+}
+
+func (closedSource) Seek(offset int64, whence int) (int64, error) {
+	return 0, errors.New("Reader has been closed")
+}
+
+// Close the file opened by NewPdfReader.  Sources and streams passed to the
+// reader by the caller are left open for the caller to close.  The reader
+// cannot read its source any more; closing it again does nothing.
+func (pdfReader *PdfReader) Close() error {
+	closer := pdfReader.closer
+	pdfReader.closer = nil
+	pdfReader.f = closedSource{}
+
+	if closer == nil {
+		return nil
+	}
+	if err := closer.Close(); err != nil {
+		return errors.Wrap(err, "Failed to close source")
+	}
+	return nil
+}
+
+// Close the files of all sources (see PdfReader.Close) and remove the
+// temporary files used by the importer (see SetSpill), e.g. when it is done
+// in a long-running service.  Pages cannot be imported from the sources
+// afterwards.
+func (importer *Importer) Close() error {
+	sources := make([]string, 0, len(importer.readers))
+	for source := range importer.readers {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	var err error
+	for _, source := range sources {
+		if closeErr := importer.readers[source].Close(); closeErr != nil && err == nil {
+			err = errors.Wrap(closeErr, "Failed to close "+source)
+		}
+	}
+
+	if closeErr := importer.spill.close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Close the file of a source as soon as every page of it has been imported
+// and the form xobjects of its templates have been put, so importing many
+// files does not keep them all open until Close.
+func (importer *Importer) SetAutoClose(b bool) {
+	importer.autoClose = b
+}
+
+// Close the reader of source if SetAutoClose is set and nothing more needs to
+// be read from it
+func (importer *Importer) autoCloseSource(source string) error {
+	reader, writer := importer.GetReaderForFile(source), importer.GetWriterForFile(source)
+	if !importer.autoClose || reader == nil || writer == nil {
+		return nil
+	}
+
+	numPages, err := reader.getNumPages()
+	if err != nil {
+		return nil
+	}
+
+	pages := make(map[int]bool, numPages)
+	for _, tpl := range writer.tpls {
+		if tpl.Reader != reader {
+			continue
+		}
+		if tpl.objId == nil {
+			return nil
+		}
+		pages[tpl.PageNo] = true
+	}
+	for pageno := 1; pageno <= numPages; pageno++ {
+		if !pages[pageno] {
+			return nil
+		}
+	}
+
+	return reader.Close()
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hrubymar10/gofpdi"
+)
+
+// Resource dictionaries followed into forms before giving up
+const maxResourceDepth = 32
+
+// What inspect reports about a file
+type fileReport struct {
+	File string `json:"file"`
+	// Why the file could not be read, the other fields are then not set
+	Error       string                 `json:"error,omitempty"`
+	Pages       int                    `json:"pages"`
+	Encrypted   bool                   `json:"encrypted"`
+	Permissions *permissionsReport     `json:"permissions,omitempty"`
+	Geometry    []*gofpdi.PageGeometry `json:"geometry"`
+	Objects     objectReport           `json:"objects"`
+	// /BaseFont names of the fonts used by pages that are not embedded, sorted
+	MissingFonts []string `json:"missingFonts"`
+	Warnings     []string `json:"warnings"`
+}
+
+// The permissions of an encrypted file, see gofpdi.Permissions
+type permissionsReport struct {
+	P                       int32 `json:"p"`
+	Print                   bool  `json:"print"`
+	Modify                  bool  `json:"modify"`
+	Copy                    bool  `json:"copy"`
+	Annotate                bool  `json:"annotate"`
+	FillForms               bool  `json:"fillForms"`
+	ExtractForAccessibility bool  `json:"extractForAccessibility"`
+	Assemble                bool  `json:"assemble"`
+	PrintHighQuality        bool  `json:"printHighQuality"`
+}
+
+// Counts of the objects of a file
+type objectReport struct {
+	// Size of the cross-reference table (/Size of the trailer)
+	Count int `json:"count"`
+	// Distinct fonts, images and forms used by the pages
+	Fonts  int `json:"fonts"`
+	Images int `json:"images"`
+	Forms  int `json:"forms"`
+	// Objects parsed to inspect the file and their estimated size in memory
+	Parsed      int   `json:"parsed"`
+	ParsedBytes int64 `json:"parsedBytes"`
+}
+
+// Run the inspect command and get its exit status
+func inspect(args []string) int {
+	flags := flag.NewFlagSet("inspect", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "write a JSON array with an object per file")
+	flags.Usage = usage
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		usage()
+	}
+
+	status := 0
+	reports := make([]*fileReport, 0, flags.NArg())
+	for _, file := range flags.Args() {
+		report := inspectFile(file)
+		if report.Error != "" {
+			status = 1
+		}
+		reports = append(reports, report)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(reports); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return status
+	}
+
+	for _, report := range reports {
+		writeReport(os.Stdout, report)
+	}
+	return status
+}
+
+// Inspect a file.  Errors are recorded in the report.
+func inspectFile(file string) *fileReport {
+	report := &fileReport{File: file, MissingFonts: []string{}, Warnings: []string{}}
+
+	importer := gofpdi.NewImporter()
+	if err := importer.SetSourceFile(file); err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	reader := importer.GetReader()
+
+	pages, err := importer.GetNumPages()
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	report.Pages = pages
+
+	geometry, err := importer.GetPageGeometry()
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	report.Geometry = geometry
+
+	trailer := reader.GetTrailer()
+	if size, err := reader.ResolveKey(trailer, "/Size"); err == nil {
+		report.Objects.Count, _ = size.AsInt()
+	}
+	if encrypt, err := reader.ResolveKey(trailer, "/Encrypt"); err == nil && !encrypt.IsNull() {
+		report.Encrypted = true
+		if p, err := reader.ResolveKey(encrypt, "/P"); err == nil {
+			n, _ := p.AsInt()
+			report.Permissions = newPermissionsReport(int32(n))
+		}
+	}
+
+	resources := newResourceCollector(reader)
+	err = reader.WalkPages(func(pageno int, page *gofpdi.PdfValue) error {
+		return resources.collect(inheritedResources(reader, page), 0)
+	})
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	report.Objects.Fonts = len(resources.fonts)
+	report.Objects.Images = resources.images
+	report.Objects.Forms = resources.forms
+	for name := range resources.missing {
+		report.MissingFonts = append(report.MissingFonts, name)
+	}
+	sort.Strings(report.MissingFonts)
+
+	stats := importer.Stats()
+	report.Objects.Parsed = stats.ParsedObjectCount
+	report.Objects.ParsedBytes = stats.ParsedObjects
+	report.Warnings = append(report.Warnings, reader.GetWarnings()...)
+
+	return report
+}
+
+func newPermissionsReport(p int32) *permissionsReport {
+	permissions := gofpdi.PermissionsFromP(p)
+	return &permissionsReport{
+		P:                       p,
+		Print:                   permissions.Print,
+		Modify:                  permissions.Modify,
+		Copy:                    permissions.Copy,
+		Annotate:                permissions.Annotate,
+		FillForms:               permissions.FillForms,
+		ExtractForAccessibility: permissions.ExtractForAccessibility,
+		Assemble:                permissions.Assemble,
+		PrintHighQuality:        permissions.PrintHighQuality,
+	}
+}
+
+// Get the resources of a page, which it may inherit from the page tree
+func inheritedResources(reader *gofpdi.PdfReader, page *gofpdi.PdfValue) *gofpdi.PdfValue {
+	for depth := 0; page != nil && depth < maxResourceDepth; depth++ {
+		if resources, err := reader.ResolveKey(page, "/Resources"); err == nil {
+			return resources
+		}
+		parent, err := reader.ResolveKey(page, "/Parent")
+		if err != nil {
+			return nil
+		}
+		page = parent
+	}
+	return nil
+}
+
+// Collects the fonts, images and forms of resource dictionaries
+type resourceCollector struct {
+	reader *gofpdi.PdfReader
+	// Fonts and xobjects seen, by object number
+	fonts    map[int]bool
+	xobjects map[int]bool
+	images   int
+	forms    int
+	missing  map[string]bool
+}
+
+func newResourceCollector(reader *gofpdi.PdfReader) *resourceCollector {
+	return &resourceCollector{
+		reader:   reader,
+		fonts:    make(map[int]bool, 0),
+		xobjects: make(map[int]bool, 0),
+		missing:  make(map[string]bool, 0),
+	}
+}
+
+// Collect the fonts and xobjects of a resource dictionary and of the forms it
+// has, and of Type 3 fonts
+func (collector *resourceCollector) collect(resources *gofpdi.PdfValue, depth int) error {
+	if resources.IsNull() || depth > maxResourceDepth {
+		return nil
+	}
+	reader := collector.reader
+
+	if fonts, err := reader.ResolveKey(resources, "/Font"); err == nil {
+		names, _ := fonts.Keys()
+		for _, name := range names {
+			ref, _ := fonts.GetKey(name)
+			if ref.IsRef() {
+				if collector.fonts[ref.Id] {
+					continue
+				}
+				collector.fonts[ref.Id] = true
+			}
+			font, err := reader.Resolve(ref)
+			if err != nil {
+				return err
+			}
+			if err := collector.collectFont(font, depth); err != nil {
+				return err
+			}
+		}
+	}
+
+	if xobjects, err := reader.ResolveKey(resources, "/XObject"); err == nil {
+		names, _ := xobjects.Keys()
+		for _, name := range names {
+			ref, _ := xobjects.GetKey(name)
+			if ref.IsRef() {
+				if collector.xobjects[ref.Id] {
+					continue
+				}
+				collector.xobjects[ref.Id] = true
+			}
+			xobject, err := reader.Resolve(ref)
+			if err != nil {
+				return err
+			}
+			subtype, _ := reader.ResolveKey(xobject, "/Subtype")
+			switch name, _ := subtype.AsName(); name {
+			case "/Image":
+				collector.images++
+			case "/Form":
+				collector.forms++
+				form, _ := reader.ResolveKey(xobject, "/Resources")
+				if err := collector.collect(form, depth+1); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Record a font as missing if it does not embed its font file
+func (collector *resourceCollector) collectFont(font *gofpdi.PdfValue, depth int) error {
+	reader := collector.reader
+
+	subtype, _ := reader.ResolveKey(font, "/Subtype")
+	name, _ := subtype.AsName()
+	switch name {
+	case "/Type3":
+		// Glyphs are content streams, which may use fonts themselves
+		resources, _ := reader.ResolveKey(font, "/Resources")
+		return collector.collect(resources, depth+1)
+	case "/Type0":
+		// The font file is in the descriptor of the descendant font
+		descendants, err := reader.ResolveKey(font, "/DescendantFonts")
+		if err != nil {
+			return nil
+		}
+		descendant, err := descendants.Index(0)
+		if err != nil {
+			return nil
+		}
+		if font, err = reader.Resolve(descendant); err != nil {
+			return err
+		}
+	}
+
+	descriptor, _ := reader.ResolveKey(font, "/FontDescriptor")
+	for _, key := range []string{"/FontFile", "/FontFile2", "/FontFile3"} {
+		if !descriptor.IsNull() && descriptor.HasKey(key) {
+			return nil
+		}
+	}
+
+	baseFont, _ := reader.ResolveKey(font, "/BaseFont")
+	if baseFont, err := baseFont.AsName(); err == nil {
+		collector.missing[strings.TrimPrefix(baseFont, "/")] = true
+	}
+	return nil
+}
+
+// Write a report as text
+func writeReport(w io.Writer, report *fileReport) {
+	fmt.Fprintf(w, "%s:\n", report.File)
+	if report.Error != "" {
+		fmt.Fprintf(w, "  error: %s\n", report.Error)
+		return
+	}
+
+	fmt.Fprintf(w, "  pages: %d\n", report.Pages)
+	for _, page := range report.Geometry {
+		fmt.Fprintf(w, "  page %d: %.2f x %.2f pt (%.1f x %.1f mm)", page.Page, page.WidthPt, page.HeightPt, page.WidthMm, page.HeightMm)
+		if page.Rotation != 0 {
+			fmt.Fprintf(w, ", rotated %d", page.Rotation)
+		}
+		if page.UserUnit != 1 {
+			fmt.Fprintf(w, ", user unit %g", page.UserUnit)
+		}
+		fmt.Fprintln(w)
+	}
+
+	if report.Encrypted {
+		fmt.Fprintf(w, "  encrypted: yes")
+		if report.Permissions != nil {
+			fmt.Fprintf(w, " (P %d)", report.Permissions.P)
+		}
+		fmt.Fprintln(w)
+	} else {
+		fmt.Fprintln(w, "  encrypted: no")
+	}
+
+	objects := report.Objects
+	fmt.Fprintf(w, "  objects: %d (%d fonts, %d images, %d forms used by pages)\n", objects.Count, objects.Fonts, objects.Images, objects.Forms)
+	if len(report.MissingFonts) > 0 {
+		fmt.Fprintf(w, "  fonts not embedded: %s\n", strings.Join(report.MissingFonts, ", "))
+	}
+	for _, warning := range report.Warnings {
+		fmt.Fprintf(w, "  warning: %s\n", warning)
+	}
+}
@@ -0,0 +1,34 @@
+// Command gofpdi inspects PDF documents with the gofpdi package.
+//
+// Usage:
+//
+//	gofpdi inspect [-json] file...
+//
+// inspect reports the pages, page geometry, encryption, objects, fonts that
+// are not embedded and warnings of each file, as text or, with -json, as a
+// JSON array with an object per file for scripts and CI pipelines.  The exit
+// status is 1 if a file could not be read and 2 for wrong usage.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: gofpdi inspect [-json] file...")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "inspect":
+		os.Exit(inspect(os.Args[2:]))
+	default:
+		usage()
+	}
+}
@@ -0,0 +1,260 @@
+package gofpdi
+
+import (
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// The device color model that imported content is converted to
+type ColorModel int
+
+const (
+	// Keep colors as they are
+	ColorModelOriginal ColorModel = iota
+	// Convert RGB and CMYK colors to gray
+	ColorModelGray
+	// Convert RGB colors to CMYK.  Gray is kept, as it is valid in CMYK output.
+	ColorModelCMYK
+)
+
+// Get the device color space that colors of space are converted to, or "" if
+// they are kept
+func (model ColorModel) target(space string) string {
+	switch {
+	case model == ColorModelGray && (space == "/DeviceRGB" || space == "/DeviceCMYK"):
+		return "/DeviceGray"
+	case model == ColorModelCMYK && space == "/DeviceRGB":
+		return "/DeviceCMYK"
+	}
+	return ""
+}
+
+// Convert color components from a device color space to another
+func convertColor(c []float64, from string, to string) []float64 {
+	switch {
+	case from == "/DeviceRGB" && to == "/DeviceGray":
+		return []float64{0.3*c[0] + 0.59*c[1] + 0.11*c[2]}
+	case from == "/DeviceCMYK" && to == "/DeviceGray":
+		return []float64{1 - math.Min(1, 0.3*c[0]+0.59*c[1]+0.11*c[2]+c[3])}
+	case from == "/DeviceRGB" && to == "/DeviceCMYK":
+		k := 1 - math.Max(c[0], math.Max(c[1], c[2]))
+		if k >= 1 {
+			return []float64{0, 0, 0, 1}
+		}
+		return []float64{(1 - c[0] - k) / (1 - k), (1 - c[1] - k) / (1 - k), (1 - c[2] - k) / (1 - k), k}
+	}
+	return c
+}
+
+// Get the number of components of a device color space
+func deviceComponents(space string) int {
+	switch space {
+	case "/DeviceGray":
+		return 1
+	case "/DeviceRGB":
+		return 3
+	case "/DeviceCMYK":
+		return 4
+	}
+	return 0
+}
+
+// Convert the device colors set by a content stream to model.  Colors of
+// inline images and shadings are not converted.
+func convertContentColors(content []byte, model ColorModel) ([]byte, error) {
+	ops, err := ParseContentStream(content)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to parse content stream")
+	}
+
+	// The original color spaces for non-stroking (0) and stroking (1) operators
+	spaces := [2]string{"/DeviceGray", "/DeviceGray"}
+	stack := make([][2]string, 0)
+
+	for _, op := range ops {
+		i := 0
+		if isStrokingColorOperator(op.Operator) {
+			i = 1
+		}
+
+		switch op.Operator {
+		case "q":
+			stack = append(stack, spaces)
+		case "Q":
+			if len(stack) > 0 {
+				spaces = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+		case "g", "G":
+			spaces[i] = "/DeviceGray"
+			convertColorOperation(op, "/DeviceGray", model)
+		case "rg", "RG":
+			spaces[i] = "/DeviceRGB"
+			convertColorOperation(op, "/DeviceRGB", model)
+		case "k", "K":
+			spaces[i] = "/DeviceCMYK"
+			convertColorOperation(op, "/DeviceCMYK", model)
+		case "cs", "CS":
+			name := nameOperand(op)
+			spaces[i] = name
+			if to := model.target(name); to != "" {
+				op.Operands[len(op.Operands)-1] = &PdfValue{Type: PDF_TYPE_TOKEN, Token: to}
+			}
+		case "sc", "scn", "SC", "SCN":
+			if model.target(spaces[i]) != "" {
+				convertColorOperation(op, spaces[i], model)
+			}
+		}
+	}
+
+	return WriteContentStream(ops), nil
+}
+
+// Replace the operands of a color operator in device color space from with
+// the converted color, e.g. "1 0 0 rg" becomes "0.3 g"
+func convertColorOperation(op *ContentOperation, from string, model ColorModel) {
+	to := model.target(from)
+	if to == "" || len(op.Operands) != deviceComponents(from) {
+		return
+	}
+
+	converted := convertColor(numericOperands(op), from, to)
+
+	op.Operands = make([]*PdfValue, 0, len(converted))
+	for _, c := range converted {
+		c = math.Round(c*10000) / 10000
+		op.Operands = append(op.Operands, &PdfValue{Type: PDF_TYPE_REAL, Real: c})
+	}
+
+	switch op.Operator {
+	case "sc", "scn", "SC", "SCN":
+		return
+	}
+
+	operators := map[string]string{"/DeviceGray": "g", "/DeviceCMYK": "k"}
+	if isStrokingColorOperator(op.Operator) {
+		operators = map[string]string{"/DeviceGray": "G", "/DeviceCMYK": "K"}
+	}
+	op.Operator = operators[to]
+}
+
+func isStrokingColorOperator(operator string) bool {
+	switch operator {
+	case "G", "RG", "K", "CS", "SC", "SCN":
+		return true
+	}
+	return false
+}
+
+// Convert the colors of an image, form xobject or tiling pattern stream to
+// model.  Returns obj unchanged if it has no colors to convert or cannot be converted.
+func (pdfReader *PdfReader) convertStreamColors(obj *PdfValue, model ColorModel) *PdfValue {
+	dict := obj.Value.Dictionary
+
+	// Tiling patterns have a content stream like forms
+	if patternType, ok := dict["/PatternType"]; ok && patternType.Int == 1 {
+		return pdfReader.convertContentStreamColors(obj, model)
+	}
+
+	subtype, ok := dict["/Subtype"]
+	if !ok {
+		return obj
+	}
+
+	switch subtype.Token {
+	case "/Form":
+		return pdfReader.convertContentStreamColors(obj, model)
+	case "/Image":
+		return pdfReader.convertImageColors(obj, model)
+	}
+
+	return obj
+}
+
+// Convert the colors of a form xobject or tiling pattern content stream
+func (pdfReader *PdfReader) convertContentStreamColors(obj *PdfValue, model ColorModel) *PdfValue {
+	content, err := pdfReader.rebuildContentStream(obj)
+	if err != nil {
+		return obj
+	}
+
+	content, err = convertContentColors(content, model)
+	if err != nil {
+		return obj
+	}
+
+	return replaceStreamData(obj, deflate(content), nil)
+}
+
+// Convert the samples of an 8 bit device color image to model
+func (pdfReader *PdfReader) convertImageColors(obj *PdfValue, model ColorModel) *PdfValue {
+	dict := obj.Value.Dictionary
+
+	// Decode arrays and color key masks depend on the color space
+	if _, ok := dict["/Decode"]; ok {
+		return obj
+	}
+	if mask, ok := dict["/Mask"]; ok && mask.Type == PDF_TYPE_ARRAY {
+		return obj
+	}
+
+	cs, err := pdfReader.resolveDirect(dict["/ColorSpace"])
+	if err != nil || cs == nil || cs.Type != PDF_TYPE_TOKEN {
+		return obj
+	}
+
+	from := cs.Token
+	to := model.target(from)
+	if to == "" {
+		return obj
+	}
+
+	width := imageDictInt(dict, "/Width", "/W")
+	height := imageDictInt(dict, "/Height", "/H")
+	components := deviceComponents(from)
+
+	pixels, err := pdfReader.imagePixels(obj, width, height, components)
+	if err != nil {
+		return obj
+	}
+
+	c := make([]float64, components)
+	converted := make([]byte, 0, width*height*deviceComponents(to))
+	for i := 0; i < width*height; i++ {
+		for j := range c {
+			c[j] = float64(pixels[i*components+j]) / 255
+		}
+		for _, v := range convertColor(c, from, to) {
+			converted = append(converted, byte(math.Round(v*255)))
+		}
+	}
+
+	return replaceStreamData(obj, deflate(converted), map[string]*PdfValue{
+		"/ColorSpace":       {Type: PDF_TYPE_TOKEN, Token: to},
+		"/BitsPerComponent": {Type: PDF_TYPE_NUMERIC, Int: 8},
+	})
+}
+
+// Copy a stream object with new data and changed dictionary entries.  The data
+// is Flate encoded unless entries has another /Filter.
+func replaceStreamData(obj *PdfValue, data []byte, entries map[string]*PdfValue) *PdfValue {
+	dict := make(map[string]*PdfValue, len(obj.Value.Dictionary))
+	for k, v := range obj.Value.Dictionary {
+		dict[k] = v
+	}
+	delete(dict, "/DecodeParms")
+	dict["/Filter"] = &PdfValue{Type: PDF_TYPE_TOKEN, Token: "/FlateDecode"}
+	for k, v := range entries {
+		dict[k] = v
+	}
+	dict["/Length"] = &PdfValue{Type: PDF_TYPE_NUMERIC, Int: len(data)}
+
+	return &PdfValue{
+		Type:   PDF_TYPE_STREAM,
+		Id:     obj.Id,
+		Gen:    obj.Gen,
+		Value:  &PdfValue{Type: PDF_TYPE_DICTIONARY, Dictionary: dict},
+		Stream: &PdfValue{Type: PDF_TYPE_STREAM, Bytes: data},
+	}
+}
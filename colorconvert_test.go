@@ -0,0 +1,33 @@
+package gofpdi
+
+import (
+	"strings"
+	"testing"
+)
+
+// The content of tiling patterns is converted like that of forms
+func TestConvertTilingPatternColors(t *testing.T) {
+	data := buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 200] /Contents 4 0 R /Resources << /Pattern << /P1 5 0 R >> >> >>",
+		testStream("", "/Pattern cs /P1 scn 0 0 100 100 re f"),
+		testStream("/Type /Pattern /PatternType 1 /PaintType 1 /TilingType 1 /BBox [0 0 10 10] /XStep 10 /YStep 10 /Resources << >>",
+			"1 0 0 rg 0 0 5 5 re f 0 0 1 0 K 0 0 m 10 10 l S"),
+	)
+
+	output := importTestDocument(t, data, func(importer *Importer) {
+		importer.SetColorModel(ColorModelGray)
+	})
+	reader, form := outputForm(t, output, 1)
+	content := string(decodedStream(t, reader, resolvePath(t, reader, form, "/Resources", "/Pattern", "/P1")))
+
+	for _, operator := range []string{" rg", " K"} {
+		if strings.Contains(content, operator) {
+			t.Errorf("content of /P1 still sets colors with%s: %q", operator, content)
+		}
+	}
+	if !strings.Contains(content, " g") || !strings.Contains(content, " G") {
+		t.Errorf("content of /P1 sets no gray colors: %q", content)
+	}
+}
@@ -0,0 +1,251 @@
+package gofpdi
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// The colors a page paints with, see GetColorUsage
+type ColorUsage struct {
+	// Families of the color spaces painted with, sorted, e.g. /DeviceCMYK,
+	// /ICCBased and /Separation
+	ColorSpaces []string
+	// Gray is painted with: /DeviceGray, /CalGray or 1 component /ICCBased
+	Gray bool
+	// RGB is painted with: /DeviceRGB, /CalRGB or 3 component /ICCBased
+	RGB bool
+	// CMYK is painted with: /DeviceCMYK or 4 component /ICCBased
+	CMYK bool
+	// Names of the spot colors, see Importer.GetSpotColors
+	SpotColors []string
+	// Something is painted with a constant alpha below 1, a soft mask or a
+	// blend mode other than /Normal, or an image with a soft mask
+	Transparency bool
+	// Fraction of the crop box covered by marks.  Overlapping marks add up,
+	// so it can be more than 1.
+	Coverage float64
+	// Approximate coverage of cyan, magenta, yellow and black ink, as a
+	// fraction of the crop box covered at full tint, of the paths and text
+	// painted in gray, RGB or CMYK.  RGB is converted like SetColorModel
+	// does; images, shadings and patterns are left out.
+	Inks [4]float64
+}
+
+// Collects the colors of the marks found by an inkHandler
+type colorCollector struct {
+	spaces       map[string]bool
+	gray         bool
+	rgb          bool
+	cmyk         bool
+	transparency bool
+	// Device space area covered by each process ink at full tint
+	inks [4]float64
+}
+
+// Abbreviated color space names of inline images
+var inlineColorSpaces = map[string]string{
+	"/G":    "/DeviceGray",
+	"/RGB":  "/DeviceRGB",
+	"/CMYK": "/DeviceCMYK",
+	"/I":    "/Indexed",
+}
+
+// Get the process color space that colors of a color space family with a
+// number of components are, or "" if they are not gray, RGB or CMYK
+func processColorSpace(space string, components int) string {
+	switch space {
+	case "/DeviceGray", "/CalGray":
+		return "/DeviceGray"
+	case "/DeviceRGB", "/CalRGB":
+		return "/DeviceRGB"
+	case "/DeviceCMYK":
+		return "/DeviceCMYK"
+	case "/ICCBased":
+		switch components {
+		case 1:
+			return "/DeviceGray"
+		case 3:
+			return "/DeviceRGB"
+		case 4:
+			return "/DeviceCMYK"
+		}
+	}
+	return ""
+}
+
+// Record painting in a color space with colors of a number of components
+func (colors *colorCollector) use(it *contentInterpreter, space string, components int, alpha float64) {
+	colors.spaces[space] = true
+	switch processColorSpace(space, components) {
+	case "/DeviceGray":
+		colors.gray = true
+	case "/DeviceRGB":
+		colors.rgb = true
+	case "/DeviceCMYK":
+		colors.cmyk = true
+	}
+	if alpha < 1 || it.gs.softMask || it.gs.blend {
+		colors.transparency = true
+	}
+}
+
+// Add the inks of a color painted on a device space area
+func (colors *colorCollector) addInk(space string, color []float64, area float64) {
+	var cmyk []float64
+	switch from := processColorSpace(space, len(color)); {
+	case from == "/DeviceGray" && len(color) == 1:
+		cmyk = []float64{0, 0, 0, 1 - color[0]}
+	case from == "/DeviceRGB" && len(color) == 3:
+		cmyk = convertColor(color, "/DeviceRGB", "/DeviceCMYK")
+	case from == "/DeviceCMYK" && len(color) == 4:
+		cmyk = color
+	default:
+		return
+	}
+
+	for i, c := range cmyk {
+		if c > 0 {
+			colors.inks[i] += c * area
+		}
+	}
+}
+
+// Record the color space of an image xobject or an inline image
+func (colors *colorCollector) useImage(it *contentInterpreter, image *PdfValue, inline *ContentOperation) {
+	var dict map[string]*PdfValue
+	if image != nil {
+		dict = image.Value.Dictionary
+	} else if len(inline.Operands) > 0 {
+		dict = inline.Operands[len(inline.Operands)-1].Dictionary
+	}
+
+	for _, key := range []string{"/ImageMask", "/IM"} {
+		if v, ok := dict[key]; ok && v.Type == PDF_TYPE_BOOLEAN && v.Bool {
+			// Stencil masks are painted with the fill color
+			colors.use(it, it.gs.fillSpace, len(it.gs.fillColor), it.gs.fillAlpha)
+			return
+		}
+	}
+	if _, ok := dict["/SMask"]; ok {
+		colors.transparency = true
+	}
+	if v, ok := dict["/SMaskInData"]; ok && v.Int > 0 {
+		colors.transparency = true
+	}
+
+	cs, ok := dict["/ColorSpace"]
+	if !ok {
+		cs = dict["/CS"]
+	}
+	colors.useColorSpace(it, cs, it.gs.fillAlpha, 0)
+}
+
+// Record the color space of a shading
+func (colors *colorCollector) useShading(it *contentInterpreter, shading *PdfValue) {
+	dict := shading.Dictionary
+	if shading.Type == PDF_TYPE_STREAM {
+		dict = shading.Value.Dictionary
+	}
+	colors.useColorSpace(it, dict["/ColorSpace"], it.gs.fillAlpha, 0)
+}
+
+// Record a color space given as a name (of a resource or abbreviated) or an array
+func (colors *colorCollector) useColorSpace(it *contentInterpreter, cs *PdfValue, alpha float64, depth int) {
+	if cs == nil || depth > maxColorSpaceDepth {
+		return
+	}
+
+	if cs.Type == PDF_TYPE_TOKEN {
+		name := cs.Token
+		if full, ok := inlineColorSpaces[name]; ok {
+			name = full
+		}
+		switch name {
+		case "/DeviceGray", "/DeviceRGB", "/DeviceCMYK", "/Pattern", "/Indexed":
+			colors.use(it, name, deviceComponents(name), alpha)
+			return
+		}
+		resource, _ := it.resource("/ColorSpace", name)
+		if resource == nil {
+			return
+		}
+		cs = resource
+	}
+
+	cs, err := it.reader.resolveDirect(cs)
+	if err != nil || cs == nil || cs.Type != PDF_TYPE_ARRAY || len(cs.Array) == 0 {
+		return
+	}
+
+	family := cs.Array[0].Token
+	if full, ok := inlineColorSpaces[family]; ok {
+		family = full
+	}
+	components := 0
+	if family == "/ICCBased" && len(cs.Array) > 1 {
+		profile, _ := it.reader.resolveDirect(cs.Array[1])
+		if profile != nil && profile.Type == PDF_TYPE_STREAM {
+			if n, ok := profile.Value.Dictionary["/N"]; ok {
+				components = n.Int
+			}
+		}
+	}
+	colors.use(it, family, components, alpha)
+
+	// The colors of an indexed color space are those of its base
+	if family == "/Indexed" && len(cs.Array) > 1 {
+		colors.useColorSpace(it, cs.Array[1], alpha, depth+1)
+	}
+}
+
+// Find out which colors page pageno paints with (see ColorUsage), e.g. to
+// check material for print against the color spaces, inks and transparency
+// it may use.  The content of the page and of the forms it draws is
+// interpreted; annotations are not considered.
+func (pdfReader *PdfReader) GetColorUsage(pageno int) (*ColorUsage, error) {
+	colors := &colorCollector{spaces: make(map[string]bool, 0)}
+	handler, err := pdfReader.pageInk(pageno, colors)
+	if err != nil {
+		return nil, err
+	}
+
+	spots, err := pdfReader.getPageSpotColors(pageno)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get spot colors")
+	}
+
+	usage := &ColorUsage{
+		ColorSpaces:  make([]string, 0, len(colors.spaces)),
+		Gray:         colors.gray,
+		RGB:          colors.rgb,
+		CMYK:         colors.cmyk,
+		SpotColors:   spots,
+		Transparency: colors.transparency,
+	}
+	for space := range colors.spaces {
+		usage.ColorSpaces = append(usage.ColorSpaces, space)
+	}
+	sort.Strings(usage.ColorSpaces)
+
+	page := handler.page
+	if area := (page[2] - page[0]) * (page[3] - page[1]); area > 0 {
+		usage.Coverage = handler.area / area
+		for i, ink := range colors.inks {
+			usage.Inks[i] = ink / area
+		}
+	}
+
+	return usage, nil
+}
+
+// Find out which colors page pageno of the current source paints with, see
+// PdfReader.GetColorUsage
+func (importer *Importer) GetColorUsage(pageno int) (*ColorUsage, error) {
+	usage, err := importer.GetReader().GetColorUsage(pageno)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("Failed to analyze page %d", pageno))
+	}
+	return usage, nil
+}
@@ -0,0 +1,42 @@
+package gofpdi
+
+// Write only the whitespace between tokens that would run together without
+// it, see Importer.SetCompactOutput
+func (pdfWriter *PdfWriter) SetCompactOutput(b bool) {
+	pdfWriter.compact_output = b
+}
+
+// Write the whitespace the last token written needs before the next one,
+// s.  With compact output, the separators of tokens are only written when
+// both are regular characters, e.g. "/Type /Page" but "/Length 12/Filter".
+func (pdfWriter *PdfWriter) separate(s string) {
+	if !pdfWriter.separator_pending {
+		return
+	}
+	pdfWriter.separator_pending = false
+
+	buffer := pdfWriter.current_obj.buffer
+	if s == "" || buffer.Len() == 0 {
+		return
+	}
+	last := buffer.Bytes()[buffer.Len()-1]
+	if isRegularByte(last) && isRegularByte(s[0]) {
+		buffer.WriteByte(' ')
+	}
+}
+
+// Check whether c is a regular character of the PDF syntax, which tokens
+// are not separated by
+func isRegularByte(c byte) bool {
+	return !isWhitespace(c) && !isDelimiter(c)
+}
+
+// Write copied objects with only the whitespace the PDF syntax needs between
+// tokens, e.g. "<</Type/Page/Contents 12 0 R>>" instead of "<</Type /Page
+// /Contents 12 0 R >>", to make large documents smaller.  The objects mean
+// the same either way.  Off by default.
+func (importer *Importer) SetCompactOutput(b bool) {
+	importer.setWriterOptions(func(options *writerOptions) {
+		options.compact_output = b
+	})
+}
@@ -0,0 +1,129 @@
+package gofpdi
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// The placement of a template in a composite template, see ComposeTemplates
+type TemplatePlacement struct {
+	// Template id (returned from ImportPage) of the placed template
+	TplId int
+	// Offset of the template from the top left corner of the composite
+	// template (bottom left with OriginBottomLeft) and its size, as passed to
+	// UseTemplate.  If one of W and H is 0, it is calculated from the other
+	// keeping the aspect ratio; if both are 0, the template's own size is used.
+	X float64
+	Y float64
+	W float64
+	H float64
+}
+
+// A template drawn by a composite template, with the name of its form xobject
+// in the resources of the composite
+type templatePart struct {
+	tpl  *PdfTemplate
+	name string
+}
+
+// Create a template of size w x h that draws several templates, e.g. the two
+// pages of a spread, so they can be placed (and scaled) together.  The new
+// template is a form xobject that invokes the form xobjects of the placed
+// templates, which are written only once however often they are used.
+//
+// The composite template belongs to the source of the first placed template
+// and is written by its PutFormXobjects.  Templates of other sources must be
+// written before that, by PutFormXobjects of their sources.
+func (importer *Importer) ComposeTemplates(w float64, h float64, placements []TemplatePlacement) (int, error) {
+	if w <= 0 || h <= 0 {
+		return -1, errors.New("Composite template is empty")
+	}
+	if len(placements) == 0 {
+		return -1, errors.New("No templates to compose")
+	}
+
+	first, ok := importer.tplMap[placements[0].TplId]
+	if !ok {
+		return -1, errors.New(fmt.Sprintf("Template %d does not exist", placements[0].TplId))
+	}
+	writer := first.Writer
+
+	var content bytes.Buffer
+	parts := make([]*templatePart, 0, len(placements))
+	names := make(map[*PdfTemplate]string, len(placements))
+	for _, placement := range placements {
+		tplInfo, ok := importer.tplMap[placement.TplId]
+		if !ok {
+			return -1, errors.New(fmt.Sprintf("Template %d does not exist", placement.TplId))
+		}
+		tpl := tplInfo.Writer.tpls[tplInfo.TemplateId]
+
+		// Each template is a resource of the composite once
+		name, ok := names[tpl]
+		if !ok {
+			name = fmt.Sprintf("/TPL%d", len(parts))
+			names[tpl] = name
+			parts = append(parts, &templatePart{tpl: tpl, name: name})
+		}
+
+		_, scaleX, scaleY, tx, ty := tplInfo.Writer.useTemplate(tplInfo.TemplateId, placement.X, placement.Y, placement.W, placement.H)
+		if importer.options.origin == OriginTopLeft {
+			// Relative to the top of the composite rather than of a page
+			ty += h * importer.options.k
+		}
+
+		content.WriteString(fmt.Sprintf("q %.5F 0 0 %.5F %.5F %.5F cm %s Do Q\n", scaleX, scaleY, tx, ty, name))
+	}
+
+	tpl := &PdfTemplate{
+		Reader: first.Writer.tpls[first.TemplateId].Reader,
+		Buffer: content.Bytes(),
+		Box: map[string]float64{
+			"x":   0,
+			"y":   0,
+			"w":   w,
+			"h":   h,
+			"llx": 0,
+			"lly": 0,
+			"urx": w,
+			"ury": h,
+		},
+		W:     w,
+		H:     h,
+		k:     importer.options.k,
+		parts: parts,
+	}
+
+	res := writer.addTemplate(tpl)
+
+	tplN := importer.tplN
+	importer.tplMap[tplN] = &TplInfo{SourceFile: first.SourceFile, TemplateId: res, Writer: writer}
+	importer.tplN++
+
+	return tplN, nil
+}
+
+// Output the resources of a composite template: the form xobjects of its
+// parts and the font of its text layer
+func (pdfWriter *PdfWriter) writePartResources(tpl *PdfTemplate) error {
+	pdfWriter.straightOut("<</XObject <<")
+	for _, part := range tpl.parts {
+		if part.tpl.objId == nil {
+			return errors.New("A template of the composite template has not been written, put the form xobjects of its source first")
+		}
+
+		pdfWriter.outToken(part.name)
+		pdfWriter.outPdfObjectIdRef(part.tpl.objId)
+		pdfWriter.part_objs[part.tpl.objId.hash] = true
+	}
+	pdfWriter.straightOut(">>")
+
+	if tpl.textLayer != nil {
+		pdfWriter.writeTextLayerResource(tpl)
+	}
+	pdfWriter.out(">>")
+
+	return nil
+}
@@ -0,0 +1,243 @@
+package gofpdi
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// A single operation of a content stream, e.g. "1 0 0 1 10 10 cm"
+type ContentOperation struct {
+	Operator string
+	Operands []*PdfValue
+	// Image data of an inline image (operator BI)
+	Data []byte
+}
+
+// Parse a decoded content stream into its operations
+func ParseContentStream(data []byte) ([]*ContentOperation, error) {
+	var err error
+
+	// The tokenizer needs a delimiter after the last token
+	buf := make([]byte, len(data)+1)
+	copy(buf, data)
+	buf[len(data)] = '\n'
+
+	lexer := &PdfReader{}
+	r := bufio.NewReader(bytes.NewReader(buf))
+
+	ops := make([]*ContentOperation, 0)
+	operands := make([]*PdfValue, 0)
+
+	for {
+		t, err := lexer.readToken(r)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to read token")
+		}
+		if t == "" {
+			break
+		}
+
+		switch t {
+		case "[", "(", "<", "<<":
+			value, err := lexer.readValue(r, t)
+			if err != nil {
+				return nil, errors.Wrap(err, "Failed to read value for token: "+t)
+			}
+			operands = append(operands, value)
+			continue
+		}
+
+		if value := contentTokenValue(t); value != nil {
+			operands = append(operands, value)
+			continue
+		}
+
+		op := &ContentOperation{Operator: t, Operands: operands}
+		operands = make([]*PdfValue, 0)
+
+		if t == "BI" {
+			err = lexer.readInlineImage(r, op)
+			if err != nil {
+				return nil, errors.Wrap(err, "Failed to read inline image")
+			}
+		}
+
+		ops = append(ops, op)
+	}
+
+	return ops, err
+}
+
+// Convert a token that is not an operator into a PdfValue.  Returns nil for operators.
+// Unlike readValue, this does not look ahead for object references, which do
+// not exist in content streams.
+func contentTokenValue(t string) *PdfValue {
+	if t[0] == '/' {
+		return &PdfValue{Type: PDF_TYPE_TOKEN, Token: t}
+	}
+
+	if is_numeric(t) {
+		if n, err := strconv.Atoi(t); err == nil {
+			return &PdfValue{Type: PDF_TYPE_NUMERIC, Int: n, Real: float64(n)}
+		}
+		real, _ := strconv.ParseFloat(t, 64)
+		return &PdfValue{Type: PDF_TYPE_REAL, Real: real}
+	}
+
+	switch t {
+	case "true", "false":
+		return &PdfValue{Type: PDF_TYPE_BOOLEAN, Bool: t == "true"}
+	case "null":
+		return &PdfValue{Type: PDF_TYPE_NULL}
+	}
+
+	return nil
+}
+
+// Read the dictionary and data of an inline image (BI ... ID ... EI)
+func (pdfReader *PdfReader) readInlineImage(r *bufio.Reader, op *ContentOperation) error {
+	dict := &PdfValue{Type: PDF_TYPE_DICTIONARY, Dictionary: make(map[string]*PdfValue, 0)}
+
+	for {
+		key, err := pdfReader.readToken(r)
+		if err != nil {
+			return errors.Wrap(err, "Failed to read token")
+		}
+		if key == "" {
+			return errors.New("Unexpected end of inline image")
+		}
+		if key == "ID" {
+			break
+		}
+
+		t, err := pdfReader.readToken(r)
+		if err != nil {
+			return errors.Wrap(err, "Failed to read token")
+		}
+
+		var value *PdfValue
+		switch t {
+		case "[", "(", "<", "<<":
+			value, err = pdfReader.readValue(r, t)
+			if err != nil {
+				return errors.Wrap(err, "Failed to read value for token: "+t)
+			}
+		default:
+			value = contentTokenValue(t)
+			if value == nil {
+				value = &PdfValue{Type: PDF_TYPE_TOKEN, Token: t}
+			}
+		}
+
+		dict.Dictionary[key] = value
+	}
+
+	// A single whitespace character separates ID from the image data
+	if _, err := r.ReadByte(); err != nil {
+		return errors.Wrap(err, "Failed to read byte")
+	}
+
+	// Read until EI surrounded by whitespace
+	var data []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return errors.New("Could not find end of inline image")
+		}
+		data = append(data, b)
+
+		n := len(data)
+		if n >= 3 && data[n-2] == 'E' && data[n-1] == 'I' && isWhitespace(data[n-3]) {
+			next, err := r.Peek(1)
+			if err != nil || isWhitespace(next[0]) || isDelimiter(next[0]) {
+				data = data[:n-3]
+				break
+			}
+		}
+	}
+
+	op.Operands = append(op.Operands, dict)
+	op.Data = data
+
+	return nil
+}
+
+func isWhitespace(b byte) bool {
+	return b == ' ' || b == '\n' || b == '\r' || b == '\t' || b == '\f' || b == 0
+}
+
+func isDelimiter(b byte) bool {
+	return strings.IndexByte("()<>[]{}/%", b) >= 0
+}
+
+// Serialize content operations into a content stream
+func WriteContentStream(ops []*ContentOperation) []byte {
+	var buf bytes.Buffer
+
+	for _, op := range ops {
+		if op.Operator == "BI" {
+			buf.WriteString("BI")
+			if len(op.Operands) > 0 {
+				dict := op.Operands[0].Dictionary
+				for _, k := range sortedKeys(dict) {
+					buf.WriteString(" " + escapeName(k) + " ")
+					writeContentValue(&buf, dict[k])
+				}
+			}
+			buf.WriteString(" ID ")
+			buf.Write(op.Data)
+			buf.WriteString("\nEI\n")
+			continue
+		}
+
+		for _, operand := range op.Operands {
+			writeContentValue(&buf, operand)
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(op.Operator)
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes()
+}
+
+// Write a direct PdfValue the way it appears in a content stream
+func writeContentValue(buf *bytes.Buffer, value *PdfValue) {
+	switch value.Type {
+	case PDF_TYPE_TOKEN:
+		buf.WriteString(escapeName(value.Token))
+	case PDF_TYPE_NUMERIC:
+		buf.WriteString(strconv.Itoa(value.Int))
+	case PDF_TYPE_REAL:
+		buf.WriteString(strconv.FormatFloat(value.Real, 'f', -1, 64))
+	case PDF_TYPE_STRING:
+		buf.WriteString("(" + value.String + ")")
+	case PDF_TYPE_HEX:
+		buf.WriteString("<" + value.String + ">")
+	case PDF_TYPE_BOOLEAN:
+		buf.WriteString(strconv.FormatBool(value.Bool))
+	case PDF_TYPE_NULL:
+		buf.WriteString("null")
+	case PDF_TYPE_ARRAY:
+		buf.WriteByte('[')
+		for i, v := range value.Array {
+			if i > 0 {
+				buf.WriteByte(' ')
+			}
+			writeContentValue(buf, v)
+		}
+		buf.WriteByte(']')
+	case PDF_TYPE_DICTIONARY:
+		buf.WriteString("<<")
+		for _, k := range sortedKeys(value.Dictionary) {
+			buf.WriteString(escapeName(k) + " ")
+			writeContentValue(buf, value.Dictionary[k])
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(">>")
+	}
+}
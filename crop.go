@@ -0,0 +1,96 @@
+package gofpdi
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// Crop templates to the marks their content makes, see Importer.SetCropToContent
+func (pdfWriter *PdfWriter) SetCropToContent(b bool, margin float64) {
+	pdfWriter.crop_to_content = b
+	pdfWriter.crop_margin = margin
+}
+
+// Get the bounding box of the marks that the content of a page makes within
+// its crop box, in points: of everything that is painted in a color other than
+// white, see IsPageBlank.  Returns false if the page makes no marks.
+func (pdfReader *PdfReader) getContentBox(pageno int) ([4]float64, bool, error) {
+	handler, err := pdfReader.pageInk(pageno, nil)
+	if err != nil {
+		return [4]float64{}, false, err
+	}
+	return handler.bounds, handler.marked, nil
+}
+
+// Shrink the box of a template to the marks of its page and the margin around
+// them; the box is not enlarged.  Pages without marks are left as they are.
+func (pdfWriter *PdfWriter) cropToContent(tpl *PdfTemplate) error {
+	bounds, ok, err := tpl.Reader.getContentBox(tpl.PageNo)
+	if err != nil {
+		return errors.Wrap(err, "Failed to find content box")
+	}
+	if !ok {
+		return nil
+	}
+
+	k := pdfWriter.k
+	d := pdfWriter.crop_margin
+	llx := math.Max(tpl.Box["llx"], bounds[0]/k-d)
+	lly := math.Max(tpl.Box["lly"], bounds[1]/k-d)
+	urx := math.Min(tpl.Box["urx"], bounds[2]/k+d)
+	ury := math.Min(tpl.Box["ury"], bounds[3]/k+d)
+	if urx <= llx || ury <= lly {
+		return nil
+	}
+
+	tpl.Box = map[string]float64{
+		"x":   llx,
+		"y":   lly,
+		"w":   urx - llx,
+		"h":   ury - lly,
+		"llx": llx,
+		"lly": lly,
+		"urx": urx,
+		"ury": ury,
+	}
+	return nil
+}
+
+// Get the bounding box of the marks that the content of page pageno of the
+// current source makes, in the same units and format as the boxes of
+// GetPageSizes, e.g. to place a figure of a page by what it shows.  Returns
+// false if the page makes no marks.
+func (importer *Importer) GetContentBox(pageno int) (map[string]float64, bool, error) {
+	bounds, ok, err := importer.GetReader().getContentBox(pageno)
+	if err != nil {
+		return nil, false, errors.Wrap(err, fmt.Sprintf("Failed to analyze page %d", pageno))
+	}
+	if !ok {
+		return nil, false, nil
+	}
+
+	k := importer.options.k
+	return map[string]float64{
+		"x":   bounds[0] / k,
+		"y":   bounds[1] / k,
+		"w":   (bounds[2] - bounds[0]) / k,
+		"h":   (bounds[3] - bounds[1]) / k,
+		"llx": bounds[0] / k,
+		"lly": bounds[1] / k,
+		"urx": bounds[2] / k,
+		"ury": bounds[3] / k,
+	}, true, nil
+}
+
+// Crop pages imported after the call to the bounding box of the marks their
+// content makes (see GetContentBox), with margin (in user units) around it,
+// e.g. to import a plot from a page that is mostly white paper.  The template
+// never grows beyond the requested box; pages without marks keep it.
+func (importer *Importer) SetCropToContent(b bool, margin float64) {
+	importer.setWriterOptions(func(options *writerOptions) {
+		options.crop_to_content = b
+		options.crop_margin = margin
+	})
+}
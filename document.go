@@ -0,0 +1,624 @@
+package gofpdi
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Object ids of the catalog and the root of the page tree of a Document.
+// Imported objects are numbered from documentFirstId.
+const (
+	documentCatalogId = 1
+	documentPagesId   = 2
+	documentFirstId   = 3
+)
+
+// A PDF document whose pages draw imported templates, written without a pdf
+// generation library, e.g. to merge, reorder or impose the pages of existing
+// documents.  Pages are imported with the importer of the document (see
+// Importer), which also holds the import options.
+type Document struct {
+	importer *Importer
+	pages    []*documentPage
+	// Objects of the imported templates, kept across writes so templates
+	// are only written once
+	backend *MapBackend
+	// Header version set with SetVersion, "" to choose it from the features
+	// used, and the version of the last file written
+	version        string
+	writtenVersion string
+	// Signature field to reserve, see AddSignatureField, and its placeholder
+	// in the file last written
+	signature            *SignatureField
+	signaturePlaceholder *SignaturePlaceholder
+	// PDF/X conformance, see SetPDFX, and the problems found in the last file written
+	pdfx         *PDFXOptions
+	pdfxWarnings []string
+	// Carry the languages of the imported documents, see SetCarryAccessibility
+	carryAccessibility bool
+	// User unit of pages larger than 14400 points, see SetUserUnit
+	userUnit float64
+	// Carry the durations and transitions of the imported pages, see SetCarryPresentation
+	carryPresentation bool
+	// Carry the article threads of the imported pages, see SetCarryThreads
+	carryThreads bool
+	// Document outline, see SetOutlines
+	outlines []*Outline
+	// Embedded files, see AddAttachment
+	attachments []*Attachment
+	// Page labels, see SetPageLabels
+	pageLabels []PageLabel
+}
+
+// A page of a Document: its size and the templates it draws
+type documentPage struct {
+	// Size in user space units, which are points unless the page keeps the
+	// user unit of the page it was imported from
+	w        float64
+	h        float64
+	userUnit float64
+	content  bytes.Buffer
+	// Resource names of the templates drawn by the page
+	names map[int]string
+	tpls  []int
+	// Page boxes other than the media box, [llx lly urx ury] in user space units
+	boxes map[BoxName][4]float64
+	// Duration and transition, see SetPagePresentation
+	presentation *PagePresentation
+	// The imported page added by AddTemplatePage, and the mapping from its
+	// default user space to the page
+	source          *PdfReader
+	sourcePage      int
+	sourceTransform func(x float64, y float64) (float64, float64)
+	// Beads of article threads on the page, see putThreads
+	beads []int
+	// Texts drawn over the templates, e.g. the page numbers of a Merger
+	texts []pageText
+}
+
+// Create a document with a new importer
+func NewDocument() *Document {
+	return &Document{
+		importer: NewImporter(),
+		pages:    make([]*documentPage, 0),
+		backend:  NewMapBackend(documentFirstId),
+	}
+}
+
+// Get the importer of the document, to set sources and options and to import pages
+func (doc *Document) Importer() *Importer {
+	return doc.importer
+}
+
+// Get the number of pages of the document
+func (doc *Document) GetNumPages() int {
+	return len(doc.pages)
+}
+
+// Add an empty page of w by h (in the unit of the importer, see Importer.SetUnit)
+func (doc *Document) AddPage(w float64, h float64) error {
+	if !(w > 0) || !(h > 0) {
+		return errors.New("Page is empty")
+	}
+
+	k := doc.importer.options.k
+	doc.pages = append(doc.pages, &documentPage{w: w * k, h: h * k, userUnit: 1, names: make(map[int]string, 0), boxes: make(map[BoxName][4]float64, 0)})
+	return nil
+}
+
+// Add a page of the size of template tplid (returned from ImportPage) that
+// draws the template, e.g. to copy an imported page as it is.  The bleed, trim
+// and art boxes and the user unit of the imported page are carried over to
+// the new page, so pages with a /UserUnit keep their real-world size.
+func (doc *Document) AddTemplatePage(tplid int) error {
+	w, h, err := doc.importer.GetTemplateSize(tplid, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	return doc.addTemplatePage(tplid, w, h, 0, 0, w, h, true)
+}
+
+// Add a page of w by h (in the unit of the importer) that draws template
+// tplid fitted to it with mode and centered, e.g. to bring pages of
+// different sizes to one size.  The bleed, trim and art boxes of the
+// imported page are carried over like with AddTemplatePage, mapped to where
+// the template is drawn; the user unit is not.
+func (doc *Document) AddFittedTemplatePage(tplid int, w float64, h float64, mode FitMode) error {
+	x, y, fw, fh, err := doc.importer.FitTemplate(tplid, w, h, mode)
+	if err != nil {
+		return err
+	}
+
+	return doc.addTemplatePage(tplid, w, h, x, y, fw, fh, false)
+}
+
+// Add a page of w by h that draws template tplid at x, y with size tplW by
+// tplH, carrying over the boxes, presentation and, if userUnit is set, the
+// user unit of the imported page
+func (doc *Document) addTemplatePage(tplid int, w float64, h float64, x float64, y float64, tplW float64, tplH float64, userUnit bool) error {
+	err := doc.AddPage(w, h)
+	if err != nil {
+		return err
+	}
+
+	err = doc.UseTemplate(tplid, x, y, tplW, tplH)
+	if err != nil {
+		return err
+	}
+
+	page := doc.pages[len(doc.pages)-1]
+	doc.putTemplateBoxes(page, tplid, x, y, tplW, tplH)
+
+	tplInfo := doc.importer.tplMap[tplid]
+	tpl := tplInfo.Writer.tpls[tplInfo.TemplateId]
+	if tpl.Reader != nil && tpl.PageNo > 0 {
+		page.source, page.sourcePage = tpl.Reader, tpl.PageNo
+		page.sourceTransform = doc.templateTransform(page, tplid, x, y, tplW, tplH)
+		if userUnit {
+			page.userUnit, err = tpl.Reader.getPageUserUnit(tpl.PageNo)
+			if err != nil {
+				return err
+			}
+		}
+		if doc.carryPresentation {
+			page.presentation, err = tpl.Reader.GetPagePresentation(tpl.PageNo)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Set a page box of the last page (the crop, bleed, trim or art box) to the
+// area at x, y with size w by h, in the unit and origin of the importer
+func (doc *Document) SetPageBox(box BoxName, x float64, y float64, w float64, h float64) error {
+	if len(doc.pages) == 0 {
+		return errors.New("Document has no pages")
+	}
+
+	box, err := box.normalize()
+	if err != nil {
+		return err
+	}
+	if box == MediaBox {
+		return errors.New("The media box is the size of the page")
+	}
+	if !(w > 0) || !(h > 0) {
+		return errors.New("Box is empty")
+	}
+
+	page := doc.pages[len(doc.pages)-1]
+	k := doc.importer.options.k
+	lly := y * k
+	if doc.importer.options.origin == OriginTopLeft {
+		lly = page.h - (y+h)*k
+	}
+
+	page.boxes[box] = [4]float64{x * k, lly, (x + w) * k, lly + h*k}
+	return nil
+}
+
+// Get the mapping from the default user space of the page of template tplid
+// (in points) to a page that draws it at x, y with size w by h
+func (doc *Document) templateTransform(page *documentPage, tplid int, x float64, y float64, w float64, h float64) func(x float64, y float64) (float64, float64) {
+	tplInfo := doc.importer.tplMap[tplid]
+	tpl := tplInfo.Writer.tpls[tplInfo.TemplateId]
+
+	scaleX, scaleY, tx, ty := doc.placement(page, tplid, x, y, w, h)
+	c, s, fx, fy := tpl.formMatrix()
+	fx *= tpl.k
+	fy *= tpl.k
+
+	return func(x float64, y float64) (float64, float64) {
+		return scaleX*(c*x-s*y+fx) + tx, scaleY*(s*x+c*y+fy) + ty
+	}
+}
+
+// Put the bleed, trim and art boxes of the page of template tplid, drawn at
+// x, y with size w by h, onto a page.  The boxes are mapped through the form
+// matrix of the template, so they follow rotated pages, and are clipped to
+// the page.
+func (doc *Document) putTemplateBoxes(page *documentPage, tplid int, x float64, y float64, w float64, h float64) {
+	tplInfo := doc.importer.tplMap[tplid]
+	tpl := tplInfo.Writer.tpls[tplInfo.TemplateId]
+	transform := doc.templateTransform(page, tplid, x, y, w, h)
+
+	for _, name := range []BoxName{BleedBox, TrimBox, ArtBox} {
+		box := tpl.Boxes[name.String()]
+		if len(box) == 0 {
+			continue
+		}
+
+		x1, y1 := transform(box["llx"]*tpl.k, box["lly"]*tpl.k)
+		x2, y2 := transform(box["urx"]*tpl.k, box["ury"]*tpl.k)
+		rect := [4]float64{
+			math.Max(math.Min(x1, x2), 0),
+			math.Max(math.Min(y1, y2), 0),
+			math.Min(math.Max(x1, x2), page.w),
+			math.Min(math.Max(y1, y2), page.h),
+		}
+		if rect[2] > rect[0] && rect[3] > rect[1] {
+			page.boxes[name] = rect
+		}
+	}
+}
+
+// Draw template tplid onto the last page at x, y with size w by h, see Importer.UseTemplate
+func (doc *Document) UseTemplate(tplid int, x float64, y float64, w float64, h float64) error {
+	if len(doc.pages) == 0 {
+		return errors.New("Document has no pages")
+	}
+	if _, ok := doc.importer.tplMap[tplid]; !ok {
+		return errors.New(fmt.Sprintf("Template %d does not exist", tplid))
+	}
+
+	page := doc.pages[len(doc.pages)-1]
+	name, ok := page.names[tplid]
+	if !ok {
+		name = fmt.Sprintf("/TPL%d", len(page.tpls))
+		page.names[tplid] = name
+		page.tpls = append(page.tpls, tplid)
+	}
+
+	// Templates in another language than the document are marked with theirs
+	lang := ""
+	if doc.carryAccessibility {
+		lang = doc.templateLang(tplid)
+		if lang == doc.documentLang() {
+			lang = ""
+		}
+	}
+	if lang != "" {
+		page.content.WriteString("/Span << /Lang ")
+		writeContentValue(&page.content, encodeTextString(lang))
+		page.content.WriteString(" >> BDC\n")
+	}
+
+	scaleX, scaleY, tx, ty := doc.placement(page, tplid, x, y, w, h)
+	page.content.WriteString(fmt.Sprintf("q %.5F 0 0 %.5F %.5F %.5F cm %s Do Q\n", scaleX, scaleY, tx, ty, name))
+	if lang != "" {
+		page.content.WriteString("EMC\n")
+	}
+	return nil
+}
+
+// Get the scale and translation (in points, from the bottom left corner of
+// the page) that draw template tplid at x, y with size w by h
+func (doc *Document) placement(page *documentPage, tplid int, x float64, y float64, w float64, h float64) (float64, float64, float64, float64) {
+	_, scaleX, scaleY, tx, ty := doc.importer.UseTemplate(tplid, x, y, w, h)
+	if doc.importer.options.origin == OriginTopLeft {
+		ty += page.h
+	}
+	return scaleX, scaleY, tx, ty
+}
+
+// Write the document to a file
+func (doc *Document) WriteFile(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return errors.Wrap(err, "Failed to create file")
+	}
+
+	_, err = doc.WriteTo(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// Write the document.  The document can be written again after adding pages.
+func (doc *Document) WriteTo(w io.Writer) (int64, error) {
+	if len(doc.pages) == 0 {
+		return 0, errors.New("Document has no pages")
+	}
+	if doc.pdfx != nil {
+		err := doc.checkPDFXBoxes()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err := doc.putTemplates()
+	if err != nil {
+		return 0, err
+	}
+
+	objects := make(map[int][]byte, len(doc.backend.Objects)+2*len(doc.pages)+2)
+	for id, data := range doc.backend.Objects {
+		objects[id] = data
+	}
+
+	// Pages and their content streams follow the imported objects
+	nextId := doc.backend.NextId
+	pageIds := make([]int, len(doc.pages))
+	for i := range doc.pages {
+		pageIds[i] = nextId
+		nextId += 2
+	}
+
+	// Pages larger than the largest page size are scaled down
+	scales := make([]float64, len(doc.pages))
+	userUnits := make([]float64, len(doc.pages))
+	for i, page := range doc.pages {
+		scales[i], userUnits[i], err = doc.pageScale(page)
+		if err != nil {
+			return 0, errors.Wrap(err, fmt.Sprintf("Page %d", i+1))
+		}
+	}
+
+	// Annotations by page index, entries of the catalog and of the trailer
+	annots := make(map[int][]int, 0)
+	catalog := ""
+	trailer := ""
+	doc.pdfxWarnings = nil
+	if doc.pdfx != nil {
+		catalog, trailer, nextId, err = doc.putPDFX(objects, nextId)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if doc.carryAccessibility {
+		if lang := doc.documentLang(); lang != "" {
+			var b bytes.Buffer
+			writeContentValue(&b, encodeTextString(lang))
+			catalog += " /Lang " + b.String()
+		}
+	}
+	if doc.signature != nil {
+		var entries string
+		entries, nextId = doc.putSignatureField(objects, pageIds, scales, annots, nextId)
+		catalog += entries
+	}
+	threads, nextId, err := doc.putThreads(objects, pageIds, scales, nextId)
+	if err != nil {
+		return 0, err
+	}
+	catalog += threads
+	outlines, nextId := doc.putOutlines(objects, pageIds, nextId)
+	catalog += outlines
+	catalog += doc.pageLabelsEntry()
+	attachments, nextId := doc.putAttachments(objects, nextId)
+	catalog += attachments
+	fonts, nextId := doc.putStandardFonts(objects, nextId)
+
+	for i, page := range doc.pages {
+		pageId := pageIds[i]
+		objects[pageId], objects[pageId+1], err = doc.pageObjects(page, pageId, scales[i], userUnits[i], annots[i], fonts)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	objects[documentPagesId] = []byte(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>\nendobj\n", objectRefs(pageIds), len(pageIds)))
+	objects[documentCatalogId] = []byte(fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R%s >>\nendobj\n", documentPagesId, catalog))
+
+	version := doc.version
+	if version == "" {
+		version = negotiateVersion(objects)
+	}
+	if doc.pdfx != nil && version > "1.6" {
+		return 0, errors.New("PDF/X-4 documents are limited to PDF 1.6, the document needs " + version)
+	}
+	doc.writtenVersion = version
+
+	data := buildDocument(version, objects, nextId, trailer)
+	if doc.signature != nil {
+		doc.signaturePlaceholder, err = fillByteRange(data)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.Write(data)
+	return int64(n), err
+}
+
+// Get the data of the page object pageId of a page and of its content
+// stream, object pageId + 1, for a page scaled down by scale, see pageScale.
+// fonts are the object ids of the standard fonts of the texts of the pages.
+func (doc *Document) pageObjects(page *documentPage, pageId int, scale float64, userUnit float64, annots []int, fonts map[string]int) ([]byte, []byte, error) {
+	contentId := pageId + 1
+
+	var resources bytes.Buffer
+	for _, tplid := range page.tpls {
+		id, err := doc.templateObjectId(tplid)
+		if err != nil {
+			return nil, nil, err
+		}
+		resources.WriteString(fmt.Sprintf("%s %d 0 R ", page.names[tplid], id))
+	}
+	resources.WriteString(">>")
+
+	content := page.content.Bytes()
+	if len(page.texts) > 0 {
+		texts, names := pageTextContent(page.texts, userUnit)
+		content = append(append([]byte{}, content...), texts...)
+		resources.WriteString(" /Font << ")
+		for _, name := range names {
+			resources.WriteString(fmt.Sprintf("/%s %d 0 R ", name, fonts[name]))
+		}
+		resources.WriteString(">>")
+	}
+
+	entries := ""
+	for _, name := range boxNames {
+		if rect, ok := page.boxes[name]; ok {
+			entries += fmt.Sprintf(" %s [%.5F %.5F %.5F %.5F]", name, rect[0]/scale, rect[1]/scale, rect[2]/scale, rect[3]/scale)
+		}
+	}
+	if userUnit != 1 {
+		entries += fmt.Sprintf(" /UserUnit %.5F", userUnit)
+	}
+	if len(annots) > 0 {
+		entries += " /Annots [" + objectRefs(annots) + "]"
+	}
+	if page.presentation != nil {
+		entries += page.presentation.pageEntries()
+	}
+	if len(page.beads) > 0 {
+		entries += " /B [" + objectRefs(page.beads) + "]"
+	}
+
+	if scale != 1 {
+		content = append([]byte(fmt.Sprintf("q %.5F 0 0 %.5F 0 0 cm\n", 1/scale, 1/scale)), content...)
+		content = append(content, "Q\n"...)
+	}
+
+	pageData := []byte(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.5F %.5F] /Resources << /XObject << %s >> /Contents %d 0 R%s >>\nendobj\n", documentPagesId, page.w/scale, page.h/scale, resources.String(), contentId, entries))
+	contentData := append(pdfStream("/Filter /FlateDecode", deflate(content)), "\nendobj\n"...)
+	return pageData, contentData, nil
+}
+
+// Get references to objects, separated by spaces
+func objectRefs(ids []int) string {
+	var b bytes.Buffer
+	for _, id := range ids {
+		b.WriteString(fmt.Sprintf("%d 0 R ", id))
+	}
+	return b.String()
+}
+
+// Put the form xobjects of all sources into the backend of the document.
+// Sources are put after the sources of the parts of their composite
+// templates (see ComposeTemplates), which have to be written first.
+func (doc *Document) putTemplates() error {
+	importer := doc.importer
+
+	sources := make([]string, 0, len(importer.writers))
+	for source := range importer.writers {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	done := make(map[string]bool, len(sources))
+	var put func(source string, visiting map[string]bool) error
+	put = func(source string, visiting map[string]bool) error {
+		if done[source] {
+			return nil
+		}
+		if visiting[source] {
+			return errors.New("Composite templates of sources depend on each other")
+		}
+		visiting[source] = true
+
+		for _, tpl := range importer.writers[source].tpls {
+			for _, part := range tpl.parts {
+				partSource, ok := importer.templateSource(part.tpl)
+				if ok && partSource != source {
+					err := put(partSource, visiting)
+					if err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		err := importer.putFormXobjectsTo(source, doc.backend)
+		if err != nil {
+			return errors.Wrap(err, "Failed to put templates of "+source)
+		}
+		done[source] = true
+		return nil
+	}
+
+	for _, source := range sources {
+		err := put(source, make(map[string]bool, 0))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Get the source whose writer holds a template
+func (importer *Importer) templateSource(tpl *PdfTemplate) (string, bool) {
+	for source, writer := range importer.writers {
+		for _, t := range writer.tpls {
+			if t == tpl {
+				return source, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Get the output object id of the form xobject of a template, once written
+func (doc *Document) templateObjectId(tplid int) (int, error) {
+	tplInfo := doc.importer.tplMap[tplid]
+	tpl := tplInfo.Writer.tpls[tplInfo.TemplateId]
+	if tpl.objId != nil {
+		if id, ok := doc.importer.backendIds[tpl.objId.hash]; ok {
+			return id, nil
+		}
+	}
+	return 0, errors.New(fmt.Sprintf("Template %d has not been written", tplid))
+}
+
+// Serialize a document from its objects, with object documentCatalogId being
+// the catalog, and additional entries of the trailer.  The file identifier is
+// derived from the contents, so the same document always gets the same
+// identifier.
+func buildDocument(version string, objects map[int][]byte, size int, trailer string) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-" + version + "\n%\xe2\xe3\xcf\xd3\n")
+
+	offsets := make(map[int]int, len(objects))
+	for id := 1; id < size; id++ {
+		data, ok := objects[id]
+		if !ok {
+			continue
+		}
+		offsets[id] = b.Len()
+		b.WriteString(fmt.Sprintf("%d 0 obj\n", id))
+		b.Write(data)
+	}
+
+	digest := md5.Sum(b.Bytes())
+
+	xref := b.Len()
+	b.WriteString(xrefTable(size, func(id int) (int64, bool) {
+		offset, ok := offsets[id]
+		return int64(offset), ok
+	}))
+	b.WriteString(fmt.Sprintf("trailer\n<< /Size %d /Root %d 0 R%s /ID [<%x> <%x>] >>\nstartxref\n%d\n%%%%EOF\n", size, documentCatalogId, trailer, digest, digest, xref))
+
+	return b.Bytes()
+}
+
+// Get the cross-reference table of objects 0 to size-1, with the offsets of
+// the objects that were written.  The other objects are free and linked into
+// a list from object 0, each entry holding the number of the next free object
+// and the last one 0.
+func xrefTable(size int, offset func(id int) (int64, bool)) string {
+	nextFree := make(map[int]int, 0)
+	last := 0
+	for id := 1; id < size; id++ {
+		if _, ok := offset(id); !ok {
+			nextFree[last] = id
+			last = id
+		}
+	}
+
+	var b bytes.Buffer
+	b.WriteString(fmt.Sprintf("xref\n0 %d\n%010d 65535 f \n", size, nextFree[0]))
+	for id := 1; id < size; id++ {
+		if off, ok := offset(id); ok {
+			b.WriteString(fmt.Sprintf("%010d 00000 n \n", off))
+		} else {
+			b.WriteString(fmt.Sprintf("%010d 00000 f \n", nextFree[id]))
+		}
+	}
+	return b.String()
+}
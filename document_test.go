@@ -0,0 +1,112 @@
+package gofpdi
+
+import (
+	"bytes"
+	"crypto/md5"
+	"path/filepath"
+	"testing"
+)
+
+// Get the file identifier of the trailer of a document, and check that both
+// of its strings are the digest of the objects written before the
+// cross-reference table
+func documentId(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	reader := readTestPDF(t, data)
+	if reader.xrefRebuilt {
+		t.Fatal("cross-reference table was rebuilt")
+	}
+	ids := resolvePath(t, reader, reader.trailer, "/ID")
+	if len(ids.Array) != 2 {
+		t.Fatalf("/ID has %d strings, want 2", len(ids.Array))
+	}
+	first, second := stringBytes(ids.Array[0]), stringBytes(ids.Array[1])
+	if !bytes.Equal(first, second) {
+		t.Errorf("/ID strings %x and %x differ", first, second)
+	}
+
+	digest := md5.Sum(data[:bytes.LastIndex(data, []byte("\nxref\n"))+1])
+	if !bytes.Equal(first, digest[:]) {
+		t.Errorf("/ID is %x, want the digest of the objects %x", first, digest)
+	}
+	return first
+}
+
+// A written document is read back with its pages, and its file identifier is
+// derived from its contents
+func TestDocumentRoundTrip(t *testing.T) {
+	sortKeys := func(importer *Importer) {
+		importer.SetSortKeys(true)
+	}
+	data := importTestDocument(t, testDocument(3), sortKeys)
+	checkReferences(t, data)
+	if pages, err := readTestPDF(t, data).getNumPages(); err != nil || pages != 3 {
+		t.Fatalf("got %d pages (%v), want 3", pages, err)
+	}
+
+	id := documentId(t, data)
+	if again := documentId(t, importTestDocument(t, testDocument(3), sortKeys)); !bytes.Equal(again, id) {
+		t.Errorf("/ID of the same document is %x, want %x", again, id)
+	}
+	if other := documentId(t, importTestDocument(t, testDocument(2), sortKeys)); bytes.Equal(other, id) {
+		t.Errorf("/ID of another document is %x too", other)
+	}
+}
+
+// A document written by a pipeline is read back with the pages of all
+// sources, and its file identifier is derived from its contents
+func TestPipelineRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "a.pdf"), filepath.Join(dir, "b.pdf")}
+	writeTestFile(t, paths[0], testDocument(2))
+	writeTestFile(t, paths[1], testDocument(3))
+
+	var buf bytes.Buffer
+	if _, err := NewPipeline().Run(&buf, SourceFiles(paths...)); err != nil {
+		t.Fatal(err)
+	}
+	checkReferences(t, buf.Bytes())
+	if pages, err := readTestPDF(t, buf.Bytes()).getNumPages(); err != nil || pages != 5 {
+		t.Fatalf("got %d pages (%v), want 5", pages, err)
+	}
+	documentId(t, buf.Bytes())
+}
+
+// The free entries of the cross-reference table are linked into a list from
+// object 0
+func TestXrefFreeList(t *testing.T) {
+	offsets := map[int]int64{1: 10, 3: 30, 5: 50}
+	table := xrefTable(7, func(id int) (int64, bool) {
+		offset, ok := offsets[id]
+		return offset, ok
+	})
+	want := "xref\n0 7\n" +
+		"0000000002 65535 f \n" +
+		"0000000010 00000 n \n" +
+		"0000000004 00000 f \n" +
+		"0000000030 00000 n \n" +
+		"0000000006 00000 f \n" +
+		"0000000050 00000 n \n" +
+		"0000000000 00000 f \n"
+	if table != want {
+		t.Errorf("got table\n%s\nwant\n%s", table, want)
+	}
+
+	// A document with free objects is read without rebuilding the table
+	objects := map[int][]byte{
+		documentCatalogId: []byte("<< /Type /Catalog /Pages 2 0 R >>\nendobj\n"),
+		documentPagesId:   []byte("<< /Type /Pages /Kids [4 0 R] /Count 1 >>\nendobj\n"),
+		4:                 []byte("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /XObject << /X1 3 0 R >> >> >>\nendobj\n"),
+	}
+	data := buildDocument("1.7", objects, 6, "")
+	documentId(t, data)
+	reader := readTestPDF(t, data)
+	obj, err := reader.resolveObject(&PdfValue{Type: PDF_TYPE_OBJREF, Id: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.Value == nil || obj.Value.Type != PDF_TYPE_NULL {
+		t.Errorf("free object 3 is %v, want null", obj.Value)
+	}
+}
@@ -0,0 +1,234 @@
+package gofpdi
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// Collects the largest size (in points) at which each image xobject is drawn
+type imagePlacementHandler struct {
+	nopContentHandler
+	sizes map[int][2]float64
+}
+
+func (handler *imagePlacementHandler) drawImage(it *contentInterpreter, image *PdfValue, inline *ContentOperation) {
+	if image == nil {
+		return
+	}
+
+	// Images are drawn into the unit square
+	m := it.gs.ctm
+	w := math.Hypot(m[0], m[1])
+	h := math.Hypot(m[2], m[3])
+
+	size := handler.sizes[image.Id]
+	handler.sizes[image.Id] = [2]float64{math.Max(size[0], w), math.Max(size[1], h)}
+}
+
+// Find the placed size of the images drawn by a template
+func (tpl *PdfTemplate) imageSizes(sizes map[int][2]float64) error {
+	handler := &imagePlacementHandler{sizes: sizes}
+
+	it := newContentInterpreter(tpl.Reader, tpl.Resources, identityMatrix, handler)
+	err := it.run(tpl.Buffer)
+	if err != nil {
+		return errors.Wrap(err, "Failed to interpret content")
+	}
+
+	return nil
+}
+
+// Downsample an image stream that has a higher resolution than maxDpi at the
+// size it is drawn, re-encoding it as a JPEG.  Returns obj unchanged if the
+// image is not drawn above maxDpi or cannot be converted.
+func (pdfReader *PdfReader) downsampleImage(obj *PdfValue, size [2]float64, maxDpi float64, quality int) *PdfValue {
+	dict := obj.Value.Dictionary
+	if subtype, ok := dict["/Subtype"]; !ok || subtype.Token != "/Image" {
+		return obj
+	}
+
+	// Masks and decode arrays depend on exact sample values
+	for _, key := range []string{"/ImageMask", "/Mask", "/Decode", "/SMaskInData"} {
+		if _, ok := dict[key]; ok {
+			return obj
+		}
+	}
+
+	width := imageDictInt(dict, "/Width", "/W")
+	height := imageDictInt(dict, "/Height", "/H")
+	if width <= 0 || height <= 0 || size[0] <= 0 || size[1] <= 0 {
+		return obj
+	}
+
+	// Keep enough pixels for maxDpi in both directions
+	scale := math.Max(size[0]/72*maxDpi/float64(width), size[1]/72*maxDpi/float64(height))
+	if scale >= 1 {
+		return obj
+	}
+	newWidth := int(math.Ceil(float64(width) * scale))
+	newHeight := int(math.Ceil(float64(height) * scale))
+
+	components := pdfReader.imageComponents(dict["/ColorSpace"])
+	if components != 1 && components != 3 {
+		return obj
+	}
+
+	pixels, err := pdfReader.imagePixels(obj, width, height, components)
+	if err != nil {
+		return obj
+	}
+
+	pixels = resample(pixels, width, height, components, newWidth, newHeight)
+
+	var img image.Image
+	if components == 1 {
+		img = &image.Gray{Pix: pixels, Stride: newWidth, Rect: image.Rect(0, 0, newWidth, newHeight)}
+	} else {
+		rgba := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
+		for i := 0; i < newWidth*newHeight; i++ {
+			copy(rgba.Pix[i*4:], pixels[i*3:i*3+3])
+			rgba.Pix[i*4+3] = 255
+		}
+		img = rgba
+	}
+
+	if quality <= 0 {
+		quality = jpeg.DefaultQuality
+	}
+
+	var buf bytes.Buffer
+	err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+	if err != nil || int64(buf.Len()) >= obj.streamLength() {
+		return obj
+	}
+
+	return replaceStreamData(obj, buf.Bytes(), map[string]*PdfValue{
+		"/Width":            {Type: PDF_TYPE_NUMERIC, Int: newWidth},
+		"/Height":           {Type: PDF_TYPE_NUMERIC, Int: newHeight},
+		"/BitsPerComponent": {Type: PDF_TYPE_NUMERIC, Int: 8},
+		"/Filter":           {Type: PDF_TYPE_TOKEN, Token: "/DCTDecode"},
+	})
+}
+
+// Get the number of color components of an image color space that can be
+// stored in a JPEG unchanged (gray or RGB), or 0
+func (pdfReader *PdfReader) imageComponents(cs *PdfValue) int {
+	cs, err := pdfReader.resolveDirect(cs)
+	if err != nil || cs == nil {
+		return 0
+	}
+
+	switch cs.Token {
+	case "/DeviceGray":
+		return 1
+	case "/DeviceRGB":
+		return 3
+	}
+
+	if cs.Type == PDF_TYPE_ARRAY && len(cs.Array) == 2 && cs.Array[0].Token == "/ICCBased" {
+		profile, err := pdfReader.resolveDirect(cs.Array[1])
+		if err != nil || profile == nil || profile.Type != PDF_TYPE_STREAM {
+			return 0
+		}
+		if n, ok := profile.Value.Dictionary["/N"]; ok && (n.Int == 1 || n.Int == 3) {
+			return n.Int
+		}
+	}
+
+	return 0
+}
+
+// Decode the 8 bit samples of an image stream
+func (pdfReader *PdfReader) imagePixels(obj *PdfValue, width int, height int, components int) ([]byte, error) {
+	dict := obj.Value.Dictionary
+	if bpc := imageDictInt(dict, "/BitsPerComponent", "/BPC"); bpc != 8 {
+		return nil, errors.New("Unsupported bits per component")
+	}
+
+	filter, err := pdfReader.resolveDirect(dict["/Filter"])
+	if err != nil {
+		return nil, err
+	}
+	if filter != nil && filter.Type == PDF_TYPE_ARRAY && len(filter.Array) == 1 {
+		filter = filter.Array[0]
+	}
+
+	var pixels []byte
+	if filter != nil && filter.Token == "/DCTDecode" {
+		if components != 1 && components != 3 {
+			return nil, errors.New("Unsupported jpeg color space")
+		}
+
+		img, err := jpeg.Decode(pdfReader.streamReader(obj))
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to decode jpeg")
+		}
+
+		pixels = make([]byte, 0, width*height*components)
+		bounds := img.Bounds()
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				c := img.At(x, y)
+				if components == 1 {
+					pixels = append(pixels, color.GrayModel.Convert(c).(color.Gray).Y)
+				} else {
+					rgba := color.RGBAModel.Convert(c).(color.RGBA)
+					pixels = append(pixels, rgba.R, rgba.G, rgba.B)
+				}
+			}
+		}
+	} else {
+		if _, ok := dict["/DecodeParms"]; ok {
+			return nil, errors.New("Unsupported decode parameters")
+		}
+
+		pixels, err = pdfReader.rebuildContentStream(obj)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(pixels) < width*height*components {
+		return nil, errors.New("Image data is too short")
+	}
+
+	return pixels, nil
+}
+
+// Resize pixels by averaging the source pixels covered by each target pixel
+func resample(pixels []byte, width int, height int, components int, newWidth int, newHeight int) []byte {
+	result := make([]byte, newWidth*newHeight*components)
+	sums := make([]int, components)
+
+	for y := 0; y < newHeight; y++ {
+		y0 := y * height / newHeight
+		y1 := maxInt((y+1)*height/newHeight, y0+1)
+
+		for x := 0; x < newWidth; x++ {
+			x0 := x * width / newWidth
+			x1 := maxInt((x+1)*width/newWidth, x0+1)
+
+			for c := range sums {
+				sums[c] = 0
+			}
+			for sy := y0; sy < y1; sy++ {
+				row := pixels[(sy*width+x0)*components : (sy*width+x1)*components]
+				for i, b := range row {
+					sums[i%components] += int(b)
+				}
+			}
+
+			n := (y1 - y0) * (x1 - x0)
+			for c, sum := range sums {
+				result[(y*newWidth+x)*components+c] = byte(sum / n)
+			}
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,117 @@
+package gofpdi
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// What is imported when a page does not define the box asked for, see
+// Importer.SetBoxFallback
+type BoxFallback int
+
+const (
+	// The box the PDF specification defaults it to: the CropBox for the
+	// BleedBox, TrimBox and ArtBox, and the MediaBox for the CropBox
+	BoxFallbackDefault BoxFallback = iota
+	// Importing the page fails with a *MissingBoxError
+	BoxFallbackStrict
+	// The first box of the chain given to SetBoxFallback that the page defines
+	BoxFallbackCustom
+)
+
+// Returned when a page does not define the box asked for, nor any box it
+// falls back to
+type MissingBoxError struct {
+	Name   BoxName
+	PageNo int
+}
+
+func (err *MissingBoxError) Error() string {
+	return fmt.Sprintf("Page %d has no %s", err.PageNo, err.Name)
+}
+
+// Choose what is imported when a page does not define the box asked for,
+// see Importer.SetBoxFallback
+func (pdfWriter *PdfWriter) SetBoxFallback(policy BoxFallback, chain ...BoxName) error {
+	boxes, err := boxFallbackChain(policy, chain)
+	if err != nil {
+		return err
+	}
+	pdfWriter.box_fallback = policy
+	pdfWriter.box_chain = boxes
+	return nil
+}
+
+// Get the normalized box names of a fallback chain
+func boxFallbackChain(policy BoxFallback, chain []BoxName) ([]BoxName, error) {
+	if policy == BoxFallbackCustom && len(chain) == 0 {
+		return nil, errors.New("A custom box fallback needs a chain of boxes")
+	}
+	if policy != BoxFallbackCustom && len(chain) > 0 {
+		return nil, errors.New("Only a custom box fallback has a chain of boxes")
+	}
+
+	boxes := make([]BoxName, 0, len(chain))
+	for _, box := range chain {
+		box, err := box.normalize()
+		if err != nil {
+			return nil, err
+		}
+		boxes = append(boxes, box)
+	}
+	return boxes, nil
+}
+
+// Get the box of a page that is imported when boxName is asked for: the box
+// itself if the page defines it, else the one it falls back to
+func (pdfWriter *PdfWriter) fallbackBox(pageBoxes map[string]map[string]float64, pageno int, boxName BoxName) (BoxName, error) {
+	chain := []BoxName{boxName}
+	switch pdfWriter.box_fallback {
+	case BoxFallbackDefault:
+		switch boxName {
+		case BleedBox, TrimBox, ArtBox:
+			chain = append(chain, CropBox, MediaBox)
+		case CropBox:
+			chain = append(chain, MediaBox)
+		}
+	case BoxFallbackCustom:
+		chain = append(chain, pdfWriter.box_chain...)
+	}
+
+	// Boxes a page does not define are empty; the MediaBox always exists
+	for _, box := range chain {
+		if len(pageBoxes[box.String()]) > 0 {
+			return box, nil
+		}
+	}
+	return "", &MissingBoxError{Name: boxName, PageNo: pageno}
+}
+
+// Choose what is imported when a page does not define the box asked for:
+// with BoxFallbackDefault the box the PDF specification defaults it to (a
+// missing TrimBox is the CropBox, or the MediaBox if that is missing too),
+// with BoxFallbackStrict nothing, so ImportPage returns a *MissingBoxError,
+// and with BoxFallbackCustom the first box of chain the page defines, e.g.
+// BleedBox then MediaBox.  The box that was imported is the Box of the
+// template's GetTemplateInfo.
+func (importer *Importer) SetBoxFallback(policy BoxFallback, chain ...BoxName) error {
+	boxes, err := boxFallbackChain(policy, chain)
+	if err != nil {
+		return err
+	}
+	importer.setWriterOptions(func(options *writerOptions) {
+		options.box_fallback = policy
+		options.box_chain = boxes
+	})
+	return nil
+}
+
+// Get the source, writer and imported box of template tplid
+func (importer *Importer) GetTemplateInfo(tplid int) (TplInfo, error) {
+	tplInfo, ok := importer.tplMap[tplid]
+	if !ok {
+		return TplInfo{}, errors.New(fmt.Sprintf("Template %d does not exist", tplid))
+	}
+	return *tplInfo, nil
+}
@@ -0,0 +1,219 @@
+package gofpdi
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// A backend added with AddOutputBackend and the ids of the objects put into
+// it, by hash
+type outputTarget struct {
+	backend OutputBackend
+	ids     map[string]int
+}
+
+// A written object and the writer that holds its data
+type writtenObject struct {
+	writer   *PdfWriter
+	pdfObjId *PdfObjectId
+}
+
+// Add a destination document that templates are put into, e.g. a PDF per
+// customer next to an archive of all of them.  Each backend added numbers the
+// objects of its own: PutFormXobjectsTo, PutFormXobjectsToAll and
+// PutTemplatesTo write every object once into it, however many other backends
+// have it.  Sources are read and their objects serialized once; the objects
+// are only renumbered for each backend.  Backends must be comparable, e.g.
+// pointers.  Object ids of added backends are not part of checkpoints.
+func (importer *Importer) AddOutputBackend(backend OutputBackend) {
+	for _, target := range importer.outputs {
+		if target.backend == backend {
+			return
+		}
+	}
+	importer.outputs = append(importer.outputs, &outputTarget{backend: backend, ids: make(map[string]int, 0)})
+}
+
+// Get the backend added with AddOutputBackend that is backend, or nil
+func (importer *Importer) outputTarget(backend OutputBackend) *outputTarget {
+	for _, target := range importer.outputs {
+		if target.backend == backend {
+			return target
+		}
+	}
+	return nil
+}
+
+// Get the ids of the objects put into backend: its own if it was added with
+// AddOutputBackend, the ones of the backends that were not otherwise
+func (importer *Importer) outputIds(backend OutputBackend) map[string]int {
+	if target := importer.outputTarget(backend); target != nil {
+		return target.ids
+	}
+	return importer.backendIds
+}
+
+// Put form xobjects of the current source into every backend added with
+// AddOutputBackend.  Objects already written into a backend are not written
+// into it again.
+func (importer *Importer) PutFormXobjectsToAll() error {
+	if len(importer.outputs) == 0 {
+		return errors.New("No output backends have been added")
+	}
+	return importer.putFormXobjectsToTargets(importer.sourceFile, importer.outputs)
+}
+
+// Put form xobjects of a source into backends added with AddOutputBackend.
+// The writer of the source keeps the objects with placeholders, which are
+// replaced by the ids of each backend when they are copied into it.
+func (importer *Importer) putFormXobjectsToTargets(source string, targets []*outputTarget) error {
+	writer := importer.GetWriterForFile(source)
+	writer.SetUseHash(true)
+
+	tplNamesIds, err := writer.PutFormXobjects(importer.GetReaderForFile(source))
+	if err != nil {
+		return errors.Wrap(err, "Failed to put form xobjects")
+	}
+
+	objs := writer.writtenObjects()
+	for _, target := range targets {
+		err = copyObjects(target.backend, target.ids, objs, tplNamesIds)
+		if err != nil {
+			return err
+		}
+	}
+
+	return importer.autoCloseSource(source)
+}
+
+// Put the form xobjects of templates ids (returned from ImportPage), of any
+// source, and the objects they refer to into backend, e.g. only the pages of
+// one customer into their document while an archive added with
+// AddOutputBackend gets all of them.  Objects already written into backend
+// are not written into it again; templates already written for another
+// backend are not written again by the sources.
+func (importer *Importer) PutTemplatesTo(backend OutputBackend, ids []int) error {
+	for _, tplid := range ids {
+		tplInfo, ok := importer.tplMap[tplid]
+		if !ok {
+			return errors.New(fmt.Sprintf("Template %d does not exist", tplid))
+		}
+		tplInfo.Writer.SetUseHash(true)
+	}
+
+	_, err := importer.PutFormXobjectsOnly(ids)
+	if err != nil {
+		return err
+	}
+
+	// Objects by hash, of all sources, since shared streams are written by
+	// the writer of the source that used them first
+	sources := make([]string, 0, len(importer.writers))
+	for source := range importer.writers {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	byHash := make(map[string]writtenObject, 0)
+	order := make(map[*PdfWriter]int, len(sources))
+	for i, source := range sources {
+		writer := importer.writers[source]
+		order[writer] = i
+		for _, obj := range writer.writtenObjects() {
+			byHash[obj.pdfObjId.hash] = obj
+		}
+	}
+
+	// Objects reachable from the form xobjects
+	reachable := make(map[string]bool, 0)
+	objs := make([]writtenObject, 0)
+	pending := make([]string, 0, len(ids))
+	tplNamesIds := make(map[string]*PdfObjectId, len(ids))
+	for _, tplid := range ids {
+		tplInfo := importer.tplMap[tplid]
+		pdfObjId := tplInfo.Writer.tpls[tplInfo.TemplateId].objId
+		if pdfObjId == nil {
+			// Skipped, see SetSkipUnusedTemplates
+			continue
+		}
+		tplNamesIds[tplInfo.Writer.templateName(tplInfo.TemplateId)] = pdfObjId
+		pending = append(pending, pdfObjId.hash)
+	}
+	for len(pending) > 0 {
+		hash := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		obj, ok := byHash[hash]
+		if !ok || reachable[hash] {
+			// Objects not written by the writers, e.g. of a checkpoint, have
+			// been put into the backend before
+			continue
+		}
+		reachable[hash] = true
+		objs = append(objs, obj)
+		for _, ref := range obj.writer.written_obj_pos[obj.pdfObjId] {
+			pending = append(pending, ref)
+		}
+	}
+
+	// Allocate output ids in the order the writers created the objects
+	sort.Slice(objs, func(i, j int) bool {
+		if objs[i].writer != objs[j].writer {
+			return order[objs[i].writer] < order[objs[j].writer]
+		}
+		return objs[i].pdfObjId.id < objs[j].pdfObjId.id
+	})
+
+	return copyObjects(backend, importer.outputIds(backend), objs, tplNamesIds)
+}
+
+// Get the written objects, in the order they were created
+func (pdfWriter *PdfWriter) writtenObjects() []writtenObject {
+	objs := make([]writtenObject, 0, len(pdfWriter.written_objs))
+	for pdfObjId := range pdfWriter.written_objs {
+		objs = append(objs, writtenObject{writer: pdfWriter, pdfObjId: pdfObjId})
+	}
+	sort.Slice(objs, func(i, j int) bool { return objs[i].pdfObjId.id < objs[j].pdfObjId.id })
+	return objs
+}
+
+// Write the objects that backend does not have yet into it, with ids
+// allocated in the order of objs and recorded in ids, and map the templates
+// to their form xobjects
+func copyObjects(backend OutputBackend, ids map[string]int, objs []writtenObject, tplNamesIds map[string]*PdfObjectId) error {
+	pending := make([]writtenObject, 0, len(objs))
+	for _, obj := range objs {
+		if _, ok := ids[obj.pdfObjId.hash]; !ok {
+			pending = append(pending, obj)
+		}
+	}
+
+	for _, obj := range pending {
+		ids[obj.pdfObjId.hash] = backend.AllocateObjectID()
+	}
+
+	for _, obj := range pending {
+		data, err := obj.writer.objectData(obj.pdfObjId)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("Failed to read object %d", obj.pdfObjId.id))
+		}
+
+		data, err = replaceObjHashes(data, obj.writer.written_obj_pos[obj.pdfObjId], ids)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("Failed to resolve references of object %d", obj.pdfObjId.id))
+		}
+
+		err = backend.WriteObject(ids[obj.pdfObjId.hash], data)
+		if err != nil {
+			return errors.Wrap(err, "Failed to write object")
+		}
+	}
+
+	for tplName, pdfObjId := range tplNamesIds {
+		backend.MapPlaceholder(tplName, ids[pdfObjId.hash])
+	}
+
+	return nil
+}
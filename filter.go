@@ -0,0 +1,121 @@
+package gofpdi
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Returned by an ObjectFilter to leave an object out of the output.  The object
+// is written as null, so references to it stay valid.
+var ErrSkipObject = errors.New("Skip object")
+
+// Called for each object copied from a source, before it is written.  obj is
+// the direct value of the object: a stream, or a dictionary, array etc.  The
+// filter returns the value to write instead, obj itself to write it unchanged,
+// or ErrSkipObject.  Any other error stops the import.
+//
+// obj belongs to the reader, which may use it again, so it must not be
+// changed; change a copy (see PdfValue.Copy) and return that instead.
+// References in the returned value are copied like those of obj.
+type ObjectFilter func(ref ObjectRef, obj *PdfValue) (*PdfValue, error)
+
+// Set a filter that is called for each object the writer copies, see ObjectFilter
+func (pdfWriter *PdfWriter) SetObjectFilter(filter ObjectFilter) {
+	pdfWriter.object_filter = filter
+}
+
+// Call the object filter, if any, on an object about to be written
+func (pdfWriter *PdfWriter) filterObject(v *PdfValue, obj *PdfValue) (*PdfValue, error) {
+	if pdfWriter.object_filter == nil {
+		return obj, nil
+	}
+
+	filtered, err := pdfWriter.object_filter(ObjectRef{Id: v.Id, Gen: v.Gen}, obj)
+	if errors.Cause(err) == ErrSkipObject {
+		return &PdfValue{Type: PDF_TYPE_NULL}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if filtered == nil {
+		return nil, errors.New("Object filter returned no object")
+	}
+
+	return filtered, nil
+}
+
+// Get a copy of a value that can be changed without changing the original.
+// Dictionaries and arrays are copied; the data of streams is shared, so it
+// must be replaced rather than changed.
+func (value *PdfValue) Copy() *PdfValue {
+	if value == nil {
+		return nil
+	}
+
+	c := *value
+	if value.Dictionary != nil {
+		c.Dictionary = make(map[string]*PdfValue, len(value.Dictionary))
+		for k, v := range value.Dictionary {
+			c.Dictionary[k] = v.Copy()
+		}
+	}
+	if value.Array != nil {
+		c.Array = make([]*PdfValue, len(value.Array))
+		for i, v := range value.Array {
+			c.Array[i] = v.Copy()
+		}
+	}
+	c.Value = value.Value.Copy()
+	if value.Stream != nil {
+		stream := *value.Stream
+		c.Stream = &stream
+	}
+
+	return &c
+}
+
+// A page of a source, see ContentFilter
+type PageRef struct {
+	Source string
+	PageNo int
+}
+
+// Called for each operation of the content of a page as it is imported, e.g.
+// to remove the text or the Do of a logo.  The filter returns the operations
+// to write instead: op itself to keep it, none to drop it, or others.  An
+// error stops the import.  Only the content of the page is filtered, not that
+// of the form xobjects it draws (see ObjectFilter for those).
+//
+// The filter may be called from several goroutines at once by ImportPages.
+// Operations that are dropped must keep q ... Q, BT ... ET and marked content
+// balanced; if they drop all uses of a resource, SetPruneResources leaves it
+// out.
+type ContentFilter func(page PageRef, op *ContentOperation) ([]*ContentOperation, error)
+
+// Set a filter that is called for each operation of the pages the writer
+// imports, see ContentFilter
+func (pdfWriter *PdfWriter) SetContentFilter(filter ContentFilter) {
+	pdfWriter.content_filter = filter
+}
+
+// Call the content filter, if any, on the content of page pageno
+func (pdfWriter *PdfWriter) filterContent(pageno int, content []byte) ([]byte, error) {
+	if pdfWriter.content_filter == nil {
+		return content, nil
+	}
+
+	ops, err := ParseContentStream(content)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to parse content")
+	}
+
+	filtered := make([]*ContentOperation, 0, len(ops))
+	for _, op := range ops {
+		res, err := pdfWriter.content_filter(PageRef{PageNo: pageno}, op)
+		if err != nil {
+			return nil, err
+		}
+		filtered = append(filtered, res...)
+	}
+
+	return WriteContentStream(filtered), nil
+}
@@ -0,0 +1,128 @@
+package gofpdi
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// Get the /ID pair of the trailer: the permanent identifier of the document
+// and the one of its current version.  ok is false if the trailer has no
+// valid /ID.
+func (pdfReader *PdfReader) GetDocumentID() (permanent []byte, changing []byte, ok bool) {
+	if pdfReader.trailer == nil {
+		return nil, nil, false
+	}
+	id, err := pdfReader.resolveDirect(pdfReader.trailer.Dictionary["/ID"])
+	if err != nil || id == nil || id.Type != PDF_TYPE_ARRAY || len(id.Array) != 2 {
+		return nil, nil, false
+	}
+	for _, v := range id.Array {
+		if v == nil || (v.Type != PDF_TYPE_STRING && v.Type != PDF_TYPE_HEX) {
+			return nil, nil, false
+		}
+	}
+	return stringBytes(id.Array[0]), stringBytes(id.Array[1]), true
+}
+
+// Get the hex SHA-1 of the data of the source, which identifies its content
+// whatever file name or stream it was opened from.  The source is read once,
+// when the fingerprint is first needed; if that fails, the error is returned
+// from then on.
+func (pdfReader *PdfReader) Fingerprint() (string, error) {
+	pdfReader.parseMu.Lock()
+	defer pdfReader.parseMu.Unlock()
+
+	if pdfReader.fingerprint != "" || pdfReader.fingerprintErr != nil {
+		return pdfReader.fingerprint, pdfReader.fingerprintErr
+	}
+
+	hasher := sha1.New()
+	if ra, ok := pdfReader.f.(io.ReaderAt); ok {
+		_, err := io.Copy(hasher, io.NewSectionReader(ra, 0, pdfReader.nBytes))
+		if err != nil {
+			pdfReader.fingerprintErr = errors.Wrap(err, "Failed to read source")
+			return "", pdfReader.fingerprintErr
+		}
+	} else {
+		pos, err := pdfReader.f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			pdfReader.fingerprintErr = errors.Wrap(err, "Failed to seek source")
+			return "", pdfReader.fingerprintErr
+		}
+		_, err = pdfReader.f.Seek(0, io.SeekStart)
+		if err == nil {
+			_, err = io.CopyN(hasher, pdfReader.f, pdfReader.nBytes)
+		}
+		_, seekErr := pdfReader.f.Seek(pos, io.SeekStart)
+		if err != nil {
+			pdfReader.fingerprintErr = errors.Wrap(err, "Failed to read source")
+			return "", pdfReader.fingerprintErr
+		}
+		if seekErr != nil {
+			pdfReader.fingerprintErr = errors.Wrap(seekErr, "Failed to seek source")
+			return "", pdfReader.fingerprintErr
+		}
+	}
+
+	pdfReader.fingerprint = hex.EncodeToString(hasher.Sum(nil))
+	return pdfReader.fingerprint, nil
+}
+
+// Get what the hashes of the objects written of the source are computed
+// from: its fingerprint, or its name if the source cannot be read
+func (pdfReader *PdfReader) hashSource() string {
+	if pdfReader == nil {
+		return ""
+	}
+	fingerprint, err := pdfReader.Fingerprint()
+	if err != nil {
+		pdfReader.warnings.add(fmt.Sprintf("Fingerprint of %s could not be computed, using its name: %s", pdfReader.sourceFile, err))
+		return pdfReader.sourceFile + pdfReader.hashSalt
+	}
+	return fingerprint + pdfReader.hashSalt
+}
+
+// Get the fingerprint of the current source, see PdfReader.Fingerprint.
+// Sources with the same fingerprint are one source to the importer: setting
+// a source identical to one that was set before, e.g. the same file under
+// another path or read into memory, imports from the first one, so its pages
+// and objects are not written twice.
+func (importer *Importer) GetFingerprint() (string, error) {
+	return importer.GetReader().Fingerprint()
+}
+
+// Get the /ID pair of the trailer of the current source, see
+// PdfReader.GetDocumentID
+func (importer *Importer) GetDocumentID() ([]byte, []byte, bool) {
+	return importer.GetReader().GetDocumentID()
+}
+
+// Get the name of the source that source is identical to, source itself if
+// it is not an alias of another one
+func (importer *Importer) canonicalSource(source string) string {
+	if canonical, ok := importer.sourceAliases[source]; ok {
+		return canonical
+	}
+	return source
+}
+
+// Make source, whose reader has just been opened, an alias of the source set
+// before that has the same content, if there is one and it can still be read
+func (importer *Importer) aliasSource(source string, reader *PdfReader) bool {
+	fingerprint := reader.hashSource()
+	for other, r := range importer.readers {
+		if other == source || importer.writers[other] == nil || r.hashSource() != fingerprint {
+			continue
+		}
+		if _, closed := r.f.(closedSource); closed {
+			continue
+		}
+		importer.sourceAliases[source] = other
+		return true
+	}
+	return false
+}
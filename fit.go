@@ -0,0 +1,47 @@
+package gofpdi
+
+import (
+	"math"
+)
+
+// How a template is scaled to fit a box, see FitTemplate
+type FitMode int
+
+const (
+	// Scale to the largest size that fits within the box, keeping the aspect ratio
+	FitContain FitMode = iota
+	// Scale to the smallest size that covers the box, keeping the aspect ratio
+	FitCover
+	// Scale to the size of the box, ignoring the aspect ratio
+	FitStretch
+)
+
+// Get the placement of template tplid (returned from ImportPage) in a box of
+// maxW by maxH: the offset of the template from the top left corner of the box,
+// centering it, and its size.  Add the offset to the position of the box and
+// pass the results to UseTemplate.  With FitCover the template extends beyond
+// the box, which ClipTemplate can cut off.
+func (importer *Importer) FitTemplate(tplid int, maxW float64, maxH float64, mode FitMode) (float64, float64, float64, float64, error) {
+	tpl, err := importer.getTemplate(tplid)
+	if err != nil {
+		return 0, 0, 0, 0, err
+	}
+
+	w, h := fitSize(tpl.W, tpl.H, maxW, maxH, mode)
+
+	return (maxW - w) / 2, (maxH - h) / 2, w, h, nil
+}
+
+// Get the size of a w by h rectangle scaled to a maxW by maxH box
+func fitSize(w float64, h float64, maxW float64, maxH float64, mode FitMode) (float64, float64) {
+	if mode == FitStretch || w <= 0 || h <= 0 {
+		return maxW, maxH
+	}
+
+	scale := math.Min(maxW/w, maxH/h)
+	if mode == FitCover {
+		scale = math.Max(maxW/w, maxH/h)
+	}
+
+	return w * scale, h * scale
+}
@@ -0,0 +1,218 @@
+package gofpdi
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// Prefix of the names of the appearances of flattened annotations in the
+// resources of templates
+const flattenedAnnotationName = "/GOFPDIAnnot"
+
+// Annotation flags of annotations that are not shown: Hidden (bit 2) and
+// NoView (bit 6)
+const hiddenAnnotationFlags = 1<<1 | 1<<5
+
+// Draw the normal appearance (/AP /N) of each annotation of imported pages
+// into the template of the page, see Importer.SetFlattenAnnotations
+func (pdfWriter *PdfWriter) SetFlattenAnnotations(b bool) {
+	pdfWriter.flatten_annotations = b
+}
+
+// Append the normal appearances of the annotations of the page of tpl to its
+// content, each drawn at the /Rect of its annotation, and add them to the
+// resources of the template as form xobjects.  Annotations that are hidden,
+// popups and annotations without an appearance are left out.
+func (pdfWriter *PdfWriter) flattenAnnotations(tpl *PdfTemplate) error {
+	reader := tpl.Reader
+	page, err := reader.getPage(tpl.PageNo)
+	if err != nil {
+		return err
+	}
+
+	annots, err := reader.resolveDirect(page.Value.Dictionary["/Annots"])
+	if err != nil {
+		return errors.Wrap(err, "Failed to resolve /Annots")
+	}
+	if annots == nil || annots.Type != PDF_TYPE_ARRAY {
+		return nil
+	}
+
+	resources, err := reader.resolveDirect(tpl.Resources)
+	if err != nil {
+		return errors.Wrap(err, "Failed to resolve resources")
+	}
+	xobjects := make(map[string]*PdfValue, 0)
+	if resources != nil && resources.Type == PDF_TYPE_DICTIONARY {
+		existing, err := reader.resolveDirect(resources.Dictionary["/XObject"])
+		if err != nil {
+			return errors.Wrap(err, "Failed to resolve /XObject")
+		}
+		if existing != nil && existing.Type == PDF_TYPE_DICTIONARY {
+			for name, xobj := range existing.Dictionary {
+				xobjects[name] = xobj
+			}
+		}
+	}
+
+	var content bytes.Buffer
+	n := 0
+	for _, ref := range annots.Array {
+		annot, err := reader.resolveDirect(ref)
+		if err != nil {
+			return errors.Wrap(err, "Failed to resolve annotation")
+		}
+		if annot == nil || annot.Type != PDF_TYPE_DICTIONARY {
+			continue
+		}
+
+		appearance, m, err := reader.annotationAppearance(annot)
+		if err != nil {
+			return err
+		}
+		if appearance == nil {
+			continue
+		}
+
+		var name string
+		for {
+			n++
+			name = fmt.Sprintf("%s%d", flattenedAnnotationName, n)
+			if _, ok := xobjects[name]; !ok {
+				break
+			}
+		}
+		xobjects[name] = appearance
+
+		content.WriteString(fmt.Sprintf("q %.5F 0 0 %.5F %.5F %.5F cm %s Do Q\n", m[0], m[3], m[4], m[5], name))
+	}
+	if content.Len() == 0 {
+		return nil
+	}
+
+	// The resources of the page are cached by the reader, so they are copied
+	dict := make(map[string]*PdfValue, 0)
+	if resources != nil && resources.Type == PDF_TYPE_DICTIONARY {
+		for k, v := range resources.Dictionary {
+			dict[k] = v
+		}
+	}
+	dict["/XObject"] = &PdfValue{Type: PDF_TYPE_DICTIONARY, Dictionary: xobjects}
+	tpl.Resources = &PdfValue{Type: PDF_TYPE_DICTIONARY, Dictionary: dict}
+
+	// The page content may leave the graphics state changed
+	buf := make([]byte, 0, len(tpl.Buffer)+content.Len()+6)
+	buf = append(buf, "q\n"...)
+	buf = append(buf, tpl.Buffer...)
+	buf = append(buf, "\nQ\n"...)
+	tpl.Buffer = append(buf, content.Bytes()...)
+	return nil
+}
+
+// Get a reference to the normal appearance of an annotation, and the matrix
+// that maps its bounding box (transformed by its /Matrix) to the /Rect of the
+// annotation.  The reference is nil for annotations that are not drawn.
+func (pdfReader *PdfReader) annotationAppearance(annot *PdfValue) (*PdfValue, matrix, error) {
+	dict := annot.Dictionary
+
+	if subtype, ok := dict["/Subtype"]; ok && subtype.Token == "/Popup" {
+		return nil, identityMatrix, nil
+	}
+	if flags, err := pdfReader.resolveDirect(dict["/F"]); err == nil && flags != nil && flags.Int&hiddenAnnotationFlags != 0 {
+		return nil, identityMatrix, nil
+	}
+
+	ap, err := pdfReader.resolveDirect(dict["/AP"])
+	if err != nil {
+		return nil, identityMatrix, errors.Wrap(err, "Failed to resolve /AP")
+	}
+	if ap == nil || ap.Type != PDF_TYPE_DICTIONARY {
+		return nil, identityMatrix, nil
+	}
+
+	// The normal appearance, or the one for the state of the annotation
+	// (/AS), e.g. of a check box
+	ref := ap.Dictionary["/N"]
+	normal, err := pdfReader.resolveDirect(ref)
+	if err != nil {
+		return nil, identityMatrix, errors.Wrap(err, "Failed to resolve normal appearance")
+	}
+	if normal != nil && normal.Type == PDF_TYPE_DICTIONARY {
+		state, err := pdfReader.resolveDirect(dict["/AS"])
+		if err != nil || state == nil || state.Type != PDF_TYPE_TOKEN {
+			return nil, identityMatrix, nil
+		}
+		ref = normal.Dictionary[state.Token]
+		normal, err = pdfReader.resolveDirect(ref)
+		if err != nil {
+			return nil, identityMatrix, errors.Wrap(err, "Failed to resolve normal appearance")
+		}
+	}
+	if normal == nil || normal.Type != PDF_TYPE_STREAM || ref.Type != PDF_TYPE_OBJREF {
+		return nil, identityMatrix, nil
+	}
+
+	rect, ok := pdfReader.annotationNumbers(dict["/Rect"], 4)
+	if !ok {
+		return nil, identityMatrix, nil
+	}
+	bbox, ok := pdfReader.annotationNumbers(normal.Value.Dictionary["/BBox"], 4)
+	if !ok {
+		return nil, identityMatrix, nil
+	}
+	form := identityMatrix
+	if values, ok := pdfReader.annotationNumbers(normal.Value.Dictionary["/Matrix"], 6); ok {
+		copy(form[:], values)
+	}
+
+	// The bounding box of the appearance in the space of the annotation
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, corner := range [][2]float64{{bbox[0], bbox[1]}, {bbox[2], bbox[1]}, {bbox[0], bbox[3]}, {bbox[2], bbox[3]}} {
+		p := form.transform(corner[0], corner[1])
+		minX, minY = math.Min(minX, p.x), math.Min(minY, p.y)
+		maxX, maxY = math.Max(maxX, p.x), math.Max(maxY, p.y)
+	}
+	llx, lly := math.Min(rect[0], rect[2]), math.Min(rect[1], rect[3])
+	urx, ury := math.Max(rect[0], rect[2]), math.Max(rect[1], rect[3])
+	if maxX <= minX || maxY <= minY || urx <= llx || ury <= lly {
+		return nil, identityMatrix, nil
+	}
+
+	sx := (urx - llx) / (maxX - minX)
+	sy := (ury - lly) / (maxY - minY)
+	return ref, matrix{sx, 0, 0, sy, llx - minX*sx, lly - minY*sy}, nil
+}
+
+// Get the numbers of an array of at least n numbers, e.g. a rectangle
+func (pdfReader *PdfReader) annotationNumbers(value *PdfValue, n int) ([]float64, bool) {
+	array, err := pdfReader.resolveDirect(value)
+	if err != nil || array == nil || array.Type != PDF_TYPE_ARRAY || len(array.Array) < n {
+		return nil, false
+	}
+
+	numbers := make([]float64, n)
+	for i := range numbers {
+		v, err := pdfReader.resolveDirect(array.Array[i])
+		if err != nil || v == nil || (v.Type != PDF_TYPE_NUMERIC && v.Type != PDF_TYPE_REAL) {
+			return nil, false
+		}
+		numbers[i] = v.Real
+	}
+	return numbers, true
+}
+
+// Draw the normal appearance of each annotation of the imported pages, such
+// as stamps, the looks of filled-in form fields and the appearances of
+// signatures, into the template of the page at the /Rect of the annotation.
+// Templates do not carry annotations, so without flattening they are not in
+// the output.  Hidden annotations and popups are left out.  Applies to pages
+// imported after the call.
+func (importer *Importer) SetFlattenAnnotations(b bool) {
+	importer.setWriterOptions(func(options *writerOptions) {
+		options.flatten_annotations = b
+	})
+}
@@ -0,0 +1,145 @@
+package gofpdi
+
+import (
+	"os"
+	"testing"
+)
+
+// Type3 fonts are copied with their glyph procedures and resources, and
+// with their /FontMatrix written exactly
+func TestType3Fonts(t *testing.T) {
+	data, err := os.ReadFile("testdata/type3.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := importTestDocument(t, data, nil)
+	checkReferences(t, output)
+	reader, form := outputForm(t, output, 1)
+
+	font := resolvePath(t, reader, form, "/Resources", "/Font", "/T1")
+	if got := testNumbers(t, reader, resolvePath(t, reader, font, "/FontMatrix")); got != "[0.00048828125 0 0 0.00048828125 0 0]" {
+		t.Errorf("/FontMatrix is %s", got)
+	}
+	for name, want := range map[string]string{
+		"/square": "2048 0 0 0 2048 2048 d1 0 0 2048 2048 re f",
+		"/image":  "2048 0 d0 q 2048 0 0 2048 0 0 cm /Im1 Do Q",
+	} {
+		proc := resolvePath(t, reader, font, "/CharProcs", name)
+		if got := string(decodedStream(t, reader, proc)); got != want {
+			t.Errorf("glyph %s is %q, want %q", name, got, want)
+		}
+	}
+	image := resolvePath(t, reader, font, "/Resources", "/XObject", "/Im1")
+	if got := string(decodedStream(t, reader, image)); got != "\xff\x00\x00\x00\x00\xff" {
+		t.Errorf("image of the glyphs is %q", got)
+	}
+
+	// The font without resources gets those of the imported page
+	font = resolvePath(t, reader, form, "/Resources", "/Font", "/T2")
+	dot := resolvePath(t, reader, font, "/Resources", "/XObject", "/Dot")
+	if got := string(decodedStream(t, reader, dot)); got != "0 0 1 1 re f" {
+		t.Errorf("/Dot of the font without resources is %q", got)
+	}
+}
+
+// A page with a composite font whose every component is an object of its
+// own: descendant font, font descriptor, font file, CIDToGIDMap, embedded
+// CMap and ToUnicode
+func compositeFontDocument() []byte {
+	cmap := "/CIDInit /ProcSet findresource begin 12 dict begin begincmap /CMapName /Test-H def " +
+		"1 begincodespacerange <0000> <FFFF> endcodespacerange 1 begincidrange <0000> <FFFF> 0 endcidrange " +
+		"endcmap CMapName currentdict /CMap defineresource pop end end"
+	toUnicode := "/CIDInit /ProcSet findresource begin 12 dict begin begincmap 1 begincodespacerange <0000> <FFFF> endcodespacerange " +
+		"2 beginbfchar <0001> <65E5> <0002> <672C> endbfchar endcmap CMapName currentdict /CMap defineresource pop end end"
+
+	return buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 100] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+		testStream("", "BT /F1 24 Tf 10 40 Td <00010002> Tj ET"),
+		"<< /Type /Font /Subtype /Type0 /BaseFont /ABCDEF+TestSans /Encoding 6 0 R /DescendantFonts [7 0 R] /ToUnicode 11 0 R >>",
+		testStream("/Type /CMap /CMapName /Test-H /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >>", cmap),
+		"<< /Type /Font /Subtype /CIDFontType2 /BaseFont /ABCDEF+TestSans /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> "+
+			"/FontDescriptor 8 0 R /CIDToGIDMap 10 0 R /DW 1000 /W [1 [500 600]] >>",
+		"<< /Type /FontDescriptor /FontName /ABCDEF+TestSans /Flags 4 /FontBBox [0 0 1000 1000] /ItalicAngle 0 /Ascent 800 /Descent -200 "+
+			"/CapHeight 700 /StemV 80 /FontFile2 9 0 R >>",
+		testStream("/Length1 8", "\x00\x01\x00\x00\x00\x0c\x00\x80"),
+		testStream("", "\x00\x00\x00\x03\x00\x07"),
+		testStream("", toUnicode),
+	)
+}
+
+// Every component of a composite font is copied and referenced, whether
+// objects are renumbered (the default) or keep their numbers
+func TestCompositeFonts(t *testing.T) {
+	source := readTestPDF(t, compositeFontDocument())
+	streams := map[string]int{
+		"/Encoding":    6,
+		"/ToUnicode":   11,
+		"/FontFile2":   9,
+		"/CIDToGIDMap": 10,
+	}
+
+	for _, test := range []struct {
+		name      string
+		configure func(importer *Importer)
+	}{
+		{"renumbered", nil},
+		{"numbers preserved", func(importer *Importer) {
+			importer.SetRenumbering(RenumberPreserve)
+		}},
+		{"merged font files", func(importer *Importer) {
+			importer.SetMergeFontFiles(true)
+		}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			output := importTestDocument(t, compositeFontDocument(), test.configure)
+			checkReferences(t, output)
+			reader, form := outputForm(t, output, 1)
+
+			font := resolvePath(t, reader, form, "/Resources", "/Font", "/F1")
+			descendants := resolvePath(t, reader, font, "/DescendantFonts")
+			if len(descendants.Array) != 1 {
+				t.Fatalf("/DescendantFonts is %v", descendants)
+			}
+			cidFont, err := reader.Resolve(descendants.Array[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := cidFont.Dictionary["/Subtype"].Token; got != "/CIDFontType2" {
+				t.Errorf("descendant font is %s", got)
+			}
+			if got := resolvePath(t, reader, cidFont, "/W").Array; len(got) != 2 || len(got[1].Array) != 2 {
+				t.Errorf("/W is %v", got)
+			}
+			descriptor := resolvePath(t, reader, cidFont, "/FontDescriptor")
+
+			for key, container := range map[string]*PdfValue{
+				"/Encoding":    font,
+				"/ToUnicode":   font,
+				"/CIDToGIDMap": cidFont,
+				"/FontFile2":   descriptor,
+			} {
+				want, err := source.StreamData(mustObject(t, source, streams[key]))
+				if err != nil {
+					t.Fatal(err)
+				}
+				got := decodedStream(t, reader, resolvePath(t, reader, container, key))
+				if string(got) != string(want) {
+					t.Errorf("%s is %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func mustObject(t testing.TB, reader *PdfReader, id int) *PdfValue {
+	t.Helper()
+
+	obj, err := reader.GetObject(id, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return obj
+}
@@ -0,0 +1,304 @@
+package gofpdi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// A font file embedded for fonts that a source does not embed, see
+// Importer.SetFontSubstitutes
+type fontSubstitute struct {
+	data []byte
+	// Subtype of the font dictionary, and key and subtype of the font file
+	// in the descriptor
+	subtype     string
+	fileKey     string
+	fileSubtype string
+	// /Length1, /Length2 and /Length3 of the font file
+	lengths []int
+	// Metrics for the descriptor, in glyph space units of 1/1000
+	bbox        [4]float64
+	italicAngle float64
+	ascent      float64
+	descent     float64
+	capHeight   float64
+}
+
+// Parse a TrueType, OpenType or Type 1 (.pfb) font file
+func parseFontSubstitute(data []byte) (*fontSubstitute, error) {
+	switch {
+	case len(data) >= 4 && (string(data[:4]) == "\x00\x01\x00\x00" || string(data[:4]) == "true"):
+		return parseSfntFont(data, "/TrueType", "/FontFile2", "")
+	case len(data) >= 4 && string(data[:4]) == "OTTO":
+		return parseSfntFont(data, "/Type1", "/FontFile3", "/OpenType")
+	case len(data) >= 2 && data[0] == 0x80 && data[1] == 0x01:
+		return parsePfbFont(data)
+	}
+	return nil, errors.New("Unsupported font file format")
+}
+
+// Parse the metrics of a TrueType or OpenType font
+func parseSfntFont(data []byte, subtype string, fileKey string, fileSubtype string) (*fontSubstitute, error) {
+	if len(data) < 12 {
+		return nil, errors.New("Font file is truncated")
+	}
+
+	tables := make(map[string][]byte, 0)
+	n := int(binary.BigEndian.Uint16(data[4:]))
+	for i := 0; i < n; i++ {
+		record := 12 + 16*i
+		if record+16 > len(data) {
+			return nil, errors.New("Font file is truncated")
+		}
+		offset := int(binary.BigEndian.Uint32(data[record+8:]))
+		length := int(binary.BigEndian.Uint32(data[record+12:]))
+		if offset < 0 || length < 0 || offset+length > len(data) {
+			return nil, errors.New(fmt.Sprintf("Font table %q is out of bounds", data[record:record+4]))
+		}
+		tables[string(data[record:record+4])] = data[offset : offset+length]
+	}
+
+	head, hhea := tables["head"], tables["hhea"]
+	if len(head) < 54 || len(hhea) < 8 {
+		return nil, errors.New("Font file has no head or hhea table")
+	}
+	unitsPerEm := float64(binary.BigEndian.Uint16(head[18:]))
+	if unitsPerEm == 0 {
+		return nil, errors.New("Font file has no units per em")
+	}
+	scale := func(b []byte) float64 {
+		return float64(int16(binary.BigEndian.Uint16(b))) * 1000 / unitsPerEm
+	}
+
+	font := &fontSubstitute{
+		data:        data,
+		subtype:     subtype,
+		fileKey:     fileKey,
+		fileSubtype: fileSubtype,
+		lengths:     []int{len(data)},
+		bbox:        [4]float64{scale(head[36:]), scale(head[38:]), scale(head[40:]), scale(head[42:])},
+		ascent:      scale(hhea[4:]),
+		descent:     scale(hhea[6:]),
+	}
+	font.capHeight = font.ascent
+	if os2 := tables["OS/2"]; len(os2) >= 90 && binary.BigEndian.Uint16(os2) >= 2 {
+		font.capHeight = scale(os2[88:])
+	}
+	if post := tables["post"]; len(post) >= 8 {
+		font.italicAngle = float64(int32(binary.BigEndian.Uint32(post[4:]))) / 65536
+	}
+	if fileKey == "/FontFile3" {
+		// The lengths are only given for TrueType and Type 1 font files
+		font.lengths = nil
+	}
+
+	return font, nil
+}
+
+var (
+	pfbFontBBox     = regexp.MustCompile(`/FontBBox\s*[\[{]\s*(-?[\d.]+)\s+(-?[\d.]+)\s+(-?[\d.]+)\s+(-?[\d.]+)`)
+	pfbItalicAngle  = regexp.MustCompile(`/ItalicAngle\s+(-?[\d.]+)`)
+	pfbSegmentTypes = map[byte]int{1: 0, 2: 1}
+)
+
+// Parse a Type 1 font in PFB segments into the clear text, encrypted and
+// trailing parts of its font file
+func parsePfbFont(data []byte) (*fontSubstitute, error) {
+	var file bytes.Buffer
+	lengths := []int{0, 0, 0}
+	part := 0
+	for len(data) >= 2 && data[0] == 0x80 && data[1] != 3 {
+		if len(data) < 6 {
+			return nil, errors.New("Font file is truncated")
+		}
+		segment, ok := pfbSegmentTypes[data[1]]
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("Unknown PFB segment type %d", data[1]))
+		}
+		length := int(binary.LittleEndian.Uint32(data[2:]))
+		if length < 0 || 6+length > len(data) {
+			return nil, errors.New("Font file is truncated")
+		}
+
+		// Clear text after the encrypted part is its trailer
+		if segment == 0 && part > 0 {
+			segment = 2
+		}
+		part = segment
+		lengths[segment] += length
+		file.Write(data[6 : 6+length])
+		data = data[6+length:]
+	}
+	if lengths[0] == 0 || lengths[1] == 0 {
+		return nil, errors.New("Font file has no clear text or encrypted part")
+	}
+
+	font := &fontSubstitute{
+		data:      file.Bytes(),
+		subtype:   "/Type1",
+		fileKey:   "/FontFile",
+		lengths:   lengths,
+		bbox:      [4]float64{0, -200, 1000, 800},
+		ascent:    800,
+		descent:   -200,
+		capHeight: 700,
+	}
+	header := font.data[:lengths[0]]
+	if m := pfbFontBBox.FindSubmatch(header); m != nil {
+		for i := range font.bbox {
+			font.bbox[i], _ = strconv.ParseFloat(string(m[i+1]), 64)
+		}
+		font.ascent, font.descent, font.capHeight = font.bbox[3], font.bbox[1], font.bbox[3]
+	}
+	if m := pfbItalicAngle.FindSubmatch(header); m != nil {
+		font.italicAngle, _ = strconv.ParseFloat(string(m[1]), 64)
+	}
+
+	return font, nil
+}
+
+// Embed fonts for the fonts that pages use but do not embed, by /BaseFont
+// name, see Importer.SetFontSubstitutes
+func (pdfWriter *PdfWriter) SetFontSubstitutes(fonts map[string][]byte) error {
+	substitutes := make(map[string]*fontSubstitute, len(fonts))
+	for name, data := range fonts {
+		font, err := parseFontSubstitute(data)
+		if err != nil {
+			return errors.Wrap(err, "Failed to parse font for "+name)
+		}
+		substitutes[fontSubstituteName(name)] = font
+	}
+	pdfWriter.font_substitutes = substitutes
+	return nil
+}
+
+// Get the /BaseFont name of a substitute, with the leading slash
+func fontSubstituteName(name string) string {
+	return "/" + strings.TrimPrefix(name, "/")
+}
+
+// Get the substitute for a font dictionary that does not embed its font
+func (pdfWriter *PdfWriter) fontSubstitute(value *PdfValue) (*fontSubstitute, bool) {
+	if len(pdfWriter.font_substitutes) == 0 || value.Type != PDF_TYPE_DICTIONARY {
+		return nil, false
+	}
+
+	dict := value.Dictionary
+	if t, ok := dict["/Type"]; !ok || t.Token != "/Font" {
+		return nil, false
+	}
+	switch subtype := dict["/Subtype"]; {
+	case subtype == nil:
+		return nil, false
+	case subtype.Token != "/Type1" && subtype.Token != "/TrueType" && subtype.Token != "/MMType1":
+		return nil, false
+	}
+	baseFont, ok := dict["/BaseFont"]
+	if !ok {
+		return nil, false
+	}
+	font, ok := pdfWriter.font_substitutes[baseFont.Token]
+	if !ok {
+		return nil, false
+	}
+
+	descriptor, err := pdfWriter.r.resolveDirect(dict["/FontDescriptor"])
+	if err == nil && descriptor != nil && descriptor.Type == PDF_TYPE_DICTIONARY {
+		for _, key := range []string{"/FontFile", "/FontFile2", "/FontFile3"} {
+			if _, embedded := descriptor.Dictionary[key]; embedded {
+				return nil, false
+			}
+		}
+	}
+
+	return font, true
+}
+
+// Get the /Flags of the font descriptor of a font dictionary, or flags for
+// its name if it has none
+func (pdfWriter *PdfWriter) fontFlags(value *PdfValue) int {
+	descriptor, err := pdfWriter.r.resolveDirect(value.Dictionary["/FontDescriptor"])
+	if err == nil && descriptor != nil && descriptor.Type == PDF_TYPE_DICTIONARY {
+		if flags, ok := descriptor.Dictionary["/Flags"]; ok {
+			return flags.Int
+		}
+	}
+
+	// Symbolic or nonsymbolic
+	switch value.Dictionary["/BaseFont"].Token {
+	case "/Symbol", "/ZapfDingbats":
+		return 4
+	}
+	return 32
+}
+
+// The descriptor and font file of a substitute that are yet to be written
+type pendingFont struct {
+	name  string
+	font  *fontSubstitute
+	flags int
+	ids   [2]int
+}
+
+// Output a font dictionary that does not embed its font with the descriptor
+// of its substitute, which is written once for all writers sharing
+// shared_streams.  Returns the descriptor and font file if they have yet to
+// be written, see putFontSubstitute.
+func (pdfWriter *PdfWriter) writeSubstitutedFont(value *PdfValue, font *fontSubstitute) *pendingFont {
+	name := value.Dictionary["/BaseFont"].Token
+	flags := pdfWriter.fontFlags(value)
+
+	var pending *pendingFont
+	key := fmt.Sprintf("font:%s:%d", name, flags)
+	descriptor, ok := pdfWriter.shared_streams[key]
+	if !ok {
+		pending = &pendingFont{name: name, font: font, flags: flags, ids: [2]int{pdfWriter.allocObjId(0), pdfWriter.allocObjId(0)}}
+		descriptor = &PdfObjectId{id: pending.ids[0], hash: pdfWriter.shaOfInt(pending.ids[0])}
+		pdfWriter.shared_streams[key] = descriptor
+	}
+
+	c := value.Copy()
+	c.Dictionary["/Subtype"] = &PdfValue{Type: PDF_TYPE_TOKEN, Token: font.subtype}
+	pdfWriter.straightOut("<<")
+	pdfWriter.writeDictionaryEntries(c, "/FontDescriptor")
+	pdfWriter.outToken("/FontDescriptor")
+	pdfWriter.outPdfObjectIdRef(descriptor)
+	pdfWriter.straightOut(">>")
+
+	return pending
+}
+
+// Output the descriptor and font file of a substitute
+func (pdfWriter *PdfWriter) putFontSubstitute(pending *pendingFont) {
+	font := pending.font
+
+	pdfWriter.newObj(pending.ids[0], false)
+	pdfWriter.straightOut("<</Type /FontDescriptor /FontName ")
+	pdfWriter.outToken(pending.name)
+	pdfWriter.straightOut(fmt.Sprintf("/Flags %d /FontBBox [%.0F %.0F %.0F %.0F] /ItalicAngle %.2F /Ascent %.0F /Descent %.0F /CapHeight %.0F /StemV 80 %s ",
+		pending.flags, font.bbox[0], font.bbox[1], font.bbox[2], font.bbox[3], font.italicAngle, font.ascent, font.descent, font.capHeight, font.fileKey))
+	pdfWriter.outObjRef(pending.ids[1])
+	pdfWriter.out(">>")
+	pdfWriter.endObj()
+
+	data := deflate(font.data)
+	pdfWriter.newObj(pending.ids[1], false)
+	pdfWriter.straightOut(fmt.Sprintf("<</Length %d /Filter /FlateDecode", len(data)))
+	for i, length := range font.lengths {
+		pdfWriter.straightOut(fmt.Sprintf(" /Length%d %d", i+1, length))
+	}
+	if font.fileSubtype != "" {
+		pdfWriter.straightOut(" /Subtype " + font.fileSubtype)
+	}
+	pdfWriter.out(">>")
+	pdfWriter.out("stream")
+	pdfWriter.out(string(data))
+	pdfWriter.out("endstream")
+	pdfWriter.endObj()
+}
@@ -0,0 +1,278 @@
+package gofpdi
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// A terminal field of an interactive form (AcroForm)
+type FormField struct {
+	// Fully qualified name, e.g. "address.street"
+	Name string
+	// Field type: /Tx, /Btn, /Ch or /Sig
+	Type string
+	// The field value (/V), nil if the field has no value
+	Value *PdfValue
+}
+
+// Get the text of a field value.  Multiple values (e.g. of list boxes) are returned separately.
+func (field *FormField) Text() []string {
+	return fieldValueText(field.Value)
+}
+
+func fieldValueText(value *PdfValue) []string {
+	if value == nil {
+		return nil
+	}
+
+	switch value.Type {
+	case PDF_TYPE_STRING, PDF_TYPE_HEX:
+		return []string{decodeTextString(stringBytes(value))}
+	case PDF_TYPE_TOKEN:
+		return []string{strings.TrimPrefix(value.Token, "/")}
+	case PDF_TYPE_ARRAY:
+		result := make([]string, 0, len(value.Array))
+		for _, v := range value.Array {
+			result = append(result, fieldValueText(v)...)
+		}
+		return result
+	}
+
+	return nil
+}
+
+// Get all terminal fields of the document's interactive form
+func (pdfReader *PdfReader) getFormFields() ([]*FormField, error) {
+	result := make([]*FormField, 0)
+
+	acroForm, err := pdfReader.resolveDirect(pdfReader.catalog.Value.Dictionary["/AcroForm"])
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to resolve /AcroForm")
+	}
+	if acroForm == nil {
+		return result, nil
+	}
+
+	fields, err := pdfReader.resolveDirect(acroForm.Dictionary["/Fields"])
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to resolve /Fields")
+	}
+	if fields == nil {
+		return result, nil
+	}
+
+	visited := make(map[int]bool, 0)
+	for _, field := range fields.Array {
+		err = pdfReader.readFormField(field, "", "", nil, visited, &result)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// Read a field and its kids.  The field type and value are inheritable.
+func (pdfReader *PdfReader) readFormField(ref *PdfValue, parentName string, fieldType string, value *PdfValue, visited map[int]bool, result *[]*FormField) error {
+	if ref.Type == PDF_TYPE_OBJREF {
+		if visited[ref.Id] {
+			return nil
+		}
+		visited[ref.Id] = true
+	}
+
+	field, err := pdfReader.resolveDirect(ref)
+	if err != nil {
+		return errors.Wrap(err, "Failed to resolve form field")
+	}
+	if field == nil || field.Type != PDF_TYPE_DICTIONARY {
+		return nil
+	}
+
+	name := parentName
+	if t, ok := field.Dictionary["/T"]; ok {
+		partial := decodeTextString(stringBytes(t))
+		if name != "" {
+			name += "." + partial
+		} else {
+			name = partial
+		}
+	}
+
+	if ft, ok := field.Dictionary["/FT"]; ok {
+		fieldType = ft.Token
+	}
+
+	if v, ok := field.Dictionary["/V"]; ok {
+		value, err = pdfReader.resolveDirect(v)
+		if err != nil {
+			return errors.Wrap(err, "Failed to resolve field value")
+		}
+		if value != nil && value.Type == PDF_TYPE_STREAM {
+			value = nil
+		}
+	}
+
+	// Kids without a /T are widget annotations of this field, not fields
+	hasFieldKids := false
+	kids, err := pdfReader.resolveDirect(field.Dictionary["/Kids"])
+	if err != nil {
+		return errors.Wrap(err, "Failed to resolve field kids")
+	}
+	if kids != nil {
+		for _, kid := range kids.Array {
+			kidDict, err := pdfReader.resolveDirect(kid)
+			if err != nil {
+				return errors.Wrap(err, "Failed to resolve field kid")
+			}
+			if kidDict == nil || kidDict.Dictionary["/T"] == nil {
+				continue
+			}
+
+			hasFieldKids = true
+			err = pdfReader.readFormField(kid, name, fieldType, value, visited, result)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if !hasFieldKids && name != "" {
+		*result = append(*result, &FormField{Name: name, Type: fieldType, Value: value})
+	}
+
+	return nil
+}
+
+// Get all terminal fields of the interactive form of the current source
+func (importer *Importer) GetFormFields() ([]*FormField, error) {
+	return importer.GetReader().getFormFields()
+}
+
+// A node of the field hierarchy, rebuilt from fully qualified field names
+type formFieldNode struct {
+	name  string
+	field *FormField
+	kids  []*formFieldNode
+}
+
+func formFieldTree(fields []*FormField) []*formFieldNode {
+	root := &formFieldNode{}
+	for _, field := range fields {
+		node := root
+		for _, part := range strings.Split(field.Name, ".") {
+			var next *formFieldNode
+			for _, kid := range node.kids {
+				if kid.name == part {
+					next = kid
+					break
+				}
+			}
+			if next == nil {
+				next = &formFieldNode{name: part}
+				node.kids = append(node.kids, next)
+			}
+			node = next
+		}
+		node.field = field
+	}
+
+	sortFormFieldNodes(root.kids)
+	return root.kids
+}
+
+func sortFormFieldNodes(nodes []*formFieldNode) {
+	sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].name < nodes[j].name })
+	for _, node := range nodes {
+		sortFormFieldNodes(node.kids)
+	}
+}
+
+// Write the form data of the current source as FDF
+func (importer *Importer) WriteFDF(w io.Writer) error {
+	fields, err := importer.GetFormFields()
+	if err != nil {
+		return errors.Wrap(err, "Failed to get form fields")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%FDF-1.2\n%\xe2\xe3\xcf\xd3\n1 0 obj\n<< /FDF << /Fields [\n")
+	writeFDFFields(&buf, formFieldTree(fields))
+	buf.WriteString("] >> >>\nendobj\ntrailer\n<< /Root 1 0 R >>\n%%EOF\n")
+
+	_, err = w.Write(buf.Bytes())
+	if err != nil {
+		return errors.Wrap(err, "Failed to write FDF")
+	}
+
+	return nil
+}
+
+func writeFDFFields(buf *bytes.Buffer, nodes []*formFieldNode) {
+	for _, node := range nodes {
+		buf.WriteString("<< /T ")
+		writeContentValue(buf, encodeTextString(node.name))
+
+		if node.field != nil && node.field.Value != nil {
+			buf.WriteString(" /V ")
+			writeContentValue(buf, node.field.Value)
+		}
+
+		if len(node.kids) > 0 {
+			buf.WriteString(" /Kids [\n")
+			writeFDFFields(buf, node.kids)
+			buf.WriteString("]")
+		}
+
+		buf.WriteString(" >>\n")
+	}
+}
+
+// Write the form data of the current source as XFDF
+func (importer *Importer) WriteXFDF(w io.Writer) error {
+	fields, err := importer.GetFormFields()
+	if err != nil {
+		return errors.Wrap(err, "Failed to get form fields")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	buf.WriteString("<xfdf xmlns=\"http://ns.adobe.com/xfdf/\" xml:space=\"preserve\">\n<fields>\n")
+	writeXFDFFields(&buf, formFieldTree(fields))
+	buf.WriteString("</fields>\n</xfdf>\n")
+
+	_, err = w.Write(buf.Bytes())
+	if err != nil {
+		return errors.Wrap(err, "Failed to write XFDF")
+	}
+
+	return nil
+}
+
+func writeXFDFFields(buf *bytes.Buffer, nodes []*formFieldNode) {
+	for _, node := range nodes {
+		buf.WriteString("<field name=\"")
+		xml.EscapeText(buf, []byte(node.name))
+		buf.WriteString("\">")
+
+		if node.field != nil {
+			for _, text := range node.field.Text() {
+				buf.WriteString("<value>")
+				xml.EscapeText(buf, []byte(text))
+				buf.WriteString("</value>")
+			}
+		}
+
+		if len(node.kids) > 0 {
+			buf.WriteString("\n")
+			writeXFDFFields(buf, node.kids)
+		}
+
+		buf.WriteString("</field>\n")
+	}
+}
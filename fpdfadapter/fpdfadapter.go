@@ -0,0 +1,129 @@
+// Package fpdfadapter imports pages of existing PDF documents into documents
+// generated with github.com/go-pdf/fpdf, the maintained fork of gofpdf.
+//
+// It mirrors the contrib/gofpdi package shipped with gofpdf, so code written
+// against one can move to the other by changing the import path.
+package fpdfadapter
+
+import (
+	"io"
+
+	"github.com/hrubymar10/gofpdi"
+)
+
+// Pdf is the subset of *fpdf.Fpdf that is needed to hand imported objects
+// over to fpdf.  It is declared here so that this package does not depend on fpdf.
+type Pdf interface {
+	ImportObjects(objs map[string][]byte)
+	ImportObjPos(objs map[string]map[int]string)
+	ImportTemplates(tpls map[string]string)
+	UseImportedTemplate(tplName string, scaleX float64, scaleY float64, tX float64, tY float64)
+	SetError(err error)
+}
+
+// Importer wraps a gofpdi.Importer for use with a single fpdf document
+type Importer struct {
+	fpdi *gofpdi.Importer
+}
+
+// Default importer used by the package level functions
+var defaultImporter = NewImporter()
+
+// NewImporter returns a new importer.  Use one importer per fpdf document
+// when generating documents concurrently.
+func NewImporter() *Importer {
+	return &Importer{fpdi: gofpdi.NewImporter()}
+}
+
+// ImportPage imports a page of a PDF file with the specified box (/MediaBox,
+// /TrimBox, /ArtBox, /CropBox, or /BleedBox). Returns a template id that can
+// be used with UseImportedTemplate to draw the template onto the page.
+func ImportPage(f Pdf, sourceFile string, pageno int, box string) int {
+	return defaultImporter.ImportPage(f, sourceFile, pageno, box)
+}
+
+// ImportPageFromStream imports a page of a PDF from an io.ReadSeeker
+func ImportPageFromStream(f Pdf, rs *io.ReadSeeker, pageno int, box string) int {
+	return defaultImporter.ImportPageFromStream(f, rs, pageno, box)
+}
+
+// UseImportedTemplate draws the template onto the page at x,y. If w is 0, the
+// template will be scaled to fit based on h. If h is 0, the template will be
+// scaled to fit based on w.
+func UseImportedTemplate(f Pdf, tplid int, x float64, y float64, w float64, h float64) {
+	defaultImporter.UseImportedTemplate(f, tplid, x, y, w, h)
+}
+
+// GetPageSizes returns page dimensions for all pages of the imported pdf.
+// Result consists of map[<page number>]map[<box>]map[<dimension>]<value>.
+func GetPageSizes(f Pdf, sourceFile string) map[int]map[string]map[string]float64 {
+	return defaultImporter.GetPageSizes(f, sourceFile)
+}
+
+// ImportPage imports a page of a PDF file, see ImportPage
+func (i *Importer) ImportPage(f Pdf, sourceFile string, pageno int, box string) int {
+	if err := i.fpdi.SetSourceFile(sourceFile); err != nil {
+		f.SetError(err)
+		return -1
+	}
+
+	return i.getTemplateID(f, pageno, box)
+}
+
+// ImportPageFromStream imports a page of a PDF from an io.ReadSeeker, see ImportPageFromStream
+func (i *Importer) ImportPageFromStream(f Pdf, rs *io.ReadSeeker, pageno int, box string) int {
+	if err := i.fpdi.SetSourceStream(rs); err != nil {
+		f.SetError(err)
+		return -1
+	}
+
+	return i.getTemplateID(f, pageno, box)
+}
+
+func (i *Importer) getTemplateID(f Pdf, pageno int, box string) int {
+	tpl, err := i.fpdi.ImportPage(pageno, gofpdi.BoxName(box))
+	if err != nil {
+		f.SetError(err)
+		return -1
+	}
+
+	// Objects are returned with a sha1 hash instead of an integer id.  fpdf
+	// replaces the hashes with its own object ids when the document is written.
+	tplObjIDs, err := i.fpdi.PutFormXobjectsUnordered()
+	if err != nil {
+		f.SetError(err)
+		return -1
+	}
+
+	f.ImportTemplates(tplObjIDs)
+	f.ImportObjects(i.fpdi.GetImportedObjectsUnordered())
+	f.ImportObjPos(i.fpdi.GetImportedObjHashPos())
+
+	return tpl
+}
+
+// UseImportedTemplate draws the template onto the page, see UseImportedTemplate
+func (i *Importer) UseImportedTemplate(f Pdf, tplid int, x float64, y float64, w float64, h float64) {
+	if tplid < 0 {
+		return
+	}
+
+	tplName, scaleX, scaleY, tX, tY := i.fpdi.UseTemplate(tplid, x, y, w, h)
+	f.UseImportedTemplate(tplName, scaleX, scaleY, tX, tY)
+}
+
+// GetPageSizes returns page dimensions for all pages of the imported pdf, see GetPageSizes
+func (i *Importer) GetPageSizes(f Pdf, sourceFile string) map[int]map[string]map[string]float64 {
+	if err := i.fpdi.SetSourceFile(sourceFile); err != nil {
+		f.SetError(err)
+		return nil
+	}
+
+	sizes, err := i.fpdi.GetPageSizes()
+	if err != nil {
+		f.SetError(err)
+		return nil
+	}
+
+	return sizes
+}
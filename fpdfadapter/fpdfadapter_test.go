@@ -0,0 +1,156 @@
+package fpdfadapter
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// A Pdf that records what is handed over to it
+type stubPdf struct {
+	objects   map[string][]byte
+	objPos    map[string]map[int]string
+	templates map[string]string
+	used      []string
+	err       error
+}
+
+// Get a pdf that has nothing imported yet
+func newStubPdf() *stubPdf {
+	return &stubPdf{
+		objects:   make(map[string][]byte, 0),
+		objPos:    make(map[string]map[int]string, 0),
+		templates: make(map[string]string, 0),
+	}
+}
+
+func (pdf *stubPdf) ImportObjects(objs map[string][]byte) {
+	for hash, data := range objs {
+		pdf.objects[hash] = data
+	}
+}
+
+func (pdf *stubPdf) ImportObjPos(objs map[string]map[int]string) {
+	for hash, pos := range objs {
+		pdf.objPos[hash] = pos
+	}
+}
+
+func (pdf *stubPdf) ImportTemplates(tpls map[string]string) {
+	for name, hash := range tpls {
+		pdf.templates[name] = hash
+	}
+}
+
+func (pdf *stubPdf) UseImportedTemplate(tplName string, scaleX float64, scaleY float64, tX float64, tY float64) {
+	pdf.used = append(pdf.used, tplName)
+}
+
+func (pdf *stubPdf) SetError(err error) {
+	if pdf.err == nil {
+		pdf.err = err
+	}
+}
+
+var testFile = filepath.Join("..", "testdata", "type3.pdf")
+
+// An imported page hands its template and the objects it refers to over to
+// the pdf, and drawing it uses the template
+func TestImportPage(t *testing.T) {
+	pdf := newStubPdf()
+	importer := NewImporter()
+	tplid := importer.ImportPage(pdf, testFile, 1, "/MediaBox")
+	if pdf.err != nil || tplid < 0 {
+		t.Fatalf("got template %d (%v)", tplid, pdf.err)
+	}
+	if len(pdf.templates) != 1 {
+		t.Fatalf("got templates %v, want 1", pdf.templates)
+	}
+	for name, hash := range pdf.templates {
+		if _, ok := pdf.objects[hash]; !ok {
+			t.Errorf("form xobject of template %s was not imported", name)
+		}
+	}
+	// The references of the objects are to objects that were imported
+	for hash, pos := range pdf.objPos {
+		if _, ok := pdf.objects[hash]; !ok {
+			t.Errorf("positions of object %s that was not imported", hash)
+		}
+		for _, ref := range pos {
+			if _, ok := pdf.objects[ref]; !ok {
+				t.Errorf("object %s refers to object %s that was not imported", hash, ref)
+			}
+		}
+	}
+
+	importer.UseImportedTemplate(pdf, tplid, 10, 10, 150, 0)
+	if len(pdf.used) != 1 {
+		t.Fatalf("used templates %v, want 1", pdf.used)
+	}
+	if _, ok := pdf.templates[pdf.used[0]]; !ok {
+		t.Errorf("used template %s, which was not imported", pdf.used[0])
+	}
+
+	// Importing the page again returns the same template
+	if again := importer.ImportPage(pdf, testFile, 1, "/MediaBox"); again != tplid {
+		t.Errorf("got template %d for the same page, want %d", again, tplid)
+	}
+}
+
+// Pages are imported from streams as well
+func TestImportPageFromStream(t *testing.T) {
+	file, err := os.Open(testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	pdf := newStubPdf()
+	var rs io.ReadSeeker = file
+	if tplid := NewImporter().ImportPageFromStream(pdf, &rs, 1, "/MediaBox"); pdf.err != nil || tplid < 0 {
+		t.Fatalf("got template %d (%v)", tplid, pdf.err)
+	}
+	if len(pdf.templates) != 1 {
+		t.Errorf("got templates %v, want 1", pdf.templates)
+	}
+}
+
+// Errors are set on the pdf, and templates that failed to import are not drawn
+func TestImportErrors(t *testing.T) {
+	pdf := newStubPdf()
+	importer := NewImporter()
+	tplid := importer.ImportPage(pdf, filepath.Join(t.TempDir(), "missing.pdf"), 1, "/MediaBox")
+	if tplid != -1 || pdf.err == nil {
+		t.Errorf("got template %d (%v) for a missing file", tplid, pdf.err)
+	}
+	importer.UseImportedTemplate(pdf, tplid, 0, 0, 100, 100)
+	if len(pdf.used) != 0 {
+		t.Errorf("used templates %v", pdf.used)
+	}
+
+	pdf = newStubPdf()
+	if tplid := importer.ImportPage(pdf, testFile, 2, "/MediaBox"); tplid != -1 || pdf.err == nil {
+		t.Errorf("got template %d (%v) for a page that does not exist", tplid, pdf.err)
+	}
+	pdf = newStubPdf()
+	if sizes := importer.GetPageSizes(pdf, filepath.Join(t.TempDir(), "missing.pdf")); sizes != nil || pdf.err == nil {
+		t.Errorf("got sizes %v (%v) for a missing file", sizes, pdf.err)
+	}
+}
+
+// The page sizes are those of the boxes of the source
+func TestGetPageSizes(t *testing.T) {
+	pdf := newStubPdf()
+	sizes := GetPageSizes(pdf, testFile)
+	if pdf.err != nil {
+		t.Fatal(pdf.err)
+	}
+	box, ok := sizes[1]["/MediaBox"]
+	if len(sizes) != 1 || !ok {
+		t.Fatalf("got sizes %v", sizes)
+	}
+	if box["w"] != 300 || box["h"] != 100 {
+		t.Errorf("media box is %v x %v, want 300 x 100", box["w"], box["h"])
+	}
+}
@@ -0,0 +1,189 @@
+package gofpdi
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Entry points for fuzzing the reader and the writer, run with go test -fuzz
+// by the Fuzz tests of fuzz_test.go.  Each one takes arbitrary
+// bytes: inputs that are not valid pdf are expected and not reported, an
+// error is returned only if an invariant of the parser or the writer is
+// broken, and any panic is a bug.
+
+// Limits of the readers of the fuzz entry points, so compression bombs and
+// huge object counts are not mistaken for hangs
+var fuzzLimits = Limits{
+	MaxObjects:     100000,
+	MaxStreamBytes: 16 << 20,
+}
+
+// Read data as a sequence of tokens and values, the way content streams and
+// object streams are read
+func fuzzTokenizer(data []byte) error {
+	lexer := &PdfReader{}
+	r := bufio.NewReader(bytes.NewReader(fuzzDelimit(data)))
+
+	// Every value takes at least one byte of the input
+	for n := 0; ; n++ {
+		if n > len(data) {
+			return errors.New(fmt.Sprintf("Tokenizer read more than %d values from %d bytes", len(data), len(data)))
+		}
+
+		t, err := lexer.readToken(r)
+		if err != nil || t == "" {
+			return nil
+		}
+		if _, err := lexer.readValue(r, t); err != nil {
+			return nil
+		}
+	}
+}
+
+// Read data as a pdf file, which finds and parses its cross-reference table
+// or streams, or reconstructs them if they are damaged
+func fuzzXref(data []byte) error {
+	reader, err := fuzzReader(data)
+	if err != nil {
+		return nil
+	}
+
+	for id, gens := range reader.xref {
+		for gen, offset := range gens {
+			if offset < 0 {
+				return errors.New(fmt.Sprintf("Object %d %d R is at offset %d", id, gen, offset))
+			}
+		}
+	}
+	reader.getNumPages()
+	return nil
+}
+
+// Read data as a pdf file and resolve every object of its cross-reference
+// table, including objects of object streams, the data of streams and the
+// page tree
+func fuzzResolve(data []byte) error {
+	reader, err := fuzzReader(data)
+	if err != nil {
+		return nil
+	}
+
+	for _, ref := range fuzzRefs(reader) {
+		obj, err := reader.GetObject(ref[0], ref[1])
+		if err != nil || obj == nil {
+			continue
+		}
+		if obj.Type == PDF_TYPE_STREAM {
+			reader.StreamData(obj)
+		}
+	}
+	reader.getAllPageBoxes(1)
+	reader.WalkPages(func(pageno int, page *PdfValue) error {
+		reader.getPageResources(pageno)
+		if contents, err := page.GetKey("/Contents"); err == nil {
+			reader.getPageContent(contents)
+		}
+		return nil
+	})
+	return nil
+}
+
+// Read data as a single value, write it, read the output back and write it
+// again.  The output must be readable, and writing a value that was read
+// from the output must give the same output.
+func fuzzRoundTrip(data []byte) error {
+	value, err := fuzzReadValue(data)
+	if err != nil || value == nil || value.Type == PDF_TYPE_OBJDEC {
+		return nil
+	}
+
+	written := fuzzWriteValue(value)
+	for round := 0; round < 2; round++ {
+		value, err = fuzzReadValue(written)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("Written value %q cannot be read back", written))
+		}
+		if value == nil {
+			return errors.New(fmt.Sprintf("Written value %q is empty", written))
+		}
+
+		rewritten := fuzzWriteValue(value)
+		if round > 0 && !bytes.Equal(rewritten, written) {
+			return errors.New(fmt.Sprintf("Value written as %q is written as %q once read back", written, rewritten))
+		}
+		written = rewritten
+	}
+	return nil
+}
+
+// The tokenizer needs a delimiter after the last token
+func fuzzDelimit(data []byte) []byte {
+	buf := make([]byte, len(data)+1)
+	copy(buf, data)
+	buf[len(data)] = '\n'
+	return buf
+}
+
+// Get a reader of data, with the limits set before the source is read
+func fuzzReader(data []byte) (*PdfReader, error) {
+	reader := &PdfReader{f: bytes.NewReader(data), nBytes: int64(len(data)), limits: fuzzLimits}
+	if err := reader.init(); err != nil {
+		return nil, err
+	}
+	if err := reader.SetLimits(fuzzLimits); err != nil {
+		return nil, err
+	}
+	return reader, nil
+}
+
+// Get the objects of the cross-reference table of a reader as id and
+// generation, in order, so runs on the same input do the same
+func fuzzRefs(reader *PdfReader) [][2]int {
+	refs := make([][2]int, 0, len(reader.xref)+len(reader.xrefStream))
+	for id, gens := range reader.xref {
+		for gen := range gens {
+			refs = append(refs, [2]int{id, gen})
+		}
+	}
+	for id := range reader.xrefStream {
+		if _, ok := reader.xref[id]; !ok {
+			refs = append(refs, [2]int{id, 0})
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i][0] != refs[j][0] {
+			return refs[i][0] < refs[j][0]
+		}
+		return refs[i][1] < refs[j][1]
+	})
+	return refs
+}
+
+// Read the first value of data, nil if there is none
+func fuzzReadValue(data []byte) (*PdfValue, error) {
+	lexer := &PdfReader{}
+	r := bufio.NewReader(bytes.NewReader(fuzzDelimit(data)))
+
+	t, err := lexer.readToken(r)
+	if err != nil || t == "" {
+		return nil, err
+	}
+	return lexer.readValue(r, t)
+}
+
+// Write a value the way the writer writes the objects it copies, with the
+// entries of dictionaries sorted so the output does not depend on map order
+// and with the object numbers of references kept
+func fuzzWriteValue(value *PdfValue) []byte {
+	writer := &PdfWriter{}
+	writer.Init()
+	writer.sort_keys = true
+	writer.renumbering = RenumberPreserve
+	writer.newObj(-1, false)
+	writer.writeValue(value)
+	return writer.current_obj.buffer.Bytes()
+}
@@ -0,0 +1,31 @@
+package gofpdi
+
+import "testing"
+
+// Fuzz tests of the entry points of fuzz.go, seeded with the inputs in
+// testdata/fuzz, e.g. go test -run '^$' -fuzz FuzzXref -fuzztime 90s.  Without
+// -fuzz, go test runs them on the seeds.
+
+func FuzzTokenizer(f *testing.F) {
+	fuzz(f, fuzzTokenizer)
+}
+
+func FuzzXref(f *testing.F) {
+	fuzz(f, fuzzXref)
+}
+
+func FuzzResolve(f *testing.F) {
+	fuzz(f, fuzzResolve)
+}
+
+func FuzzWriteValue(f *testing.F) {
+	fuzz(f, fuzzRoundTrip)
+}
+
+func fuzz(f *testing.F, target func(data []byte) error) {
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := target(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
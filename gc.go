@@ -0,0 +1,70 @@
+package gofpdi
+
+// Remove written objects that nothing refers to any more, see Importer.SetRemoveUnreachable
+func (pdfWriter *PdfWriter) SetRemoveUnreachable(b bool) {
+	pdfWriter.remove_unreachable = b
+}
+
+// Remove the written objects that cannot be reached from the form xobjects of
+// the templates, e.g. objects a filter no longer refers to.  Streams the writer shares with other
+// writers are kept.  Removed source objects are copied again if they are
+// referenced later.  Returns the number of objects removed.
+func (pdfWriter *PdfWriter) removeUnreachable() int {
+	byHash := make(map[string]*PdfObjectId, len(pdfWriter.written_objs))
+	for pdfObjId := range pdfWriter.written_objs {
+		byHash[pdfObjId.hash] = pdfObjId
+	}
+
+	// Mark
+	reachable := make(map[*PdfObjectId]bool, len(pdfWriter.written_objs))
+	pending := make([]*PdfObjectId, 0)
+	mark := func(hash string) {
+		if pdfObjId, ok := byHash[hash]; ok && !reachable[pdfObjId] {
+			reachable[pdfObjId] = true
+			pending = append(pending, pdfObjId)
+		}
+	}
+
+	for _, tpl := range pdfWriter.tpls {
+		if tpl.objId != nil {
+			mark(tpl.objId.hash)
+		}
+	}
+	for _, pdfObjId := range pdfWriter.shared_streams {
+		mark(pdfObjId.hash)
+	}
+
+	for len(pending) > 0 {
+		pdfObjId := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		for _, hash := range pdfWriter.written_obj_pos[pdfObjId] {
+			mark(hash)
+		}
+	}
+
+	// Sweep
+	removed := make(map[int]bool, 0)
+	for pdfObjId, data := range pdfWriter.written_objs {
+		if reachable[pdfObjId] {
+			continue
+		}
+
+		if pdfWriter.spill != nil && data != nil {
+			pdfWriter.spill.memory -= int64(len(data))
+		}
+		delete(pdfWriter.written_objs, pdfObjId)
+		delete(pdfWriter.written_obj_pos, pdfObjId)
+		delete(pdfWriter.spilled_objs, pdfObjId)
+		delete(pdfWriter.written_readers, pdfObjId)
+		removed[pdfObjId.id] = true
+	}
+
+	// Forget the source objects that were removed
+	for id, v := range pdfWriter.don_obj_stack {
+		if removed[v.NewId] {
+			delete(pdfWriter.don_obj_stack, id)
+		}
+	}
+
+	return len(removed)
+}
@@ -0,0 +1,153 @@
+package gofpdi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// Millimeters per point
+const mmPerPt = 25.4 / 72
+
+// The corners and size of a page box, in points
+type BoxGeometry struct {
+	Llx    float64 `json:"llx"`
+	Lly    float64 `json:"lly"`
+	Urx    float64 `json:"urx"`
+	Ury    float64 `json:"ury"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// The geometry of a page: its boxes, rotation and the size it is displayed at
+type PageGeometry struct {
+	Page  int                    `json:"page"`
+	Boxes map[string]BoxGeometry `json:"boxes"`
+	// Rotation in degrees clockwise (0, 90, 180 or 270), rounded from
+	// RawRotation, the /Rotate value of the page
+	Rotation    int     `json:"rotation"`
+	RawRotation float64 `json:"rawRotation"`
+	UserUnit    float64 `json:"userUnit"`
+	// Display size: the crop box, rotated and scaled by UserUnit
+	WidthPt  float64 `json:"widthPt"`
+	HeightPt float64 `json:"heightPt"`
+	WidthMm  float64 `json:"widthMm"`
+	HeightMm float64 `json:"heightMm"`
+}
+
+// Get the user unit of a page (the size of a user space unit in points)
+func (pdfReader *PdfReader) getPageUserUnit(pageno int) (float64, error) {
+	if len(pdfReader.pages) < pageno {
+		return 0, errors.New(fmt.Sprintf("Page %d does not exist", pageno))
+	}
+
+	page, err := pdfReader.getPage(pageno)
+	if err != nil {
+		return 0, errors.Wrap(err, "Failed to resolve page object")
+	}
+
+	userUnit, err := pdfReader.resolveDirect(page.Value.Dictionary["/UserUnit"])
+	if err != nil {
+		return 0, errors.Wrap(err, "Failed to resolve /UserUnit")
+	}
+	if userUnit == nil || userUnit.Real <= 0 {
+		return 1, nil
+	}
+
+	return userUnit.Real, nil
+}
+
+// Get the geometry of a page
+func (pdfReader *PdfReader) getPageGeometry(pageno int) (*PageGeometry, error) {
+	boxes, err := pdfReader.getPageBoxes(pageno, 1)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get page boxes")
+	}
+
+	rotation, rawRotation, err := pdfReader.getNormalizedPageRotation(pageno)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get page rotation")
+	}
+
+	userUnit, err := pdfReader.getPageUserUnit(pageno)
+	if err != nil {
+		return nil, err
+	}
+
+	geometry := &PageGeometry{
+		Page:        pageno,
+		Boxes:       make(map[string]BoxGeometry, len(boxes)),
+		Rotation:    rotation,
+		RawRotation: rawRotation,
+		UserUnit:    userUnit,
+	}
+
+	for name, box := range boxes {
+		if len(box) == 0 {
+			continue
+		}
+		geometry.Boxes[name] = BoxGeometry{
+			Llx:    box["llx"],
+			Lly:    box["lly"],
+			Urx:    box["urx"],
+			Ury:    box["ury"],
+			Width:  box["w"],
+			Height: box["h"],
+		}
+	}
+
+	// The crop box defaults to the media box
+	display, ok := geometry.Boxes["/CropBox"]
+	if !ok {
+		display = geometry.Boxes["/MediaBox"]
+	}
+
+	w := display.Width * userUnit
+	h := display.Height * userUnit
+	if (rotation/90)%2 != 0 {
+		w, h = h, w
+	}
+
+	geometry.WidthPt = w
+	geometry.HeightPt = h
+	geometry.WidthMm = w * mmPerPt
+	geometry.HeightMm = h * mmPerPt
+
+	return geometry, nil
+}
+
+// Get the geometry of every page of the current source
+func (importer *Importer) GetPageGeometry() ([]*PageGeometry, error) {
+	reader := importer.GetReader()
+
+	result := make([]*PageGeometry, 0, len(reader.pages))
+	for i := 1; i <= len(reader.pages); i++ {
+		geometry, err := reader.getPageGeometry(i)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("Failed to get geometry of page %d", i))
+		}
+		result = append(result, geometry)
+	}
+
+	return result, nil
+}
+
+// Write the geometry of every page of the current source as JSON
+func (importer *Importer) WritePageGeometryJSON(w io.Writer) error {
+	geometry, err := importer.GetPageGeometry()
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	err = encoder.Encode(geometry)
+	if err != nil {
+		return errors.Wrap(err, "Failed to encode page geometry")
+	}
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package gofpditest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// Add every file of a directory as an input of a fuzz test, e.g. a
+// directory of pdf files for a fuzz test of code that imports them.
+// Subdirectories are not read.  The files are added as they are, unlike those
+// of testdata/fuzz, which go test reads in its own format.
+func AddSeedCorpus(f *testing.F, dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return errors.Wrap(err, "Failed to read seed corpus")
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return errors.Wrap(err, "Failed to read seed "+entry.Name())
+		}
+		f.Add(data)
+	}
+	return nil
+}
@@ -0,0 +1,331 @@
+// Package gofpditest checks that pdf files survive a round trip through
+// gofpdi: their pages are imported, written to a new document with
+// gofpdi.Document and the document is read back.  The checks are the ones
+// gofpdi is tested with, so integrators can run them against their own
+// corpora of documents, with the importer options they use:
+//
+//   - the document has as many pages as the source
+//   - each page has the display size of the source page, and the bleed,
+//     trim and art boxes of the source page, as far as they lie within its
+//     crop box
+//   - the data of the streams the resources of the source pages refer to,
+//     such as images and fonts, is copied unchanged
+//
+// AddSeedCorpus seeds fuzz tests with a corpus of documents.
+package gofpditest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hrubymar10/gofpdi"
+	"github.com/pkg/errors"
+)
+
+// Sizes of boxes may differ by this many points, as the document writes
+// them rounded
+const boxTolerance = 0.02
+
+// Options of a round trip
+type Options struct {
+	// Called with the importer of the document before pages are imported,
+	// e.g. to set the importer options that are used in production
+	Configure func(importer *gofpdi.Importer) error
+	// Do not check the data of streams, for importer options that change
+	// them, such as SetCompressStreams or SetImageDownsampling
+	IgnoreStreams bool
+}
+
+// The outcome of a round trip
+type Result struct {
+	// The document the pages were written to
+	Output []byte
+	// Number of pages of the source
+	Pages int
+	// Descriptions of the checks that failed, empty if the round trip
+	// preserved the source
+	Failures []string
+}
+
+// Run a round trip of a pdf file, see the package documentation.  The error
+// is for sources that cannot be imported and documents that cannot be
+// written or read back; checks that fail are reported by the result.
+func RoundTrip(filename string, options Options) (*Result, error) {
+	doc := gofpdi.NewDocument()
+	importer := doc.Importer()
+	if options.Configure != nil {
+		err := options.Configure(importer)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to configure importer")
+		}
+	}
+
+	err := importer.SetSourceFile(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to open source")
+	}
+	pages, err := importer.GetNumPages()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get page count")
+	}
+	for pageno := 1; pageno <= pages; pageno++ {
+		tplid, err := importer.ImportPage(pageno, gofpdi.CropBox)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("Failed to import page %d", pageno))
+		}
+		err = doc.AddTemplatePage(tplid)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("Failed to add page %d", pageno))
+		}
+	}
+
+	var buf bytes.Buffer
+	_, err = doc.WriteTo(&buf)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to write document")
+	}
+	result := &Result{Output: buf.Bytes(), Pages: pages}
+
+	source := importer.GetReader()
+	output, err := gofpdi.NewPdfReaderFromStream(bytes.NewReader(result.Output))
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read document back")
+	}
+	outputImporter := gofpdi.NewImporter()
+	err = outputImporter.SetSourceReader("output", output)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read document back")
+	}
+
+	failures, err := checkPages(importer, outputImporter, pages)
+	if err != nil {
+		return nil, err
+	}
+	result.Failures = append(result.Failures, failures...)
+
+	if !options.IgnoreStreams && len(result.Failures) == 0 {
+		failures, err := checkStreams(source, output)
+		if err != nil {
+			return nil, err
+		}
+		result.Failures = append(result.Failures, failures...)
+	}
+
+	return result, nil
+}
+
+// Run a round trip of a pdf file and get an error listing the checks that
+// failed, if any
+func CheckFile(filename string, options Options) error {
+	result, err := RoundTrip(filename, options)
+	if err != nil {
+		return err
+	}
+	if len(result.Failures) > 0 {
+		return errors.New(filename + ": " + strings.Join(result.Failures, "; "))
+	}
+	return nil
+}
+
+// Run a round trip of every file that matches a pattern (see filepath.Glob),
+// e.g. "testdata/*.pdf", each as a subtest of t named after the file
+func CheckCorpus(t *testing.T, pattern string, options Options) {
+	t.Helper()
+
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("No files match %s", pattern)
+	}
+
+	for _, file := range files {
+		file := file
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			result, err := RoundTrip(file, options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, failure := range result.Failures {
+				t.Error(failure)
+			}
+		})
+	}
+}
+
+// Compare the page count and the boxes of the pages of the source and of the
+// document
+func checkPages(source *gofpdi.Importer, output *gofpdi.Importer, pages int) ([]string, error) {
+	failures := make([]string, 0)
+
+	sourceGeometry, err := source.GetPageGeometry()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get page geometry of source")
+	}
+	outputGeometry, err := output.GetPageGeometry()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get page geometry of document")
+	}
+	if len(outputGeometry) != pages {
+		return append(failures, fmt.Sprintf("document has %d pages, the source %d", len(outputGeometry), pages)), nil
+	}
+
+	for i, want := range sourceGeometry {
+		got := outputGeometry[i]
+		if !equalSize(want.WidthPt, want.HeightPt, got.WidthPt, got.HeightPt) {
+			failures = append(failures, fmt.Sprintf("page %d is %.2f x %.2f, the source page %.2f x %.2f",
+				i+1, got.WidthPt, got.HeightPt, want.WidthPt, want.HeightPt))
+		}
+
+		crop, ok := want.Boxes["/CropBox"]
+		if !ok {
+			crop = want.Boxes["/MediaBox"]
+		}
+		for _, name := range []string{"/BleedBox", "/TrimBox", "/ArtBox"} {
+			box, ok := want.Boxes[name]
+			if !ok {
+				continue
+			}
+
+			// Boxes are clipped to the page
+			w := (math.Min(box.Urx, crop.Urx) - math.Max(box.Llx, crop.Llx)) * want.UserUnit
+			h := (math.Min(box.Ury, crop.Ury) - math.Max(box.Lly, crop.Lly)) * want.UserUnit
+			if w <= 0 || h <= 0 {
+				continue
+			}
+			if (want.Rotation/90)%2 != 0 {
+				w, h = h, w
+			}
+
+			gotBox, ok := got.Boxes[name]
+			if !ok {
+				failures = append(failures, fmt.Sprintf("page %d has no %s", i+1, name))
+				continue
+			}
+			gotW, gotH := gotBox.Width*got.UserUnit, gotBox.Height*got.UserUnit
+			if !equalSize(w, h, gotW, gotH) {
+				failures = append(failures, fmt.Sprintf("%s of page %d is %.2f x %.2f, of the source page %.2f x %.2f",
+					name, i+1, gotW, gotH, w, h))
+			}
+		}
+	}
+
+	return failures, nil
+}
+
+func equalSize(w1 float64, h1 float64, w2 float64, h2 float64) bool {
+	return math.Abs(w1-w2) <= boxTolerance*math.Max(1, w1/1000) && math.Abs(h1-h2) <= boxTolerance*math.Max(1, h1/1000)
+}
+
+// Check that the data of every stream the resources of the source pages
+// refer to is also in a stream of the document
+func checkStreams(source *gofpdi.PdfReader, output *gofpdi.PdfReader) ([]string, error) {
+	want, err := pageResourceStreams(source, true)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read streams of source")
+	}
+	got, err := pageResourceStreams(output, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read streams of document")
+	}
+
+	failures := make([]string, 0)
+	for hash, ref := range want {
+		if _, ok := got[hash]; !ok {
+			failures = append(failures, fmt.Sprintf("data of stream %s of the source has changed", ref))
+		}
+	}
+	return failures, nil
+}
+
+// Get the hashes of the data of the streams the resources of the pages of a
+// document refer to, directly or not, with the object each one is first
+// found in.  Resources inherited from the page tree are followed if inherit is set.
+func pageResourceStreams(reader *gofpdi.PdfReader, inherit bool) (map[string]string, error) {
+	result := make(map[string]string, 0)
+	visited := make(map[int]bool, 0)
+
+	var walk func(value *gofpdi.PdfValue, ref string) error
+	walk = func(value *gofpdi.PdfValue, ref string) error {
+		if value == nil {
+			return nil
+		}
+		if value.IsRef() {
+			if visited[value.Id] {
+				return nil
+			}
+			visited[value.Id] = true
+			ref = fmt.Sprintf("%d %d R", value.Id, value.Gen)
+
+			// References to objects that do not exist are null, as for the
+			// importer
+			value, _ = reader.Resolve(value)
+			if value == nil {
+				return nil
+			}
+		}
+
+		switch value.Type {
+		case gofpdi.PDF_TYPE_STREAM:
+			data, err := reader.StreamData(value)
+			if err != nil {
+				return err
+			}
+			hash := fmt.Sprintf("%x", sha256.Sum256(data))
+			if _, ok := result[hash]; !ok {
+				result[hash] = ref
+			}
+			return walk(value.Value, ref)
+		case gofpdi.PDF_TYPE_DICTIONARY:
+			for key, entry := range value.Dictionary {
+				// Back to the page tree
+				if key == "/Parent" || key == "/P" {
+					continue
+				}
+				if err := walk(entry, ref); err != nil {
+					return err
+				}
+			}
+		case gofpdi.PDF_TYPE_ARRAY:
+			for _, entry := range value.Array {
+				if err := walk(entry, ref); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	err := reader.WalkPages(func(pageno int, page *gofpdi.PdfValue) error {
+		node := page
+		for node != nil {
+			resources, err := node.GetKey("/Resources")
+			if err == nil {
+				return walk(resources, fmt.Sprintf("resources of page %d", pageno))
+			}
+			if !inherit {
+				return nil
+			}
+
+			parent, err := node.GetKey("/Parent")
+			if err != nil {
+				return nil
+			}
+			node, err = reader.Resolve(parent)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,121 @@
+package gofpditest
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hrubymar10/gofpdi"
+)
+
+// The documents of the gofpdi tests survive a round trip
+func TestCheckCorpus(t *testing.T) {
+	CheckCorpus(t, filepath.Join("..", "testdata", "*.pdf"), Options{})
+	CheckCorpus(t, filepath.Join("..", "testdata", "xref", "*.pdf"), Options{})
+	CheckCorpus(t, filepath.Join("..", "testdata", "encrypted", "*.pdf"), Options{
+		Configure: func(importer *gofpdi.Importer) error {
+			return importer.SetPassword("owner")
+		},
+	})
+}
+
+// A round trip writes a document with the pages of the source, and sources
+// that cannot be imported are errors
+func TestRoundTrip(t *testing.T) {
+	file := filepath.Join("..", "testdata", "type3.pdf")
+	result, err := RoundTrip(file, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Failures) > 0 {
+		t.Errorf("failures: %v", result.Failures)
+	}
+	reader, err := gofpdi.NewPdfReaderFromStream(bytes.NewReader(result.Output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	importer := gofpdi.NewImporter()
+	if err := importer.SetSourceReader("output", reader); err != nil {
+		t.Fatal(err)
+	}
+	if pages, err := importer.GetNumPages(); err != nil || pages != result.Pages {
+		t.Errorf("document has %d pages (%v), want %d", pages, err, result.Pages)
+	}
+
+	// Encrypted sources need a password
+	if err := CheckFile(filepath.Join("..", "testdata", "encrypted", "aes-256-r6.pdf"), Options{}); err == nil {
+		t.Error("imported an encrypted source without a password")
+	}
+	if err := CheckFile(filepath.Join(t.TempDir(), "missing.pdf"), Options{}); err == nil {
+		t.Error("no error for a missing file")
+	}
+}
+
+// Open an importer of a file
+func openImporter(t *testing.T, file string) *gofpdi.Importer {
+	t.Helper()
+
+	importer := gofpdi.NewImporter()
+	if err := importer.SetSourceFile(filepath.Join("..", "testdata", file)); err != nil {
+		t.Fatal(err)
+	}
+	return importer
+}
+
+// Pages and streams that differ from the source are reported
+func TestCheckFailures(t *testing.T) {
+	// Page 2 of the rebuilt table has the size of the last definition
+	source := openImporter(t, filepath.Join("xref", "startxref-off.pdf"))
+	output := openImporter(t, filepath.Join("xref", "duplicate-objects-rebuilt.pdf"))
+	failures, err := checkPages(source, output, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "page 2 is 400.00 x 300.00, the source page 300.00 x 200.00"; len(failures) != 1 || failures[0] != want {
+		t.Errorf("got failures %q, want %q", failures, want)
+	}
+
+	failures, err = checkPages(source, output, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "document has 2 pages, the source 3"; len(failures) != 1 || failures[0] != want {
+		t.Errorf("got failures %q, want %q", failures, want)
+	}
+
+	// The streams of the fonts of the source are not in the other document
+	failures, err = checkStreams(openImporter(t, "type3.pdf").GetReader(), source.GetReader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) == 0 {
+		t.Error("no failures for a document without the streams of the source")
+	}
+	for _, failure := range failures {
+		if !strings.HasPrefix(failure, "data of stream") {
+			t.Errorf("unexpected failure %q", failure)
+		}
+	}
+}
+
+// Every file of a seed corpus is a seed, and round trips of the seeds do not
+// panic
+func FuzzRoundTrip(f *testing.F) {
+	if err := AddSeedCorpus(f, filepath.Join("..", "testdata", "xref")); err != nil {
+		f.Fatal(err)
+	}
+	if err := AddSeedCorpus(f, filepath.Join("..", "testdata", "missing")); err == nil {
+		f.Error("no error for a missing seed corpus")
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		file := filepath.Join(t.TempDir(), "seed.pdf")
+		if err := os.WriteFile(file, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		// Errors are expected for inputs that are not valid documents
+		_, _ = RoundTrip(file, Options{})
+	})
+}
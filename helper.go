@@ -1,7 +1,10 @@
 package gofpdi
 
 import (
+	"fmt"
+	"sort"
 	"strings"
+	"unicode/utf16"
 )
 
 // Determine if a value is numeric
@@ -12,12 +15,12 @@ func is_numeric(val interface{}) bool {
 	case float32, float64, complex64, complex128:
 		return true
 	case string:
-		if str == "" {
-			return false
-		}
 		// Trim any whitespace
 		str = strings.TrimSpace(str)
 		//fmt.Println(str)
+		if str == "" {
+			return false
+		}
 		if str[0] == '-' || str[0] == '+' {
 			if len(str) == 1 {
 				return false
@@ -126,3 +129,260 @@ func filterPaeth(cdat, pdat []byte, bytesPerPixel int) {
 		}
 	}
 }
+
+// Decode the escape sequences of a literal string, as stored in PdfValue.String
+func decodeLiteralString(s string) []byte {
+	result := make([]byte, 0, len(s))
+
+	for i := 0; i < len(s); i++ {
+		b := s[i]
+		if b != '\\' || i+1 >= len(s) {
+			result = append(result, b)
+			continue
+		}
+
+		i++
+		switch s[i] {
+		case 'n':
+			result = append(result, '\n')
+		case 'r':
+			result = append(result, '\r')
+		case 't':
+			result = append(result, '\t')
+		case 'b':
+			result = append(result, '\b')
+		case 'f':
+			result = append(result, '\f')
+		case '\r':
+			// Line continuation
+			if i+1 < len(s) && s[i+1] == '\n' {
+				i++
+			}
+		case '\n':
+			// Line continuation
+		default:
+			if s[i] >= '0' && s[i] <= '7' {
+				// Octal character code, up to 3 digits
+				n := 0
+				j := 0
+				for ; j < 3 && i+j < len(s) && s[i+j] >= '0' && s[i+j] <= '7'; j++ {
+					n = n*8 + int(s[i+j]-'0')
+				}
+				i += j - 1
+				result = append(result, byte(n))
+			} else {
+				result = append(result, s[i])
+			}
+		}
+	}
+
+	return result
+}
+
+// Decode a hex string, as stored in PdfValue.String
+func decodeHexString(s string) []byte {
+	result := make([]byte, 0, len(s)/2)
+
+	var cur byte
+	half := false
+	for i := 0; i < len(s); i++ {
+		var v byte
+		c := s[i]
+		switch {
+		case c >= '0' && c <= '9':
+			v = c - '0'
+		case c >= 'a' && c <= 'f':
+			v = c - 'a' + 10
+		case c >= 'A' && c <= 'F':
+			v = c - 'A' + 10
+		default:
+			// Skip whitespace
+			continue
+		}
+
+		if half {
+			result = append(result, cur<<4|v)
+		} else {
+			cur = v
+		}
+		half = !half
+	}
+
+	// An odd number of digits is padded with 0
+	if half {
+		result = append(result, cur<<4)
+	}
+
+	return result
+}
+
+// Get the bytes of a string value (literal or hex)
+func stringBytes(value *PdfValue) []byte {
+	if value.Type == PDF_TYPE_HEX {
+		return decodeHexString(value.String)
+	}
+	return decodeLiteralString(value.String)
+}
+
+// Characters of PDFDocEncoding that differ from ISO Latin-1.  0x7f, 0x9f and
+// 0xad are undefined and decode to U+FFFD.
+var pdfDocEncoding = map[byte]rune{
+	0x18: '\u02d8', 0x19: '\u02c7', 0x1a: '\u02c6', 0x1b: '\u02d9',
+	0x1c: '\u02dd', 0x1d: '\u02db', 0x1e: '\u02da', 0x1f: '\u02dc',
+	0x7f: '\ufffd',
+	0x80: '\u2022', 0x81: '\u2020', 0x82: '\u2021', 0x83: '\u2026',
+	0x84: '\u2014', 0x85: '\u2013', 0x86: '\u0192', 0x87: '\u2044',
+	0x88: '\u2039', 0x89: '\u203a', 0x8a: '\u2212', 0x8b: '\u2030',
+	0x8c: '\u201e', 0x8d: '\u201c', 0x8e: '\u201d', 0x8f: '\u2018',
+	0x90: '\u2019', 0x91: '\u201a', 0x92: '\u2122', 0x93: '\ufb01',
+	0x94: '\ufb02', 0x95: '\u0141', 0x96: '\u0152', 0x97: '\u0160',
+	0x98: '\u0178', 0x99: '\u017d', 0x9a: '\u0131', 0x9b: '\u0142',
+	0x9c: '\u0153', 0x9d: '\u0161', 0x9e: '\u017e', 0x9f: '\ufffd',
+	0xa0: '\u20ac', 0xad: '\ufffd',
+}
+
+// Decode a pdf text string into a Go string.  Text strings are UTF-16BE with
+// byte order mark, UTF-8 with byte order mark (PDF 2.0), or PDFDocEncoded.
+// Language escape sequences of UTF-16 strings are removed.
+func decodeTextString(b []byte) string {
+	if len(b) >= 2 && b[0] == 0xfe && b[1] == 0xff {
+		return decodeUTF16Text(b[2:], func(b []byte) uint16 { return uint16(b[0])<<8 | uint16(b[1]) })
+	}
+
+	// Not allowed, but written by some producers
+	if len(b) >= 2 && b[0] == 0xff && b[1] == 0xfe {
+		return decodeUTF16Text(b[2:], func(b []byte) uint16 { return uint16(b[1])<<8 | uint16(b[0]) })
+	}
+
+	if len(b) >= 3 && b[0] == 0xef && b[1] == 0xbb && b[2] == 0xbf {
+		return strings.ToValidUTF8(string(b[3:]), "\ufffd")
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(b))
+	for _, c := range b {
+		if r, ok := pdfDocEncoding[c]; ok {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteRune(rune(c))
+		}
+	}
+	return sb.String()
+}
+
+// Decode UTF-16 code units read with unit, dropping language escape sequences
+// (a language code between two U+001B).  Unpaired surrogates decode to U+FFFD.
+func decodeUTF16Text(b []byte, unit func([]byte) uint16) string {
+	units := make([]uint16, 0, len(b)/2)
+	escape := false
+	for i := 0; i+1 < len(b); i += 2 {
+		u := unit(b[i:])
+		if u == 0x1b {
+			escape = !escape
+			continue
+		}
+		if !escape {
+			units = append(units, u)
+		}
+	}
+
+	return string(utf16.Decode(units))
+}
+
+// Encode a Go string as a pdf text string: a literal string if it is ASCII,
+// otherwise a UTF-16BE hex string with byte order mark
+func encodeTextString(s string) *PdfValue {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			var b strings.Builder
+			b.WriteString("FEFF")
+			for _, r := range utf16.Encode([]rune(s)) {
+				b.WriteString(fmt.Sprintf("%04X", r))
+			}
+			return &PdfValue{Type: PDF_TYPE_HEX, String: b.String()}
+		}
+	}
+
+	return &PdfValue{Type: PDF_TYPE_STRING, String: escapeLiteralString(s)}
+}
+
+// Escape a Go string for use in a literal string
+func escapeLiteralString(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(', ')', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// Decode #xx escapes in a name, e.g. /text#2Fxml becomes /text/xml.  Names
+// are decoded by readToken, so that names that are escaped differently
+// compare equal, and escaped again by the writers with escapeName.
+func decodeName(name string) string {
+	if strings.IndexByte(name, '#') < 0 {
+		return name
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '#' && i+2 < len(name) && is_hex_digit(name[i+1]) && is_hex_digit(name[i+2]) {
+			b.Write(decodeHexString(name[i+1 : i+3]))
+			i += 2
+			continue
+		}
+		b.WriteByte(name[i])
+	}
+	return b.String()
+}
+
+// Escape the bytes of a name that cannot appear in it literally as #xx, e.g.
+// /F Bold becomes /F#20Bold.  Tokens that are not names are returned as is.
+func escapeName(name string) string {
+	if len(name) == 0 || name[0] != '/' {
+		return name
+	}
+
+	i := 1
+	for i < len(name) && !nameNeedsEscape(name[i]) {
+		i++
+	}
+	if i == len(name) {
+		return name
+	}
+
+	var b strings.Builder
+	b.WriteString(name[:i])
+	for ; i < len(name); i++ {
+		c := name[i]
+		if nameNeedsEscape(c) {
+			fmt.Fprintf(&b, "#%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// Bytes that are written as #xx in names: whitespace, delimiters, # and
+// bytes outside the printable ASCII range
+func nameNeedsEscape(c byte) bool {
+	return c < 0x21 || c > 0x7e || c == '#' || isDelimiter(c)
+}
+
+// Get the keys of a dictionary in sorted order
+func sortedKeys(dict map[string]*PdfValue) []string {
+	keys := make([]string, 0, len(dict))
+	for k := range dict {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func is_hex_digit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
@@ -0,0 +1,243 @@
+package gofpdi
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Build a pdf file of objects numbered from 1, with a cross-reference table
+// and a trailer with /Root 1 0 R
+func buildPDF(objects ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+// A stream object with the entries of dict and data, which may be binary
+func testStream(dict string, data string) string {
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data)
+}
+
+// A document with one page of size w x h for each of sizes, drawing a
+// rectangle; objects 1 and 2 are the catalog and the page tree
+func testPages(sizes ...[2]float64) []string {
+	kids := make([]string, len(sizes))
+	objects := []string{"<< /Type /Catalog /Pages 2 0 R >>", ""}
+	for i, size := range sizes {
+		page := len(objects) + 1
+		kids[i] = fmt.Sprintf("%d 0 R", page)
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %g %g] /Contents %d 0 R /Resources << >> >>", size[0], size[1], page+1),
+			testStream("", fmt.Sprintf("0 0 %g %g re f", size[0]/2, size[1]/2)))
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(sizes))
+	return objects
+}
+
+// A document of n pages that share a font and an image, each page with
+// its own text content
+func testDocument(n int) []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		testStream("/Type /XObject /Subtype /Image /Width 2 /Height 2 /ColorSpace /DeviceRGB /BitsPerComponent 8",
+			"\xff\x00\x00\x00\xff\x00\x00\x00\xff\x00\x00\x00"),
+	}
+
+	kids := make([]string, n)
+	for i := 0; i < n; i++ {
+		page := len(objects) + 1
+		kids[i] = fmt.Sprintf("%d 0 R", page)
+		content := fmt.Sprintf("q 100 0 0 100 72 600 cm /Im1 Do Q\nBT /F1 12 Tf 72 720 Td (Page %d of the test document) Tj ET\n"+
+			"0.5 g 72 72 %d 20 re f\n", i+1, 100+i)
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents %d 0 R "+
+				"/Resources << /Font << /F1 3 0 R >> /XObject << /Im1 4 0 R >> >> >>", page+1),
+			testStream("", content))
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), n)
+	return buildPDF(objects...)
+}
+
+// Read a pdf file from memory
+func readTestPDF(t testing.TB, data []byte) *PdfReader {
+	t.Helper()
+
+	reader, err := NewPdfReaderFromStream(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reader
+}
+
+func writeTestFile(t testing.TB, path string, data []byte) {
+	t.Helper()
+
+	err := os.WriteFile(path, data, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Get an importer with a pdf file from memory as its current source
+func newTestImporter(t testing.TB, data []byte) *Importer {
+	t.Helper()
+
+	importer := NewImporter()
+	err := importer.SetSourceReader("test", readTestPDF(t, data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return importer
+}
+
+// Import every page of data into a document and get the document as written
+func importTestDocument(t testing.TB, data []byte, configure func(importer *Importer)) []byte {
+	t.Helper()
+
+	doc := NewDocument()
+	importer := doc.Importer()
+	if configure != nil {
+		configure(importer)
+	}
+	err := importer.SetSourceReader("test", readTestPDF(t, data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pages, err := importer.GetNumPages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for pageno := 1; pageno <= pages; pageno++ {
+		tplid, err := importer.ImportPage(pageno, MediaBox)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = doc.AddTemplatePage(tplid)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	_, err = doc.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// Check that every reference of every object of a pdf file points to an
+// object of the file, and get the number of objects
+func checkReferences(t testing.TB, data []byte) int {
+	t.Helper()
+
+	reader := readTestPDF(t, data)
+	objects := 0
+	for id, gens := range reader.xref {
+		for gen := range gens {
+			if id == 0 {
+				continue
+			}
+			obj, err := reader.resolveObject(&PdfValue{Type: PDF_TYPE_OBJREF, Id: id, Gen: gen})
+			if err != nil {
+				t.Fatalf("object %d %d R: %s", id, gen, err)
+			}
+			objects++
+
+			values := []*PdfValue{obj}
+			for len(values) > 0 {
+				v := values[len(values)-1]
+				values = values[:len(values)-1]
+				if v == nil {
+					continue
+				}
+				switch v.Type {
+				case PDF_TYPE_OBJREF:
+					if _, ok := reader.xref[v.Id][v.Gen]; !ok {
+						t.Errorf("object %d %d R references %d %d R, which does not exist", id, gen, v.Id, v.Gen)
+					}
+				case PDF_TYPE_OBJECT, PDF_TYPE_STREAM:
+					values = append(values, v.Value)
+				case PDF_TYPE_DICTIONARY:
+					for _, entry := range v.Dictionary {
+						values = append(values, entry)
+					}
+				case PDF_TYPE_ARRAY:
+					values = append(values, v.Array...)
+				}
+			}
+		}
+	}
+	return objects
+}
+
+// Get the form xobject drawn by page pageno of a document written by
+// importTestDocument, and a reader of the document
+func outputForm(t testing.TB, data []byte, pageno int) (*PdfReader, *PdfValue) {
+	t.Helper()
+
+	reader := readTestPDF(t, data)
+	resources, err := reader.getPageResources(pageno)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xobjects, err := reader.ResolveKey(resources, "/XObject")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(xobjects.Dictionary) != 1 {
+		t.Fatalf("page %d draws %d xobjects, want 1", pageno, len(xobjects.Dictionary))
+	}
+	for _, ref := range xobjects.Dictionary {
+		form, err := reader.Resolve(ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return reader, form
+	}
+	return nil, nil
+}
+
+// Resolve a path of keys from value, e.g. "/Resources", "/Font", "/F1"
+func resolvePath(t testing.TB, reader *PdfReader, value *PdfValue, keys ...string) *PdfValue {
+	t.Helper()
+
+	for i, key := range keys {
+		var err error
+		value, err = reader.ResolveKey(value, key)
+		if err != nil {
+			t.Fatalf("%s: %s", strings.Join(keys[:i+1], " "), err)
+		}
+	}
+	return value
+}
+
+// Get the decoded data of a stream
+func decodedStream(t testing.TB, reader *PdfReader, stream *PdfValue) []byte {
+	t.Helper()
+
+	data, err := reader.rebuildContentStream(stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
@@ -0,0 +1,181 @@
+package gofpdi
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// Import a PNG or JPEG image file as a template, see ImportImageFromStream
+func (importer *Importer) ImportImage(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return -1, errors.Wrap(err, "Failed to open image")
+	}
+	defer f.Close()
+
+	return importer.ImportImageFromStream(f)
+}
+
+// Import a PNG or JPEG image as a template that can be used like an imported
+// page.  The template is one point per pixel; scale it with UseTemplate.
+// Like SetSourceFile, the image becomes the current source.
+func (importer *Importer) ImportImageFromStream(r io.Reader) (int, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return -1, errors.Wrap(err, "Failed to read image")
+	}
+
+	hasher := sha1.New()
+	hasher.Write(data)
+	source := "image-" + hex.EncodeToString(hasher.Sum(nil))
+
+	err = importer.setSource(source, func() (*PdfReader, error) {
+		pdf, err := imagePdf(data)
+		if err != nil {
+			return nil, err
+		}
+
+		reader, err := NewPdfReaderFromStream(bytes.NewReader(pdf))
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to read image pdf")
+		}
+
+		// Used for object hashes, so it must be unique per image
+		reader.sourceFile = source
+
+		return reader, nil
+	})
+	if err != nil {
+		return -1, err
+	}
+
+	return importer.ImportPage(1, MediaBox)
+}
+
+// Build a single page pdf document that shows an image at one point per pixel
+func imagePdf(data []byte) ([]byte, error) {
+	var imageObj, smaskObj []byte
+	var w, h int
+
+	if len(data) > 2 && data[0] == 0xff && data[1] == 0xd8 {
+		config, err := jpeg.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to decode jpeg")
+		}
+		w, h = config.Width, config.Height
+
+		colorSpace := "/DeviceRGB"
+		decode := ""
+		switch config.ColorModel {
+		case color.GrayModel:
+			colorSpace = "/DeviceGray"
+		case color.CMYKModel:
+			// Adobe writes inverted CMYK jpegs
+			colorSpace = "/DeviceCMYK"
+			decode = " /Decode [1 0 1 0 1 0 1 0]"
+		}
+
+		imageObj = pdfStream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace %s /BitsPerComponent 8%s /Filter /DCTDecode", w, h, colorSpace, decode), data)
+	} else {
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to decode image, only PNG and JPEG are supported")
+		}
+
+		bounds := img.Bounds()
+		w, h = bounds.Dx(), bounds.Dy()
+
+		gray := img.ColorModel() == color.GrayModel || img.ColorModel() == color.Gray16Model
+		components := 3
+		colorSpace := "/DeviceRGB"
+		if gray {
+			components = 1
+			colorSpace = "/DeviceGray"
+		}
+
+		pixels := make([]byte, 0, w*h*components)
+		alpha := make([]byte, 0, w*h)
+		opaque := true
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+				if gray {
+					pixels = append(pixels, c.R)
+				} else {
+					pixels = append(pixels, c.R, c.G, c.B)
+				}
+				alpha = append(alpha, c.A)
+				if c.A != 255 {
+					opaque = false
+				}
+			}
+		}
+
+		smask := ""
+		if !opaque {
+			smask = " /SMask 6 0 R"
+			smaskObj = pdfStream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceGray /BitsPerComponent 8 /Filter /FlateDecode", w, h), deflate(alpha))
+		}
+
+		imageObj = pdfStream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace %s /BitsPerComponent 8 /Filter /FlateDecode%s", w, h, colorSpace, smask), deflate(pixels))
+	}
+
+	if w <= 0 || h <= 0 {
+		return nil, errors.New("Image is empty")
+	}
+
+	objects := [][]byte{
+		[]byte("<< /Type /Catalog /Pages 2 0 R >>"),
+		[]byte("<< /Type /Pages /Kids [3 0 R] /Count 1 >>"),
+		[]byte(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /XObject << /Im0 5 0 R >> >> /Contents 4 0 R >>", w, h)),
+		pdfStream("", []byte(fmt.Sprintf("q %d 0 0 %d 0 0 cm /Im0 Do Q", w, h))),
+		imageObj,
+	}
+	if smaskObj != nil {
+		objects = append(objects, smaskObj)
+	}
+
+	return buildPdf(objects), nil
+}
+
+// Serialize a stream object from the contents of its dictionary and its data
+func pdfStream(dict string, data []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString(fmt.Sprintf("<< %s /Length %d >>\nstream\n", dict, len(data)))
+	b.Write(data)
+	b.WriteString("\nendstream")
+	return b.Bytes()
+}
+
+// Build a pdf document from objects numbered from 1, with object 1 being the catalog
+func buildPdf(objects [][]byte) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = b.Len()
+		b.WriteString(fmt.Sprintf("%d 0 obj\n", i+1))
+		b.Write(obj)
+		b.WriteString("\nendobj\n")
+	}
+
+	xref := b.Len()
+	b.WriteString(fmt.Sprintf("xref\n0 %d\n0000000000 65535 f \n", len(objects)+1))
+	for _, offset := range offsets {
+		b.WriteString(fmt.Sprintf("%010d 00000 n \n", offset))
+	}
+	b.WriteString(fmt.Sprintf("trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref))
+
+	return b.Bytes()
+}
@@ -3,23 +3,51 @@ package gofpdi
 import (
 	"fmt"
 	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
 )
 
 // The Importer class to be used by a pdf generation library
 type Importer struct {
-	sourceFile    string
-	readers       map[string]*PdfReader
-	writers       map[string]*PdfWriter
+	sourceFile string
+	readers    map[string]*PdfReader
+	writers    map[string]*PdfWriter
+	// Sources identical to a source set before, by name, see GetFingerprint
+	sourceAliases map[string]string
 	tplMap        map[int]*TplInfo
 	tplN          int
 	writer        *PdfWriter
 	importedPages map[string]int
+	backendIds    map[string]int
+	outputs       []*outputTarget
+	rasterizer    Rasterizer
+	autoClose     bool
+	// The templates and sources of a resumed checkpoint, see ResumeCheckpoint
+	resumedWriter   *PdfWriter
+	resumedSources  map[string]*checkpointSource
+	sharedStreams   map[string]*PdfObjectId
+	cacheSize       int
+	largeStreamSize int
+	lenient         bool
+	defaultPageSize PageSize
+	limits          Limits
+	spill           *spillStore
+	// Options of the writers of all sources, see setWriterOptions
+	options  writerOptions
+	password string
 }
 
 type TplInfo struct {
 	SourceFile string
 	Writer     *PdfWriter
 	TemplateId int
+	// The box of the page that was imported, which may be another than the
+	// one asked for (see SetBoxFallback); empty for templates that are not
+	// pages
+	Box BoxName
 }
 
 func (importer *Importer) GetReader() *PdfReader {
@@ -31,6 +59,7 @@ func (importer *Importer) GetWriter() *PdfWriter {
 }
 
 func (importer *Importer) GetReaderForFile(file string) *PdfReader {
+	file = importer.canonicalSource(file)
 	if _, ok := importer.readers[file]; ok {
 		return importer.readers[file]
 	}
@@ -39,6 +68,7 @@ func (importer *Importer) GetReaderForFile(file string) *PdfReader {
 }
 
 func (importer *Importer) GetWriterForFile(file string) *PdfWriter {
+	file = importer.canonicalSource(file)
 	if _, ok := importer.writers[file]; ok {
 		return importer.writers[file]
 	}
@@ -59,22 +89,82 @@ func (importer *Importer) init() {
 	importer.tplMap = make(map[int]*TplInfo, 0)
 	importer.writer, _ = NewPdfWriter("")
 	importer.importedPages = make(map[string]int, 0)
+	importer.sourceAliases = make(map[string]string, 0)
+	importer.backendIds = make(map[string]int, 0)
+	importer.rasterizer = nopRasterizer{}
+	importer.sharedStreams = make(map[string]*PdfObjectId, 0)
+	importer.options.k = 1
+	importer.largeStreamSize = defaultLargeStreamSize
+	importer.defaultPageSize = PageSizeLetter
+	importer.spill = &spillStore{}
 }
 
+// Use a file as the current source.  A file with the same content as a
+// source set before, e.g. a temporary copy of it, is imported from that
+// source, see GetFingerprint.
 func (importer *Importer) SetSourceFile(f string) error {
-	importer.sourceFile = f
+	return importer.setSource(f, func() (*PdfReader, error) {
+		return importer.openReader(f)
+	})
+}
+
+func (importer *Importer) SetSourceStream(rs *io.ReadSeeker) error {
+	return importer.setSource(fmt.Sprintf("%v", rs), func() (*PdfReader, error) {
+		return newPdfReaderFromStream(*rs, importer.limits)
+	})
+}
+
+// Use a reader that was opened elsewhere, such as one from a ReaderPool, as
+// the source with the given name.  The reader's cache and stream settings and
+// its limits are kept.
+func (importer *Importer) SetSourceReader(source string, reader *PdfReader) error {
+	if _, ok := importer.readers[source]; !ok {
+		importer.readers[source] = reader
+	}
+	return importer.setSource(source, nil)
+}
+
+// Make source the current source, opening its reader with open if it hasn't been opened yet
+func (importer *Importer) setSource(source string, open func() (*PdfReader, error)) error {
+	importer.sourceFile = importer.canonicalSource(source)
 
 	// If reader hasn't been instantiated, do that now
+	opened := false
 	if _, ok := importer.readers[importer.sourceFile]; !ok {
-		reader, err := NewPdfReader(importer.sourceFile)
+		start := time.Now()
+		reader, err := open()
+		if err != nil {
+			return err
+		}
+		opened = true
+		err = importer.configureReader(importer.sourceFile, reader)
 		if err != nil {
+			reader.Close()
 			return err
 		}
 		importer.readers[importer.sourceFile] = reader
+
+		observeSince(importer.options.metrics, MetricOpenDuration, start)
+		addMetric(importer.options.metrics, MetricSourcesOpened, 1)
+		addMetric(importer.options.metrics, MetricSourceBytes, float64(reader.nBytes))
 	}
 
 	// If writer hasn't been instantiated, do that now
 	if _, ok := importer.writers[importer.sourceFile]; !ok {
+		// A source identical to one set before is imported from that one
+		reader := importer.readers[importer.sourceFile]
+		if importer.aliasSource(importer.sourceFile, reader) {
+			if opened {
+				reader.Close()
+			}
+			delete(importer.readers, importer.sourceFile)
+			importer.sourceFile = importer.canonicalSource(importer.sourceFile)
+			return nil
+		}
+		setMetric(importer.options.metrics, MetricSources, float64(len(importer.readers)))
+
+		reader.warnXFA()
+
 		writer, err := NewPdfWriter("")
 		if err != nil {
 			return err
@@ -82,63 +172,388 @@ func (importer *Importer) SetSourceFile(f string) error {
 
 		// Make the next writer start template numbers at importer.tplN
 		writer.SetTplIdOffset(importer.tplN)
+		writer.shared_streams = importer.sharedStreams
+		writer.spill = importer.spill
+		importer.configureWriter(importer.sourceFile, writer)
+		if state, ok := importer.resumedSources[importer.sourceFile]; ok {
+			writer.resume(reader.hashSource(), state, importer.backendIds)
+			delete(importer.resumedSources, importer.sourceFile)
+		}
 		importer.writers[importer.sourceFile] = writer
 	}
 
 	return nil
 }
 
-func (importer *Importer) SetSourceStream(rs *io.ReadSeeker) error {
-	importer.sourceFile = fmt.Sprintf("%v", rs)
+// Apply the reader options of the importer to a reader of source it opened
+func (importer *Importer) configureReader(source string, reader *PdfReader) error {
+	reader.SetCacheSize(importer.cacheSize)
+	reader.SetLargeStreamSize(importer.largeStreamSize)
+	reader.SetLenient(importer.lenient)
+	reader.SetDefaultPageSize(importer.defaultPageSize)
+	reader.SetMetrics(importer.options.metrics)
+	if importer.password != "" {
+		return importer.decryptReader(source, reader)
+	}
+	return nil
+}
 
-	if _, ok := importer.readers[importer.sourceFile]; !ok {
-		reader, err := NewPdfReaderFromStream(*rs)
-		if err != nil {
-			return err
-		}
-		importer.readers[importer.sourceFile] = reader
+// Apply the writer options of the importer to the writer of source
+func (importer *Importer) configureWriter(source string, writer *PdfWriter) {
+	options := importer.options
+	options.object_filter = importer.sourceObjectFilter(source)
+	options.content_filter = importer.sourceContentFilter(source)
+	writer.setOptions(options)
+}
+
+// Change the writer options of the importer with set, for the writers of the
+// sources set before as well as later ones
+func (importer *Importer) setWriterOptions(set func(options *writerOptions)) {
+	set(&importer.options)
+	for source, writer := range importer.writers {
+		importer.configureWriter(source, writer)
 	}
+}
 
-	// If writer hasn't been instantiated, do that now
-	if _, ok := importer.writers[importer.sourceFile]; !ok {
-		writer, err := NewPdfWriter("")
+// Limit the number of parsed objects each source keeps in memory, see
+// PdfReader.SetCacheSize.  A size of 0 (the default) keeps every object.
+func (importer *Importer) SetCacheSize(size int) {
+	importer.cacheSize = size
+	for _, reader := range importer.readers {
+		reader.SetCacheSize(size)
+	}
+}
+
+// Keep the data of streams longer than size bytes (1 MiB by default) in the
+// sources instead of in memory while they are parsed, see
+// PdfReader.SetLargeStreamSize; written objects still hold their stream data.
+// A size of 0 keeps all streams in memory.
+func (importer *Importer) SetLargeStreamSize(size int) {
+	importer.largeStreamSize = size
+	for _, reader := range importer.readers {
+		reader.SetLargeStreamSize(size)
+	}
+}
+
+// Resolve references to objects that do not exist to null instead of failing
+// the import, see PdfReader.SetLenient.  The references are reported by
+// GetWarnings.
+func (importer *Importer) SetLenient(b bool) {
+	importer.lenient = b
+	for _, reader := range importer.readers {
+		reader.SetLenient(b)
+	}
+}
+
+// Set the size of pages without a valid /MediaBox, see PdfReader.SetDefaultPageSize
+func (importer *Importer) SetDefaultPageSize(size PageSize) {
+	importer.defaultPageSize = size
+	for _, reader := range importer.readers {
+		reader.SetDefaultPageSize(size)
+	}
+}
+
+// Only copy the resources that are used by the content of imported pages,
+// instead of the whole /Resources dictionary.  Applies to pages imported after the call.
+func (importer *Importer) SetPruneResources(b bool) {
+	importer.setWriterOptions(func(options *writerOptions) {
+		options.prune_resources = b
+	})
+}
+
+// Write identical embedded font files (FontFile, FontFile2 and FontFile3 streams)
+// only once, even when they come from different sources.  This avoids copying
+// the same large font repeatedly when merging many documents.
+func (importer *Importer) SetMergeFontFiles(b bool) {
+	importer.setWriterOptions(func(options *writerOptions) {
+		options.merge_font_files = b
+	})
+}
+
+// Write identical ICC profiles of ICCBased color spaces only once, even when
+// they come from different sources, so merged documents keep their color
+// profiles without repeating them for every page and source.
+func (importer *Importer) SetMergeICCProfiles(b bool) {
+	importer.setWriterOptions(func(options *writerOptions) {
+		options.merge_icc_profiles = b
+	})
+}
+
+// Downsample images that are drawn at a higher resolution than dpi, relative to
+// their size on the imported page, and re-encode them as JPEGs of the given
+// quality (1-100, 0 for the default).  Only 8 bit gray and RGB images without
+// masks are converted.  A dpi of 0 disables downsampling.
+func (importer *Importer) SetImageDownsampling(dpi float64, quality int) {
+	importer.setWriterOptions(func(options *writerOptions) {
+		options.downsample_dpi = dpi
+		options.jpeg_quality = quality
+	})
+}
+
+// Convert the device colors of imported pages to a color model: colors set by
+// content streams of pages and form xobjects, and 8 bit images in device color
+// spaces.  Shadings, inline images and ICC based or special color spaces are
+// kept as they are.
+func (importer *Importer) SetColorModel(model ColorModel) {
+	importer.setWriterOptions(func(options *writerOptions) {
+		options.color_model = model
+	})
+}
+
+// Set the scale factor: the number of points per user unit.  Page sizes,
+// template sizes and the coordinates passed to and returned by UseTemplate are
+// in user units.  Applies to pages imported after the call.  The default of 1
+// (points) is what gofpdf and the fpdfadapter package expect, as they apply
+// their own scale factor.
+func (importer *Importer) SetK(k float64) error {
+	if k <= 0 {
+		return errors.New("Scale factor must be positive")
+	}
+
+	importer.setWriterOptions(func(options *writerOptions) {
+		options.k = k
+	})
+	return nil
+}
+
+// Set the scale factor for a user unit: "pt", "mm", "cm" or "in"
+func (importer *Importer) SetUnit(unit string) error {
+	switch unit {
+	case "pt":
+		return importer.SetK(1)
+	case "mm":
+		return importer.SetK(72 / 25.4)
+	case "cm":
+		return importer.SetK(72 / 2.54)
+	case "in", "inch":
+		return importer.SetK(72)
+	}
+	return errors.New("Unknown unit: " + unit)
+}
+
+// Produce the same bytes every time the same pages are imported with the same
+// options, e.g. for golden file tests.  Dictionary keys are written in sorted
+// order (see SetSortKeys), so the numbering of imported objects no longer
+// depends on Go's map iteration order.  gofpdi writes no timestamps or
+// document ids of its own.
+func (importer *Importer) SetReproducible(b bool) {
+	importer.setWriterOptions(func(options *writerOptions) {
+		options.reproducible = b
+	})
+}
+
+// Write the keys of imported dictionaries in sorted order instead of Go's
+// random map order, so the same import produces the same file.  Always on in
+// reproducible mode, see SetReproducible.
+func (importer *Importer) SetSortKeys(b bool) {
+	importer.setWriterOptions(func(options *writerOptions) {
+		options.sort_keys = b
+	})
+}
+
+// Set how imported objects are numbered.  RenumberCompact (the default)
+// numbers them consecutively from the next object id; RenumberPreserve keeps
+// their numbers in the source where they are free and above the next object
+// id, which makes the output easier to compare with the source.  Ids are then
+// not consecutive, see GetNextObjectID for the id after the highest one used.
+func (importer *Importer) SetRenumbering(renumbering Renumbering) {
+	importer.setWriterOptions(func(options *writerOptions) {
+		options.renumbering = renumbering
+	})
+}
+
+// Leave the given keys (with or without the leading slash) out of every
+// dictionary copied from the sources, including the dictionaries of streams,
+// e.g. SetStripKeys(PrivateDataKeys...).  Objects that are only referenced by
+// the stripped entries are not copied either.  Keys that are needed to read
+// the data, such as /Length or /Filter of streams, must not be stripped.
+func (importer *Importer) SetStripKeys(keys ...string) {
+	set := stripKeySet(keys)
+	importer.setWriterOptions(func(options *writerOptions) {
+		options.strip_keys = set
+	})
+}
+
+// Drop the thumbnail images (/Thumb) of pages that are copied along with the
+// imported content, e.g. as the target of a reference, so their streams are
+// not written.  Thumbnails can be large and are of no use in a composed
+// document.
+func (importer *Importer) SetStripThumbnails(b bool) {
+	importer.setWriterOptions(func(options *writerOptions) {
+		options.strip_thumbnails = b
+	})
+}
+
+// Drop the associated files (/AF) of imported pages and of the objects copied
+// with them, e.g. the source data of a chart, which are kept by default: the
+// files of a page are carried on its template, for viewers and PDF/A-3
+// validators to find.  See GetAssociatedFiles.
+func (importer *Importer) SetStripAssociatedFiles(b bool) {
+	importer.setWriterOptions(func(options *writerOptions) {
+		options.strip_af = b
+	})
+}
+
+// Remove metadata that identifies people and tools from everything copied
+// from the sources: document information dictionaries (/Info) and their
+// /Author, /Creator and /Producer entries wherever they occur, XMP metadata
+// streams (/Metadata) and application data (/PieceInfo, /LastModified).
+// Use it when republishing documents must not leak user names or tool
+// versions.  Text and annotations of the imported pages are not changed.
+func (importer *Importer) SetPrivacyScrub(b bool) {
+	importer.setWriterOptions(func(options *writerOptions) {
+		options.scrub_privacy = b
+	})
+}
+
+// Remove written objects that can no longer be reached from the form xobjects
+// of the templates at the end of each PutFormXobjects, e.g. the form xobjects
+// written by earlier calls, which are written again by every call, or objects
+// that a filter (see SetObjectFilter) no longer refers to.  Objects are
+// removed even if GetImportedObjects has returned them before, so collect the
+// objects after the last call.
+func (importer *Importer) SetRemoveUnreachable(b bool) {
+	importer.setWriterOptions(func(options *writerOptions) {
+		options.remove_unreachable = b
+	})
+}
+
+// Flate encode the data of copied streams that have no filter, e.g. content
+// streams and images of sources written without compression.  Form xobjects of
+// templates are always compressed.  XMP metadata is left readable.
+func (importer *Importer) SetCompressStreams(b bool) {
+	importer.setWriterOptions(func(options *writerOptions) {
+		options.compress_streams = b
+	})
+}
+
+// Set a filter that is called for each object copied from any source, e.g.
+// to strip metadata or rewrite URIs, see ObjectFilter.  The source of ref is
+// set; form xobjects created for templates are not passed to the filter.
+func (importer *Importer) SetObjectFilter(filter ObjectFilter) {
+	importer.setWriterOptions(func(options *writerOptions) {
+		options.object_filter = filter
+	})
+}
+
+// Get the object filter for the writer of a source, which fills in the source
+func (importer *Importer) sourceObjectFilter(source string) ObjectFilter {
+	filter := importer.options.object_filter
+	if filter == nil {
+		return nil
+	}
+
+	return func(ref ObjectRef, obj *PdfValue) (*PdfValue, error) {
+		ref.Source = source
+		return filter(ref, obj)
+	}
+}
+
+// Set a filter that is called for each operation of the pages imported from
+// any source, e.g. to remove their text or a logo, see ContentFilter.  The
+// source of page is set.  Pages imported before the call are not filtered.
+func (importer *Importer) SetContentFilter(filter ContentFilter) {
+	importer.setWriterOptions(func(options *writerOptions) {
+		options.content_filter = filter
+	})
+}
+
+// Get the content filter for the writer of a source, which fills in the source
+func (importer *Importer) sourceContentFilter(source string) ContentFilter {
+	filter := importer.options.content_filter
+	if filter == nil {
+		return nil
+	}
+
+	return func(page PageRef, op *ContentOperation) ([]*ContentOperation, error) {
+		page.Source = source
+		return filter(page, op)
+	}
+}
+
+// Embed font files for fonts that pages imported after the call use without
+// embedding them, so the output does not depend on the fonts of the viewer.
+// fonts maps /BaseFont names (e.g. "Helvetica" or "Arial,Bold") to TrueType,
+// OpenType or Type 1 (.pfb) font files, which should have the metrics of the
+// fonts they replace.  Each file is written once.  Only simple fonts that
+// are objects of their own are substituted; their /Widths and /Encoding are
+// kept.
+func (importer *Importer) SetFontSubstitutes(fonts map[string]string) error {
+	substitutes := make(map[string]*fontSubstitute, len(fonts))
+	for name, filename := range fonts {
+		data, err := os.ReadFile(filename)
 		if err != nil {
-			return err
+			return errors.Wrap(err, "Failed to read font file "+filename)
 		}
-
-		// Make the next writer start template numbers at importer.tplN
-		writer.SetTplIdOffset(importer.tplN)
-		importer.writers[importer.sourceFile] = writer
+		font, err := parseFontSubstitute(data)
+		if err != nil {
+			return errors.Wrap(err, "Failed to parse font file "+filename)
+		}
+		substitutes[fontSubstituteName(name)] = font
 	}
 
+	importer.setWriterOptions(func(options *writerOptions) {
+		options.font_substitutes = substitutes
+	})
 	return nil
 }
 
+// Draw printer's marks (crop marks, bleed marks and registration targets)
+// around pages imported after the call, based on their TrimBox and BleedBox.
+// Templates are enlarged by the margin the marks need.  nil draws no marks.
+func (importer *Importer) SetPrinterMarks(marks *PrinterMarks) {
+	importer.setWriterOptions(func(options *writerOptions) {
+		options.printer_marks = marks
+	})
+}
+
+// Set the origin of the coordinates passed to UseTemplate and ClipTemplate.
+// The default is OriginTopLeft.
+func (importer *Importer) SetOrigin(origin Origin) {
+	importer.setWriterOptions(func(options *writerOptions) {
+		options.origin = origin
+	})
+	if importer.resumedWriter != nil {
+		importer.resumedWriter.SetOrigin(origin)
+	}
+}
+
 func (importer *Importer) GetNumPages() (int, error) {
 	return importer.GetReader().getNumPages()
 }
 
 func (importer *Importer) GetPageSizes() (map[int]map[string]map[string]float64, error) {
-	return importer.GetReader().getAllPageBoxes(1.0)
+	return importer.GetReader().getAllPageBoxes(importer.options.k)
 }
 
-func (importer *Importer) ImportPage(pageno int, box string) (int, error) {
-	// If page has already been imported, return existing tplN
-	pageNameNumber := fmt.Sprintf("%s-%04d", importer.sourceFile, pageno)
+// Import page pageno of the current source as a template and get its id.
+// Importing the page again with the same box returns the same template, see
+// FindTemplate.
+func (importer *Importer) ImportPage(pageno int, box BoxName) (int, error) {
+	box, err := box.normalize()
+	if err != nil {
+		return 0, err
+	}
+
+	// If page has already been imported with box, return existing tplN
+	pageNameNumber := pageKey(importer.sourceFile, pageno, box)
 	if _, ok := importer.importedPages[pageNameNumber]; ok {
 		return importer.importedPages[pageNameNumber], nil
 	}
 
+	defer importer.GetWriter().startDeadline()()
+	start := time.Now()
 	res, err := importer.GetWriter().ImportPage(importer.GetReader(), pageno, box)
 	if err != nil {
 		return 0, err
 	}
+	observeSince(importer.options.metrics, MetricImportDuration, start)
 
 	// Get current template id
 	tplN := importer.tplN
 
 	// Set tpl info
-	importer.tplMap[tplN] = &TplInfo{SourceFile: importer.sourceFile, TemplateId: res, Writer: importer.GetWriter()}
+	importer.tplMap[tplN] = &TplInfo{SourceFile: importer.sourceFile, TemplateId: res, Writer: importer.GetWriter(), Box: importer.GetWriter().tpls[res].boxName}
 
 	// Increment template id
 	importer.tplN++
@@ -146,6 +561,9 @@ func (importer *Importer) ImportPage(pageno int, box string) (int, error) {
 	// Cache imported page tplN
 	importer.importedPages[pageNameNumber] = tplN
 
+	addMetric(importer.options.metrics, MetricPagesImported, 1)
+	setMetric(importer.options.metrics, MetricTemplates, float64(importer.tplN))
+
 	return tplN, nil
 }
 
@@ -153,7 +571,49 @@ func (importer *Importer) SetNextObjectID(objId int) {
 	importer.GetWriter().SetNextObjectID(objId)
 }
 
-// Put form xobjects and get back a map of template names (e.g. /GOFPDITPL1) and their object ids (int)
+// Get the number of every new output object from allocator, e.g. the object
+// counter of the pdf library the imported objects are added to, instead of
+// numbering them from SetNextObjectID.  Both sides then never use the same
+// number, however their allocations interleave.  The allocator is shared by
+// all sources, and called from the goroutine that calls PutFormXobjects; it
+// takes precedence over SetRenumbering.  nil restores the default numbering.
+func (importer *Importer) SetObjectIdAllocator(allocator func() int) {
+	importer.setWriterOptions(func(options *writerOptions) {
+		options.id_allocator = allocator
+	})
+}
+
+// Get the id after the highest object id used by the writer of the current source
+func (importer *Importer) GetNextObjectID() int {
+	return importer.GetWriter().GetNextObjectID()
+}
+
+// Get the problems of all sources that did not stop the import but changed its
+// result, e.g. soft masks that could not be resolved and were removed
+func (importer *Importer) GetWarnings() []string {
+	sources := make([]string, 0, len(importer.readers))
+	for source := range importer.readers {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	warnings := make([]string, 0)
+	for _, source := range sources {
+		for _, warning := range importer.readers[source].GetWarnings() {
+			warnings = append(warnings, source+": "+warning)
+		}
+		if writer, ok := importer.writers[source]; ok {
+			for _, warning := range writer.GetWarnings() {
+				warnings = append(warnings, source+": "+warning)
+			}
+		}
+	}
+	return warnings
+}
+
+// Put form xobjects and get back a map of template names (e.g. /GOFPDITPL1) and their object ids (int).
+// It can be called again after importing more pages: templates and objects
+// that have been written are not written again, and the map has all templates.
 func (importer *Importer) PutFormXobjects() (map[string]int, error) {
 	res := make(map[string]int, 0)
 	tplNamesIds, err := importer.GetWriter().PutFormXobjects(importer.GetReader())
@@ -163,6 +623,49 @@ func (importer *Importer) PutFormXobjects() (map[string]int, error) {
 	for tplName, pdfObjId := range tplNamesIds {
 		res[tplName] = pdfObjId.id
 	}
+
+	err = importer.autoCloseSource(importer.sourceFile)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Put the form xobjects of templates ids (returned from ImportPage) only, of
+// any source, and get back a map of their names (e.g. /GOFPDITPL1) and object
+// ids, e.g. to flush only the templates of the current output document when
+// one importer serves several.  The templates a composite template shows
+// must be written before it.
+func (importer *Importer) PutFormXobjectsOnly(ids []int) (map[string]int, error) {
+	// Templates by source, written in the order of ids
+	sources := make([]string, 0)
+	tplIds := make(map[string][]int, 0)
+	for _, tplid := range ids {
+		tplInfo, ok := importer.tplMap[tplid]
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("Template %d does not exist", tplid))
+		}
+		if _, ok := tplIds[tplInfo.SourceFile]; !ok {
+			sources = append(sources, tplInfo.SourceFile)
+		}
+		tplIds[tplInfo.SourceFile] = append(tplIds[tplInfo.SourceFile], tplInfo.TemplateId)
+	}
+
+	res := make(map[string]int, 0)
+	for _, source := range sources {
+		tplNamesIds, err := importer.GetWriterForFile(source).PutFormXobjectsOnly(importer.GetReaderForFile(source), tplIds[source])
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to put form xobjects of "+source)
+		}
+		for tplName, pdfObjId := range tplNamesIds {
+			res[tplName] = pdfObjId.id
+		}
+
+		err = importer.autoCloseSource(source)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return res, nil
 }
 
@@ -177,6 +680,11 @@ func (importer *Importer) PutFormXobjectsUnordered() (map[string]string, error)
 	for tplName, pdfObjId := range tplNamesIds {
 		res[tplName] = pdfObjId.hash
 	}
+
+	err = importer.autoCloseSource(importer.sourceFile)
+	if err != nil {
+		return nil, err
+	}
 	return res, nil
 }
 
@@ -190,9 +698,29 @@ func (importer *Importer) GetImportedObjects() map[int]string {
 	return res
 }
 
+// Clear the imported objects of one source (see SetSourceFile), e.g. once
+// they have been written out, keeping those of the other sources.  Cleared
+// objects are not written again when more pages of the source are imported.
+func (importer *Importer) ClearImportedObjectsFrom(source string) error {
+	writer, ok := importer.writers[source]
+	if !ok {
+		return errors.New("Unknown source: " + source)
+	}
+
+	writer.ClearImportedObjectsFrom(importer.readers[source])
+	return nil
+}
+
+// Get the imported objects sorted by object id, e.g. to write them in order
+// without sorting the map returned by GetImportedObjects
+func (importer *Importer) GetImportedObjectsOrdered() []ImportedObject {
+	return importer.GetWriter().GetImportedObjectsOrdered()
+}
+
 // Get object ids (sha1 hash) and their contents ([]byte)
 // The contents may have references to other object hashes which will need to be replaced by the pdf generator library
-// The positions of the hashes (sha1 - 40 characters) can be obtained by calling GetImportedObjHashPos()
+// The positions of the hashes (sha1 - 40 characters) can be obtained by calling GetImportedObjHashPos(),
+// see PlaceholderVersion for their format
 func (importer *Importer) GetImportedObjectsUnordered() map[string][]byte {
 	res := make(map[string][]byte, 0)
 	pdfObjIdBytes := importer.GetWriter().GetImportedObjects()
@@ -215,8 +743,56 @@ func (importer *Importer) GetImportedObjHashPos() map[string]map[int]string {
 
 // For a given template id (returned from ImportPage), get the template name (e.g. /GOFPDITPL1) and
 // the 4 float64 values necessary to draw the template a x,y for a given width and height.
+// The /Rotate of the page is part of the form xobject, so rotated pages are
+// placed like any other, upright with their displayed size.  See
+// UseTemplateUnits for the units of the values.
 func (importer *Importer) UseTemplate(tplid int, _x float64, _y float64, _w float64, _h float64) (string, float64, float64, float64, float64) {
 	// Look up template id in importer tpl map
 	tplInfo := importer.tplMap[tplid]
 	return tplInfo.Writer.UseTemplate(tplInfo.TemplateId, _x, _y, _w, _h)
 }
+
+// Get the size of template tplid (returned from ImportPage) when it is drawn
+// with width w and height h.  If one of them is 0, it is calculated from the
+// other keeping the aspect ratio; if both are 0, the template's own size is returned.
+func (importer *Importer) GetTemplateSize(tplid int, w float64, h float64) (float64, float64, error) {
+	tplInfo, ok := importer.tplMap[tplid]
+	if !ok {
+		return 0, 0, errors.New(fmt.Sprintf("Template %d does not exist", tplid))
+	}
+
+	size := tplInfo.Writer.getTemplateSize(tplInfo.TemplateId, w, h)
+	return size["w"], size["h"], nil
+}
+
+// Create a template that shows only part of template tplid (returned from ImportPage).
+// x and y are the offset of the part from the top left corner of the template
+// (see SetOrigin), w and h its size.  The source content is not modified; the new template,
+// used like any other, is clipped by its bounding box.
+func (importer *Importer) ClipTemplate(tplid int, x float64, y float64, w float64, h float64) (int, error) {
+	tplInfo, ok := importer.tplMap[tplid]
+	if !ok {
+		return -1, errors.New(fmt.Sprintf("Template %d does not exist", tplid))
+	}
+
+	res, err := tplInfo.Writer.ClipTemplate(tplInfo.TemplateId, x, y, w, h)
+	if err != nil {
+		return -1, err
+	}
+
+	tplN := importer.tplN
+	importer.tplMap[tplN] = &TplInfo{SourceFile: tplInfo.SourceFile, TemplateId: res, Writer: tplInfo.Writer}
+	importer.tplN++
+
+	return tplN, nil
+}
+
+// Get the template for a template id (returned from ImportPage)
+func (importer *Importer) getTemplate(tplid int) (*PdfTemplate, error) {
+	tplInfo, ok := importer.tplMap[tplid]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("Template %d does not exist", tplid))
+	}
+
+	return tplInfo.Writer.tpls[tplInfo.TemplateId], nil
+}
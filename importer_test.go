@@ -0,0 +1,127 @@
+package gofpdi
+
+import (
+	"math"
+	"testing"
+)
+
+// Each template is sized and placed by its own page box, whichever template
+// was imported first
+func TestTemplateSizes(t *testing.T) {
+	importer := newTestImporter(t, buildPDF(testPages([2]float64{612, 792}, [2]float64{300, 200})...))
+
+	// The small page first
+	small, err := importer.ImportPage(2, MediaBox)
+	if err != nil {
+		t.Fatal(err)
+	}
+	large, err := importer.ImportPage(1, MediaBox)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		tplid        int
+		w, h         float64
+		wantW, wantH float64
+	}{
+		{small, 0, 0, 300, 200},
+		{small, 150, 0, 150, 100},
+		{small, 0, 50, 75, 50},
+		{large, 0, 0, 612, 792},
+		{large, 306, 0, 306, 396},
+		{large, 0, 396, 306, 396},
+	} {
+		w, h, err := importer.GetTemplateSize(test.tplid, test.w, test.h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !nearly(w, test.wantW) || !nearly(h, test.wantH) {
+			t.Errorf("template %d at %g x %g is %g x %g, want %g x %g", test.tplid, test.w, test.h, w, h, test.wantW, test.wantH)
+		}
+
+		_, scaleX, scaleY, tx, ty := importer.UseTemplate(test.tplid, 10, 20, test.w, test.h)
+		wantScale := test.wantW / map[int]float64{small: 300, large: 612}[test.tplid]
+		if !nearly(scaleX, wantScale) || !nearly(scaleY, wantScale) {
+			t.Errorf("template %d at %g x %g is scaled by %g, %g, want %g", test.tplid, test.w, test.h, scaleX, scaleY, wantScale)
+		}
+		// The translation is relative to the top of the page, see OriginTopLeft
+		if !nearly(tx, 10) || !nearly(ty, -20-test.wantH) {
+			t.Errorf("template %d at %g x %g is translated by %g, %g, want 10, %g", test.tplid, test.w, test.h, tx, ty, -20-test.wantH)
+		}
+	}
+
+	if _, _, err := importer.GetTemplateSize(large+1, 0, 0); err == nil {
+		t.Error("size of a template that does not exist")
+	}
+}
+
+// A clipped template is not drawn when the template it clips is, and is
+// skipped if it is not drawn itself
+func TestClipUnusedTemplate(t *testing.T) {
+	importer := newTestImporter(t, testDocument(1))
+	importer.SetSkipUnusedTemplates(true)
+	tplid, err := importer.ImportPage(1, MediaBox)
+	if err != nil {
+		t.Fatal(err)
+	}
+	importer.UseTemplate(tplid, 0, 0, 612, 792)
+
+	clipped, err := importer.ClipTemplate(tplid, 0, 0, 306, 396)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unused := importer.GetUnusedTemplates(); len(unused) != 1 || unused[0] != clipped {
+		t.Fatalf("unused templates are %v, want [%d]", unused, clipped)
+	}
+	forms, err := importer.PutFormXobjects()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(forms) != 1 {
+		t.Errorf("put %d form xobjects, want 1: %v", len(forms), forms)
+	}
+
+	importer.UseTemplate(clipped, 0, 0, 306, 396)
+	if unused := importer.GetUnusedTemplates(); len(unused) != 0 {
+		t.Errorf("unused templates are %v after drawing the clipped one", unused)
+	}
+}
+
+// Writer options apply to the writers of the sources set before and after,
+// and the filters of each writer fill in its source
+func TestWriterOptions(t *testing.T) {
+	importer := newTestImporter(t, testDocument(1))
+	sources := make([]string, 0)
+	importer.SetObjectFilter(func(ref ObjectRef, obj *PdfValue) (*PdfValue, error) {
+		sources = append(sources, ref.Source)
+		return obj, nil
+	})
+	importer.SetObjectStats(true)
+	if err := importer.SetSourceReader("other", readTestPDF(t, testDocument(2))); err != nil {
+		t.Fatal(err)
+	}
+	importer.SetCompressStreams(true)
+
+	for source, writer := range importer.writers {
+		if !writer.compress_streams || !writer.record_object_stats || writer.object_stats == nil {
+			t.Errorf("options of the writer of %s are %+v", source, writer.writerOptions)
+		}
+		sources = sources[:0]
+		if _, err := writer.object_filter(ObjectRef{Id: 1}, &PdfValue{Type: PDF_TYPE_NULL}); err != nil {
+			t.Fatal(err)
+		}
+		if len(sources) != 1 || sources[0] != source {
+			t.Errorf("filter of the writer of %s got sources %v", source, sources)
+		}
+	}
+
+	importer.SetObjectStats(false)
+	if writer := importer.GetWriter(); writer.record_object_stats || writer.object_stats != nil {
+		t.Error("object stats are still recorded")
+	}
+}
+
+func nearly(a float64, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
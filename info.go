@@ -0,0 +1,204 @@
+package gofpdi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// The document information dictionary (/Info) of a source
+type DocumentInfo struct {
+	Title    string
+	Author   string
+	Subject  string
+	Keywords string
+	Creator  string
+	Producer string
+	// Dates in pdf date syntax, e.g. D:20240102150405+01'00'
+	CreationDate string
+	ModDate      string
+	// CreationDate and ModDate parsed with ParseDate, zero if they are
+	// missing or invalid
+	Created  time.Time
+	Modified time.Time
+	// True, False or Unknown
+	Trapped string
+	// Other entries, by key without the leading slash
+	Custom map[string]string
+}
+
+// Get the text of an information dictionary entry
+func infoText(value *PdfValue) string {
+	switch value.Type {
+	case PDF_TYPE_STRING, PDF_TYPE_HEX:
+		return decodeTextString(stringBytes(value))
+	case PDF_TYPE_TOKEN:
+		return strings.TrimPrefix(decodeName(value.Token), "/")
+	}
+	return ""
+}
+
+// Read the document information dictionary
+func (pdfReader *PdfReader) getInfo() (*DocumentInfo, error) {
+	info := &DocumentInfo{Custom: make(map[string]string, 0)}
+
+	if pdfReader.trailer == nil {
+		return info, nil
+	}
+
+	dict, err := pdfReader.resolveDirect(pdfReader.trailer.Dictionary["/Info"])
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to resolve /Info")
+	}
+	if dict == nil || dict.Type != PDF_TYPE_DICTIONARY {
+		return info, nil
+	}
+
+	fields := map[string]*string{
+		"/Title":        &info.Title,
+		"/Author":       &info.Author,
+		"/Subject":      &info.Subject,
+		"/Keywords":     &info.Keywords,
+		"/Creator":      &info.Creator,
+		"/Producer":     &info.Producer,
+		"/CreationDate": &info.CreationDate,
+		"/ModDate":      &info.ModDate,
+		"/Trapped":      &info.Trapped,
+	}
+
+	for key, value := range dict.Dictionary {
+		value, err := pdfReader.resolveDirect(value)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to resolve "+key)
+		}
+		if value == nil {
+			continue
+		}
+
+		if field, ok := fields[key]; ok {
+			*field = infoText(value)
+		} else {
+			info.Custom[strings.TrimPrefix(decodeName(key), "/")] = infoText(value)
+		}
+	}
+
+	// Invalid dates are left zero
+	info.Created, _ = ParseDate(info.CreationDate)
+	info.Modified, _ = ParseDate(info.ModDate)
+
+	return info, nil
+}
+
+// Parse a date in pdf date syntax, D:YYYYMMDDHHmmSSOHH'mm', where all fields
+// after the year are optional and O is +, - or Z.  Dates without time zone
+// are returned in UTC.  The D: prefix and the apostrophes may be missing, as
+// written by some producers.
+func ParseDate(s string) (time.Time, error) {
+	d := strings.TrimSpace(s)
+	d = strings.TrimPrefix(d, "D:")
+
+	// Read the digits of the date and time
+	i := 0
+	for i < len(d) && d[i] >= '0' && d[i] <= '9' {
+		i++
+	}
+	digits := d[:i]
+	zone := d[i:]
+
+	if len(digits) < 4 || len(digits) > 14 || len(digits)%2 != 0 {
+		return time.Time{}, errors.New(fmt.Sprintf("Invalid date: %q", s))
+	}
+
+	// year, month, day, hour, minute, second
+	fields := []int{0, 1, 1, 0, 0, 0}
+	fields[0], _ = strconv.Atoi(digits[:4])
+	for j := 1; 4+2*j <= len(digits); j++ {
+		fields[j], _ = strconv.Atoi(digits[2+2*j : 4+2*j])
+	}
+	if fields[1] < 1 || fields[1] > 12 || fields[2] < 1 || fields[2] > 31 ||
+		fields[3] > 23 || fields[4] > 59 || fields[5] > 59 {
+		return time.Time{}, errors.New(fmt.Sprintf("Invalid date: %q", s))
+	}
+
+	loc, err := parseDateZone(zone)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, fmt.Sprintf("Invalid date: %q", s))
+	}
+
+	t := time.Date(fields[0], time.Month(fields[1]), fields[2], fields[3], fields[4], fields[5], 0, loc)
+	if t.Day() != fields[2] {
+		return time.Time{}, errors.New(fmt.Sprintf("Invalid date: %q", s))
+	}
+
+	return t, nil
+}
+
+// Format a time in pdf date syntax, e.g. D:20240102150405+01'00'
+func FormatDate(t time.Time) string {
+	_, offset := t.Zone()
+	if offset == 0 {
+		return t.Format("D:20060102150405Z")
+	}
+
+	sign := '+'
+	if offset < 0 {
+		sign = '-'
+		offset = -offset
+	}
+	return fmt.Sprintf("%s%c%02d'%02d'", t.Format("D:20060102150405"), sign, offset/3600, offset/60%60)
+}
+
+// Parse the time zone of a pdf date: Z, or + or - followed by HH'mm'
+func parseDateZone(zone string) (*time.Location, error) {
+	if zone == "" {
+		return time.UTC, nil
+	}
+
+	sign := 1
+	switch zone[0] {
+	case 'Z':
+		// Some producers write Z00'00'
+		return time.UTC, nil
+	case '+':
+	case '-':
+		sign = -1
+	default:
+		return nil, errors.New("Invalid time zone")
+	}
+
+	parts := strings.Split(strings.TrimSuffix(zone[1:], "'"), "'")
+	if len(parts) == 1 && len(parts[0]) == 4 {
+		// HHmm
+		parts = []string{parts[0][:2], parts[0][2:]}
+	}
+	if len(parts) > 2 || len(parts[0]) != 2 || (len(parts) == 2 && len(parts[1]) != 2) {
+		return nil, errors.New("Invalid time zone")
+	}
+
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil || hours < 0 || hours > 23 {
+		return nil, errors.New("Invalid time zone")
+	}
+	minutes := 0
+	if len(parts) == 2 {
+		minutes, err = strconv.Atoi(parts[1])
+		if err != nil || minutes < 0 || minutes > 59 {
+			return nil, errors.New("Invalid time zone")
+		}
+	}
+
+	offset := sign * (hours*3600 + minutes*60)
+	if offset == 0 {
+		return time.UTC, nil
+	}
+
+	return time.FixedZone("", offset), nil
+}
+
+// Get the document information of the current source
+func (importer *Importer) GetInfo() (*DocumentInfo, error) {
+	return importer.GetReader().getInfo()
+}
@@ -0,0 +1,96 @@
+package gofpdi
+
+import (
+	"testing"
+	"time"
+)
+
+// Dates are parsed with the optional fields, prefix and apostrophes missing,
+// and with each kind of time zone
+func TestParseDate(t *testing.T) {
+	for _, test := range []struct {
+		date string
+		want string
+	}{
+		{"D:2024", "2024-01-01T00:00:00Z"},
+		{"D:202403", "2024-03-01T00:00:00Z"},
+		{"D:20240305", "2024-03-05T00:00:00Z"},
+		{"D:2024030515", "2024-03-05T15:00:00Z"},
+		{"D:20240305150405", "2024-03-05T15:04:05Z"},
+		{"D:20240305150405Z", "2024-03-05T15:04:05Z"},
+		{"D:20240305150405Z00'00'", "2024-03-05T15:04:05Z"},
+		{"D:20240305150405+01'00'", "2024-03-05T15:04:05+01:00"},
+		{"D:20240305150405+05'30", "2024-03-05T15:04:05+05:30"},
+		{"D:20240305150405-08'00'", "2024-03-05T15:04:05-08:00"},
+		{"D:20240305150405-03'30", "2024-03-05T15:04:05-03:30"},
+		{"D:20240305150405+0130", "2024-03-05T15:04:05+01:30"},
+		{"D:20240305150405-04", "2024-03-05T15:04:05-04:00"},
+		{"D:20240305150405+00'00'", "2024-03-05T15:04:05Z"},
+		{"20240305150405+01'00'", "2024-03-05T15:04:05+01:00"},
+		{"2024", "2024-01-01T00:00:00Z"},
+		{" D:20240229 ", "2024-02-29T00:00:00Z"},
+	} {
+		got, err := ParseDate(test.date)
+		if err != nil {
+			t.Errorf("%q: %s", test.date, err)
+			continue
+		}
+		if s := got.Format(time.RFC3339); s != test.want {
+			t.Errorf("%q is %s, want %s", test.date, s, test.want)
+		}
+	}
+}
+
+// Malformed dates are rejected
+func TestParseDateInvalid(t *testing.T) {
+	for _, date := range []string{
+		"",
+		"D:",
+		"D:24",
+		"D:20241",
+		"D:2024010203040506",
+		"D:20241301",
+		"D:20240001",
+		"D:20240132",
+		"D:20230229",
+		"D:20240102250000",
+		"D:20240102126000",
+		"D:20240102120060",
+		"D:20240102120000X",
+		"D:20240102120000+1'00'",
+		"D:20240102120000+24'00'",
+		"D:20240102120000+01'60'",
+		"D:20240102120000+-1'00'",
+		"D:20240102120000+01'-1'",
+		"D:20240102120000+01'00'00'",
+		"Monday",
+	} {
+		if got, err := ParseDate(date); err == nil {
+			t.Errorf("%q is parsed as %s, want an error", date, got)
+		}
+	}
+}
+
+// Formatted dates are parsed back as the same time
+func TestFormatDate(t *testing.T) {
+	for _, test := range []struct {
+		time time.Time
+		want string
+	}{
+		{time.Date(2024, 3, 5, 15, 4, 5, 0, time.UTC), "D:20240305150405Z"},
+		{time.Date(2024, 3, 5, 15, 4, 5, 0, time.FixedZone("", 5*3600+30*60)), "D:20240305150405+05'30'"},
+		{time.Date(2024, 3, 5, 15, 4, 5, 0, time.FixedZone("", -8*3600)), "D:20240305150405-08'00'"},
+	} {
+		date := FormatDate(test.time)
+		if date != test.want {
+			t.Errorf("%s is formatted as %q, want %q", test.time, date, test.want)
+		}
+		back, err := ParseDate(date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !back.Equal(test.time) {
+			t.Errorf("%q is parsed as %s, want %s", date, back, test.time)
+		}
+	}
+}
@@ -0,0 +1,745 @@
+package gofpdi
+
+import (
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// Maximum nesting depth of form xobjects followed by the content interpreter
+const maxFormDepth = 16
+
+// A point in device space
+type point struct {
+	x float64
+	y float64
+}
+
+// A 2d affine transformation matrix [a b c d e f]
+type matrix [6]float64
+
+var identityMatrix = matrix{1, 0, 0, 1, 0, 0}
+
+// Concatenate two matrices: the result applies m first, then n
+func (m matrix) multiply(n matrix) matrix {
+	return matrix{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+// Transform a point
+func (m matrix) transform(x float64, y float64) point {
+	return point{m[0]*x + m[2]*y + m[4], m[1]*x + m[3]*y + m[5]}
+}
+
+// Get the factor by which m scales lengths (e.g. line widths)
+func (m matrix) scale() float64 {
+	return math.Sqrt(math.Abs(m[0]*m[3] - m[1]*m[2]))
+}
+
+// Get the inverse of m, or the identity if m is not invertible
+func (m matrix) inverse() matrix {
+	det := m[0]*m[3] - m[1]*m[2]
+	if det == 0 {
+		return identityMatrix
+	}
+	return matrix{
+		m[3] / det,
+		-m[1] / det,
+		-m[2] / det,
+		m[0] / det,
+		(m[2]*m[5] - m[3]*m[4]) / det,
+		(m[1]*m[4] - m[0]*m[5]) / det,
+	}
+}
+
+// Get a matrix from the first 6 numbers of a slice of values
+func matrixOf(values []*PdfValue) matrix {
+	m := identityMatrix
+	for i := 0; i < 6 && i < len(values); i++ {
+		m[i] = values[i].Real
+	}
+	return m
+}
+
+// A segment of a path in device space
+type pathSegment struct {
+	op  byte // 'm', 'l', 'c' or 'h'
+	pts []point
+}
+
+// A clipping path in device space
+type clipPath struct {
+	path    []pathSegment
+	evenOdd bool
+}
+
+// Get the device space bounding box of a path (llx, lly, urx, ury)
+func pathBounds(path []pathSegment) ([4]float64, bool) {
+	box := [4]float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	found := false
+	for _, seg := range path {
+		for _, p := range seg.pts {
+			box[0] = math.Min(box[0], p.x)
+			box[1] = math.Min(box[1], p.y)
+			box[2] = math.Max(box[2], p.x)
+			box[3] = math.Max(box[3], p.y)
+			found = true
+		}
+	}
+	return box, found
+}
+
+// A font as far as the interpreter needs it: for computing glyph advances
+type contentFont struct {
+	dict         *PdfValue
+	twoByte      bool
+	firstChar    int
+	widths       []float64
+	cidWidths    map[int]float64
+	defaultWidth float64
+}
+
+// Get the width of a character code in thousandths of text space units
+func (font *contentFont) width(code int) float64 {
+	if font.twoByte {
+		if w, ok := font.cidWidths[code]; ok {
+			return w
+		}
+		return font.defaultWidth
+	}
+
+	i := code - font.firstChar
+	if i >= 0 && i < len(font.widths) {
+		return font.widths[i]
+	}
+	return font.defaultWidth
+}
+
+// Split a string into character codes
+func (font *contentFont) codes(s []byte) []int {
+	codes := make([]int, 0, len(s))
+	if font.twoByte {
+		for i := 0; i+1 < len(s); i += 2 {
+			codes = append(codes, int(s[i])<<8|int(s[i+1]))
+		}
+	} else {
+		for _, b := range s {
+			codes = append(codes, int(b))
+		}
+	}
+	return codes
+}
+
+// The parts of the pdf graphics state tracked by the interpreter
+type graphicsState struct {
+	ctm         matrix
+	lineWidth   float64
+	fillSpace   string
+	fillColor   []float64
+	strokeSpace string
+	strokeColor []float64
+	fillAlpha   float64
+	strokeAlpha float64
+	softMask    bool
+	blend       bool
+	clips       []*clipPath
+
+	// Text state
+	font        *contentFont
+	fontSize    float64
+	charSpacing float64
+	wordSpacing float64
+	hScale      float64
+	leading     float64
+	rise        float64
+	render      int
+}
+
+func newGraphicsState(ctm matrix) *graphicsState {
+	return &graphicsState{
+		ctm:         ctm,
+		lineWidth:   1,
+		fillSpace:   "/DeviceGray",
+		fillColor:   []float64{0},
+		strokeSpace: "/DeviceGray",
+		strokeColor: []float64{0},
+		fillAlpha:   1,
+		strokeAlpha: 1,
+		hScale:      100,
+	}
+}
+
+func (gs *graphicsState) clone() *graphicsState {
+	c := *gs
+	c.clips = append([]*clipPath(nil), gs.clips...)
+	return &c
+}
+
+// A string shown by a text operator
+type textRun struct {
+	font     *contentFont
+	codes    []int
+	text     []byte
+	start    matrix // text space to device space at the start of the run
+	fontSize float64
+	hScale   float64
+	rise     float64
+	width    float64 // advance in text space
+}
+
+// Get the matrix mapping glyph space (scaled to 1 unit per em) to device space
+func (run *textRun) glyphMatrix() matrix {
+	return matrix{run.fontSize * run.hScale / 100, 0, 0, run.fontSize, 0, run.rise}.multiply(run.start)
+}
+
+// Get the approximate device space corners of the run
+func (run *textRun) corners() [4]point {
+	lo := run.rise - 0.2*run.fontSize
+	hi := run.rise + 0.8*run.fontSize
+	return [4]point{
+		run.start.transform(0, lo),
+		run.start.transform(run.width, lo),
+		run.start.transform(run.width, hi),
+		run.start.transform(0, hi),
+	}
+}
+
+// Receives the drawing operations found by a contentInterpreter
+type contentHandler interface {
+	// A path has been painted with a painting operator (S, f, B, n, ...)
+	paintPath(it *contentInterpreter, path []pathSegment, op string)
+
+	// A string has been shown
+	showText(it *contentInterpreter, run *textRun)
+
+	// An image xobject (image set) or an inline image (inline set) has been drawn into the unit square
+	drawImage(it *contentInterpreter, image *PdfValue, inline *ContentOperation)
+
+	// A shading has been painted with the sh operator
+	paintShading(it *contentInterpreter, shading *PdfValue)
+}
+
+// Interprets content streams, keeping track of the graphics state
+type contentInterpreter struct {
+	reader      *PdfReader
+	handler     contentHandler
+	resources   *PdfValue
+	gs          *graphicsState
+	stack       []*graphicsState
+	path        []pathSegment
+	current     point
+	start       point
+	pendingClip int
+	tm          matrix
+	tlm         matrix
+	fonts       map[*PdfValue]map[string]*contentFont
+	forms       map[int]bool
+	depth       int
+}
+
+func newContentInterpreter(reader *PdfReader, resources *PdfValue, ctm matrix, handler contentHandler) *contentInterpreter {
+	return &contentInterpreter{
+		reader:    reader,
+		handler:   handler,
+		resources: resources,
+		gs:        newGraphicsState(ctm),
+		fonts:     make(map[*PdfValue]map[string]*contentFont, 0),
+		forms:     make(map[int]bool, 0),
+	}
+}
+
+// Interpret a decoded content stream
+func (it *contentInterpreter) run(content []byte) error {
+	ops, err := ParseContentStream(content)
+	if err != nil {
+		return errors.Wrap(err, "Failed to parse content stream")
+	}
+
+	return it.runOperations(ops)
+}
+
+// Get a number operand, or 0 if it is missing
+func operand(op *ContentOperation, i int) float64 {
+	if i < len(op.Operands) {
+		return op.Operands[i].Real
+	}
+	return 0
+}
+
+// Get the numbers of all operands
+func numericOperands(op *ContentOperation) []float64 {
+	result := make([]float64, 0, len(op.Operands))
+	for _, v := range op.Operands {
+		if v.Type == PDF_TYPE_NUMERIC || v.Type == PDF_TYPE_REAL {
+			result = append(result, v.Real)
+		}
+	}
+	return result
+}
+
+// Get the name of the last operand, or "" if it is not a name
+func nameOperand(op *ContentOperation) string {
+	if len(op.Operands) == 0 {
+		return ""
+	}
+	v := op.Operands[len(op.Operands)-1]
+	if v.Type == PDF_TYPE_TOKEN {
+		return v.Token
+	}
+	return ""
+}
+
+func (it *contentInterpreter) runOperations(ops []*ContentOperation) error {
+	for _, op := range ops {
+		gs := it.gs
+
+		switch op.Operator {
+		case "q":
+			it.stack = append(it.stack, gs.clone())
+		case "Q":
+			if len(it.stack) > 0 {
+				it.gs = it.stack[len(it.stack)-1]
+				it.stack = it.stack[:len(it.stack)-1]
+			}
+		case "cm":
+			gs.ctm = matrixOf(op.Operands).multiply(gs.ctm)
+		case "w":
+			gs.lineWidth = operand(op, 0)
+
+		// Path construction
+		case "m":
+			it.current = gs.ctm.transform(operand(op, 0), operand(op, 1))
+			it.start = it.current
+			it.path = append(it.path, pathSegment{'m', []point{it.current}})
+		case "l":
+			it.current = gs.ctm.transform(operand(op, 0), operand(op, 1))
+			it.path = append(it.path, pathSegment{'l', []point{it.current}})
+		case "c":
+			p1 := gs.ctm.transform(operand(op, 0), operand(op, 1))
+			p2 := gs.ctm.transform(operand(op, 2), operand(op, 3))
+			p3 := gs.ctm.transform(operand(op, 4), operand(op, 5))
+			it.path = append(it.path, pathSegment{'c', []point{p1, p2, p3}})
+			it.current = p3
+		case "v":
+			p2 := gs.ctm.transform(operand(op, 0), operand(op, 1))
+			p3 := gs.ctm.transform(operand(op, 2), operand(op, 3))
+			it.path = append(it.path, pathSegment{'c', []point{it.current, p2, p3}})
+			it.current = p3
+		case "y":
+			p1 := gs.ctm.transform(operand(op, 0), operand(op, 1))
+			p3 := gs.ctm.transform(operand(op, 2), operand(op, 3))
+			it.path = append(it.path, pathSegment{'c', []point{p1, p3, p3}})
+			it.current = p3
+		case "h":
+			it.path = append(it.path, pathSegment{'h', nil})
+			it.current = it.start
+		case "re":
+			x, y, w, h := operand(op, 0), operand(op, 1), operand(op, 2), operand(op, 3)
+			it.start = gs.ctm.transform(x, y)
+			it.current = it.start
+			it.path = append(it.path,
+				pathSegment{'m', []point{it.start}},
+				pathSegment{'l', []point{gs.ctm.transform(x+w, y)}},
+				pathSegment{'l', []point{gs.ctm.transform(x+w, y+h)}},
+				pathSegment{'l', []point{gs.ctm.transform(x, y+h)}},
+				pathSegment{'h', nil})
+
+		// Path painting
+		case "s", "b", "b*":
+			it.path = append(it.path, pathSegment{'h', nil})
+			it.paintPath(op.Operator)
+		case "S", "f", "F", "f*", "B", "B*", "n":
+			it.paintPath(op.Operator)
+		case "W":
+			it.pendingClip = 1
+		case "W*":
+			it.pendingClip = 2
+
+		// Colors
+		case "g":
+			gs.fillSpace, gs.fillColor = "/DeviceGray", numericOperands(op)
+		case "G":
+			gs.strokeSpace, gs.strokeColor = "/DeviceGray", numericOperands(op)
+		case "rg":
+			gs.fillSpace, gs.fillColor = "/DeviceRGB", numericOperands(op)
+		case "RG":
+			gs.strokeSpace, gs.strokeColor = "/DeviceRGB", numericOperands(op)
+		case "k":
+			gs.fillSpace, gs.fillColor = "/DeviceCMYK", numericOperands(op)
+		case "K":
+			gs.strokeSpace, gs.strokeColor = "/DeviceCMYK", numericOperands(op)
+		case "cs":
+			gs.fillSpace, gs.fillColor = it.colorSpaceFamily(nameOperand(op)), []float64{0}
+		case "CS":
+			gs.strokeSpace, gs.strokeColor = it.colorSpaceFamily(nameOperand(op)), []float64{0}
+		case "sc", "scn":
+			gs.fillColor = numericOperands(op)
+		case "SC", "SCN":
+			gs.strokeColor = numericOperands(op)
+		case "gs":
+			it.setExtGState(nameOperand(op))
+
+		// Text
+		case "BT":
+			it.tm = identityMatrix
+			it.tlm = identityMatrix
+		case "Tf":
+			if len(op.Operands) >= 2 {
+				gs.font = it.loadFont(op.Operands[0].Token)
+				gs.fontSize = op.Operands[1].Real
+			}
+		case "Tc":
+			gs.charSpacing = operand(op, 0)
+		case "Tw":
+			gs.wordSpacing = operand(op, 0)
+		case "Tz":
+			gs.hScale = operand(op, 0)
+		case "TL":
+			gs.leading = operand(op, 0)
+		case "Ts":
+			gs.rise = operand(op, 0)
+		case "Tr":
+			gs.render = int(operand(op, 0))
+		case "Td":
+			it.moveText(operand(op, 0), operand(op, 1))
+		case "TD":
+			gs.leading = -operand(op, 1)
+			it.moveText(operand(op, 0), operand(op, 1))
+		case "Tm":
+			it.tlm = matrixOf(op.Operands)
+			it.tm = it.tlm
+		case "T*":
+			it.moveText(0, -gs.leading)
+		case "Tj":
+			if len(op.Operands) > 0 {
+				it.showString(stringBytes(op.Operands[0]))
+			}
+		case "'":
+			it.moveText(0, -gs.leading)
+			if len(op.Operands) > 0 {
+				it.showString(stringBytes(op.Operands[0]))
+			}
+		case "\"":
+			if len(op.Operands) >= 3 {
+				gs.wordSpacing = op.Operands[0].Real
+				gs.charSpacing = op.Operands[1].Real
+				it.moveText(0, -gs.leading)
+				it.showString(stringBytes(op.Operands[2]))
+			}
+		case "TJ":
+			if len(op.Operands) > 0 {
+				for _, v := range op.Operands[0].Array {
+					switch v.Type {
+					case PDF_TYPE_STRING, PDF_TYPE_HEX:
+						it.showString(stringBytes(v))
+					case PDF_TYPE_NUMERIC, PDF_TYPE_REAL:
+						tx := -v.Real / 1000 * gs.fontSize * gs.hScale / 100
+						it.tm = matrix{1, 0, 0, 1, tx, 0}.multiply(it.tm)
+					}
+				}
+			}
+
+		// XObjects, images and shadings
+		case "Do":
+			err := it.drawXObject(nameOperand(op))
+			if err != nil {
+				return err
+			}
+		case "BI":
+			it.handler.drawImage(it, nil, op)
+		case "sh":
+			shading, _ := it.resource("/Shading", nameOperand(op))
+			if shading != nil {
+				it.handler.paintShading(it, shading)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Hand the current path over to the handler and apply a pending clip
+func (it *contentInterpreter) paintPath(op string) {
+	it.handler.paintPath(it, it.path, op)
+
+	if it.pendingClip != 0 {
+		it.gs.clips = append(it.gs.clips, &clipPath{path: it.path, evenOdd: it.pendingClip == 2})
+		it.pendingClip = 0
+	}
+
+	it.path = nil
+}
+
+// Move to the start of the next line, offset by tx, ty
+func (it *contentInterpreter) moveText(tx float64, ty float64) {
+	it.tlm = matrix{1, 0, 0, 1, tx, ty}.multiply(it.tlm)
+	it.tm = it.tlm
+}
+
+// Show a string and advance the text matrix
+func (it *contentInterpreter) showString(s []byte) {
+	gs := it.gs
+	font := gs.font
+	if font == nil {
+		font = &contentFont{defaultWidth: 500}
+	}
+
+	run := &textRun{
+		font:     font,
+		codes:    font.codes(s),
+		text:     s,
+		start:    it.tm.multiply(gs.ctm),
+		fontSize: gs.fontSize,
+		hScale:   gs.hScale,
+		rise:     gs.rise,
+	}
+
+	for _, code := range run.codes {
+		tx := font.width(code)/1000*gs.fontSize + gs.charSpacing
+		if !font.twoByte && code == 32 {
+			tx += gs.wordSpacing
+		}
+		run.width += tx * gs.hScale / 100
+	}
+
+	it.handler.showText(it, run)
+
+	it.tm = matrix{1, 0, 0, 1, run.width, 0}.multiply(it.tm)
+}
+
+// Look up a named resource (e.g. /Font /F1) in the current resources
+func (it *contentInterpreter) resource(category string, name string) (*PdfValue, error) {
+	if it.resources == nil || name == "" {
+		return nil, nil
+	}
+
+	dict, err := it.reader.resolveDirect(it.resources.Dictionary[category])
+	if err != nil || dict == nil {
+		return nil, err
+	}
+
+	return it.reader.resolveDirect(dict.Dictionary[name])
+}
+
+// Get the family of a color space (e.g. /DeviceRGB, /ICCBased, /Separation) by name
+func (it *contentInterpreter) colorSpaceFamily(name string) string {
+	switch name {
+	case "/DeviceGray", "/DeviceRGB", "/DeviceCMYK", "/Pattern":
+		return name
+	}
+
+	cs, _ := it.resource("/ColorSpace", name)
+	if cs == nil {
+		return name
+	}
+
+	if cs.Type == PDF_TYPE_ARRAY && len(cs.Array) > 0 {
+		return cs.Array[0].Token
+	}
+
+	return cs.Token
+}
+
+// Apply the parts of an ExtGState dictionary that the interpreter tracks
+func (it *contentInterpreter) setExtGState(name string) {
+	dict, _ := it.resource("/ExtGState", name)
+	if dict == nil {
+		return
+	}
+
+	if v, ok := dict.Dictionary["/LW"]; ok {
+		it.gs.lineWidth = v.Real
+	}
+	if v, ok := dict.Dictionary["/CA"]; ok {
+		it.gs.strokeAlpha = v.Real
+	}
+	if v, ok := dict.Dictionary["/ca"]; ok {
+		it.gs.fillAlpha = v.Real
+	}
+	if v, ok := dict.Dictionary["/SMask"]; ok {
+		it.gs.softMask = v.Token != "/None"
+	}
+	if v, ok := dict.Dictionary["/BM"]; ok {
+		if v.Type == PDF_TYPE_ARRAY && len(v.Array) > 0 {
+			v = v.Array[0]
+		}
+		it.gs.blend = v.Token != "/Normal" && v.Token != "/Compatible"
+	}
+}
+
+// Load the font with the given resource name (cached per resource dictionary)
+func (it *contentInterpreter) loadFont(name string) *contentFont {
+	if _, ok := it.fonts[it.resources]; !ok {
+		it.fonts[it.resources] = make(map[string]*contentFont, 0)
+	}
+	if font, ok := it.fonts[it.resources][name]; ok {
+		return font
+	}
+
+	font := &contentFont{defaultWidth: 500}
+	it.fonts[it.resources][name] = font
+
+	dict, _ := it.resource("/Font", name)
+	if dict == nil {
+		return font
+	}
+	font.dict = dict
+
+	reader := it.reader
+
+	if dict.Dictionary["/Subtype"] != nil && dict.Dictionary["/Subtype"].Token == "/Type0" {
+		font.twoByte = true
+		font.defaultWidth = 1000
+		font.cidWidths = make(map[int]float64, 0)
+
+		descendants, _ := reader.resolveDirect(dict.Dictionary["/DescendantFonts"])
+		if descendants == nil || len(descendants.Array) == 0 {
+			return font
+		}
+		cidFont, _ := reader.resolveDirect(descendants.Array[0])
+		if cidFont == nil {
+			return font
+		}
+
+		if dw, _ := reader.resolveDirect(cidFont.Dictionary["/DW"]); dw != nil {
+			font.defaultWidth = dw.Real
+		}
+
+		// /W is a list of "c [w1 w2 ...]" and "cfirst clast w" entries
+		w, _ := reader.resolveDirect(cidFont.Dictionary["/W"])
+		if w != nil {
+			for i := 0; i+1 < len(w.Array); {
+				first := w.Array[i].Int
+				if w.Array[i+1].Type == PDF_TYPE_ARRAY {
+					for j, width := range w.Array[i+1].Array {
+						font.cidWidths[first+j] = width.Real
+					}
+					i += 2
+				} else if i+2 < len(w.Array) {
+					for c := first; c <= w.Array[i+1].Int; c++ {
+						font.cidWidths[c] = w.Array[i+2].Real
+					}
+					i += 3
+				} else {
+					break
+				}
+			}
+		}
+
+		return font
+	}
+
+	if fc, _ := reader.resolveDirect(dict.Dictionary["/FirstChar"]); fc != nil {
+		font.firstChar = fc.Int
+	}
+
+	if widths, _ := reader.resolveDirect(dict.Dictionary["/Widths"]); widths != nil {
+		for _, width := range widths.Array {
+			width, _ = reader.resolveDirect(width)
+			if width == nil {
+				width = &PdfValue{}
+			}
+			font.widths = append(font.widths, width.Real)
+		}
+	}
+
+	if descriptor, _ := reader.resolveDirect(dict.Dictionary["/FontDescriptor"]); descriptor != nil {
+		if mw, ok := descriptor.Dictionary["/MissingWidth"]; ok {
+			font.defaultWidth = mw.Real
+		}
+	}
+
+	// Type3 widths are in glyph space, which /FontMatrix maps to text space
+	if dict.Dictionary["/Subtype"] != nil && dict.Dictionary["/Subtype"].Token == "/Type3" {
+		fontMatrix, _ := reader.resolveDirect(dict.Dictionary["/FontMatrix"])
+		if fontMatrix != nil && len(fontMatrix.Array) == 6 {
+			scale := matrixOf(fontMatrix.Array)[0] * 1000
+			for i := range font.widths {
+				font.widths[i] *= scale
+			}
+			font.defaultWidth = 0
+		}
+	}
+
+	return font
+}
+
+// Draw a named xobject: images go to the handler, forms are interpreted
+func (it *contentInterpreter) drawXObject(name string) error {
+	xobj, err := it.resource("/XObject", name)
+	if err != nil || xobj == nil || xobj.Type != PDF_TYPE_STREAM {
+		// Missing xobjects are not drawn by viewers either
+		return nil
+	}
+
+	subtype := xobj.Value.Dictionary["/Subtype"]
+	if subtype == nil {
+		return nil
+	}
+
+	switch subtype.Token {
+	case "/Image":
+		it.handler.drawImage(it, xobj, nil)
+	case "/Form":
+		if it.depth >= maxFormDepth || it.forms[xobj.Id] {
+			return nil
+		}
+
+		content, err := it.reader.rebuildContentStream(xobj)
+		if err != nil {
+			return errors.Wrap(err, "Failed to decode form xobject "+name)
+		}
+
+		// Interpret the form with its own resources and graphics state
+		resources, _ := it.reader.resolveDirect(xobj.Value.Dictionary["/Resources"])
+		if resources == nil {
+			resources = it.resources
+		}
+
+		saved := *it
+		it.stack = nil
+		it.path = nil
+		it.gs = it.gs.clone()
+		it.resources = resources
+		it.depth++
+		it.forms[xobj.Id] = true
+
+		if m, ok := xobj.Value.Dictionary["/Matrix"]; ok {
+			it.gs.ctm = matrixOf(m.Array).multiply(it.gs.ctm)
+		}
+
+		err = it.run(content)
+
+		delete(it.forms, xobj.Id)
+		forms := it.forms
+		fonts := it.fonts
+		*it = saved
+		it.forms = forms
+		it.fonts = fonts
+
+		if err != nil {
+			return errors.Wrap(err, "Failed to interpret form xobject "+name)
+		}
+	}
+
+	return nil
+}
+
+// A contentHandler that ignores everything, to be embedded by handlers
+// interested in only some operations
+type nopContentHandler struct{}
+
+func (nopContentHandler) paintPath(it *contentInterpreter, path []pathSegment, op string) {}
+
+func (nopContentHandler) showText(it *contentInterpreter, run *textRun) {}
+
+func (nopContentHandler) drawImage(it *contentInterpreter, image *PdfValue, inline *ContentOperation) {
+}
+
+func (nopContentHandler) paintShading(it *contentInterpreter, shading *PdfValue) {}
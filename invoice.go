@@ -0,0 +1,165 @@
+package gofpdi
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// File names of the invoice xml embedded in Factur-X, ZUGFeRD and XRechnung documents
+var invoiceFileNames = []string{"factur-x.xml", "zugferd-invoice.xml", "xrechnung.xml"}
+
+// The structured invoice embedded in a Factur-X / ZUGFeRD document
+type InvoiceXML struct {
+	FileName       string
+	AFRelationship string
+	// Profile name, e.g. MINIMUM, BASIC WL, BASIC, EN 16931, EXTENDED or XRECHNUNG
+	Profile string
+	// Guideline identifier from the xml, e.g. urn:cen.eu:en16931:2017
+	GuidelineID string
+	Data        []byte
+}
+
+// Get the embedded invoice xml of the current source, or nil if there is none
+func (importer *Importer) GetInvoiceXML() (*InvoiceXML, error) {
+	reader := importer.GetReader()
+
+	attachments, err := reader.getAttachments()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get attachments")
+	}
+
+	var found *Attachment
+	for _, attachment := range attachments {
+		for _, name := range invoiceFileNames {
+			if strings.EqualFold(attachment.FileName, name) || strings.EqualFold(attachment.Name, name) {
+				found = attachment
+				break
+			}
+		}
+		if found != nil {
+			break
+		}
+	}
+
+	if found == nil {
+		return nil, nil
+	}
+
+	invoice := &InvoiceXML{
+		FileName:       found.FileName,
+		AFRelationship: found.AFRelationship,
+		Data:           found.Data,
+	}
+
+	invoice.GuidelineID = invoiceGuidelineID(found.Data)
+	invoice.Profile = invoiceProfile(invoice.GuidelineID)
+
+	// Fall back to the conformance level declared in the xmp metadata
+	if invoice.Profile == "" {
+		invoice.Profile = reader.xmpConformanceLevel()
+	}
+
+	return invoice, nil
+}
+
+// Get the text of the ID element of GuidelineSpecifiedDocumentContextParameter
+func invoiceGuidelineID(data []byte) string {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+
+	inGuideline := false
+	inID := false
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return ""
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "GuidelineSpecifiedDocumentContextParameter" {
+				inGuideline = true
+			} else if inGuideline && t.Name.Local == "ID" {
+				inID = true
+			}
+		case xml.EndElement:
+			if t.Name.Local == "GuidelineSpecifiedDocumentContextParameter" {
+				inGuideline = false
+			}
+			inID = false
+		case xml.CharData:
+			if inID {
+				return strings.TrimSpace(string(t))
+			}
+		}
+	}
+}
+
+// Map a guideline identifier to a profile name
+func invoiceProfile(guideline string) string {
+	g := strings.ToLower(guideline)
+
+	switch {
+	case g == "":
+		return ""
+	case strings.Contains(g, "xrechnung"):
+		return "XRECHNUNG"
+	case strings.HasSuffix(g, ":minimum"):
+		return "MINIMUM"
+	case strings.HasSuffix(g, ":basicwl"):
+		return "BASIC WL"
+	case strings.HasSuffix(g, ":basic"):
+		return "BASIC"
+	case strings.HasSuffix(g, ":comfort"):
+		return "COMFORT"
+	case strings.HasSuffix(g, ":extended"):
+		return "EXTENDED"
+	case strings.HasPrefix(g, "urn:cen.eu:en16931:2017"):
+		return "EN 16931"
+	}
+
+	return ""
+}
+
+// Get the Factur-X / ZUGFeRD ConformanceLevel from the document's xmp metadata
+func (pdfReader *PdfReader) xmpConformanceLevel() string {
+	metadata, err := pdfReader.resolveDirect(pdfReader.catalog.Value.Dictionary["/Metadata"])
+	if err != nil || metadata == nil || metadata.Type != PDF_TYPE_STREAM {
+		return ""
+	}
+
+	data, err := pdfReader.rebuildContentStream(metadata)
+	if err != nil {
+		return ""
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	inLevel := false
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return ""
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			// Either <fx:ConformanceLevel>BASIC</fx:ConformanceLevel> or an attribute of rdf:Description
+			if t.Name.Local == "ConformanceLevel" {
+				inLevel = true
+			}
+			for _, attr := range t.Attr {
+				if attr.Name.Local == "ConformanceLevel" {
+					return strings.ToUpper(strings.TrimSpace(attr.Value))
+				}
+			}
+		case xml.EndElement:
+			inLevel = false
+		case xml.CharData:
+			if inLevel {
+				return strings.ToUpper(strings.TrimSpace(string(t)))
+			}
+		}
+	}
+}
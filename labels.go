@@ -0,0 +1,265 @@
+package gofpdi
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Walk a number tree and call fn for each key and value
+func (pdfReader *PdfReader) walkNumberTree(node *PdfValue, fn func(key int, value *PdfValue) error) error {
+	return pdfReader.walkNumberTreeNode(node, fn, make(map[int]bool, 0))
+}
+
+func (pdfReader *PdfReader) walkNumberTreeNode(node *PdfValue, fn func(key int, value *PdfValue) error, visited map[int]bool) error {
+	if node != nil && node.Type == PDF_TYPE_OBJREF {
+		if visited[node.Id] {
+			return nil
+		}
+		visited[node.Id] = true
+	}
+
+	node, err := pdfReader.resolveDirect(node)
+	if err != nil {
+		return errors.Wrap(err, "Failed to resolve number tree node")
+	}
+	if node == nil || node.Type != PDF_TYPE_DICTIONARY {
+		return nil
+	}
+
+	nums, err := pdfReader.resolveDirect(node.Dictionary["/Nums"])
+	if err != nil {
+		return errors.Wrap(err, "Failed to resolve /Nums")
+	}
+	if nums != nil {
+		for i := 0; i+1 < len(nums.Array); i += 2 {
+			if nums.Array[i].Type != PDF_TYPE_NUMERIC {
+				continue
+			}
+			err = fn(nums.Array[i].Int, nums.Array[i+1])
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	kids, err := pdfReader.resolveDirect(node.Dictionary["/Kids"])
+	if err != nil {
+		return errors.Wrap(err, "Failed to resolve /Kids")
+	}
+	if kids != nil {
+		for _, kid := range kids.Array {
+			err = pdfReader.walkNumberTreeNode(kid, fn, visited)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// A range of pages labelled alike, from the /PageLabels of the catalog
+type pageLabelRange struct {
+	// Index of the first page of the range
+	first  int
+	style  string
+	prefix string
+	start  int
+}
+
+// Get the ranges of the /PageLabels of the catalog, sorted by their first page
+func (pdfReader *PdfReader) getPageLabelRanges() ([]pageLabelRange, error) {
+	ranges := make([]pageLabelRange, 0)
+	err := pdfReader.walkNumberTree(pdfReader.catalog.Value.Dictionary["/PageLabels"], func(key int, value *PdfValue) error {
+		dict, err := pdfReader.resolveDirect(value)
+		if err != nil {
+			return errors.Wrap(err, "Failed to resolve page label")
+		}
+		r := pageLabelRange{first: key, start: 1}
+		if dict != nil && dict.Type == PDF_TYPE_DICTIONARY {
+			if s, _ := pdfReader.resolveDirect(dict.Dictionary["/S"]); s != nil && s.Type == PDF_TYPE_TOKEN {
+				r.style = s.Token
+			}
+			if p, _ := pdfReader.resolveDirect(dict.Dictionary["/P"]); p != nil && (p.Type == PDF_TYPE_STRING || p.Type == PDF_TYPE_HEX) {
+				r.prefix = decodeTextString(stringBytes(p))
+			}
+			if st, _ := pdfReader.resolveDirect(dict.Dictionary["/St"]); st != nil && st.Type == PDF_TYPE_NUMERIC && st.Int >= 1 {
+				r.start = st.Int
+			}
+		}
+		ranges = append(ranges, r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].first < ranges[j].first })
+	return ranges, nil
+}
+
+// Get the labels of all pages, by page number from 1.  Pages of documents
+// without /PageLabels are labelled with their page number.
+func (pdfReader *PdfReader) getPageLabels() ([]string, error) {
+	numPages, err := pdfReader.getNumPages()
+	if err != nil {
+		return nil, err
+	}
+	ranges, err := pdfReader.getPageLabelRanges()
+	if err != nil {
+		return nil, err
+	}
+
+	labels := make([]string, numPages+1)
+	for i := 0; i < numPages; i++ {
+		// The last range that starts at or before the page
+		var r *pageLabelRange
+		for j := range ranges {
+			if ranges[j].first <= i {
+				r = &ranges[j]
+			}
+		}
+		if r == nil {
+			labels[i+1] = strconv.Itoa(i + 1)
+			continue
+		}
+		labels[i+1] = r.prefix + formatPageNumber(r.style, r.start+i-r.first)
+	}
+	return labels, nil
+}
+
+// Format the number of a page in a page label numbering style: decimal (/D),
+// roman (/R, /r) or letters (/A, /a: A to Z, then AA to ZZ, ...).  Labels
+// without a style are the prefix only.
+func formatPageNumber(style string, n int) string {
+	switch style {
+	case "/D":
+		return strconv.Itoa(n)
+	case "/R":
+		return romanNumeral(n)
+	case "/r":
+		return strings.ToLower(romanNumeral(n))
+	case "/A", "/a":
+		letter := byte('A' + (n-1)%26)
+		if style == "/a" {
+			letter += 'a' - 'A'
+		}
+		return strings.Repeat(string(letter), (n-1)/26+1)
+	}
+	return ""
+}
+
+// Get the roman numeral of n
+func romanNumeral(n int) string {
+	values := []int{1000, 900, 500, 400, 100, 90, 50, 40, 10, 9, 5, 4, 1}
+	symbols := []string{"M", "CM", "D", "CD", "C", "XC", "L", "XL", "X", "IX", "V", "IV", "I"}
+
+	var sb strings.Builder
+	for i, v := range values {
+		for n >= v {
+			sb.WriteString(symbols[i])
+			n -= v
+		}
+	}
+	return sb.String()
+}
+
+// Get the labels of the pages of the current source (e.g. "iv" or "A-3"), as
+// viewers show them, by page number.  Pages of documents without page labels
+// are labelled with their page number.
+func (importer *Importer) GetPageLabels() (map[int]string, error) {
+	labels, err := importer.GetReader().getPageLabels()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get page labels")
+	}
+
+	res := make(map[int]string, len(labels))
+	for pageno := 1; pageno < len(labels); pageno++ {
+		res[pageno] = labels[pageno]
+	}
+	return res, nil
+}
+
+// Numbering style of page labels, see PageLabel
+type PageLabelStyle string
+
+const (
+	// Labels are the prefix only
+	PageLabelNone         PageLabelStyle = ""
+	PageLabelDecimal      PageLabelStyle = "/D"
+	PageLabelUpperRoman   PageLabelStyle = "/R"
+	PageLabelLowerRoman   PageLabelStyle = "/r"
+	PageLabelUpperLetters PageLabelStyle = "/A"
+	PageLabelLowerLetters PageLabelStyle = "/a"
+)
+
+// A range of pages of a Document labelled alike, from FirstPage to the first
+// page of the next range, e.g. {FirstPage: 5, Style: PageLabelDecimal} to
+// label pages 5, 6, ... as 1, 2, ...
+type PageLabel struct {
+	// Page number from 1 the range starts at
+	FirstPage int
+	Style     PageLabelStyle
+	// Put before the number of each page, e.g. "A-"
+	Prefix string
+	// Number of the first page of the range, 1 if 0
+	Start int
+}
+
+// Set the page labels of the document, as viewers show them instead of the
+// page numbers.  Ranges are sorted by their first page, and the first starts
+// at page 1.  nil for none.
+func (doc *Document) SetPageLabels(labels []PageLabel) error {
+	for i, label := range labels {
+		if i == 0 && label.FirstPage != 1 {
+			return errors.New("First page label range does not start at page 1")
+		}
+		if i > 0 && label.FirstPage <= labels[i-1].FirstPage {
+			return errors.New(fmt.Sprintf("Page label range of page %d is not after the range before it", label.FirstPage))
+		}
+		if label.Start < 0 {
+			return errors.New(fmt.Sprintf("Page label range of page %d starts at %d", label.FirstPage, label.Start))
+		}
+		switch label.Style {
+		case PageLabelNone, PageLabelDecimal, PageLabelUpperRoman, PageLabelLowerRoman, PageLabelUpperLetters, PageLabelLowerLetters:
+		default:
+			return errors.New(fmt.Sprintf("Unknown page label style %q", label.Style))
+		}
+	}
+	doc.pageLabels = labels
+	return nil
+}
+
+// Get the catalog entry for the page labels of the document.  Ranges that
+// start after the last page are left out.
+func (doc *Document) pageLabelsEntry() string {
+	if len(doc.pageLabels) == 0 {
+		return ""
+	}
+
+	var b bytes.Buffer
+	b.WriteString(" /PageLabels << /Nums [")
+	for _, label := range doc.pageLabels {
+		if label.FirstPage > len(doc.pages) {
+			break
+		}
+		b.WriteString(fmt.Sprintf(" %d <<", label.FirstPage-1))
+		if label.Style != PageLabelNone {
+			b.WriteString(" /S " + string(label.Style))
+		}
+		if label.Prefix != "" {
+			b.WriteString(" /P ")
+			writeContentValue(&b, encodeTextString(label.Prefix))
+		}
+		if label.Start > 1 {
+			b.WriteString(fmt.Sprintf(" /St %d", label.Start))
+		}
+		b.WriteString(" >>")
+	}
+	b.WriteString(" ] >>")
+	return b.String()
+}
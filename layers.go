@@ -0,0 +1,190 @@
+package gofpdi
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// Operators that only set the graphics or text state, which the foreground of
+// a split page repeats from the background, see ImportPageLayers
+var stateOperators = map[string]bool{
+	"cm": true, "w": true, "J": true, "j": true, "M": true, "d": true, "ri": true, "i": true, "gs": true,
+	"CS": true, "cs": true, "SC": true, "SCN": true, "sc": true, "scn": true,
+	"G": true, "g": true, "RG": true, "rg": true, "K": true, "k": true,
+	"Tc": true, "Tw": true, "Tz": true, "TL": true, "Tf": true, "Tr": true, "Ts": true,
+}
+
+// Operators that construct a path, which belongs to the operator that paints it
+var pathOperators = map[string]bool{
+	"m": true, "l": true, "c": true, "v": true, "y": true, "h": true, "re": true, "W": true, "W*": true,
+}
+
+// Split operations into their units at the outermost level: q ... Q blocks,
+// text objects, marked content, paths with the operator that paints them and
+// the other operations one by one
+func contentUnits(ops []*ContentOperation) [][]*ContentOperation {
+	units := make([][]*ContentOperation, 0)
+	start := 0
+	depth := 0
+	for i, op := range ops {
+		switch op.Operator {
+		case "q", "BT", "BMC", "BDC":
+			depth++
+		case "Q", "ET", "EMC":
+			if depth > 0 {
+				depth--
+			}
+		}
+		if depth > 0 || pathOperators[op.Operator] {
+			continue
+		}
+		units = append(units, ops[start:i+1])
+		start = i + 1
+	}
+	if start < len(ops) {
+		units = append(units, ops[start:])
+	}
+	return units
+}
+
+// Determine if a unit is a q ... Q block or marked content, whose inside is split
+func isWrapperUnit(unit []*ContentOperation) bool {
+	first, last := unit[0].Operator, unit[len(unit)-1].Operator
+	return len(unit) >= 2 && ((first == "q" && last == "Q") || ((first == "BMC" || first == "BDC") && last == "EMC"))
+}
+
+// Determine if a unit only changes the state the units after it are painted
+// with: state operators, and clipping paths that paint nothing
+func isStateUnit(unit []*ContentOperation) bool {
+	clip := false
+	for _, op := range unit {
+		switch {
+		case stateOperators[op.Operator]:
+		case op.Operator == "W" || op.Operator == "W*":
+			clip = true
+		case pathOperators[op.Operator]:
+		case op.Operator == "n" && clip:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Determine if a unit shows text, the default start of the foreground of
+// ImportPageLayers
+func showsText(ops []*ContentOperation) bool {
+	for _, op := range ops {
+		switch op.Operator {
+		case "Tj", "TJ", "'", "\"":
+			return true
+		}
+	}
+	return false
+}
+
+// Split operations into the units before the first one for which foreground
+// returns true and the units from there on, and report whether there is one.
+// The foreground starts with the state the background left.  If that unit is
+// a q ... Q block or marked content, it is split inside as well, so both parts
+// keep the wrapper.
+func splitContent(ops []*ContentOperation, foreground func(ops []*ContentOperation) bool) ([]*ContentOperation, []*ContentOperation, bool) {
+	units := contentUnits(ops)
+	split := len(units)
+	for i, unit := range units {
+		if foreground(unit) {
+			split = i
+			break
+		}
+	}
+
+	background := make([]*ContentOperation, 0)
+	fore := make([]*ContentOperation, 0)
+	for i, unit := range units {
+		switch {
+		case i < split:
+			background = append(background, unit...)
+			if isStateUnit(unit) {
+				fore = append(fore, unit...)
+			}
+		case i == split && isWrapperUnit(unit):
+			open, close := unit[0], unit[len(unit)-1]
+			innerBackground, innerFore, ok := splitContent(unit[1:len(unit)-1], foreground)
+			if !ok {
+				// Only the block as a whole is foreground
+				fore = append(fore, unit...)
+				break
+			}
+			background = append(append(append(background, open), innerBackground...), close)
+			fore = append(append(append(fore, open), innerFore...), close)
+		default:
+			fore = append(fore, unit...)
+		}
+	}
+	return background, fore, split < len(units)
+}
+
+// Make two templates of the content of template tplid, see ImportPageLayers
+func (pdfWriter *PdfWriter) splitTemplate(tplid int, foreground func(ops []*ContentOperation) bool) (int, int, error) {
+	if tplid < 0 || tplid >= len(pdfWriter.tpls) {
+		return -1, -1, errors.New(fmt.Sprintf("Template %d does not exist", tplid))
+	}
+	tpl := pdfWriter.tpls[tplid]
+	if tpl.parts != nil {
+		return -1, -1, errors.New("Composite templates cannot be split")
+	}
+
+	ops, err := ParseContentStream(tpl.Buffer)
+	if err != nil {
+		return -1, -1, errors.Wrap(err, "Failed to parse content")
+	}
+	background, fore, _ := splitContent(ops, foreground)
+
+	ids := make([]int, 0, 2)
+	for _, part := range [][]*ContentOperation{background, fore} {
+		layer := *tpl
+		layer.Buffer = WriteContentStream(part)
+		// The layers are form xobjects of their own
+		layer.stream = nil
+		layer.objId = nil
+		pdfWriter.tpls = append(pdfWriter.tpls, &layer)
+		ids = append(ids, len(pdfWriter.tpls)-1)
+	}
+	return ids[0], ids[1], nil
+}
+
+// Import page pageno of the current source as two templates of its size, so
+// other content can be drawn between them, e.g. the fields of a form between
+// its artwork and its printed text.  The background is what the page paints
+// first: its content up to the first part of it at the outermost level (a
+// q ... Q block, a text object, a painted path or another operation) for
+// which foreground returns true, looking inside q ... Q blocks and marked
+// content; the foreground is the rest, starting with the graphics state the
+// background set.  A nil foreground starts the foreground at the first text
+// that is shown.  Drawing the background and then the foreground at the same
+// place draws the page.
+func (importer *Importer) ImportPageLayers(pageno int, box BoxName, foreground func(ops []*ContentOperation) bool) (int, int, error) {
+	if foreground == nil {
+		foreground = showsText
+	}
+
+	tplid, err := importer.ImportPage(pageno, box)
+	if err != nil {
+		return -1, -1, err
+	}
+
+	tplInfo := importer.tplMap[tplid]
+	background, fore, err := tplInfo.Writer.splitTemplate(tplInfo.TemplateId, foreground)
+	if err != nil {
+		return -1, -1, errors.Wrap(err, fmt.Sprintf("Failed to split page %d", pageno))
+	}
+
+	ids := make([]int, 0, 2)
+	for _, res := range []int{background, fore} {
+		importer.tplMap[importer.tplN] = &TplInfo{SourceFile: tplInfo.SourceFile, TemplateId: res, Writer: tplInfo.Writer, Box: tplInfo.Box}
+		ids = append(ids, importer.tplN)
+		importer.tplN++
+	}
+	return ids[0], ids[1], nil
+}
@@ -0,0 +1,176 @@
+package gofpdi
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Limits on what a source may hold and how long importing from it may take,
+// e.g. for services that import PDFs uploaded by users.  A limit of 0 is no
+// limit.
+type Limits struct {
+	// Size of the source in bytes
+	MaxFileSize int64
+	// Number of pages
+	MaxPages int
+	// Number of objects in the cross-reference table
+	MaxObjects int
+	// Size of a stream in bytes, both as stored and once decompressed, so
+	// compression bombs are stopped before they fill memory
+	MaxStreamBytes int64
+	// Time opening the source, and each ImportPage, ImportPages or
+	// PutFormXobjects call, may take
+	Timeout time.Duration
+}
+
+// Limits suitable for documents uploaded by users: up to 100 MB, 2000 pages,
+// 500000 objects and streams of 256 MB, each import taking up to 30 seconds
+var DefaultLimits = Limits{
+	MaxFileSize:    100 << 20,
+	MaxPages:       2000,
+	MaxObjects:     500000,
+	MaxStreamBytes: 256 << 20,
+	Timeout:        30 * time.Second,
+}
+
+// Returned when a source exceeds one of its Limits
+type LimitError struct {
+	// Name of the field of Limits, e.g. "MaxPages"
+	Limit string
+	Value int64
+	Max   int64
+}
+
+func (err *LimitError) Error() string {
+	if err.Limit == "Timeout" {
+		return fmt.Sprintf("Import took longer than %s", time.Duration(err.Max))
+	}
+	return fmt.Sprintf("Source exceeds %s: %d > %d", err.Limit, err.Value, err.Max)
+}
+
+// Set the limits of a source that is already open, see NewPdfReaderWithLimits
+// to apply them while it is opened.  The size, pages and objects of the
+// source are checked at once, so a *LimitError is returned if it exceeds
+// them; streams are checked as they are read.
+func (pdfReader *PdfReader) SetLimits(limits Limits) error {
+	pdfReader.limits = limits
+
+	if limits.MaxFileSize > 0 && pdfReader.nBytes > limits.MaxFileSize {
+		return &LimitError{Limit: "MaxFileSize", Value: pdfReader.nBytes, Max: limits.MaxFileSize}
+	}
+	if limits.MaxPages > 0 && pdfReader.pageCount > limits.MaxPages {
+		return &LimitError{Limit: "MaxPages", Value: int64(pdfReader.pageCount), Max: int64(limits.MaxPages)}
+	}
+	return pdfReader.checkObjectCount()
+}
+
+// Get a reader of f, which holds size bytes, to be read within limits.
+// Fails with a *LimitError if f is larger than MaxFileSize.
+func newLimitedReader(f io.ReadSeeker, name string, size int64, limits Limits) (*PdfReader, error) {
+	if limits.MaxFileSize > 0 && size > limits.MaxFileSize {
+		return nil, &LimitError{Limit: "MaxFileSize", Value: size, Max: limits.MaxFileSize}
+	}
+	return &PdfReader{f: f, sourceFile: name, nBytes: size, limits: limits}, nil
+}
+
+// Apply limits to the sources opened after the call, see
+// NewPdfReaderWithLimits, e.g. SetLimits(DefaultLimits) for sources uploaded
+// by users.  Setting a source that exceeds them fails with a *LimitError, and
+// so does importing from it once a stream is too large.  Readers given to
+// SetSourceReader keep their own limits, since other importers may share
+// them.  The Timeout applies to the imports of every source, also of those
+// set before.
+func (importer *Importer) SetLimits(limits Limits) {
+	importer.limits = limits
+	importer.setWriterOptions(func(options *writerOptions) {
+		options.timeout = limits.Timeout
+	})
+}
+
+// Get the time that is timeout from now, or the zero time if timeout is 0
+func limitDeadline(timeout time.Duration) time.Time {
+	if timeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(timeout)
+}
+
+// Get a *LimitError if deadline, set for timeout, has passed
+func checkLimitDeadline(deadline time.Time, timeout time.Duration) error {
+	if !deadline.IsZero() && time.Now().After(deadline) {
+		return &LimitError{Limit: "Timeout", Max: int64(timeout)}
+	}
+	return nil
+}
+
+// Get a *LimitError if the deadline of opening the source has passed
+func (pdfReader *PdfReader) checkDeadline() error {
+	return checkLimitDeadline(pdfReader.deadline, pdfReader.limits.Timeout)
+}
+
+// Fail the current import with a *LimitError once the timeout of the writer
+// has passed, until the returned function is called.  The deadline is kept
+// by the writer, which belongs to one importer, and not by the reader, which
+// importers may share.  An import within another keeps its deadline.
+func (pdfWriter *PdfWriter) startDeadline() func() {
+	if pdfWriter.timeout <= 0 || !pdfWriter.deadline.IsZero() {
+		return func() {}
+	}
+	pdfWriter.deadline = limitDeadline(pdfWriter.timeout)
+	return func() { pdfWriter.deadline = time.Time{} }
+}
+
+// Get a *LimitError if the deadline of the current import has passed
+func (pdfWriter *PdfWriter) checkDeadline() error {
+	return checkLimitDeadline(pdfWriter.deadline, pdfWriter.timeout)
+}
+
+// Get a *LimitError if the cross-reference table has more entries than
+// MaxObjects
+func (pdfReader *PdfReader) checkObjectCount() error {
+	if objects := len(pdfReader.xref) + len(pdfReader.xrefStream); pdfReader.limits.MaxObjects > 0 && objects > pdfReader.limits.MaxObjects {
+		return &LimitError{Limit: "MaxObjects", Value: int64(objects), Max: int64(pdfReader.limits.MaxObjects)}
+	}
+	return nil
+}
+
+// Get a *LimitError if a stream of length bytes is too large
+func (pdfReader *PdfReader) checkStreamSize(length int64) error {
+	if max := pdfReader.limits.MaxStreamBytes; max > 0 && length > max {
+		return &LimitError{Limit: "MaxStreamBytes", Value: length, Max: max}
+	}
+	return nil
+}
+
+// Decompress zlib compressed data, up to MaxStreamBytes.  If the data is
+// corrupt, what could be decompressed is returned with the error; if it is
+// too large, a *LimitError.
+func (pdfReader *PdfReader) inflate(data []byte) ([]byte, error) {
+	z, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer z.Close()
+
+	var r io.Reader = z
+	if max := pdfReader.limits.MaxStreamBytes; max > 0 {
+		r = io.LimitReader(z, max+1)
+	}
+	out, err := io.ReadAll(r)
+	if limitErr := pdfReader.checkStreamSize(int64(len(out))); limitErr != nil {
+		return nil, limitErr
+	}
+	return out, err
+}
+
+// Determine if err is a *LimitError, which stops reading even where corrupt
+// data is tolerated
+func isLimitError(err error) bool {
+	_, ok := errors.Cause(err).(*LimitError)
+	return ok
+}
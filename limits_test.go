@@ -0,0 +1,157 @@
+package gofpdi
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// A Source that counts the reads of its data
+type countingSource struct {
+	*bytes.Reader
+	reads int32
+}
+
+func (source *countingSource) ReadAt(p []byte, off int64) (int, error) {
+	atomic.AddInt32(&source.reads, 1)
+	return source.Reader.ReadAt(p, off)
+}
+
+// Build a pdf file like buildPDF, with a flate encoded cross-reference
+// stream instead of a table
+func buildXrefStreamPDF(objects ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+
+	var entries bytes.Buffer
+	entries.Write([]byte{0, 0, 0, 0, 0, 0xff})
+	for i, obj := range objects {
+		entries.WriteByte(1)
+		binary.Write(&entries, binary.BigEndian, uint32(buf.Len()))
+		entries.WriteByte(0)
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	// The entry of the xref stream itself
+	xref := buf.Len()
+	entries.WriteByte(1)
+	binary.Write(&entries, binary.BigEndian, uint32(xref))
+	entries.WriteByte(0)
+
+	var data bytes.Buffer
+	z := zlib.NewWriter(&data)
+	z.Write(entries.Bytes())
+	z.Close()
+
+	size := len(objects) + 2
+	fmt.Fprintf(&buf, "%d 0 obj\n<< /Type /XRef /Size %d /W [1 4 1] /Root 1 0 R /Filter /FlateDecode /Length %d >>\nstream\n",
+		size-1, size, data.Len())
+	buf.Write(data.Bytes())
+	fmt.Fprintf(&buf, "\nendstream\nendobj\nstartxref\n%d\n%%%%EOF\n", xref)
+	return buf.Bytes()
+}
+
+// Get the limit a *LimitError was returned for, or "" for other errors
+func exceededLimit(err error) string {
+	if limitErr, ok := errors.Cause(err).(*LimitError); ok {
+		return limitErr.Limit
+	}
+	return ""
+}
+
+// Sources are checked against the limits while they are opened
+func TestLimitsWhileOpening(t *testing.T) {
+	document := testDocument(5)
+	// The startxref offset points to the middle of an object, so the
+	// cross-reference table is rebuilt
+	broken := bytes.Replace(document, []byte("startxref\n"), []byte("startxref\n1"), 1)
+	xrefStream := buildXrefStreamPDF(testPages([2]float64{612, 792}, [2]float64{300, 200})...)
+
+	for _, test := range []struct {
+		name   string
+		data   []byte
+		limits Limits
+		want   string
+	}{
+		{"file size", document, Limits{MaxFileSize: int64(len(document)) - 1}, "MaxFileSize"},
+		{"pages", document, Limits{MaxPages: 4}, "MaxPages"},
+		{"objects", document, Limits{MaxObjects: 10}, "MaxObjects"},
+		{"objects of a rebuilt table", broken, Limits{MaxObjects: 10}, "MaxObjects"},
+		{"xref stream", xrefStream, Limits{MaxStreamBytes: 16}, "MaxStreamBytes"},
+		{"timeout", document, Limits{Timeout: time.Nanosecond}, "Timeout"},
+		{"within the limits", document, DefaultLimits, ""},
+		{"xref stream within the limits", xrefStream, DefaultLimits, ""},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			source := &countingSource{Reader: bytes.NewReader(test.data)}
+			reader, err := NewPdfReaderWithLimits("test", source, test.limits)
+			if got := exceededLimit(err); got != test.want {
+				t.Fatalf("got error %v, want a limit error for %q", err, test.want)
+			}
+			if test.want == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if pages, _ := reader.getNumPages(); pages == 0 {
+					t.Error("no pages")
+				}
+			}
+			if test.want == "MaxFileSize" && source.reads > 0 {
+				t.Errorf("source was read %d times", source.reads)
+			}
+		})
+	}
+}
+
+// The importer opens its sources with its limits
+func TestImporterLimits(t *testing.T) {
+	importer := NewImporter()
+	importer.SetLimits(Limits{MaxPages: 2})
+	err := importer.SetSourceBytes("test", testDocument(3))
+	if exceededLimit(err) != "MaxPages" {
+		t.Fatalf("got error %v, want a limit error for MaxPages", err)
+	}
+	if err := importer.SetSourceBytes("small", testDocument(2)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// The timeout of an importer applies to a reader it shares, without changing
+// the limits of the reader
+func TestImportTimeoutOfSharedReader(t *testing.T) {
+	reader := readTestPDF(t, testDocument(3))
+	importer := NewImporter()
+	if err := importer.SetSourceReader("shared", reader); err != nil {
+		t.Fatal(err)
+	}
+	importer.SetLimits(Limits{Timeout: time.Nanosecond, MaxStreamBytes: 1})
+
+	_, err := importer.ImportPage(1, MediaBox)
+	if exceededLimit(err) != "Timeout" {
+		t.Fatalf("got error %v, want a limit error for Timeout", err)
+	}
+	if reader.limits != (Limits{}) {
+		t.Errorf("limits of the shared reader were changed to %+v", reader.limits)
+	}
+
+	// Another importer of the reader has no timeout
+	other := NewImporter()
+	if err := other.SetSourceReader("shared", reader); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.ImportPage(1, MediaBox); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.PutFormXobjects(); err != nil {
+		t.Fatal(err)
+	}
+	if len(other.GetImportedObjects()) == 0 {
+		t.Error("no objects written")
+	}
+}
@@ -0,0 +1,164 @@
+package gofpdi
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+)
+
+// Printer's marks drawn around imported pages, see Importer.SetPrinterMarks.
+// Distances are in points.
+type PrinterMarks struct {
+	// Marks at the corners of the TrimBox
+	CropMarks bool
+	// Marks at the corners of the BleedBox, for pages whose BleedBox is larger than their TrimBox
+	BleedMarks bool
+	// Targets in the middle of each side, for aligning the separations
+	RegistrationMarks bool
+	// Distance of the marks from the box they mark (9 if 0)
+	Offset float64
+	// Length of the marks (18 if 0)
+	Length float64
+	// Width of the lines of the marks (0.25 if 0)
+	LineWidth float64
+}
+
+// Get the marks with the defaults filled in
+func (marks PrinterMarks) withDefaults() PrinterMarks {
+	if marks.Offset <= 0 {
+		marks.Offset = 9
+	}
+	if marks.Length <= 0 {
+		marks.Length = 18
+	}
+	if marks.LineWidth <= 0 {
+		marks.LineWidth = 0.25
+	}
+	return marks
+}
+
+// Get the first of the boxes that is set, in points
+func markBox(boxes map[string]map[string]float64, k float64, names ...BoxName) (box [4]float64, ok bool) {
+	for _, name := range names {
+		b := boxes[name.String()]
+		if b["w"] > 0 && b["h"] > 0 {
+			return [4]float64{b["llx"] * k, b["lly"] * k, b["urx"] * k, b["ury"] * k}, true
+		}
+	}
+	return box, false
+}
+
+// Draw printer's marks around the TrimBox of a template.  The box of the
+// template is enlarged to make room for them, and the page content is clipped
+// to the BleedBox (or TrimBox), so nothing of the page shows in the margin but
+// the marks.  The marks are drawn in registration black (100% of each process
+// color).
+func (tpl *PdfTemplate) addPrinterMarks(marks PrinterMarks) {
+	marks = marks.withDefaults()
+	k := tpl.k
+
+	trim, ok := markBox(tpl.Boxes, k, TrimBox, CropBox, MediaBox)
+	if !ok {
+		return
+	}
+	bleed, ok := markBox(tpl.Boxes, k, BleedBox)
+	hasBleed := ok && (bleed[0] < trim[0] || bleed[1] < trim[1] || bleed[2] > trim[2] || bleed[3] > trim[3])
+	if !hasBleed {
+		bleed = trim
+	}
+
+	imported := [4]float64{tpl.Box["llx"] * k, tpl.Box["lly"] * k, tpl.Box["urx"] * k, tpl.Box["ury"] * k}
+
+	var buf bytes.Buffer
+
+	// The page content, clipped to the bleed
+	buf.WriteString(fmt.Sprintf("q %.5F %.5F %.5F %.5F re W n\n", bleed[0], bleed[1], bleed[2]-bleed[0], bleed[3]-bleed[1]))
+	buf.Write(tpl.Buffer)
+	buf.WriteString("\nQ\n")
+
+	buf.WriteString(fmt.Sprintf("q %.5F w 0 J 1 1 1 1 K\n", marks.LineWidth))
+
+	// Lines along the edges of a box at its corners, outside of the bleed
+	corners := func(box [4]float64) {
+		left := math.Min(box[0], bleed[0]) - marks.Offset
+		right := math.Max(box[2], bleed[2]) + marks.Offset
+		bottom := math.Min(box[1], bleed[1]) - marks.Offset
+		top := math.Max(box[3], bleed[3]) + marks.Offset
+
+		for _, x := range []float64{box[0], box[2]} {
+			buf.WriteString(fmt.Sprintf("%.5F %.5F m %.5F %.5F l S\n", x, bottom, x, bottom-marks.Length))
+			buf.WriteString(fmt.Sprintf("%.5F %.5F m %.5F %.5F l S\n", x, top, x, top+marks.Length))
+		}
+		for _, y := range []float64{box[1], box[3]} {
+			buf.WriteString(fmt.Sprintf("%.5F %.5F m %.5F %.5F l S\n", left, y, left-marks.Length, y))
+			buf.WriteString(fmt.Sprintf("%.5F %.5F m %.5F %.5F l S\n", right, y, right+marks.Length, y))
+		}
+	}
+
+	if marks.CropMarks {
+		corners(trim)
+	}
+	if marks.BleedMarks && hasBleed {
+		corners(bleed)
+	}
+
+	if marks.RegistrationMarks {
+		// Targets centered in the margin outside of the bleed
+		d := marks.Offset + marks.Length/2
+		r := marks.Length / 3
+		cx := (trim[0] + trim[2]) / 2
+		cy := (trim[1] + trim[3]) / 2
+		targets := [][2]float64{
+			{cx, bleed[1] - d},
+			{cx, bleed[3] + d},
+			{bleed[0] - d, cy},
+			{bleed[2] + d, cy},
+		}
+		for _, t := range targets {
+			writeRegistrationTarget(&buf, t[0], t[1], r)
+		}
+	}
+
+	buf.WriteString("Q\n")
+	tpl.Buffer = buf.Bytes()
+	tpl.stream = nil
+
+	// Make room for the marks
+	margin := marks.Offset + marks.Length
+	llx := math.Min(imported[0], bleed[0]-margin)
+	lly := math.Min(imported[1], bleed[1]-margin)
+	urx := math.Max(imported[2], bleed[2]+margin)
+	ury := math.Max(imported[3], bleed[3]+margin)
+
+	tpl.Box = map[string]float64{
+		"x":   llx / k,
+		"y":   lly / k,
+		"w":   (urx - llx) / k,
+		"h":   (ury - lly) / k,
+		"llx": llx / k,
+		"lly": lly / k,
+		"urx": urx / k,
+		"ury": ury / k,
+	}
+	tpl.W = tpl.Box["w"]
+	tpl.H = tpl.Box["h"]
+	if tpl.Rotation%180 != 0 {
+		tpl.W, tpl.H = tpl.H, tpl.W
+	}
+}
+
+// Draw a registration target: a circle with a cross through it
+func writeRegistrationTarget(buf *bytes.Buffer, x float64, y float64, r float64) {
+	// Bezier approximation of a circle
+	const kappa = 0.5522847498
+	c := r * kappa
+	buf.WriteString(fmt.Sprintf("%.5F %.5F m\n", x+r, y))
+	buf.WriteString(fmt.Sprintf("%.5F %.5F %.5F %.5F %.5F %.5F c\n", x+r, y+c, x+c, y+r, x, y+r))
+	buf.WriteString(fmt.Sprintf("%.5F %.5F %.5F %.5F %.5F %.5F c\n", x-c, y+r, x-r, y+c, x-r, y))
+	buf.WriteString(fmt.Sprintf("%.5F %.5F %.5F %.5F %.5F %.5F c\n", x-r, y-c, x-c, y-r, x, y-r))
+	buf.WriteString(fmt.Sprintf("%.5F %.5F %.5F %.5F %.5F %.5F c S\n", x+c, y-r, x+r, y-c, x+r, y))
+
+	l := r * 1.5
+	buf.WriteString(fmt.Sprintf("%.5F %.5F m %.5F %.5F l S\n", x-l, y, x+l, y))
+	buf.WriteString(fmt.Sprintf("%.5F %.5F m %.5F %.5F l S\n", x, y-l, x, y+l))
+}
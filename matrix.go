@@ -0,0 +1,142 @@
+package gofpdi
+
+import (
+	"fmt"
+	"math"
+)
+
+// A transformation matrix [a b c d e f], as used by the cm operator
+type Matrix [6]float64
+
+// The matrix that leaves coordinates unchanged
+var IdentityMatrix = Matrix{1, 0, 0, 1, 0, 0}
+
+// Concatenate two matrices: the result applies m first, then n
+func (m Matrix) Multiply(n Matrix) Matrix {
+	return Matrix(matrix(m).multiply(matrix(n)))
+}
+
+// Transform a point
+func (m Matrix) Transform(x float64, y float64) (float64, float64) {
+	p := matrix(m).transform(x, y)
+	return p.x, p.y
+}
+
+// Get the cm operator that applies m, e.g. "1 0 0 1 10 20 cm"
+func (m Matrix) Cm() string {
+	return fmt.Sprintf("%.5F %.5F %.5F %.5F %.5F %.5F cm", m[0], m[1], m[2], m[3], m[4], m[5])
+}
+
+// Like UseTemplate, but returns the template name and the complete matrix that
+// places the template.  Draw it with "q <matrix> cm <name> Do Q".  With
+// OriginTopLeft the page height has to be added to the translation (m[5]), as
+// for the ty returned by UseTemplate.
+func (importer *Importer) UseTemplateMatrix(tplid int, x float64, y float64, w float64, h float64) (string, Matrix) {
+	name, scaleX, scaleY, tx, ty := importer.UseTemplate(tplid, x, y, w, h)
+	return name, Matrix{scaleX, 0, 0, scaleY, tx, ty}
+}
+
+// How a template is rotated and mirrored when it is placed, see UseTemplatePlacement
+type Placement struct {
+	// Counter-clockwise rotation in degrees around the center of the template
+	Rotate float64
+	// Mirror left to right
+	FlipHorizontal bool
+	// Mirror top to bottom
+	FlipVertical bool
+}
+
+// Like UseTemplateMatrix, but rotates and mirrors the template around its
+// center after placing it in the w by h box at x, y
+func (importer *Importer) UseTemplatePlacement(tplid int, x float64, y float64, w float64, h float64, placement Placement) (string, Matrix, error) {
+	tpl, err := importer.getTemplate(tplid)
+	if err != nil {
+		return "", IdentityMatrix, err
+	}
+
+	name, m := importer.UseTemplateMatrix(tplid, x, y, w, h)
+
+	// Form space of the template covers its size in points
+	cx, cy := m.Transform(tpl.W*tpl.k/2, tpl.H*tpl.k/2)
+
+	sx, sy := 1.0, 1.0
+	if placement.FlipHorizontal {
+		sx = -1
+	}
+	if placement.FlipVertical {
+		sy = -1
+	}
+
+	angle := placement.Rotate * math.Pi / 180
+	c, s := math.Cos(angle), math.Sin(angle)
+
+	m = m.Multiply(Matrix{1, 0, 0, 1, -cx, -cy}).
+		Multiply(Matrix{sx, 0, 0, sy, 0, 0}).
+		Multiply(Matrix{c, s, -s, c, 0, 0}).
+		Multiply(Matrix{1, 0, 0, 1, cx, cy})
+
+	return name, m, nil
+}
+
+// Like UseTemplateMatrix, but also returns the matrix that maps the default
+// user space of the source page, before its /Rotate and in points, onto the
+// output page where the template is placed, e.g. to carry over the positions
+// of links, annotations or text (see GetPageText) of a rotated page.  With
+// OriginTopLeft the page height has to be added to its translation too.
+func (importer *Importer) UseTemplatePageMatrix(tplid int, x float64, y float64, w float64, h float64) (string, Matrix, Matrix, error) {
+	tpl, err := importer.getTemplate(tplid)
+	if err != nil {
+		return "", IdentityMatrix, IdentityMatrix, err
+	}
+
+	name, m := importer.UseTemplateMatrix(tplid, x, y, w, h)
+
+	c, s, tx, ty := tpl.formMatrix()
+	form := Matrix{c, s, -s, c, tx * tpl.k, ty * tpl.k}
+
+	return name, m, form.Multiply(m), nil
+}
+
+// The corners of a placed template on the output page, as x, y pairs in
+// the order lower left, lower right, upper right, upper left of the template
+type TemplateOutline [4][2]float64
+
+// Get the outline of template tplid where matrix m (from UseTemplateMatrix,
+// UseTemplatePlacement or UseTemplatePageMatrix) places it: the area it can
+// draw in, as it is clipped by its bounding box, e.g. to draw a border or a
+// shadow around it.  The outline is rotated and mirrored with the template.
+// With OriginTopLeft it needs the page height added to its y coordinates,
+// unless it was added to m.
+func (importer *Importer) GetTemplateOutline(tplid int, m Matrix) (TemplateOutline, error) {
+	tpl, err := importer.getTemplate(tplid)
+	if err != nil {
+		return TemplateOutline{}, err
+	}
+
+	// Form space of the template covers its size in points
+	w, h := tpl.W*tpl.k, tpl.H*tpl.k
+	var outline TemplateOutline
+	for i, corner := range [4][2]float64{{0, 0}, {w, 0}, {w, h}, {0, h}} {
+		outline[i][0], outline[i][1] = m.Transform(corner[0], corner[1])
+	}
+	return outline, nil
+}
+
+// Get the smallest rectangle (llx, lly, urx, ury) that encloses the outline
+func (outline TemplateOutline) Bounds() [4]float64 {
+	bounds := [4]float64{outline[0][0], outline[0][1], outline[0][0], outline[0][1]}
+	for _, p := range outline[1:] {
+		bounds[0] = math.Min(bounds[0], p[0])
+		bounds[1] = math.Min(bounds[1], p[1])
+		bounds[2] = math.Max(bounds[2], p[0])
+		bounds[3] = math.Max(bounds[3], p[1])
+	}
+	return bounds
+}
+
+// Get the path operators of the outline, e.g. to stroke it with
+// "q 0.5 w <path> S Q" or fill it with "q 0.8 g <path> f Q"
+func (outline TemplateOutline) Path() string {
+	p := outline
+	return fmt.Sprintf("%.5F %.5F m %.5F %.5F l %.5F %.5F l %.5F %.5F l h", p[0][0], p[0][1], p[1][0], p[1][1], p[2][0], p[2][1], p[3][0], p[3][1])
+}
@@ -0,0 +1,284 @@
+package gofpdi
+
+import (
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Merges documents into one Document: every page of each source, one source
+// after the other, added as it is with AddTemplatePage
+type Merger struct {
+	doc     *Document
+	sources []*mergedSource
+	// Generate an outline with an entry per source, see SetTableOfContents
+	tableOfContents bool
+	// Page numbers stamped on the pages, see SetPageNumbers
+	pageNumbers *PageNumbers
+	// Sizing of the pages of sources added next, see SetScaling
+	scaling PageScaling
+	// Pad sources added next to an even number of pages, see SetDuplex
+	duplex bool
+	// Indexes of the pages added by SetDuplex
+	blankPages map[int]bool
+	// Embed the attachments of sources added next, see SetCarryAttachments
+	carryAttachments bool
+}
+
+// A source added to a Merger and the pages of the document it was added to
+type mergedSource struct {
+	name   string
+	reader *PdfReader
+	// Index of the first page of the source in the document, and its number
+	// of pages, without a blank page added by SetDuplex
+	first int
+	pages int
+}
+
+// How a Merger sizes the pages of a source, see PageScaling
+type ScalingPolicy int
+
+const (
+	// Pages keep their size
+	ScalingKeep ScalingPolicy = iota
+	// Pages are fit to pages of the target size and centered on them
+	ScalingFit
+	// Pages are scaled to the width of the target size, keeping their aspect
+	// ratio, so their heights differ
+	ScalingWidth
+)
+
+// How a Merger sizes the pages of a source, see Merger.SetScaling
+type PageScaling struct {
+	Policy ScalingPolicy
+	// Target size in points, e.g. PageSizeA4; only the width is used by
+	// ScalingWidth
+	Size PageSize
+	// How pages are fit by ScalingFit, FitContain by default
+	Mode FitMode
+}
+
+// Create a merger with a new Document
+func NewMerger() *Merger {
+	return &Merger{doc: NewDocument(), sources: make([]*mergedSource, 0), blankPages: make(map[int]bool, 0)}
+}
+
+// Get the document the pages are merged into, e.g. to set import options on
+// its importer before adding sources
+func (merger *Merger) Document() *Document {
+	return merger.doc
+}
+
+// Size the pages of the sources added after the call with scaling, e.g.
+// PageScaling{Policy: ScalingFit, Size: PageSizeA4} to merge A4 and Letter
+// sources onto A4 pages.  Pages keep their size by default.
+func (merger *Merger) SetScaling(scaling PageScaling) error {
+	if scaling.Policy != ScalingKeep {
+		if !(scaling.Size.W > 0) || (scaling.Policy == ScalingFit && !(scaling.Size.H > 0)) {
+			return errors.New("Target page size is empty")
+		}
+	}
+	merger.scaling = scaling
+	return nil
+}
+
+// Add a blank page after each source added after the call that has an odd
+// number of pages, the size of its last page, so every source starts on a
+// new sheet when the document is printed on both sides.  The blank pages
+// are counted, but not stamped with page numbers.
+func (merger *Merger) SetDuplex(b bool) {
+	merger.duplex = b
+}
+
+// Embed the files embedded in the sources added after the call (their
+// /EmbeddedFiles) in the merged document, e.g. the invoice XML of ZUGFeRD
+// invoices.  Files whose name is taken by a file of another source are
+// renamed, see Document.AddAttachment.
+func (merger *Merger) SetCarryAttachments(b bool) {
+	merger.carryAttachments = b
+}
+
+// Add the pages of a file
+func (merger *Merger) AddFile(filename string) error {
+	err := merger.doc.importer.SetSourceFile(filename)
+	if err != nil {
+		return errors.Wrap(err, "Failed to open "+filename)
+	}
+	return merger.addPages(filename)
+}
+
+// Add the pages of a source with the given name, see Importer.SetSource
+func (merger *Merger) AddSource(name string, source Source) error {
+	err := merger.doc.importer.SetSource(name, source)
+	if err != nil {
+		return errors.Wrap(err, "Failed to open "+name)
+	}
+	return merger.addPages(name)
+}
+
+// Add the pages of the current source of the importer
+func (merger *Merger) addPages(name string) error {
+	importer := merger.doc.importer
+	pages, err := importer.GetNumPages()
+	if err != nil {
+		return errors.Wrap(err, "Failed to get number of pages of "+name)
+	}
+
+	source := &mergedSource{name: name, reader: importer.GetReader(), first: merger.doc.GetNumPages()}
+	if merger.carryAttachments {
+		attachments, err := source.reader.getAttachments()
+		if err != nil {
+			return errors.Wrap(err, "Failed to get attachments of "+name)
+		}
+		for _, attachment := range attachments {
+			_, err = merger.doc.AddAttachment(attachment)
+			if err != nil {
+				return errors.Wrap(err, "Failed to carry attachment of "+name)
+			}
+		}
+	}
+
+	for pageno := 1; pageno <= pages; pageno++ {
+		tplid, err := importer.ImportPage(pageno, CropBox)
+		if err != nil {
+			return errors.Wrap(err, "Failed to import page of "+name)
+		}
+		err = merger.addPage(tplid)
+		if err != nil {
+			return err
+		}
+		source.pages++
+	}
+	merger.sources = append(merger.sources, source)
+
+	// Sources with an odd number of pages end on the front of a sheet
+	if merger.duplex && source.pages%2 == 1 {
+		last := merger.doc.pages[len(merger.doc.pages)-1]
+		k := importer.options.k
+		err = merger.doc.AddPage(last.w*last.userUnit/k, last.h*last.userUnit/k)
+		if err != nil {
+			return err
+		}
+		merger.blankPages[len(merger.doc.pages)-1] = true
+	}
+
+	return nil
+}
+
+// Add a page that draws template tplid, sized with the scaling of the merger
+func (merger *Merger) addPage(tplid int) error {
+	doc := merger.doc
+	scaling := merger.scaling
+	k := doc.importer.options.k
+
+	switch scaling.Policy {
+	case ScalingFit:
+		return doc.AddFittedTemplatePage(tplid, scaling.Size.W/k, scaling.Size.H/k, scaling.Mode)
+	case ScalingWidth:
+		w, h, err := doc.importer.GetTemplateSize(tplid, 0, 0)
+		if err != nil {
+			return err
+		}
+		if !(w > 0) {
+			return errors.New("Template is empty")
+		}
+		return doc.AddFittedTemplatePage(tplid, scaling.Size.W/k, h*scaling.Size.W/k/w, FitStretch)
+	}
+	return doc.AddTemplatePage(tplid)
+}
+
+// Generate an outline (bookmarks) for the merged document, with an entry per
+// source pointing at its first page.  Entries are titled with the /Title of
+// the source's document information, or its file name without the extension,
+// and hold the outline of the source, if it has one.  Off by default, in
+// which case the document has the outline set with Document.SetOutlines.
+func (merger *Merger) SetTableOfContents(b bool) {
+	merger.tableOfContents = b
+}
+
+// Get the outline with an entry per source, see SetTableOfContents
+func (merger *Merger) getTableOfContents() ([]*Outline, error) {
+	result := make([]*Outline, 0, len(merger.sources))
+	for _, source := range merger.sources {
+		if source.pages == 0 {
+			continue
+		}
+
+		info, err := source.reader.getInfo()
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to get document information of "+source.name)
+		}
+		title := strings.TrimSpace(info.Title)
+		if title == "" {
+			title = strings.TrimSuffix(filepath.Base(source.name), filepath.Ext(source.name))
+		}
+
+		outlines, err := source.reader.getOutlines()
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to get outline of "+source.name)
+		}
+
+		result = append(result, &Outline{
+			Title:    title,
+			PageNo:   source.first + 1,
+			Children: source.mergedOutlines(outlines, 0),
+		})
+	}
+	return result, nil
+}
+
+// Get the entries of the outline of a source, pointing to its pages in the
+// merged document
+func (source *mergedSource) mergedOutlines(outlines []*Outline, depth int) []*Outline {
+	result := make([]*Outline, 0, len(outlines))
+	if depth > maxOutlineDepth {
+		return result
+	}
+
+	for _, outline := range outlines {
+		pageno := 0
+		if outline.PageNo >= 1 && outline.PageNo <= source.pages {
+			pageno = source.first + outline.PageNo
+		}
+		result = append(result, &Outline{
+			Title:    outline.Title,
+			PageNo:   pageno,
+			Open:     outline.Open,
+			Children: source.mergedOutlines(outline.Children, depth+1),
+		})
+	}
+	return result
+}
+
+// Write the merged document to a file
+func (merger *Merger) WriteFile(filename string) error {
+	err := merger.prepare()
+	if err != nil {
+		return err
+	}
+	return merger.doc.WriteFile(filename)
+}
+
+// Write the merged document
+func (merger *Merger) WriteTo(w io.Writer) (int64, error) {
+	err := merger.prepare()
+	if err != nil {
+		return 0, err
+	}
+	return merger.doc.WriteTo(w)
+}
+
+// Set up the document for writing
+func (merger *Merger) prepare() error {
+	if merger.tableOfContents {
+		outlines, err := merger.getTableOfContents()
+		if err != nil {
+			return err
+		}
+		merger.doc.SetOutlines(outlines)
+	}
+	merger.stampPageNumbers()
+	return nil
+}
@@ -0,0 +1,91 @@
+package gofpdi
+
+import (
+	"time"
+)
+
+// Metrics receives the counts and durations of the work of an importer, so
+// services can monitor it, e.g. with expvar or a Prometheus client.  Names are
+// the Metric constants.  Its methods may be called from several goroutines at
+// once, e.g. by ImportPages, and must not block.
+type Metrics interface {
+	// Add delta to a counter
+	Add(name string, delta float64)
+	// Set a gauge
+	Set(name string, value float64)
+	// Record the duration of an operation
+	Observe(name string, d time.Duration)
+}
+
+// Names of metrics, in the form Prometheus uses
+const (
+	// Counters
+	MetricPagesImported  = "gofpdi_pages_imported_total"
+	MetricSourcesOpened  = "gofpdi_sources_opened_total"
+	MetricSourceBytes    = "gofpdi_source_bytes_total"
+	MetricObjectsWritten = "gofpdi_objects_written_total"
+	MetricBytesWritten   = "gofpdi_bytes_written_total"
+	MetricCacheHits      = "gofpdi_cache_hits_total"
+	MetricCacheMisses    = "gofpdi_cache_misses_total"
+	MetricRecoveries     = "gofpdi_recoveries_total"
+
+	// Gauges
+	MetricSources   = "gofpdi_sources"
+	MetricTemplates = "gofpdi_templates"
+
+	// Durations
+	MetricOpenDuration   = "gofpdi_source_open_duration_seconds"
+	MetricImportDuration = "gofpdi_page_import_duration_seconds"
+	MetricWriteDuration  = "gofpdi_write_duration_seconds"
+)
+
+// Report to metrics, or to nothing if it is nil
+func addMetric(metrics Metrics, name string, delta float64) {
+	if metrics != nil {
+		metrics.Add(name, delta)
+	}
+}
+
+func setMetric(metrics Metrics, name string, value float64) {
+	if metrics != nil {
+		metrics.Set(name, value)
+	}
+}
+
+// Record the time since start; use as defer observeSince(metrics, name, time.Now())
+func observeSince(metrics Metrics, name string, start time.Time) {
+	if metrics != nil {
+		metrics.Observe(name, time.Since(start))
+	}
+}
+
+// Report cache hits and misses, and warnings and a rebuilt cross-reference
+// table as recoveries, to metrics.  Recoveries made before the call, such
+// as those made while the source was opened, are reported by the first call
+// with metrics other than nil.
+func (pdfReader *PdfReader) SetMetrics(metrics Metrics) {
+	pdfReader.metrics = metrics
+	if pdfReader.warnings.setMetrics(metrics) && pdfReader.xrefRebuilt {
+		metrics.Add(MetricRecoveries, 1)
+	}
+}
+
+// Report the objects written and their sizes, the time form xobjects take to
+// write and warnings as recoveries to metrics
+func (pdfWriter *PdfWriter) SetMetrics(metrics Metrics) {
+	pdfWriter.metrics = metrics
+}
+
+// Report the pages imported, the sources opened and their sizes, cache hits,
+// recoveries from damaged sources (see GetWarnings), the objects written for
+// the templates and their sizes, and the time each of these take to metrics,
+// see the Metric constants.  nil (the default) reports nothing.  Applies to
+// the sources opened before the call as well.
+func (importer *Importer) SetMetrics(metrics Metrics) {
+	importer.setWriterOptions(func(options *writerOptions) {
+		options.metrics = metrics
+	})
+	for _, reader := range importer.readers {
+		reader.SetMetrics(metrics)
+	}
+}
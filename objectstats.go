@@ -0,0 +1,212 @@
+package gofpdi
+
+import (
+	"sort"
+	"strconv"
+)
+
+// Kinds of written objects in an ObjectStatsReport
+const (
+	ObjectKindPage  = "page"
+	ObjectKindForm  = "form"
+	ObjectKindImage = "image"
+	ObjectKindFont  = "font"
+	ObjectKindOther = "other"
+)
+
+// The size of an object written for the imported pages and how it was
+// changed, see Importer.SetObjectStats
+type ObjectStat struct {
+	// The object of the source the object was copied from; the Id is 0 for
+	// the form xobjects of the pages
+	Source ObjectRef
+	// Object id, as in GetImportedObjects
+	Id int
+	// One of the ObjectKind constants
+	Kind string
+	// Size of the written object.  Placeholders (see PlaceholderVersion) are
+	// counted as object numbers.
+	Bytes int64
+	// Sizes of the stream data in the source and as written, 0 for objects
+	// that are not streams
+	SourceStreamBytes int64
+	StreamBytes       int64
+	// Filters of the stream in the source and as written, e.g. /FlateDecode
+	SourceFilters []string
+	Filters       []string
+	// What changed the stream: "colors converted", "downsampled",
+	// "compressed" or "font substituted"
+	Changes []string
+}
+
+// Totals of the objects of an ObjectStatsReport
+type ObjectStatsTotal struct {
+	Count             int
+	Bytes             int64
+	SourceStreamBytes int64
+	StreamBytes       int64
+}
+
+// The sizes of the objects written for the imported pages, see
+// Importer.GetObjectStats
+type ObjectStatsReport struct {
+	// The objects, largest first
+	Objects []ObjectStat
+	Total   ObjectStatsTotal
+	// Totals by kind, see the ObjectKind constants
+	ByKind map[string]ObjectStatsTotal
+}
+
+// Record the sizes of the objects written, see Importer.SetObjectStats
+func (pdfWriter *PdfWriter) SetObjectStats(b bool) {
+	pdfWriter.record_object_stats = b
+	if !b {
+		pdfWriter.object_stats = nil
+	} else if pdfWriter.object_stats == nil {
+		pdfWriter.object_stats = make(map[*PdfObjectId]*ObjectStat, 0)
+	}
+}
+
+// Record the sizes of the object that was just written, and get the record
+// or nil if sizes are not recorded.  original is the source object it was
+// copied from, referenced by ref, or nil for a form xobject of a page.
+func (pdfWriter *PdfWriter) recordObjectStat(pdfObjId *PdfObjectId, ref *PdfValue, original *PdfValue, written *PdfValue, changes []string) *ObjectStat {
+	if pdfWriter.object_stats == nil {
+		return nil
+	}
+
+	stat := &ObjectStat{Id: pdfObjId.id, Kind: ObjectKindPage, Changes: changes}
+	if data, ok := pdfWriter.written_objs[pdfObjId]; ok && data != nil {
+		stat.Bytes = int64(len(data))
+	} else if spilled, ok := pdfWriter.spilled_objs[pdfObjId]; ok {
+		stat.Bytes = int64(spilled.length)
+	} else if size, ok := pdfWriter.backend_objs[pdfObjId]; ok {
+		stat.Bytes = int64(size)
+	}
+	if pdfWriter.use_hash && !pdfWriter.hasOutputBackend() {
+		for _, hash := range pdfWriter.written_obj_pos[pdfObjId] {
+			stat.Bytes -= int64(len(hash) - len(strconv.Itoa(pdfObjId.id)))
+		}
+	}
+
+	if original != nil {
+		stat.Source = ObjectRef{Id: ref.Id, Gen: ref.Gen}
+		stat.Kind = objectKind(original)
+		if original.Type == PDF_TYPE_STREAM {
+			stat.SourceStreamBytes = original.streamLength()
+			stat.SourceFilters = pdfWriter.r.streamFilters(original)
+		}
+	}
+	if written != nil && written.Type == PDF_TYPE_STREAM {
+		stat.StreamBytes = written.streamLength()
+		stat.Filters = pdfWriter.r.streamFilters(written)
+	}
+
+	pdfWriter.object_stats[pdfObjId] = stat
+	return stat
+}
+
+// Get the kind of a source object, one of the ObjectKind constants
+func objectKind(obj *PdfValue) string {
+	value := obj
+	if obj.Value != nil {
+		value = obj.Value
+	}
+	if value.Type != PDF_TYPE_DICTIONARY {
+		return ObjectKindOther
+	}
+
+	dict := value.Dictionary
+	if subtype, ok := dict["/Subtype"]; ok {
+		switch subtype.Token {
+		case "/Image":
+			return ObjectKindImage
+		case "/Form":
+			return ObjectKindForm
+		case "/Type1C", "/CIDFontType0C", "/OpenType":
+			return ObjectKindFont
+		}
+	}
+	if t, ok := dict["/Type"]; ok && (t.Token == "/Font" || t.Token == "/FontDescriptor") {
+		return ObjectKindFont
+	}
+	for _, key := range []string{"/Length1", "/Length2", "/Length3"} {
+		if _, ok := dict[key]; ok {
+			return ObjectKindFont
+		}
+	}
+	return ObjectKindOther
+}
+
+// Get the names of the filters of a stream
+func (pdfReader *PdfReader) streamFilters(obj *PdfValue) []string {
+	filter, err := pdfReader.resolveDirect(obj.Value.Dictionary["/Filter"])
+	if err != nil || filter == nil {
+		return nil
+	}
+
+	filters := make([]string, 0, 1)
+	if filter.Type == PDF_TYPE_TOKEN {
+		filters = append(filters, filter.Token)
+	}
+	for _, f := range filter.Array {
+		if f, _ := pdfReader.resolveDirect(f); f != nil && f.Type == PDF_TYPE_TOKEN {
+			filters = append(filters, f.Token)
+		}
+	}
+	return filters
+}
+
+// Record the sizes of the objects written for the imported pages and how
+// they were changed, see GetObjectStats.  Off by default, as the records
+// take memory for each object.
+func (importer *Importer) SetObjectStats(b bool) {
+	importer.setWriterOptions(func(options *writerOptions) {
+		options.record_object_stats = b
+	})
+}
+
+// Get the sizes of the objects written for the imported pages of all
+// sources since SetObjectStats(true), and their totals, e.g. to find which
+// images make a document large and whether SetImageDownsampling or
+// SetCompressStreams would help.  Objects cleared with ClearImportedObjects
+// are still counted.
+func (importer *Importer) GetObjectStats() ObjectStatsReport {
+	report := ObjectStatsReport{Objects: make([]ObjectStat, 0), ByKind: make(map[string]ObjectStatsTotal, 0)}
+	for source, writer := range importer.writers {
+		for pdfObjId, stat := range writer.object_stats {
+			stat := *stat
+			if stat.Source.Id != 0 {
+				stat.Source.Source = source
+			}
+			if id, ok := importer.backendIds[pdfObjId.hash]; ok {
+				stat.Id = id
+			}
+			report.Objects = append(report.Objects, stat)
+
+			report.Total.add(stat)
+			total := report.ByKind[stat.Kind]
+			total.add(stat)
+			report.ByKind[stat.Kind] = total
+		}
+	}
+
+	sort.Slice(report.Objects, func(i, j int) bool {
+		a, b := report.Objects[i], report.Objects[j]
+		if a.Bytes != b.Bytes {
+			return a.Bytes > b.Bytes
+		}
+		if a.Source.Source != b.Source.Source {
+			return a.Source.Source < b.Source.Source
+		}
+		return a.Id < b.Id
+	})
+	return report
+}
+
+func (total *ObjectStatsTotal) add(stat ObjectStat) {
+	total.Count++
+	total.Bytes += stat.Bytes
+	total.SourceStreamBytes += stat.SourceStreamBytes
+	total.StreamBytes += stat.StreamBytes
+}
@@ -0,0 +1,326 @@
+package gofpdi
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/pkg/errors"
+)
+
+// Name of the font of text layers in the resources of their templates
+const textLayerFontName = "/GOFPDIOCR"
+
+// A page recognized by OCR, in the pixels (or other units) of the scan
+type OCRPage struct {
+	Width  float64
+	Height float64
+	Words  []OCRWord
+}
+
+// A word recognized by OCR and its bounding box (left, top, right, bottom),
+// from the top left corner of the scan
+type OCRWord struct {
+	Text string
+	Box  [4]float64
+}
+
+// Read the pages of hOCR output (e.g. of tesseract with the hocr config):
+// the ocr_page elements with the ocrx_word elements in them.  Files that are
+// HTML rather than XHTML are read too.
+func ParseHOCR(r io.Reader) ([]OCRPage, error) {
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+
+	pages := make([]OCRPage, 0)
+	var word *OCRWord
+	var text strings.Builder
+	wordDepth, depth := 0, 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to parse hOCR")
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			class := strings.Fields(xmlAttr(t, "class"))
+			if hasWord(class, "ocr_page") {
+				box, ok := hocrBox(xmlAttr(t, "title"))
+				if !ok {
+					return nil, errors.New("hOCR page has no bbox")
+				}
+				pages = append(pages, OCRPage{Width: box[2] - box[0], Height: box[3] - box[1], Words: make([]OCRWord, 0)})
+			} else if hasWord(class, "ocrx_word") && word == nil {
+				box, ok := hocrBox(xmlAttr(t, "title"))
+				if !ok {
+					continue
+				}
+				word = &OCRWord{Box: box}
+				wordDepth = depth
+				text.Reset()
+			}
+		case xml.CharData:
+			if word != nil {
+				text.Write(t)
+			}
+		case xml.EndElement:
+			if word != nil && depth == wordDepth {
+				word.Text = strings.TrimSpace(text.String())
+				if len(pages) == 0 {
+					return nil, errors.New("hOCR word outside of a page")
+				}
+				if word.Text != "" {
+					page := &pages[len(pages)-1]
+					page.Words = append(page.Words, *word)
+				}
+				word = nil
+			}
+			depth--
+		}
+	}
+
+	if len(pages) == 0 {
+		return nil, errors.New("No pages in hOCR")
+	}
+	return pages, nil
+}
+
+// Get the bbox property of the title of an hOCR element
+func hocrBox(title string) ([4]float64, bool) {
+	var box [4]float64
+	for _, property := range strings.Split(title, ";") {
+		fields := strings.Fields(property)
+		if len(fields) != 5 || fields[0] != "bbox" {
+			continue
+		}
+		for i := range box {
+			v, err := strconv.ParseFloat(fields[i+1], 64)
+			if err != nil {
+				return box, false
+			}
+			box[i] = v
+		}
+		return box, box[2] > box[0] && box[3] > box[1]
+	}
+	return box, false
+}
+
+// Read the pages of ALTO XML: the Page elements with the String elements in
+// them.  Positions are in the measurement unit of the file, whichever it is.
+func ParseALTO(r io.Reader) ([]OCRPage, error) {
+	decoder := xml.NewDecoder(r)
+
+	pages := make([]OCRPage, 0)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to parse ALTO")
+		}
+
+		t, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch t.Name.Local {
+		case "Page":
+			w, errW := strconv.ParseFloat(xmlAttr(t, "WIDTH"), 64)
+			h, errH := strconv.ParseFloat(xmlAttr(t, "HEIGHT"), 64)
+			if errW != nil || errH != nil || w <= 0 || h <= 0 {
+				return nil, errors.New("ALTO page has no size")
+			}
+			pages = append(pages, OCRPage{Width: w, Height: h, Words: make([]OCRWord, 0)})
+		case "String":
+			if len(pages) == 0 {
+				return nil, errors.New("ALTO string outside of a page")
+			}
+			var pos [4]float64
+			valid := true
+			for i, name := range []string{"HPOS", "VPOS", "WIDTH", "HEIGHT"} {
+				v, err := strconv.ParseFloat(xmlAttr(t, name), 64)
+				if err != nil {
+					valid = false
+				}
+				pos[i] = v
+			}
+			text := strings.TrimSpace(xmlAttr(t, "CONTENT"))
+			if !valid || text == "" || pos[2] <= 0 || pos[3] <= 0 {
+				continue
+			}
+			page := &pages[len(pages)-1]
+			page.Words = append(page.Words, OCRWord{Text: text, Box: [4]float64{pos[0], pos[1], pos[0] + pos[2], pos[1] + pos[3]}})
+		}
+	}
+
+	if len(pages) == 0 {
+		return nil, errors.New("No pages in ALTO")
+	}
+	return pages, nil
+}
+
+// Get the value of an attribute of an element, by its local name
+func xmlAttr(element xml.StartElement, name string) string {
+	for _, attr := range element.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// Determine if a list of words has word
+func hasWord(words []string, word string) bool {
+	for _, w := range words {
+		if w == word {
+			return true
+		}
+	}
+	return false
+}
+
+// Create a template that draws template tplid (returned from ImportPage) of
+// a scanned page with the words recognized by OCR over it, as invisible text
+// (text render mode 3), so the page can be searched and its text selected and
+// copied.  The OCR page is stretched over the whole template; each word is
+// scaled to fill its box.  The text is written with a font that has no glyphs
+// and maps its codes to the characters of the words, so any script can be
+// searched.  The new template is written with the form xobjects of the source
+// of tplid, after it.
+func (importer *Importer) AddTextLayer(tplid int, page OCRPage) (int, error) {
+	if page.Width <= 0 || page.Height <= 0 {
+		return -1, errors.New("OCR page is empty")
+	}
+
+	w, h, err := importer.GetTemplateSize(tplid, 0, 0)
+	if err != nil {
+		return -1, err
+	}
+
+	layerid, err := importer.ComposeTemplates(w, h, []TemplatePlacement{{TplId: tplid}})
+	if err != nil {
+		return -1, err
+	}
+	tplInfo := importer.tplMap[layerid]
+	tpl := tplInfo.Writer.tpls[tplInfo.TemplateId]
+
+	// Character codes by character, from 1
+	codes := make(map[rune]int, 0)
+	tpl.textLayer = make([]rune, 0)
+
+	sx := w * importer.options.k / page.Width
+	sy := h * importer.options.k / page.Height
+
+	var buf bytes.Buffer
+	buf.WriteString("q BT 3 Tr\n")
+	for _, word := range page.Words {
+		runes := []rune(word.Text)
+		if len(runes) == 0 || word.Box[2] <= word.Box[0] || word.Box[3] <= word.Box[1] {
+			continue
+		}
+
+		x := word.Box[0] * sx
+		width := (word.Box[2] - word.Box[0]) * sx
+		size := (word.Box[3] - word.Box[1]) * sy
+		// The glyphs are half as wide as they are high
+		scale := 100 * width / (float64(len(runes)) * size / 2)
+		// The baseline is a fifth of the size above the bottom of the box
+		y := h*importer.options.k - word.Box[3]*sy + size/5
+
+		buf.WriteString(fmt.Sprintf("%s %.2F Tf %.2F Tz 1 0 0 1 %.2F %.2F Tm <", textLayerFontName, size, scale, x, y))
+		for _, r := range runes {
+			code, ok := codes[r]
+			if !ok {
+				if len(codes) >= 0xfffe {
+					return -1, errors.New("Too many different characters in OCR page")
+				}
+				tpl.textLayer = append(tpl.textLayer, r)
+				code = len(tpl.textLayer)
+				codes[r] = code
+			}
+			buf.WriteString(fmt.Sprintf("%04X", code))
+		}
+		buf.WriteString("> Tj\n")
+	}
+	buf.WriteString("ET Q\n")
+
+	tpl.Buffer = append(tpl.Buffer, buf.Bytes()...)
+	return layerid, nil
+}
+
+// Output the font resource of the text layer of a template, with an object
+// id for each object of the font, see putTextLayerFont
+func (pdfWriter *PdfWriter) writeTextLayerResource(tpl *PdfTemplate) {
+	tpl.textLayerFont = [4]int{pdfWriter.allocObjId(0), pdfWriter.allocObjId(0), pdfWriter.allocObjId(0), pdfWriter.allocObjId(0)}
+	pdfWriter.straightOut("/Font <<")
+	pdfWriter.outToken(textLayerFontName)
+	pdfWriter.outObjRef(tpl.textLayerFont[0])
+	pdfWriter.straightOut(">>")
+}
+
+// Output the objects of the font of the text layer of a template: a composite
+// font with no glyphs, its CID font and descriptor, and the /ToUnicode map of
+// its codes
+func (pdfWriter *PdfWriter) putTextLayerFont(tpl *PdfTemplate) {
+	ids := tpl.textLayerFont
+
+	pdfWriter.newObj(ids[0], false)
+	pdfWriter.straightOut("<</Type /Font /Subtype /Type0 /BaseFont /GlyphLessFont /Encoding /Identity-H /DescendantFonts [")
+	pdfWriter.outObjRef(ids[1])
+	pdfWriter.straightOut("] /ToUnicode ")
+	pdfWriter.outObjRef(ids[3])
+	pdfWriter.out(">>")
+	pdfWriter.endObj()
+
+	pdfWriter.newObj(ids[1], false)
+	pdfWriter.straightOut("<</Type /Font /Subtype /CIDFontType2 /BaseFont /GlyphLessFont /CIDSystemInfo <</Registry (Adobe) /Ordering (Identity) /Supplement 0>> /DW 500 /CIDToGIDMap /Identity /FontDescriptor ")
+	pdfWriter.outObjRef(ids[2])
+	pdfWriter.out(">>")
+	pdfWriter.endObj()
+
+	pdfWriter.newObj(ids[2], false)
+	pdfWriter.out("<</Type /FontDescriptor /FontName /GlyphLessFont /Flags 4 /FontBBox [0 -200 500 800] /ItalicAngle 0 /Ascent 800 /Descent -200 /CapHeight 800 /StemV 80>>")
+	pdfWriter.endObj()
+
+	var cmap bytes.Buffer
+	cmap.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n")
+	cmap.WriteString("/CIDSystemInfo <</Registry (Adobe) /Ordering (UCS) /Supplement 0>> def\n")
+	cmap.WriteString("/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n")
+	cmap.WriteString("1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	for i := 0; i < len(tpl.textLayer); i += 100 {
+		n := len(tpl.textLayer) - i
+		if n > 100 {
+			n = 100
+		}
+		cmap.WriteString(fmt.Sprintf("%d beginbfchar\n", n))
+		for j, r := range tpl.textLayer[i : i+n] {
+			cmap.WriteString(fmt.Sprintf("<%04X> <", i+j+1))
+			for _, unit := range utf16.Encode([]rune{r}) {
+				cmap.WriteString(fmt.Sprintf("%04X", unit))
+			}
+			cmap.WriteString(">\n")
+		}
+		cmap.WriteString("endbfchar\n")
+	}
+	cmap.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+
+	pdfWriter.newObj(ids[3], false)
+	pdfWriter.out(fmt.Sprintf("<</Length %d>>", cmap.Len()))
+	pdfWriter.out("stream")
+	pdfWriter.straightOut(cmap.String())
+	pdfWriter.out("endstream")
+	pdfWriter.endObj()
+}
@@ -0,0 +1,49 @@
+package gofpdi
+
+import (
+	"github.com/pkg/errors"
+)
+
+// A template imported by ImportPageTo
+type ImportedTemplate struct {
+	// Template name, as passed to MapPlaceholder of the backend (e.g. /GOFPDITPL0)
+	Name string
+	// Size of the template in points
+	W float64
+	H float64
+}
+
+// Import a page of a source and put its form xobject and the objects it uses
+// into backend, without keeping any state: the importer and reader used are
+// discarded when it returns, e.g. for functions that handle one request per
+// invocation.  The source is not closed.  The template's box starts at the
+// origin of its form xobject, so "q 1 0 0 1 x y cm <name> Do Q" draws it at
+// x, y at its own size.  The name is the same for every call, so when several
+// pages go into one backend, take the id of each from MapPlaceholder before the
+// next call.
+func ImportPageTo(source Source, pageno int, box BoxName, backend OutputBackend) (*ImportedTemplate, error) {
+	importer := NewImporter()
+
+	err := importer.SetSource("source", source)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read source")
+	}
+
+	tplid, err := importer.ImportPage(pageno, box)
+	if err != nil {
+		return nil, err
+	}
+
+	err = importer.PutFormXobjectsTo(backend)
+	if err != nil {
+		return nil, err
+	}
+
+	name, _, _, _, _ := importer.UseTemplate(tplid, 0, 0, 0, 0)
+	w, h, err := importer.GetTemplateSize(tplid, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ImportedTemplate{Name: name, W: w, H: h}, nil
+}
@@ -0,0 +1,40 @@
+package gofpdi
+
+// The orientation that imported pages are turned to, see Importer.SetOrientation
+type Orientation int
+
+const (
+	// Pages keep their orientation
+	OrientationAny Orientation = iota
+	// Pages that are wider than high are turned
+	OrientationPortrait
+	// Pages that are higher than wide are turned
+	OrientationLandscape
+)
+
+// Turn imported pages to an orientation, see Importer.SetOrientation
+func (pdfWriter *PdfWriter) SetOrientation(orientation Orientation) {
+	pdfWriter.orientation = orientation
+}
+
+// Get the rotation (clockwise, 0 to 270) that shows a page of w by h, with
+// rotation angle from its /Rotate, in the orientation of the writer
+func (pdfWriter *PdfWriter) orientPage(angle int, w float64, h float64) int {
+	if angle%180 != 0 {
+		w, h = h, w
+	}
+	if (pdfWriter.orientation == OrientationPortrait && w > h) || (pdfWriter.orientation == OrientationLandscape && h > w) {
+		return (angle + 90) % 360
+	}
+	return angle
+}
+
+// Turn pages imported after the call a quarter turn clockwise when, as
+// displayed (with their /Rotate), they do not have the given orientation, so
+// all pages of a merged document are e.g. portrait.  The template sizes are
+// those of the turned pages.  Square pages are not turned.
+func (importer *Importer) SetOrientation(orientation Orientation) {
+	importer.setWriterOptions(func(options *writerOptions) {
+		options.orientation = orientation
+	})
+}
@@ -0,0 +1,331 @@
+package gofpdi
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// An entry of the document outline (bookmarks)
+type Outline struct {
+	Title string
+	// Page the entry points to, 0 if it does not point to a page of the document
+	PageNo int
+	// The children are shown, rather than collapsed
+	Open     bool
+	Children []*Outline
+}
+
+// Outline trees deeper than this are not read
+const maxOutlineDepth = 64
+
+// Get the document outline.  Entries that are reached twice are only read once.
+func (pdfReader *PdfReader) getOutlines() ([]*Outline, error) {
+	outlines, err := pdfReader.resolveDirect(pdfReader.catalog.Value.Dictionary["/Outlines"])
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to resolve /Outlines")
+	}
+	if outlines == nil || outlines.Type != PDF_TYPE_DICTIONARY {
+		return []*Outline{}, nil
+	}
+
+	pageNos, err := pdfReader.getPageNumbers()
+	if err != nil {
+		return nil, err
+	}
+
+	return pdfReader.readOutlineItems(outlines.Dictionary["/First"], pageNos, make(map[int]bool, 0), 0)
+}
+
+// Get page numbers by page object id
+func (pdfReader *PdfReader) getPageNumbers() (map[int]int, error) {
+	numPages, err := pdfReader.getNumPages()
+	if err != nil {
+		return nil, err
+	}
+
+	pageNos := make(map[int]int, numPages)
+	for pageno := 1; pageno <= numPages; pageno++ {
+		page, err := pdfReader.getPage(pageno)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to get page")
+		}
+		pageNos[page.Id] = pageno
+	}
+
+	return pageNos, nil
+}
+
+// Read an outline item and its siblings
+func (pdfReader *PdfReader) readOutlineItems(ref *PdfValue, pageNos map[int]int, visited map[int]bool, depth int) ([]*Outline, error) {
+	result := make([]*Outline, 0)
+	if depth > maxOutlineDepth {
+		return result, nil
+	}
+
+	for ref != nil {
+		if ref.Type == PDF_TYPE_OBJREF {
+			if visited[ref.Id] {
+				break
+			}
+			visited[ref.Id] = true
+		}
+
+		item, err := pdfReader.resolveDirect(ref)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to resolve outline item")
+		}
+		if item == nil || item.Type != PDF_TYPE_DICTIONARY {
+			break
+		}
+
+		outline := &Outline{}
+		if title, ok := item.Dictionary["/Title"]; ok {
+			title, err = pdfReader.resolveDirect(title)
+			if err != nil {
+				return nil, errors.Wrap(err, "Failed to resolve /Title")
+			}
+			outline.Title = decodeTextString(stringBytes(title))
+		}
+		if count, ok := item.Dictionary["/Count"]; ok && count.Type == PDF_TYPE_NUMERIC {
+			outline.Open = count.Int > 0
+		}
+
+		dest := item.Dictionary["/Dest"]
+		if dest == nil {
+			action, err := pdfReader.resolveDirect(item.Dictionary["/A"])
+			if err != nil {
+				return nil, errors.Wrap(err, "Failed to resolve /A")
+			}
+			if action != nil && action.Type == PDF_TYPE_DICTIONARY {
+				if s, ok := action.Dictionary["/S"]; ok && s.Token == "/GoTo" {
+					dest = action.Dictionary["/D"]
+				}
+			}
+		}
+		outline.PageNo, err = pdfReader.destinationPage(dest, pageNos)
+		if err != nil {
+			return nil, err
+		}
+
+		outline.Children, err = pdfReader.readOutlineItems(item.Dictionary["/First"], pageNos, visited, depth+1)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, outline)
+		ref = item.Dictionary["/Next"]
+	}
+
+	return result, nil
+}
+
+// Get the page number of a destination: an explicit destination array, or the
+// name of one in /Dests or the /Dests name tree.  Returns 0 if the destination
+// does not point to a page of the document.
+func (pdfReader *PdfReader) destinationPage(dest *PdfValue, pageNos map[int]int) (int, error) {
+	dest, err := pdfReader.resolveDirect(dest)
+	if err != nil {
+		return 0, errors.Wrap(err, "Failed to resolve destination")
+	}
+	if dest == nil {
+		return 0, nil
+	}
+
+	switch dest.Type {
+	case PDF_TYPE_TOKEN, PDF_TYPE_STRING, PDF_TYPE_HEX:
+		dest, err = pdfReader.namedDestination(dest)
+		if err != nil {
+			return 0, err
+		}
+		if dest == nil {
+			return 0, nil
+		}
+	}
+
+	// A dictionary with the destination in /D
+	if dest.Type == PDF_TYPE_DICTIONARY {
+		dest, err = pdfReader.resolveDirect(dest.Dictionary["/D"])
+		if err != nil {
+			return 0, errors.Wrap(err, "Failed to resolve destination")
+		}
+		if dest == nil {
+			return 0, nil
+		}
+	}
+
+	if dest.Type != PDF_TYPE_ARRAY || len(dest.Array) == 0 || dest.Array[0].Type != PDF_TYPE_OBJREF {
+		return 0, nil
+	}
+
+	return pageNos[dest.Array[0].Id], nil
+}
+
+// Look up a named destination
+func (pdfReader *PdfReader) namedDestination(name *PdfValue) (*PdfValue, error) {
+	catalog := pdfReader.catalog.Value
+
+	// Names of PDF 1.1 are names, looked up in /Dests of the catalog
+	if name.Type == PDF_TYPE_TOKEN {
+		dests, err := pdfReader.resolveDirect(catalog.Dictionary["/Dests"])
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to resolve /Dests")
+		}
+		if dests == nil || dests.Type != PDF_TYPE_DICTIONARY {
+			return nil, nil
+		}
+		return pdfReader.resolveDirect(dests.Dictionary[name.Token])
+	}
+
+	names, err := pdfReader.resolveDirect(catalog.Dictionary["/Names"])
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to resolve /Names")
+	}
+	if names == nil || names.Type != PDF_TYPE_DICTIONARY {
+		return nil, nil
+	}
+
+	key := decodeTextString(stringBytes(name))
+	var result *PdfValue
+	err = pdfReader.walkNameTree(names.Dictionary["/Dests"], func(k string, value *PdfValue) error {
+		if result == nil && k == key {
+			result = value
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read /Dests name tree")
+	}
+
+	return pdfReader.resolveDirect(result)
+}
+
+// Get the page number of a named destination.  The name is looked up in the
+// /Dests name tree and, as a name, in /Dests of the catalog.
+func (pdfReader *PdfReader) getDestinationPage(name string) (int, error) {
+	pageNos, err := pdfReader.getPageNumbers()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, key := range []*PdfValue{encodeTextString(name), {Type: PDF_TYPE_TOKEN, Token: "/" + strings.TrimPrefix(name, "/")}} {
+		dest, err := pdfReader.namedDestination(key)
+		if err != nil {
+			return 0, err
+		}
+		if dest == nil {
+			continue
+		}
+
+		pageno, err := pdfReader.destinationPage(dest, pageNos)
+		if err != nil {
+			return 0, err
+		}
+		if pageno == 0 {
+			return 0, errors.New("Destination does not point to a page of the document: " + name)
+		}
+		return pageno, nil
+	}
+
+	return 0, errors.New("Destination not found: " + name)
+}
+
+// Import the page a named destination of the current source points to, e.g.
+// a chapter whose page number changes between versions of a document
+func (importer *Importer) ImportPageByDestination(name string, box BoxName) (int, error) {
+	pageno, err := importer.GetReader().getDestinationPage(name)
+	if err != nil {
+		return 0, err
+	}
+
+	return importer.ImportPage(pageno, box)
+}
+
+// Get the document outline (bookmarks) of the current source
+func (importer *Importer) GetOutlines() ([]*Outline, error) {
+	return importer.GetReader().getOutlines()
+}
+
+// Set the document outline (bookmarks), with the PageNo of the entries a page
+// of the document.  Entries with a PageNo of 0 have no destination.  nil for
+// no outline.
+func (doc *Document) SetOutlines(outlines []*Outline) {
+	doc.outlines = outlines
+}
+
+// Get the number of entries of an outline that are shown, the entries and
+// the shown entries of their open children
+func visibleOutlines(outlines []*Outline, depth int) int {
+	if depth > maxOutlineDepth {
+		return 0
+	}
+
+	n := 0
+	for _, outline := range outlines {
+		n++
+		if outline.Open {
+			n += visibleOutlines(outline.Children, depth+1)
+		}
+	}
+	return n
+}
+
+// Write the outline of the document from object id nextId.  Returns the
+// catalog entry for it and the next free object id.
+func (doc *Document) putOutlines(objects map[int][]byte, pageIds []int, nextId int) (string, int) {
+	if len(doc.outlines) == 0 {
+		return "", nextId
+	}
+
+	// Write the items of a level, and their children, and get the ids of the
+	// first and the last item
+	var putItems func(outlines []*Outline, parent int, depth int) (int, int)
+	putItems = func(outlines []*Outline, parent int, depth int) (int, int) {
+		if depth > maxOutlineDepth || len(outlines) == 0 {
+			return 0, 0
+		}
+
+		ids := make([]int, len(outlines))
+		for i := range outlines {
+			ids[i] = nextId
+			nextId++
+		}
+
+		for i, outline := range outlines {
+			var b bytes.Buffer
+			b.WriteString("<< /Title ")
+			writeContentValue(&b, encodeTextString(outline.Title))
+			b.WriteString(fmt.Sprintf(" /Parent %d 0 R", parent))
+			if i > 0 {
+				b.WriteString(fmt.Sprintf(" /Prev %d 0 R", ids[i-1]))
+			}
+			if i < len(ids)-1 {
+				b.WriteString(fmt.Sprintf(" /Next %d 0 R", ids[i+1]))
+			}
+			if first, last := putItems(outline.Children, ids[i], depth+1); first != 0 {
+				// Closed items count their children negatively
+				count := visibleOutlines(outline.Children, depth+1)
+				if !outline.Open {
+					count = -len(outline.Children)
+				}
+				b.WriteString(fmt.Sprintf(" /First %d 0 R /Last %d 0 R /Count %d", first, last, count))
+			}
+			if outline.PageNo >= 1 && outline.PageNo <= len(pageIds) {
+				b.WriteString(fmt.Sprintf(" /Dest [%d 0 R /Fit]", pageIds[outline.PageNo-1]))
+			}
+			b.WriteString(" >>\nendobj\n")
+			objects[ids[i]] = b.Bytes()
+		}
+
+		return ids[0], ids[len(ids)-1]
+	}
+
+	rootId := nextId
+	nextId++
+	first, last := putItems(doc.outlines, rootId, 0)
+	objects[rootId] = []byte(fmt.Sprintf("<< /Type /Outlines /First %d 0 R /Last %d 0 R /Count %d >>\nendobj\n", first, last, visibleOutlines(doc.outlines, 0)))
+
+	return fmt.Sprintf(" /Outlines %d 0 R", rootId), nextId
+}
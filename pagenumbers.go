@@ -0,0 +1,117 @@
+package gofpdi
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Where page numbers are stamped on a page, see PageNumbers
+type PageNumberPosition int
+
+const (
+	PageNumberBottomCenter PageNumberPosition = iota
+	PageNumberBottomLeft
+	PageNumberBottomRight
+	PageNumberTopCenter
+	PageNumberTopLeft
+	PageNumberTopRight
+)
+
+// Page numbers stamped on the pages of a merged document, see
+// Merger.SetPageNumbers.  Sizes and distances are in points.
+type PageNumbers struct {
+	// Text of the page numbers, in which {page} is replaced by the page
+	// number and {pages} by the page count, "Page {page} of {pages}" if ""
+	Format string
+	// One of the standard fonts Helvetica, Helvetica-Bold, Times-Roman,
+	// Courier or Courier-Bold, Helvetica if ""
+	Font string
+	// Font size, 10 if 0
+	Size     float64
+	Position PageNumberPosition
+	// Distance of the baseline or the side of the text from the edges of the
+	// page, 24 if 0
+	Margin float64
+	// Added to the page numbers and the page count, e.g. 2 to number the
+	// pages of a document that is bound after a cover and a title page
+	Offset int
+	// Number of the first page that is stamped, for pages such as a cover
+	// that are counted but have no number; 1 if 0
+	FirstPage int
+	// Color of the text, red, green and blue from 0 to 1
+	Color [3]float64
+}
+
+// Get the page numbers with the defaults filled in
+func (numbers PageNumbers) withDefaults() PageNumbers {
+	if numbers.Format == "" {
+		numbers.Format = "Page {page} of {pages}"
+	}
+	if numbers.Font == "" {
+		numbers.Font = "Helvetica"
+	}
+	if numbers.Size <= 0 {
+		numbers.Size = 10
+	}
+	if numbers.Margin <= 0 {
+		numbers.Margin = 24
+	}
+	if numbers.FirstPage <= 0 {
+		numbers.FirstPage = 1
+	}
+	return numbers
+}
+
+// Stamp page numbers on the pages of the merged document when it is written,
+// nil for none
+func (merger *Merger) SetPageNumbers(numbers *PageNumbers) error {
+	if numbers != nil {
+		n := numbers.withDefaults()
+		if err := checkStandardFont(n.Font); err != nil {
+			return err
+		}
+		numbers = &n
+	}
+	merger.pageNumbers = numbers
+	return nil
+}
+
+// Put the page numbers onto the pages of the document, replacing those of
+// the last write
+func (merger *Merger) stampPageNumbers() {
+	pages := merger.doc.pages
+	for _, page := range pages {
+		page.texts = nil
+	}
+	numbers := merger.pageNumbers
+	if numbers == nil {
+		return
+	}
+
+	total := strconv.Itoa(len(pages) + numbers.Offset)
+	for i, page := range pages {
+		if i+1 < numbers.FirstPage || merger.blankPages[i] {
+			continue
+		}
+
+		replacer := strings.NewReplacer("{page}", strconv.Itoa(i+1+numbers.Offset), "{pages}", total)
+		text := winAnsiText(replacer.Replace(numbers.Format))
+
+		// The page is in user space units
+		w, h := page.w*page.userUnit, page.h*page.userUnit
+		width := standardTextWidth(numbers.Font, text) * numbers.Size
+		x, y := (w-width)/2, numbers.Margin
+		switch numbers.Position {
+		case PageNumberBottomLeft, PageNumberTopLeft:
+			x = numbers.Margin
+		case PageNumberBottomRight, PageNumberTopRight:
+			x = w - numbers.Margin - width
+		}
+		switch numbers.Position {
+		case PageNumberTopCenter, PageNumberTopLeft, PageNumberTopRight:
+			y = h - numbers.Margin - numbers.Size
+		}
+
+		page.texts = append(page.texts, pageText{text: text, font: numbers.Font, size: numbers.Size, x: x, y: y, color: numbers.Color})
+	}
+}
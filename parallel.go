@@ -0,0 +1,188 @@
+package gofpdi
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Get a reader for the same source that can be used from another goroutine.
+// It shares the parsed cross reference table, page tree and object cache, and
+// reads the source through its own io.SectionReader.
+func (pdfReader *PdfReader) clone() (*PdfReader, error) {
+	ra, ok := pdfReader.f.(io.ReaderAt)
+	if !ok {
+		return nil, errors.New("Source does not support concurrent reads")
+	}
+
+	pdfReader.pagesMu.Lock()
+	defer pdfReader.pagesMu.Unlock()
+
+	clone := *pdfReader
+	clone.f = io.NewSectionReader(ra, 0, pdfReader.nBytes)
+	clone.stack = nil
+	clone.closer = nil
+	clone.pages = append([]*PdfValue(nil), pdfReader.pages...)
+	clone.parseMu = &sync.Mutex{}
+	clone.pagesMu = &sync.Mutex{}
+
+	return &clone, nil
+}
+
+// Resolve every object that can be reached from value, so they are in the
+// object cache when they are written, stopping with the error of
+// checkDeadline before an object is resolved
+func (pdfReader *PdfReader) resolveGraph(value *PdfValue, checkDeadline func() error) error {
+	seen := make(map[int]bool, 0)
+	values := []*PdfValue{value}
+
+	for len(values) > 0 {
+		v := values[len(values)-1]
+		values = values[:len(values)-1]
+		if v == nil {
+			continue
+		}
+
+		switch v.Type {
+		case PDF_TYPE_OBJREF:
+			if seen[v.Id] {
+				continue
+			}
+			seen[v.Id] = true
+
+			if err := checkDeadline(); err != nil {
+				return err
+			}
+			obj, err := pdfReader.resolveObject(v)
+			if err != nil {
+				return errors.Wrap(err, fmt.Sprintf("Failed to resolve object %d", v.Id))
+			}
+			values = append(values, obj.Value)
+		case PDF_TYPE_DICTIONARY:
+			for _, entry := range v.Dictionary {
+				values = append(values, entry)
+			}
+		case PDF_TYPE_ARRAY:
+			values = append(values, v.Array...)
+		}
+	}
+
+	return nil
+}
+
+// Import pages of the current source using up to workers goroutines (0 for
+// the number of CPUs) and get their template ids, in the order of pagenos.
+// Each goroutine builds page templates, compresses their content and resolves
+// the objects they use; the templates are then added in the order of pagenos,
+// so template ids and the written objects are the same as when importing the
+// pages one by one.
+// Sources that are not files or io.ReaderAt streams are imported sequentially.
+func (importer *Importer) ImportPages(pagenos []int, box BoxName, workers int) ([]int, error) {
+	box, err := box.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	reader := importer.GetReader()
+	writer := importer.GetWriter()
+	defer writer.startDeadline()()
+
+	// Find the pages that still have to be imported
+	todo := make([]int, 0, len(pagenos))
+	queued := make(map[int]bool, 0)
+	for _, pageno := range pagenos {
+		pageNameNumber := pageKey(importer.sourceFile, pageno, box)
+		if _, ok := importer.importedPages[pageNameNumber]; ok || queued[pageno] {
+			continue
+		}
+
+		// Read the page tree before it is shared by the readers of the workers
+		if _, err := reader.getPage(pageno); err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("Failed to get page %d", pageno))
+		}
+
+		todo = append(todo, pageno)
+		queued[pageno] = true
+	}
+
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > len(todo) {
+		workers = len(todo)
+	}
+
+	readers := make([]*PdfReader, 0, workers)
+	for i := 0; i < workers; i++ {
+		clone, err := reader.clone()
+		if err != nil {
+			readers = []*PdfReader{reader}
+			break
+		}
+		readers = append(readers, clone)
+	}
+
+	// Build the templates
+	tpls := make([]*PdfTemplate, len(todo))
+	errs := make([]error, len(todo))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for _, r := range readers {
+		wg.Add(1)
+		go func(r *PdfReader) {
+			defer wg.Done()
+			for i := range jobs {
+				start := time.Now()
+				tpl, err := writer.newTemplate(r, todo[i], box)
+				if err == nil {
+					err = r.resolveGraph(tpl.Resources, writer.checkDeadline)
+				}
+				if err == nil {
+					err = r.resolveGraph(tpl.Group, writer.checkDeadline)
+				}
+				if err == nil {
+					_, err = writer.templateStream(tpl, true)
+				}
+				if err == nil {
+					observeSince(importer.options.metrics, MetricImportDuration, start)
+				}
+				tpls[i], errs[i] = tpl, err
+			}
+		}(r)
+	}
+	for i := range todo {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("Failed to import page %d", todo[i]))
+		}
+	}
+
+	// Add the templates in order
+	for _, tpl := range tpls {
+		tpl.Reader = reader
+		res := writer.addTemplate(tpl)
+
+		importer.tplMap[importer.tplN] = &TplInfo{SourceFile: importer.sourceFile, TemplateId: res, Writer: writer, Box: tpl.boxName}
+		importer.importedPages[pageKey(importer.sourceFile, tpl.PageNo, box)] = importer.tplN
+		importer.tplN++
+	}
+	addMetric(importer.options.metrics, MetricPagesImported, float64(len(tpls)))
+	setMetric(importer.options.metrics, MetricTemplates, float64(importer.tplN))
+
+	result := make([]int, len(pagenos))
+	for i, pageno := range pagenos {
+		result[i] = importer.importedPages[pageKey(importer.sourceFile, pageno, box)]
+	}
+
+	return result, nil
+}
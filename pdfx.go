@@ -0,0 +1,295 @@
+package gofpdi
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// The printing condition and document metadata of a PDF/X-4 document, see
+// Document.SetPDFX
+type PDFXOptions struct {
+	// Identifier of the printing condition, e.g. "FOGRA39" or "CGATS TR 006"
+	OutputConditionIdentifier string
+	// Description of the printing condition, optional
+	OutputCondition string
+	// Registry of the identifier (http://www.color.org if empty)
+	RegistryName string
+	// ICC profile of the printing condition, an output (prtr) profile
+	Profile []byte
+	// Title of the document, required by PDF/X
+	Title string
+	// Whether the document has been trapped
+	Trapped bool
+	// Creation date of the document.  If zero, the time of writing is used,
+	// which is not allowed in reproducible mode (see Importer.SetReproducible).
+	Date time.Time
+}
+
+// Color spaces of ICC profiles and their number of components
+var iccColorSpaces = map[string]int{"GRAY": 1, "RGB ": 3, "CMYK": 4}
+
+// Uses of uncalibrated RGB: the color space in dictionaries, but not as the
+// alternate of an ICC based color space, which is not used for printing
+var deviceRGBPattern = regexp.MustCompile(`/DeviceRGB\b`)
+var alternateRGBPattern = regexp.MustCompile(`/Alternate\s*/DeviceRGB\b`)
+
+// Write the document as PDF/X-4 for the printing condition of options, or as
+// a plain document if options is nil.  WriteTo then adds the output intent,
+// the document information and XMP metadata that PDF/X requires, and fails if
+// a page has no trim box or if its boxes are not nested (the trim box in the
+// bleed box in the media box).  Pages made with AddTemplatePage keep the boxes
+// of the imported page; others need SetPageBox.  Content in RGB is not
+// converted, but reported by GetPDFXWarnings if the output intent is not RGB.
+func (doc *Document) SetPDFX(options *PDFXOptions) error {
+	if options == nil {
+		doc.pdfx = nil
+		return nil
+	}
+
+	if options.OutputConditionIdentifier == "" {
+		return errors.New("PDF/X requires an output condition identifier")
+	}
+	if options.Title == "" {
+		return errors.New("PDF/X requires a title")
+	}
+	_, err := iccComponents(options.Profile)
+	if err != nil {
+		return errors.Wrap(err, "Invalid output profile")
+	}
+
+	copied := *options
+	if copied.RegistryName == "" {
+		copied.RegistryName = "http://www.color.org"
+	}
+	doc.pdfx = &copied
+	return nil
+}
+
+// Get the problems of the last file written in PDF/X mode that did not stop
+// it from being written, e.g. templates that use RGB with a CMYK output intent
+func (doc *Document) GetPDFXWarnings() []string {
+	return doc.pdfxWarnings
+}
+
+// Get the number of color components of an ICC output profile
+func iccComponents(profile []byte) (int, error) {
+	if len(profile) < 128 || string(profile[36:40]) != "acsp" {
+		return 0, errors.New("Not an ICC profile")
+	}
+	if string(profile[12:16]) != "prtr" {
+		return 0, errors.New(fmt.Sprintf("Profile class %q is not an output profile", profile[12:16]))
+	}
+
+	n, ok := iccColorSpaces[string(profile[16:20])]
+	if !ok {
+		return 0, errors.New(fmt.Sprintf("Unsupported profile color space %q", profile[16:20]))
+	}
+	return n, nil
+}
+
+// Check the page boxes of the document: every page needs a trim box, which
+// has to lie within the bleed box, if any, and all boxes within the media box
+func (doc *Document) checkPDFXBoxes() error {
+	problems := make([]string, 0)
+
+	for i, page := range doc.pages {
+		media := [4]float64{0, 0, page.w, page.h}
+		trim, ok := page.boxes[TrimBox]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("page %d has no trim box", i+1))
+		}
+
+		for _, name := range boxNames[1:] {
+			if rect, ok := page.boxes[name]; ok && !containsRect(media, rect) {
+				problems = append(problems, fmt.Sprintf("%s of page %d is not within the media box", name, i+1))
+			}
+		}
+
+		if bleed, hasBleed := page.boxes[BleedBox]; ok && hasBleed && !containsRect(bleed, trim) {
+			problems = append(problems, fmt.Sprintf("trim box of page %d is not within the bleed box", i+1))
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New("Document is not PDF/X-4 conforming: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Determine if rectangle inner lies within outer, allowing for the rounding of
+// the written coordinates
+func containsRect(outer [4]float64, inner [4]float64) bool {
+	const epsilon = 0.001
+	return inner[0] >= outer[0]-epsilon && inner[1] >= outer[1]-epsilon &&
+		inner[2] <= outer[2]+epsilon && inner[3] <= outer[3]+epsilon
+}
+
+// Find the uses of uncalibrated RGB in the imported objects and in the
+// content of the templates of the document
+func (doc *Document) findDeviceRGB() []string {
+	warnings := make([]string, 0)
+
+	ids := make([]int, 0, len(doc.backend.Objects))
+	for id := range doc.backend.Objects {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		data := doc.backend.Objects[id]
+		if i := bytes.Index(data, []byte("stream\n")); i >= 0 {
+			data = data[:i]
+		}
+		if len(deviceRGBPattern.FindAll(data, -1)) > len(alternateRGBPattern.FindAll(data, -1)) {
+			warnings = append(warnings, fmt.Sprintf("Object %d uses DeviceRGB", id))
+		}
+	}
+
+	seen := make(map[*PdfTemplate]bool, 0)
+	for _, page := range doc.pages {
+		for _, tplid := range page.tpls {
+			tplInfo := doc.importer.tplMap[tplid]
+			tpl := tplInfo.Writer.tpls[tplInfo.TemplateId]
+			if seen[tpl] || tpl.Buffer == nil {
+				continue
+			}
+			seen[tpl] = true
+
+			if contentUsesRGB(tpl.Buffer) {
+				warnings = append(warnings, fmt.Sprintf("Page %d of %s paints in DeviceRGB", tpl.PageNo, tpl.Reader.sourceFile))
+			}
+		}
+	}
+
+	return warnings
+}
+
+// Determine if a content stream sets RGB colors, with rg or RG, a DeviceRGB
+// color space or an RGB inline image
+func contentUsesRGB(content []byte) bool {
+	ops, err := ParseContentStream(content)
+	if err != nil {
+		return false
+	}
+
+	for _, op := range ops {
+		switch op.Operator {
+		case "rg", "RG":
+			return true
+		case "cs", "CS":
+			if nameOperand(op) == "/DeviceRGB" {
+				return true
+			}
+		case "BI":
+			for _, key := range []string{"/CS", "/ColorSpace"} {
+				space, err := op.Operands[0].GetKey(key)
+				if err == nil && (space.Token == "/RGB" || space.Token == "/DeviceRGB") {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// Put the objects that PDF/X requires: the output intent with its profile,
+// the document information and the XMP metadata.  Returns the entries of the
+// catalog and of the trailer and the next free id.
+func (doc *Document) putPDFX(objects map[int][]byte, nextId int) (string, string, int, error) {
+	options := doc.pdfx
+	profileId := nextId
+	infoId := nextId + 1
+	metadataId := nextId + 2
+
+	date := options.Date
+	if date.IsZero() {
+		if doc.importer.options.reproducible {
+			return "", "", 0, errors.New("PDF/X requires a date in reproducible mode")
+		}
+		date = time.Now()
+	}
+
+	n, err := iccComponents(options.Profile)
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	objects[profileId] = append(pdfStream(fmt.Sprintf("/N %d /Filter /FlateDecode", n), deflate(options.Profile)), "\nendobj\n"...)
+
+	var intent bytes.Buffer
+	intent.WriteString("<< /Type /OutputIntent /S /GTS_PDFX /OutputConditionIdentifier ")
+	writeContentValue(&intent, encodeTextString(options.OutputConditionIdentifier))
+	if options.OutputCondition != "" {
+		intent.WriteString(" /OutputCondition ")
+		writeContentValue(&intent, encodeTextString(options.OutputCondition))
+	}
+	intent.WriteString(" /RegistryName ")
+	writeContentValue(&intent, encodeTextString(options.RegistryName))
+	intent.WriteString(" /Info ")
+	writeContentValue(&intent, encodeTextString(options.OutputConditionIdentifier))
+	intent.WriteString(fmt.Sprintf(" /DestOutputProfile %d 0 R >>", profileId))
+
+	trapped := "/False"
+	if options.Trapped {
+		trapped = "/True"
+	}
+
+	var info bytes.Buffer
+	info.WriteString("<< /Title ")
+	writeContentValue(&info, encodeTextString(options.Title))
+	info.WriteString(fmt.Sprintf(" /CreationDate (%s) /ModDate (%s) /Trapped %s /GTS_PDFXVersion (PDF/X-4) >>\nendobj\n", FormatDate(date), FormatDate(date), trapped))
+	objects[infoId] = info.Bytes()
+
+	objects[metadataId] = append(pdfStream("/Type /Metadata /Subtype /XML", pdfxMetadata(options, date)), "\nendobj\n"...)
+
+	if n != 3 {
+		doc.pdfxWarnings = doc.findDeviceRGB()
+	}
+
+	catalog := fmt.Sprintf(" /OutputIntents [%s] /Metadata %d 0 R", intent.String(), metadataId)
+	trailer := fmt.Sprintf(" /Info %d 0 R", infoId)
+	return catalog, trailer, metadataId + 1, nil
+}
+
+// Build the XMP metadata of a PDF/X-4 document.  The document id is derived
+// from the title and date, so the same document always gets the same id.
+func pdfxMetadata(options *PDFXOptions, date time.Time) []byte {
+	var title bytes.Buffer
+	xml.EscapeText(&title, []byte(options.Title))
+
+	trapped := "False"
+	if options.Trapped {
+		trapped = "True"
+	}
+
+	digest := md5.Sum([]byte(options.Title + "\x00" + date.Format(time.RFC3339)))
+	id := fmt.Sprintf("uuid:%x-%x-%x-%x-%x", digest[0:4], digest[4:6], digest[6:8], digest[8:10], digest[10:16])
+	stamp := date.Format("2006-01-02T15:04:05-07:00")
+
+	var b bytes.Buffer
+	b.WriteString("<?xpacket begin=\"\xef\xbb\xbf\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.WriteString("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n")
+	b.WriteString("<rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">\n")
+	b.WriteString("<rdf:Description rdf:about=\"\" xmlns:dc=\"http://purl.org/dc/elements/1.1/\" xmlns:xmp=\"http://ns.adobe.com/xap/1.0/\"")
+	b.WriteString(" xmlns:xmpMM=\"http://ns.adobe.com/xap/1.0/mm/\" xmlns:pdf=\"http://ns.adobe.com/pdf/1.3/\" xmlns:pdfxid=\"http://www.npes.org/pdfx/ns/id/\">\n")
+	b.WriteString("<dc:title><rdf:Alt><rdf:li xml:lang=\"x-default\">" + title.String() + "</rdf:li></rdf:Alt></dc:title>\n")
+	b.WriteString("<xmp:CreateDate>" + stamp + "</xmp:CreateDate>\n")
+	b.WriteString("<xmp:ModifyDate>" + stamp + "</xmp:ModifyDate>\n")
+	b.WriteString("<xmp:MetadataDate>" + stamp + "</xmp:MetadataDate>\n")
+	b.WriteString("<xmpMM:DocumentID>" + id + "</xmpMM:DocumentID>\n")
+	b.WriteString("<xmpMM:VersionID>1</xmpMM:VersionID>\n")
+	b.WriteString("<xmpMM:RenditionClass>default</xmpMM:RenditionClass>\n")
+	b.WriteString("<pdf:Trapped>" + trapped + "</pdf:Trapped>\n")
+	b.WriteString("<pdfxid:GTS_PDFXVersion>PDF/X-4</pdfxid:GTS_PDFXVersion>\n")
+	b.WriteString("</rdf:Description>\n</rdf:RDF>\n</x:xmpmeta>\n<?xpacket end=\"w\"?>")
+	return b.Bytes()
+}
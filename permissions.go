@@ -0,0 +1,71 @@
+package gofpdi
+
+// The permissions of the standard security handler, stored in the /P entry of
+// the encryption dictionary.  gofpdi does not write encrypted documents yet;
+// the type is meant for the options of an encrypting writer.
+type Permissions struct {
+	// Print the document (bit 3), at full quality if PrintHighQuality is set
+	Print bool
+	// Change the document other than with the other permissions (bit 4)
+	Modify bool
+	// Copy or extract text and graphics (bit 5)
+	Copy bool
+	// Add or change annotations and fill in form fields (bit 6)
+	Annotate bool
+	// Fill in form fields, even without Annotate (bit 9)
+	FillForms bool
+	// Extract text and graphics for accessibility (bit 10)
+	ExtractForAccessibility bool
+	// Insert, rotate and delete pages and create bookmarks and thumbnails (bit 11)
+	Assemble bool
+	// Print at full quality rather than as a low resolution image (bit 12)
+	PrintHighQuality bool
+}
+
+// All permissions granted
+var AllPermissions = Permissions{true, true, true, true, true, true, true, true}
+
+// Bits of the /P value by permission, numbered from 1 as in the PDF specification
+func (permissions Permissions) bits() map[uint]bool {
+	return map[uint]bool{
+		3:  permissions.Print,
+		4:  permissions.Modify,
+		5:  permissions.Copy,
+		6:  permissions.Annotate,
+		9:  permissions.FillForms,
+		10: permissions.ExtractForAccessibility,
+		11: permissions.Assemble,
+		12: permissions.PrintHighQuality,
+	}
+}
+
+// Get the /P value of the permissions.  Reserved bits 7, 8 and 13 to 32 are
+// set, bits 1 and 2 are clear, as the specification requires.
+func (permissions Permissions) P() int32 {
+	p := ^uint32(0)
+	p &^= 1<<0 | 1<<1
+	for bit, granted := range permissions.bits() {
+		if !granted {
+			p &^= 1 << (bit - 1)
+		}
+	}
+	return int32(p)
+}
+
+// Get the permissions of a /P value
+func PermissionsFromP(p int32) Permissions {
+	has := func(bit uint) bool {
+		return uint32(p)&(1<<(bit-1)) != 0
+	}
+
+	return Permissions{
+		Print:                   has(3),
+		Modify:                  has(4),
+		Copy:                    has(5),
+		Annotate:                has(6),
+		FillForms:               has(9),
+		ExtractForAccessibility: has(10),
+		Assemble:                has(11),
+		PrintHighQuality:        has(12),
+	}
+}
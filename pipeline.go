@@ -0,0 +1,499 @@
+package gofpdi
+
+import (
+	"bufio"
+	"crypto/md5"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"runtime"
+
+	"github.com/pkg/errors"
+)
+
+// Pages imported, written and released at a time by a Pipeline, and sources
+// opened ahead of the one being merged, by default
+const (
+	defaultPipelineBatch  = 64
+	defaultPipelineWindow = 2
+)
+
+// A source merged by a Pipeline: a file, or a stream with its name
+type PipelineSource struct {
+	// Name of the source, the path of the file if Stream is nil
+	Name   string
+	Stream io.ReadSeeker
+	// Pages to merge, in order; nil for every page
+	Pages []int
+}
+
+// Gives the sources merged by a Pipeline, in order.  Next returns io.EOF
+// after the last source.
+type SourceIterator interface {
+	Next() (*PipelineSource, error)
+}
+
+// Iterates over files
+type fileIterator struct {
+	paths []string
+}
+
+func (it *fileIterator) Next() (*PipelineSource, error) {
+	if len(it.paths) == 0 {
+		return nil, io.EOF
+	}
+	path := it.paths[0]
+	it.paths = it.paths[1:]
+	return &PipelineSource{Name: path}, nil
+}
+
+// Get an iterator over every page of the files paths
+func SourceFiles(paths ...string) SourceIterator {
+	return &fileIterator{paths: paths}
+}
+
+// Merges any number of sources into one PDF that is written as it is made:
+// sources are opened ahead of time while the pages of the current one are
+// imported by workers and written out in order, objects first, so only the
+// sources being opened and one batch of pages are held in memory, e.g. for
+// batch jobs of thousands of files.  The imported pages are copied as with
+// Document.AddTemplatePage; the importer holds the import options.
+type Pipeline struct {
+	doc     *Document
+	box     BoxName
+	workers int
+	batch   int
+	window  int
+	// Sources merged and pages written by the last run
+	numSources int
+	numPages   int
+}
+
+// A source opened ahead of time, or why it could not be opened
+type openedSource struct {
+	source *PipelineSource
+	reader *PdfReader
+	err    error
+}
+
+// Create a pipeline with a new importer that merges the media boxes of the pages
+func NewPipeline() *Pipeline {
+	return &Pipeline{
+		doc:    NewDocument(),
+		box:    MediaBox,
+		batch:  defaultPipelineBatch,
+		window: defaultPipelineWindow,
+	}
+}
+
+// Get the importer of the pipeline, to set the import options.  Sources are
+// set by the pipeline.
+func (pipeline *Pipeline) Importer() *Importer {
+	return pipeline.doc.importer
+}
+
+// Set the box of the pages that is merged, see Importer.ImportPage
+func (pipeline *Pipeline) SetBox(box BoxName) error {
+	box, err := box.normalize()
+	if err != nil {
+		return err
+	}
+	pipeline.box = box
+	return nil
+}
+
+// Set the number of goroutines that import the pages of a source, 0 (the
+// default) for the number of CPUs, see Importer.ImportPages
+func (pipeline *Pipeline) SetWorkers(n int) {
+	pipeline.workers = n
+}
+
+// Set the number of pages that are imported, written and released at a time
+// (64 by default).  Larger batches keep more of a source in memory.
+func (pipeline *Pipeline) SetBatchSize(n int) {
+	if n <= 0 {
+		n = defaultPipelineBatch
+	}
+	pipeline.batch = n
+}
+
+// Set the number of sources that are opened and fingerprinted ahead of the
+// one being merged, at least 1 (2 by default), so reading them overlaps with
+// importing
+func (pipeline *Pipeline) SetWindow(n int) {
+	if n < 1 {
+		n = 1
+	}
+	pipeline.window = n
+}
+
+// Write the header version ("1.4" to "1.7", or "2.0"), see
+// Document.SetVersion.  "" (the default) writes a 1.4 header and the
+// version the objects need, if higher, into the catalog.
+func (pipeline *Pipeline) SetVersion(version string) error {
+	return pipeline.doc.SetVersion(version)
+}
+
+// Get the number of pages written by the last run
+func (pipeline *Pipeline) GetNumPages() int {
+	return pipeline.numPages
+}
+
+// Get the number of sources merged by the last run
+func (pipeline *Pipeline) GetNumSources() int {
+	return pipeline.numSources
+}
+
+// Merge the sources into a file, see Run
+func (pipeline *Pipeline) RunFile(filename string, sources SourceIterator) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return errors.Wrap(err, "Failed to create file")
+	}
+
+	_, err = pipeline.Run(f, sources)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// Merge the pages of every source of sources, in order, and write the
+// document to w as the pages are imported.  A source whose pages were
+// merged is closed and released, except for the ids of the objects written,
+// which later sources refer to for the font files, ICC profiles and spot
+// colors they share (see SetMergeFontFiles).  If an error occurs, what was
+// written to w so far is not a complete document.
+func (pipeline *Pipeline) Run(w io.Writer, sources SourceIterator) (int64, error) {
+	importer := pipeline.doc.importer
+	pipeline.numSources, pipeline.numPages = 0, 0
+
+	out := newStreamBackend(w, documentFirstId)
+	version := pipeline.doc.version
+	if version == "" {
+		version = pdfVersions[0]
+	}
+	out.writeString("%PDF-" + version + "\n%\xe2\xe3\xcf\xd3\n")
+
+	// Open the sources ahead of time; one more is opened while the channel is full
+	opened := make(chan *openedSource, pipeline.window-1)
+	done := make(chan struct{})
+	go importer.openSources(sources, opened, done)
+	defer func() {
+		close(done)
+		for o := range opened {
+			if o.reader != nil {
+				o.reader.Close()
+			}
+		}
+	}()
+
+	seen := make(map[string]int, 0)
+	pageIds := make([]int, 0)
+	for o := range opened {
+		if o.err != nil {
+			if o.reader != nil {
+				o.reader.Close()
+			}
+			return out.n, o.err
+		}
+
+		// Objects of a source identical to one that was released are
+		// written again
+		fingerprint := o.reader.hashSource()
+		if seen[fingerprint] > 0 {
+			o.reader.hashSalt = fmt.Sprintf("-%d", seen[fingerprint])
+		}
+		seen[fingerprint]++
+
+		ids, err := pipeline.merge(o, out)
+		if err != nil {
+			return out.n, errors.Wrap(err, "Failed to merge "+o.source.Name)
+		}
+		pageIds = append(pageIds, ids...)
+		pipeline.numSources++
+	}
+	if out.err != nil {
+		return out.n, out.err
+	}
+	if len(pageIds) == 0 {
+		return out.n, errors.New("Document has no pages")
+	}
+	pipeline.numPages = len(pageIds)
+
+	catalog := ""
+	if pipeline.doc.version == "" && out.version > version {
+		catalog = " /Version /" + out.version
+	}
+	out.WriteObject(documentPagesId, []byte(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>\nendobj\n", objectRefs(pageIds), len(pageIds))))
+	out.WriteObject(documentCatalogId, []byte(fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R%s >>\nendobj\n", documentPagesId, catalog)))
+	err := out.finish()
+	return out.n, err
+}
+
+// Open the sources of sources and send them to opened, until done is closed
+func (importer *Importer) openSources(sources SourceIterator, opened chan<- *openedSource, done <-chan struct{}) {
+	defer close(opened)
+
+	for {
+		source, err := sources.Next()
+		if err == io.EOF {
+			return
+		}
+
+		var reader *PdfReader
+		if err == nil {
+			if source.Stream != nil {
+				reader, err = newPdfReaderFromStream(source.Stream, importer.limits)
+			} else {
+				reader, err = openPdfReader(source.Name, importer.limits)
+			}
+		}
+		if err == nil {
+			err = importer.configureReader(source.Name, reader)
+		}
+		if err == nil {
+			_, err = reader.getNumPages()
+		}
+		if err == nil {
+			// Fingerprinting reads the whole source
+			reader.hashSource()
+		}
+		if err != nil {
+			name := ""
+			if source != nil {
+				name = source.Name
+			}
+			err = errors.Wrap(err, "Failed to open "+name)
+		}
+
+		select {
+		case opened <- &openedSource{source: source, reader: reader, err: err}:
+		case <-done:
+			if reader != nil {
+				reader.Close()
+			}
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Import the pages of an opened source batch by batch, write them to out
+// and release the source, and get the ids of the page objects
+func (pipeline *Pipeline) merge(o *openedSource, out *streamBackend) ([]int, error) {
+	doc := pipeline.doc
+	importer := doc.importer
+	name := o.source.Name
+	reader := o.reader
+
+	err := importer.SetSourceReader(name, reader)
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+	source := importer.sourceFile
+	defer importer.releaseSource(source)
+	if importer.readers[source] != reader {
+		// A source of the same name or content was set before the run
+		reader.Close()
+		reader = importer.readers[source]
+	}
+
+	pagenos := o.source.Pages
+	if pagenos == nil {
+		numPages, err := reader.getNumPages()
+		if err != nil {
+			return nil, err
+		}
+		pagenos = make([]int, numPages)
+		for i := range pagenos {
+			pagenos[i] = i + 1
+		}
+	}
+
+	workers := pipeline.workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	pageIds := make([]int, 0, len(pagenos))
+	for start := 0; start < len(pagenos); start += pipeline.batch {
+		end := start + pipeline.batch
+		if end > len(pagenos) {
+			end = len(pagenos)
+		}
+
+		tplids, err := importer.ImportPages(pagenos[start:end], pipeline.box, workers)
+		if err != nil {
+			return nil, err
+		}
+		for _, tplid := range tplids {
+			err = doc.AddTemplatePage(tplid)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		err = importer.putFormXobjectsTo(source, out)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, page := range doc.pages {
+			scale, userUnit, err := doc.pageScale(page)
+			if err != nil {
+				return nil, errors.Wrap(err, fmt.Sprintf("Page %d", len(pageIds)+1))
+			}
+
+			pageId := out.AllocateObjectID()
+			out.AllocateObjectID()
+			pageData, contentData, err := doc.pageObjects(page, pageId, scale, userUnit, nil, nil)
+			if err != nil {
+				return nil, err
+			}
+			out.WriteObject(pageId, pageData)
+			out.WriteObject(pageId+1, contentData)
+			pageIds = append(pageIds, pageId)
+		}
+		doc.pages = doc.pages[:0]
+
+		// The written objects and templates are not needed any more
+		writer := importer.GetWriterForFile(source)
+		writer.ClearImportedObjectsFrom(reader)
+		for _, tplid := range tplids {
+			writer.tpls[importer.tplMap[tplid].TemplateId].release()
+		}
+		if out.err != nil {
+			return nil, out.err
+		}
+	}
+
+	return pageIds, nil
+}
+
+// Drop the content and resources of a template whose form xobject has been written
+func (tpl *PdfTemplate) release() {
+	tpl.Buffer = nil
+	tpl.stream = nil
+	tpl.Resources = nil
+	tpl.Group = nil
+	tpl.pageResources = nil
+}
+
+// Close a source and forget its reader, writer and templates, keeping the
+// ids of the objects written of it
+func (importer *Importer) releaseSource(source string) {
+	for tplid, tplInfo := range importer.tplMap {
+		if tplInfo.SourceFile == source {
+			delete(importer.tplMap, tplid)
+		}
+	}
+	for key, tplid := range importer.importedPages {
+		if _, ok := importer.tplMap[tplid]; !ok {
+			delete(importer.importedPages, key)
+		}
+	}
+
+	if reader, ok := importer.readers[source]; ok {
+		reader.Close()
+	}
+	delete(importer.readers, source)
+	delete(importer.writers, source)
+	for alias, canonical := range importer.sourceAliases {
+		if canonical == source || alias == source {
+			delete(importer.sourceAliases, alias)
+		}
+	}
+}
+
+// An OutputBackend that writes objects to a file as they are put, keeping
+// only their offsets for the cross-reference table
+type streamBackend struct {
+	w      *bufio.Writer
+	digest hash.Hash
+	n      int64
+	err    error
+	nextId int
+	// Offset of each object by id, 0 for objects not written
+	offsets []int64
+	// Lowest header version that covers the objects written
+	version string
+}
+
+func newStreamBackend(w io.Writer, nextId int) *streamBackend {
+	return &streamBackend{
+		w:       bufio.NewWriter(w),
+		digest:  md5.New(),
+		nextId:  nextId,
+		offsets: make([]int64, nextId),
+		version: pdfVersions[0],
+	}
+}
+
+// Write s, unless an earlier write failed
+func (backend *streamBackend) writeString(s string) {
+	backend.write([]byte(s))
+}
+
+func (backend *streamBackend) write(data []byte) {
+	if backend.err != nil {
+		return
+	}
+	n, err := backend.w.Write(data)
+	backend.digest.Write(data[:n])
+	backend.n += int64(n)
+	if err != nil {
+		backend.err = errors.Wrap(err, "Failed to write document")
+	}
+}
+
+func (backend *streamBackend) AllocateObjectID() int {
+	id := backend.nextId
+	backend.nextId++
+	backend.offsets = append(backend.offsets, 0)
+	return id
+}
+
+func (backend *streamBackend) WriteObject(id int, data []byte) error {
+	if id <= 0 || id >= len(backend.offsets) || backend.offsets[id] != 0 {
+		return errors.New(fmt.Sprintf("Object %d has not been allocated", id))
+	}
+	if v := negotiateVersion(map[int][]byte{id: data}); v > backend.version {
+		backend.version = v
+	}
+
+	backend.offsets[id] = backend.n
+	backend.writeString(fmt.Sprintf("%d 0 obj\n", id))
+	backend.write(data)
+	return backend.err
+}
+
+// Templates are found by the ids of the importer, see Document.templateObjectId
+func (backend *streamBackend) MapPlaceholder(name string, id int) {
+}
+
+// Write the cross-reference table and the trailer, see buildDocument
+func (backend *streamBackend) finish() error {
+	digest := backend.digest.Sum(nil)
+
+	xref := backend.n
+	size := len(backend.offsets)
+	backend.writeString(xrefTable(size, func(id int) (int64, bool) {
+		return backend.offsets[id], backend.offsets[id] > 0
+	}))
+	backend.writeString(fmt.Sprintf("trailer\n<< /Size %d /Root %d 0 R /ID [<%x> <%x>] >>\nstartxref\n%d\n%%%%EOF\n", size, documentCatalogId, digest, digest, xref))
+
+	if backend.err != nil {
+		return backend.err
+	}
+	if err := backend.w.Flush(); err != nil {
+		return errors.Wrap(err, "Failed to write document")
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package gofpdi
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+)
+
+// The objects returned by Importer.GetImportedObjectsUnordered refer to each
+// other by placeholders instead of object numbers, which the pdf generator
+// library replaces with the object numbers it gives the objects.  A
+// placeholder is the hash of the object it refers to, PlaceholderLength
+// lowercase hexadecimal digits, in place of the object number of an
+// indirect reference: "<hash> 0 R".  The hashes are those of
+// Importer.PutFormXobjectsUnordered and the keys of
+// GetImportedObjectsUnordered, and their positions are those of
+// Importer.GetImportedObjHashPos.  Placeholders are never inside stream data.
+//
+// PlaceholderVersion is changed when the format of placeholders changes, so
+// libraries that replace them can check that they handle the format.
+const (
+	PlaceholderVersion = 1
+	PlaceholderLength  = 40
+	// Follows the hash of a placeholder
+	PlaceholderSuffix = " 0 R"
+)
+
+// A placeholder in the data of an object, see PlaceholderVersion
+type Placeholder struct {
+	// Position of the hash in the data
+	Offset int
+	Hash   string
+}
+
+// Find the placeholders in the data of an object, e.g. for a pdf generator
+// library that does not keep the positions of GetImportedObjHashPos.  The
+// stream data of the object, which may have the same bytes by chance, is
+// not searched.
+func ParsePlaceholders(data []byte) []Placeholder {
+	end := streamDataStart(data)
+
+	result := make([]Placeholder, 0)
+	for i := 0; i+PlaceholderLength+len(PlaceholderSuffix) <= end; i++ {
+		if i > 0 && isPlaceholderByte(data[i-1]) {
+			continue
+		}
+		j := i
+		for j < i+PlaceholderLength && isPlaceholderByte(data[j]) {
+			j++
+		}
+		if j < i+PlaceholderLength || !bytes.HasPrefix(data[j:], []byte(PlaceholderSuffix)) {
+			continue
+		}
+		result = append(result, Placeholder{Offset: i, Hash: string(data[i:j])})
+		i = j + len(PlaceholderSuffix) - 1
+	}
+	return result
+}
+
+// Replace the placeholders in the data of an object with the object numbers
+// of the objects they refer to, by hash
+func ReplacePlaceholders(data []byte, ids map[string]int) ([]byte, error) {
+	posHash := make(map[int]string, 0)
+	for _, placeholder := range ParsePlaceholders(data) {
+		posHash[placeholder.Offset] = placeholder.Hash
+	}
+	result, err := replaceObjHashes(data, posHash, ids)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to replace placeholders")
+	}
+	return result, nil
+}
+
+// Get the position of the stream data of an object, after the "stream"
+// keyword that follows its dictionary, or the length of the data if it is
+// not a stream
+func streamDataStart(data []byte) int {
+	keyword := []byte("stream")
+	for offset := 0; ; {
+		i := bytes.Index(data[offset:], keyword)
+		if i < 0 {
+			return len(data)
+		}
+		i += offset
+		offset = i + len(keyword)
+		if offset >= len(data) || (data[offset] != '\n' && data[offset] != '\r') {
+			continue
+		}
+		if dict := bytes.TrimRight(data[:i], " \t\r\n"); bytes.HasSuffix(dict, []byte(">>")) {
+			return offset
+		}
+	}
+}
+
+// Check whether c is a byte of the hash of a placeholder
+func isPlaceholderByte(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f')
+}
@@ -0,0 +1,56 @@
+package gofpdi
+
+import (
+	"bytes"
+	"compress/zlib"
+	"sync"
+)
+
+// Zlib writers and buffers are reused between templates, writers and importers
+var zlibWriterPool = sync.Pool{
+	New: func() interface{} {
+		return zlib.NewWriter(nil)
+	},
+}
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// Buffers that grew larger than this are left to the garbage collector, and
+// their data is used without copying it
+const maxPooledBufferSize = 1 << 20
+
+// Get an empty buffer from the pool
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// Get the data of a buffer from getBuffer and return the buffer to the pool.
+// The data is a copy unless the buffer is too large to be pooled.
+func releaseBuffer(buf *bytes.Buffer) []byte {
+	if buf.Cap() > maxPooledBufferSize {
+		return buf.Bytes()
+	}
+
+	data := append([]byte(nil), buf.Bytes()...)
+	bufferPool.Put(buf)
+	return data
+}
+
+// Compress data with zlib
+func deflate(data []byte) []byte {
+	buf := getBuffer()
+
+	w := zlibWriterPool.Get().(*zlib.Writer)
+	w.Reset(buf)
+	w.Write(data)
+	w.Close()
+	zlibWriterPool.Put(w)
+
+	return releaseBuffer(buf)
+}
@@ -0,0 +1,112 @@
+package gofpdi
+
+import (
+	"bytes"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Determine if the current source is a portfolio (a document with a /Collection)
+func (importer *Importer) IsPortfolio() bool {
+	return importer.GetReader().isPortfolio()
+}
+
+func (pdfReader *PdfReader) isPortfolio() bool {
+	_, ok := pdfReader.catalog.Value.Dictionary["/Collection"]
+	return ok
+}
+
+// Get the names of the pdf documents embedded in the current source.  Pages of
+// an embedded document can be imported by passing "<source>#<name>" to SetSourceFile.
+func (importer *Importer) GetPortfolioFiles() ([]string, error) {
+	attachments, err := importer.GetReader().getAttachments()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get attachments")
+	}
+
+	result := make([]string, 0, len(attachments))
+	for _, attachment := range attachments {
+		if isPdfData(attachment.Data) {
+			result = append(result, attachment.Name)
+		}
+	}
+
+	return result, nil
+}
+
+func isPdfData(data []byte) bool {
+	return bytes.Contains(data[:minInt(len(data), 1024)], []byte("%PDF-"))
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Open a reader for a source file.  If the file does not exist and the name has
+// the form "portfolio.pdf#child.pdf", the embedded document child.pdf is opened.
+func (importer *Importer) openReader(f string) (*PdfReader, error) {
+	i := strings.LastIndexByte(f, '#')
+	if i < 0 {
+		return openPdfReader(f, importer.limits)
+	}
+	if _, err := os.Stat(f); err == nil {
+		return openPdfReader(f, importer.limits)
+	}
+
+	parentFile := f[:i]
+	childName := f[i+1:]
+
+	parent, ok := importer.readers[parentFile]
+	if !ok {
+		var err error
+		parent, err = importer.openReader(parentFile)
+		if err != nil {
+			return nil, err
+		}
+		importer.readers[parentFile] = parent
+	}
+
+	return parent.openEmbeddedReader(childName, f)
+}
+
+// Open a reader for the embedded pdf document with the given name
+func (pdfReader *PdfReader) openEmbeddedReader(name string, sourceFile string) (*PdfReader, error) {
+	attachments, err := pdfReader.getAttachments()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get attachments")
+	}
+
+	for _, attachment := range attachments {
+		if attachment.Name != name && attachment.FileName != name {
+			continue
+		}
+
+		if !isPdfData(attachment.Data) {
+			return nil, errors.New("Embedded file is not a pdf: " + name)
+		}
+
+		reader, err := newPdfReaderFromStream(bytes.NewReader(attachment.Data), pdfReader.limits)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to read embedded pdf: "+name)
+		}
+
+		// Used for object hashes, so it must be unique per document
+		reader.sourceFile = sourceFile
+
+		return reader, nil
+	}
+
+	return nil, errors.New("Embedded file not found: " + name)
+}
@@ -0,0 +1,56 @@
+package gofpdi
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Write real numbers with at most digits decimals, see Importer.SetRealPrecision
+func (pdfWriter *PdfWriter) SetRealPrecision(digits int) {
+	pdfWriter.real_precision = digits
+}
+
+// Format a real number the writer writes.  Without a precision set, def
+// decimals are written (the shortest exact representation if def is -1).
+func (pdfWriter *PdfWriter) formatReal(v float64, def int) string {
+	if pdfWriter.real_precision <= 0 {
+		return strconv.FormatFloat(v, 'f', def, 64)
+	}
+	return formatReal(v, pdfWriter.real_precision)
+}
+
+// Format a real number with digits decimals, or as many more as it takes to
+// keep digits significant digits of numbers smaller than 1 (e.g. the 1/2048
+// of a Type3 /FontMatrix), without trailing zeros.  strconv always writes a
+// '.', whatever the locale.
+func formatReal(v float64, digits int) string {
+	decimals := digits
+	if a := math.Abs(v); a > 0 && a < 1 {
+		decimals += int(math.Floor(-math.Log10(a)))
+	}
+	if decimals > 17 {
+		decimals = 17
+	}
+
+	s := strconv.FormatFloat(v, 'f', decimals, 64)
+	if strings.IndexByte(s, '.') >= 0 {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	if s == "-0" {
+		s = "0"
+	}
+	return s
+}
+
+// Write real numbers (of the objects copied from the sources and of the
+// /BBox and /Matrix of form xobjects) with at most digits decimals, dropping
+// trailing zeros, to make files smaller, e.g. 2 for coordinates that need no
+// more than a hundredth of a point.  Numbers smaller than 1 keep digits
+// significant digits, so small scale factors survive.  0 (the default) writes
+// numbers exactly and form boxes with 2, and matrices with 5 decimals.
+func (importer *Importer) SetRealPrecision(digits int) {
+	importer.setWriterOptions(func(options *writerOptions) {
+		options.real_precision = digits
+	})
+}
@@ -0,0 +1,161 @@
+package gofpdi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// How a page is shown in a presentation, see GetPagePresentation
+type PagePresentation struct {
+	// Seconds the page is shown before the viewer advances to the next page
+	// (/Dur), 0 if it advances only when asked
+	Duration float64
+	// The transition to the page (/Trans), nil if it has none
+	Transition *PageTransition
+}
+
+// A transition to a page (/Trans).  Names are given without the slash.
+type PageTransition struct {
+	// Style, e.g. "Split", "Blinds", "Wipe", "Dissolve" or "R" (no transition)
+	Style string
+	// Seconds the transition takes, 0 for the default of 1
+	Duration float64
+	// Dimension ("H" or "V") of Split and Blinds and motion ("I" or "O") of
+	// Split, Box and Fly, "" if not set
+	Dimension string
+	Motion    string
+	// Direction in degrees of Wipe, Glitter, Fly, Cover, Uncover and Push, or
+	// "None" for Fly, "" if not set
+	Direction string
+	// Starting or ending scale of Fly, 0 if not set
+	Scale float64
+	// The area Fly changes is opaque (/B)
+	Opaque bool
+}
+
+// Get how page pageno is shown in a presentation: how long it is shown and
+// the transition to it
+func (pdfReader *PdfReader) GetPagePresentation(pageno int) (*PagePresentation, error) {
+	if len(pdfReader.pages) < pageno {
+		return nil, errors.New(fmt.Sprintf("Page %d does not exist", pageno))
+	}
+
+	page, err := pdfReader.getPage(pageno)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to resolve page object")
+	}
+
+	presentation := &PagePresentation{}
+	dur, err := pdfReader.resolveDirect(page.Value.Dictionary["/Dur"])
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to resolve /Dur")
+	}
+	if dur != nil && dur.Real > 0 {
+		presentation.Duration = dur.Real
+	}
+
+	trans, err := pdfReader.resolveDirect(page.Value.Dictionary["/Trans"])
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to resolve /Trans")
+	}
+	if trans == nil || trans.Type != PDF_TYPE_DICTIONARY {
+		return presentation, nil
+	}
+
+	transition := &PageTransition{Style: "R", Duration: 1}
+	for key, v := range trans.Dictionary {
+		v, err := pdfReader.resolveDirect(v)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to resolve "+key)
+		}
+		name := strings.TrimPrefix(v.Token, "/")
+		switch key {
+		case "/S":
+			transition.Style = name
+		case "/D":
+			transition.Duration = v.Real
+		case "/Dm":
+			transition.Dimension = name
+		case "/M":
+			transition.Motion = name
+		case "/Di":
+			if v.Type == PDF_TYPE_NUMERIC || v.Type == PDF_TYPE_REAL {
+				transition.Direction = strconv.FormatFloat(v.Real, 'f', -1, 64)
+			} else {
+				transition.Direction = name
+			}
+		case "/SS":
+			transition.Scale = v.Real
+		case "/B":
+			transition.Opaque = v.Type == PDF_TYPE_BOOLEAN && v.Bool
+		}
+	}
+	presentation.Transition = transition
+
+	return presentation, nil
+}
+
+// Get the entries of a page object for a presentation, e.g. " /Dur 5"
+func (presentation *PagePresentation) pageEntries() string {
+	entries := ""
+	if presentation.Duration > 0 {
+		entries += fmt.Sprintf(" /Dur %.5F", presentation.Duration)
+	}
+
+	transition := presentation.Transition
+	if transition == nil {
+		return entries
+	}
+	style := transition.Style
+	if style == "" {
+		style = "R"
+	}
+	entries += " /Trans << /Type /Trans /S " + escapeName("/"+style)
+	if transition.Duration > 0 && transition.Duration != 1 {
+		entries += fmt.Sprintf(" /D %.5F", transition.Duration)
+	}
+	if transition.Dimension != "" {
+		entries += " /Dm " + escapeName("/"+transition.Dimension)
+	}
+	if transition.Motion != "" {
+		entries += " /M " + escapeName("/"+transition.Motion)
+	}
+	if di, err := strconv.ParseFloat(transition.Direction, 64); err == nil {
+		entries += fmt.Sprintf(" /Di %.5F", di)
+	} else if transition.Direction != "" {
+		entries += " /Di " + escapeName("/"+transition.Direction)
+	}
+	if transition.Scale != 0 {
+		entries += fmt.Sprintf(" /SS %.5F", transition.Scale)
+	}
+	if transition.Opaque {
+		entries += " /B true"
+	}
+	return entries + " >>"
+}
+
+// Get how page pageno of the current source is shown in a presentation, see
+// PdfReader.GetPagePresentation
+func (importer *Importer) GetPagePresentation(pageno int) (*PagePresentation, error) {
+	return importer.GetReader().GetPagePresentation(pageno)
+}
+
+// Carry how the imported pages are shown in a presentation (their duration
+// and transition) to the pages added for them by AddTemplatePage, so
+// converted slide decks keep advancing and their transitions
+func (doc *Document) SetCarryPresentation(b bool) {
+	doc.carryPresentation = b
+}
+
+// Set how the last page is shown in a presentation, nil for the viewer's
+// defaults: shown until the viewer is asked to advance, without a transition
+func (doc *Document) SetPagePresentation(presentation *PagePresentation) error {
+	if len(doc.pages) == 0 {
+		return errors.New("Document has no pages")
+	}
+	doc.pages[len(doc.pages)-1].presentation = presentation
+	return nil
+}
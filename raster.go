@@ -0,0 +1,46 @@
+package gofpdi
+
+import (
+	"image"
+
+	"github.com/pkg/errors"
+)
+
+// Returned by RenderTemplate when no rasterizer has been set
+var ErrNoRasterizer = errors.New("No rasterizer configured")
+
+// Rasterizer renders an imported template to an image.  gofpdi does not
+// render pdf content itself; implementations typically wrap an external
+// renderer such as ghostscript or a pdfium binding.
+type Rasterizer interface {
+	Rasterize(tpl *PdfTemplate, dpi float64) (image.Image, error)
+}
+
+// The default rasterizer, which renders nothing
+type nopRasterizer struct{}
+
+func (nopRasterizer) Rasterize(tpl *PdfTemplate, dpi float64) (image.Image, error) {
+	return nil, ErrNoRasterizer
+}
+
+// Set the rasterizer used by RenderTemplate
+func (importer *Importer) SetRasterizer(r Rasterizer) {
+	if r == nil {
+		r = nopRasterizer{}
+	}
+	importer.rasterizer = r
+}
+
+// Render a template (returned from ImportPage) at the given resolution, e.g. for previews or thumbnails
+func (importer *Importer) RenderTemplate(tplid int, dpi float64) (image.Image, error) {
+	tpl, err := importer.getTemplate(tplid)
+	if err != nil {
+		return nil, err
+	}
+
+	if dpi <= 0 {
+		return nil, errors.New("Resolution must be greater than 0")
+	}
+
+	return importer.rasterizer.Rasterize(tpl, dpi)
+}
@@ -3,17 +3,23 @@ package gofpdi
 import (
 	"bufio"
 	"bytes"
-	"compress/zlib"
 	"encoding/binary"
 	"fmt"
 	"io"
 	"math"
-	"os"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 )
 
+// Reads the objects of a pdf.  Once a reader is created, its read paths can
+// be used by several goroutines at once, e.g. by importers that share it
+// (see Importer.SetSourceReader): objects are parsed one at a time, and the
+// parsed objects, pages and decoded object streams that are cached for all
+// of them must not be changed.  Setters such as SetCacheSize are not safe to
+// call while the reader is being used.
 type PdfReader struct {
 	availableBoxes []string
 	stack          []string
@@ -29,14 +35,167 @@ type PdfReader struct {
 	curPage        int
 	alreadyRead    bool
 	pageCount      int
+	// Positions of the xref sections read, so /Prev entries that loop are
+	// not followed
+	xrefSections map[int]bool
+	// The cross-reference table was rebuilt from the objects of the file,
+	// see reconstructXref
+	xrefRebuilt bool
+	// Root of the page tree; pages are looked up when they are first used
+	pagesRoot *PdfValue
+	// Resolved objects by id, and decoded object streams by object id
+	objects    *objectCache
+	objStreams *objectCache
+	// Streams longer than this are read from the source when they are used
+	largeStreamSize int
+	// Resolve references to objects that do not exist to null, see SetLenient
+	lenient  bool
+	warnings *warningList
+	// Offsets of the last definitions of objects by number and generation,
+	// see lastDefinition
+	definitions map[[2]int]int
+	// Size of pages without a valid /MediaBox
+	defaultPageSize PageSize
+	// The file opened by NewPdfReader, closed by Close
+	closer io.Closer
+	// SHA-1 of the source, or why it could not be computed, see Fingerprint
+	fingerprint    string
+	fingerprintErr error
+	// Appended to the fingerprint in object hashes, to tell apart the
+	// objects of a source imported again after an identical one was released
+	hashSalt string
+	// Limits of the source, see NewPdfReaderWithLimits, and the deadline of
+	// opening it
+	limits   Limits
+	deadline time.Time
+	// Held while objects are parsed, which moves the position of f and uses
+	// stack, and while the fingerprint is computed
+	parseMu *sync.Mutex
+	// Held while the page tree is read into pages, see getPage
+	pagesMu *sync.Mutex
+	// Decrypts the objects of encrypted documents, nil if the document is
+	// not encrypted
+	security *securityHandler
+	// Receives cache hits and misses, see SetMetrics
+	metrics Metrics
+}
+
+// The size of a page in points
+type PageSize struct {
+	W float64
+	H float64
+}
+
+var (
+	PageSizeA4     = PageSize{W: 595.276, H: 841.89}
+	PageSizeLetter = PageSize{W: 612, H: 792}
+)
+
+// Set the size of pages that have no /MediaBox or one that does not enclose an
+// area (PageSizeLetter by default, as most viewers use)
+func (pdfReader *PdfReader) SetDefaultPageSize(size PageSize) {
+	pdfReader.defaultPageSize = size
+}
+
+// Warnings shared by the readers of a source
+type warningList struct {
+	mu       sync.Mutex
+	warnings []string
+	// Receives each new warning as a recovery, see PdfReader.SetMetrics
+	metrics Metrics
+}
+
+// Add a warning, unless it was already added
+func (list *warningList) add(warning string) {
+	list.mu.Lock()
+	defer list.mu.Unlock()
+	for _, w := range list.warnings {
+		if w == warning {
+			return
+		}
+	}
+	list.warnings = append(list.warnings, warning)
+	addMetric(list.metrics, MetricRecoveries, 1)
+}
+
+// Report new warnings to metrics, and the warnings added so far if no
+// metrics were set before.  Returns whether they were reported.
+func (list *warningList) setMetrics(metrics Metrics) bool {
+	list.mu.Lock()
+	defer list.mu.Unlock()
+	first := list.metrics == nil && metrics != nil
+	if first && len(list.warnings) > 0 {
+		metrics.Add(MetricRecoveries, float64(len(list.warnings)))
+	}
+	list.metrics = metrics
+	return first
+}
+
+func (list *warningList) get() []string {
+	list.mu.Lock()
+	defer list.mu.Unlock()
+	return append([]string(nil), list.warnings...)
+}
+
+// Resolve references to objects that do not exist to the null object, as the
+// pdf specification says, instead of failing.  Each such reference adds a
+// warning.  Applies to objects resolved after the call.
+func (pdfReader *PdfReader) SetLenient(b bool) {
+	pdfReader.lenient = b
+}
+
+// Get the problems of the source that did not stop reading it, see SetLenient
+func (pdfReader *PdfReader) GetWarnings() []string {
+	return pdfReader.warnings.get()
+}
+
+// Limit the number of resolved objects kept in memory.  The least recently
+// used objects are parsed again when they are needed.  A size of 0 (the
+// default) keeps every object that has been resolved.
+func (pdfReader *PdfReader) SetCacheSize(size int) {
+	pdfReader.objects.setSize(size)
+	pdfReader.objStreams.setSize(objectStreamCacheSize(size))
+}
+
+// The default size above which stream data is not kept in memory
+const defaultLargeStreamSize = 1 << 20
+
+// Keep the data of streams longer than size bytes in the source instead of in
+// memory.  They are read again when they are used, and when they are written.
+// Only the copy held by the reader is avoided: the writer serializes each
+// object, stream data included, into memory, where it stays until it is
+// output or spilled (see Importer.SetSpill), since OutputBackend.WriteObject
+// takes whole objects.  A size of 0 keeps all streams in memory.  Only
+// applies to sources that implement io.ReaderAt, such as files, and to
+// objects resolved after the call.
+func (pdfReader *PdfReader) SetLargeStreamSize(size int) {
+	pdfReader.largeStreamSize = size
+}
+
+// Get the number of decoded object streams to keep for an object cache size.
+// Object streams hold many objects each, so fewer of them are kept.
+func objectStreamCacheSize(size int) int {
+	if size == 0 {
+		return 0
+	}
+	return maxInt(size/100, 1)
 }
 
 func NewPdfReaderFromStream(rs io.ReadSeeker) (*PdfReader, error) {
+	return newPdfReaderFromStream(rs, Limits{})
+}
+
+// Create a reader for a stream that is read within limits, see
+// NewPdfReaderWithLimits
+func newPdfReaderFromStream(rs io.ReadSeeker, limits Limits) (*PdfReader, error) {
 	length, err := rs.Seek(0, 2)
 	if err != nil {
 		return nil, errors.Wrapf(err, "Failed to determine stream length")
 	}
-	parser := &PdfReader{f: rs, nBytes: length}
+	parser, err := newLimitedReader(rs, "", length, limits)
+	if err != nil {
+		return nil, err
+	}
 	if err := parser.init(); err != nil {
 		return nil, errors.Wrap(err, "Failed to initialize parser")
 	}
@@ -47,32 +206,49 @@ func NewPdfReaderFromStream(rs io.ReadSeeker) (*PdfReader, error) {
 }
 
 func NewPdfReader(filename string) (*PdfReader, error) {
-	var err error
-	f, err := os.Open(filename)
+	return openPdfReader(filename, Limits{})
+}
+
+// Create a reader for a file that is read within limits, see
+// NewPdfReaderWithLimits
+func openPdfReader(filename string, limits Limits) (*PdfReader, error) {
+	source, err := OpenFileSource(filename)
 	if err != nil {
-		return nil, errors.Wrap(err, "Failed to open file")
+		return nil, err
 	}
-	info, err := f.Stat()
+
+	reader, err := NewPdfReaderWithLimits(filename, source, limits)
 	if err != nil {
-		return nil, errors.Wrap(err, "Failed to obtain file information")
+		source.Close()
+		return nil, err
 	}
+	reader.closer = source
 
-	parser := &PdfReader{f: f, sourceFile: filename, nBytes: info.Size()}
-	if err = parser.init(); err != nil {
-		return nil, errors.Wrap(err, "Failed to initialize parser")
-	}
-	if err = parser.read(); err != nil {
-		return nil, errors.Wrap(err, "Failed to read pdf")
-	}
+	return reader, nil
+}
 
-	return parser, nil
+// Get the file name (or source name) the reader was opened from, empty for streams
+func (pdfReader *PdfReader) GetSourceFile() string {
+	return pdfReader.sourceFile
 }
 
 func (pdfReader *PdfReader) init() error {
 	pdfReader.availableBoxes = []string{"/MediaBox", "/CropBox", "/BleedBox", "/TrimBox", "/ArtBox"}
 	pdfReader.xref = make(map[int]map[int]int, 0)
 	pdfReader.xrefStream = make(map[int][2]int, 0)
+	pdfReader.objects = newObjectCache(0)
+	pdfReader.objStreams = newObjectCache(0)
+	pdfReader.largeStreamSize = defaultLargeStreamSize
+	pdfReader.warnings = &warningList{}
+	pdfReader.defaultPageSize = PageSizeLetter
+	pdfReader.parseMu = &sync.Mutex{}
+	pdfReader.pagesMu = &sync.Mutex{}
+
+	// The reader is not shared yet, so the deadline of opening it can be
+	// kept in it
+	pdfReader.deadline = limitDeadline(pdfReader.limits.Timeout)
 	err := pdfReader.read()
+	pdfReader.deadline = time.Time{}
 	if err != nil {
 		return errors.Wrap(err, "Failed to read pdf")
 	}
@@ -94,6 +270,11 @@ type PdfValue struct {
 	Value      *PdfValue
 	Stream     *PdfValue
 	Bytes      []byte
+	// Position and length in the source of stream data that is not kept in
+	// memory, see PdfReader.SetLargeStreamSize
+	streamed bool
+	offset   int64
+	length   int64
 }
 
 // Jump over comments
@@ -104,6 +285,10 @@ func (*PdfReader) skipComments(r *bufio.Reader) error {
 	for {
 		b, err = r.ReadByte()
 		if err != nil {
+			if err == io.EOF {
+				// A comment at the end of the data
+				return nil
+			}
 			return errors.Wrap(err, "Failed to ReadByte while skipping comments")
 		}
 
@@ -111,10 +296,7 @@ func (*PdfReader) skipComments(r *bufio.Reader) error {
 			if b == '\r' {
 				// Peek and see if next char is \n
 				b2, err := r.ReadByte()
-				if err != nil {
-					return errors.Wrap(err, "Failed to read byte")
-				}
-				if b2 != '\n' {
+				if err == nil && b2 != '\n' {
 					r.UnreadByte()
 				}
 			}
@@ -139,7 +321,7 @@ func (*PdfReader) skipWhitespace(r *bufio.Reader) error {
 			return errors.Wrap(err, "Failed to read byte")
 		}
 
-		if b == ' ' || b == '\n' || b == '\r' || b == '\t' {
+		if isWhitespace(b) {
 			continue
 		} else {
 			r.UnreadByte()
@@ -161,17 +343,31 @@ func (pdfReader *PdfReader) readToken(r *bufio.Reader) (string, error) {
 		return popped, nil
 	}
 
-	err = pdfReader.skipWhitespace(r)
-	if err != nil {
-		return "", errors.Wrap(err, "Failed to skip whitespace")
-	}
+	// Comments may appear anywhere outside of strings and streams, and are
+	// treated as whitespace
+	var b byte
+	for {
+		err = pdfReader.skipWhitespace(r)
+		if err != nil {
+			return "", errors.Wrap(err, "Failed to skip whitespace")
+		}
 
-	b, err := r.ReadByte()
-	if err != nil {
-		if err == io.EOF {
-			return "", nil
+		b, err = r.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				return "", nil
+			}
+			return "", errors.Wrap(err, "Failed to read byte")
+		}
+
+		if b != '%' {
+			break
+		}
+
+		err = pdfReader.skipComments(r)
+		if err != nil {
+			return "", errors.Wrap(err, "Failed to skip comments")
 		}
-		return "", errors.Wrap(err, "Failed to read byte")
 	}
 
 	switch b {
@@ -193,70 +389,116 @@ func (pdfReader *PdfReader) readToken(r *bufio.Reader) (string, error) {
 			return string(b), nil
 		}
 
-	case '%':
-		err = pdfReader.skipComments(r)
+	default:
+		// Scan the token in the reader's buffer, so only the finished token is
+		// converted to a string
+		r.UnreadByte()
+		token, err := scanBytes(r, 1, &tokenDelimiters)
 		if err != nil {
-			return "", errors.Wrap(err, "Failed to skip comments")
+			return "", errors.Wrap(err, "Failed to read byte")
+		}
+		if token[0] == '/' && bytes.IndexByte(token, '#') >= 0 {
+			return decodeName(string(token)), nil
 		}
-		return pdfReader.readToken(r)
+		return string(token), nil
+	}
+}
 
-	default:
-		// FIXME pdfReader may not be performant to create new strings for each byte
-		// Is it probably better to create a buffer and then convert to a string at the end.
-		str := string(b)
+// Bytes that end a regular token
+var tokenDelimiters = delimiterTable(" %[]<>(){}\r\n\t\f\x00/")
 
-	loop:
-		for {
-			b, err := r.ReadByte()
-			if err != nil {
-				return "", errors.Wrap(err, "Failed to read byte")
+// Bytes that end a hex string
+var hexStringDelimiters = delimiterTable(">")
+
+func delimiterTable(delimiters string) [256]bool {
+	var table [256]bool
+	for i := 0; i < len(delimiters); i++ {
+		table[delimiters[i]] = true
+	}
+	return table
+}
+
+// Read at least min bytes and then up to the next delimiter, which is not
+// consumed.  The bytes are a view of the reader's buffer when they fit in it,
+// so they are only valid until the next read from r.
+func scanBytes(r *bufio.Reader, min int, delimiters *[256]bool) ([]byte, error) {
+	var token []byte
+	for {
+		if r.Buffered() == 0 {
+			if _, err := r.Peek(1); err != nil {
+				return nil, err
 			}
-			switch b {
-			case ' ', '%', '[', ']', '<', '>', '(', ')', '\r', '\n', '\t', '/':
+		}
+
+		buf, _ := r.Peek(r.Buffered())
+		for i, b := range buf {
+			if delimiters[b] && len(token)+i >= min {
+				if token == nil {
+					token = buf[:i]
+				} else {
+					token = append(token, buf[:i]...)
+				}
+				r.Discard(i)
+				return token, nil
+			}
+		}
+
+		token = append(token, buf...)
+		r.Discard(len(buf))
+	}
+}
+
+// Skip the end of line after the stream keyword: CRLF, LF or a bare CR, as
+// written by old Mac applications.  Spaces before it are skipped as well,
+// but not whitespace after it, which belongs to the stream data.
+func (*PdfReader) skipStreamEOL(r *bufio.Reader) error {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return errors.Wrap(err, "Failed to read byte")
+		}
+
+		switch b {
+		case ' ', '\t':
+			continue
+		case '\r':
+			if nb, err := r.ReadByte(); err == nil && nb != '\n' {
 				r.UnreadByte()
-				break loop
-			default:
-				str += string(b)
 			}
+			return nil
+		case '\n':
+			return nil
+		default:
+			// No end of line
+			return r.UnreadByte()
 		}
-		return str, nil
 	}
 }
 
 // Read a value based on a token
 func (pdfReader *PdfReader) readValue(r *bufio.Reader, t string) (*PdfValue, error) {
-	var err error
-	var b byte
-
 	result := &PdfValue{}
 	result.Type = -1
 	result.Token = t
-	result.Dictionary = make(map[string]*PdfValue, 0)
-	result.Array = make([]*PdfValue, 0)
 
 	switch t {
 	case "<":
 		// pdfReader is a hex string
 
 		// Read bytes until '>' is found
-		var s string
-		for {
-			b, err = r.ReadByte()
-			if err != nil {
-				return nil, errors.Wrap(err, "Failed to read byte")
-			}
-			if b != '>' {
-				s += string(b)
-			} else {
-				break
-			}
+		hex, err := scanBytes(r, 0, &hexStringDelimiters)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to read byte")
 		}
+		s := string(hex)
+		r.Discard(1)
 
 		result.Type = PDF_TYPE_HEX
 		result.String = s
 
 	case "<<":
 		// pdfReader is a dictionary
+		result.Dictionary = make(map[string]*PdfValue, 0)
 
 		// Recurse into pdfReader function until we reach the end of the dictionary.
 		for {
@@ -454,6 +696,10 @@ func (pdfReader *PdfReader) resolveCompressedObject(objSpec *PdfValue) (*PdfValu
 
 	// Make sure object reference exists in xrefStream
 	if _, ok := pdfReader.xrefStream[objSpec.Id]; !ok {
+		if pdfReader.lenient {
+			pdfReader.warnings.add(fmt.Sprintf("Object %d %d does not exist and was replaced with null", objSpec.Id, objSpec.Gen))
+			return &PdfValue{Type: PDF_TYPE_OBJECT, Id: objSpec.Id, Gen: objSpec.Gen, Value: &PdfValue{Type: PDF_TYPE_NULL}}, nil
+		}
 		return nil, errors.New(fmt.Sprintf("Could not find object ID %d in xref stream or xref table.", objSpec.Id))
 	}
 
@@ -465,12 +711,15 @@ func (pdfReader *PdfReader) resolveCompressedObject(objSpec *PdfValue) (*PdfValu
 	compressedObjSpec := &PdfValue{Type: PDF_TYPE_OBJREF, Id: objectId, Gen: 0}
 
 	// Resolve compressed object
-	compressedObj, err := pdfReader.resolveObject(compressedObjSpec)
+	compressedObj, err := pdfReader.resolveObjectLocked(compressedObjSpec)
 	if err != nil {
 		return nil, errors.Wrap(err, "Failed to resolve compressed object")
 	}
 
 	// Verify object type is /ObjStm
+	if compressedObj.Value == nil || compressedObj.Value.Type != PDF_TYPE_DICTIONARY {
+		return nil, errors.New("Compressed object is not a stream")
+	}
 	if _, ok := compressedObj.Value.Dictionary["/Type"]; ok {
 		if compressedObj.Value.Dictionary["/Type"].Token != "/ObjStm" {
 			return nil, errors.New("Expected compressed object type to be /ObjStm")
@@ -480,40 +729,28 @@ func (pdfReader *PdfReader) resolveCompressedObject(objSpec *PdfValue) (*PdfValu
 	}
 
 	// Get number of sub-objects in compressed object
-	n := compressedObj.Value.Dictionary["/N"].Int
+	nValue, firstValue := compressedObj.Value.Dictionary["/N"], compressedObj.Value.Dictionary["/First"]
+	if nValue == nil || firstValue == nil {
+		return nil, errors.New("Compressed object has no /N or /First")
+	}
+	n := nValue.Int
 	if n <= 0 {
 		return nil, errors.New("No sub objects in compressed object")
 	}
 
 	// Get offset of first object
-	first := compressedObj.Value.Dictionary["/First"].Int
+	first := firstValue.Int
 
 	// Get length
 	//length := compressedObj.Value.Dictionary["/Length"].Int
 
-	// Check for filter
-	filter := ""
-	if _, ok := compressedObj.Value.Dictionary["/Filter"]; ok {
-		filter = compressedObj.Value.Dictionary["/Filter"].Token
-		if filter != "/FlateDecode" {
-			return nil, errors.New("Unsupported filter - expected /FlateDecode, got: " + filter)
-		}
-	}
-
-	if filter == "/FlateDecode" {
-		// Decompress if filter is /FlateDecode
-		// Uncompress zlib compressed data
-		var out bytes.Buffer
-		zlibReader, _ := zlib.NewReader(bytes.NewBuffer(compressedObj.Stream.Bytes))
-		defer zlibReader.Close()
-		io.Copy(&out, zlibReader)
-
-		// Set stream to uncompressed data
-		compressedObj.Stream.Bytes = out.Bytes()
+	data, err := pdfReader.objectStreamData(objectId, compressedObj)
+	if err != nil {
+		return nil, err
 	}
 
 	// Get io.Reader for bytes
-	r := bufio.NewReader(bytes.NewBuffer(compressedObj.Stream.Bytes))
+	r := bufio.NewReader(bytes.NewBuffer(data))
 
 	subObjId := 0
 	subObjPos := 0
@@ -555,7 +792,7 @@ func (pdfReader *PdfReader) resolveCompressedObject(objSpec *PdfValue) (*PdfValu
 	}
 
 	// Now create an io.ReadSeeker
-	rs := io.ReadSeeker(bytes.NewReader(compressedObj.Stream.Bytes))
+	rs := io.ReadSeeker(bytes.NewReader(data))
 
 	// Determine where to seek to (sub-object position + /First)
 	seekTo := int64(subObjPos + first)
@@ -587,108 +824,271 @@ func (pdfReader *PdfReader) resolveCompressedObject(objSpec *PdfValue) (*PdfValu
 	return result, nil
 }
 
+// Get the decoded data of an object stream.  It is decoded once, since it
+// usually holds many objects.
+func (pdfReader *PdfReader) objectStreamData(objectId int, compressedObj *PdfValue) ([]byte, error) {
+	if decoded, ok := pdfReader.objStreams.get(objectId); ok {
+		addMetric(pdfReader.metrics, MetricCacheHits, 1)
+		return decoded.Bytes, nil
+	}
+	addMetric(pdfReader.metrics, MetricCacheMisses, 1)
+
+	// Check for filter
+	filter := ""
+	if _, ok := compressedObj.Value.Dictionary["/Filter"]; ok {
+		filter = compressedObj.Value.Dictionary["/Filter"].Token
+		if filter != "/FlateDecode" {
+			return nil, errors.New("Unsupported filter - expected /FlateDecode, got: " + filter)
+		}
+	}
+
+	data, err := pdfReader.streamData(compressedObj)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read object stream")
+	}
+	if filter == "/FlateDecode" {
+		// Decompress if filter is /FlateDecode
+		// Uncompress zlib compressed data
+		out, err := pdfReader.inflate(data)
+		if err != nil && (out == nil || isLimitError(err)) {
+			return nil, errors.Wrap(err, "Failed to decompress object stream")
+		}
+
+		// Set stream to uncompressed data
+		data = out
+	}
+
+	pdfReader.objStreams.put(objectId, &PdfValue{Type: PDF_TYPE_STREAM, Bytes: data})
+
+	return data, nil
+}
+
+// Resolve an object reference.  Objects are parsed when they are first
+// resolved and cached, so they must not be modified by callers.
 func (pdfReader *PdfReader) resolveObject(objSpec *PdfValue) (*PdfValue, error) {
+	if objSpec == nil {
+		return nil, errors.New("Object is missing")
+	}
+	if objSpec.Type != PDF_TYPE_OBJREF {
+		return objSpec, nil
+	}
+
+	if obj, ok := pdfReader.objects.get(objSpec.Id); ok && obj.Gen == objSpec.Gen {
+		addMetric(pdfReader.metrics, MetricCacheHits, 1)
+		return obj, nil
+	}
+
+	pdfReader.parseMu.Lock()
+	defer pdfReader.parseMu.Unlock()
+	return pdfReader.resolveObjectLocked(objSpec)
+}
+
+// Resolve an object reference while parseMu is held, e.g. the /Length of a
+// stream that is being parsed
+func (pdfReader *PdfReader) resolveObjectLocked(objSpec *PdfValue) (*PdfValue, error) {
+	if objSpec == nil {
+		return nil, errors.New("Object is missing")
+	}
+	if objSpec.Type != PDF_TYPE_OBJREF {
+		return objSpec, nil
+	}
+
+	// Another goroutine may have parsed the object in the meantime
+	if obj, ok := pdfReader.objects.get(objSpec.Id); ok && obj.Gen == objSpec.Gen {
+		addMetric(pdfReader.metrics, MetricCacheHits, 1)
+		return obj, nil
+	}
+	addMetric(pdfReader.metrics, MetricCacheMisses, 1)
+
+	obj, err := pdfReader.readObject(objSpec)
+	if err != nil {
+		return nil, err
+	}
+	pdfReader.objects.put(objSpec.Id, obj)
+
+	return obj, nil
+}
+
+// Parse the object an object reference points to
+func (pdfReader *PdfReader) readObject(objSpec *PdfValue) (*PdfValue, error) {
 	var err error
 	var old_pos int64
 
+	if err := pdfReader.checkDeadline(); err != nil {
+		return nil, err
+	}
+
 	// Create new bufio.Reader
 	r := bufio.NewReader(pdfReader.f)
 
 	if objSpec.Type == PDF_TYPE_OBJREF {
 		// pdfReader is a reference, resolve it.
-		offset := pdfReader.xref[objSpec.Id][objSpec.Gen]
-
-		if _, ok := pdfReader.xref[objSpec.Id]; !ok {
+		entry, ok := pdfReader.xref[objSpec.Id]
+		if !ok {
 			// pdfReader may be a compressed object
 			return pdfReader.resolveCompressedObject(objSpec)
 		}
 
+		// A free object, or a generation that was deleted and whose object
+		// number was reused, is the null object
+		offset, ok := entry[objSpec.Gen]
+		if !ok {
+			return &PdfValue{Type: PDF_TYPE_OBJECT, Id: objSpec.Id, Gen: objSpec.Gen, Value: &PdfValue{Type: PDF_TYPE_NULL}}, nil
+		}
+
 		// Save current file position
 		// pdfReader is needed if you want to resolve reference while you're reading another object.
 		// (e.g.: if you need to determine the length of a stream)
-		old_pos, err = pdfReader.f.Seek(0, os.SEEK_CUR)
+		old_pos, err = pdfReader.f.Seek(0, io.SeekCurrent)
 		if err != nil {
 			return nil, errors.Wrap(err, "Failed to get current position of file")
 		}
 
-		// Reposition the file pointer and load the object header
-		_, err = pdfReader.f.Seek(int64(offset), 0)
+		result, err := pdfReader.readObjectAt(r, objSpec, offset)
 		if err != nil {
-			return nil, errors.Wrap(err, "Failed to set position of file")
+			// The xref entry does not point at the object, which happens when
+			// an object number is defined more than once.  Use the last
+			// definition of the object in the file.
+			if last, ok := pdfReader.lastDefinition(objSpec.Id, objSpec.Gen); ok && last != offset {
+				r.Reset(pdfReader.f)
+				pdfReader.stack = nil
+				result, err = pdfReader.readObjectAt(r, objSpec, last)
+			}
 		}
-
-		token, err := pdfReader.readToken(r)
 		if err != nil {
-			return nil, errors.Wrap(err, "Failed to read token")
+			return nil, err
 		}
 
-		obj, err := pdfReader.readValue(r, token)
+		// Reposition the file pointer to previous position
+		_, err = pdfReader.f.Seek(old_pos, 0)
 		if err != nil {
-			return nil, errors.Wrap(err, "Failed to read value for token: "+token)
+			return nil, errors.Wrap(err, "Failed to set position of file")
 		}
 
-		if obj.Type != PDF_TYPE_OBJDEC {
-			return nil, errors.New(fmt.Sprintf("Expected type to be PDF_TYPE_OBJDEC, got: %d", obj.Type))
-		}
+		return result, nil
 
-		if obj.Id != objSpec.Id {
-			return nil, errors.New(fmt.Sprintf("Object ID (%d) does not match ObjSpec ID (%d)", obj.Id, objSpec.Id))
-		}
+	} else {
+		return objSpec, nil
+	}
+}
 
-		if obj.Gen != objSpec.Gen {
-			return nil, errors.New("Object Gen does not match ObjSpec Gen")
-		}
+// Read the object objSpec at an offset of the file
+func (pdfReader *PdfReader) readObjectAt(r *bufio.Reader, objSpec *PdfValue, offset int) (*PdfValue, error) {
+	// Reposition the file pointer and load the object header
+	_, err := pdfReader.f.Seek(int64(offset), 0)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to set position of file")
+	}
 
-		// Read next token
-		token, err = pdfReader.readToken(r)
-		if err != nil {
-			return nil, errors.Wrap(err, "Failed to read token")
-		}
+	token, err := pdfReader.readToken(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read token")
+	}
+
+	obj, err := pdfReader.readValue(r, token)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read value for token: "+token)
+	}
+
+	if obj.Type != PDF_TYPE_OBJDEC {
+		return nil, errors.New(fmt.Sprintf("Expected type to be PDF_TYPE_OBJDEC, got: %d", obj.Type))
+	}
+
+	if obj.Id != objSpec.Id {
+		return nil, errors.New(fmt.Sprintf("Object ID (%d) does not match ObjSpec ID (%d)", obj.Id, objSpec.Id))
+	}
+
+	if obj.Gen != objSpec.Gen {
+		return nil, errors.New("Object Gen does not match ObjSpec Gen")
+	}
+
+	// Read next token
+	token, err = pdfReader.readToken(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read token")
+	}
 
-		// Read actual object value
-		value, err := pdfReader.readValue(r, token)
+	// Read actual object value
+	value, err := pdfReader.readValue(r, token)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read value for token: "+token)
+	}
+
+	// Read next token
+	token, err = pdfReader.readToken(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read token")
+	}
+
+	result := &PdfValue{}
+	result.Id = obj.Id
+	result.Gen = obj.Gen
+	result.Type = PDF_TYPE_OBJECT
+	result.Value = value
+
+	if token == "stream" {
+		result.Type = PDF_TYPE_STREAM
+
+		err = pdfReader.skipStreamEOL(r)
 		if err != nil {
-			return nil, errors.Wrap(err, "Failed to read value for token: "+token)
+			return nil, errors.Wrap(err, "Failed to skip end of line")
 		}
 
-		// Read next token
-		token, err = pdfReader.readToken(r)
-		if err != nil {
-			return nil, errors.Wrap(err, "Failed to read token")
+		// Get stream length dictionary
+		lengthDict := value.Dictionary["/Length"]
+		if lengthDict == nil {
+			return nil, errors.New("Stream has no /Length")
 		}
 
-		result := &PdfValue{}
-		result.Id = obj.Id
-		result.Gen = obj.Gen
-		result.Type = PDF_TYPE_OBJECT
-		result.Value = value
+		// Get number of bytes of stream
+		length := lengthDict.Int
 
-		if token == "stream" {
-			result.Type = PDF_TYPE_STREAM
+		// If lengthDict is an object reference, resolve the object and set length
+		if lengthDict.Type == PDF_TYPE_OBJREF {
+			lengthDict, err = pdfReader.resolveObjectLocked(lengthDict)
 
-			err = pdfReader.skipWhitespace(r)
 			if err != nil {
-				return nil, errors.Wrap(err, "Failed to skip whitespace")
+				return nil, errors.Wrap(err, "Failed to resolve length object of stream")
 			}
 
-			// Get stream length dictionary
-			lengthDict := value.Dictionary["/Length"]
+			if lengthDict.Value == nil {
+				return nil, errors.New("Length object of stream is not a number")
+			}
 
-			// Get number of bytes of stream
-			length := lengthDict.Int
+			// Set length to resolved object value
+			length = lengthDict.Value.Int
+		}
+		if length < 0 {
+			return nil, errors.New(fmt.Sprintf("Stream has a /Length of %d", length))
+		}
+		if err := pdfReader.checkStreamSize(int64(length)); err != nil {
+			return nil, err
+		}
 
-			// If lengthDict is an object reference, resolve the object and set length
-			if lengthDict.Type == PDF_TYPE_OBJREF {
-				lengthDict, err = pdfReader.resolveObject(lengthDict)
+		streamObj := &PdfValue{}
+		streamObj.Type = PDF_TYPE_STREAM
 
-				if err != nil {
-					return nil, errors.Wrap(err, "Failed to resolve length object of stream")
-				}
+		_, isReaderAt := pdfReader.f.(io.ReaderAt)
+		if pdfReader.largeStreamSize > 0 && length > pdfReader.largeStreamSize && isReaderAt && pdfReader.security == nil {
+			// Leave large streams in the source and skip over them
+			pos, err := pdfReader.f.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return nil, errors.Wrap(err, "Failed to get current position of file")
+			}
 
-				// Set length to resolved object value
-				length = lengthDict.Value.Int
+			streamObj.streamed = true
+			streamObj.offset = pos - int64(r.Buffered())
+			streamObj.length = int64(length)
+			if streamObj.offset+streamObj.length > pdfReader.nBytes {
+				return nil, errors.New("Failed to read bytes from buffer: stream is longer than the file")
 			}
 
+			_, err = pdfReader.f.Seek(streamObj.offset+streamObj.length, 0)
+			if err != nil {
+				return nil, errors.Wrap(err, "Failed to set position of file")
+			}
+			r.Reset(pdfReader.f)
+		} else {
 			// Read length bytes
 			bytes := make([]byte, length)
 
@@ -697,107 +1097,198 @@ func (pdfReader *PdfReader) resolveObject(objSpec *PdfValue) (*PdfValue, error)
 			if err != nil {
 				return nil, errors.Wrap(err, "Failed to read bytes from buffer")
 			}
-
-			token, err = pdfReader.readToken(r)
-			if err != nil {
-				return nil, errors.Wrap(err, "Failed to read token")
-			}
-			if token != "endstream" {
-				return nil, errors.New("Expected next token to be: endstream, got: " + token)
-			}
-
-			token, err = pdfReader.readToken(r)
-			if err != nil {
-				return nil, errors.Wrap(err, "Failed to read token")
-			}
-
-			streamObj := &PdfValue{}
-			streamObj.Type = PDF_TYPE_STREAM
 			streamObj.Bytes = bytes
+		}
 
-			result.Stream = streamObj
+		token, err = pdfReader.readToken(r)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to read token")
+		}
+		if token != "endstream" {
+			return nil, errors.New("Expected next token to be: endstream, got: " + token)
 		}
 
-		if token != "endobj" {
-			return nil, errors.New("Expected next token to be: endobj, got: " + token)
+		token, err = pdfReader.readToken(r)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to read token")
 		}
 
-		// Reposition the file pointer to previous position
-		_, err = pdfReader.f.Seek(old_pos, 0)
+		result.Stream = streamObj
+	}
+
+	if token != "endobj" {
+		return nil, errors.New("Expected next token to be: endobj, got: " + token)
+	}
+
+	// Objects in object streams are decrypted with the object stream
+	if pdfReader.security != nil && pdfReader.security.key != nil {
+		err = pdfReader.security.decryptObject(result)
 		if err != nil {
-			return nil, errors.Wrap(err, "Failed to set position of file")
+			return nil, errors.Wrap(err, fmt.Sprintf("Failed to decrypt object %d %d R", result.Id, result.Gen))
 		}
+	}
 
-		return result, nil
+	return result, nil
+}
+
+// Resolve value if it is an object reference and return its direct value.
+// Streams are returned as the resolved object, with the stream dictionary in Value.
+func (pdfReader *PdfReader) resolveDirect(value *PdfValue) (*PdfValue, error) {
+	if value == nil || value.Type != PDF_TYPE_OBJREF {
+		return value, nil
+	}
+
+	obj, err := pdfReader.resolveObject(value)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to resolve object")
+	}
 
-	} else {
-		return objSpec, nil
+	if obj.Type == PDF_TYPE_STREAM {
+		return obj, nil
 	}
+
+	return obj.Value, nil
 }
 
-// Find the xref offset (should be at the end of the PDF)
+// The distance from the end of the file in which startxref is searched, and
+// the size of the chunks that are read
+const (
+	startxrefSearchSize = 1 << 20
+	startxrefChunkSize  = 4096
+)
+
+// Find the xref offset (should be at the end of the PDF).  The end of the file
+// is scanned backwards in chunks for the last startxref keyword.
 func (pdfReader *PdfReader) findXref() error {
-	var result int
-	var err error
-	var toRead int64
+	keyword := []byte("startxref")
 
-	toRead = 1500
+	// Bytes kept from the start of the previous chunk, for a keyword and
+	// offset that cross chunk boundaries
+	const overlap = 64
 
-	// If PDF is smaller than 1500 bytes, be sure to only read the number of bytes that are in the file
-	fileSize := pdfReader.nBytes
-	if fileSize < toRead {
-		toRead = fileSize
+	limit := pdfReader.nBytes - startxrefSearchSize
+	if limit < 0 {
+		limit = 0
 	}
 
-	// 0 means relative to the origin of the file,
-	// 1 means relative to the current offset,
-	// and 2 means relative to the end.
-	whence := 2
+	var window []byte
+	pos := pdfReader.nBytes
+	for pos > limit {
+		n := int64(startxrefChunkSize)
+		if pos-limit < n {
+			n = pos - limit
+		}
+		pos -= n
 
-	// Perform seek operation
-	_, err = pdfReader.f.Seek(-toRead, whence)
-	if err != nil {
-		return errors.Wrap(err, "Failed to set position of file")
-	}
+		_, err := pdfReader.f.Seek(pos, 0)
+		if err != nil {
+			return errors.Wrap(err, "Failed to set position of file")
+		}
 
-	// Create new bufio.Reader
-	r := bufio.NewReader(pdfReader.f)
-	for {
-		// Read all tokens until "startxref" is found
-		token, err := pdfReader.readToken(r)
+		chunk := make([]byte, int(n)+len(window))
+		_, err = io.ReadFull(pdfReader.f, chunk[:n])
 		if err != nil {
-			return errors.Wrap(err, "Failed to read token")
+			return errors.Wrap(err, "Failed to read end of file")
 		}
+		copy(chunk[n:], window)
 
-		if token == "startxref" {
-			token, err = pdfReader.readToken(r)
-			// Probably EOF before finding startxref
+		if i := bytes.LastIndex(chunk, keyword); i >= 0 {
+			result, err := parseXrefOffset(chunk[i+len(keyword):])
 			if err != nil {
-				return errors.Wrap(err, "Failed to find startxref token")
+				return err
 			}
+			pdfReader.xrefPos = result
 
-			// Convert line (string) into int
-			result, err = strconv.Atoi(token)
+			// Rewind file pointer
+			_, err = pdfReader.f.Seek(0, 0)
 			if err != nil {
-				return errors.Wrap(err, "Failed to convert xref position into integer: "+token)
+				return errors.Wrap(err, "Failed to set position of file")
 			}
 
-			// Successfully read the xref position
-			pdfReader.xrefPos = result
-			break
+			return nil
 		}
+
+		if len(chunk) > overlap {
+			chunk = chunk[:overlap]
+		}
+		window = chunk
+	}
+
+	return errors.New("Failed to find startxref token")
+}
+
+// Parse the offset that follows the startxref keyword
+func parseXrefOffset(data []byte) (int, error) {
+	i := 0
+	for i < len(data) && isWhitespace(data[i]) {
+		i++
+	}
+
+	j := i
+	for j < len(data) && data[j] >= '0' && data[j] <= '9' {
+		j++
 	}
 
-	// Rewind file pointer
-	whence = 0
-	_, err = pdfReader.f.Seek(0, whence)
+	result, err := strconv.Atoi(string(data[i:j]))
 	if err != nil {
-		return errors.Wrap(err, "Failed to set position of file")
+		return 0, errors.Wrap(err, "Failed to convert xref position into integer: "+string(data[i:j]))
 	}
 
-	pdfReader.xrefPos = result
+	return result, nil
+}
 
-	return nil
+// Check whether an object is defined by a cross reference section that was
+// already read.  Sections are read from the newest revision to the oldest, so
+// the first definition of an object is its current one.
+func (pdfReader *PdfReader) hasXrefEntry(id int) bool {
+	if _, ok := pdfReader.xref[id]; ok {
+		return true
+	}
+	_, ok := pdfReader.xrefStream[id]
+	return ok
+}
+
+// Set the offset of an object, unless a newer revision defines it
+func (pdfReader *PdfReader) setXrefEntry(id, gen, offset int) {
+	if !pdfReader.hasXrefEntry(id) {
+		pdfReader.xref[id] = map[int]int{gen: offset}
+	}
+}
+
+// Mark an object as free, unless a newer revision defines it.  Free objects
+// have no generation in use, so references to them resolve to null.
+func (pdfReader *PdfReader) setFreeXrefEntry(id int) {
+	if !pdfReader.hasXrefEntry(id) {
+		pdfReader.xref[id] = map[int]int{}
+	}
+}
+
+// Keys of xref stream dictionaries that describe the stream, rather than the document
+var xrefStreamKeys = map[string]bool{
+	"/Type": true, "/W": true, "/Index": true, "/Length": true,
+	"/Filter": true, "/DecodeParms": true, "/Prev": true, "/XRefStm": true,
+}
+
+// Add the entries of the trailer of an older revision that the newer
+// revisions do not have.  Trailers are read from the newest to the oldest, so
+// the newest /Root, /Info, /Encrypt, /ID and /Size are used.
+func (pdfReader *PdfReader) mergeTrailer(trailer *PdfValue) {
+	if trailer == nil || trailer.Type != PDF_TYPE_DICTIONARY {
+		return
+	}
+
+	if pdfReader.trailer == nil {
+		pdfReader.trailer = &PdfValue{Type: PDF_TYPE_DICTIONARY, Dictionary: make(map[string]*PdfValue, len(trailer.Dictionary))}
+	}
+
+	for key, value := range trailer.Dictionary {
+		if xrefStreamKeys[key] {
+			continue
+		}
+		if _, ok := pdfReader.trailer.Dictionary[key]; !ok {
+			pdfReader.trailer.Dictionary[key] = value
+		}
+	}
 }
 
 // Read and parse the xref table
@@ -807,7 +1298,16 @@ func (pdfReader *PdfReader) readXref() error {
 	// Create new bufio.Reader
 	r := bufio.NewReader(pdfReader.f)
 
-	// Set file pointer to xref start
+	// Set file pointer to xref start, which may be a few bytes off
+	pdfReader.xrefPos = pdfReader.locateXref(pdfReader.xrefPos)
+	if pdfReader.xrefSections[pdfReader.xrefPos] {
+		return nil
+	}
+	pdfReader.xrefSections[pdfReader.xrefPos] = true
+	// The sections read so far are checked before the next one is read
+	if err := pdfReader.checkObjectCount(); err != nil {
+		return err
+	}
 	_, err = pdfReader.f.Seek(int64(pdfReader.xrefPos), 0)
 	if err != nil {
 		return errors.Wrap(err, "Failed to set position of file")
@@ -893,14 +1393,8 @@ func (pdfReader *PdfReader) readXref() error {
 						prevXref = v.Dictionary["/Prev"].Int
 					}
 
-					// Set root object
-					if _, ok := v.Dictionary["/Root"]; ok {
-						// Just set the whole dictionary with /Root key to keep compatibiltiy with existing code
-						pdfReader.trailer = v
-					} else {
-						// Don't return an error here.  The trailer could be in another XRef stream.
-						//return errors.New("Did not set root object")
-					}
+					// The dictionary of an xref stream is also the trailer
+					pdfReader.mergeTrailer(v)
 
 					startObject := index[0]
 
@@ -910,21 +1404,21 @@ func (pdfReader *PdfReader) readXref() error {
 					}
 
 					// Get stream length dictionary
-					lengthDict := v.Dictionary["/Length"]
+					lengthDict, err := pdfReader.resolveDirect(v.Dictionary["/Length"])
+					if err != nil {
+						return errors.Wrap(err, "Failed to resolve length object of stream")
+					}
+					if lengthDict == nil {
+						return errors.New("Xref stream has no /Length")
+					}
 
 					// Get number of bytes of stream
 					length := lengthDict.Int
-
-					// If lengthDict is an object reference, resolve the object and set length
-					if lengthDict.Type == PDF_TYPE_OBJREF {
-						lengthDict, err = pdfReader.resolveObject(lengthDict)
-
-						if err != nil {
-							return errors.Wrap(err, "Failed to resolve length object of stream")
-						}
-
-						// Set length to resolved object value
-						length = lengthDict.Value.Int
+					if length < 0 {
+						return errors.New(fmt.Sprintf("Xref stream has a /Length of %d", length))
+					}
+					if err := pdfReader.checkStreamSize(int64(length)); err != nil {
+						return err
 					}
 
 					t, err = pdfReader.readToken(r)
@@ -935,16 +1429,16 @@ func (pdfReader *PdfReader) readXref() error {
 						return errors.New("Expected next token to be: stream, got: " + t)
 					}
 
-					err = pdfReader.skipWhitespace(r)
+					err = pdfReader.skipStreamEOL(r)
 					if err != nil {
-						return errors.Wrap(err, "Failed to skip whitespace")
+						return errors.Wrap(err, "Failed to skip end of line")
 					}
 
 					// Read length bytes
 					data := make([]byte, length)
 
 					// Cannot use reader.Read() because that may not read all the bytes
-					_, err := io.ReadFull(r, data)
+					_, err = io.ReadFull(r, data)
 					if err != nil {
 						return errors.Wrap(err, "Failed to read bytes from buffer")
 					}
@@ -968,17 +1462,9 @@ func (pdfReader *PdfReader) readXref() error {
 					}
 
 					// Now decode zlib data
-					b := bytes.NewReader(data)
-
-					z, err := zlib.NewReader(b)
-					if err != nil {
-						return errors.Wrap(err, "zlib.NewReader error")
-					}
-					defer z.Close()
-
-					p, err := io.ReadAll(z)
+					p, err := pdfReader.inflate(data)
 					if err != nil {
-						return errors.Wrap(err, "ioutil.ReadAll error")
+						return errors.Wrap(err, "Failed to decompress xref stream")
 					}
 
 					objPos := 0
@@ -987,11 +1473,17 @@ func (pdfReader *PdfReader) readXref() error {
 
 					// Decode result with paeth algorithm
 					var result []byte
-					b = bytes.NewReader(p)
+					b := bytes.NewReader(p)
 
-					firstFieldSize := v.Dictionary["/W"].Array[0].Int
-					middleFieldSize := v.Dictionary["/W"].Array[1].Int
-					lastFieldSize := v.Dictionary["/W"].Array[2].Int
+					// The type and generation fields are read as one byte, the
+					// offset as up to four
+					w := v.Dictionary["/W"]
+					if w == nil || len(w.Array) < 3 || w.Array[0].Int != 1 || w.Array[1].Int < 1 || w.Array[1].Int > 4 || w.Array[2].Int < 1 {
+						return errors.New("Unsupported /W of xref stream")
+					}
+					firstFieldSize := w.Array[0].Int
+					middleFieldSize := w.Array[1].Int
+					lastFieldSize := w.Array[2].Int
 
 					fieldSize := firstFieldSize + middleFieldSize + lastFieldSize
 					if paethDecode {
@@ -1030,11 +1522,10 @@ func (pdfReader *PdfReader) readXref() error {
 							objPos = int(binary.BigEndian.Uint32(b))
 							objGen = int(objectData[firstFieldSize+middleFieldSize])
 
-							// Append map[int]int
-							pdfReader.xref[i] = make(map[int]int, 1)
-
-							// Set object id, generation, and position
-							pdfReader.xref[i][objGen] = objPos
+							pdfReader.setXrefEntry(i, objGen, objPos)
+						} else if objectData[0] == 0 {
+							// Free objects
+							pdfReader.setFreeXrefEntry(i)
 						} else if objectData[0] == 2 {
 							// Compressed objects
 							b := make([]byte, 4)
@@ -1044,7 +1535,9 @@ func (pdfReader *PdfReader) readXref() error {
 							objIdx := int(objectData[firstFieldSize+middleFieldSize])
 
 							// object id (i) is located in StmObj (objId) at index (objIdx)
-							pdfReader.xrefStream[i] = [2]int{objId, objIdx}
+							if !pdfReader.hasXrefEntry(i) {
+								pdfReader.xrefStream[i] = [2]int{objId, objIdx}
+							}
 						}
 
 						i++
@@ -1133,11 +1626,11 @@ func (pdfReader *PdfReader) readXref() error {
 				return errors.New("Expected objStatus to be 'n' or 'f', got: " + objStatus)
 			}
 
-			// Append map[int]int
-			pdfReader.xref[i] = make(map[int]int, 1)
-
-			// Set object id, generation, and position
-			pdfReader.xref[i][objGen] = objPos
+			if objStatus == "f" {
+				pdfReader.setFreeXrefEntry(i)
+			} else {
+				pdfReader.setXrefEntry(i, objGen, objPos)
+			}
 		}
 	}
 
@@ -1152,10 +1645,7 @@ func (pdfReader *PdfReader) readXref() error {
 		return errors.Wrap(err, "Failed to read value for token: "+t)
 	}
 
-	// If /Root is set, then set trailer object so that /Root can be read later
-	if _, ok := trailer.Dictionary["/Root"]; ok {
-		pdfReader.trailer = trailer
-	}
+	pdfReader.mergeTrailer(trailer)
 
 	// If a /Prev xref trailer is specified, parse that
 	if tr, ok := trailer.Dictionary["/Prev"]; ok {
@@ -1172,18 +1662,28 @@ func (pdfReader *PdfReader) readRoot() error {
 	var err error
 
 	rootObjSpec := pdfReader.trailer.Dictionary["/Root"]
+	if rootObjSpec == nil {
+		return errors.New("Trailer has no /Root")
+	}
 
 	// Read root (catalog)
 	pdfReader.catalog, err = pdfReader.resolveObject(rootObjSpec)
 	if err != nil {
 		return errors.Wrap(err, "Failed to resolve root object")
 	}
+	if pdfReader.catalog.Value == nil || pdfReader.catalog.Value.Type != PDF_TYPE_DICTIONARY {
+		return errors.New("Root object is not a dictionary")
+	}
 
 	return nil
 }
 
 // Read kids (pages inside a page tree)
 func (pdfReader *PdfReader) readKids(kids *PdfValue, r int) error {
+	if r > 64 {
+		return errors.New("Page tree is too deep")
+	}
+
 	// Loop through pages and add to result
 	for i := 0; i < len(kids.Array); i++ {
 		page, err := pdfReader.resolveObject(kids.Array[i])
@@ -1191,10 +1691,19 @@ func (pdfReader *PdfReader) readKids(kids *PdfValue, r int) error {
 			return errors.Wrap(err, "Failed to resolve page/pages object")
 		}
 
-		objType := page.Value.Dictionary["/Type"].Token
+		if page.Value == nil || page.Value.Type != PDF_TYPE_DICTIONARY {
+			continue
+		}
+
+		objType := ""
+		if t, ok := page.Value.Dictionary["/Type"]; ok {
+			objType = t.Token
+		}
 		if objType == "/Page" {
 			// Set page and increment curPage
-			pdfReader.pages[pdfReader.curPage] = page
+			if pdfReader.curPage < len(pdfReader.pages) {
+				pdfReader.pages[pdfReader.curPage] = page
+			}
 			pdfReader.curPage++
 		} else if objType == "/Pages" {
 			// Resolve kids
@@ -1221,31 +1730,123 @@ func (pdfReader *PdfReader) readPages() error {
 	var err error
 
 	// resolve_pages_dict
-	pagesDict, err := pdfReader.resolveObject(pdfReader.catalog.Value.Dictionary["/Pages"])
+	pagesRef, err := pdfReader.catalog.GetKey("/Pages")
 	if err != nil {
-		return errors.Wrap(err, "Failed to resolve pages object")
+		return errors.Wrap(err, "Failed to get pages object")
 	}
-
-	// pdfReader will normally return itself
-	kids, err := pdfReader.resolveObject(pagesDict.Value.Dictionary["/Kids"])
+	pagesDict, err := pdfReader.resolveObject(pagesRef)
 	if err != nil {
-		return errors.Wrap(err, "Failed to resolve kids object")
+		return errors.Wrap(err, "Failed to resolve pages object")
+	}
+	if pagesDict.Value == nil || pagesDict.Value.Type != PDF_TYPE_DICTIONARY {
+		return errors.New("Pages object is not a dictionary")
 	}
 
 	// Get number of pages
-	pageCount, err := pdfReader.resolveObject(pagesDict.Value.Dictionary["/Count"])
+	countRef, err := pagesDict.GetKey("/Count")
+	if err != nil {
+		return errors.Wrap(err, "Failed to get page count")
+	}
+	pageCount, err := pdfReader.resolveObject(countRef)
 	if err != nil {
 		return errors.Wrap(err, "Failed to get page count")
 	}
+	// Every page takes some bytes of the file
+	if pageCount.Int < 0 || int64(pageCount.Int) > pdfReader.nBytes {
+		return errors.New(fmt.Sprintf("Page count %d is not possible in a file of %d bytes", pageCount.Int, pdfReader.nBytes))
+	}
+	if max := pdfReader.limits.MaxPages; max > 0 && pageCount.Int > max {
+		return &LimitError{Limit: "MaxPages", Value: int64(pageCount.Int), Max: int64(max)}
+	}
 	pdfReader.pageCount = pageCount.Int
 
-	// Allocate pages
+	// Allocate pages, they are read by getPage
 	pdfReader.pages = make([]*PdfValue, pageCount.Int)
+	pdfReader.pagesRoot = pagesDict
+
+	return nil
+}
+
+// Get the page object of a page number, reading only the part of the page
+// tree that leads to it
+func (pdfReader *PdfReader) getPage(pageno int) (*PdfValue, error) {
+	if pageno < 1 || len(pdfReader.pages) < pageno {
+		return nil, errors.New(fmt.Sprintf("Page %d does not exist", pageno))
+	}
+
+	pdfReader.pagesMu.Lock()
+	defer pdfReader.pagesMu.Unlock()
+
+	if pdfReader.pages[pageno-1] == nil {
+		err := pdfReader.findPage(pdfReader.pagesRoot, pageno-1, 0, 0)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to find page")
+		}
+	}
+
+	// The /Count entries of the page tree are wrong, read the whole tree
+	if pdfReader.pages[pageno-1] == nil {
+		kids, err := pdfReader.resolveObject(pdfReader.pagesRoot.Value.Dictionary["/Kids"])
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to resolve kids object")
+		}
+
+		pdfReader.curPage = 0
+		err = pdfReader.readKids(kids, 0)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to read kids")
+		}
+	}
 
-	// Read kids
-	err = pdfReader.readKids(kids, 0)
+	if pdfReader.pages[pageno-1] == nil {
+		return nil, errors.New(fmt.Sprintf("Page %d not found in page tree", pageno))
+	}
+
+	return pdfReader.pages[pageno-1], nil
+}
+
+// Find page index (0 based) in the page tree node pages, whose first page has
+// index first.  Pages seen on the way are stored in pdfReader.pages.
+func (pdfReader *PdfReader) findPage(pages *PdfValue, index int, first int, depth int) error {
+	if depth > 64 {
+		return errors.New("Page tree is too deep")
+	}
+
+	kids, err := pdfReader.resolveObject(pages.Value.Dictionary["/Kids"])
 	if err != nil {
-		return errors.Wrap(err, "Failed to read kids")
+		return errors.Wrap(err, "Failed to resolve kids")
+	}
+	if kids.Type == PDF_TYPE_OBJECT {
+		kids = kids.Value
+	}
+
+	for i := 0; i < len(kids.Array) && first <= index; i++ {
+		kid, err := pdfReader.resolveObject(kids.Array[i])
+		if err != nil {
+			return errors.Wrap(err, "Failed to resolve page/pages object")
+		}
+
+		if kid.Value == nil || kid.Value.Type != PDF_TYPE_DICTIONARY {
+			continue
+		}
+
+		if objType, ok := kid.Value.Dictionary["/Type"]; ok && objType.Token == "/Pages" {
+			count, err := pdfReader.resolveDirect(kid.Value.Dictionary["/Count"])
+			if err != nil || count == nil {
+				return errors.New("Failed to get page count of page tree node")
+			}
+
+			if index < first+count.Int {
+				return pdfReader.findPage(kid, index, first, depth+1)
+			}
+			first += count.Int
+			continue
+		}
+
+		if first < len(pdfReader.pages) {
+			pdfReader.pages[first] = kid
+		}
+		first++
 	}
 
 	return nil
@@ -1261,7 +1862,7 @@ func (pdfReader *PdfReader) getPageResources(pageno int) (*PdfValue, error) {
 	}
 
 	// Resolve page object
-	page, err := pdfReader.resolveObject(pdfReader.pages[pageno-1])
+	page, err := pdfReader.getPage(pageno)
 	if err != nil {
 		return nil, errors.Wrap(err, "Failed to resolve page object")
 	}
@@ -1337,43 +1938,49 @@ func (pdfReader *PdfReader) getPageContent(objSpec *PdfValue) ([]*PdfValue, erro
 }
 
 // Get content (i.e. PDF drawing instructions)
-func (pdfReader *PdfReader) getContent(pageno int) (string, error) {
+func (pdfReader *PdfReader) getContent(pageno int) ([]byte, error) {
 	var err error
 	var contents []*PdfValue
 
 	// Check to make sure page exists in pages slice
 	if len(pdfReader.pages) < pageno {
-		return "", errors.New(fmt.Sprintf("Page %d does not exist.", pageno))
+		return nil, errors.New(fmt.Sprintf("Page %d does not exist.", pageno))
 	}
 
 	// Get page
-	page := pdfReader.pages[pageno-1]
-
-	// FIXME: pdfReader could be slow, converting []byte to string and appending many times
-	buffer := ""
+	page, err := pdfReader.getPage(pageno)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get page")
+	}
 
 	// Check to make sure /Contents exists in page dictionary
-	if _, ok := page.Value.Dictionary["/Contents"]; ok {
-		// Get an array of page content
-		contents, err = pdfReader.getPageContent(page.Value.Dictionary["/Contents"])
+	if _, ok := page.Value.Dictionary["/Contents"]; !ok {
+		return []byte{}, nil
+	}
+
+	// Get an array of page content
+	contents, err = pdfReader.getPageContent(page.Value.Dictionary["/Contents"])
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get page content")
+	}
+
+	var buffer bytes.Buffer
+	for i := 0; i < len(contents); i++ {
+		// Decode content if one or more /Filter is specified.
+		// Most common filter is FlateDecode which can be uncompressed with zlib
+		tmpBuffer, err := pdfReader.rebuildContentStream(contents[i])
 		if err != nil {
-			return "", errors.Wrap(err, "Failed to get page content")
+			return nil, errors.Wrap(err, "Failed to rebuild content stream")
 		}
 
-		for i := 0; i < len(contents); i++ {
-			// Decode content if one or more /Filter is specified.
-			// Most common filter is FlateDecode which can be uncompressed with zlib
-			tmpBuffer, err := pdfReader.rebuildContentStream(contents[i])
-			if err != nil {
-				return "", errors.Wrap(err, "Failed to rebuild content stream")
-			}
-
-			// FIXME:  pdfReader is probably slow
-			buffer += string(tmpBuffer)
+		// A single stream is used as it is, without copying it
+		if len(contents) == 1 {
+			return tmpBuffer, nil
 		}
+		buffer.Write(tmpBuffer)
 	}
 
-	return buffer, nil
+	return buffer.Bytes(), nil
 }
 
 // Rebuild content stream
@@ -1410,20 +2017,23 @@ func (pdfReader *PdfReader) rebuildContentStream(content *PdfValue) ([]byte, err
 	}
 
 	// Set stream variable to content bytes
-	stream := content.Stream.Bytes
+	stream, err := pdfReader.streamData(content)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read stream")
+	}
 
 	// Loop through filters and apply each filter to stream
 	for i := 0; i < len(filters); i++ {
 		switch filters[i].Token {
 		case "/FlateDecode":
 			// Uncompress zlib compressed data
-			var out bytes.Buffer
-			zlibReader, _ := zlib.NewReader(bytes.NewBuffer(stream))
-			defer zlibReader.Close()
-			io.Copy(&out, zlibReader)
+			out, err := pdfReader.inflate(stream)
+			if isLimitError(err) {
+				return nil, err
+			}
 
 			// Set stream to uncompressed data
-			stream = out.Bytes()
+			stream = out
 		default:
 			return nil, errors.New("Unspported filter: " + filters[i].Token)
 		}
@@ -1432,6 +2042,39 @@ func (pdfReader *PdfReader) rebuildContentStream(content *PdfValue) ([]byte, err
 	return stream, nil
 }
 
+// Get the (encoded) data of a stream object, reading it from the source if it
+// is not kept in memory
+func (pdfReader *PdfReader) streamData(obj *PdfValue) ([]byte, error) {
+	if !obj.Stream.streamed {
+		return obj.Stream.Bytes, nil
+	}
+
+	data := make([]byte, obj.Stream.length)
+	_, err := io.ReadFull(pdfReader.streamReader(obj), data)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read stream data")
+	}
+
+	return data, nil
+}
+
+// Get a reader for the (encoded) data of a stream object
+func (pdfReader *PdfReader) streamReader(obj *PdfValue) io.Reader {
+	if !obj.Stream.streamed {
+		return bytes.NewReader(obj.Stream.Bytes)
+	}
+
+	return io.NewSectionReader(pdfReader.f.(io.ReaderAt), obj.Stream.offset, obj.Stream.length)
+}
+
+// Get the length of the (encoded) data of a stream object
+func (value *PdfValue) streamLength() int64 {
+	if value.Stream.streamed {
+		return value.Stream.length
+	}
+	return int64(len(value.Stream.Bytes))
+}
+
 func (pdfReader *PdfReader) getNumPages() (int, error) {
 	if pdfReader.pageCount == 0 {
 		return 0, errors.New("Page count is 0")
@@ -1469,9 +2112,9 @@ func (pdfReader *PdfReader) getPageBoxes(pageno int, k float64) (map[string]map[
 	}
 
 	// Resolve page object
-	page, err := pdfReader.resolveObject(pdfReader.pages[pageno-1])
+	page, err := pdfReader.getPage(pageno)
 	if err != nil {
-		return nil, errors.New("Failed to resolve page object")
+		return nil, errors.Wrap(err, "Failed to resolve page object")
 	}
 
 	// Loop through available boxes and add to result
@@ -1487,52 +2130,90 @@ func (pdfReader *PdfReader) getPageBoxes(pageno int, k float64) (map[string]map[
 	return result, nil
 }
 
-// Get a specific page box value (e.g. MediaBox) and return its values
+// Get a specific page box value (e.g. MediaBox) and return its values.  Boxes
+// are inherited from the page tree, and coordinates are normalized so that
+// llx <= urx and lly <= ury.  A missing or degenerate MediaBox is replaced by
+// the default page size, see SetDefaultPageSize; other boxes that are
+// missing or degenerate are returned empty.
 func (pdfReader *PdfReader) getPageBox(page *PdfValue, box_index string, k float64) (map[string]float64, error) {
-	var err error
-	var tmpBox *PdfValue
-
 	// Allocate 8 fields in result
 	result := make(map[string]float64, 8)
 
-	// Check to make sure box_index (e.g. MediaBox) exists in page dictionary
-	if _, ok := page.Value.Dictionary[box_index]; ok {
-		box := page.Value.Dictionary[box_index]
+	rect, ok, err := pdfReader.findPageBox(page, box_index, 0)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if box_index != "/MediaBox" {
+			return result, nil
+		}
+		size := pdfReader.defaultPageSize
+		pdfReader.warnings.add(fmt.Sprintf("Page object %d has no valid /MediaBox, using %gx%g", page.Id, size.W, size.H))
+		rect = [4]float64{0, 0, size.W, size.H}
+	}
+
+	// Calculate scaled value based on k
+	result["x"] = rect[0] / k
+	result["y"] = rect[1] / k
+	result["w"] = (rect[2] - rect[0]) / k
+	result["h"] = (rect[3] - rect[1]) / k
+	result["llx"] = rect[0] / k
+	result["lly"] = rect[1] / k
+	result["urx"] = rect[2] / k
+	result["ury"] = rect[3] / k
+
+	return result, nil
+}
+
+// Find a page box of a page or the page tree nodes above it, as llx, lly, urx
+// and ury.  Boxes that are not arrays of four numbers enclosing an area are
+// ignored.
+func (pdfReader *PdfReader) findPageBox(page *PdfValue, box_index string, depth int) ([4]float64, bool, error) {
+	var rect [4]float64
+
+	if depth > 64 {
+		return rect, false, errors.New("Page tree is too deep")
+	}
+	if page.Value == nil || page.Value.Type != PDF_TYPE_DICTIONARY {
+		return rect, false, nil
+	}
+
+	box, err := pdfReader.resolveDirect(page.Value.Dictionary[box_index])
+	if err != nil {
+		return rect, false, errors.Wrap(err, "Failed to resolve page box")
+	}
 
-		// If the box type is a reference, resolve it
-		if box.Type == PDF_TYPE_OBJREF {
-			tmpBox, err = pdfReader.resolveObject(box)
+	if box != nil && box.Type == PDF_TYPE_ARRAY && len(box.Array) >= 4 {
+		valid := true
+		for i := 0; i < 4; i++ {
+			v, err := pdfReader.resolveDirect(box.Array[i])
 			if err != nil {
-				return nil, errors.New("Failed to resolve object")
+				return rect, false, errors.Wrap(err, "Failed to resolve page box")
+			}
+			if v == nil || (v.Type != PDF_TYPE_NUMERIC && v.Type != PDF_TYPE_REAL) {
+				valid = false
+				break
 			}
-			box = tmpBox.Value
+			rect[i] = v.Real
 		}
 
-		if box.Type == PDF_TYPE_ARRAY {
-			// If the box type is an array, calculate scaled value based on k
-			result["x"] = box.Array[0].Real / k
-			result["y"] = box.Array[1].Real / k
-			result["w"] = math.Abs(box.Array[0].Real-box.Array[2].Real) / k
-			result["h"] = math.Abs(box.Array[1].Real-box.Array[3].Real) / k
-			result["llx"] = math.Min(box.Array[0].Real, box.Array[2].Real)
-			result["lly"] = math.Min(box.Array[1].Real, box.Array[3].Real)
-			result["urx"] = math.Max(box.Array[0].Real, box.Array[2].Real)
-			result["ury"] = math.Max(box.Array[1].Real, box.Array[3].Real)
-		} else {
-			// TODO: Improve error handling
-			return nil, errors.New("Could not get page box")
+		// Boxes may be given by any two opposite corners
+		rect = [4]float64{math.Min(rect[0], rect[2]), math.Min(rect[1], rect[3]), math.Max(rect[0], rect[2]), math.Max(rect[1], rect[3])}
+		if valid && rect[2] > rect[0] && rect[3] > rect[1] {
+			return rect, true, nil
 		}
-	} else if _, ok := page.Value.Dictionary["/Parent"]; ok {
-		parentObj, err := pdfReader.resolveObject(page.Value.Dictionary["/Parent"])
+	}
+
+	// If the page box is inherited from /Parent, recursively return page box of parent
+	if parent, ok := page.Value.Dictionary["/Parent"]; ok {
+		parentObj, err := pdfReader.resolveObject(parent)
 		if err != nil {
-			return nil, errors.Wrap(err, "Could not resolve parent object")
+			return rect, false, errors.Wrap(err, "Could not resolve parent object")
 		}
-
-		// If the page box is inherited from /Parent, recursively return page box of parent
-		return pdfReader.getPageBox(parentObj, box_index, k)
+		return pdfReader.findPageBox(parentObj, box_index, depth+1)
 	}
 
-	return result, nil
+	return rect, false, nil
 }
 
 // Get page rotation for a page number
@@ -1542,7 +2223,67 @@ func (pdfReader *PdfReader) getPageRotation(pageno int) (*PdfValue, error) {
 		return nil, errors.New(fmt.Sprintf("Page %d does not exist!!!!", pageno))
 	}
 
-	return pdfReader._getPageRotation(pdfReader.pages[pageno-1])
+	page, err := pdfReader.getPage(pageno)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get page")
+	}
+
+	return pdfReader._getPageRotation(page)
+}
+
+// Get the rotation of a page in degrees clockwise, 0, 90, 180 or 270, and the
+// /Rotate value it was made from.  Values that are not multiples of 90 are
+// out of spec but exist; they are rounded to the nearest multiple of 90 and
+// add a warning.
+func (pdfReader *PdfReader) getNormalizedPageRotation(pageno int) (int, float64, error) {
+	rotation, err := pdfReader.getPageRotation(pageno)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	raw := float64(rotation.Int)
+	if rotation.Type == PDF_TYPE_REAL {
+		raw = rotation.Real
+	}
+
+	angle := int(math.Round(raw/90)) * 90 % 360
+	if angle < 0 {
+		angle += 360
+	}
+	if raw != math.Trunc(raw) || int(raw)%90 != 0 {
+		pdfReader.warnings.add(fmt.Sprintf("Page %d has /Rotate %g, which is not a multiple of 90, using %d", pageno, raw, angle))
+	}
+
+	return angle, raw, nil
+}
+
+// Get the transparency group attributes (/Group) of a page, or nil if it has none
+func (pdfReader *PdfReader) getPageGroup(pageno int) (*PdfValue, error) {
+	// Check to make sure page exists in pages slice
+	if len(pdfReader.pages) < pageno {
+		return nil, errors.New(fmt.Sprintf("Page %d does not exist", pageno))
+	}
+
+	page, err := pdfReader.getPage(pageno)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to resolve page object")
+	}
+
+	return page.Value.Dictionary["/Group"], nil
+}
+
+// Get the associated files (/AF) of a page, or nil if it has none
+func (pdfReader *PdfReader) getPageAssociatedFiles(pageno int) (*PdfValue, error) {
+	if len(pdfReader.pages) < pageno {
+		return nil, errors.New(fmt.Sprintf("Page %d does not exist", pageno))
+	}
+
+	page, err := pdfReader.getPage(pageno)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to resolve page object")
+	}
+
+	return page.Value.Dictionary["/AF"], nil
 }
 
 // Get page rotation for a page object spec
@@ -1552,7 +2293,7 @@ func (pdfReader *PdfReader) _getPageRotation(page *PdfValue) (*PdfValue, error)
 	// Resolve page object
 	page, err = pdfReader.resolveObject(page)
 	if err != nil {
-		return nil, errors.New("Failed to resolve page object")
+		return nil, errors.Wrap(err, "Failed to resolve page object")
 	}
 
 	// Check to make sure /Rotate exists in page dictionary
@@ -1591,33 +2332,85 @@ func (pdfReader *PdfReader) _getPageRotation(page *PdfValue) (*PdfValue, error)
 	return &PdfValue{Int: 0}, nil
 }
 
+// Read the cross reference sections the startxref keyword points to, the
+// catalog and the page tree
+func (pdfReader *PdfReader) readStructure() error {
+	// Find xref position
+	err := pdfReader.findXref()
+	if err != nil {
+		return errors.Wrap(err, "Failed to find xref position")
+	}
+
+	// Parse xref table
+	pdfReader.xrefSections = make(map[int]bool, 0)
+	err = pdfReader.readXref()
+	if err == nil {
+		err = pdfReader.checkObjectCount()
+	}
+	if err != nil {
+		return errors.Wrap(err, "Failed to read xref table")
+	}
+	if pdfReader.trailer == nil || pdfReader.trailer.Dictionary["/Root"] == nil {
+		return errors.New("No trailer with /Root")
+	}
+
+	err = pdfReader.readEncryption()
+	if err != nil {
+		return errors.Wrap(err, "Failed to read encryption dictionary")
+	}
+
+	// Read catalog
+	err = pdfReader.readRoot()
+	if err != nil {
+		return errors.Wrap(err, "Failed to read root")
+	}
+
+	// Read pages
+	err = pdfReader.readPages()
+	if err != nil {
+		return errors.Wrap(err, "Failed to to read pages")
+	}
+
+	return nil
+}
+
 func (pdfReader *PdfReader) read() error {
 	// Only run once
 	if !pdfReader.alreadyRead {
 		var err error
 
-		// Find xref position
-		err = pdfReader.findXref()
-		if err != nil {
-			return errors.Wrap(err, "Failed to find xref position")
+		err = pdfReader.readStructure()
+		if isLimitError(err) {
+			return err
 		}
-
-		// Parse xref table
-		err = pdfReader.readXref()
 		if err != nil {
-			return errors.Wrap(err, "Failed to read xref table")
-		}
+			// The cross reference sections are broken, rebuild them from
+			// the objects in the file
+			rebuildErr := pdfReader.reconstructXref()
+			if rebuildErr != nil {
+				return errors.Wrap(err, "Failed to reconstruct xref table: "+rebuildErr.Error())
+			}
+			pdfReader.xrefRebuilt = true
+			if err := pdfReader.checkObjectCount(); err != nil {
+				return err
+			}
 
-		// Read catalog
-		err = pdfReader.readRoot()
-		if err != nil {
-			return errors.Wrap(err, "Failed to read root")
-		}
+			err = pdfReader.readEncryption()
+			if err != nil {
+				return errors.Wrap(err, "Failed to read encryption dictionary")
+			}
 
-		// Read pages
-		err = pdfReader.readPages()
-		if err != nil {
-			return errors.Wrap(err, "Failed to to read pages")
+			// Read catalog
+			err = pdfReader.readRoot()
+			if err != nil {
+				return errors.Wrap(err, "Failed to read root")
+			}
+
+			// Read pages
+			err = pdfReader.readPages()
+			if err != nil {
+				return errors.Wrap(err, "Failed to to read pages")
+			}
 		}
 
 		// Now that pdfReader has been read, do not read again
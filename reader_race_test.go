@@ -0,0 +1,169 @@
+package gofpdi
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A parsed reader is shared by importers in different goroutines, see the
+// PdfReader documentation.  Run with go test -race.
+func TestSharedReader(t *testing.T) {
+	const importers = 8
+	const pages = 12
+
+	reader := readTestPDF(t, testDocument(pages))
+	// Evict objects while others resolve them
+	reader.SetCacheSize(5)
+
+	var wg sync.WaitGroup
+	errs := make([]error, importers)
+	results := make([]map[int]string, importers)
+	for g := 0; g < importers; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			results[g], errs[g] = importShared(reader, g, pages, Limits{})
+		}(g)
+	}
+	wg.Wait()
+
+	for g, err := range errs {
+		if err != nil {
+			t.Fatalf("importer %d: %s", g, err)
+		}
+	}
+
+	// The importers imported the pages in different orders, but the same
+	// objects
+	for g := 1; g < importers; g++ {
+		if len(results[g]) != len(results[0]) {
+			t.Fatalf("importer %d wrote %d objects, importer 0 %d", g, len(results[g]), len(results[0]))
+		}
+	}
+}
+
+// Importers with a timeout keep their deadlines to themselves, so they can
+// share a reader
+func TestSharedReaderTimeout(t *testing.T) {
+	const pages = 6
+
+	reader := readTestPDF(t, testDocument(pages))
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for g := range errs {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			_, errs[g] = importShared(reader, g, pages, Limits{Timeout: time.Second})
+		}(g)
+	}
+	wg.Wait()
+
+	for g, err := range errs {
+		if err != nil {
+			t.Fatalf("importer %d: %s", g, err)
+		}
+	}
+}
+
+// Import every page from reader, with limits, starting at a page that
+// depends on g, and get the written objects
+func importShared(reader *PdfReader, g int, pages int, limits Limits) (map[int]string, error) {
+	importer := NewImporter()
+	err := importer.SetSourceReader("shared", reader)
+	if err != nil {
+		return nil, err
+	}
+	importer.SetLimits(limits)
+
+	for i := 0; i < pages; i++ {
+		pageno := (i+g)%pages + 1
+		if _, err := importer.ImportPage(pageno, MediaBox); err != nil {
+			return nil, err
+		}
+		if _, err := importer.GetPageText(pageno); err != nil {
+			return nil, err
+		}
+		if _, err := importer.IsPageBlank(pageno, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := importer.PutFormXobjects(); err != nil {
+		return nil, err
+	}
+	return importer.GetImportedObjects(), nil
+}
+
+// Readers of a ReaderPool share the parsed source
+func TestReaderPoolConcurrentGet(t *testing.T) {
+	path := t.TempDir() + "/pool.pdf"
+	writeTestFile(t, path, testDocument(4))
+
+	pool := NewReaderPool(0)
+	defer pool.Close()
+
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for g := range errs {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			reader, err := pool.Get(path)
+			if err != nil {
+				errs[g] = err
+				return
+			}
+			defer pool.Release(reader)
+			_, errs[g] = importShared(reader, g, 4, Limits{})
+		}(g)
+	}
+	wg.Wait()
+
+	for g, err := range errs {
+		if err != nil {
+			t.Fatalf("goroutine %d: %s", g, err)
+		}
+	}
+}
+
+// Parallel imports write the same objects as sequential ones, with sorted
+// dictionary keys so the output does not depend on map order
+func TestImportPagesMatchesImportPage(t *testing.T) {
+	data := testDocument(6)
+	pagenos := []int{3, 1, 2, 6, 5, 4}
+
+	sequential := newTestImporter(t, data)
+	sequential.SetSortKeys(true)
+	for _, pageno := range pagenos {
+		if _, err := sequential.ImportPage(pageno, MediaBox); err != nil {
+			t.Fatal(err)
+		}
+	}
+	parallel := newTestImporter(t, data)
+	parallel.SetSortKeys(true)
+	if _, err := parallel.ImportPages(pagenos, MediaBox, 4); err != nil {
+		t.Fatal(err)
+	}
+
+	want, got := putTestObjects(t, sequential), putTestObjects(t, parallel)
+	if len(want) != len(got) {
+		t.Fatalf("got %d objects, want %d", len(got), len(want))
+	}
+	for id, data := range want {
+		if !bytes.Equal([]byte(got[id]), []byte(data)) {
+			t.Errorf("object %d differs:\n%s\nwant\n%s", id, got[id], data)
+		}
+	}
+}
+
+func putTestObjects(t *testing.T, importer *Importer) map[int]string {
+	t.Helper()
+
+	if _, err := importer.PutFormXobjects(); err != nil {
+		t.Fatal(err)
+	}
+	return importer.GetImportedObjects()
+}
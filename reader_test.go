@@ -0,0 +1,268 @@
+package gofpdi
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Values and operators as they appear in content streams and objects
+const benchmarkTokens = "q 0.95 0 0 0.95 12.5 -30 cm /GS1 gs BT /F1 9.5 Tf 1 0 0 1 72 712.25 Tm " +
+	"[(Hello) -250 (W) 30 (orld)] TJ <48656c6c6f> Tj ET 0 0 1 RG 72 72 m 540 72 l S Q\n" +
+	"<< /Type /Page /MediaBox [0 0 612 792] /Resources << /Font << /F1 5 0 R >> >> /Annots [6 0 R 7 0 R] >>\n"
+
+// Tokenize and parse values the way content and object streams are read
+func BenchmarkReadToken(b *testing.B) {
+	data := []byte(strings.Repeat(benchmarkTokens, 100))
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		reader := &PdfReader{}
+		r := bufio.NewReader(bytes.NewReader(data))
+		for {
+			t, err := reader.readToken(r)
+			if err != nil || t == "" {
+				break
+			}
+			_, err = reader.readValue(r, t)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// Open a document and resolve every object of its cross-reference table
+func BenchmarkParseFile(b *testing.B) {
+	data := testDocument(100)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		reader, err := NewPdfReaderFromStream(bytes.NewReader(data))
+		if err != nil {
+			b.Fatal(err)
+		}
+		for id := range reader.xref {
+			if id == 0 {
+				continue
+			}
+			_, err := reader.resolveObject(&PdfValue{Type: PDF_TYPE_OBJREF, Id: id})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// Names are read with their #xx escapes decoded and written with the bytes
+// that cannot appear in a name escaped, so reading the output again gives
+// the same name
+func TestNameEscapes(t *testing.T) {
+	for _, test := range []struct {
+		in      string
+		name    string
+		written string
+	}{
+		{"/Im#31", "/Im1", "/Im1 "},
+		{"/F#20Bold", "/F Bold", "/F#20Bold "},
+		{"/text#2Fxml", "/text/xml", "/text#2Fxml "},
+		{"/A#28x#29", "/A(x)", "/A#28x#29 "},
+		{"/A#5b#5D", "/A[]", "/A#5B#5D "},
+		{"/A#3C#3E#7B#7D#25", "/A<>{}%", "/A#3C#3E#7B#7D#25 "},
+		{"/a#23b", "/a#b", "/a#23b "},
+		{"/Caf#E9", "/Caf\xe9", "/Caf#E9 "},
+		{"/Tab#09#0A", "/Tab\t\n", "/Tab#09#0A "},
+		// Invalid escapes are kept, with the # escaped when written
+		{"/Bad#zz", "/Bad#zz", "/Bad#23zz "},
+		{"/End#4", "/End#4", "/End#234 "},
+		{"/", "/", "/ "},
+	} {
+		value, err := fuzzReadValue([]byte(test.in))
+		if err != nil {
+			t.Fatalf("%s: %s", test.in, err)
+		}
+		if value.Type != PDF_TYPE_TOKEN || value.Token != test.name {
+			t.Errorf("%s is read as %q, want %q", test.in, value.Token, test.name)
+		}
+
+		written := string(fuzzWriteValue(value))
+		if written != test.written {
+			t.Errorf("%s is written as %q, want %q", test.in, written, test.written)
+		}
+
+		again, err := fuzzReadValue([]byte(written))
+		if err != nil {
+			t.Fatalf("%s: %s", written, err)
+		}
+		if again.Token != value.Token {
+			t.Errorf("%s is read again as %q, want %q", written, again.Token, value.Token)
+		}
+	}
+}
+
+// Escaped names as dictionary keys and as values in arrays and dictionaries
+func TestNameEscapesInDictionary(t *testing.T) {
+	in := "<< /F#20Bold /Im#31 /Ref [/a#25b /c#2Fd] /Sub << /#28k#29 /v#5D >> >>"
+	value, err := fuzzReadValue([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if name := value.Dictionary["/F Bold"]; name == nil || name.Token != "/Im1" {
+		t.Errorf("/F Bold is %v, want /Im1", name)
+	}
+	if sub := value.Dictionary["/Sub"]; sub == nil || sub.Dictionary["/(k)"] == nil || sub.Dictionary["/(k)"].Token != "/v]" {
+		t.Errorf("/Sub is %v, want << /(k) /v] >>", sub)
+	}
+
+	written := string(fuzzWriteValue(value))
+	want := "<</F#20Bold /Im1 /Ref [/a#25b /c#2Fd ]\n/Sub <</#28k#29 /v#5D >>>>"
+	if written != want {
+		t.Errorf("written as %q, want %q", written, want)
+	}
+
+	again, err := fuzzReadValue([]byte(written))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rewritten := string(fuzzWriteValue(again)); rewritten != written {
+		t.Errorf("written again as %q, want %q", rewritten, written)
+	}
+}
+
+// A page whose graphics states are object 5, which is free, and 6 1 R, a
+// generation of object 6 that is not in use
+var freeEntryObjects = []string{
+	"<< /Type /Catalog /Pages 2 0 R >>",
+	"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+	"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 100] /Contents 4 0 R " +
+		"/Resources << /ExtGState << /GS1 5 0 R /GS2 6 1 R >> >> >>",
+	testStream("", "/GS1 gs /GS2 gs 0 0 10 10 re f"),
+	"<< /Type /ExtGState /CA 0.5 >>",
+	"<< /Type /ExtGState /ca 0.5 >>",
+}
+
+// References to free entries of the cross-reference table, also when an
+// incremental update frees an object, resolve to null
+func TestFreeXrefEntries(t *testing.T) {
+	data := buildPDF(freeEntryObjects...)
+	entry := []byte(fmt.Sprintf("%010d 00000 n \n", bytes.Index(data, []byte("\n5 0 obj"))+1))
+	free := bytes.Replace(data, entry, []byte("0000000000 00001 f \n"), 1)
+	if bytes.Equal(free, data) {
+		t.Fatal("no cross-reference entry of object 5")
+	}
+
+	// The update frees object 5 of the original revision
+	prev := bytes.LastIndex(data, []byte("\nxref\n")) + 1
+	update := append([]byte(nil), data...)
+	xref := len(update)
+	update = append(update, fmt.Sprintf("xref\n5 1\n0000000000 00001 f \ntrailer\n<< /Size 7 /Root 1 0 R /Prev %d >>\nstartxref\n%d\n%%%%EOF\n", prev, xref)...)
+
+	for _, test := range []struct {
+		name string
+		data []byte
+	}{
+		{"free entry", free},
+		{"incremental update", update},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			reader := readTestPDF(t, test.data)
+			if reader.xrefRebuilt {
+				t.Fatal("cross-reference table was rebuilt")
+			}
+			for _, ref := range []*PdfValue{{Type: PDF_TYPE_OBJREF, Id: 5}, {Type: PDF_TYPE_OBJREF, Id: 6, Gen: 1}} {
+				obj, err := reader.resolveObject(ref)
+				if err != nil {
+					t.Fatalf("%d %d R: %s", ref.Id, ref.Gen, err)
+				}
+				if obj.Value == nil || obj.Value.Type != PDF_TYPE_NULL {
+					t.Errorf("%d %d R is %v, want null", ref.Id, ref.Gen, obj.Value)
+				}
+			}
+
+			out := importTestDocument(t, test.data, nil)
+			checkReferences(t, out)
+			outReader, form := outputForm(t, out, 1)
+			for _, key := range []string{"/GS1", "/GS2"} {
+				gs := resolvePath(t, outReader, form, "/Resources", "/ExtGState", key)
+				if gs.Type != PDF_TYPE_NULL {
+					t.Errorf("%s is written as %v, want null", key, gs)
+				}
+			}
+		})
+	}
+}
+
+// Comments are whitespace between any two tokens of a value
+func TestComments(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want string
+	}{
+		{"<< % comment\n/Type /Page %another\r/Count 3 >>", "<< /Type /Page /Count 3 >>"},
+		{"<</Type%no space before\n/Page>>", "<< /Type /Page >>"},
+		{"<< /Kids [3 0 R % first\n 4 %in a reference\n0 %\nR] >>", "<< /Kids [3 0 R 4 0 R] >>"},
+		{"[1 %one\r\n2 %two\n%%three\n(3 % in a string) <41>]", "[1 2 (3 % in a string) <41>]"},
+		{"<< /A << %nested\n/B [%\n/C] >> >>", "<< /A << /B [/C] >> >>"},
+		{"[/A %at the end of the data without an end of line\n] %", "[/A]"},
+		{"%%only comments\n%first\n/Name", "/Name"},
+	} {
+		value, err := fuzzReadValue([]byte(test.in))
+		if err != nil {
+			t.Fatalf("%q: %s", test.in, err)
+		}
+		want, err := fuzzReadValue([]byte(test.want))
+		if err != nil {
+			t.Fatalf("%q: %s", test.want, err)
+		}
+		if got, want := fuzzWriteValue(value), fuzzWriteValue(want); !bytes.Equal(got, want) {
+			t.Errorf("%q is read as %q, want %q", test.in, got, want)
+		}
+	}
+}
+
+// Comments around the stream keywords are skipped, but a % in the stream
+// data, even right after the stream keyword, is data
+func TestStreamComments(t *testing.T) {
+	data := "%not a comment\n%%EOF\n0 0 10 10 re f % neither\r"
+	objects := []string{
+		"<< /Type /Catalog %comment\n/Pages 2 0 R >> %after the catalog",
+		"<< /Type /Pages /Kids [3 0 R %page\n] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 100] /Contents 4 0 R /Resources << >> >>",
+		fmt.Sprintf("<< /Length %d %%length\n>> %%before the stream\nstream\n%sendstream %%after the stream\n%%another", len(data), data),
+	}
+
+	reader := readTestPDF(t, buildPDF(objects...))
+	if reader.xrefRebuilt {
+		t.Fatal("cross-reference table was rebuilt")
+	}
+	if got := streamData(t, reader, 4); got != data {
+		t.Errorf("stream data is %q, want %q", got, data)
+	}
+
+	// A comment on the line of the stream keyword is part of the data
+	stream := "% a comment?\n0 0 10 10 re f"
+	objects[3] = fmt.Sprintf("<< /Length %d >>\nstream %s\nendstream", len(stream), stream)
+	reader = readTestPDF(t, buildPDF(objects...))
+	if got := streamData(t, reader, 4); got != stream {
+		t.Errorf("stream data is %q, want %q", got, stream)
+	}
+}
+
+// Get the raw data of stream object id
+func streamData(t *testing.T, reader *PdfReader, id int) string {
+	t.Helper()
+
+	obj, err := reader.resolveObject(&PdfValue{Type: PDF_TYPE_OBJREF, Id: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.Stream == nil {
+		t.Fatalf("object %d is not a stream", id)
+	}
+	return string(obj.Stream.Bytes)
+}
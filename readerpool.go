@@ -0,0 +1,181 @@
+package gofpdi
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// A ReaderPool keeps parsed sources open, so that servers importing from the
+// same files on every request parse them only once.  Sources are identified by
+// the sha1 hash of their contents.  Each Get returns a new reader for the
+// source that shares the parsed cross reference table, page tree and object
+// cache with the other readers of that source, and can be used concurrently
+// with them.  Sources that are not in use for longer than the idle time are
+// closed.
+type ReaderPool struct {
+	mu      sync.Mutex
+	idle    time.Duration
+	sources map[string]*pooledSource
+	// Hash of a file's contents by path, for files that did not change
+	files map[string]pooledFile
+	// Source of each reader handed out by Get
+	readers map[*PdfReader]*pooledSource
+}
+
+type pooledSource struct {
+	reader   *PdfReader
+	file     *os.File
+	refs     int
+	lastUsed time.Time
+	// Close was called while the source was in use
+	closing bool
+}
+
+type pooledFile struct {
+	hash    string
+	size    int64
+	modTime time.Time
+}
+
+// Create a reader pool that closes sources that were not used for idle.  An
+// idle time of 0 keeps sources open until Close.
+func NewReaderPool(idle time.Duration) *ReaderPool {
+	return &ReaderPool{
+		idle:    idle,
+		sources: make(map[string]*pooledSource, 0),
+		files:   make(map[string]pooledFile, 0),
+		readers: make(map[*PdfReader]*pooledSource, 0),
+	}
+}
+
+// Get a reader for a file, parsing it if it is not in the pool.  The reader
+// must be given back with Release when it is no longer used.
+func (pool *ReaderPool) Get(path string) (*PdfReader, error) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.expire()
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to open file")
+	}
+
+	hash, err := pool.fileHash(path, f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	source, ok := pool.sources[hash]
+	if ok {
+		f.Close()
+	} else {
+		reader, err := NewPdfReaderFromStream(f)
+		if err != nil {
+			f.Close()
+			return nil, errors.Wrap(err, "Failed to read pdf")
+		}
+		reader.sourceFile = path
+		reader.fingerprint = hash
+
+		source = &pooledSource{reader: reader, file: f}
+		pool.sources[hash] = source
+	}
+
+	reader, err := source.reader.clone()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to create reader")
+	}
+
+	source.refs++
+	source.lastUsed = time.Now()
+	pool.readers[reader] = source
+
+	return reader, nil
+}
+
+// Give back a reader returned by Get
+func (pool *ReaderPool) Release(reader *PdfReader) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	source, ok := pool.readers[reader]
+	if !ok {
+		return
+	}
+	delete(pool.readers, reader)
+
+	source.refs--
+	source.lastUsed = time.Now()
+
+	if source.closing && source.refs == 0 {
+		source.file.Close()
+		delete(pool.sources, source.reader.fingerprint)
+	}
+	pool.expire()
+}
+
+// Close all sources that are not in use.  Sources that are in use are
+// closed when their last reader is released.
+func (pool *ReaderPool) Close() error {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	var err error
+	for hash, source := range pool.sources {
+		if source.refs > 0 {
+			source.closing = true
+			continue
+		}
+		if closeErr := source.file.Close(); closeErr != nil && err == nil {
+			err = errors.Wrap(closeErr, "Failed to close file")
+		}
+		delete(pool.sources, hash)
+	}
+
+	return err
+}
+
+// Close sources that have not been in use for longer than the idle time
+func (pool *ReaderPool) expire() {
+	if pool.idle <= 0 {
+		return
+	}
+
+	for hash, source := range pool.sources {
+		if source.refs == 0 && time.Since(source.lastUsed) > pool.idle {
+			source.file.Close()
+			delete(pool.sources, hash)
+		}
+	}
+}
+
+// Get the hash of the contents of an open file, hashing it again only if its
+// size or modification time changed
+func (pool *ReaderPool) fileHash(path string, f *os.File) (string, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to obtain file information")
+	}
+
+	if file, ok := pool.files[path]; ok && file.size == info.Size() && file.modTime.Equal(info.ModTime()) {
+		return file.hash, nil
+	}
+
+	hasher := sha1.New()
+	if _, err := io.Copy(hasher, io.NewSectionReader(f, 0, info.Size())); err != nil {
+		return "", errors.Wrap(err, "Failed to read file")
+	}
+	hash := hex.EncodeToString(hasher.Sum(nil))
+
+	pool.files[path] = pooledFile{hash: hash, size: info.Size(), modTime: info.ModTime()}
+
+	return hash, nil
+}
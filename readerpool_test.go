@@ -0,0 +1,191 @@
+package gofpdi
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Get the only source of a reader pool
+func pooledTestSource(t *testing.T, pool *ReaderPool) *pooledSource {
+	t.Helper()
+
+	if len(pool.sources) != 1 {
+		t.Fatalf("pool has %d sources, want 1", len(pool.sources))
+	}
+	for _, source := range pool.sources {
+		return source
+	}
+	return nil
+}
+
+// Check whether the file of a pooled source was closed
+func sourceClosed(source *pooledSource) bool {
+	_, err := source.file.Stat()
+	return err != nil
+}
+
+// Readers of the same file share one source, which counts the readers that
+// were not released
+func TestReaderPoolRefs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pool.pdf")
+	writeTestFile(t, path, testDocument(2))
+	pool := NewReaderPool(0)
+	defer pool.Close()
+
+	first, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Fatal("Get returned the same reader twice")
+	}
+	source := pooledTestSource(t, pool)
+	if source.refs != 2 {
+		t.Fatalf("source has %d references, want 2", source.refs)
+	}
+
+	pool.Release(first)
+	// Releasing a reader again, or one of another pool, does nothing
+	pool.Release(first)
+	pool.Release(readTestPDF(t, testDocument(1)))
+	if source.refs != 1 {
+		t.Errorf("source has %d references, want 1", source.refs)
+	}
+	pool.Release(second)
+	if source.refs != 0 || sourceClosed(source) {
+		t.Errorf("source has %d references and is closed: %v, want 0 and open", source.refs, sourceClosed(source))
+	}
+}
+
+// Sources that were not used for the idle time are closed, unless they are
+// in use
+func TestReaderPoolExpire(t *testing.T) {
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "a.pdf"), filepath.Join(dir, "b.pdf")}
+	writeTestFile(t, paths[0], testDocument(2))
+	writeTestFile(t, paths[1], testDocument(3))
+	pool := NewReaderPool(time.Minute)
+	defer pool.Close()
+
+	idle, err := pool.Get(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.Release(idle)
+	inUse, err := pool.Get(paths[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Release(inUse)
+
+	idleSource, inUseSource := pool.sources[idle.fingerprint], pool.sources[inUse.fingerprint]
+	pool.expire()
+	if len(pool.sources) != 2 {
+		t.Fatalf("%d sources are left before the idle time, want 2", len(pool.sources))
+	}
+
+	idleSource.lastUsed = time.Now().Add(-2 * time.Minute)
+	inUseSource.lastUsed = time.Now().Add(-2 * time.Minute)
+	pool.expire()
+	if _, ok := pool.sources[idle.fingerprint]; ok || !sourceClosed(idleSource) {
+		t.Error("idle source was not closed")
+	}
+	if _, ok := pool.sources[inUse.fingerprint]; !ok || sourceClosed(inUseSource) {
+		t.Error("source in use was closed")
+	}
+}
+
+// The hash of a file is computed again when its size or modification time
+// changes, and the cached hash is used otherwise
+func TestReaderPoolFileChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pool.pdf")
+	writeTestFile(t, path, testDocument(2))
+	pool := NewReaderPool(0)
+	defer pool.Close()
+
+	pages := func() int {
+		t.Helper()
+		reader, err := pool.Get(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer pool.Release(reader)
+		n, err := reader.getNumPages()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	setModTime := func(modTime time.Time) {
+		t.Helper()
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	setModTime(modTime)
+	if n := pages(); n != 2 {
+		t.Fatalf("got %d pages, want 2", n)
+	}
+
+	// Another size
+	writeTestFile(t, path, testDocument(3))
+	setModTime(modTime)
+	if n := pages(); n != 3 {
+		t.Errorf("got %d pages after the size changed, want 3", n)
+	}
+
+	// The same size, with contents changed in place
+	changed := bytes.Replace(testDocument(3), []byte("/Count 3"), []byte("/Count 2"), 1)
+	writeTestFile(t, path, changed)
+	setModTime(modTime)
+	if n := pages(); n != 3 {
+		t.Errorf("got %d pages with the hash of the unchanged file information, want 3", n)
+	}
+	setModTime(modTime.Add(time.Minute))
+	if n := pages(); n != 2 {
+		t.Errorf("got %d pages after the modification time changed, want 2", n)
+	}
+}
+
+// Close keeps the sources of readers that are in use open, and closes them
+// when the readers are released
+func TestReaderPoolCloseInUse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pool.pdf")
+	writeTestFile(t, path, testDocument(2))
+	pool := NewReaderPool(0)
+
+	reader, err := pool.Get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Close(); err != nil {
+		t.Fatal(err)
+	}
+	source := pooledTestSource(t, pool)
+	if sourceClosed(source) {
+		t.Fatal("source in use was closed")
+	}
+	importer := NewImporter()
+	if err := importer.SetSourceReader("pool", reader); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := importer.ImportPage(2, MediaBox); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := importer.PutFormXobjects(); err != nil {
+		t.Fatal(err)
+	}
+
+	pool.Release(reader)
+	if len(pool.sources) != 0 || !sourceClosed(source) {
+		t.Error("source was not closed when its reader was released")
+	}
+}
@@ -0,0 +1,31 @@
+package gofpdi
+
+// Flate encode the data of streams that have no filter, see Importer.SetCompressStreams
+func (pdfWriter *PdfWriter) SetCompressStreams(b bool) {
+	pdfWriter.compress_streams = b
+}
+
+// Get a stream with Flate encoded data for a stream without filter.  Streams
+// that have a filter, XMP metadata (which is meant to be readable by tools that
+// don't parse PDF) and streams that don't get smaller are returned as they are.
+func (pdfReader *PdfReader) compressStream(obj *PdfValue) *PdfValue {
+	dict := obj.Value.Dictionary
+	if _, ok := dict["/Filter"]; ok {
+		return obj
+	}
+	if t, ok := dict["/Type"]; ok && t.Token == "/Metadata" {
+		return obj
+	}
+
+	data, err := pdfReader.streamData(obj)
+	if err != nil || len(data) == 0 {
+		return obj
+	}
+
+	compressed := deflate(data)
+	if len(compressed) >= len(data) {
+		return obj
+	}
+
+	return replaceStreamData(obj, compressed, nil)
+}
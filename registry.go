@@ -0,0 +1,29 @@
+package gofpdi
+
+import (
+	"fmt"
+)
+
+// Get the key of an imported page in importedPages: its source, page number
+// and (normalized) box, so importing a page again with another box creates
+// another template
+func pageKey(source string, pageno int, box BoxName) string {
+	return fmt.Sprintf("%s-%04d-%s", source, pageno, box)
+}
+
+// Get the id of the template that imported page pageno of source with box,
+// e.g. to draw a page that another part of a long-lived importer imported
+// without keeping its id.  Sources are identified as by the importer (see
+// GetFingerprint), so an identical source under another name finds the
+// same template.  ok is false if the page has not been imported with box.
+func (importer *Importer) FindTemplate(source string, pageno int, box BoxName) (int, bool) {
+	box, err := box.normalize()
+	if err != nil {
+		return -1, false
+	}
+	tplid, ok := importer.importedPages[pageKey(importer.canonicalSource(source), pageno, box)]
+	if !ok {
+		return -1, false
+	}
+	return tplid, true
+}
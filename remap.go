@@ -0,0 +1,61 @@
+package gofpdi
+
+// An object of a source document, identified by the source (the file name
+// passed to SetSourceFile, or the name passed to SetSourceReader) and its
+// object number and generation in that source
+type ObjectRef struct {
+	Source string
+	Id     int
+	Gen    int
+}
+
+// Get the output objects of the source objects copied by the writer, including
+// merged streams (see SetMergeFontFiles), which map to the identical stream
+// that was written instead
+func (pdfWriter *PdfWriter) objectIds(source string) map[ObjectRef]*PdfObjectId {
+	res := make(map[ObjectRef]*PdfObjectId, len(pdfWriter.don_obj_stack)+len(pdfWriter.merged_objs))
+	if pdfWriter.r == nil {
+		// Nothing has been written yet
+		return res
+	}
+	for id, v := range pdfWriter.don_obj_stack {
+		res[ObjectRef{Source: source, Id: id, Gen: v.Gen}] = &PdfObjectId{id: v.NewId, hash: pdfWriter.shaOfInt(v.NewId)}
+	}
+	for ref, pdfObjId := range pdfWriter.merged_objs {
+		ref.Source = source
+		res[ref] = pdfObjId
+	}
+	return res
+}
+
+// Get the object ids that the objects of all sources were given in the
+// output, after PutFormXobjects, so references that gofpdi does not copy
+// (e.g. of links, outlines or structure trees) can be fixed up.  The ids are
+// the ones of GetImportedObjects, or the ones allocated by the backend for
+// objects put with PutFormXobjectsTo.  Objects that were not needed by the
+// imported pages are not in the map.
+func (importer *Importer) GetObjectIdMap() map[ObjectRef]int {
+	res := make(map[ObjectRef]int, 0)
+	for source, writer := range importer.writers {
+		for ref, pdfObjId := range writer.objectIds(source) {
+			if id, ok := importer.backendIds[pdfObjId.hash]; ok {
+				res[ref] = id
+			} else {
+				res[ref] = pdfObjId.id
+			}
+		}
+	}
+	return res
+}
+
+// Get the object hashes that the objects of all sources were given in the
+// output, after PutFormXobjectsUnordered, see GetObjectIdMap
+func (importer *Importer) GetObjectIdMapUnordered() map[ObjectRef]string {
+	res := make(map[ObjectRef]string, 0)
+	for source, writer := range importer.writers {
+		for ref, pdfObjId := range writer.objectIds(source) {
+			res[ref] = pdfObjId.hash
+		}
+	}
+	return res
+}
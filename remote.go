@@ -0,0 +1,247 @@
+package gofpdi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Bytes fetched by a range request at least, so the small reads of the parser
+// do not become a request each
+const httpSourceBlockSize = 64 * 1024
+
+// How an HTTPSource retries range requests that fail
+type RetryPolicy struct {
+	// Attempts of each request, including the first (1 if 0)
+	Attempts int
+	// Wait before the first retry, doubled for every further retry up to
+	// MaxBackoff (no wait if 0)
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Time limit of each attempt (none if 0)
+	Timeout time.Duration
+}
+
+// The retry policy of OpenHTTPSource if none is given: 4 attempts, half a
+// minute each, waiting from 200ms up to 5s between them
+var DefaultRetryPolicy = RetryPolicy{Attempts: 4, Backoff: 200 * time.Millisecond, MaxBackoff: 5 * time.Second, Timeout: 30 * time.Second}
+
+// A source document on an HTTP(S) server that supports range requests, such
+// as a presigned S3 (or other object storage) URL.  The document is read in
+// ranges as it is used; failed requests (network errors, 429 and 5xx
+// responses, short responses) are retried following the retry policy, and
+// responses with another range than the one requested fail.  Use it with
+// Importer.SetSource.
+type HTTPSource struct {
+	url    string
+	size   int64
+	client *http.Client
+	policy RetryPolicy
+
+	// The block read last
+	mu       sync.Mutex
+	block    []byte
+	blockOff int64
+}
+
+// Open a document on an HTTP(S) server as a Source.  The size is found with a
+// ranged GET rather than HEAD, since presigned URLs are only valid for one
+// method.  A nil client uses http.DefaultClient; a nil policy DefaultRetryPolicy.
+func OpenHTTPSource(url string, client *http.Client, policy *RetryPolicy) (*HTTPSource, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if policy == nil {
+		policy = &DefaultRetryPolicy
+	}
+
+	source := &HTTPSource{url: url, client: client, policy: *policy}
+
+	var contentRange string
+	_, err := source.fetch(0, 1, func(resp *http.Response) {
+		contentRange = resp.Header.Get("Content-Range")
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to open "+url)
+	}
+
+	// bytes 0-0/size
+	i := strings.LastIndexByte(contentRange, '/')
+	if i < 0 {
+		return nil, errors.New("Server did not return the size of " + url)
+	}
+	source.size, err = strconv.ParseInt(contentRange[i+1:], 10, 64)
+	if err != nil || source.size <= 0 {
+		return nil, errors.New(fmt.Sprintf("Invalid size of %s: %q", url, contentRange))
+	}
+
+	return source, nil
+}
+
+// Get the size of the document
+func (source *HTTPSource) Size() int64 {
+	return source.size
+}
+
+// Read len(p) bytes at offset off, from the block read last if it has them
+func (source *HTTPSource) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("Negative offset")
+	}
+	if off >= source.size {
+		return 0, io.EOF
+	}
+
+	end := off + int64(len(p))
+	if end > source.size {
+		end = source.size
+	}
+
+	source.mu.Lock()
+	block, blockOff := source.block, source.blockOff
+	source.mu.Unlock()
+
+	if off < blockOff || end > blockOff+int64(len(block)) {
+		length := end - off
+		if length < httpSourceBlockSize {
+			length = httpSourceBlockSize
+		}
+		if off+length > source.size {
+			length = source.size - off
+		}
+
+		var err error
+		block, err = source.fetch(off, length, nil)
+		if err != nil {
+			return 0, err
+		}
+		blockOff = off
+
+		source.mu.Lock()
+		source.block, source.blockOff = block, blockOff
+		source.mu.Unlock()
+	}
+
+	n := copy(p, block[off-blockOff:end-blockOff])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Get length bytes at offset off, retrying failed requests.  header is called
+// with the response that succeeded, if not nil.
+func (source *HTTPSource) fetch(off int64, length int64, header func(*http.Response)) ([]byte, error) {
+	attempts := source.policy.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	backoff := source.policy.Backoff
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		var data []byte
+		var retry bool
+		data, retry, err = source.fetchOnce(off, length, header)
+		if err == nil {
+			return data, nil
+		}
+		if !retry || attempt >= attempts {
+			break
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+		if source.policy.MaxBackoff > 0 && backoff > source.policy.MaxBackoff {
+			backoff = source.policy.MaxBackoff
+		}
+	}
+
+	return nil, errors.Wrap(err, fmt.Sprintf("Failed to read %d bytes at %d", length, off))
+}
+
+// Make one range request.  Returns whether a failed request may succeed if
+// it is repeated.
+func (source *HTTPSource) fetchOnce(off int64, length int64, header func(*http.Response)) ([]byte, bool, error) {
+	ctx := context.Background()
+	if source.policy.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, source.policy.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, source.url, nil)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "Invalid request")
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+length-1))
+
+	resp, err := source.client.Do(req)
+	if err != nil {
+		return nil, true, errors.Wrap(err, "Request failed")
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+	case resp.StatusCode == http.StatusOK:
+		return nil, false, errors.New("Server does not support range requests")
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return nil, true, errors.New(fmt.Sprintf("Server responded %s", resp.Status))
+	default:
+		return nil, false, errors.New(fmt.Sprintf("Server responded %s", resp.Status))
+	}
+
+	// The server must send the range that was requested, not one it chose
+	first, last, err := parseContentRange(resp.Header.Get("Content-Range"))
+	if err != nil {
+		return nil, false, err
+	}
+	if first != off || last != off+length-1 {
+		return nil, false, errors.New(fmt.Sprintf("Server responded with bytes %d-%d instead of %d-%d", first, last, off, off+length-1))
+	}
+
+	data := make([]byte, length)
+	_, err = io.ReadFull(resp.Body, data)
+	if err != nil {
+		return nil, true, errors.Wrap(err, "Failed to read response")
+	}
+
+	if header != nil {
+		header(resp)
+	}
+	return data, false, nil
+}
+
+// Get the first and the last byte of a Content-Range header, e.g.
+// "bytes 0-99/1000"
+func parseContentRange(header string) (int64, int64, error) {
+	invalid := errors.New(fmt.Sprintf("Invalid Content-Range %q", header))
+
+	spec := strings.TrimPrefix(header, "bytes ")
+	i := strings.IndexByte(spec, '/')
+	if spec == header || i < 0 {
+		return 0, 0, invalid
+	}
+	dash := strings.IndexByte(spec[:i], '-')
+	if dash < 0 {
+		return 0, 0, invalid
+	}
+	first, err := strconv.ParseInt(spec[:dash], 10, 64)
+	if err != nil {
+		return 0, 0, invalid
+	}
+	last, err := strconv.ParseInt(spec[dash+1:i], 10, 64)
+	if err != nil || last < first {
+		return 0, 0, invalid
+	}
+	return first, last, nil
+}
@@ -0,0 +1,188 @@
+package gofpdi
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Serve data with range requests, failing requests with fail until it
+// returns false
+func rangeServer(t *testing.T, data []byte, fail func(w http.ResponseWriter, r *http.Request, request int32) bool) (*httptest.Server, *int32) {
+	t.Helper()
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := atomic.AddInt32(&requests, 1)
+		if fail != nil && fail(w, r, request) {
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// A retry policy with short waits for tests
+var testRetryPolicy = RetryPolicy{Attempts: 3, Backoff: 10 * time.Millisecond, MaxBackoff: 15 * time.Millisecond}
+
+// Pages are imported from a document read in ranges
+func TestHTTPSourceImport(t *testing.T) {
+	data := testDocument(3)
+	server, _ := rangeServer(t, data, nil)
+
+	source, err := OpenHTTPSource(server.URL, nil, &testRetryPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source.Size() != int64(len(data)) {
+		t.Fatalf("size is %d, want %d", source.Size(), len(data))
+	}
+	importer := NewImporter()
+	if err := importer.SetSource(server.URL, source); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := importer.ImportPage(3, MediaBox); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Responses with another range than the one requested fail without retries,
+// and short responses are retried
+func TestHTTPSourceRange(t *testing.T) {
+	data := testDocument(1)
+	for _, test := range []struct {
+		name     string
+		respond  func(w http.ResponseWriter, r *http.Request)
+		requests int32
+		want     string
+	}{
+		{"other range", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Range", "bytes 0-9/100")
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(data[:10])
+		}, 1, "instead of"},
+		{"invalid range", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Range", "bytes */100")
+			w.WriteHeader(http.StatusPartialContent)
+		}, 1, "Invalid Content-Range"},
+		{"short body", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Range", strings.Replace(r.Header.Get("Range"), "=", " ", 1)+"/*")
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(data[10:20])
+		}, 3, "Failed to read response"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			// The request of the size succeeds
+			server, requests := rangeServer(t, data, func(w http.ResponseWriter, r *http.Request, request int32) bool {
+				if request == 1 {
+					return false
+				}
+				test.respond(w, r)
+				return true
+			})
+			source, err := OpenHTTPSource(server.URL, nil, &testRetryPolicy)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = source.ReadAt(make([]byte, 100), 10)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("got error %v, want %q", err, test.want)
+			}
+			if got := atomic.LoadInt32(requests) - 1; got != test.requests {
+				t.Errorf("made %d requests, want %d", got, test.requests)
+			}
+		})
+	}
+}
+
+// Failed requests are retried after waiting for the backoff, doubled up to
+// the maximum backoff
+func TestHTTPSourceRetry(t *testing.T) {
+	data := testDocument(1)
+	server, requests := rangeServer(t, data, func(w http.ResponseWriter, r *http.Request, request int32) bool {
+		if request <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return true
+		}
+		return false
+	})
+
+	start := time.Now()
+	source, err := OpenHTTPSource(server.URL, nil, &testRetryPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed, want := time.Since(start), testRetryPolicy.Backoff+testRetryPolicy.MaxBackoff; elapsed < want {
+		t.Errorf("retried after %s, want at least %s", elapsed, want)
+	}
+	if got := atomic.LoadInt32(requests); got != 3 {
+		t.Errorf("made %d requests, want 3", got)
+	}
+	if source.Size() != int64(len(data)) {
+		t.Errorf("size is %d, want %d", source.Size(), len(data))
+	}
+
+	// Once the attempts are used up the error is returned
+	atomic.StoreInt32(requests, 0)
+	_, err = OpenHTTPSource(server.URL, nil, &RetryPolicy{Attempts: 2})
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("got error %v, want a 503 response", err)
+	}
+	if got := atomic.LoadInt32(requests); got != 2 {
+		t.Errorf("made %d requests, want 2", got)
+	}
+}
+
+// A server that ignores the range and responds with the whole document is
+// not retried
+func TestHTTPSourceWithoutRanges(t *testing.T) {
+	data := testDocument(1)
+	server, requests := rangeServer(t, data, func(w http.ResponseWriter, r *http.Request, request int32) bool {
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
+		return true
+	})
+
+	_, err := OpenHTTPSource(server.URL, nil, &testRetryPolicy)
+	if err == nil || !strings.Contains(err.Error(), "does not support range requests") {
+		t.Fatalf("got error %v, want one for range requests", err)
+	}
+	if got := atomic.LoadInt32(requests); got != 1 {
+		t.Errorf("made %d requests, want 1", got)
+	}
+}
+
+// An attempt that takes longer than the timeout of the policy is cancelled
+// and retried
+func TestHTTPSourceTimeout(t *testing.T) {
+	data := testDocument(1)
+	server, requests := rangeServer(t, data, func(w http.ResponseWriter, r *http.Request, request int32) bool {
+		if request == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return true
+		}
+		return false
+	})
+
+	policy := testRetryPolicy
+	policy.Timeout = 50 * time.Millisecond
+	start := time.Now()
+	if _, err := OpenHTTPSource(server.URL, nil, &policy); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("took %s, the first attempt was not cancelled", elapsed)
+	}
+	if got := atomic.LoadInt32(requests); got != 2 {
+		t.Errorf("made %d requests, want 2", got)
+	}
+}
@@ -0,0 +1,66 @@
+package gofpdi
+
+// How imported objects are numbered in the output, see Importer.SetRenumbering
+type Renumbering int
+
+const (
+	// Number objects consecutively from the next object id, in the order they are written
+	RenumberCompact Renumbering = iota
+	// Keep the object numbers of the source where they are free, so the output
+	// can be compared object by object with the source.  Objects whose numbers
+	// are taken, or below the next object id, are numbered like RenumberCompact.
+	RenumberPreserve
+)
+
+// Get the output id of a new object, preferably sourceId (0 for objects that
+// are not copied from the source)
+func (pdfWriter *PdfWriter) allocObjId(sourceId int) int {
+	if pdfWriter.hasOutputBackend() || pdfWriter.id_allocator != nil {
+		var id int
+		if pdfWriter.hasOutputBackend() {
+			id = pdfWriter.backend.AllocateObjectID()
+		} else {
+			id = pdfWriter.id_allocator()
+		}
+		if id > pdfWriter.n {
+			pdfWriter.n = id
+		}
+		return id
+	}
+
+	if pdfWriter.renumbering != RenumberPreserve {
+		pdfWriter.n++
+		return pdfWriter.n
+	}
+
+	if sourceId > pdfWriter.first_id && !pdfWriter.used_ids[sourceId] {
+		pdfWriter.used_ids[sourceId] = true
+		return sourceId
+	}
+
+	for {
+		pdfWriter.n++
+		if !pdfWriter.used_ids[pdfWriter.n] {
+			break
+		}
+	}
+	pdfWriter.used_ids[pdfWriter.n] = true
+	return pdfWriter.n
+}
+
+// Get the highest object id used so far
+func (pdfWriter *PdfWriter) maxObjId() int {
+	max := pdfWriter.n
+	for id := range pdfWriter.used_ids {
+		if id > max {
+			max = id
+		}
+	}
+	return max
+}
+
+// Get the id of every new output object from allocator instead of counting
+// from the next object id, see Importer.SetObjectIdAllocator
+func (pdfWriter *PdfWriter) SetObjectIdAllocator(allocator func() int) {
+	pdfWriter.id_allocator = allocator
+}
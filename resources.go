@@ -0,0 +1,95 @@
+package gofpdi
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Resource categories that are pruned to the names used by the content stream.
+// Other entries such as /ProcSet are always kept.
+var prunableResources = []string{"/Font", "/XObject", "/ExtGState", "/ColorSpace", "/Pattern", "/Shading", "/Properties"}
+
+// Find the resource names referenced by a content stream, by resource category
+func usedResourceNames(content []byte) (map[string]map[string]bool, error) {
+	ops, err := ParseContentStream(content)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to parse content stream")
+	}
+
+	used := make(map[string]map[string]bool, 0)
+	for _, category := range prunableResources {
+		used[category] = make(map[string]bool, 0)
+	}
+
+	use := func(category string, value *PdfValue) {
+		if value != nil && value.Type == PDF_TYPE_TOKEN {
+			used[category][value.Token] = true
+		}
+	}
+
+	for _, op := range ops {
+		if len(op.Operands) == 0 {
+			continue
+		}
+		last := op.Operands[len(op.Operands)-1]
+
+		switch op.Operator {
+		case "Tf":
+			use("/Font", op.Operands[0])
+		case "Do":
+			use("/XObject", last)
+		case "gs":
+			use("/ExtGState", last)
+		case "cs", "CS":
+			use("/ColorSpace", last)
+		case "scn", "SCN":
+			use("/Pattern", last)
+		case "sh":
+			use("/Shading", last)
+		case "BDC", "DP":
+			use("/Properties", last)
+		case "BI":
+			use("/ColorSpace", last.Dictionary["/CS"])
+			use("/ColorSpace", last.Dictionary["/ColorSpace"])
+		}
+	}
+
+	return used, nil
+}
+
+// Return a copy of a resources dictionary containing only the names in used
+func (pdfReader *PdfReader) pruneResources(resources *PdfValue, used map[string]map[string]bool) (*PdfValue, error) {
+	if resources == nil || resources.Type != PDF_TYPE_DICTIONARY {
+		return resources, nil
+	}
+
+	result := &PdfValue{Type: PDF_TYPE_DICTIONARY, Dictionary: make(map[string]*PdfValue, 0)}
+	for key, value := range resources.Dictionary {
+		names, ok := used[key]
+		if !ok {
+			result.Dictionary[key] = value
+			continue
+		}
+
+		category, err := pdfReader.resolveDirect(value)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to resolve resource category: "+key)
+		}
+		if category == nil || category.Type != PDF_TYPE_DICTIONARY {
+			result.Dictionary[key] = value
+			continue
+		}
+
+		pruned := &PdfValue{Type: PDF_TYPE_DICTIONARY, Dictionary: make(map[string]*PdfValue, 0)}
+		for name, resource := range category.Dictionary {
+			if names[name] {
+				pruned.Dictionary[name] = resource
+			}
+		}
+
+		if len(pruned.Dictionary) > 0 {
+			result.Dictionary[key] = pruned
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,674 @@
+package gofpdi
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rc4"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Padding of passwords of the standard security handler, revisions 2 to 4
+var passwordPadding = []byte{
+	0x28, 0xbf, 0x4e, 0x5e, 0x4e, 0x75, 0x8a, 0x41, 0x64, 0x00, 0x4e, 0x56, 0xff, 0xfa, 0x01, 0x08,
+	0x2e, 0x2e, 0x00, 0xb6, 0xd0, 0x68, 0x3e, 0x80, 0x2f, 0x0c, 0xa9, 0xfe, 0x64, 0x53, 0x69, 0x7a,
+}
+
+// How strings or streams are encrypted: RC4 (/V2), AES-128 (/AESV2) or
+// AES-256 (/AESV3).  Data of the /Identity crypt filter, and of /None, is not
+// encrypted, which a nil *cryptFilter stands for.
+type cryptFilter struct {
+	method string
+}
+
+// The standard security handler of an encrypted document.  Strings and streams
+// of the objects read are decrypted, so the objects written for the imported
+// pages are not encrypted.
+type securityHandler struct {
+	v int
+	r int
+	// Length of the file key in bytes
+	length int
+	// The file key, nil until a password was accepted
+	key []byte
+	// Crypt filters of streams, strings and embedded files, and by name for
+	// streams that select one with a /Crypt filter
+	stmF    *cryptFilter
+	strF    *cryptFilter
+	eff     *cryptFilter
+	filters map[string]*cryptFilter
+	// XMP metadata streams are encrypted too
+	encryptMetadata bool
+	// Object number of the encryption dictionary, which is not encrypted
+	encryptId int
+	o         []byte
+	u         []byte
+	oe        []byte
+	ue        []byte
+	p         int32
+	id        []byte
+}
+
+// Read the encryption dictionary of the trailer, if the document has one, and
+// try the empty user password, which most encrypted documents have
+func (pdfReader *PdfReader) readEncryption() error {
+	pdfReader.security = nil
+	ref := pdfReader.trailer.Dictionary["/Encrypt"]
+	if ref == nil || ref.Type == PDF_TYPE_NULL {
+		return nil
+	}
+	encrypt, err := pdfReader.resolveDirect(ref)
+	if err != nil {
+		return errors.Wrap(err, "Failed to resolve encryption dictionary")
+	}
+	if encrypt == nil || encrypt.Type != PDF_TYPE_DICTIONARY {
+		return errors.New("Encryption dictionary is not a dictionary")
+	}
+
+	dict := encrypt.Dictionary
+	if filter := dict["/Filter"]; filter == nil || filter.Token != "/Standard" {
+		name := ""
+		if filter != nil {
+			name = filter.Token
+		}
+		return errors.New("Unsupported security handler " + name)
+	}
+
+	value := func(key string) *PdfValue {
+		v, _ := pdfReader.resolveDirect(dict[key])
+		if v == nil {
+			return &PdfValue{Type: PDF_TYPE_NULL}
+		}
+		return v
+	}
+	str := func(key string) []byte {
+		v := value(key)
+		if v.Type != PDF_TYPE_STRING && v.Type != PDF_TYPE_HEX {
+			return nil
+		}
+		return stringBytes(v)
+	}
+
+	handler := &securityHandler{
+		v:               value("/V").Int,
+		r:               value("/R").Int,
+		length:          5,
+		filters:         make(map[string]*cryptFilter, 0),
+		encryptMetadata: true,
+		encryptId:       ref.Id,
+		o:               str("/O"),
+		u:               str("/U"),
+		oe:              str("/OE"),
+		ue:              str("/UE"),
+		p:               int32(value("/P").Int),
+	}
+	if metadata := value("/EncryptMetadata"); metadata.Type == PDF_TYPE_BOOLEAN {
+		handler.encryptMetadata = metadata.Bool
+	}
+	if ids, _ := pdfReader.resolveDirect(pdfReader.trailer.Dictionary["/ID"]); ids != nil && len(ids.Array) > 0 {
+		if id, _ := pdfReader.resolveDirect(ids.Array[0]); id != nil && (id.Type == PDF_TYPE_STRING || id.Type == PDF_TYPE_HEX) {
+			handler.id = stringBytes(id)
+		}
+	}
+
+	switch handler.v {
+	case 1, 2:
+		if handler.v == 2 {
+			if length := value("/Length").Int; length >= 40 && length <= 128 && length%8 == 0 {
+				handler.length = length / 8
+			}
+		}
+		rc4 := &cryptFilter{method: "/V2"}
+		handler.stmF, handler.strF, handler.eff = rc4, rc4, rc4
+	case 4, 5:
+		handler.length = 16
+		if handler.v == 5 {
+			handler.length = 32
+		}
+		if cf := value("/CF"); cf.Type == PDF_TYPE_DICTIONARY {
+			for name := range cf.Dictionary {
+				filter, _ := pdfReader.resolveDirect(cf.Dictionary[name])
+				if filter == nil || filter.Type != PDF_TYPE_DICTIONARY {
+					continue
+				}
+				method := "/None"
+				if cfm, _ := pdfReader.resolveDirect(filter.Dictionary["/CFM"]); cfm != nil && cfm.Type == PDF_TYPE_TOKEN {
+					method = cfm.Token
+				}
+				switch method {
+				case "/None":
+					handler.filters[name] = nil
+				case "/V2", "/AESV2", "/AESV3":
+					handler.filters[name] = &cryptFilter{method: method}
+				default:
+					return errors.New("Unsupported crypt filter method " + method)
+				}
+			}
+		}
+		filter := func(key string) (*cryptFilter, error) {
+			name := value(key).Token
+			if name == "" || name == "/Identity" {
+				return nil, nil
+			}
+			filter, ok := handler.filters[name]
+			if !ok {
+				return nil, errors.New("Unknown crypt filter " + name)
+			}
+			return filter, nil
+		}
+		if handler.stmF, err = filter("/StmF"); err != nil {
+			return err
+		}
+		if handler.strF, err = filter("/StrF"); err != nil {
+			return err
+		}
+		handler.eff = handler.stmF
+		if dict["/EFF"] != nil {
+			if handler.eff, err = filter("/EFF"); err != nil {
+				return err
+			}
+		}
+	default:
+		return errors.New(fmt.Sprintf("Unsupported encryption version %d", handler.v))
+	}
+
+	// 256 bit keys are computed by revisions 5 and 6 only
+	if (handler.v == 5) != (handler.r >= 5) || handler.r < 2 || handler.r > 6 {
+		return errors.New(fmt.Sprintf("Unsupported revision %d of encryption version %d", handler.r, handler.v))
+	}
+
+	pdfReader.security = handler
+
+	// Documents without a user password open with the empty one
+	_ = handler.authenticate("")
+	return nil
+}
+
+// Returned when a password is neither the user nor the owner password of an
+// encrypted source
+type PasswordError struct {
+	// Name of the source, empty for a PdfReader
+	Source string
+}
+
+func (err *PasswordError) Error() string {
+	if err.Source == "" {
+		return "Wrong password"
+	}
+	return "Wrong password for " + err.Source
+}
+
+// Decrypt the document with a password, the user or the owner password,
+// e.g. for documents that cannot be opened without one.  Documents that are
+// not encrypted, or were opened with the empty user password, need none.
+// Returns a *PasswordError for a wrong password.
+func (pdfReader *PdfReader) SetPassword(password string) error {
+	security := pdfReader.security
+	if security == nil || security.key != nil {
+		return nil
+	}
+	if !security.authenticate(password) {
+		return &PasswordError{}
+	}
+
+	// Objects read before were not decrypted
+	pdfReader.parseMu.Lock()
+	pdfReader.objects.clear()
+	pdfReader.objStreams.clear()
+	pdfReader.parseMu.Unlock()
+	err := pdfReader.readRoot()
+	if err != nil {
+		return errors.Wrap(err, "Failed to read root")
+	}
+	return pdfReader.readPages()
+}
+
+// Check whether the document is encrypted and cannot be decrypted without
+// a password, see SetPassword
+func (pdfReader *PdfReader) NeedsPassword() bool {
+	return pdfReader.security != nil && pdfReader.security.key == nil
+}
+
+// Get the file key for a password if it is the user or the owner password
+func (security *securityHandler) authenticate(password string) bool {
+	if security.r >= 5 {
+		return security.authenticateAES256(password)
+	}
+
+	// The owner password decrypts the user password from /O
+	key := security.fileKey(paddedPassword([]byte(password)))
+	if security.checkUserKey(key) {
+		security.key = key
+		return true
+	}
+	user := security.ownerPasswordUser([]byte(password))
+	key = security.fileKey(user)
+	if security.checkUserKey(key) {
+		security.key = key
+		return true
+	}
+	return false
+}
+
+// Pad or truncate a password to 32 bytes
+func paddedPassword(password []byte) []byte {
+	padded := make([]byte, 0, 32)
+	if len(password) > 32 {
+		password = password[:32]
+	}
+	padded = append(padded, password...)
+	return append(padded, passwordPadding[:32-len(password)]...)
+}
+
+// Compute the file key of a padded user password, revisions 2 to 4
+func (security *securityHandler) fileKey(password []byte) []byte {
+	hash := md5.New()
+	hash.Write(password)
+	hash.Write(security.o)
+	var p [4]byte
+	binary.LittleEndian.PutUint32(p[:], uint32(security.p))
+	hash.Write(p[:])
+	hash.Write(security.id)
+	if security.r >= 4 && !security.encryptMetadata {
+		hash.Write([]byte{0xff, 0xff, 0xff, 0xff})
+	}
+	key := hash.Sum(nil)
+
+	n := security.length
+	if security.r == 2 {
+		n = 5
+	}
+	if security.r >= 3 {
+		for i := 0; i < 50; i++ {
+			sum := md5.Sum(key[:n])
+			key = sum[:]
+		}
+	}
+	return key[:n]
+}
+
+// Check a file key against /U, revisions 2 to 4
+func (security *securityHandler) checkUserKey(key []byte) bool {
+	if security.r == 2 {
+		return len(security.u) >= 32 && bytes.Equal(rc4Crypt(key, passwordPadding), security.u[:32])
+	}
+
+	hash := md5.New()
+	hash.Write(passwordPadding)
+	hash.Write(security.id)
+	data := rc4Crypt(key, hash.Sum(nil))
+	for i := 1; i <= 19; i++ {
+		data = rc4Crypt(xorKey(key, byte(i)), data)
+	}
+	return len(security.u) >= 16 && bytes.Equal(data, security.u[:16])
+}
+
+// Get the padded user password from /O with the owner password, revisions 2 to 4
+func (security *securityHandler) ownerPasswordUser(password []byte) []byte {
+	sum := md5.Sum(paddedPassword(password))
+	key := sum[:]
+	n := security.length
+	if security.r == 2 {
+		n = 5
+	}
+	if security.r >= 3 {
+		for i := 0; i < 50; i++ {
+			sum = md5.Sum(key)
+			key = sum[:]
+		}
+	}
+	key = key[:n]
+
+	if security.r == 2 {
+		return rc4Crypt(key, security.o)
+	}
+	data := security.o
+	for i := 19; i >= 0; i-- {
+		data = rc4Crypt(xorKey(key, byte(i)), data)
+	}
+	return data
+}
+
+// Get the file key for a password if it is the user or the owner password,
+// revisions 5 and 6 (AES-256)
+func (security *securityHandler) authenticateAES256(password string) bool {
+	pw := []byte(password)
+	if len(pw) > 127 {
+		pw = pw[:127]
+	}
+	if len(security.u) < 48 || len(security.o) < 48 {
+		return false
+	}
+	u := security.u[:48]
+	o := security.o[:48]
+
+	var intermediate, encrypted []byte
+	switch {
+	case bytes.Equal(security.hashAES256(pw, o[32:40], u), o[:32]):
+		intermediate = security.hashAES256(pw, o[40:48], u)
+		encrypted = security.oe
+	case bytes.Equal(security.hashAES256(pw, u[32:40], nil), u[:32]):
+		intermediate = security.hashAES256(pw, u[40:48], nil)
+		encrypted = security.ue
+	default:
+		return false
+	}
+	if len(encrypted) < 32 {
+		return false
+	}
+
+	block, err := aes.NewCipher(intermediate)
+	if err != nil {
+		return false
+	}
+	key := make([]byte, 32)
+	cipher.NewCBCDecrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(key, encrypted[:32])
+	security.key = key
+	return true
+}
+
+// Hash a password with a salt and the user key, revision 5 (SHA-256) and
+// revision 6 (algorithm 2.B)
+func (security *securityHandler) hashAES256(password []byte, salt []byte, user []byte) []byte {
+	hash := sha256.New()
+	hash.Write(password)
+	hash.Write(salt)
+	hash.Write(user)
+	k := hash.Sum(nil)
+	if security.r == 5 {
+		return k
+	}
+
+	for i := 0; ; i++ {
+		round := make([]byte, 0, len(password)+len(k)+len(user))
+		round = append(append(append(round, password...), k...), user...)
+		k1 := bytes.Repeat(round, 64)
+
+		block, err := aes.NewCipher(k[:16])
+		if err != nil {
+			return nil
+		}
+		e := make([]byte, len(k1))
+		cipher.NewCBCEncrypter(block, k[16:32]).CryptBlocks(e, k1)
+
+		sum := 0
+		for _, b := range e[:16] {
+			sum += int(b)
+		}
+		switch sum % 3 {
+		case 0:
+			s := sha256.Sum256(e)
+			k = s[:]
+		case 1:
+			s := sha512.Sum384(e)
+			k = s[:]
+		case 2:
+			s := sha512.Sum512(e)
+			k = s[:]
+		}
+
+		if i >= 63 && int(e[len(e)-1]) <= i+1-32 {
+			break
+		}
+	}
+	return k[:32]
+}
+
+// Get the key of an object for a crypt filter
+func (security *securityHandler) objectKey(filter *cryptFilter, id int, gen int) []byte {
+	if filter.method == "/AESV3" {
+		return security.key
+	}
+
+	hash := md5.New()
+	hash.Write(security.key)
+	hash.Write([]byte{byte(id), byte(id >> 8), byte(id >> 16), byte(gen), byte(gen >> 8)})
+	if filter.method == "/AESV2" {
+		hash.Write([]byte("sAlT"))
+	}
+	key := hash.Sum(nil)
+
+	n := len(security.key) + 5
+	if n > 16 {
+		n = 16
+	}
+	return key[:n]
+}
+
+// Decrypt data of object id gen with a crypt filter
+func (security *securityHandler) decrypt(filter *cryptFilter, id int, gen int, data []byte) ([]byte, error) {
+	if filter == nil {
+		return data, nil
+	}
+	key := security.objectKey(filter, id, gen)
+	if filter.method == "/V2" {
+		return rc4Crypt(key, data), nil
+	}
+
+	// AES data starts with the initialization vector and is padded to whole blocks
+	if len(data) == 0 {
+		return data, nil
+	}
+	if len(data) < 2*aes.BlockSize || len(data)%aes.BlockSize != 0 {
+		return nil, errors.New(fmt.Sprintf("AES encrypted data of object %d %d R has a length of %d", id, gen, len(data)))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to create cipher")
+	}
+	result := make([]byte, len(data)-aes.BlockSize)
+	cipher.NewCBCDecrypter(block, data[:aes.BlockSize]).CryptBlocks(result, data[aes.BlockSize:])
+
+	if pad := int(result[len(result)-1]); pad >= 1 && pad <= aes.BlockSize {
+		result = result[:len(result)-pad]
+	}
+	return result, nil
+}
+
+// Decrypt the strings and the stream data of an object read from the file.
+// The streams of cross-reference sections and the encryption dictionary are
+// not encrypted.  Streams select their crypt filter with a /Crypt filter,
+// which is removed, as the data is no longer encrypted.
+func (security *securityHandler) decryptObject(obj *PdfValue) error {
+	if obj.Id == security.encryptId {
+		return nil
+	}
+
+	err := security.decryptStrings(obj.Value, obj.Id, obj.Gen)
+	if err != nil {
+		return err
+	}
+
+	if obj.Type != PDF_TYPE_STREAM || obj.Stream == nil || obj.Value == nil || obj.Value.Type != PDF_TYPE_DICTIONARY {
+		return nil
+	}
+	dict := obj.Value.Dictionary
+	filter := security.stmF
+	if t := dict["/Type"]; t != nil {
+		switch t.Token {
+		case "/XRef":
+			return nil
+		case "/Metadata":
+			if !security.encryptMetadata {
+				filter = nil
+			}
+		case "/EmbeddedFile":
+			filter = security.eff
+		}
+	}
+	if name, ok := removeCryptFilter(obj.Value); ok {
+		if name == "/Identity" {
+			filter = nil
+		} else if filter, ok = security.filters[name]; !ok {
+			return errors.New("Unknown crypt filter " + name)
+		}
+	}
+
+	obj.Stream.Bytes, err = security.decrypt(filter, obj.Id, obj.Gen, obj.Stream.Bytes)
+	if err != nil {
+		return errors.Wrap(err, "Failed to decrypt stream")
+	}
+	return nil
+}
+
+// Decrypt the strings of a value, except for the /Contents of signatures,
+// which are not encrypted
+func (security *securityHandler) decryptStrings(value *PdfValue, id int, gen int) error {
+	if value == nil {
+		return nil
+	}
+
+	switch value.Type {
+	case PDF_TYPE_STRING, PDF_TYPE_HEX:
+		data, err := security.decrypt(security.strF, id, gen, stringBytes(value))
+		if err != nil {
+			return errors.Wrap(err, "Failed to decrypt string")
+		}
+		if value.Type == PDF_TYPE_HEX {
+			value.String = hex.EncodeToString(data)
+		} else {
+			value.String = escapeBinaryString(data)
+		}
+	case PDF_TYPE_ARRAY:
+		for _, v := range value.Array {
+			if err := security.decryptStrings(v, id, gen); err != nil {
+				return err
+			}
+		}
+	case PDF_TYPE_DICTIONARY:
+		signature := false
+		if t := value.Dictionary["/Type"]; t != nil && (t.Token == "/Sig" || t.Token == "/DocTimeStamp") {
+			signature = true
+		}
+		for key, v := range value.Dictionary {
+			if signature && key == "/Contents" {
+				continue
+			}
+			if err := security.decryptStrings(v, id, gen); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Remove a /Crypt filter from the filters of a stream dictionary and get
+// the name of the crypt filter it selects, /Identity if it names none
+func removeCryptFilter(dict *PdfValue) (string, bool) {
+	filter := dict.Dictionary["/Filter"]
+	parms := dict.Dictionary["/DecodeParms"]
+
+	var cryptParms *PdfValue
+	switch {
+	case filter == nil:
+		return "", false
+	case filter.Type == PDF_TYPE_TOKEN && filter.Token == "/Crypt":
+		cryptParms = parms
+		delete(dict.Dictionary, "/Filter")
+		delete(dict.Dictionary, "/DecodeParms")
+	case filter.Type == PDF_TYPE_ARRAY && len(filter.Array) > 0 && filter.Array[0].Token == "/Crypt":
+		filter.Array = filter.Array[1:]
+		if len(filter.Array) == 0 {
+			delete(dict.Dictionary, "/Filter")
+		}
+		if parms != nil && parms.Type == PDF_TYPE_ARRAY && len(parms.Array) > 0 {
+			cryptParms = parms.Array[0]
+			parms.Array = parms.Array[1:]
+			if len(parms.Array) == 0 {
+				delete(dict.Dictionary, "/DecodeParms")
+			}
+		}
+	default:
+		return "", false
+	}
+
+	if cryptParms != nil && cryptParms.Type == PDF_TYPE_DICTIONARY {
+		if name := cryptParms.Dictionary["/Name"]; name != nil && name.Type == PDF_TYPE_TOKEN {
+			return name.Token, true
+		}
+	}
+	return "/Identity", true
+}
+
+// Escape bytes for use in a literal string, keeping carriage returns, which
+// readers would change into line feeds
+func escapeBinaryString(data []byte) string {
+	var b strings.Builder
+	for _, c := range data {
+		switch c {
+		case '(', ')', '\\':
+			b.WriteByte('\\')
+		case '\r':
+			b.WriteString("\\r")
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// Encrypt or decrypt data with RC4
+func rc4Crypt(key []byte, data []byte) []byte {
+	c, err := rc4.NewCipher(key)
+	if err != nil {
+		return nil
+	}
+	result := make([]byte, len(data))
+	c.XORKeyStream(result, data)
+	return result
+}
+
+// XOR every byte of a key with b
+func xorKey(key []byte, b byte) []byte {
+	result := make([]byte, len(key))
+	for i := range key {
+		result[i] = key[i] ^ b
+	}
+	return result
+}
+
+// Decrypt the sources that need a password with password, the user or the
+// owner password of the sources: the sources that are open at once, and the
+// sources opened after the call as they are set.  Returns a *PasswordError
+// naming the first open source it is not the password of; the source setters
+// return one for sources opened later.  Sources that are not encrypted, or
+// open with the empty user password, are not affected.
+func (importer *Importer) SetPassword(password string) error {
+	importer.password = password
+
+	sources := make([]string, 0, len(importer.readers))
+	for source, reader := range importer.readers {
+		if reader.NeedsPassword() {
+			sources = append(sources, source)
+		}
+	}
+	sort.Strings(sources)
+
+	for _, source := range sources {
+		if err := importer.decryptReader(source, importer.readers[source]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Decrypt a reader of source with the password of the importer
+func (importer *Importer) decryptReader(source string, reader *PdfReader) error {
+	err := reader.SetPassword(importer.password)
+	if _, ok := err.(*PasswordError); ok {
+		return &PasswordError{Source: source}
+	}
+	if err != nil {
+		return errors.Wrap(err, "Failed to decrypt "+source)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package gofpdi
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// Encrypted fixtures in testdata/encrypted, with the user password "user"
+// (empty for rc4-128-r3) and the owner password "owner".  Each has one page
+// with a content stream that draws "Decrypted page of <name>", and /Info with
+// the title "Title of <name>".
+var encryptedFixtures = []struct {
+	name string
+	user string
+	// The page has a second content stream with the /Identity crypt filter
+	identity bool
+}{
+	{"rc4-40-r2", "user", false},
+	{"rc4-128-r3", "", false},
+	// Strings use the /Identity crypt filter
+	{"rc4-128-r4", "user", true},
+	// The metadata is not encrypted
+	{"aes-128-r4", "user", true},
+	{"aes-256-r5", "user", true},
+	{"aes-256-r6", "user", true},
+}
+
+// The XMP metadata stream of the encrypted fixtures
+const fixtureMetadata = `<?xpacket begin="" id="W5M0MpCehiHzreSzNTczkc9d"?><x:xmpmeta xmlns:x="adobe:ns:meta/"/><?xpacket end="w"?>`
+
+// Pages of encrypted sources are imported with the user and the owner
+// password, and their content, strings and metadata are decrypted
+func TestImportEncrypted(t *testing.T) {
+	for _, fixture := range encryptedFixtures {
+		for _, password := range []string{fixture.user, "owner"} {
+			fixture := fixture
+			password := password
+			t.Run(fixture.name+"/"+password, func(t *testing.T) {
+				doc := NewDocument()
+				importer := doc.Importer()
+				if err := importer.SetPassword(password); err != nil {
+					t.Fatal(err)
+				}
+				err := importer.SetSourceFile(filepath.Join("testdata", "encrypted", fixture.name+".pdf"))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				info, err := importer.GetInfo()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := "Title of " + fixture.name; info.Title != want {
+					t.Errorf("title is %q, want %q", info.Title, want)
+				}
+				reader := importer.GetReader()
+				metadata := resolvePath(t, reader, reader.catalog, "/Metadata")
+				if got := string(metadata.Stream.Bytes); got != fixtureMetadata {
+					t.Errorf("metadata is %q, want %q", got, fixtureMetadata)
+				}
+
+				tplid, err := importer.ImportPage(1, MediaBox)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := doc.AddTemplatePage(tplid); err != nil {
+					t.Fatal(err)
+				}
+				var buf bytes.Buffer
+				if _, err := doc.WriteTo(&buf); err != nil {
+					t.Fatal(err)
+				}
+
+				output, form := outputForm(t, buf.Bytes(), 1)
+				content := string(decodedStream(t, output, form))
+				if want := "(Decrypted page of " + fixture.name + ") Tj"; !strings.Contains(content, want) {
+					t.Errorf("content %q does not contain %q", content, want)
+				}
+				if want := "72 72 144 144 re f"; fixture.identity && !strings.Contains(content, want) {
+					t.Errorf("content %q does not contain the stream of the /Identity crypt filter %q", content, want)
+				}
+				if font := resolvePath(t, output, form, "/Resources", "/Font", "/F1", "/BaseFont"); font.Token != "/Helvetica" {
+					t.Errorf("font is %s, want /Helvetica", font.Token)
+				}
+			})
+		}
+	}
+}
+
+// A wrong password fails to open an encrypted source, and a source that needs
+// a password cannot be imported without one
+func TestWrongPassword(t *testing.T) {
+	for _, fixture := range encryptedFixtures {
+		path := filepath.Join("testdata", "encrypted", fixture.name+".pdf")
+
+		importer := NewImporter()
+		if err := importer.SetPassword("wrong"); err != nil {
+			t.Fatal(err)
+		}
+		err := importer.SetSourceFile(path)
+		if fixture.user == "" && err != nil {
+			t.Errorf("%s: %s", fixture.name, err)
+		} else if passwordErr, ok := errors.Cause(err).(*PasswordError); fixture.user != "" && (!ok || passwordErr.Source != path) {
+			t.Errorf("%s: got error %v, want a *PasswordError for %s", fixture.name, err, path)
+		}
+
+		reader, err := NewPdfReader(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reader.NeedsPassword() != (fixture.user != "") {
+			t.Errorf("%s: NeedsPassword is %v", fixture.name, reader.NeedsPassword())
+		}
+		if reader.NeedsPassword() {
+			if _, ok := reader.SetPassword("wrong").(*PasswordError); !ok {
+				t.Errorf("%s: wrong password accepted", fixture.name)
+			}
+			if err := reader.SetPassword(fixture.user); err != nil {
+				t.Errorf("%s: %s", fixture.name, err)
+			}
+		}
+		reader.Close()
+	}
+}
+
+// A password set after a source was opened is checked against the source at
+// once and decrypts it
+func TestPasswordOfOpenSource(t *testing.T) {
+	path := filepath.Join("testdata", "encrypted", "aes-256-r6.pdf")
+	importer := NewImporter()
+	if err := importer.SetSourceFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := importer.ImportPage(1, MediaBox); err == nil {
+		t.Fatal("imported a page without a password")
+	}
+
+	err := importer.SetPassword("wrong")
+	if passwordErr, ok := err.(*PasswordError); !ok || passwordErr.Source != path {
+		t.Fatalf("got error %v, want a *PasswordError for %s", err, path)
+	}
+	if err := importer.SetPassword("owner"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := importer.ImportPage(1, MediaBox); err != nil {
+		t.Fatal(err)
+	}
+
+	// Sources opened later are decrypted with the password too
+	if err := importer.SetSourceFile(filepath.Join("testdata", "encrypted", "aes-128-r4.pdf")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := importer.ImportPage(1, MediaBox); err != nil {
+		t.Fatal(err)
+	}
+}
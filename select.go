@@ -0,0 +1,74 @@
+package gofpdi
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// A page of the current source, passed to the predicate of ImportPagesMatching
+type PageInfo struct {
+	PageNo int
+	// Display size in user units: the crop box, rotated and scaled by /UserUnit
+	Width  float64
+	Height float64
+	// Rotation in degrees clockwise (0, 90, 180 or 270)
+	Rotation int
+	// Label of the page, see GetPageLabels
+	Label  string
+	reader *PdfReader
+}
+
+// Determine if the page is displayed wider than high
+func (info PageInfo) Landscape() bool {
+	return info.Width > info.Height
+}
+
+// Determine if the page is blank, see PdfReader.IsPageBlank.  Pages that
+// cannot be analyzed are not blank.
+func (info PageInfo) IsBlank(tolerance float64) bool {
+	blank, err := info.reader.IsPageBlank(info.PageNo, tolerance)
+	return err == nil && blank
+}
+
+// Import the pages of the current source for which match returns true, in
+// page order, and get their template ids, e.g. all landscape pages with
+// func(p PageInfo) bool { return p.Landscape() }, or every page that is not
+// blank with func(p PageInfo) bool { return !p.IsBlank(0.001) }.  Pages are
+// only analyzed for IsBlank when the predicate asks.
+func (importer *Importer) ImportPagesMatching(match func(PageInfo) bool, box BoxName) ([]int, error) {
+	reader := importer.GetReader()
+
+	labels, err := reader.getPageLabels()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get page labels")
+	}
+
+	res := make([]int, 0)
+	for pageno := 1; pageno < len(labels); pageno++ {
+		geometry, err := reader.getPageGeometry(pageno)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("Failed to get geometry of page %d", pageno))
+		}
+
+		info := PageInfo{
+			PageNo:   pageno,
+			Width:    geometry.WidthPt / importer.options.k,
+			Height:   geometry.HeightPt / importer.options.k,
+			Rotation: geometry.Rotation,
+			Label:    labels[pageno],
+			reader:   reader,
+		}
+		if !match(info) {
+			continue
+		}
+
+		tplid, err := importer.ImportPage(pageno, box)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("Failed to import page %d", pageno))
+		}
+		res = append(res, tplid)
+	}
+
+	return res, nil
+}
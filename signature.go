@@ -0,0 +1,169 @@
+package gofpdi
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Bytes reserved for the signature if SignatureField.ContentsSize is 0
+const defaultSignatureSize = 8192
+
+// A /ByteRange of the same length as the one that replaces it, so the offsets
+// of the file don't change when it is filled in
+const byteRangePlaceholder = "/ByteRange [0 9999999999 9999999999 9999999999]"
+
+// A signature field that a Document reserves for signing after it is written,
+// see AddSignatureField
+type SignatureField struct {
+	// Name of the field (Signature1 if empty)
+	Name string
+	// Page of the field, from 1
+	Page int
+	// Position and size of the field on the page, like UseTemplate.  A field
+	// without a size is invisible.
+	X float64
+	Y float64
+	W float64
+	H float64
+	// Bytes reserved for the encoded signature (8192 if 0)
+	ContentsSize int
+	// Format of the signature (/adbe.pkcs7.detached if empty, e.g. /ETSI.CAdES.detached for PAdES)
+	SubFilter string
+}
+
+// Where to put the signature in a written document, see Document.GetSignaturePlaceholder
+type SignaturePlaceholder struct {
+	// The signed parts of the file: the offset and length of the part before
+	// and of the part after the /Contents value
+	ByteRange [4]int64
+	// Offset of the hex digits of /Contents (after "<") and their number,
+	// twice the bytes reserved for the signature
+	ContentsOffset int64
+	ContentsLength int64
+}
+
+// Reserve a signature field, so the document can be signed by an external
+// signing service once it is written.  WriteTo writes a signature dictionary
+// with a complete /ByteRange and a /Contents of zeros; the signer signs the
+// bytes of the ByteRange and puts the signature into /Contents with
+// FillSignature.  A document has one signature field.
+func (doc *Document) AddSignatureField(field SignatureField) error {
+	if field.Page < 1 || field.Page > len(doc.pages) {
+		return errors.New(fmt.Sprintf("Page %d does not exist", field.Page))
+	}
+	if field.W < 0 || field.H < 0 || field.ContentsSize < 0 {
+		return errors.New("Invalid signature field")
+	}
+	if field.Name == "" {
+		field.Name = "Signature1"
+	}
+	if field.ContentsSize == 0 {
+		field.ContentsSize = defaultSignatureSize
+	}
+	if field.SubFilter == "" {
+		field.SubFilter = "/adbe.pkcs7.detached"
+	}
+	if !strings.HasPrefix(field.SubFilter, "/") {
+		field.SubFilter = "/" + field.SubFilter
+	}
+	field.SubFilter = escapeName(field.SubFilter)
+
+	doc.signature = &field
+	return nil
+}
+
+// Get the placeholder of the signature in the file last written, nil if
+// the document has no signature field
+func (doc *Document) GetSignaturePlaceholder() *SignaturePlaceholder {
+	return doc.signaturePlaceholder
+}
+
+// Put the objects of the signature field: the widget, which is added to the
+// annotations of its page, and the signature dictionary, which is the last
+// object of the file.  Returns the entries of the catalog and the next free id.
+func (doc *Document) putSignatureField(objects map[int][]byte, pageIds []int, scales []float64, annots map[int][]int, nextId int) (string, int) {
+	field := doc.signature
+	page := doc.pages[field.Page-1]
+	widgetId := nextId
+	sigId := nextId + 1
+
+	k := doc.importer.options.k
+	llx := field.X * k
+	lly := field.Y * k
+	if doc.importer.options.origin == OriginTopLeft {
+		lly = page.h - (field.Y+field.H)*k
+	}
+	urx := llx + field.W*k
+	ury := lly + field.H*k
+
+	// Pages larger than the largest page size are scaled down
+	scale := scales[field.Page-1]
+	llx, lly, urx, ury = llx/scale, lly/scale, urx/scale, ury/scale
+
+	var name bytes.Buffer
+	writeContentValue(&name, encodeTextString(field.Name))
+
+	objects[widgetId] = []byte(fmt.Sprintf("<< /Type /Annot /Subtype /Widget /FT /Sig /T %s /F 132 /Rect [%.5F %.5F %.5F %.5F] /P %d 0 R /V %d 0 R >>\nendobj\n",
+		name.String(), llx, lly, urx, ury, pageIds[field.Page-1], sigId))
+	objects[sigId] = []byte(fmt.Sprintf("<< /Type /Sig /Filter /Adobe.PPKLite /SubFilter %s %s /Contents <%s> >>\nendobj\n",
+		field.SubFilter, byteRangePlaceholder, bytes.Repeat([]byte("0"), 2*field.ContentsSize)))
+
+	annots[field.Page-1] = append(annots[field.Page-1], widgetId)
+
+	return fmt.Sprintf(" /AcroForm << /Fields [%d 0 R] /SigFlags 3 >>", widgetId), sigId + 1
+}
+
+// Fill in the /ByteRange of the signature dictionary of a written document,
+// which is its last object
+func fillByteRange(data []byte) (*SignaturePlaceholder, error) {
+	i := bytes.LastIndex(data, []byte(byteRangePlaceholder))
+	if i < 0 {
+		return nil, errors.New("Signature dictionary not found")
+	}
+	start := bytes.Index(data[i:], []byte("/Contents <"))
+	if start < 0 {
+		return nil, errors.New("Signature contents not found")
+	}
+	start += i + len("/Contents <")
+	end := bytes.IndexByte(data[start:], '>')
+	if end < 0 {
+		return nil, errors.New("Signature contents not found")
+	}
+	end += start
+
+	// The signed parts exclude the value of /Contents with its angle brackets
+	placeholder := &SignaturePlaceholder{
+		ByteRange:      [4]int64{0, int64(start - 1), int64(end + 1), int64(len(data) - end - 1)},
+		ContentsOffset: int64(start),
+		ContentsLength: int64(end - start),
+	}
+
+	byteRange := fmt.Sprintf("/ByteRange [0 %-10d %-10d %-10d]", placeholder.ByteRange[1], placeholder.ByteRange[2], placeholder.ByteRange[3])
+	if len(byteRange) != len(byteRangePlaceholder) {
+		return nil, errors.New("Document is too large to be signed")
+	}
+	copy(data[i:], byteRange)
+
+	return placeholder, nil
+}
+
+// Put a signature (e.g. a DER encoded PKCS#7 or CAdES signature) into the
+// /Contents of a document written with a signature field
+func FillSignature(data []byte, placeholder *SignaturePlaceholder, signature []byte) error {
+	if placeholder == nil {
+		return errors.New("Document has no signature field")
+	}
+	if int64(2*len(signature)) > placeholder.ContentsLength {
+		return errors.New(fmt.Sprintf("Signature of %d bytes does not fit into %d bytes", len(signature), placeholder.ContentsLength/2))
+	}
+	if placeholder.ContentsOffset+placeholder.ContentsLength > int64(len(data)) {
+		return errors.New("Placeholder is not part of the document")
+	}
+
+	hex.Encode(data[placeholder.ContentsOffset:], signature)
+	return nil
+}
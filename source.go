@@ -0,0 +1,93 @@
+package gofpdi
+
+import (
+	"bytes"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// The data of a source document: anything that can be read at any offset and
+// knows its size, such as *bytes.Reader, *io.SectionReader, a FileSource, or
+// the objects of cloud storage clients.  Sources are read concurrently by
+// ImportPagesParallel, so ReadAt must be safe for concurrent use.
+type Source interface {
+	io.ReaderAt
+	Size() int64
+}
+
+// A file as a Source
+type FileSource struct {
+	*os.File
+	size int64
+}
+
+// Open a file as a Source.  The file is closed with Close.
+func OpenFileSource(filename string) (*FileSource, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to open file")
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, errors.Wrap(err, "Failed to obtain file information")
+	}
+
+	return &FileSource{File: f, size: info.Size()}, nil
+}
+
+// Get the size of the file when it was opened
+func (source *FileSource) Size() int64 {
+	return source.size
+}
+
+// Create a reader for a source document.  name identifies the source in
+// warnings and object hashes, like the file name of NewPdfReader.
+func NewPdfReaderFromSource(name string, source Source) (*PdfReader, error) {
+	return NewPdfReaderWithLimits(name, source, Limits{})
+}
+
+// Create a reader for a source document that is read within limits, e.g.
+// DefaultLimits for documents uploaded by users.  A source larger than
+// MaxFileSize is rejected before it is read; the cross-reference table and
+// the page count are checked while they are read, and MaxStreamBytes and
+// Timeout apply to opening the source too.  Fails with a *LimitError if the
+// source exceeds them.
+func NewPdfReaderWithLimits(name string, source Source, limits Limits) (*PdfReader, error) {
+	size := source.Size()
+	if size < 0 {
+		return nil, errors.New("Source has no size")
+	}
+
+	parser, err := newLimitedReader(io.NewSectionReader(source, 0, size), name, size, limits)
+	if err != nil {
+		return nil, err
+	}
+	if err := parser.init(); err != nil {
+		return nil, errors.Wrap(err, "Failed to initialize parser")
+	}
+	if err := parser.read(); err != nil {
+		return nil, errors.Wrap(err, "Failed to read pdf")
+	}
+
+	return parser, nil
+}
+
+// Use a document in memory as the current source, see SetSource.  With
+// SetSource, this is the way to import pages where there is no file system,
+// e.g. when built for GOOS=js or GOOS=wasip1: file names are only opened by
+// SetSourceFile, file sources, ReaderPool and Document.WriteFile.
+func (importer *Importer) SetSourceBytes(name string, data []byte) error {
+	return importer.SetSource(name, bytes.NewReader(data))
+}
+
+// Use a Source as the current source, under a name that identifies it (like
+// the file name passed to SetSourceFile).  The source is read when it is
+// first used; later calls with the same name switch back to it.
+func (importer *Importer) SetSource(name string, source Source) error {
+	return importer.setSource(name, func() (*PdfReader, error) {
+		return NewPdfReaderWithLimits(name, source, importer.limits)
+	})
+}
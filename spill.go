@@ -0,0 +1,150 @@
+package gofpdi
+
+import (
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// Scratch stores written objects that are spilled from memory.  Objects are
+// appended with Write and read back with ReadAt.  An *os.File is a Scratch.
+// A Scratch that is also an io.Seeker may hold data already, objects are
+// appended after its end; any other Scratch must be empty.
+type Scratch interface {
+	io.Writer
+	io.ReaderAt
+}
+
+// The written objects of the writers of an importer that are kept in memory
+// and spilled to scratch
+type spillStore struct {
+	// Memory that written objects may use before they are spilled
+	threshold int64
+	memory    int64
+	scratch   Scratch
+	// Offset of the first spilled object in scratch, and the error of
+	// finding it
+	start    int64
+	startErr error
+	size     int64
+	// Temporary file created when no scratch was given
+	temp *os.File
+}
+
+// Where a spilled object is stored in the scratch
+type spilledObject struct {
+	offset int64
+	length int
+}
+
+// Keep data in memory, or write it to scratch and get its position if memory
+// is over the threshold.  Data that cannot be spilled stays in memory.
+func (store *spillStore) add(data []byte) (*spilledObject, error) {
+	if store == nil || store.threshold <= 0 || store.memory+int64(len(data)) <= store.threshold {
+		if store != nil {
+			store.memory += int64(len(data))
+		}
+		return nil, nil
+	}
+
+	spilled, err := store.write(data)
+	if err != nil {
+		store.memory += int64(len(data))
+	}
+	return spilled, err
+}
+
+// Append data to scratch and get its position
+func (store *spillStore) write(data []byte) (*spilledObject, error) {
+	if store.startErr != nil {
+		return nil, store.startErr
+	}
+	if store.scratch == nil {
+		f, err := os.CreateTemp("", "gofpdi-*.tmp")
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to create temporary file")
+		}
+		store.temp = f
+		store.scratch = f
+	}
+
+	// The bytes of a short write stay in scratch, later objects follow them
+	n, err := store.scratch.Write(data)
+	store.size += int64(n)
+	if err == nil && n < len(data) {
+		err = io.ErrShortWrite
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to write to scratch")
+	}
+
+	return &spilledObject{offset: store.start + store.size - int64(len(data)), length: len(data)}, nil
+}
+
+// Read a spilled object back
+func (store *spillStore) read(spilled *spilledObject) ([]byte, error) {
+	data := make([]byte, spilled.length)
+	_, err := store.scratch.ReadAt(data, spilled.offset)
+	if err != nil && !(err == io.EOF && spilled.length == 0) {
+		return nil, errors.Wrap(err, "Failed to read from scratch")
+	}
+	return data, nil
+}
+
+// Remove the temporary file, if one was created
+func (store *spillStore) close() error {
+	if store == nil || store.temp == nil {
+		return nil
+	}
+
+	name := store.temp.Name()
+	err := store.temp.Close()
+	os.Remove(name)
+	store.temp = nil
+	store.scratch = nil
+	store.size = 0
+
+	return err
+}
+
+// Spill written objects to scratch once the objects kept in memory by all
+// sources exceed threshold bytes, so large documents can be merged with
+// little memory.  A nil scratch uses a temporary file, which is removed by
+// Close.  A scratch that can seek is appended to from its end, any other
+// must be empty.  The scratch cannot be changed once objects were spilled.  A
+// threshold of 0 keeps all objects in memory.  Applies to objects written
+// after the call.  Spilled objects are read back one at a time by
+// PutFormXobjectsToAll and PutTemplatesTo; GetImportedObjects reads all of
+// them back into memory.  PutFormXobjectsTo writes objects into its backend
+// without keeping them, so they are not spilled.
+func (importer *Importer) SetSpill(threshold int64, scratch Scratch) {
+	importer.spill.threshold = threshold
+	if scratch != nil && importer.spill.size == 0 {
+		importer.spill.close()
+		importer.spill.scratch = scratch
+		importer.spill.start, importer.spill.startErr = scratchStart(scratch)
+	}
+}
+
+// Get the offset at which objects are appended to scratch, the end of the
+// data it holds if it can seek
+func scratchStart(scratch Scratch) (int64, error) {
+	seeker, ok := scratch.(io.Seeker)
+	if !ok {
+		return 0, nil
+	}
+	offset, err := seeker.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, errors.Wrap(err, "Failed to seek to the end of scratch")
+	}
+	return offset, nil
+}
+
+// Get the data of a written object, reading it back if it was spilled
+func (pdfWriter *PdfWriter) objectData(pdfObjId *PdfObjectId) ([]byte, error) {
+	if spilled, ok := pdfWriter.spilled_objs[pdfObjId]; ok {
+		return pdfWriter.spill.read(spilled)
+	}
+	return pdfWriter.written_objs[pdfObjId], nil
+}
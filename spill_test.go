@@ -0,0 +1,139 @@
+package gofpdi
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// Objects spilled to a scratch file that holds data already are appended
+// after the data and read back as written
+func TestSpillScratchWithData(t *testing.T) {
+	data := testDocument(3)
+	want := putTestObjects(t, importTestPages(t, data, nil))
+
+	scratch, err := os.CreateTemp(t.TempDir(), "scratch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer scratch.Close()
+	existing := []byte("data of the caller before the spilled objects\n")
+	if _, err := scratch.Write(existing); err != nil {
+		t.Fatal(err)
+	}
+	// The file position is not at the end
+	if _, err := scratch.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	spilled := importTestPages(t, data, func(importer *Importer) {
+		importer.SetSpill(1, scratch)
+	})
+	got := putTestObjects(t, spilled)
+	if len(spilled.GetWarnings()) > 0 {
+		t.Fatalf("warnings: %v", spilled.GetWarnings())
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d objects, want %d", len(got), len(want))
+	}
+	for id, data := range want {
+		if got[id] != data {
+			t.Errorf("object %d differs:\n%s\nwant\n%s", id, got[id], data)
+		}
+	}
+
+	prefix := make([]byte, len(existing))
+	if _, err := scratch.ReadAt(prefix, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(prefix, existing) {
+		t.Errorf("scratch starts with %q, want %q", prefix, existing)
+	}
+}
+
+// Get an importer that imported every page of data with sorted dictionary
+// keys, configured before the source is set
+func importTestPages(t *testing.T, data []byte, configure func(importer *Importer)) *Importer {
+	t.Helper()
+
+	importer := NewImporter()
+	importer.SetSortKeys(true)
+	if configure != nil {
+		configure(importer)
+	}
+	if err := importer.SetSourceReader("test", readTestPDF(t, data)); err != nil {
+		t.Fatal(err)
+	}
+	pages, err := importer.GetNumPages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for pageno := 1; pageno <= pages; pageno++ {
+		if _, err := importer.ImportPage(pageno, MediaBox); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return importer
+}
+
+// A Scratch whose write number fail writes half of the data and fails
+type failingScratch struct {
+	bytes.Buffer
+	writes int
+	fail   int
+}
+
+func (scratch *failingScratch) Write(p []byte) (int, error) {
+	scratch.writes++
+	if scratch.writes == scratch.fail {
+		n, _ := scratch.Buffer.Write(p[:len(p)/2])
+		return n, errors.New("disk full")
+	}
+	return scratch.Buffer.Write(p)
+}
+
+func (scratch *failingScratch) ReadAt(p []byte, off int64) (int, error) {
+	return bytes.NewReader(scratch.Bytes()).ReadAt(p, off)
+}
+
+// An object that fails to spill after part of it was written stays in memory
+// and is counted there, and the objects spilled after it are read back as
+// written
+func TestSpillShortWrite(t *testing.T) {
+	data := testDocument(3)
+	want := putTestObjects(t, importTestPages(t, data, nil))
+
+	scratch := &failingScratch{fail: 2}
+	spilled := importTestPages(t, data, func(importer *Importer) {
+		importer.SetSpill(1, scratch)
+	})
+	got := putTestObjects(t, spilled)
+	if len(spilled.GetWarnings()) != 1 || !strings.Contains(spilled.GetWarnings()[0], "could not be spilled") {
+		t.Fatalf("warnings: %v, want one for the object that could not be spilled", spilled.GetWarnings())
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d objects, want %d", len(got), len(want))
+	}
+	for id, data := range want {
+		if got[id] != data {
+			t.Errorf("object %d differs:\n%s\nwant\n%s", id, got[id], data)
+		}
+	}
+
+	// Only the object that could not be spilled is in memory
+	var memory int64
+	for _, writer := range spilled.writers {
+		for _, data := range writer.written_objs {
+			memory += int64(len(data))
+		}
+	}
+	if memory == 0 || spilled.spill.memory != memory {
+		t.Errorf("spill counts %d bytes in memory, want %d", spilled.spill.memory, memory)
+	}
+	if spilled.spill.size != int64(scratch.Len()) {
+		t.Errorf("spill counts %d bytes in scratch, want %d", spilled.spill.size, scratch.Len())
+	}
+}
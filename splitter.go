@@ -0,0 +1,390 @@
+package gofpdi
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Longest file name, without the extension, written by Splitter.WriteParts
+const maxPartNameLength = 100
+
+// Estimated bytes a split document has besides its pages (header, catalog,
+// page tree, cross-reference table and trailer), each page has besides the
+// objects it draws (page object, content stream and their cross-reference
+// entries), each object has besides its data, and each outline entry has
+// besides its title, see Splitter.PartsBySize
+const (
+	splitDocumentOverhead = 512
+	splitPageOverhead     = 640
+	splitObjectOverhead   = 32
+	splitOutlineOverhead  = 160
+)
+
+// A part of a document split by a Splitter: a range of its pages
+type SplitPart struct {
+	// Name of the part, e.g. the title of the bookmark it starts at, which
+	// WriteParts names the file after
+	Name string
+	// First and last page of the part, numbered from 1
+	FirstPage int
+	LastPage  int
+}
+
+// Splits a document into parts of consecutive pages, each written as a
+// document of its own with the pages as they are (see Document.AddTemplatePage)
+// and the entries of the outline that point into it
+type Splitter struct {
+	name   string
+	reader *PdfReader
+}
+
+// Create a splitter for a file
+func NewSplitter(filename string) (*Splitter, error) {
+	reader, err := NewPdfReader(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &Splitter{name: filename, reader: reader}, nil
+}
+
+// Create a splitter for a source with the given name, see Importer.SetSource
+func NewSplitterFromSource(name string, source Source) (*Splitter, error) {
+	reader, err := NewPdfReaderFromSource(name, source)
+	if err != nil {
+		return nil, err
+	}
+	return &Splitter{name: name, reader: reader}, nil
+}
+
+// Close the source of the splitter
+func (splitter *Splitter) Close() error {
+	return splitter.reader.Close()
+}
+
+// Get the parts that start at the top level entries of the outline, e.g. a
+// part per chapter, named after the titles of the entries.  Pages before the
+// first entry are a part without a name.  Entries that do not point to a
+// page, or point to the page of the entry before them, start no part.
+func (splitter *Splitter) PartsByOutline() ([]SplitPart, error) {
+	pages, err := splitter.reader.getNumPages()
+	if err != nil {
+		return nil, err
+	}
+	outlines, err := splitter.reader.getOutlines()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get outline")
+	}
+
+	starts := make([]*Outline, 0, len(outlines))
+	for _, outline := range outlines {
+		if outline.PageNo >= 1 && outline.PageNo <= pages {
+			starts = append(starts, outline)
+		}
+	}
+	sort.SliceStable(starts, func(i, j int) bool {
+		return starts[i].PageNo < starts[j].PageNo
+	})
+
+	parts := make([]SplitPart, 0, len(starts)+1)
+	if len(starts) == 0 || starts[0].PageNo > 1 {
+		parts = append(parts, SplitPart{FirstPage: 1})
+	}
+	for _, start := range starts {
+		if len(parts) > 0 && parts[len(parts)-1].FirstPage == start.PageNo {
+			continue
+		}
+		parts = append(parts, SplitPart{Name: start.Title, FirstPage: start.PageNo})
+	}
+	for i := range parts {
+		parts[i].LastPage = pages
+		if i+1 < len(parts) {
+			parts[i].LastPage = parts[i+1].FirstPage - 1
+		}
+	}
+
+	return parts, nil
+}
+
+// Get parts of consecutive pages whose documents are at most maxBytes
+// large, e.g. for systems that limit the size of uploaded files.  Sizes are
+// estimated from the objects the pages copy from the document, counting
+// objects pages share, such as fonts, once per part.  A page that is larger
+// than maxBytes itself is a part of its own, which is larger.  The parts have
+// no names.
+func (splitter *Splitter) PartsBySize(maxBytes int64) ([]SplitPart, error) {
+	if maxBytes <= 0 {
+		return nil, errors.New("Part size must be positive")
+	}
+	pages, err := splitter.reader.getNumPages()
+	if err != nil {
+		return nil, err
+	}
+	outlines, err := splitter.reader.getOutlines()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get outline")
+	}
+	outlineSizes := make(map[int]int64, 0)
+	addOutlineSizes(outlineSizes, outlines, 0)
+
+	// Copy every page, as Document does, to find the objects each one draws
+	importer := NewImporter()
+	err = importer.SetSourceReader(splitter.name, splitter.reader)
+	if err != nil {
+		return nil, err
+	}
+	writer := importer.GetWriter()
+	tpls := make([]int, pages)
+	for pageno := 1; pageno <= pages; pageno++ {
+		tplid, err := importer.ImportPage(pageno, CropBox)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("Failed to import page %d", pageno))
+		}
+		tpls[pageno-1] = importer.tplMap[tplid].TemplateId
+	}
+	_, err = writer.PutFormXobjects(splitter.reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to copy pages")
+	}
+
+	objs := writer.GetImportedObjects()
+	byHash := make(map[string]*PdfObjectId, len(objs))
+	for pdfObjId := range objs {
+		byHash[pdfObjId.hash] = pdfObjId
+	}
+
+	parts := make([]SplitPart, 0)
+	var part *SplitPart
+	var size int64
+	var inPart map[*PdfObjectId]bool
+	for pageno := 1; pageno <= pages; pageno++ {
+		reached := reachedObjects(writer, byHash, writer.tpls[tpls[pageno-1]].objId.hash)
+
+		// The size the page adds to the part: objects pages before it in the part draw are there
+		pageSize := func() int64 {
+			n := splitPageOverhead + outlineSizes[pageno]
+			for _, pdfObjId := range reached {
+				if !inPart[pdfObjId] {
+					n += int64(len(objs[pdfObjId]) + splitObjectOverhead)
+				}
+			}
+			return n
+		}
+
+		n := pageSize()
+		if part != nil && size+n > maxBytes {
+			parts = append(parts, *part)
+			part = nil
+		}
+		if part == nil {
+			part = &SplitPart{FirstPage: pageno}
+			size = splitDocumentOverhead
+			inPart = make(map[*PdfObjectId]bool, len(reached))
+			n = pageSize()
+		}
+		part.LastPage = pageno
+		size += n
+		for _, pdfObjId := range reached {
+			inPart[pdfObjId] = true
+		}
+	}
+	if part != nil {
+		parts = append(parts, *part)
+	}
+
+	return parts, nil
+}
+
+// Get the written object with a hash and the written objects it refers to,
+// directly or through other objects
+func reachedObjects(writer *PdfWriter, byHash map[string]*PdfObjectId, hash string) []*PdfObjectId {
+	result := make([]*PdfObjectId, 0)
+	seen := make(map[*PdfObjectId]bool, 0)
+	pending := []*PdfObjectId{byHash[hash]}
+	for len(pending) > 0 {
+		pdfObjId := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if pdfObjId == nil || seen[pdfObjId] {
+			continue
+		}
+		seen[pdfObjId] = true
+		result = append(result, pdfObjId)
+		for _, hash := range writer.written_obj_pos[pdfObjId] {
+			pending = append(pending, byHash[hash])
+		}
+	}
+	return result
+}
+
+// Add the estimated sizes of the entries of an outline to the pages they
+// point to
+func addOutlineSizes(sizes map[int]int64, outlines []*Outline, depth int) {
+	if depth > maxOutlineDepth {
+		return
+	}
+	for _, outline := range outlines {
+		sizes[outline.PageNo] += int64(splitOutlineOverhead + 2*len(outline.Title))
+		addOutlineSizes(sizes, outline.Children, depth+1)
+	}
+}
+
+// Get a document of the pages of a part, e.g. to set options of the
+// document before writing it
+func (splitter *Splitter) Document(part SplitPart) (*Document, error) {
+	pages, err := splitter.reader.getNumPages()
+	if err != nil {
+		return nil, err
+	}
+	if part.FirstPage < 1 || part.LastPage > pages || part.FirstPage > part.LastPage {
+		return nil, errors.New(fmt.Sprintf("Pages %d to %d are not in the document", part.FirstPage, part.LastPage))
+	}
+
+	doc := NewDocument()
+	err = doc.importer.SetSourceReader(splitter.name, splitter.reader)
+	if err != nil {
+		return nil, err
+	}
+	for pageno := part.FirstPage; pageno <= part.LastPage; pageno++ {
+		tplid, err := doc.importer.ImportPage(pageno, CropBox)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("Failed to import page %d", pageno))
+		}
+		err = doc.AddTemplatePage(tplid)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	outlines, err := splitter.reader.getOutlines()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get outline")
+	}
+	doc.SetOutlines(partOutlines(outlines, part, 0))
+
+	ranges, err := splitter.reader.getPageLabelRanges()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get page labels")
+	}
+	doc.pageLabels = partPageLabels(ranges, part)
+
+	return doc, nil
+}
+
+// Get the entries of an outline that point into a part, or have children
+// that do, pointing to the pages of the part
+func partOutlines(outlines []*Outline, part SplitPart, depth int) []*Outline {
+	result := make([]*Outline, 0)
+	if depth > maxOutlineDepth {
+		return result
+	}
+
+	for _, outline := range outlines {
+		children := partOutlines(outline.Children, part, depth+1)
+		inPart := outline.PageNo >= part.FirstPage && outline.PageNo <= part.LastPage
+		if !inPart && len(children) == 0 {
+			continue
+		}
+
+		pageno := 0
+		if inPart {
+			pageno = outline.PageNo - part.FirstPage + 1
+		}
+		result = append(result, &Outline{Title: outline.Title, PageNo: pageno, Open: outline.Open, Children: children})
+	}
+	return result
+}
+
+// Get the page labels of the pages of a part: the labels of the ranges of
+// the document that the part has pages of, starting at the first page of the
+// part, so its pages keep their labels.  Parts that are labelled with their
+// page numbers from 1 get no labels.
+func partPageLabels(ranges []pageLabelRange, part SplitPart) []PageLabel {
+	// Pages before the first range are labelled with their page number
+	if len(ranges) == 0 || ranges[0].first > 0 {
+		ranges = append([]pageLabelRange{{first: 0, style: "/D", start: 1}}, ranges...)
+	}
+
+	first := part.FirstPage - 1
+	labels := make([]PageLabel, 0)
+	for i, r := range ranges {
+		if r.first > part.LastPage-1 {
+			break
+		}
+		// Ranges that end before the part, or where another one starts
+		if i+1 < len(ranges) && ranges[i+1].first <= first {
+			continue
+		}
+		if i+1 < len(ranges) && ranges[i+1].first == r.first {
+			continue
+		}
+
+		label := PageLabel{FirstPage: r.first - first + 1, Style: PageLabelStyle(r.style), Prefix: r.prefix, Start: r.start}
+		if r.first < first {
+			label.FirstPage = 1
+			label.Start += first - r.first
+		}
+		labels = append(labels, label)
+	}
+
+	if len(labels) == 1 && labels[0] == (PageLabel{FirstPage: 1, Style: PageLabelDecimal, Start: 1}) {
+		return nil
+	}
+	return labels
+}
+
+// Write each part to a file in dir named after the part, e.g.
+// "Chapter 1.pdf", or "part 1.pdf" for the first part if it has no name,
+// and get the paths of the files.  Characters that cannot be in file names
+// are replaced by "_", and names that are taken are made unique by adding a
+// number.  The source is never overwritten.
+func (splitter *Splitter) WriteParts(parts []SplitPart, dir string) ([]string, error) {
+	source, _ := filepath.Abs(splitter.name)
+	paths := make([]string, 0, len(parts))
+	taken := make(map[string]bool, len(parts))
+	for i, part := range parts {
+		name := partFileName(part.Name)
+		if name == "" {
+			name = fmt.Sprintf("part %d", i+1)
+		}
+		unique := name
+		for n := 2; taken[strings.ToLower(unique)]; n++ {
+			unique = fmt.Sprintf("%s (%d)", name, n)
+		}
+		taken[strings.ToLower(unique)] = true
+
+		doc, err := splitter.Document(part)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to split "+part.Name)
+		}
+		path := filepath.Join(dir, unique+".pdf")
+		if abs, _ := filepath.Abs(path); abs == source {
+			return nil, errors.New("Part would overwrite the source: " + path)
+		}
+		err = doc.WriteFile(path)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// Get a file name for a part name: characters that cannot be in file names
+// on common file systems are replaced, and it is shortened
+func partFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 32 || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+
+	runes := []rune(name)
+	if len(runes) > maxPartNameLength {
+		runes = runes[:maxPartNameLength]
+	}
+	return strings.Trim(string(runes), " .")
+}
@@ -0,0 +1,360 @@
+package gofpdi
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Color spaces nested deeper than this are not compared
+const maxColorSpaceDepth = 16
+
+// Write identical /Separation and /DeviceN color spaces only once, see
+// Importer.SetMergeSpotColors
+func (pdfWriter *PdfWriter) SetMergeSpotColors(b bool) {
+	pdfWriter.merge_spot_colors = b
+}
+
+// Write a reference to an identical spot color space written before by any
+// writer sharing shared_streams, instead of writing the color space again.
+// Returns false if value is not a reference to a spot color space or if it
+// has been written by this writer before.
+func (pdfWriter *PdfWriter) writeSharedColorSpaceRef(value *PdfValue) bool {
+	if !pdfWriter.merge_spot_colors || value.Type != PDF_TYPE_OBJREF {
+		return false
+	}
+
+	// Already referenced by this writer
+	if _, ok := pdfWriter.don_obj_stack[value.Id]; ok {
+		return false
+	}
+
+	digest, ok := pdfWriter.r.spotColorSpaceDigest(value)
+	if !ok {
+		return false
+	}
+
+	key := "spot:" + digest
+	if pdfObjId, ok := pdfWriter.shared_streams[key]; ok {
+		pdfWriter.outPdfObjectIdRef(pdfObjId)
+		pdfWriter.merged_objs[ObjectRef{Id: value.Id, Gen: value.Gen}] = pdfObjId
+		return true
+	}
+
+	pdfWriter.writeValue(value)
+
+	objId := pdfWriter.don_obj_stack[value.Id].NewId
+	pdfWriter.shared_streams[key] = &PdfObjectId{id: objId, hash: pdfWriter.shaOfInt(objId)}
+
+	return true
+}
+
+// Get a digest that identifies a /Separation or /DeviceN color space by its
+// contents: the colorant names, the alternate space and the tint transform
+// with the data of its streams.  Returns false for other values.
+func (pdfReader *PdfReader) spotColorSpaceDigest(value *PdfValue) (string, bool) {
+	cs, err := pdfReader.resolveDirect(value)
+	if err != nil || !isSpotColorSpace(cs) {
+		return "", false
+	}
+
+	var buf bytes.Buffer
+	if !pdfReader.writeCanonicalValue(&buf, cs, 0) {
+		return "", false
+	}
+
+	digest := sha1.Sum(buf.Bytes())
+	return hex.EncodeToString(digest[:]), true
+}
+
+// Determine if a value is a /Separation or /DeviceN color space
+func isSpotColorSpace(cs *PdfValue) bool {
+	return cs != nil && cs.Type == PDF_TYPE_ARRAY && len(cs.Array) >= 4 &&
+		(cs.Array[0].Token == "/Separation" || cs.Array[0].Token == "/DeviceN")
+}
+
+// Write a value with its references resolved and its dictionary keys
+// sorted, so values with the same contents are written the same.  Streams
+// are written as their dictionary without /Length and the digest of their
+// data.  Returns false if the value is nested too deeply or cannot be resolved.
+func (pdfReader *PdfReader) writeCanonicalValue(buf *bytes.Buffer, value *PdfValue, depth int) bool {
+	if depth > maxColorSpaceDepth {
+		return false
+	}
+
+	value, err := pdfReader.resolveDirect(value)
+	if err != nil || value == nil {
+		return false
+	}
+
+	switch value.Type {
+	case PDF_TYPE_ARRAY:
+		buf.WriteString("[")
+		for _, v := range value.Array {
+			if !pdfReader.writeCanonicalValue(buf, v, depth+1) {
+				return false
+			}
+		}
+		buf.WriteString("]")
+	case PDF_TYPE_DICTIONARY:
+		buf.WriteString("<<")
+		for _, k := range sortedKeys(value.Dictionary) {
+			if k == "/Length" {
+				continue
+			}
+			buf.WriteString(k + " ")
+			if !pdfReader.writeCanonicalValue(buf, value.Dictionary[k], depth+1) {
+				return false
+			}
+		}
+		buf.WriteString(">>")
+	case PDF_TYPE_STREAM:
+		if !pdfReader.writeCanonicalValue(buf, value.Value, depth+1) {
+			return false
+		}
+		buf.WriteString("stream " + pdfReader.streamDigest(value))
+	default:
+		writeContentValue(buf, value)
+		buf.WriteString(" ")
+	}
+
+	return true
+}
+
+// Get the names of the spot colors (the colorants of /Separation and
+// /DeviceN color spaces) of a color space, without the leading slash
+func (pdfReader *PdfReader) spotColorNames(cs *PdfValue, depth int) []string {
+	names := make([]string, 0)
+	if depth > maxColorSpaceDepth {
+		return names
+	}
+
+	cs, err := pdfReader.resolveDirect(cs)
+	if err != nil || cs == nil || cs.Type != PDF_TYPE_ARRAY || len(cs.Array) < 2 {
+		return names
+	}
+
+	add := func(name *PdfValue) {
+		// /All and /None are not colorants of their own
+		if name.Type == PDF_TYPE_TOKEN && name.Token != "/All" && name.Token != "/None" {
+			names = append(names, strings.TrimPrefix(name.Token, "/"))
+		}
+	}
+
+	switch cs.Array[0].Token {
+	case "/Separation":
+		add(cs.Array[1])
+	case "/DeviceN":
+		colorants, err := pdfReader.resolveDirect(cs.Array[1])
+		if err == nil && colorants != nil && colorants.Type == PDF_TYPE_ARRAY {
+			for _, name := range colorants.Array {
+				add(name)
+			}
+		}
+	case "/Indexed", "/Pattern":
+		// The base color space
+		return pdfReader.spotColorNames(cs.Array[1], depth+1)
+	}
+
+	return names
+}
+
+// Collect the spot colors used by content with resources: of the color spaces
+// named by the content, of its images, shadings and patterns, and of the
+// forms it draws.  If used is nil, all resources are collected.  Resources
+// that are reached twice are only read once.
+func (pdfReader *PdfReader) collectSpotColors(spots map[string]bool, resources *PdfValue, used map[string]map[string]bool, visited map[int]bool, depth int) error {
+	if depth > maxColorSpaceDepth {
+		return nil
+	}
+
+	resources, err := pdfReader.resolveDirect(resources)
+	if err != nil {
+		return errors.Wrap(err, "Failed to resolve resources")
+	}
+	if resources == nil || resources.Type != PDF_TYPE_DICTIONARY {
+		return nil
+	}
+
+	entries := func(category string) (map[string]*PdfValue, error) {
+		dict, err := pdfReader.resolveDirect(resources.Dictionary[category])
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to resolve "+category)
+		}
+		if dict == nil || dict.Type != PDF_TYPE_DICTIONARY {
+			return nil, nil
+		}
+
+		result := make(map[string]*PdfValue, len(dict.Dictionary))
+		for name, value := range dict.Dictionary {
+			if used == nil || used[category][name] {
+				result[name] = value
+			}
+		}
+		return result, nil
+	}
+
+	colorSpaces, err := entries("/ColorSpace")
+	if err != nil {
+		return err
+	}
+	for _, cs := range colorSpaces {
+		for _, name := range pdfReader.spotColorNames(cs, 0) {
+			spots[name] = true
+		}
+	}
+
+	shadings, err := entries("/Shading")
+	if err != nil {
+		return err
+	}
+	for _, shading := range shadings {
+		err = pdfReader.collectStreamSpotColors(spots, shading, visited, depth)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, category := range []string{"/XObject", "/Pattern"} {
+		objects, err := entries(category)
+		if err != nil {
+			return err
+		}
+		for _, obj := range objects {
+			err = pdfReader.collectStreamSpotColors(spots, obj, visited, depth)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Collect the spot colors of an image, form, shading or pattern
+func (pdfReader *PdfReader) collectStreamSpotColors(spots map[string]bool, ref *PdfValue, visited map[int]bool, depth int) error {
+	if ref.Type == PDF_TYPE_OBJREF {
+		if visited[ref.Id] {
+			return nil
+		}
+		visited[ref.Id] = true
+	}
+
+	obj, err := pdfReader.resolveDirect(ref)
+	if err != nil {
+		return errors.Wrap(err, "Failed to resolve resource")
+	}
+	if obj == nil {
+		return nil
+	}
+
+	dict := obj
+	if obj.Type == PDF_TYPE_STREAM {
+		dict = obj.Value
+	}
+	if dict == nil || dict.Type != PDF_TYPE_DICTIONARY {
+		return nil
+	}
+
+	// Images and shadings
+	for _, name := range pdfReader.spotColorNames(dict.Dictionary["/ColorSpace"], 0) {
+		spots[name] = true
+	}
+
+	// Shading patterns
+	if shading, ok := dict.Dictionary["/Shading"]; ok {
+		err = pdfReader.collectStreamSpotColors(spots, shading, visited, depth+1)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Forms and tiling patterns have content of their own
+	if resources, ok := dict.Dictionary["/Resources"]; ok && obj.Type == PDF_TYPE_STREAM {
+		var used map[string]map[string]bool
+		content, err := pdfReader.rebuildContentStream(obj)
+		if err == nil {
+			used, err = usedResourceNames(content)
+		}
+		if err != nil {
+			// Content that cannot be parsed may use any of its resources
+			used = nil
+		}
+
+		err = pdfReader.collectSpotColors(spots, resources, used, visited, depth+1)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Get the names of the spot colors used by a page, sorted
+func (pdfReader *PdfReader) getPageSpotColors(pageno int) ([]string, error) {
+	resources, err := pdfReader.getPageResources(pageno)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get page resources")
+	}
+
+	content, err := pdfReader.getContent(pageno)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get content")
+	}
+
+	used, err := usedResourceNames(content)
+	if err != nil {
+		return nil, err
+	}
+
+	spots := make(map[string]bool, 0)
+	err = pdfReader.collectSpotColors(spots, resources, used, make(map[int]bool, 0), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(spots))
+	for name := range spots {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Get the names of the spot colors (the colorants of /Separation and
+// /DeviceN color spaces, e.g. "PANTONE 185 C") used by every page of the
+// current source, by page number, e.g. to check a print job against the inks
+// of the press.  Colors of the forms, images, shadings and patterns drawn by a
+// page are included.
+func (importer *Importer) GetSpotColors() (map[int][]string, error) {
+	reader := importer.GetReader()
+
+	result := make(map[int][]string, len(reader.pages))
+	for pageno := 1; pageno <= len(reader.pages); pageno++ {
+		names, err := reader.getPageSpotColors(pageno)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("Failed to get spot colors of page %d", pageno))
+		}
+		result[pageno] = names
+	}
+
+	return result, nil
+}
+
+// Write identical /Separation and /DeviceN color spaces (with their tint
+// transform functions) only once, even when they come from different pages
+// or sources, so merged documents define each spot color once.  Color spaces
+// written directly into a resource or image, rather than as objects of their
+// own, are copied with it.  Spot color
+// spaces are always copied as they are; color conversion (see SetColorModel)
+// and downsampling leave them alone.
+func (importer *Importer) SetMergeSpotColors(b bool) {
+	importer.setWriterOptions(func(options *writerOptions) {
+		options.merge_spot_colors = b
+	})
+}
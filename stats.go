@@ -0,0 +1,80 @@
+package gofpdi
+
+import (
+	"unsafe"
+)
+
+// Estimated memory held by an importer, in bytes
+type MemoryStats struct {
+	// Parsed objects and decoded object streams kept by the readers
+	ParsedObjects int64
+	// Number of parsed objects kept by the readers
+	ParsedObjectCount int
+	// Content of the imported page templates, including compressed content
+	Templates int64
+	// Objects written for the templates that are kept in memory
+	WrittenObjects int64
+	// Objects written for the templates that were spilled, see SetSpill
+	SpilledObjects int64
+}
+
+// Get the total of all memory counted by the stats
+func (stats MemoryStats) Total() int64 {
+	return stats.ParsedObjects + stats.Templates + stats.WrittenObjects
+}
+
+// Get the estimated memory held by the readers and writers of all sources.
+// Readers that share their objects, such as readers from a ReaderPool, are
+// counted once.
+func (importer *Importer) Stats() MemoryStats {
+	var stats MemoryStats
+
+	caches := make(map[*objectCache]bool, 0)
+	for _, reader := range importer.readers {
+		for _, cache := range []*objectCache{reader.objects, reader.objStreams} {
+			if caches[cache] {
+				continue
+			}
+			caches[cache] = true
+
+			n, bytes := cache.stats()
+			stats.ParsedObjects += bytes
+			if cache == reader.objects {
+				stats.ParsedObjectCount += n
+			}
+		}
+	}
+
+	for _, writer := range importer.writers {
+		for _, tpl := range writer.tpls {
+			stats.Templates += int64(len(tpl.Buffer) + len(tpl.stream))
+		}
+		for _, data := range writer.written_objs {
+			stats.WrittenObjects += int64(len(data))
+		}
+		for _, spilled := range writer.spilled_objs {
+			stats.SpilledObjects += int64(spilled.length)
+		}
+	}
+
+	return stats
+}
+
+// Estimate the memory held by a parsed value: the values themselves, their
+// strings and stream data, and their dictionary and array entries.  Stream
+// data that is kept in the source is not counted.
+func valueSize(value *PdfValue) int64 {
+	if value == nil {
+		return 0
+	}
+
+	size := int64(unsafe.Sizeof(*value)) + int64(len(value.String)+len(value.Token)+len(value.Bytes))
+	for key, v := range value.Dictionary {
+		size += int64(len(key)) + int64(unsafe.Sizeof(key)+unsafe.Sizeof(v)) + valueSize(v)
+	}
+	for _, v := range value.Array {
+		size += int64(unsafe.Sizeof(v)) + valueSize(v)
+	}
+
+	return size + valueSize(value.Value) + valueSize(value.Stream)
+}
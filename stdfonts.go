@@ -0,0 +1,137 @@
+package gofpdi
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Widths of the printable ASCII characters (32 to 126) of the standard fonts
+// that text can be drawn with onto the pages of a Document, in glyph space
+// units of 1/1000.  The fonts are not embedded; every viewer has them.
+var standardFontWidths = map[string][]int{
+	"Helvetica": {
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+	},
+	"Helvetica-Bold": {
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+	},
+	"Times-Roman": {
+		250, 333, 408, 500, 500, 833, 778, 180, 333, 333, 500, 564, 250, 333, 250, 278,
+		500, 500, 500, 500, 500, 500, 500, 500, 500, 500, 278, 278, 564, 564, 564, 444,
+		921, 722, 667, 667, 722, 611, 556, 722, 722, 333, 389, 722, 611, 889, 722, 722,
+		556, 722, 667, 556, 611, 722, 722, 944, 722, 722, 611, 333, 278, 333, 469, 500,
+		333, 444, 500, 444, 500, 444, 333, 500, 500, 278, 278, 500, 278, 778, 500, 500,
+		500, 500, 333, 389, 278, 500, 500, 722, 500, 500, 444, 480, 200, 480, 541,
+	},
+	"Courier":      nil,
+	"Courier-Bold": nil,
+}
+
+// Check that a font is one of the standard fonts text can be drawn with
+func checkStandardFont(font string) error {
+	if _, ok := standardFontWidths[font]; !ok {
+		names := make([]string, 0, len(standardFontWidths))
+		for name := range standardFontWidths {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return errors.New(fmt.Sprintf("Unsupported font %q, use one of %s", font, strings.Join(names, ", ")))
+	}
+	return nil
+}
+
+// Encode text in WinAnsiEncoding.  Characters it does not have become "?".
+func winAnsiText(text string) []byte {
+	b := make([]byte, 0, len(text))
+	for _, r := range text {
+		if (r >= 32 && r < 127) || (r >= 160 && r <= 255) {
+			b = append(b, byte(r))
+		} else {
+			b = append(b, '?')
+		}
+	}
+	return b
+}
+
+// Get the width of text encoded with winAnsiText in a standard font, in
+// units of the font size
+func standardTextWidth(font string, text []byte) float64 {
+	widths := standardFontWidths[font]
+	width := 0
+	for _, c := range text {
+		switch {
+		case widths == nil:
+			// Courier is monospaced
+			width += 600
+		case c >= 32 && c < 127:
+			width += widths[c-32]
+		default:
+			// Letters with accents are about as wide as an "o"
+			width += widths['o'-32]
+		}
+	}
+	return float64(width) / 1000
+}
+
+// Text drawn onto a page of a Document in a standard font
+type pageText struct {
+	text []byte
+	font string
+	// Font size and position of the start of the baseline in points
+	size  float64
+	x     float64
+	y     float64
+	color [3]float64
+}
+
+// Get the content that draws the texts of a page, and the standard fonts
+// they use
+func pageTextContent(texts []pageText, userUnit float64) ([]byte, []string) {
+	var b bytes.Buffer
+	fonts := make([]string, 0)
+	for _, text := range texts {
+		known := false
+		for _, font := range fonts {
+			known = known || font == text.font
+		}
+		if !known {
+			fonts = append(fonts, text.font)
+		}
+
+		b.WriteString(fmt.Sprintf("q BT /%s %.5F Tf %.5F %.5F %.5F rg %.5F %.5F Td <%X> Tj ET Q\n",
+			text.font, text.size/userUnit, text.color[0], text.color[1], text.color[2], text.x/userUnit, text.y/userUnit, text.text))
+	}
+	return b.Bytes(), fonts
+}
+
+// Write the standard fonts used by the texts of the pages of the document
+// from object id nextId.  Returns their object ids by font and the next free
+// object id.
+func (doc *Document) putStandardFonts(objects map[int][]byte, nextId int) (map[string]int, int) {
+	ids := make(map[string]int, 0)
+	for _, page := range doc.pages {
+		for _, text := range page.texts {
+			if _, ok := ids[text.font]; ok {
+				continue
+			}
+			ids[text.font] = nextId
+			objects[nextId] = []byte(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>\nendobj\n", text.font))
+			nextId++
+		}
+	}
+	return ids, nextId
+}
@@ -0,0 +1,56 @@
+package gofpdi
+
+import (
+	"strings"
+)
+
+// Dictionary keys of private or redundant data that composed documents
+// don't need: application data, XMP metadata, page thumbnails, modification
+// dates and article beads.  See Importer.SetStripKeys.
+var PrivateDataKeys = []string{"/PieceInfo", "/Metadata", "/Thumb", "/LastModified", "/B"}
+
+// Dictionary keys removed by the privacy scrub, see Importer.SetPrivacyScrub:
+// the document information dictionary and its entries naming people and
+// tools, XMP metadata and application data
+var privacyKeys = map[string]bool{
+	"/Info":         true,
+	"/Author":       true,
+	"/Creator":      true,
+	"/Producer":     true,
+	"/Metadata":     true,
+	"/PieceInfo":    true,
+	"/LastModified": true,
+}
+
+// Leave the given keys out of every dictionary the writer copies, see Importer.SetStripKeys
+func (pdfWriter *PdfWriter) SetStripKeys(keys ...string) {
+	pdfWriter.strip_keys = stripKeySet(keys)
+}
+
+// Get the set of keys to strip, with the leading slash
+func stripKeySet(keys []string) map[string]bool {
+	set := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if !strings.HasPrefix(key, "/") {
+			key = "/" + key
+		}
+		set[key] = true
+	}
+	return set
+}
+
+// Leave page thumbnails out of the copied objects, see Importer.SetStripThumbnails
+func (pdfWriter *PdfWriter) SetStripThumbnails(b bool) {
+	pdfWriter.strip_thumbnails = b
+}
+
+// Leave associated files out of the templates and copied objects, see
+// Importer.SetStripAssociatedFiles
+func (pdfWriter *PdfWriter) SetStripAssociatedFiles(b bool) {
+	pdfWriter.strip_af = b
+}
+
+// Remove document metadata from the copied objects, see Importer.SetPrivacyScrub
+func (pdfWriter *PdfWriter) SetPrivacyScrub(b bool) {
+	pdfWriter.scrub_privacy = b
+}
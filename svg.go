@@ -0,0 +1,346 @@
+package gofpdi
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Converts the drawing operations of a content stream into svg elements
+type svgHandler struct {
+	buf   bytes.Buffer
+	defs  bytes.Buffer
+	clips map[*clipPath]string
+	maps  fontTextMaps
+}
+
+// Write the content of a template (returned from ImportPage) as an svg image.
+// Paths, fills, strokes and text are converted; text is written as spans
+// using the fonts' base names, decoded by the /ToUnicode maps of the fonts,
+// or as Latin-1 for simple fonts without one.  Text of composite fonts
+// without a /ToUnicode map is left out.  DCT encoded and 8 bit gray/rgb images are
+// embedded, other images and shadings are left out.
+func (importer *Importer) ExportTemplateSVG(tplid int, w io.Writer) error {
+	tpl, err := importer.getTemplate(tplid)
+	if err != nil {
+		return err
+	}
+
+	return tpl.writeSVG(w)
+}
+
+func (tpl *PdfTemplate) writeSVG(w io.Writer) error {
+	handler := &svgHandler{clips: make(map[*clipPath]string, 0), maps: make(fontTextMaps, 0)}
+
+	// Map the template box to the svg viewport, flipping the y axis
+	c, s, tx, ty := tpl.formMatrix()
+	ctm := matrix{c, s, -s, c, tx, ty}.multiply(matrix{1, 0, 0, -1, 0, tpl.H})
+
+	resources := tpl.Resources
+	if resources != nil && resources.Type != PDF_TYPE_DICTIONARY {
+		resources = nil
+	}
+
+	it := newContentInterpreter(tpl.Reader, resources, ctm, handler)
+	err := it.run(tpl.Buffer)
+	if err != nil {
+		return errors.Wrap(err, "Failed to interpret content")
+	}
+
+	_, err = fmt.Fprintf(w, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%spt\" height=\"%spt\" viewBox=\"0 0 %s %s\">\n",
+		svgNum(tpl.W), svgNum(tpl.H), svgNum(tpl.W), svgNum(tpl.H))
+	if err != nil {
+		return errors.Wrap(err, "Failed to write svg")
+	}
+
+	if handler.defs.Len() > 0 {
+		io.WriteString(w, "<defs>\n")
+		w.Write(handler.defs.Bytes())
+		io.WriteString(w, "</defs>\n")
+	}
+
+	w.Write(handler.buf.Bytes())
+
+	_, err = io.WriteString(w, "</svg>\n")
+	if err != nil {
+		return errors.Wrap(err, "Failed to write svg")
+	}
+
+	return nil
+}
+
+// Format a number for svg output
+func svgNum(f float64) string {
+	s := fmt.Sprintf("%.3f", f)
+	s = strings.TrimRight(s, "0")
+	s = strings.TrimRight(s, ".")
+	if s == "-0" || s == "" {
+		s = "0"
+	}
+	return s
+}
+
+func svgMatrix(m matrix) string {
+	return fmt.Sprintf("matrix(%s %s %s %s %s %s)", svgNum(m[0]), svgNum(m[1]), svgNum(m[2]), svgNum(m[3]), svgNum(m[4]), svgNum(m[5]))
+}
+
+// Get the svg path data of a path
+func svgPath(path []pathSegment) string {
+	var d strings.Builder
+	for _, seg := range path {
+		switch seg.op {
+		case 'm':
+			d.WriteString("M" + svgNum(seg.pts[0].x) + " " + svgNum(seg.pts[0].y))
+		case 'l':
+			d.WriteString("L" + svgNum(seg.pts[0].x) + " " + svgNum(seg.pts[0].y))
+		case 'c':
+			d.WriteString("C")
+			for i, p := range seg.pts {
+				if i > 0 {
+					d.WriteString(" ")
+				}
+				d.WriteString(svgNum(p.x) + " " + svgNum(p.y))
+			}
+		case 'h':
+			d.WriteString("Z")
+		}
+	}
+	return d.String()
+}
+
+// Convert color components to an svg color, based on the number of components
+func svgColor(space string, components []float64) string {
+	c := colorRGB(space, components)
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// Get the clip-path attribute for the current clipping path
+func (handler *svgHandler) clipAttr(gs *graphicsState) string {
+	if len(gs.clips) == 0 {
+		return ""
+	}
+
+	parent := ""
+	for _, clip := range gs.clips {
+		id, ok := handler.clips[clip]
+		if !ok {
+			id = fmt.Sprintf("clip%d", len(handler.clips)+1)
+			handler.clips[clip] = id
+
+			rule := "nonzero"
+			if clip.evenOdd {
+				rule = "evenodd"
+			}
+
+			handler.defs.WriteString("<clipPath id=\"" + id + "\"" + parent + ">")
+			handler.defs.WriteString("<path d=\"" + svgPath(clip.path) + "\" clip-rule=\"" + rule + "\"/></clipPath>\n")
+		}
+		parent = " clip-path=\"url(#" + id + ")\""
+	}
+
+	return parent
+}
+
+func (handler *svgHandler) paintPath(it *contentInterpreter, path []pathSegment, op string) {
+	if op == "n" || len(path) == 0 {
+		return
+	}
+
+	gs := it.gs
+	fill := op != "S" && op != "s"
+	stroke := op == "S" || op == "s" || strings.HasPrefix(op, "B") || strings.HasPrefix(op, "b")
+
+	handler.buf.WriteString("<path d=\"" + svgPath(path) + "\"")
+
+	if fill {
+		handler.buf.WriteString(" fill=\"" + svgColor(gs.fillSpace, gs.fillColor) + "\"")
+		if strings.HasSuffix(op, "*") {
+			handler.buf.WriteString(" fill-rule=\"evenodd\"")
+		}
+		if gs.fillAlpha < 1 {
+			handler.buf.WriteString(" fill-opacity=\"" + svgNum(gs.fillAlpha) + "\"")
+		}
+	} else {
+		handler.buf.WriteString(" fill=\"none\"")
+	}
+
+	if stroke {
+		width := gs.lineWidth * gs.ctm.scale()
+		if width == 0 {
+			// A line width of 0 means the thinnest line that can be rendered
+			width = 0.1
+		}
+		handler.buf.WriteString(" stroke=\"" + svgColor(gs.strokeSpace, gs.strokeColor) + "\" stroke-width=\"" + svgNum(width) + "\"")
+		if gs.strokeAlpha < 1 {
+			handler.buf.WriteString(" stroke-opacity=\"" + svgNum(gs.strokeAlpha) + "\"")
+		}
+	}
+
+	handler.buf.WriteString(handler.clipAttr(gs) + "/>\n")
+}
+
+func (handler *svgHandler) showText(it *contentInterpreter, run *textRun) {
+	gs := it.gs
+
+	// Invisible and clip-only text
+	if gs.render == 3 || gs.render == 7 {
+		return
+	}
+
+	text := handler.maps.decode(it.reader, run)
+	if text == "" {
+		return
+	}
+
+	family := "serif"
+	if run.font.dict != nil && run.font.dict.Dictionary["/BaseFont"] != nil {
+		family = strings.TrimPrefix(run.font.dict.Dictionary["/BaseFont"].Token, "/")
+		if i := strings.IndexByte(family, '+'); i >= 0 {
+			family = family[i+1:]
+		}
+	}
+
+	// Glyphs are drawn upright in svg, so flip the y axis of glyph space
+	m := matrix{1, 0, 0, -1, 0, 0}.multiply(run.glyphMatrix())
+
+	handler.buf.WriteString("<text transform=\"" + svgMatrix(m) + "\" font-size=\"1\" font-family=\"")
+	xml.EscapeText(&handler.buf, []byte(family))
+	handler.buf.WriteString("\" fill=\"" + svgColor(gs.fillSpace, gs.fillColor) + "\"" + handler.clipAttr(gs) + ">")
+	xml.EscapeText(&handler.buf, []byte(text))
+
+	handler.buf.WriteString("</text>\n")
+}
+
+func (handler *svgHandler) drawImage(it *contentInterpreter, img *PdfValue, inline *ContentOperation) {
+	gs := it.gs
+
+	var dict map[string]*PdfValue
+	var data []byte
+	if inline != nil {
+		if len(inline.Operands) == 0 {
+			return
+		}
+		dict = inline.Operands[0].Dictionary
+		data = inline.Data
+	} else {
+		dict = img.Value.Dictionary
+		data, _ = it.reader.streamData(img)
+	}
+
+	uri := svgImageURI(it.reader, dict, data)
+
+	// The image is drawn into the unit square, with its first row at the top
+	m := matrix{1, 0, 0, -1, 0, 1}.multiply(gs.ctm)
+
+	if uri == "" {
+		corners := []point{m.transform(0, 0), m.transform(1, 0), m.transform(1, 1), m.transform(0, 1)}
+		path := []pathSegment{{'m', corners[:1]}, {'l', corners[1:2]}, {'l', corners[2:3]}, {'l', corners[3:4]}, {'h', nil}}
+		handler.buf.WriteString("<path d=\"" + svgPath(path) + "\" fill=\"#d3d3d3\"" + handler.clipAttr(gs) + "/>\n")
+		return
+	}
+
+	handler.buf.WriteString("<image width=\"1\" height=\"1\" preserveAspectRatio=\"none\" transform=\"" + svgMatrix(m) + "\" href=\"" + uri + "\"" + handler.clipAttr(gs) + "/>\n")
+}
+
+func (handler *svgHandler) paintShading(it *contentInterpreter, shading *PdfValue) {}
+
+// Get a data uri for an image, or "" if the image cannot be converted
+func svgImageURI(reader *PdfReader, dict map[string]*PdfValue, data []byte) string {
+	filter := dict["/Filter"]
+	if filter == nil {
+		filter = dict["/F"]
+	}
+
+	filterName := ""
+	if filter != nil {
+		if filter.Type == PDF_TYPE_ARRAY && len(filter.Array) == 1 {
+			filter = filter.Array[0]
+		}
+		filterName = filter.Token
+	}
+
+	switch filterName {
+	case "/DCTDecode", "/DCT":
+		return "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(data)
+	case "/FlateDecode", "/Fl", "":
+		if _, ok := dict["/DecodeParms"]; ok {
+			return ""
+		}
+	default:
+		return ""
+	}
+
+	if filterName != "" {
+		decoded, err := reader.rebuildContentStream(&PdfValue{
+			Value:  &PdfValue{Dictionary: map[string]*PdfValue{"/Filter": {Type: PDF_TYPE_TOKEN, Token: "/FlateDecode"}}},
+			Stream: &PdfValue{Bytes: data},
+		})
+		if err != nil {
+			return ""
+		}
+		data = decoded
+	}
+
+	width := imageDictInt(dict, "/Width", "/W")
+	height := imageDictInt(dict, "/Height", "/H")
+	bpc := imageDictInt(dict, "/BitsPerComponent", "/BPC")
+
+	cs := dict["/ColorSpace"]
+	if cs == nil {
+		cs = dict["/CS"]
+	}
+	if cs == nil || bpc != 8 || width <= 0 || height <= 0 {
+		return ""
+	}
+
+	components := 0
+	switch cs.Token {
+	case "/DeviceGray", "/G":
+		components = 1
+	case "/DeviceRGB", "/RGB":
+		components = 3
+	}
+	// Enough data for all samples, checked without multiplying the
+	// dimensions, which could overflow
+	if components == 0 || width > len(data)/components/height {
+		return ""
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := (y*width + x) * components
+			if components == 1 {
+				img.Set(x, y, color.Gray{data[i]})
+			} else {
+				img.Set(x, y, color.RGBA{data[i], data[i+1], data[i+2], 255})
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return ""
+	}
+
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+// Get an integer entry of an image dictionary, by its full or abbreviated (inline image) key
+func imageDictInt(dict map[string]*PdfValue, key string, abbr string) int {
+	if v, ok := dict[key]; ok {
+		return v.Int
+	}
+	if v, ok := dict[abbr]; ok {
+		return v.Int
+	}
+	return 0
+}
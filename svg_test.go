@@ -0,0 +1,83 @@
+package gofpdi
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// Get the svg image of page 1 of data
+func exportTestSVG(t *testing.T, data []byte) string {
+	t.Helper()
+
+	importer := newTestImporter(t, data)
+	tplid, err := importer.ImportPage(1, MediaBox)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	err = importer.ExportTemplateSVG(tplid, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+// Text is decoded by the /ToUnicode map of its font, also for two byte codes
+// of composite fonts, and as Latin-1 for simple fonts without one
+func TestSVGText(t *testing.T) {
+	withoutToUnicode := bytes.Replace(compositeFontDocument(), []byte(" /ToUnicode 11 0 R"), []byte(strings.Repeat(" ", len(" /ToUnicode 11 0 R"))), 1)
+
+	for _, test := range []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"composite font", compositeFontDocument(), "font-family=\"TestSans\" fill=\"#000000\">日本</text>"},
+		{"simple font", testDocument(1), ">Page 1 of the test document</text>"},
+		{"composite font without ToUnicode", withoutToUnicode, ""},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			svg := exportTestSVG(t, test.data)
+			if test.want == "" {
+				if strings.Contains(svg, "<text") {
+					t.Errorf("text of unknown characters is written:\n%s", svg)
+				}
+				return
+			}
+			if !strings.Contains(svg, test.want) {
+				t.Errorf("%q is not written:\n%s", test.want, svg)
+			}
+		})
+	}
+}
+
+// Images are embedded only if their data has all the samples, also for
+// dimensions whose product overflows
+func TestSVGImageURI(t *testing.T) {
+	data := []byte("\x00\xff\x80\x40\x00\xff")
+	for _, test := range []struct {
+		name          string
+		width, height int
+		embedded      bool
+	}{
+		{"gray", 3, 2, true},
+		{"too little data", 3, 3, false},
+		{"overflowing width", 1 << 62, 4, false},
+		{"overflowing product", 1 << 32, 1 << 32, false},
+		{"negative", -3, -2, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			dict := map[string]*PdfValue{
+				"/Width":            {Type: PDF_TYPE_NUMERIC, Int: test.width},
+				"/Height":           {Type: PDF_TYPE_NUMERIC, Int: test.height},
+				"/BitsPerComponent": {Type: PDF_TYPE_NUMERIC, Int: 8},
+				"/ColorSpace":       {Type: PDF_TYPE_TOKEN, Token: "/DeviceGray"},
+			}
+			uri := svgImageURI(&PdfReader{}, dict, data)
+			if embedded := strings.HasPrefix(uri, "data:image/png;base64,"); embedded != test.embedded {
+				t.Errorf("image %d x %d is embedded: %v, want %v", test.width, test.height, embedded, test.embedded)
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package gofpdi
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/pkg/errors"
+)
+
+// A run of text on a page, see Importer.GetPageText
+type TextRun struct {
+	// The text, "" for strings in fonts whose characters are not known
+	Text string
+	// Base name of the font, without the subset prefix, e.g. "Helvetica"
+	Font string
+	// Font size in points as drawn, with the scaling of the page content
+	Size float64
+	// Bounding box (llx, lly, urx, ury) in the default user space of the page,
+	// from the descent to the ascent of the font
+	Box [4]float64
+	// The text is not visible (text render mode 3), e.g. the text layer of a
+	// scanned page
+	Invisible bool
+}
+
+// Collects the text runs of a content stream
+type textHandler struct {
+	nopContentHandler
+	reader *PdfReader
+	runs   []TextRun
+	maps   fontTextMaps
+	// Where the last run ends and the direction of its baseline
+	end point
+	dir point
+}
+
+func (handler *textHandler) showText(it *contentInterpreter, run *textRun) {
+	m := run.glyphMatrix()
+	size := math.Hypot(m[2], m[3])
+	if size == 0 {
+		return
+	}
+
+	text := handler.maps.decode(handler.reader, run)
+	font := ""
+	if run.font.dict != nil && run.font.dict.Dictionary["/BaseFont"] != nil {
+		font = strings.TrimPrefix(run.font.dict.Dictionary["/BaseFont"].Token, "/")
+		if i := strings.IndexByte(font, '+'); i >= 0 {
+			font = font[i+1:]
+		}
+	}
+	invisible := it.gs.render == 3
+
+	corners := run.corners()
+	box, _ := pathBounds([]pathSegment{{'m', corners[:]}})
+	start := run.start.transform(0, 0)
+	end := run.start.transform(run.width, 0)
+	dir := point{m[0], m[1]}
+	if l := math.Hypot(dir.x, dir.y); l > 0 {
+		dir = point{dir.x / l, dir.y / l}
+	}
+
+	// Continue the last run if this one follows it on the same baseline, e.g.
+	// the parts of a kerned TJ array or the words of a line
+	if n := len(handler.runs); n > 0 {
+		last := &handler.runs[n-1]
+		dx, dy := start.x-handler.end.x, start.y-handler.end.y
+		along := dx*handler.dir.x + dy*handler.dir.y
+		across := dx*handler.dir.y - dy*handler.dir.x
+		if last.Font == font && math.Abs(last.Size-size) < 0.01*size && last.Invisible == invisible &&
+			math.Abs(dir.x-handler.dir.x) < 0.01 && math.Abs(dir.y-handler.dir.y) < 0.01 &&
+			math.Abs(across) < 0.1*size && along > -0.3*size && along < size {
+			if along > 0.15*size && !strings.HasSuffix(last.Text, " ") && !strings.HasPrefix(text, " ") {
+				last.Text += " "
+			}
+			last.Text += text
+			last.Box = [4]float64{
+				math.Min(last.Box[0], box[0]),
+				math.Min(last.Box[1], box[1]),
+				math.Max(last.Box[2], box[2]),
+				math.Max(last.Box[3], box[3]),
+			}
+			handler.end = end
+			return
+		}
+	}
+
+	handler.runs = append(handler.runs, TextRun{Text: text, Font: font, Size: size, Box: box, Invisible: invisible})
+	handler.end = end
+	handler.dir = dir
+}
+
+// Mappings of character codes to text by font dictionary, read when a font
+// is first shown
+type fontTextMaps map[*PdfValue]map[int]string
+
+// Get the text of a run: by the /ToUnicode map of its font, or for simple
+// fonts without one, the codes as Latin-1
+func (maps fontTextMaps) decode(reader *PdfReader, run *textRun) string {
+	var toUnicode map[int]string
+	if run.font.dict != nil {
+		var ok bool
+		toUnicode, ok = maps[run.font.dict]
+		if !ok {
+			toUnicode = reader.readToUnicode(run.font.dict)
+			maps[run.font.dict] = toUnicode
+		}
+	}
+
+	var sb strings.Builder
+	for _, code := range run.codes {
+		if s, ok := toUnicode[code]; ok {
+			sb.WriteString(s)
+		} else if !run.font.twoByte && toUnicode == nil && code >= 32 {
+			sb.WriteRune(rune(code))
+		}
+	}
+	return sb.String()
+}
+
+// Read the /ToUnicode map of a font, nil if it has none or it cannot be read
+func (pdfReader *PdfReader) readToUnicode(font *PdfValue) map[int]string {
+	stream, err := pdfReader.resolveDirect(font.Dictionary["/ToUnicode"])
+	if err != nil || stream == nil || stream.Type != PDF_TYPE_STREAM {
+		return nil
+	}
+	data, err := pdfReader.rebuildContentStream(stream)
+	if err != nil {
+		return nil
+	}
+
+	// A CMap parses like content: its sections are operators with the
+	// entries as operands
+	ops, err := ParseContentStream(data)
+	if err != nil {
+		return nil
+	}
+
+	result := make(map[int]string, 0)
+	for _, op := range ops {
+		switch op.Operator {
+		case "endbfchar":
+			for i := 0; i+1 < len(op.Operands); i += 2 {
+				result[cmapCode(op.Operands[i])] = cmapText(stringBytes(op.Operands[i+1]))
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(op.Operands); i += 3 {
+				lo, hi := cmapCode(op.Operands[i]), cmapCode(op.Operands[i+1])
+				if hi < lo || hi-lo > 0xffff {
+					continue
+				}
+				dst := op.Operands[i+2]
+				if dst.Type == PDF_TYPE_ARRAY {
+					for j, v := range dst.Array {
+						if lo+j <= hi {
+							result[lo+j] = cmapText(stringBytes(v))
+						}
+					}
+					continue
+				}
+
+				// Consecutive codes map to consecutive last characters
+				b := stringBytes(dst)
+				if len(b) == 0 {
+					continue
+				}
+				for code := lo; code <= hi; code++ {
+					c := append([]byte(nil), b...)
+					c[len(c)-1] += byte(code - lo)
+					result[code] = cmapText(c)
+				}
+			}
+		}
+	}
+	return result
+}
+
+// Get the character code of a CMap source string
+func cmapCode(value *PdfValue) int {
+	code := 0
+	for _, b := range stringBytes(value) {
+		code = code<<8 | int(b)
+	}
+	return code
+}
+
+// Decode the UTF-16BE text of a CMap destination string
+func cmapText(b []byte) string {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// Get the text runs of a page, see Importer.GetPageText
+func (pdfReader *PdfReader) getPageText(pageno int) ([]TextRun, error) {
+	resources, err := pdfReader.getPageResources(pageno)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get page resources")
+	}
+	resources, err = pdfReader.resolveDirect(resources)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to resolve page resources")
+	}
+
+	content, err := pdfReader.getContent(pageno)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get content")
+	}
+
+	handler := &textHandler{reader: pdfReader, runs: make([]TextRun, 0), maps: make(fontTextMaps, 0)}
+	it := newContentInterpreter(pdfReader, resources, identityMatrix, handler)
+	err = it.run(content)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler.runs, nil
+}
+
+// Get the text of page pageno of the current source as positioned runs, in
+// the order they are drawn, e.g. to index the pages of a document assembled
+// with gofpdi for search or to highlight matches.  Strings shown one after
+// another on the same baseline are joined into one run, with a space where
+// they are a word apart.  Text is decoded with the /ToUnicode maps of the
+// fonts; simple fonts without one are read as Latin-1.  Boxes are in the
+// default user space of the page, before its rotation; text of forms drawn
+// by the page is included.
+func (importer *Importer) GetPageText(pageno int) ([]TextRun, error) {
+	runs, err := importer.GetReader().getPageText(pageno)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("Failed to get text of page %d", pageno))
+	}
+	return runs, nil
+}
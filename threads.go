@@ -0,0 +1,233 @@
+package gofpdi
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// Beads followed from a thread before it is considered broken
+const maxThreadBeads = 100000
+
+// An article thread of a document (/Threads): the areas of its pages that an
+// article flows through, in reading order
+type ArticleThread struct {
+	// Title of the thread (/I /Title), "" if it has none
+	Title string
+	Beads []*ArticleBead
+}
+
+// A bead of an article thread: an area of a page the article flows through
+type ArticleBead struct {
+	PageNo int
+	// Rectangle of the area on the page, [llx lly urx ury] in points
+	Rect [4]float64
+}
+
+// Get the article threads of the document.  Beads on pages the document
+// does not have are left out.
+func (pdfReader *PdfReader) GetThreads() ([]*ArticleThread, error) {
+	threads, err := pdfReader.resolveDirect(pdfReader.catalog.Value.Dictionary["/Threads"])
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to resolve /Threads")
+	}
+	if threads == nil || threads.Type != PDF_TYPE_ARRAY {
+		return []*ArticleThread{}, nil
+	}
+
+	pageNos, err := pdfReader.getPageNumbers()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*ArticleThread, 0, len(threads.Array))
+	for _, ref := range threads.Array {
+		thread, err := pdfReader.resolveDirect(ref)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to resolve thread")
+		}
+		if thread == nil || thread.Type != PDF_TYPE_DICTIONARY {
+			continue
+		}
+
+		article := &ArticleThread{Beads: make([]*ArticleBead, 0)}
+		info, err := pdfReader.resolveDirect(thread.Dictionary["/I"])
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to resolve thread information")
+		}
+		if info != nil && info.Type == PDF_TYPE_DICTIONARY {
+			title, err := pdfReader.resolveDirect(info.Dictionary["/Title"])
+			if err != nil {
+				return nil, errors.Wrap(err, "Failed to resolve /Title")
+			}
+			if title != nil {
+				article.Title = decodeTextString(stringBytes(title))
+			}
+		}
+
+		// The beads form a ring from the first bead (/F) through /N
+		visited := make(map[int]bool, 0)
+		next := thread.Dictionary["/F"]
+		for next != nil && len(visited) < maxThreadBeads {
+			if next.Type == PDF_TYPE_OBJREF {
+				if visited[next.Id] {
+					break
+				}
+				visited[next.Id] = true
+			}
+			bead, err := pdfReader.resolveDirect(next)
+			if err != nil {
+				return nil, errors.Wrap(err, "Failed to resolve bead")
+			}
+			if bead == nil || bead.Type != PDF_TYPE_DICTIONARY {
+				break
+			}
+			next = bead.Dictionary["/N"]
+			if next != nil && next.Type != PDF_TYPE_OBJREF {
+				// A direct bead cannot be told apart from the first one
+				next = nil
+			}
+
+			page := bead.Dictionary["/P"]
+			rect, err := pdfReader.resolveDirect(bead.Dictionary["/R"])
+			if err != nil {
+				return nil, errors.Wrap(err, "Failed to resolve bead rectangle")
+			}
+			pageno, ok := 0, false
+			if page != nil && page.Type == PDF_TYPE_OBJREF {
+				pageno, ok = pageNos[page.Id]
+			}
+			if !ok || rect == nil || rect.Type != PDF_TYPE_ARRAY || len(rect.Array) != 4 {
+				continue
+			}
+
+			b := &ArticleBead{PageNo: pageno}
+			for i, v := range rect.Array {
+				v, err := pdfReader.resolveDirect(v)
+				if err != nil {
+					return nil, errors.Wrap(err, "Failed to resolve bead rectangle")
+				}
+				b.Rect[i] = v.Real
+			}
+			b.Rect = [4]float64{math.Min(b.Rect[0], b.Rect[2]), math.Min(b.Rect[1], b.Rect[3]), math.Max(b.Rect[0], b.Rect[2]), math.Max(b.Rect[1], b.Rect[3])}
+			article.Beads = append(article.Beads, b)
+		}
+
+		result = append(result, article)
+	}
+
+	return result, nil
+}
+
+// Get the article threads of the current source, see PdfReader.GetThreads
+func (importer *Importer) GetThreads() ([]*ArticleThread, error) {
+	return importer.GetReader().GetThreads()
+}
+
+// Carry the article threads of the imported documents: the beads on the
+// pages added by AddTemplatePage are written onto those pages, linked in the
+// reading order of their threads.  Threads are not carried by default, and
+// their beads are never copied along with the objects of a page.
+func (doc *Document) SetCarryThreads(b bool) {
+	doc.carryThreads = b
+}
+
+// Write the threads with beads on the pages of the document, from object id
+// nextId.  Returns the catalog entry for them and the next free object id,
+// and sets the beads of the pages.
+func (doc *Document) putThreads(objects map[int][]byte, pageIds []int, scales []float64, nextId int) (string, int, error) {
+	for _, page := range doc.pages {
+		page.beads = nil
+	}
+	if !doc.carryThreads {
+		return "", nextId, nil
+	}
+
+	// Pages of the document by the source page they show
+	type sourcePage struct {
+		reader *PdfReader
+		pageno int
+	}
+	shownOn := make(map[sourcePage][]int, 0)
+	readers := make([]*PdfReader, 0)
+	for i, page := range doc.pages {
+		if page.source == nil {
+			continue
+		}
+		key := sourcePage{page.source, page.sourcePage}
+		if len(shownOn[key]) == 0 {
+			known := false
+			for _, reader := range readers {
+				known = known || reader == page.source
+			}
+			if !known {
+				readers = append(readers, page.source)
+			}
+		}
+		shownOn[key] = append(shownOn[key], i)
+	}
+
+	refs := make([]int, 0)
+	for _, reader := range readers {
+		threads, err := reader.GetThreads()
+		if err != nil {
+			return "", 0, errors.Wrap(err, "Failed to get article threads")
+		}
+
+		for _, thread := range threads {
+			// The beads of the thread on the pages of the document, in
+			// reading order
+			type bead struct {
+				page int
+				rect [4]float64
+			}
+			beads := make([]bead, 0)
+			for _, b := range thread.Beads {
+				for _, i := range shownOn[sourcePage{reader, b.PageNo}] {
+					page := doc.pages[i]
+					x1, y1 := page.sourceTransform(b.Rect[0], b.Rect[1])
+					x2, y2 := page.sourceTransform(b.Rect[2], b.Rect[3])
+					rect := [4]float64{math.Min(x1, x2), math.Min(y1, y2), math.Max(x1, x2), math.Max(y1, y2)}
+					for j := range rect {
+						rect[j] /= scales[i]
+					}
+					beads = append(beads, bead{i, rect})
+				}
+			}
+			if len(beads) == 0 {
+				continue
+			}
+
+			threadId := nextId
+			nextId += 1 + len(beads)
+			var info bytes.Buffer
+			if thread.Title != "" {
+				info.WriteString(" /I << /Title ")
+				writeContentValue(&info, encodeTextString(thread.Title))
+				info.WriteString(" >>")
+			}
+			objects[threadId] = []byte(fmt.Sprintf("<< /Type /Thread /F %d 0 R%s >>\nendobj\n", threadId+1, info.String()))
+
+			for j, b := range beads {
+				id := threadId + 1 + j
+				next := threadId + 1 + (j+1)%len(beads)
+				prev := threadId + 1 + (j+len(beads)-1)%len(beads)
+				first := ""
+				if j == 0 {
+					first = fmt.Sprintf(" /T %d 0 R", threadId)
+				}
+				objects[id] = []byte(fmt.Sprintf("<< /Type /Bead%s /N %d 0 R /V %d 0 R /P %d 0 R /R [%.5F %.5F %.5F %.5F] >>\nendobj\n",
+					first, next, prev, pageIds[b.page], b.rect[0], b.rect[1], b.rect[2], b.rect[3]))
+				doc.pages[b.page].beads = append(doc.pages[b.page].beads, id)
+			}
+			refs = append(refs, threadId)
+		}
+	}
+
+	if len(refs) == 0 {
+		return "", nextId, nil
+	}
+	return " /Threads [" + objectRefs(refs) + "]", nextId, nil
+}
@@ -0,0 +1,447 @@
+package gofpdi
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// The built-in renderer of GenerateThumbnail draws this many samples per
+// pixel in each direction, which smooths the edges of shapes
+const thumbnailSupersampling = 3
+
+// Segments curves are flattened into
+const thumbnailCurveSegments = 12
+
+// Paints the drawing operations of a content stream onto an image, roughly:
+// paths are filled and stroked, text is drawn as a bar for each glyph, and
+// 8 bit gray and RGB images are drawn, other images as gray boxes.
+// Clipping is to the bounding boxes of the clipping paths; shadings are
+// left out.
+type thumbnailHandler struct {
+	nopContentHandler
+	img *image.RGBA
+}
+
+// Get a preview image of a template (returned from ImportPage) whose longer
+// side is maxPx pixels, e.g. for file pickers and review pages.  The
+// rasterizer (see SetRasterizer) renders it if one is set, otherwise a
+// simple built-in renderer, which is good enough for pages of vector
+// graphics and text: text is drawn as bars where its glyphs are, and only
+// 8 bit gray and RGB images are drawn, see thumbnailHandler.
+func (importer *Importer) GenerateThumbnail(tplid int, maxPx int) (image.Image, error) {
+	tpl, err := importer.getTemplate(tplid)
+	if err != nil {
+		return nil, err
+	}
+	if maxPx <= 0 {
+		return nil, errors.New("Thumbnail size must be greater than 0")
+	}
+
+	// Size of the template in points
+	w, h := tpl.W*tpl.k, tpl.H*tpl.k
+	if w <= 0 || h <= 0 {
+		return nil, errors.New("Template has no area")
+	}
+	scale := float64(maxPx) / math.Max(w, h)
+	width := int(math.Max(1, math.Round(w*scale)))
+	height := int(math.Max(1, math.Round(h*scale)))
+
+	img, err := importer.rasterizer.Rasterize(tpl, 72*scale)
+	if err == nil {
+		if b := img.Bounds(); b.Dx() > maxPx || b.Dy() > maxPx {
+			img = scaleImage(img, width, height)
+		}
+		return img, nil
+	}
+	if err != ErrNoRasterizer {
+		return nil, errors.Wrap(err, "Failed to rasterize template")
+	}
+
+	img, err = tpl.renderThumbnail(width, height, scale)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to render thumbnail")
+	}
+	return img, nil
+}
+
+// Render the template with the built-in renderer at scale pixels per point
+func (tpl *PdfTemplate) renderThumbnail(width int, height int, scale float64) (image.Image, error) {
+	if tpl.Reader == nil {
+		return nil, errors.New("Template has no source page")
+	}
+
+	n := thumbnailSupersampling
+	canvas := image.NewRGBA(image.Rect(0, 0, width*n, height*n))
+	for i := range canvas.Pix {
+		canvas.Pix[i] = 255
+	}
+	handler := &thumbnailHandler{img: canvas}
+
+	// Map the template to the canvas, flipping the y axis
+	s := scale * float64(n)
+	c, sn, tx, ty := tpl.formMatrix()
+	ctm := matrix{c, sn, -sn, c, tx * tpl.k, ty * tpl.k}.multiply(matrix{s, 0, 0, -s, 0, tpl.H * tpl.k * s})
+
+	resources := tpl.Resources
+	if resources != nil && resources.Type != PDF_TYPE_DICTIONARY {
+		resources = nil
+	}
+
+	it := newContentInterpreter(tpl.Reader, resources, ctm, handler)
+	err := it.run(tpl.Buffer)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to interpret content")
+	}
+
+	return scaleImage(canvas, width, height), nil
+}
+
+// Scale an image to width x height pixels, averaging the pixels that fall
+// on each pixel of the result
+func scaleImage(src image.Image, width int, height int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	b := src.Bounds()
+	for y := 0; y < height; y++ {
+		y0 := b.Min.Y + y*b.Dy()/height
+		y1 := b.Min.Y + (y+1)*b.Dy()/height
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0 := b.Min.X + x*b.Dx()/width
+			x1 := b.Min.X + (x+1)*b.Dx()/width
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, bl, a, count uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+cr, g+cg, bl+cb, a+ca
+					count++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{uint8(r / count >> 8), uint8(g / count >> 8), uint8(bl / count >> 8), uint8(a / count >> 8)})
+		}
+	}
+	return dst
+}
+
+// Convert color components to RGB, based on the number of components
+func colorRGB(space string, components []float64) color.RGBA {
+	clamp := func(f float64) uint8 {
+		return uint8(math.Round(math.Max(0, math.Min(1, f)) * 255))
+	}
+
+	if space == "/Pattern" {
+		return color.RGBA{128, 128, 128, 255}
+	}
+
+	switch len(components) {
+	case 1:
+		v := components[0]
+		if space == "/Separation" || space == "/DeviceN" {
+			// Tint: 1 is full ink
+			v = 1 - v
+		}
+		return color.RGBA{clamp(v), clamp(v), clamp(v), 255}
+	case 3:
+		return color.RGBA{clamp(components[0]), clamp(components[1]), clamp(components[2]), 255}
+	case 4:
+		k := components[3]
+		return color.RGBA{clamp((1 - components[0]) * (1 - k)), clamp((1 - components[1]) * (1 - k)), clamp((1 - components[2]) * (1 - k)), 255}
+	}
+
+	return color.RGBA{0, 0, 0, 255}
+}
+
+// Get the pixels of the canvas that may be painted: the intersection of the
+// bounding boxes of the clipping paths
+func (handler *thumbnailHandler) clip(gs *graphicsState) image.Rectangle {
+	r := handler.img.Bounds()
+	for _, c := range gs.clips {
+		if b, ok := pathBounds(c.path); ok {
+			r = r.Intersect(image.Rect(int(math.Floor(b[0])), int(math.Floor(b[1])), int(math.Ceil(b[2])), int(math.Ceil(b[3]))))
+		}
+	}
+	return r
+}
+
+// Blend a color into a pixel of the canvas
+func (handler *thumbnailHandler) blend(x int, y int, c color.RGBA, alpha float64) {
+	i := handler.img.PixOffset(x, y)
+	pix := handler.img.Pix[i : i+3 : i+3]
+	pix[0] = uint8(float64(pix[0])*(1-alpha) + float64(c.R)*alpha)
+	pix[1] = uint8(float64(pix[1])*(1-alpha) + float64(c.G)*alpha)
+	pix[2] = uint8(float64(pix[2])*(1-alpha) + float64(c.B)*alpha)
+}
+
+// Fill polygons in device space with a color, by the nonzero winding rule or
+// the even-odd rule
+func (handler *thumbnailHandler) fill(gs *graphicsState, polygons [][]point, evenOdd bool, c color.RGBA, alpha float64) {
+	if alpha <= 0 {
+		return
+	}
+
+	type edge struct {
+		x0, y0, x1, y1 float64
+		dir            int
+	}
+	edges := make([]edge, 0)
+	minY, maxY := math.Inf(1), math.Inf(-1)
+	for _, polygon := range polygons {
+		for i := range polygon {
+			p, q := polygon[i], polygon[(i+1)%len(polygon)]
+			if p.y == q.y {
+				continue
+			}
+			e := edge{p.x, p.y, q.x, q.y, 1}
+			if p.y > q.y {
+				e = edge{q.x, q.y, p.x, p.y, -1}
+			}
+			edges = append(edges, e)
+			minY, maxY = math.Min(minY, e.y0), math.Max(maxY, e.y1)
+		}
+	}
+	if len(edges) == 0 {
+		return
+	}
+
+	clip := handler.clip(gs)
+	y0 := int(math.Max(float64(clip.Min.Y), math.Floor(minY)))
+	y1 := int(math.Min(float64(clip.Max.Y), math.Ceil(maxY)))
+
+	type crossing struct {
+		x   float64
+		dir int
+	}
+	crossings := make([]crossing, 0)
+	for y := y0; y < y1; y++ {
+		// Sample at the pixel centers
+		sy := float64(y) + 0.5
+		crossings = crossings[:0]
+		for _, e := range edges {
+			if sy < e.y0 || sy >= e.y1 {
+				continue
+			}
+			crossings = append(crossings, crossing{e.x0 + (sy-e.y0)*(e.x1-e.x0)/(e.y1-e.y0), e.dir})
+		}
+		// There are few crossings on a row, mostly
+		for i := 1; i < len(crossings); i++ {
+			for j := i; j > 0 && crossings[j].x < crossings[j-1].x; j-- {
+				crossings[j], crossings[j-1] = crossings[j-1], crossings[j]
+			}
+		}
+
+		winding := 0
+		for i := 0; i+1 < len(crossings); i++ {
+			winding += crossings[i].dir
+			inside := winding != 0
+			if evenOdd {
+				inside = (i+1)%2 == 1
+			}
+			if !inside {
+				continue
+			}
+
+			x0 := int(math.Max(float64(clip.Min.X), math.Ceil(crossings[i].x-0.5)))
+			x1 := int(math.Min(float64(clip.Max.X), math.Ceil(crossings[i+1].x-0.5)))
+			for x := x0; x < x1; x++ {
+				handler.blend(x, y, c, alpha)
+			}
+		}
+	}
+}
+
+// Flatten a path into polygons, one for each subpath
+func flattenPath(path []pathSegment) [][]point {
+	polygons := make([][]point, 0)
+	var current []point
+	for _, seg := range path {
+		switch seg.op {
+		case 'm':
+			if len(current) > 0 {
+				polygons = append(polygons, current)
+			}
+			current = []point{seg.pts[0]}
+		case 'l':
+			current = append(current, seg.pts[0])
+		case 'c':
+			if len(current) == 0 {
+				current = []point{seg.pts[0]}
+			}
+			p0 := current[len(current)-1]
+			for i := 1; i <= thumbnailCurveSegments; i++ {
+				t := float64(i) / thumbnailCurveSegments
+				u := 1 - t
+				current = append(current, point{
+					u*u*u*p0.x + 3*u*u*t*seg.pts[0].x + 3*u*t*t*seg.pts[1].x + t*t*t*seg.pts[2].x,
+					u*u*u*p0.y + 3*u*u*t*seg.pts[0].y + 3*u*t*t*seg.pts[1].y + t*t*t*seg.pts[2].y,
+				})
+			}
+		case 'h':
+			if len(current) > 0 {
+				polygons = append(polygons, current)
+				// A path continues from the start of a closed subpath
+				current = []point{current[0]}
+			}
+		}
+	}
+	if len(current) > 1 {
+		polygons = append(polygons, current)
+	}
+	return polygons
+}
+
+// Get the outline of a stroked path as polygons, a quadrilateral for each
+// segment, all with the same orientation so that they add up
+func strokePolygons(path []pathSegment, width float64) [][]point {
+	result := make([][]point, 0)
+	closed := false
+	for _, seg := range path {
+		if seg.op == 'h' {
+			closed = true
+		}
+	}
+
+	d := width / 2
+	for _, polygon := range flattenPath(path) {
+		n := len(polygon) - 1
+		if closed {
+			n = len(polygon)
+		}
+		for i := 0; i < n; i++ {
+			p, q := polygon[i], polygon[(i+1)%len(polygon)]
+			dx, dy := q.x-p.x, q.y-p.y
+			length := math.Hypot(dx, dy)
+			if length == 0 {
+				continue
+			}
+			// Extend the segment by half the line width, which joins segments
+			nx, ny := -dy/length*d, dx/length*d
+			ex, ey := dx/length*d, dy/length*d
+			result = append(result, []point{
+				{p.x - ex + nx, p.y - ey + ny},
+				{q.x + ex + nx, q.y + ey + ny},
+				{q.x + ex - nx, q.y + ey - ny},
+				{p.x - ex - nx, p.y - ey - ny},
+			})
+		}
+	}
+	return result
+}
+
+func (handler *thumbnailHandler) paintPath(it *contentInterpreter, path []pathSegment, op string) {
+	if op == "n" || len(path) == 0 {
+		return
+	}
+
+	gs := it.gs
+	fill := op != "S" && op != "s"
+	stroke := op == "S" || op == "s" || op == "B" || op == "B*" || op == "b" || op == "b*"
+
+	if fill {
+		evenOdd := op == "f*" || op == "B*" || op == "b*"
+		handler.fill(gs, flattenPath(path), evenOdd, colorRGB(gs.fillSpace, gs.fillColor), gs.fillAlpha)
+	}
+	if stroke {
+		// Lines are at least a pixel wide
+		width := math.Max(gs.lineWidth*gs.ctm.scale(), thumbnailSupersampling)
+		handler.fill(gs, strokePolygons(path, width), false, colorRGB(gs.strokeSpace, gs.strokeColor), gs.strokeAlpha)
+	}
+}
+
+func (handler *thumbnailHandler) showText(it *contentInterpreter, run *textRun) {
+	gs := it.gs
+
+	// Invisible and clip-only text
+	if gs.render == 3 || gs.render == 7 {
+		return
+	}
+
+	space, components, alpha := gs.fillSpace, gs.fillColor, gs.fillAlpha
+	if gs.render == 1 || gs.render == 5 {
+		space, components, alpha = gs.strokeSpace, gs.strokeColor, gs.strokeAlpha
+	}
+
+	// A bar as high as lowercase letters for each glyph, advancing as
+	// showString does
+	font := run.font
+	bars := make([][]point, 0, len(run.codes))
+	x := 0.0
+	for _, code := range run.codes {
+		advance := font.width(code) / 1000 * run.fontSize
+		tx := advance + gs.charSpacing
+		if !font.twoByte && code == 32 {
+			tx += gs.wordSpacing
+		} else if advance > 0 {
+			x1 := x + advance*0.85*run.hScale/100
+			bars = append(bars, []point{
+				run.start.transform(x, run.rise),
+				run.start.transform(x1, run.rise),
+				run.start.transform(x1, run.rise+0.5*run.fontSize),
+				run.start.transform(x, run.rise+0.5*run.fontSize),
+			})
+		}
+		x += tx * run.hScale / 100
+	}
+
+	handler.fill(gs, bars, false, colorRGB(space, components), alpha)
+}
+
+func (handler *thumbnailHandler) drawImage(it *contentInterpreter, img *PdfValue, inline *ContentOperation) {
+	gs := it.gs
+
+	// The image is drawn into the unit square, with its first row at the top
+	m := gs.ctm
+	corners := []point{m.transform(0, 0), m.transform(1, 0), m.transform(1, 1), m.transform(0, 1)}
+
+	var pixels []byte
+	width, height, components := 0, 0, 0
+	if img != nil {
+		dict := img.Value.Dictionary
+		if mask := dict["/ImageMask"]; mask != nil && mask.Bool {
+			// Stencil masks paint with the fill color
+			handler.fill(gs, [][]point{corners}, false, colorRGB(gs.fillSpace, gs.fillColor), gs.fillAlpha)
+			return
+		}
+
+		width = imageDictInt(dict, "/Width", "/W")
+		height = imageDictInt(dict, "/Height", "/H")
+		components = it.reader.imageComponents(dict["/ColorSpace"])
+		if width > 0 && height > 0 && components > 0 {
+			pixels, _ = it.reader.imagePixels(img, width, height, components)
+		}
+	}
+	if pixels == nil {
+		handler.fill(gs, [][]point{corners}, false, color.RGBA{0xd3, 0xd3, 0xd3, 255}, gs.fillAlpha)
+		return
+	}
+
+	// Sample the image at the canvas pixels it covers
+	box, _ := pathBounds([]pathSegment{{'m', corners}})
+	r := image.Rect(int(math.Floor(box[0])), int(math.Floor(box[1])), int(math.Ceil(box[2])), int(math.Ceil(box[3]))).Intersect(handler.clip(gs))
+	inverse := m.inverse()
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			p := inverse.transform(float64(x)+0.5, float64(y)+0.5)
+			if p.x < 0 || p.x >= 1 || p.y <= 0 || p.y > 1 {
+				continue
+			}
+			i := (int((1-p.y)*float64(height))*width + int(p.x*float64(width))) * components
+			if i+components > len(pixels) {
+				continue
+			}
+
+			c := color.RGBA{pixels[i], pixels[i], pixels[i], 255}
+			if components == 3 {
+				c = color.RGBA{pixels[i], pixels[i+1], pixels[i+2], 255}
+			}
+			handler.blend(x, y, c, gs.fillAlpha)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package gofpdi
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// Regions are not tiled with more tiles than this
+const maxTiles = 100000
+
+// The values returned by UseTemplate for one placement of a template
+type TemplateUse struct {
+	Name   string
+	ScaleX float64
+	ScaleY float64
+	TX     float64
+	TY     float64
+	// The units of the values, see UseTemplateUnits
+	Units TemplateUnits
+}
+
+// Get the placements that repeat template tplid (returned from ImportPage) as
+// tiles of tileW by tileH over the w by h region at x, y, e.g. for background
+// textures or repeated security marks.  The tiles start at the top left corner
+// of the region (bottom left with OriginBottomLeft); if one of tileW and tileH
+// is 0, it is calculated from the other keeping the aspect ratio.  Tiles at the
+// right and bottom edges extend beyond the region; TileTemplate makes a
+// template that is clipped to it.
+func (importer *Importer) UseTemplateTiled(tplid int, x float64, y float64, w float64, h float64, tileW float64, tileH float64) ([]TemplateUse, error) {
+	placements, err := importer.tilePlacements(tplid, w, h, tileW, tileH)
+	if err != nil {
+		return nil, err
+	}
+	units, err := importer.GetTemplateUnits(tplid)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]TemplateUse, 0, len(placements))
+	for _, placement := range placements {
+		name, scaleX, scaleY, tx, ty := importer.UseTemplate(tplid, x+placement.X, y+placement.Y, placement.W, placement.H)
+		result = append(result, TemplateUse{Name: name, ScaleX: scaleX, ScaleY: scaleY, TX: tx, TY: ty, Units: units})
+	}
+
+	return result, nil
+}
+
+// Create a template of w by h that repeats template tplid as tiles of tileW by
+// tileH, clipped to its size, see UseTemplateTiled.  The tiles are drawn by a
+// single form xobject, see ComposeTemplates.
+func (importer *Importer) TileTemplate(tplid int, w float64, h float64, tileW float64, tileH float64) (int, error) {
+	placements, err := importer.tilePlacements(tplid, w, h, tileW, tileH)
+	if err != nil {
+		return -1, err
+	}
+
+	return importer.ComposeTemplates(w, h, placements)
+}
+
+// Get the placements of the tiles covering a w by h region, relative to its corner
+func (importer *Importer) tilePlacements(tplid int, w float64, h float64, tileW float64, tileH float64) ([]TemplatePlacement, error) {
+	if w <= 0 || h <= 0 {
+		return nil, errors.New("Region to tile is empty")
+	}
+
+	tileW, tileH, err := importer.GetTemplateSize(tplid, tileW, tileH)
+	if err != nil {
+		return nil, err
+	}
+	if !(tileW > 0) || !(tileH > 0) {
+		return nil, errors.New("Tile size is empty")
+	}
+
+	// Tiles that only touch the region because of rounding are left out
+	const epsilon = 1e-9
+	cols := int(math.Ceil(w/tileW - epsilon))
+	rows := int(math.Ceil(h/tileH - epsilon))
+	if float64(cols)*float64(rows) > maxTiles {
+		return nil, errors.New(fmt.Sprintf("Too many tiles: %d x %d", cols, rows))
+	}
+
+	placements := make([]TemplatePlacement, 0, cols*rows)
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			placements = append(placements, TemplatePlacement{
+				TplId: tplid,
+				X:     float64(col) * tileW,
+				Y:     float64(row) * tileH,
+				W:     tileW,
+				H:     tileH,
+			})
+		}
+	}
+
+	return placements, nil
+}
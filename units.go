@@ -0,0 +1,68 @@
+package gofpdi
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Points per inch, the resolution of pdf coordinates
+const PointsPerInch = 72
+
+// The units of a template placement, so that code converting its
+// coordinates to pixels or millimeters uses the scale factor the template
+// was imported with, see UseTemplateUnits
+type TemplateUnits struct {
+	// Points per user unit (see SetK) when the template was imported: the x,
+	// y, w and h of UseTemplate are in user units, its tx and ty and the
+	// translations of the matrices of UseTemplateMatrix in points
+	K float64
+	// The /UserUnit of the source page, the size of its units in points; 1
+	// for most pages and for templates that are not imported pages.  The
+	// template keeps the units of the source page, so its real-world size is
+	// UserUnit times its size in points, see AddTemplatePage.
+	UserUnit float64
+}
+
+// Convert a length in user units to points
+func (units TemplateUnits) Points(length float64) float64 {
+	return length * units.K
+}
+
+// Convert a length in user units to pixels at dpi dots per inch
+func (units TemplateUnits) Pixels(length float64, dpi float64) float64 {
+	return length * units.K / PointsPerInch * dpi
+}
+
+// Convert a length in user units to millimeters
+func (units TemplateUnits) Millimeters(length float64) float64 {
+	return length * units.K / PointsPerInch * 25.4
+}
+
+// Get the units of template tplid (returned from ImportPage), e.g. for the
+// placements of UseTemplateMatrix and UseTemplatePlacement
+func (importer *Importer) GetTemplateUnits(tplid int) (TemplateUnits, error) {
+	tpl, err := importer.getTemplate(tplid)
+	if err != nil {
+		return TemplateUnits{}, err
+	}
+
+	units := TemplateUnits{K: tpl.k, UserUnit: 1}
+	if tpl.Reader != nil && tpl.PageNo > 0 {
+		units.UserUnit, err = tpl.Reader.getPageUserUnit(tpl.PageNo)
+		if err != nil {
+			return TemplateUnits{}, errors.Wrap(err, "Failed to get user unit")
+		}
+	}
+	return units, nil
+}
+
+// Like UseTemplate, but returns the placement with the units it is in, see
+// TemplateUnits
+func (importer *Importer) UseTemplateUnits(tplid int, x float64, y float64, w float64, h float64) (TemplateUse, error) {
+	units, err := importer.GetTemplateUnits(tplid)
+	if err != nil {
+		return TemplateUse{}, err
+	}
+
+	name, scaleX, scaleY, tx, ty := importer.UseTemplate(tplid, x, y, w, h)
+	return TemplateUse{Name: name, ScaleX: scaleX, ScaleY: scaleY, TX: tx, TY: ty, Units: units}, nil
+}
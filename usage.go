@@ -0,0 +1,48 @@
+package gofpdi
+
+import (
+	"sort"
+)
+
+// Only write the form xobjects of templates that have been drawn, see
+// Importer.SetSkipUnusedTemplates
+func (pdfWriter *PdfWriter) SetSkipUnusedTemplates(b bool) {
+	pdfWriter.skip_unused = b
+}
+
+// Count a template as drawn, with the templates a composite template draws
+func (tpl *PdfTemplate) markUsed() {
+	if tpl.used {
+		return
+	}
+	tpl.used = true
+	for _, part := range tpl.parts {
+		part.tpl.markUsed()
+	}
+}
+
+// Get the ids of the templates (returned from ImportPage) that have not been
+// drawn with UseTemplate (or a function that calls it, e.g.
+// Document.AddTemplatePage), in ascending order.  Templates that are only
+// drawn by composite templates are used when a composite that draws them is.
+func (importer *Importer) GetUnusedTemplates() []int {
+	res := make([]int, 0)
+	for tplid, tplInfo := range importer.tplMap {
+		if !tplInfo.Writer.tpls[tplInfo.TemplateId].used {
+			res = append(res, tplid)
+		}
+	}
+	sort.Ints(res)
+	return res
+}
+
+// Skip the form xobjects of templates that have not been drawn with
+// UseTemplate when putting them (PutFormXobjects and the functions like it,
+// and Document.WriteTo), so pages that were imported but not placed add
+// nothing to the output, and neither do the objects only they refer to.
+// Skipped templates are written by a later call once they are used.
+func (importer *Importer) SetSkipUnusedTemplates(b bool) {
+	importer.setWriterOptions(func(options *writerOptions) {
+		options.skip_unused = b
+	})
+}
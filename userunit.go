@@ -0,0 +1,43 @@
+package gofpdi
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// The largest width or height of a page in user space units
+const maxPageSize = 14400
+
+// Set the user unit (the size of a user space unit in points) of pages that
+// are larger than 14400 points, the largest page size in user space units.
+// 0 (the default) chooses the smallest whole number of points that makes
+// them fit.  Pages of sources with a /UserUnit keep theirs (see
+// AddTemplatePage); smaller pages are written in points.
+func (doc *Document) SetUserUnit(u float64) error {
+	if u < 0 || math.IsNaN(u) || math.IsInf(u, 0) {
+		return errors.New(fmt.Sprintf("Invalid user unit: %g", u))
+	}
+	doc.userUnit = u
+	return nil
+}
+
+// Get the factor by which the coordinates of a page are divided to make it
+// fit into the largest page size, and the user unit it is written with
+func (doc *Document) pageScale(page *documentPage) (float64, float64, error) {
+	size := math.Max(page.w, page.h)
+	if size <= maxPageSize {
+		return 1, page.userUnit, nil
+	}
+
+	scale := doc.userUnit
+	if scale == 0 {
+		scale = math.Ceil(size / maxPageSize)
+	}
+	if size/scale > maxPageSize {
+		return 0, 0, errors.New(fmt.Sprintf("%.0F points do not fit into %d units of %g points", size, maxPageSize, scale))
+	}
+
+	return scale, page.userUnit * scale, nil
+}
@@ -0,0 +1,299 @@
+package gofpdi
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Returned by PdfValue.GetKey when a dictionary does not contain the key
+var ErrKeyNotFound = errors.New("Key not found")
+
+// Returned (wrapped) by the PdfValue accessors when a value has another type
+// than the accessor expects
+var ErrWrongType = errors.New("Value has the wrong type")
+
+// Get a readable name of the type of a value, e.g. "dictionary"
+func (value *PdfValue) TypeName() string {
+	if value == nil {
+		return "nothing"
+	}
+
+	switch value.Type {
+	case PDF_TYPE_NULL:
+		return "null"
+	case PDF_TYPE_NUMERIC:
+		return "integer"
+	case PDF_TYPE_TOKEN:
+		if strings.HasPrefix(value.Token, "/") {
+			return "name"
+		}
+		return "token"
+	case PDF_TYPE_HEX, PDF_TYPE_STRING:
+		return "string"
+	case PDF_TYPE_DICTIONARY:
+		return "dictionary"
+	case PDF_TYPE_ARRAY:
+		return "array"
+	case PDF_TYPE_OBJDEC:
+		return "object declaration"
+	case PDF_TYPE_OBJREF:
+		return "object reference"
+	case PDF_TYPE_OBJECT:
+		return "object"
+	case PDF_TYPE_STREAM:
+		return "stream"
+	case PDF_TYPE_BOOLEAN:
+		return "boolean"
+	case PDF_TYPE_REAL:
+		return "real"
+	}
+
+	return fmt.Sprintf("unknown type %d", value.Type)
+}
+
+func (value *PdfValue) wrongType(expected string) error {
+	return errors.Wrap(ErrWrongType, fmt.Sprintf("Expected %s, got %s", expected, value.TypeName()))
+}
+
+// Get the direct value of an indirect object, as returned by
+// PdfReader.Resolve.  Other values are returned as is.
+func (value *PdfValue) direct() *PdfValue {
+	if value != nil && value.Type == PDF_TYPE_OBJECT {
+		return value.Value
+	}
+	return value
+}
+
+// Determine if a value is null, or missing
+func (value *PdfValue) IsNull() bool {
+	value = value.direct()
+	return value == nil || value.Type == PDF_TYPE_NULL
+}
+
+// Determine if a value is an object reference, which can be resolved with
+// PdfReader.Resolve
+func (value *PdfValue) IsRef() bool {
+	return value != nil && value.Type == PDF_TYPE_OBJREF
+}
+
+// Get the object number and generation of an object reference
+func (value *PdfValue) Ref() (int, int, error) {
+	if !value.IsRef() {
+		return 0, 0, value.wrongType("object reference")
+	}
+	return value.Id, value.Gen, nil
+}
+
+// Get the entries of a dictionary, or of the dictionary of a stream
+func (value *PdfValue) Dict() (map[string]*PdfValue, error) {
+	value = value.direct()
+	if value != nil && value.Type == PDF_TYPE_STREAM {
+		value = value.Value
+	}
+	if value == nil || value.Type != PDF_TYPE_DICTIONARY {
+		return nil, value.wrongType("dictionary")
+	}
+	return value.Dictionary, nil
+}
+
+// Get an entry of a dictionary or stream dictionary.  The key may be given
+// with or without the leading slash, e.g. "/Font" or "Font".  Returns an error
+// wrapping ErrKeyNotFound if there is no such entry.
+func (value *PdfValue) GetKey(key string) (*PdfValue, error) {
+	dict, err := value.Dict()
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(key, "/") {
+		key = "/" + key
+	}
+
+	entry, ok := dict[key]
+	if !ok {
+		return nil, errors.Wrap(ErrKeyNotFound, key)
+	}
+	return entry, nil
+}
+
+// Determine if a dictionary or stream dictionary has an entry
+func (value *PdfValue) HasKey(key string) bool {
+	_, err := value.GetKey(key)
+	return err == nil
+}
+
+// Get the keys of a dictionary or stream dictionary, sorted
+func (value *PdfValue) Keys() ([]string, error) {
+	dict, err := value.Dict()
+	if err != nil {
+		return nil, err
+	}
+
+	return sortedKeys(dict), nil
+}
+
+// Get the number of elements of an array
+func (value *PdfValue) ArrayLen() (int, error) {
+	value = value.direct()
+	if value == nil || value.Type != PDF_TYPE_ARRAY {
+		return 0, value.wrongType("array")
+	}
+	return len(value.Array), nil
+}
+
+// Get element i of an array
+func (value *PdfValue) Index(i int) (*PdfValue, error) {
+	n, err := value.ArrayLen()
+	if err != nil {
+		return nil, err
+	}
+	if i < 0 || i >= n {
+		return nil, errors.New(fmt.Sprintf("Index %d out of range for array of length %d", i, n))
+	}
+	return value.direct().Array[i], nil
+}
+
+// Get the value of an integer.  Reals are accepted if they have no fractional part.
+func (value *PdfValue) AsInt() (int, error) {
+	value = value.direct()
+	if value != nil {
+		switch value.Type {
+		case PDF_TYPE_NUMERIC:
+			return value.Int, nil
+		case PDF_TYPE_REAL:
+			if value.Real == math.Trunc(value.Real) && math.Abs(value.Real) <= math.MaxInt32 {
+				return int(value.Real), nil
+			}
+		}
+	}
+	return 0, value.wrongType("integer")
+}
+
+// Get the value of a number, integer or real
+func (value *PdfValue) AsFloat() (float64, error) {
+	value = value.direct()
+	if value != nil {
+		switch value.Type {
+		case PDF_TYPE_NUMERIC:
+			return float64(value.Int), nil
+		case PDF_TYPE_REAL:
+			return value.Real, nil
+		}
+	}
+	return 0, value.wrongType("number")
+}
+
+// Get a name, including the leading slash (e.g. "/Type1"), with any #xx
+// escapes decoded
+func (value *PdfValue) AsName() (string, error) {
+	value = value.direct()
+	if value == nil || value.Type != PDF_TYPE_TOKEN || !strings.HasPrefix(value.Token, "/") {
+		return "", value.wrongType("name")
+	}
+	return value.Token, nil
+}
+
+// Get the value of a boolean
+func (value *PdfValue) AsBool() (bool, error) {
+	value = value.direct()
+	if value == nil || value.Type != PDF_TYPE_BOOLEAN {
+		return false, value.wrongType("boolean")
+	}
+	return value.Bool, nil
+}
+
+// Get the bytes of a literal or hex string, with escape sequences decoded
+func (value *PdfValue) AsBytes() ([]byte, error) {
+	value = value.direct()
+	if value == nil || (value.Type != PDF_TYPE_STRING && value.Type != PDF_TYPE_HEX) {
+		return nil, value.wrongType("string")
+	}
+	return stringBytes(value), nil
+}
+
+// Get a text string (like the entries of the document information
+// dictionary) decoded from PDFDocEncoding or UTF-16
+func (value *PdfValue) AsText() (string, error) {
+	b, err := value.AsBytes()
+	if err != nil {
+		return "", err
+	}
+	return decodeTextString(b), nil
+}
+
+// Get the (encoded) data of a stream object, as returned by Resolve for a
+// reference to a stream
+func (pdfReader *PdfReader) StreamData(value *PdfValue) ([]byte, error) {
+	if value == nil || value.Type != PDF_TYPE_STREAM || value.Stream == nil {
+		return nil, value.wrongType("stream")
+	}
+	return pdfReader.streamData(value)
+}
+
+// Resolve value if it is an object reference.  Streams are returned as the
+// stream object, other objects as their direct value; values that are not
+// references are returned as is.
+func (pdfReader *PdfReader) Resolve(value *PdfValue) (*PdfValue, error) {
+	return pdfReader.resolveDirect(value)
+}
+
+// Get object id, generation gen of the source, also if it is in an object
+// stream, e.g. for an inspector that follows references by number.  Streams
+// are returned as the stream object, whose data StreamData reads; other
+// objects as their direct value.
+func (pdfReader *PdfReader) GetObject(id int, gen int) (*PdfValue, error) {
+	obj, err := pdfReader.resolveDirect(&PdfValue{Type: PDF_TYPE_OBJREF, Id: id, Gen: gen})
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("Failed to get object %d %d", id, gen))
+	}
+	return obj, nil
+}
+
+// Get the trailer dictionary, the starting point for navigating the objects
+// of the document, e.g. GetKey("/Root") followed by Resolve
+func (pdfReader *PdfReader) GetTrailer() *PdfValue {
+	return pdfReader.trailer
+}
+
+// Get the document catalog (the /Root of the trailer), e.g. to read entries
+// such as /ViewerPreferences, /OpenAction or /AcroForm with ResolveKey
+func (pdfReader *PdfReader) GetCatalog() *PdfValue {
+	if pdfReader.catalog == nil {
+		return nil
+	}
+	return pdfReader.catalog.Value
+}
+
+// Get an entry of a dictionary or stream dictionary (see PdfValue.GetKey),
+// resolved if it is an object reference (see Resolve)
+func (pdfReader *PdfReader) ResolveKey(value *PdfValue, key string) (*PdfValue, error) {
+	entry, err := value.GetKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pdfReader.resolveDirect(entry)
+}
+
+// Call fn with the dictionary of every page, in page order, e.g. to collect
+// the annotations of each page or audit page sizes.  The dictionaries belong
+// to the reader and must not be changed.  Attributes a page inherits from
+// the page tree (such as /MediaBox or /Resources) are found through /Parent,
+// or with Importer.GetPageSizes for the boxes.  An error returned by fn stops
+// the walk and is returned as is.
+func (pdfReader *PdfReader) WalkPages(fn func(pageno int, pageDict *PdfValue) error) error {
+	for pageno := 1; pageno <= len(pdfReader.pages); pageno++ {
+		page, err := pdfReader.getPage(pageno)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("Failed to get page %d", pageno))
+		}
+
+		err = fn(pageno, page.Value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
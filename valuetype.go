@@ -0,0 +1,144 @@
+package gofpdi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The type of a PdfValue, see PdfValue.ValueType.  The values are those of
+// the PDF_TYPE_ constants, which PdfValue.Type holds.
+type PdfValueType int
+
+const (
+	TypeNull              PdfValueType = PDF_TYPE_NULL
+	TypeNumeric           PdfValueType = PDF_TYPE_NUMERIC
+	TypeToken             PdfValueType = PDF_TYPE_TOKEN
+	TypeHex               PdfValueType = PDF_TYPE_HEX
+	TypeString            PdfValueType = PDF_TYPE_STRING
+	TypeDictionary        PdfValueType = PDF_TYPE_DICTIONARY
+	TypeArray             PdfValueType = PDF_TYPE_ARRAY
+	TypeObjectDeclaration PdfValueType = PDF_TYPE_OBJDEC
+	TypeObjectReference   PdfValueType = PDF_TYPE_OBJREF
+	TypeObject            PdfValueType = PDF_TYPE_OBJECT
+	TypeStream            PdfValueType = PDF_TYPE_STREAM
+	TypeBoolean           PdfValueType = PDF_TYPE_BOOLEAN
+	TypeReal              PdfValueType = PDF_TYPE_REAL
+)
+
+// Names of the value types, by type
+var valueTypeNames = map[PdfValueType]string{
+	TypeNull:              "Null",
+	TypeNumeric:           "Numeric",
+	TypeToken:             "Token",
+	TypeHex:               "Hex",
+	TypeString:            "String",
+	TypeDictionary:        "Dictionary",
+	TypeArray:             "Array",
+	TypeObjectDeclaration: "ObjectDeclaration",
+	TypeObjectReference:   "ObjectReference",
+	TypeObject:            "Object",
+	TypeStream:            "Stream",
+	TypeBoolean:           "Boolean",
+	TypeReal:              "Real",
+}
+
+// Get the name of the type, e.g. "Dictionary"
+func (t PdfValueType) String() string {
+	if name, ok := valueTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("PdfValueType(%d)", int(t))
+}
+
+// Get the type of a value, TypeNull for nil
+func (value *PdfValue) ValueType() PdfValueType {
+	if value == nil {
+		return TypeNull
+	}
+	return PdfValueType(value.Type)
+}
+
+// Create a null value
+func NewNull() *PdfValue {
+	return &PdfValue{Type: PDF_TYPE_NULL}
+}
+
+// Create a name, with or without the leading slash, e.g. "XObject" or
+// "/XObject".  Characters that cannot appear in names literally are escaped
+// when the name is written.
+func NewName(name string) *PdfValue {
+	if !strings.HasPrefix(name, "/") {
+		name = "/" + name
+	}
+	return &PdfValue{Type: PDF_TYPE_TOKEN, Token: name}
+}
+
+// Create an integer
+func NewInt(v int) *PdfValue {
+	return &PdfValue{Type: PDF_TYPE_NUMERIC, Int: v}
+}
+
+// Create a real number
+func NewReal(v float64) *PdfValue {
+	return &PdfValue{Type: PDF_TYPE_REAL, Real: v}
+}
+
+// Create a boolean
+func NewBool(v bool) *PdfValue {
+	return &PdfValue{Type: PDF_TYPE_BOOLEAN, Bool: v}
+}
+
+// Create a literal string of the bytes of s, which are escaped as needed
+func NewString(s string) *PdfValue {
+	return &PdfValue{Type: PDF_TYPE_STRING, String: escapeLiteralString(s)}
+}
+
+// Create a hex string of b, e.g. for binary data such as the /ID of a document
+func NewHexString(b []byte) *PdfValue {
+	return &PdfValue{Type: PDF_TYPE_HEX, String: fmt.Sprintf("%X", b)}
+}
+
+// Create a text string, like the entries of the document information
+// dictionary: in PDFDocEncoding if s is ASCII, in UTF-16 otherwise
+func NewText(s string) *PdfValue {
+	return encodeTextString(s)
+}
+
+// Create an array of values
+func NewArray(values ...*PdfValue) *PdfValue {
+	if values == nil {
+		values = make([]*PdfValue, 0)
+	}
+	return &PdfValue{Type: PDF_TYPE_ARRAY, Array: values}
+}
+
+// Create a dictionary of entries, by key with or without the leading slash,
+// e.g. NewDict(map[string]*PdfValue{"Type": NewName("XObject")})
+func NewDict(entries map[string]*PdfValue) *PdfValue {
+	dict := make(map[string]*PdfValue, len(entries))
+	for key, v := range entries {
+		if !strings.HasPrefix(key, "/") {
+			key = "/" + key
+		}
+		dict[key] = v
+	}
+	return &PdfValue{Type: PDF_TYPE_DICTIONARY, Dictionary: dict}
+}
+
+// Create a reference to object id, generation gen.  In values returned by an
+// ObjectFilter the object is one of the source, which is copied like the
+// objects obj refers to.
+func NewRef(id, gen int) *PdfValue {
+	return &PdfValue{Type: PDF_TYPE_OBJREF, Id: id, Gen: gen}
+}
+
+// Create a stream of (encoded) data with dictionary dict (see NewDict), which
+// may be nil.  Streams can only be objects, e.g. returned by an ObjectFilter
+// for a stream, not entries of dictionaries or arrays.  /Length is written
+// from the data; a /Filter of dict must match how the data is encoded.
+func NewStream(dict *PdfValue, data []byte) *PdfValue {
+	if dict == nil {
+		dict = NewDict(nil)
+	}
+	return &PdfValue{Type: PDF_TYPE_STREAM, Value: dict, Stream: &PdfValue{Type: PDF_TYPE_STREAM, Bytes: data}}
+}
@@ -0,0 +1,65 @@
+package gofpdi
+
+import (
+	"bytes"
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+// Header versions a Document can be written with
+var pdfVersions = []string{"1.4", "1.5", "1.6", "1.7", "2.0"}
+
+// Names that need a header version above 1.4 (the lowest version written,
+// which covers transparency), with the version they need
+var versionFeatures = []struct {
+	version string
+	names   *regexp.Regexp
+}{
+	// Encryption with 256 bit AES
+	{"2.0", regexp.MustCompile(`/AESV3\b`)},
+	// Encryption with 128 bit AES, user units, OpenType font files
+	{"1.6", regexp.MustCompile(`/(AESV2|UserUnit|OpenType)\b`)},
+	// JPEG 2000 images, optional content, object and cross-reference streams
+	{"1.5", regexp.MustCompile(`/(JPXDecode|OC|OCG|OCMD|OCProperties|ObjStm|XRef)\b`)},
+}
+
+// Write the document with the given header version ("1.4" to "1.7", or "2.0"),
+// even if its objects need a higher version.  "" (the default) chooses the
+// lowest version that covers the features used, see GetVersion.
+func (doc *Document) SetVersion(version string) error {
+	if version != "" {
+		ok := false
+		for _, v := range pdfVersions {
+			ok = ok || v == version
+		}
+		if !ok {
+			return errors.New("Unsupported PDF version: " + version)
+		}
+	}
+
+	doc.version = version
+	return nil
+}
+
+// Get the header version of the file last written by WriteTo
+func (doc *Document) GetVersion() string {
+	return doc.writtenVersion
+}
+
+// Get the lowest header version that covers the features used by the
+// objects.  Only dictionaries are looked at: stream data is binary and may
+// contain anything.
+func negotiateVersion(objects map[int][]byte) string {
+	for _, feature := range versionFeatures {
+		for _, data := range objects {
+			if i := bytes.Index(data, []byte("stream\n")); i >= 0 {
+				data = data[:i]
+			}
+			if feature.names.Match(data) {
+				return feature.version
+			}
+		}
+	}
+	return pdfVersions[0]
+}
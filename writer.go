@@ -3,12 +3,15 @@ package gofpdi
 import (
 	"bufio"
 	"bytes"
-	"compress/zlib"
 	"crypto/sha1"
 	"encoding/hex"
 	"fmt"
+	"io"
 	"math"
 	"os"
+	"sort"
+	"strconv"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -17,7 +20,6 @@ type PdfWriter struct {
 	f       *os.File
 	w       *bufio.Writer
 	r       *PdfReader
-	k       float64
 	tpls    []*PdfTemplate
 	m       int
 	n       int
@@ -29,10 +31,108 @@ type PdfWriter struct {
 	don_obj_stack   map[int]*PdfValue
 	written_objs    map[*PdfObjectId][]byte
 	written_obj_pos map[*PdfObjectId]map[int]string
-	current_obj     *PdfObject
-	current_obj_id  int
-	tpl_id_offset   int
-	use_hash        bool
+	obj_hashes      map[int]string
+	// Written objects that were spilled to the scratch of spill
+	spilled_objs map[*PdfObjectId]*spilledObject
+	spill        *spillStore
+	// Form xobjects referenced by composite templates, which may have been
+	// written by other writers
+	part_objs map[string]bool
+	// Hashes of the objects written before a checkpoint the writer resumed
+	restored_objs map[string]bool
+	// Hashes of the written objects that were cleared, see ClearImportedObjects
+	cleared_objs   map[string]bool
+	current_obj    *PdfObject
+	current_obj_id int
+	tpl_id_offset  int
+	use_hash       bool
+	// What is written and how, see writerOptions
+	writerOptions
+	// Font files, ICC profiles and spot color spaces shared between the
+	// writers of an importer, by digest of their data
+	shared_streams map[string]*PdfObjectId
+	// Source objects that were replaced by an identical shared stream
+	merged_objs map[ObjectRef]*PdfObjectId
+	// The reader each written object was imported from, see ClearImportedObjectsFrom
+	written_readers map[*PdfObjectId]*PdfReader
+	image_sizes     map[int][2]float64
+	// The deadline of the current import, see writerOptions.timeout
+	deadline time.Time
+	// Whether the last token written needs a separator before the next one,
+	// see SetCompactOutput
+	separator_pending bool
+	// Sizes of the objects written, by object, see SetObjectStats
+	object_stats map[*PdfObjectId]*ObjectStat
+	// With RenumberPreserve, the ids that are used and the id before the
+	// first one that may be used
+	used_ids map[int]bool
+	first_id int
+	// Receives the written objects: the writer's own objects (see
+	// writerBackend) or the backend of PutFormXobjectsTo, and the first error
+	// it returned.  The sizes of the objects written into the latter.
+	backend      OutputBackend
+	backend_err  error
+	backend_objs map[*PdfObjectId]int
+	warnings     []string
+}
+
+// The options of a writer.  An importer keeps one set for the writers of all
+// its sources, see Importer.setWriterOptions.
+type writerOptions struct {
+	// Scale factor, see SetK, and origin of the coordinates of templates
+	k      float64
+	origin Origin
+	// Only copy the resources the content uses, see SetPruneResources
+	prune_resources bool
+	// Write identical font files, ICC profiles and spot color spaces only once
+	merge_font_files   bool
+	merge_icc_profiles bool
+	merge_spot_colors  bool
+	// Images drawn above downsample_dpi are re-encoded, see SetImageDownsampling
+	downsample_dpi float64
+	jpeg_quality   int
+	color_model    ColorModel
+	printer_marks  *PrinterMarks
+	// Crop templates to their content, see SetCropToContent
+	crop_to_content bool
+	crop_margin     float64
+	orientation     Orientation
+	// Only write templates that have been drawn, see SetSkipUnusedTemplates
+	skip_unused bool
+	// Decimals of real numbers, see SetRealPrecision
+	real_precision int
+	// What is imported for boxes a page does not define, see SetBoxFallback
+	box_fallback     BoxFallback
+	box_chain        []BoxName
+	object_filter    ObjectFilter
+	content_filter   ContentFilter
+	font_substitutes map[string]*fontSubstitute
+	strip_keys       map[string]bool
+	strip_thumbnails bool
+	strip_af         bool
+	scrub_privacy    bool
+	// Draw the appearances of annotations into templates, see SetFlattenAnnotations
+	flatten_annotations bool
+	// Receives the objects written and warnings, see SetMetrics
+	metrics Metrics
+	// Time an import may take, see Importer.SetLimits
+	timeout time.Duration
+	// Remove written objects that are no longer referenced, see removeUnreachable
+	remove_unreachable bool
+	// Flate encode streams without filter, see SetCompressStreams
+	compress_streams bool
+	// Write only the whitespace tokens need, see SetCompactOutput
+	compact_output bool
+	// Record the sizes of the objects written, see SetObjectStats
+	record_object_stats bool
+	// Write the same bytes for the same input, see SetReproducible
+	reproducible bool
+	// Write dictionary keys in sorted order, see SetSortKeys
+	sort_keys bool
+	// Numbering of imported objects, see SetRenumbering
+	renumbering Renumbering
+	// Allocator of output object ids, see SetObjectIdAllocator
+	id_allocator func() int
 }
 
 type PdfObjectId struct {
@@ -56,15 +156,96 @@ func (pdfWriter *PdfWriter) Init() {
 	pdfWriter.tpls = make([]*PdfTemplate, 0)
 	pdfWriter.written_objs = make(map[*PdfObjectId][]byte, 0)
 	pdfWriter.written_obj_pos = make(map[*PdfObjectId]map[int]string, 0)
+	pdfWriter.obj_hashes = make(map[int]string, 0)
+	pdfWriter.spilled_objs = make(map[*PdfObjectId]*spilledObject, 0)
+	pdfWriter.written_readers = make(map[*PdfObjectId]*PdfReader, 0)
+	pdfWriter.part_objs = make(map[string]bool, 0)
+	pdfWriter.restored_objs = make(map[string]bool, 0)
+	pdfWriter.cleared_objs = make(map[string]bool, 0)
+	pdfWriter.used_ids = make(map[int]bool, 0)
 	pdfWriter.current_obj = new(PdfObject)
+	pdfWriter.shared_streams = make(map[string]*PdfObjectId, 0)
+	pdfWriter.merged_objs = make(map[ObjectRef]*PdfObjectId, 0)
+	pdfWriter.image_sizes = make(map[int][2]float64, 0)
+	pdfWriter.backend = &writerBackend{writer: pdfWriter}
+	pdfWriter.backend_objs = make(map[*PdfObjectId]int, 0)
+}
+
+// Set all options of the writer at once, see Importer.configureWriter
+func (pdfWriter *PdfWriter) setOptions(options writerOptions) {
+	pdfWriter.writerOptions = options
+	pdfWriter.SetObjectStats(options.record_object_stats)
 }
 
 func (pdfWriter *PdfWriter) SetUseHash(b bool) {
 	pdfWriter.use_hash = b
 }
 
+// Only copy the resources that are used by the content of imported pages
+func (pdfWriter *PdfWriter) SetPruneResources(b bool) {
+	pdfWriter.prune_resources = b
+}
+
+// Write identical embedded font files only once, see Importer.SetMergeFontFiles
+func (pdfWriter *PdfWriter) SetMergeFontFiles(b bool) {
+	pdfWriter.merge_font_files = b
+}
+
+// Downsample images drawn at more than dpi to JPEGs of the given quality
+// (1-100, 0 for the default), see Importer.SetImageDownsampling
+func (pdfWriter *PdfWriter) SetImageDownsampling(dpi float64, quality int) {
+	pdfWriter.downsample_dpi = dpi
+	pdfWriter.jpeg_quality = quality
+}
+
+// Convert the device colors of imported content, see Importer.SetColorModel
+func (pdfWriter *PdfWriter) SetColorModel(model ColorModel) {
+	pdfWriter.color_model = model
+}
+
+// Write identical ICC profiles only once, see Importer.SetMergeICCProfiles
+func (pdfWriter *PdfWriter) SetMergeICCProfiles(b bool) {
+	pdfWriter.merge_icc_profiles = b
+}
+
+// Set the scale factor (points per user unit) for pages imported after the call
+func (pdfWriter *PdfWriter) SetK(k float64) {
+	pdfWriter.k = k
+}
+
+// Write the same bytes for the same input, see Importer.SetReproducible
+func (pdfWriter *PdfWriter) SetReproducible(b bool) {
+	pdfWriter.reproducible = b
+}
+
+// Write dictionary keys in sorted order, see Importer.SetSortKeys
+func (pdfWriter *PdfWriter) SetSortKeys(b bool) {
+	pdfWriter.sort_keys = b
+}
+
+// Draw printer's marks around pages imported after the call, nil for none
+func (pdfWriter *PdfWriter) SetPrinterMarks(marks *PrinterMarks) {
+	pdfWriter.printer_marks = marks
+}
+
+// Set the origin of template coordinates, see Origin
+func (pdfWriter *PdfWriter) SetOrigin(origin Origin) {
+	pdfWriter.origin = origin
+}
+
 func (pdfWriter *PdfWriter) SetNextObjectID(id int) {
 	pdfWriter.n = id - 1
+	pdfWriter.first_id = id - 1
+}
+
+// Get the id after the highest object id used by the writer
+func (pdfWriter *PdfWriter) GetNextObjectID() int {
+	return pdfWriter.maxObjId() + 1
+}
+
+// Set how imported objects are numbered, see Importer.SetRenumbering
+func (pdfWriter *PdfWriter) SetRenumbering(renumbering Renumbering) {
+	pdfWriter.renumbering = renumbering
 }
 
 func NewPdfWriter(filename string) (*PdfWriter, error) {
@@ -83,12 +264,26 @@ func NewPdfWriter(filename string) (*PdfWriter, error) {
 	return writer, nil
 }
 
+// The origin of the coordinates passed to UseTemplate and ClipTemplate
+type Origin int
+
+const (
+	// y grows downwards from the top.  UseTemplate returns a translation
+	// relative to the top of the page, to which the page height must be added,
+	// as gofpdf does.
+	OriginTopLeft Origin = iota
+	// y grows upwards from the bottom, as in PDF.  UseTemplate returns a
+	// translation that can be used in a cm operator directly.
+	OriginBottomLeft
+)
+
 // Done with parsing.  Now, create templates.
 type PdfTemplate struct {
 	Id        int
 	Reader    *PdfReader
 	Resources *PdfValue
-	Buffer    string
+	Group     *PdfValue
+	Buffer    []byte
 	Box       map[string]float64
 	Boxes     map[string]map[string]float64
 	X         float64
@@ -97,10 +292,68 @@ type PdfTemplate struct {
 	H         float64
 	Rotation  int
 	N         int
+	PageNo    int
+	// Resources of the page before pruning, for nested forms that inherit them
+	pageResources *PdfValue
+	// Scale factor (points per user unit) when the page was imported
+	k float64
+	// Compressed content stream and the color model it was made for
+	stream      []byte
+	streamModel ColorModel
+	// The templates drawn by a composite template, see Importer.ComposeTemplates
+	parts []*templatePart
+	// The form xobject of the template, once written by PutFormXobjects
+	objId *PdfObjectId
+	// Name of a template written before a checkpoint, see Importer.ResumeCheckpoint
+	name string
+	// The template has been drawn, see SetSkipUnusedTemplates
+	used bool
+	// The characters of the invisible text layer by character code - 1, and
+	// the objects of its font, see Importer.AddTextLayer
+	textLayer     []rune
+	textLayerFont [4]int
+	// The box that was imported, see SetBoxFallback
+	boxName BoxName
+	// Associated files of the page (/AF), see SetStripAssociatedFiles
+	associatedFiles *PdfValue
 }
 
+// Get the written objects.  Spilled objects are read back into memory.
 func (pdfWriter *PdfWriter) GetImportedObjects() map[*PdfObjectId][]byte {
-	return pdfWriter.written_objs
+	if len(pdfWriter.spilled_objs) == 0 {
+		return pdfWriter.written_objs
+	}
+
+	objs := make(map[*PdfObjectId][]byte, len(pdfWriter.written_objs))
+	for pdfObjId := range pdfWriter.written_objs {
+		data, err := pdfWriter.objectData(pdfObjId)
+		if err != nil {
+			pdfWriter.warn(fmt.Sprintf("Object %d could not be read back: %s", pdfObjId.id, err))
+		}
+		objs[pdfObjId] = data
+	}
+	return objs
+}
+
+// A written object: its output object id, its hash (see
+// Importer.GetImportedObjectsUnordered) and its contents
+type ImportedObject struct {
+	Id   int
+	Hash string
+	Data []byte
+}
+
+// Get the written objects sorted by object id.  Spilled objects are read back into memory.
+func (pdfWriter *PdfWriter) GetImportedObjectsOrdered() []ImportedObject {
+	objs := pdfWriter.GetImportedObjects()
+
+	result := make([]ImportedObject, 0, len(objs))
+	for pdfObjId, data := range objs {
+		result = append(result, ImportedObject{Id: pdfObjId.id, Hash: pdfObjId.hash, Data: data})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Id < result[j].Id })
+
+	return result
 }
 
 // For each object (uniquely identified by a sha1 hash), return the positions
@@ -110,65 +363,175 @@ func (pdfWriter *PdfWriter) GetImportedObjHashPos() map[*PdfObjectId]map[int]str
 }
 
 func (pdfWriter *PdfWriter) ClearImportedObjects() {
+	if pdfWriter.spill != nil {
+		for _, data := range pdfWriter.written_objs {
+			pdfWriter.spill.memory -= int64(len(data))
+		}
+	}
+	for pdfObjId := range pdfWriter.written_objs {
+		pdfWriter.cleared_objs[pdfObjId.hash] = true
+	}
+	for pdfObjId := range pdfWriter.backend_objs {
+		pdfWriter.cleared_objs[pdfObjId.hash] = true
+	}
 	pdfWriter.written_objs = make(map[*PdfObjectId][]byte, 0)
+	pdfWriter.backend_objs = make(map[*PdfObjectId]int, 0)
+	pdfWriter.spilled_objs = make(map[*PdfObjectId]*spilledObject, 0)
+	pdfWriter.written_readers = make(map[*PdfObjectId]*PdfReader, 0)
+}
+
+// Clear only the written objects that were imported from reader, e.g. once
+// they have been written out, when the writer imports from several readers.
+// The objects of other readers and the rest of the writer state are kept;
+// like with ClearImportedObjects, cleared objects are not written again.
+func (pdfWriter *PdfWriter) ClearImportedObjectsFrom(reader *PdfReader) {
+	for pdfObjId, r := range pdfWriter.written_readers {
+		if r != reader {
+			continue
+		}
+
+		if data, ok := pdfWriter.written_objs[pdfObjId]; ok && pdfWriter.spill != nil {
+			pdfWriter.spill.memory -= int64(len(data))
+		}
+		pdfWriter.cleared_objs[pdfObjId.hash] = true
+		delete(pdfWriter.written_objs, pdfObjId)
+		delete(pdfWriter.written_obj_pos, pdfObjId)
+		delete(pdfWriter.spilled_objs, pdfObjId)
+		delete(pdfWriter.backend_objs, pdfObjId)
+		delete(pdfWriter.written_readers, pdfObjId)
+	}
 }
 
 // Create a PdfTemplate object from a page number (e.g. 1) and a boxName (e.g. MediaBox)
-func (pdfWriter *PdfWriter) ImportPage(reader *PdfReader, pageno int, boxName string) (int, error) {
+func (pdfWriter *PdfWriter) ImportPage(reader *PdfReader, pageno int, boxName BoxName) (int, error) {
+	tpl, err := pdfWriter.newTemplate(reader, pageno, boxName)
+	if err != nil {
+		return -1, err
+	}
+
+	return pdfWriter.addTemplate(tpl), nil
+}
+
+// Add a template and get its id
+func (pdfWriter *PdfWriter) addTemplate(tpl *PdfTemplate) int {
+	pdfWriter.tpls = append(pdfWriter.tpls, tpl)
+
+	// Return last template id
+	return len(pdfWriter.tpls) - 1
+}
+
+// Create the template of a page.  Only reads the writer's options, so it can
+// be called from several goroutines with different readers.
+func (pdfWriter *PdfWriter) newTemplate(reader *PdfReader, pageno int, boxName BoxName) (*PdfTemplate, error) {
 	var err error
 
-	// Set default scale to 1
-	pdfWriter.k = 1
+	if reader.NeedsPassword() {
+		return nil, errors.New("Document is encrypted and needs a password, see SetPassword")
+	}
+	if err := pdfWriter.checkDeadline(); err != nil {
+		return nil, err
+	}
 
-	// Get all page boxes
-	pageBoxes, err := reader.getPageBoxes(1, pdfWriter.k)
+	boxName, err = boxName.normalize()
 	if err != nil {
-		return -1, errors.Wrap(err, "Failed to get page boxes")
+		return nil, err
 	}
 
-	// If requested box name does not exist for pdfWriter page, use an alternate box
-	if _, ok := pageBoxes[boxName]; !ok {
-		if boxName == "/BleedBox" || boxName == "/TrimBox" || boxName == "ArtBox" {
-			boxName = "/CropBox"
-		} else if boxName == "/CropBox" {
-			boxName = "/MediaBox"
-		}
+	// Get all page boxes
+	pageBoxes, err := reader.getPageBoxes(pageno, pdfWriter.k)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get page boxes")
 	}
 
-	// If the requested box name or an alternate box name cannot be found, trigger an error
-	// TODO: Improve error handling
-	if _, ok := pageBoxes[boxName]; !ok {
-		return -1, errors.New("Box not found: " + boxName)
+	// If requested box name does not exist for pdfWriter page, use the box it
+	// falls back to
+	boxName, err = pdfWriter.fallbackBox(pageBoxes, pageno, boxName)
+	if err != nil {
+		return nil, err
 	}
 
 	pageResources, err := reader.getPageResources(pageno)
 	if err != nil {
-		return -1, errors.Wrap(err, "Failed to get page resources")
+		return nil, errors.Wrap(err, "Failed to get page resources")
 	}
 
 	content, err := reader.getContent(pageno)
+	if err == nil {
+		err = pdfWriter.checkDeadline()
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get content")
+	}
+
+	content, err = pdfWriter.filterContent(pageno, content)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to filter content")
+	}
+
+	fullResources := pageResources
+
+	if pdfWriter.prune_resources {
+		used, err := usedResourceNames(content)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to find used resources")
+		}
+
+		pageResources, err = reader.pruneResources(pageResources, used)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to prune resources")
+		}
+	}
+
+	group, err := reader.getPageGroup(pageno)
 	if err != nil {
-		return -1, errors.Wrap(err, "Failed to get content")
+		return nil, errors.Wrap(err, "Failed to get page group")
 	}
 
 	// Set template values
 	tpl := &PdfTemplate{}
 	tpl.Reader = reader
+	tpl.PageNo = pageno
+	tpl.k = pdfWriter.k
 	tpl.Resources = pageResources
+	tpl.pageResources = fullResources
+	tpl.Group = group
+	if !pdfWriter.strip_af {
+		tpl.associatedFiles, err = reader.getPageAssociatedFiles(pageno)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to get associated files")
+		}
+	}
 	tpl.Buffer = content
-	tpl.Box = pageBoxes[boxName]
+	tpl.Box = pageBoxes[boxName.String()]
 	tpl.Boxes = pageBoxes
+	tpl.boxName = boxName
+
+	if pdfWriter.flatten_annotations {
+		err = pdfWriter.flattenAnnotations(tpl)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to flatten annotations")
+		}
+	}
+
+	if pdfWriter.crop_to_content {
+		err = pdfWriter.cropToContent(tpl)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	tpl.X = 0
 	tpl.Y = 0
 	tpl.W = tpl.Box["w"]
 	tpl.H = tpl.Box["h"]
 
 	// Set template rotation
-	rotation, err := reader.getPageRotation(pageno)
+	angle, _, err := reader.getNormalizedPageRotation(pageno)
 	if err != nil {
-		return -1, errors.Wrap(err, "Failed to get page rotation")
+		return nil, errors.Wrap(err, "Failed to get page rotation")
 	}
-	angle := rotation.Int % 360
+
+	angle = pdfWriter.orientPage(angle, tpl.W, tpl.H)
 
 	// Normalize angle
 	if angle != 0 {
@@ -184,24 +547,20 @@ func (pdfWriter *PdfWriter) ImportPage(reader *PdfReader, pageno int, boxName st
 			tpl.H = w
 		}
 
-		if angle < 0 {
-			angle += 360
-		}
-
 		tpl.Rotation = angle * -1
 	}
 
-	pdfWriter.tpls = append(pdfWriter.tpls, tpl)
+	if pdfWriter.printer_marks != nil {
+		tpl.addPrinterMarks(*pdfWriter.printer_marks)
+	}
 
-	// Return last template id
-	return len(pdfWriter.tpls) - 1, nil
+	return tpl, nil
 }
 
 // Create a new object and keep track of the offset for the xref table
 func (pdfWriter *PdfWriter) newObj(objId int, onlyNewObj bool) {
 	if objId < 0 {
-		pdfWriter.n++
-		objId = pdfWriter.n
+		objId = pdfWriter.allocObjId(0)
 	}
 
 	if !onlyNewObj {
@@ -210,83 +569,197 @@ func (pdfWriter *PdfWriter) newObj(objId int, onlyNewObj bool) {
 
 		// Create new PdfObject and PdfObjectId
 		pdfWriter.current_obj = new(PdfObject)
-		pdfWriter.current_obj.buffer = new(bytes.Buffer)
+		pdfWriter.current_obj.buffer = getBuffer()
 		pdfWriter.current_obj.id = new(PdfObjectId)
 		pdfWriter.current_obj.id.id = objId
 		pdfWriter.current_obj.id.hash = pdfWriter.shaOfInt(objId)
 
 		pdfWriter.written_obj_pos[pdfWriter.current_obj.id] = make(map[int]string, 0)
+		pdfWriter.separator_pending = false
 	}
 }
 
+// End the current object and write it into the backend
 func (pdfWriter *PdfWriter) endObj() {
 	pdfWriter.out("endobj")
 
-	pdfWriter.written_objs[pdfWriter.current_obj.id] = pdfWriter.current_obj.buffer.Bytes()
+	data := releaseBuffer(pdfWriter.current_obj.buffer)
+	pdfWriter.current_obj.buffer = nil
+	addMetric(pdfWriter.metrics, MetricObjectsWritten, 1)
+	addMetric(pdfWriter.metrics, MetricBytesWritten, float64(len(data)))
+
+	pdfObjId := pdfWriter.current_obj.id
+	err := pdfWriter.backend.WriteObject(pdfObjId.id, data)
+	if err != nil && pdfWriter.backend_err == nil {
+		pdfWriter.backend_err = errors.Wrap(err, fmt.Sprintf("Failed to write object %d", pdfObjId.id))
+	}
+	if pdfWriter.hasOutputBackend() {
+		pdfWriter.backend_objs[pdfObjId] = len(data)
+	}
+	pdfWriter.written_readers[pdfObjId] = pdfWriter.r
 	pdfWriter.current_obj_id = -1
 }
 
 func (pdfWriter *PdfWriter) shaOfInt(i int) string {
-	hasher := sha1.New()
-	hasher.Write([]byte(fmt.Sprintf("%d-%s", i, pdfWriter.r.sourceFile)))
-	sha := hex.EncodeToString(hasher.Sum(nil))
+	// Objects are usually referenced many times
+	if sha, ok := pdfWriter.obj_hashes[i]; ok {
+		return sha
+	}
+
+	sha := objectHash(i, pdfWriter.r.hashSource())
+	pdfWriter.obj_hashes[i] = sha
 	return sha
 }
 
+// Get the hash of output object i of the writer of a source, by the
+// fingerprint of the source (see PdfReader.hashSource)
+func objectHash(i int, source string) string {
+	var b [64]byte
+	hasher := sha1.New()
+	hasher.Write(append(append(strconv.AppendInt(b[:0], int64(i), 10), '-'), source...))
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
 func (pdfWriter *PdfWriter) outObjRef(objId int) {
-	sha := pdfWriter.shaOfInt(objId)
+	pdfWriter.outPdfObjectIdRef(&PdfObjectId{id: objId, hash: pdfWriter.shaOfInt(objId)})
+}
+
+// Output a reference to an object that may have been written by another writer
+func (pdfWriter *PdfWriter) outPdfObjectIdRef(pdfObjId *PdfObjectId) {
+	pdfWriter.separate("0")
 
 	// Keep track of object hash and position - to be replaced with actual object id (integer)
-	pdfWriter.written_obj_pos[pdfWriter.current_obj.id][pdfWriter.current_obj.buffer.Len()] = sha
+	pdfWriter.written_obj_pos[pdfWriter.current_obj.id][pdfWriter.current_obj.buffer.Len()] = pdfObjId.hash
 
-	if pdfWriter.use_hash {
-		pdfWriter.current_obj.buffer.WriteString(sha)
+	if pdfWriter.use_hash && !pdfWriter.hasOutputBackend() {
+		pdfWriter.current_obj.buffer.WriteString(pdfObjId.hash)
 	} else {
-		pdfWriter.current_obj.buffer.WriteString(fmt.Sprintf("%d", objId))
+		pdfWriter.outInt(pdfObjId.id)
+	}
+	if pdfWriter.compact_output {
+		pdfWriter.current_obj.buffer.WriteString(" 0 R")
+		pdfWriter.separator_pending = true
+		return
 	}
 	pdfWriter.current_obj.buffer.WriteString(" 0 R ")
 }
 
 // Output PDF data with a newline
 func (pdfWriter *PdfWriter) out(s string) {
+	if pdfWriter.compact_output {
+		pdfWriter.compactOut(s)
+		return
+	}
 	pdfWriter.current_obj.buffer.WriteString(s)
 	pdfWriter.current_obj.buffer.WriteString("\n")
 }
 
+// Output PDF data with a newline, if the syntax needs one, see SetCompactOutput
+func (pdfWriter *PdfWriter) compactOut(s string) {
+	buffer := pdfWriter.current_obj.buffer
+	switch s {
+	case "stream", "endobj":
+		// Stream data starts, and the next object follows, on a new line
+		pdfWriter.separate(s)
+		buffer.WriteString(s)
+		buffer.WriteByte('\n')
+		pdfWriter.separator_pending = false
+	case "endstream":
+		// Follows the line of the stream data
+		pdfWriter.separator_pending = false
+		buffer.WriteString("\nendstream")
+		pdfWriter.separator_pending = true
+	default:
+		pdfWriter.separate(s)
+		buffer.WriteString(s)
+		pdfWriter.separator_pending = true
+	}
+}
+
 // Output PDF data
 func (pdfWriter *PdfWriter) straightOut(s string) {
+	if pdfWriter.compact_output {
+		if s == " " {
+			pdfWriter.separator_pending = true
+			return
+		}
+		pdfWriter.separate(s)
+	}
 	pdfWriter.current_obj.buffer.WriteString(s)
 }
 
+// Output PDF data followed by a space
+func (pdfWriter *PdfWriter) outToken(s string) {
+	if pdfWriter.compact_output {
+		pdfWriter.separate(s)
+		pdfWriter.current_obj.buffer.WriteString(s)
+		pdfWriter.separator_pending = true
+		return
+	}
+	pdfWriter.current_obj.buffer.WriteString(s)
+	pdfWriter.current_obj.buffer.WriteByte(' ')
+}
+
+// Output an integer
+func (pdfWriter *PdfWriter) outInt(n int) {
+	if pdfWriter.compact_output {
+		pdfWriter.separate("0")
+	}
+	var b [24]byte
+	pdfWriter.current_obj.buffer.Write(strconv.AppendInt(b[:0], int64(n), 10))
+}
+
+// Output a real number in its shortest exact representation
+func (pdfWriter *PdfWriter) outReal(v float64) {
+	if pdfWriter.compact_output {
+		pdfWriter.separate("0")
+	}
+	var b [32]byte
+	pdfWriter.current_obj.buffer.Write(strconv.AppendFloat(b[:0], v, 'f', -1, 64))
+}
+
 // Output a PdfValue
 func (pdfWriter *PdfWriter) writeValue(value *PdfValue) {
 	switch value.Type {
 	case PDF_TYPE_TOKEN:
-		pdfWriter.straightOut(value.Token + " ")
+		pdfWriter.outToken(escapeName(value.Token))
 	case PDF_TYPE_NUMERIC:
-		pdfWriter.straightOut(fmt.Sprintf("%d", value.Int) + " ")
+		pdfWriter.outInt(value.Int)
+		pdfWriter.straightOut(" ")
 	case PDF_TYPE_REAL:
-		pdfWriter.straightOut(fmt.Sprintf("%F", value.Real) + " ")
+		// Shortest exact representation, as small values such as a Type3
+		// /FontMatrix of 1/2048 must not be rounded
+		if pdfWriter.real_precision <= 0 {
+			pdfWriter.outReal(value.Real)
+			pdfWriter.straightOut(" ")
+		} else {
+			pdfWriter.outToken(pdfWriter.formatReal(value.Real, -1))
+		}
 	case PDF_TYPE_ARRAY:
 		pdfWriter.straightOut("[")
 		for i := 0; i < len(value.Array); i++ {
+			// The profile of an [/ICCBased stream] color space
+			if i == 1 && pdfWriter.merge_icc_profiles && value.Array[0].Token == "/ICCBased" && pdfWriter.writeSharedStreamRef(value.Array[i]) {
+				continue
+			}
+			// The base of an [/Indexed base ...] or [/Pattern base] color space
+			if i == 1 && (value.Array[0].Token == "/Indexed" || value.Array[0].Token == "/Pattern") && pdfWriter.writeSharedColorSpaceRef(value.Array[i]) {
+				continue
+			}
 			pdfWriter.writeValue(value.Array[i])
 		}
 		pdfWriter.out("]")
 	case PDF_TYPE_DICTIONARY:
 		pdfWriter.straightOut("<<")
-		for k, v := range value.Dictionary {
-			pdfWriter.straightOut(k + " ")
-			pdfWriter.writeValue(v)
-		}
+		pdfWriter.writeDictionaryEntries(value, "")
 		pdfWriter.straightOut(">>")
 	case PDF_TYPE_OBJREF:
 		// An indirect object reference.  Fill the object stack if needed.
 		// Check to see if object already exists on the don_obj_stack.
 		if _, ok := pdfWriter.don_obj_stack[value.Id]; !ok {
-			pdfWriter.newObj(-1, true)
-			pdfWriter.obj_stack[value.Id] = &PdfValue{Type: PDF_TYPE_OBJREF, Gen: value.Gen, Id: value.Id, NewId: pdfWriter.n}
-			pdfWriter.don_obj_stack[value.Id] = &PdfValue{Type: PDF_TYPE_OBJREF, Gen: value.Gen, Id: value.Id, NewId: pdfWriter.n}
+			newId := pdfWriter.allocObjId(value.Id)
+			pdfWriter.obj_stack[value.Id] = &PdfValue{Type: PDF_TYPE_OBJREF, Gen: value.Gen, Id: value.Id, NewId: newId}
+			pdfWriter.don_obj_stack[value.Id] = &PdfValue{Type: PDF_TYPE_OBJREF, Gen: value.Gen, Id: value.Id, NewId: newId}
 		}
 
 		// Get object ID from don_obj_stack
@@ -295,32 +768,240 @@ func (pdfWriter *PdfWriter) writeValue(value *PdfValue) {
 		//pdfWriter.out(fmt.Sprintf("%d 0 R", objId))
 	case PDF_TYPE_STRING:
 		// A string
-		pdfWriter.straightOut("(" + value.String + ")")
+		pdfWriter.straightOut("(")
+		pdfWriter.straightOut(value.String)
+		pdfWriter.straightOut(")")
 	case PDF_TYPE_STREAM:
-		// A stream.  First, output the stream dictionary, then the stream data itself.
-		pdfWriter.writeValue(value.Value)
+		// A stream.  First, output the stream dictionary with the length of the
+		// data that is written (rather than the /Length of the source, which
+		// may be an indirect object or wrong), then the bytes of the data.
+		// Streams left in the source are copied from it here, into the buffer
+		// of the object: the object is passed to the backend whole.
+		length := value.streamLength()
+		pdfWriter.straightOut("<</Length ")
+		pdfWriter.outInt(int(length))
+		pdfWriter.straightOut(" ")
+		pdfWriter.writeDictionaryEntries(value.Value, "/Length")
+		pdfWriter.straightOut(">>")
 		pdfWriter.out("stream")
-		pdfWriter.out(string(value.Stream.Bytes))
+		pdfWriter.current_obj.buffer.Grow(int(length) + len("\nendstream\nendobj\n"))
+		n, err := io.CopyN(pdfWriter.current_obj.buffer, pdfWriter.r.streamReader(value), length)
+		if err != nil {
+			pdfWriter.warn(fmt.Sprintf("Stream data of object %d %d R could not be read: %s", value.Id, value.Gen, err))
+
+			// Keep /Length right
+			pdfWriter.current_obj.buffer.Write(make([]byte, length-n))
+		}
+		pdfWriter.out("")
 		pdfWriter.out("endstream")
 	case PDF_TYPE_HEX:
-		pdfWriter.straightOut("<" + value.String + ">")
+		pdfWriter.straightOut("<")
+		pdfWriter.straightOut(value.String)
+		pdfWriter.straightOut(">")
 	case PDF_TYPE_BOOLEAN:
 		if value.Bool {
-			pdfWriter.straightOut("true ")
+			pdfWriter.outToken("true")
 		} else {
-			pdfWriter.straightOut("false ")
+			pdfWriter.outToken("false")
 		}
 	case PDF_TYPE_NULL:
 		// The null object
-		pdfWriter.straightOut("null ")
+		pdfWriter.outToken("null")
+	}
+}
+
+// Output the entries of a dictionary, except for skip
+func (pdfWriter *PdfWriter) writeDictionaryEntries(dict *PdfValue, skip string) {
+	if pdfWriter.sort_keys || pdfWriter.reproducible {
+		// Referenced objects are numbered in the order they are written
+		for _, k := range sortedKeys(dict.Dictionary) {
+			if k != skip {
+				pdfWriter.writeDictionaryEntry(dict, k, dict.Dictionary[k])
+			}
+		}
+	} else {
+		for k, v := range dict.Dictionary {
+			if k != skip {
+				pdfWriter.writeDictionaryEntry(dict, k, v)
+			}
+		}
+	}
+}
+
+// Output an entry of a dictionary
+func (pdfWriter *PdfWriter) writeDictionaryEntry(dict *PdfValue, k string, v *PdfValue) {
+	if pdfWriter.strip_keys[k] {
+		return
+	}
+	if pdfWriter.strip_thumbnails && k == "/Thumb" {
+		return
+	}
+	if pdfWriter.strip_af && k == "/AF" {
+		return
+	}
+	if pdfWriter.scrub_privacy && privacyKeys[k] {
+		return
+	}
+
+	// The beads of article threads on a page link pages that are copied as
+	// objects, not as pages, see Document.SetCarryThreads
+	if k == "/B" && dict.Dictionary["/Type"] != nil && dict.Dictionary["/Type"].Token == "/Page" {
+		return
+	}
+
+	if k == "/SMask" && !pdfWriter.softMaskResolvable(v) {
+		pdfWriter.warn(fmt.Sprintf("Soft mask %d %d R cannot be resolved and is removed", v.Id, v.Gen))
+
+		// Images have no soft mask without the entry, graphics states need /None
+		if subtype, ok := dict.Dictionary["/Subtype"]; ok && subtype.Token == "/Image" {
+			return
+		}
+		pdfWriter.straightOut("/SMask /None ")
+		return
+	}
+
+	pdfWriter.outToken(escapeName(k))
+	if pdfWriter.merge_font_files && isFontFileKey(k) && pdfWriter.writeSharedStreamRef(v) {
+		return
 	}
+	if pdfWriter.writeSharedColorSpaceRef(v) {
+		return
+	}
+	pdfWriter.writeValue(v)
+}
+
+// Record a problem that did not stop the import but changed its result
+func (pdfWriter *PdfWriter) warn(warning string) {
+	pdfWriter.warnings = append(pdfWriter.warnings, warning)
+	addMetric(pdfWriter.metrics, MetricRecoveries, 1)
+}
+
+// Get the problems that did not stop the import but changed its result
+func (pdfWriter *PdfWriter) GetWarnings() []string {
+	return pdfWriter.warnings
+}
+
+// Check that a soft mask reference points to an image, or to a mask
+// dictionary whose transparency group exists.  Direct values are not checked.
+func (pdfWriter *PdfWriter) softMaskResolvable(value *PdfValue) bool {
+	if value.Type != PDF_TYPE_OBJREF {
+		return true
+	}
+	if _, ok := pdfWriter.don_obj_stack[value.Id]; ok {
+		return true
+	}
+
+	mask, err := pdfWriter.r.resolveDirect(value)
+	if err != nil || mask == nil {
+		return false
+	}
+
+	if mask.Type == PDF_TYPE_DICTIONARY {
+		group, ok := mask.Dictionary["/G"]
+		if !ok {
+			return false
+		}
+		group, err = pdfWriter.r.resolveDirect(group)
+		return err == nil && group != nil && group.Type == PDF_TYPE_STREAM
+	}
+
+	return mask.Type == PDF_TYPE_STREAM
+}
+
+// Is key a font descriptor entry for an embedded font file
+func isFontFileKey(key string) bool {
+	return key == "/FontFile" || key == "/FontFile2" || key == "/FontFile3"
+}
+
+// Output a reference to a stream, reusing an identical stream that was already
+// written by any writer sharing shared_streams.  Returns false if value is not
+// a stream reference, in which case nothing is written.
+func (pdfWriter *PdfWriter) writeSharedStreamRef(value *PdfValue) bool {
+	if value.Type != PDF_TYPE_OBJREF {
+		return false
+	}
+
+	// Already referenced by this writer
+	if _, ok := pdfWriter.don_obj_stack[value.Id]; ok {
+		return false
+	}
+
+	obj, err := pdfWriter.r.resolveObject(value)
+	if err != nil || obj.Type != PDF_TYPE_STREAM {
+		return false
+	}
+
+	digest := pdfWriter.r.streamDigest(obj)
+	if pdfObjId, ok := pdfWriter.shared_streams[digest]; ok {
+		pdfWriter.outPdfObjectIdRef(pdfObjId)
+		pdfWriter.merged_objs[ObjectRef{Id: value.Id, Gen: value.Gen}] = pdfObjId
+		return true
+	}
+
+	pdfWriter.writeValue(value)
+
+	objId := pdfWriter.don_obj_stack[value.Id].NewId
+	pdfWriter.shared_streams[digest] = &PdfObjectId{id: objId, hash: pdfWriter.shaOfInt(objId)}
+
+	return true
+}
+
+// Get a digest that identifies the contents of a stream object
+func (pdfReader *PdfReader) streamDigest(obj *PdfValue) string {
+	hasher := sha1.New()
+	if filter, ok := obj.Value.Dictionary["/Filter"]; ok {
+		var buf bytes.Buffer
+		writeContentValue(&buf, filter)
+		hasher.Write(buf.Bytes())
+	}
+	hasher.Write([]byte{0})
+	io.Copy(hasher, pdfReader.streamReader(obj))
+	return hex.EncodeToString(hasher.Sum(nil))
 }
 
 // Output Form XObjects (1 for each template)
-// returns a map of template names (e.g. /GOFPDITPL1) to PdfObjectId
+// returns a map of template names (e.g. /GOFPDITPL1) to PdfObjectId.
+// Templates and objects written by an earlier call are not written again,
+// so it can be called after each imported page; the map has all templates.
 func (pdfWriter *PdfWriter) PutFormXobjects(reader *PdfReader) (map[string]*PdfObjectId, error) {
-	// Set current reader
+	if pdfWriter.hasOutputBackend() {
+		return nil, errors.New("Objects are written into an output backend, see PutFormXobjectsTo")
+	}
+	return pdfWriter.putFormXobjects(reader, pdfWriter.templateIds())
+}
+
+// Get the ids of all templates
+func (pdfWriter *PdfWriter) templateIds() []int {
+	tplIds := make([]int, len(pdfWriter.tpls))
+	for i := range tplIds {
+		tplIds[i] = i
+	}
+	return tplIds
+}
+
+// Output the form xobjects of the templates tplIds (returned from
+// ImportPage) only, see Importer.PutFormXobjectsOnly
+func (pdfWriter *PdfWriter) PutFormXobjectsOnly(reader *PdfReader, tplIds []int) (map[string]*PdfObjectId, error) {
+	if pdfWriter.hasOutputBackend() {
+		return nil, errors.New("Objects are written into an output backend, see PutFormXobjectsTo")
+	}
+	for _, i := range tplIds {
+		if i < 0 || i >= len(pdfWriter.tpls) {
+			return nil, errors.New(fmt.Sprintf("Template %d does not exist", i))
+		}
+	}
+	return pdfWriter.putFormXobjects(reader, tplIds)
+}
+
+// Output the form xobjects of templates tplIds, see PutFormXobjects
+func (pdfWriter *PdfWriter) putFormXobjects(reader *PdfReader, tplIds []int) (map[string]*PdfObjectId, error) {
+	// Set current reader.  Object hashes depend on its source file.
+	if pdfWriter.r != reader {
+		pdfWriter.obj_hashes = make(map[int]string, 0)
+	}
 	pdfWriter.r = reader
+	defer pdfWriter.startDeadline()()
+	defer observeSince(pdfWriter.metrics, MetricWriteDuration, time.Now())
 
 	var err error
 	var result = make(map[string]*PdfObjectId, 0)
@@ -331,83 +1012,95 @@ func (pdfWriter *PdfWriter) PutFormXobjects(reader *PdfReader) (map[string]*PdfO
 		filter = "/Filter /FlateDecode "
 	}
 
-	for i := 0; i < len(pdfWriter.tpls); i++ {
+	for _, i := range tplIds {
 		tpl := pdfWriter.tpls[i]
 		if tpl == nil {
 			return nil, errors.New("Template is nil")
 		}
-		var p string
-		if compress {
-			var b bytes.Buffer
-			w := zlib.NewWriter(&b)
-			w.Write([]byte(tpl.Buffer))
-			w.Close()
 
-			p = b.String()
-		} else {
-			p = tpl.Buffer
+		// Written by an earlier call
+		if tpl.objId != nil {
+			result[pdfWriter.templateName(i)] = tpl.objId
+			continue
+		}
+
+		if pdfWriter.skip_unused && !tpl.used {
+			continue
+		}
+
+		stream, err := pdfWriter.templateStream(tpl, compress)
+		if err != nil {
+			return nil, err
+		}
+
+		if pdfWriter.downsample_dpi > 0 && tpl.parts == nil {
+			err = tpl.imageSizes(pdfWriter.image_sizes)
+			if err != nil {
+				return nil, errors.Wrap(err, "Failed to find image sizes")
+			}
 		}
 
 		// Create new PDF object
 		pdfWriter.newObj(-1, false)
 
-		cN := pdfWriter.n // remember current "n"
+		cN := pdfWriter.current_obj_id // remember current "n"
 
-		tpl.N = pdfWriter.n
+		tpl.N = cN
 
 		// Return xobject form name and object position
 		pdfObjId := new(PdfObjectId)
 		pdfObjId.id = cN
 		pdfObjId.hash = pdfWriter.shaOfInt(cN)
-		result[fmt.Sprintf("/GOFPDITPL%d", i+pdfWriter.tpl_id_offset)] = pdfObjId
+		result[pdfWriter.templateName(i)] = pdfObjId
+		tpl.objId = pdfObjId
 
 		pdfWriter.out("<<" + filter + "/Type /XObject")
 		pdfWriter.out("/Subtype /Form")
 		pdfWriter.out("/FormType 1")
 
-		pdfWriter.out(fmt.Sprintf("/BBox [%.2F %.2F %.2F %.2F]", tpl.Box["llx"]*pdfWriter.k, tpl.Box["lly"]*pdfWriter.k, (tpl.Box["urx"]+tpl.X)*pdfWriter.k, (tpl.Box["ury"]-tpl.Y)*pdfWriter.k))
-
-		var c, s, tx, ty float64
-		c = 1
-
-		// Handle rotated pages
-		if tpl.Box != nil {
-			tx = -tpl.Box["llx"]
-			ty = -tpl.Box["lly"]
-
-			if tpl.Rotation != 0 {
-				angle := float64(tpl.Rotation) * math.Pi / 180.0
-				c = math.Cos(float64(angle))
-				s = math.Sin(float64(angle))
-
-				switch tpl.Rotation {
-				case -90:
-					tx = -tpl.Box["lly"]
-					ty = tpl.Box["urx"]
-				case -180:
-					tx = tpl.Box["urx"]
-					ty = tpl.Box["ury"]
-				case -270:
-					tx = tpl.Box["ury"]
-					ty = -tpl.Box["llx"]
-				}
-			}
-		} else {
-			tx = -tpl.Box["x"] * 2
-			ty = tpl.Box["y"] * 2
-		}
+		pdfWriter.out(fmt.Sprintf("/BBox [%s %s %s %s]",
+			pdfWriter.formatReal(tpl.Box["llx"]*tpl.k, 2),
+			pdfWriter.formatReal(tpl.Box["lly"]*tpl.k, 2),
+			pdfWriter.formatReal((tpl.Box["urx"]+tpl.X)*tpl.k, 2),
+			pdfWriter.formatReal((tpl.Box["ury"]-tpl.Y)*tpl.k, 2)))
+
+		// Patterns and shadings need no compensation for the form matrix: a pattern
+		// used in a form maps to form space, which is the page space of the imported
+		// content, so they follow the template wherever and at whatever scale it is placed.
+		c, s, tx, ty := tpl.formMatrix()
 
-		tx *= pdfWriter.k
-		ty *= pdfWriter.k
+		tx *= tpl.k
+		ty *= tpl.k
 
 		if c != 1 || s != 0 || tx != 0 || ty != 0 {
-			pdfWriter.out(fmt.Sprintf("/Matrix [%.5F %.5F %.5F %.5F %.5F %.5F]", c, s, -s, c, tx, ty))
+			pdfWriter.out(fmt.Sprintf("/Matrix [%s %s %s %s %s %s]",
+				pdfWriter.formatReal(c, 5), pdfWriter.formatReal(s, 5), pdfWriter.formatReal(-s, 5),
+				pdfWriter.formatReal(c, 5), pdfWriter.formatReal(tx, 5), pdfWriter.formatReal(ty, 5)))
+		}
+
+		// Keep the transparency group of the page, so blending works as on the page
+		if tpl.Group != nil {
+			pdfWriter.straightOut("/Group ")
+			pdfWriter.writeValue(tpl.Group)
+			pdfWriter.out("")
+		}
+
+		// Keep the files associated with the page, which forms can carry too
+		if tpl.associatedFiles != nil {
+			pdfWriter.straightOut("/AF ")
+			pdfWriter.writeValue(tpl.associatedFiles)
+			pdfWriter.out("")
 		}
 
 		// Now write resources
 		pdfWriter.out("/Resources ")
 
-		if tpl.Resources != nil {
+		if tpl.parts != nil {
+			err = pdfWriter.writePartResources(tpl)
+			if err != nil {
+				return nil, err
+			}
+		} else if tpl.Resources != nil {
 			pdfWriter.writeValue(tpl.Resources) // "n" will be changed
 		} else {
 			return nil, errors.New("Template resources are empty")
@@ -416,75 +1109,261 @@ func (pdfWriter *PdfWriter) PutFormXobjects(reader *PdfReader) (map[string]*PdfO
 		nN := pdfWriter.n // remember new "n"
 		pdfWriter.n = cN  // reset to current "n"
 
-		pdfWriter.out("/Length " + fmt.Sprintf("%d", len(p)) + " >>")
+		pdfWriter.out("/Length " + fmt.Sprintf("%d", len(stream)) + " >>")
 
 		pdfWriter.out("stream")
-		pdfWriter.out(p)
+		pdfWriter.current_obj.buffer.Grow(len(stream) + len("\nendstream\nendobj\n"))
+		pdfWriter.current_obj.buffer.Write(stream)
+		pdfWriter.out("")
 		pdfWriter.out("endstream")
 
 		pdfWriter.endObj()
+		if stat := pdfWriter.recordObjectStat(pdfWriter.current_obj.id, nil, nil, nil, nil); stat != nil {
+			stat.StreamBytes = int64(len(stream))
+			stat.Filters = []string{"/FlateDecode"}
+		}
 
 		pdfWriter.n = nN // reset to new "n"
 
+		if tpl.textLayer != nil {
+			pdfWriter.putTextLayerFont(tpl)
+		}
+
 		// Put imported objects, starting with the ones from the XObject's Resources,
 		// then from dependencies of those resources).
-		err = pdfWriter.putImportedObjects(reader)
+		err = pdfWriter.putImportedObjects(reader, tpl)
 		if err != nil {
 			return nil, errors.Wrap(err, "Failed to put imported objects")
 		}
 	}
 
+	if pdfWriter.backend_err != nil {
+		return nil, pdfWriter.backend_err
+	}
+
+	err = pdfWriter.verifyReferences()
+	if err != nil {
+		return nil, err
+	}
+
+	// Objects written into another backend cannot be taken back
+	if pdfWriter.remove_unreachable && !pdfWriter.hasOutputBackend() {
+		pdfWriter.removeUnreachable()
+	}
+
+	for tplName, pdfObjId := range result {
+		pdfWriter.backend.MapPlaceholder(tplName, pdfObjId.id)
+	}
+
 	return result, nil
 }
 
-func (pdfWriter *PdfWriter) putImportedObjects(reader *PdfReader) error {
+// Get the content stream of a template, with colors converted to the color
+// model.  Compressed streams are kept in the template, so they can be made
+// before PutFormXobjects by ImportPages.
+func (pdfWriter *PdfWriter) templateStream(tpl *PdfTemplate, compress bool) ([]byte, error) {
+	if compress && tpl.stream != nil && tpl.streamModel == pdfWriter.color_model {
+		return tpl.stream, nil
+	}
+
 	var err error
-	var nObj *PdfValue
+	content := tpl.Buffer
+	if pdfWriter.color_model != ColorModelOriginal {
+		content, err = convertContentColors(content, pdfWriter.color_model)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to convert colors")
+		}
+	}
 
-	// obj_stack will have new items added to it in the inner loop, so do another loop to check for extras
-	// TODO make the order of pdfWriter the same every time
-	for {
-		atLeastOne := false
+	if !compress {
+		return content, nil
+	}
 
-		// FIXME:  How to determine number of objects before pdfWriter loop?
-		for i := 0; i < 9999; i++ {
-			k := i
-			v := pdfWriter.obj_stack[i]
+	tpl.stream = deflate(content)
+	tpl.streamModel = pdfWriter.color_model
 
-			if v == nil {
-				continue
+	return tpl.stream, nil
+}
+
+// Get the form matrix of a template: the cosine and sine of its rotation and
+// the translation that moves its box to the origin
+func (tpl *PdfTemplate) formMatrix() (float64, float64, float64, float64) {
+	var c, s, tx, ty float64
+	c = 1
+
+	// Handle rotated pages
+	if tpl.Box != nil {
+		tx = -tpl.Box["llx"]
+		ty = -tpl.Box["lly"]
+
+		if tpl.Rotation != 0 {
+			angle := float64(tpl.Rotation) * math.Pi / 180.0
+			c = math.Cos(float64(angle))
+			s = math.Sin(float64(angle))
+
+			switch tpl.Rotation {
+			case -90:
+				tx = -tpl.Box["lly"]
+				ty = tpl.Box["urx"]
+			case -180:
+				tx = tpl.Box["urx"]
+				ty = tpl.Box["ury"]
+			case -270:
+				tx = tpl.Box["ury"]
+				ty = -tpl.Box["llx"]
 			}
+		}
+	} else {
+		tx = -tpl.Box["x"] * 2
+		ty = tpl.Box["y"] * 2
+	}
 
-			atLeastOne = true
+	return c, s, tx, ty
+}
 
+func (pdfWriter *PdfWriter) putImportedObjects(reader *PdfReader, tpl *PdfTemplate) error {
+	var err error
+	var nObj *PdfValue
+
+	// obj_stack will have new items added to it while writing objects, so keep
+	// going until it is empty.  Objects are written in order of their source ids.
+	// Objects are only added once (see don_obj_stack), so cycles end here too.
+	for len(pdfWriter.obj_stack) > 0 {
+		ids := make([]int, 0, len(pdfWriter.obj_stack))
+		for id := range pdfWriter.obj_stack {
+			ids = append(ids, id)
+		}
+		sort.Ints(ids)
+
+		for _, id := range ids {
+			v := pdfWriter.obj_stack[id]
+
+			// Remove from stack
+			delete(pdfWriter.obj_stack, id)
+
+			if err := pdfWriter.checkDeadline(); err != nil {
+				return err
+			}
 			nObj, err = reader.resolveObject(v)
 			if err != nil {
 				return errors.Wrap(err, "Unable to resolve object")
 			}
 
-			// New object with "NewId" field
-			pdfWriter.newObj(v.NewId, false)
+			if nObj.Value != nil && nObj.Value.Type == PDF_TYPE_DICTIONARY {
+				nObj = pdfWriter.inheritResources(nObj, tpl)
+			}
 
+			original := nObj
+			var changes []string
+			change := func(changed *PdfValue, change string) {
+				if changed != nObj {
+					changes = append(changes, change)
+				}
+				nObj = changed
+			}
+
+			if pdfWriter.color_model != ColorModelOriginal && nObj.Type == PDF_TYPE_STREAM {
+				change(reader.convertStreamColors(nObj, pdfWriter.color_model), "colors converted")
+			}
+
+			if size, ok := pdfWriter.image_sizes[v.Id]; ok && pdfWriter.downsample_dpi > 0 && nObj.Type == PDF_TYPE_STREAM {
+				change(reader.downsampleImage(nObj, size, pdfWriter.downsample_dpi, pdfWriter.jpeg_quality), "downsampled")
+			}
+
+			if pdfWriter.compress_streams && nObj.Type == PDF_TYPE_STREAM {
+				change(reader.compressStream(nObj), "compressed")
+			}
+
+			value := nObj.Value
 			if nObj.Type == PDF_TYPE_STREAM {
-				pdfWriter.writeValue(nObj)
-			} else {
-				pdfWriter.writeValue(nObj.Value)
+				value = nObj
 			}
 
-			pdfWriter.endObj()
+			value, err = pdfWriter.filterObject(v, value)
+			if err != nil {
+				return errors.Wrap(err, fmt.Sprintf("Unable to filter object %d %d R", v.Id, v.Gen))
+			}
 
-			// Remove from stack
-			pdfWriter.obj_stack[k] = nil
+			// New object with "NewId" field
+			pdfWriter.newObj(v.NewId, false)
+			pdfObjId := pdfWriter.current_obj.id
+			if font, ok := pdfWriter.fontSubstitute(value); ok {
+				pending := pdfWriter.writeSubstitutedFont(value, font)
+				pdfWriter.endObj()
+				pdfWriter.recordObjectStat(pdfObjId, v, original, nil, append(changes, "font substituted"))
+				if pending != nil {
+					pdfWriter.putFontSubstitute(pending)
+				}
+				continue
+			}
+			pdfWriter.writeValue(value)
+
+			pdfWriter.endObj()
+			pdfWriter.recordObjectStat(pdfObjId, v, original, value, changes)
 		}
+	}
 
-		if !atLeastOne {
-			break
+	return nil
+}
+
+// Check that every reference written points to an object that has been
+// written, by this writer, by a writer it shares streams with or, for
+// composite templates, by the writer of a part.  Composite
+// fonts in particular are made of many objects (descendant fonts, CMaps,
+// CIDToGIDMap and font file streams) that all have to survive renumbering.
+func (pdfWriter *PdfWriter) verifyReferences() error {
+	written := make(map[string]bool, len(pdfWriter.written_objs)+len(pdfWriter.shared_streams))
+	for pdfObjId := range pdfWriter.written_objs {
+		written[pdfObjId.hash] = true
+	}
+	for pdfObjId := range pdfWriter.backend_objs {
+		written[pdfObjId.hash] = true
+	}
+	for _, pdfObjId := range pdfWriter.shared_streams {
+		written[pdfObjId.hash] = true
+	}
+	for hash := range pdfWriter.part_objs {
+		written[hash] = true
+	}
+	for hash := range pdfWriter.restored_objs {
+		written[hash] = true
+	}
+	for hash := range pdfWriter.cleared_objs {
+		written[hash] = true
+	}
+
+	for pdfObjId, posHash := range pdfWriter.written_obj_pos {
+		for _, hash := range posHash {
+			if !written[hash] {
+				return errors.New(fmt.Sprintf("Object %d references an object that has not been written", pdfObjId.id))
+			}
 		}
 	}
 
 	return nil
 }
 
+// Form xobjects and Type3 fonts without /Resources use the resources of the
+// page they are drawn on.  Once imported, that would be the page of the output
+// document, so give them the resources of the imported page instead.
+func (pdfWriter *PdfWriter) inheritResources(obj *PdfValue, tpl *PdfTemplate) *PdfValue {
+	dict := obj.Value.Dictionary
+	if subtype, ok := dict["/Subtype"]; !ok || (subtype.Token != "/Form" && subtype.Token != "/Type3") {
+		return obj
+	}
+	if _, ok := dict["/Resources"]; ok || tpl == nil || tpl.pageResources == nil {
+		return obj
+	}
+
+	newDict := make(map[string]*PdfValue, len(dict)+1)
+	for k, v := range dict {
+		newDict[k] = v
+	}
+	newDict["/Resources"] = tpl.pageResources
+
+	return &PdfValue{Type: obj.Type, Id: obj.Id, Gen: obj.Gen, Value: &PdfValue{Type: PDF_TYPE_DICTIONARY, Dictionary: newDict}, Stream: obj.Stream}
+}
+
 // Get the calculated size of a template
 // If one size is given, pdfWriter method calculates the other one
 func (pdfWriter *PdfWriter) getTemplateSize(tplid int, _w float64, _h float64) map[string]float64 {
@@ -514,7 +1393,83 @@ func (pdfWriter *PdfWriter) getTemplateSize(tplid int, _w float64, _h float64) m
 	return result
 }
 
+// Create a template that shows only a rectangle of template tplid.  x and y are
+// the offset of the rectangle from the top left corner of the template (bottom
+// left with OriginBottomLeft), w and h its size, all in the units of the template size.  The new template shares the
+// content and resources of tplid.  Returns the id of the new template.
+func (pdfWriter *PdfWriter) ClipTemplate(tplid int, x float64, y float64, w float64, h float64) (int, error) {
+	if tplid < 0 || tplid >= len(pdfWriter.tpls) {
+		return -1, errors.New(fmt.Sprintf("Template %d does not exist", tplid))
+	}
+	if w <= 0 || h <= 0 {
+		return -1, errors.New("Clip rectangle is empty")
+	}
+
+	tpl := pdfWriter.tpls[tplid]
+
+	if pdfWriter.origin == OriginBottomLeft {
+		y = tpl.H - y - h
+	}
+
+	// The form matrix maps the box to (0, 0, W, H) with the origin at the bottom
+	// left, so the inverse maps the rectangle back to the box
+	c, sn, tx, ty := tpl.formMatrix()
+	inverse := matrix{c, sn, -sn, c, tx, ty}.inverse()
+
+	corners := []point{
+		inverse.transform(x, tpl.H-y),
+		inverse.transform(x+w, tpl.H-y),
+		inverse.transform(x, tpl.H-y-h),
+		inverse.transform(x+w, tpl.H-y-h),
+	}
+	llx, lly, urx, ury := corners[0].x, corners[0].y, corners[0].x, corners[0].y
+	for _, p := range corners[1:] {
+		llx = math.Min(llx, p.x)
+		lly = math.Min(lly, p.y)
+		urx = math.Max(urx, p.x)
+		ury = math.Max(ury, p.y)
+	}
+
+	clipped := *tpl
+	clipped.Box = map[string]float64{
+		"x":   llx,
+		"y":   lly,
+		"w":   urx - llx,
+		"h":   ury - lly,
+		"llx": llx,
+		"lly": lly,
+		"urx": urx,
+		"ury": ury,
+	}
+	clipped.W = w
+	clipped.H = h
+	// The clipped template is a form xobject of its own, with its own name,
+	// and has not been drawn yet
+	clipped.objId = nil
+	clipped.name = ""
+	clipped.used = false
+
+	pdfWriter.tpls = append(pdfWriter.tpls, &clipped)
+
+	return len(pdfWriter.tpls) - 1, nil
+}
+
+// Get the name of template tplid (e.g. /GOFPDITPL1)
+func (pdfWriter *PdfWriter) templateName(tplid int) string {
+	if name := pdfWriter.tpls[tplid].name; name != "" {
+		return name
+	}
+	return fmt.Sprintf("/GOFPDITPL%d", tplid+pdfWriter.tpl_id_offset)
+}
+
 func (pdfWriter *PdfWriter) UseTemplate(tplid int, _x float64, _y float64, _w float64, _h float64) (string, float64, float64, float64, float64) {
+	pdfWriter.tpls[tplid].markUsed()
+	return pdfWriter.useTemplate(tplid, _x, _y, _w, _h)
+}
+
+// Get the name and placement of a template, see UseTemplate, without
+// counting it as used
+func (pdfWriter *PdfWriter) useTemplate(tplid int, _x float64, _y float64, _w float64, _h float64) (string, float64, float64, float64, float64) {
 	tpl := pdfWriter.tpls[tplid]
 
 	w := tpl.W
@@ -523,7 +1478,7 @@ func (pdfWriter *PdfWriter) UseTemplate(tplid int, _x float64, _y float64, _w fl
 	_x += tpl.X
 	_y += tpl.Y
 
-	wh := pdfWriter.getTemplateSize(0, _w, _h)
+	wh := pdfWriter.getTemplateSize(tplid, _w, _h)
 
 	_w = wh["w"]
 	_h = wh["h"]
@@ -537,7 +1492,10 @@ func (pdfWriter *PdfWriter) UseTemplate(tplid int, _x float64, _y float64, _w fl
 	tData["scaleY"] = (_h / h)
 	tData["tx"] = _x
 	tData["ty"] = (0 - _y - _h)
+	if pdfWriter.origin == OriginBottomLeft {
+		tData["ty"] = _y
+	}
 	tData["lty"] = (0 - _y - _h) - (0-h)*(_h/h)
 
-	return fmt.Sprintf("/GOFPDITPL%d", tplid+pdfWriter.tpl_id_offset), tData["scaleX"], tData["scaleY"], tData["tx"] * pdfWriter.k, tData["ty"] * pdfWriter.k
+	return pdfWriter.templateName(tplid), tData["scaleX"], tData["scaleY"], tData["tx"] * tpl.k, tData["ty"] * tpl.k
 }
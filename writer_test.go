@@ -0,0 +1,400 @@
+package gofpdi
+
+import (
+	"bytes"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// A value like a large font or page tree: many integers, reals and
+// references
+func benchmarkValue() *PdfValue {
+	numbers := make([]*PdfValue, 20000)
+	for i := range numbers {
+		if i%2 == 0 {
+			numbers[i] = &PdfValue{Type: PDF_TYPE_NUMERIC, Int: i * 7, Real: float64(i * 7)}
+		} else {
+			numbers[i] = &PdfValue{Type: PDF_TYPE_REAL, Real: float64(i) / 3}
+		}
+	}
+	refs := make([]*PdfValue, 2000)
+	for i := range refs {
+		refs[i] = &PdfValue{Type: PDF_TYPE_OBJREF, Id: i + 10}
+	}
+
+	return &PdfValue{Type: PDF_TYPE_DICTIONARY, Dictionary: map[string]*PdfValue{
+		"/Type":   {Type: PDF_TYPE_TOKEN, Token: "/Font"},
+		"/W":      {Type: PDF_TYPE_ARRAY, Array: numbers},
+		"/Kids":   {Type: PDF_TYPE_ARRAY, Array: refs},
+		"/Name":   {Type: PDF_TYPE_STRING, String: "Benchmark"},
+		"/Hidden": {Type: PDF_TYPE_BOOLEAN, Bool: true},
+	}}
+}
+
+// Serialize a value into an object, as the objects of imported pages are
+func BenchmarkWriteValue(b *testing.B) {
+	reader := readTestPDF(b, testDocument(1))
+	value := benchmarkValue()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		writer, err := NewPdfWriter("")
+		if err != nil {
+			b.Fatal(err)
+		}
+		writer.r = reader
+		writer.newObj(-1, false)
+		writer.writeValue(value)
+	}
+}
+
+// Write a value as an object of a writer that copies objects from reader,
+// with sorted keys and the object numbers of references kept, and get the
+// bytes written
+func writeTestValue(t testing.TB, reader *PdfReader, value *PdfValue) (string, *PdfWriter) {
+	t.Helper()
+
+	writer, err := NewPdfWriter("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.r = reader
+	writer.sort_keys = true
+	writer.renumbering = RenumberPreserve
+	writer.newObj(-1, false)
+	writer.writeValue(value)
+	return writer.current_obj.buffer.String(), writer
+}
+
+// Soft masks that cannot be resolved are removed from images and set to
+// /None in graphics states, with a warning
+func TestSoftMasks(t *testing.T) {
+	reader := readTestPDF(t, buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [] /Count 0 >>",
+		// A luminosity mask and the group it is drawn from
+		"<< /Type /Mask /S /Luminosity /G 4 0 R >>",
+		testStream("/Type /XObject /Subtype /Form /BBox [0 0 1 1] /Group << /S /Transparency /CS /DeviceGray >>", "0.5 g 0 0 1 1 re f"),
+		// A mask whose group does not exist
+		"<< /Type /Mask /S /Alpha /G 40 0 R >>",
+		// An image mask
+		testStream("/Type /XObject /Subtype /Image /Width 1 /Height 1 /ColorSpace /DeviceGray /BitsPerComponent 8", "\x80"),
+	))
+
+	for _, test := range []struct {
+		name    string
+		value   string
+		want    string
+		warning bool
+	}{
+		{"graphics state with mask", "<< /Type /ExtGState /SMask 3 0 R >>", "<</SMask 3 0 R /Type /ExtGState >>", false},
+		{"graphics state with missing mask", "<< /Type /ExtGState /SMask 30 0 R >>", "<</SMask /None /Type /ExtGState >>", true},
+		{"graphics state with missing group", "<< /Type /ExtGState /SMask 5 0 R >>", "<</SMask /None /Type /ExtGState >>", true},
+		{"graphics state with /None", "<< /Type /ExtGState /SMask /None >>", "<</SMask /None /Type /ExtGState >>", false},
+		{"graphics state with direct mask", "<< /Type /ExtGState /SMask << /S /Luminosity /G 4 0 R >> >>",
+			"<</SMask <</G 4 0 R /S /Luminosity >>/Type /ExtGState >>", false},
+		{"image with mask", "<< /Subtype /Image /SMask 6 0 R >>", "<</SMask 6 0 R /Subtype /Image >>", false},
+		{"image with missing mask", "<< /Subtype /Image /SMask 30 0 R >>", "<</Subtype /Image >>", true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			value, err := fuzzReadValue([]byte(test.value))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, writer := writeTestValue(t, reader, value)
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+			if warnings := writer.GetWarnings(); (len(warnings) > 0) != test.warning {
+				t.Errorf("got warnings %q", warnings)
+			}
+		})
+	}
+}
+
+// The soft masks of an imported page are copied with everything they use
+func TestSoftMaskImport(t *testing.T) {
+	data := buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] /Contents 4 0 R "+
+			"/Resources << /ExtGState << /GS1 5 0 R /GS2 8 0 R >> /XObject << /Im1 9 0 R >> >> >>",
+		testStream("", "/GS1 gs 0 0 50 50 re f /GS2 gs q 100 0 0 100 0 0 cm /Im1 Do Q"),
+		"<< /Type /ExtGState /SMask 6 0 R >>",
+		"<< /Type /Mask /S /Luminosity /G 7 0 R /BC [0] >>",
+		testStream("/Type /XObject /Subtype /Form /BBox [0 0 100 100] /Group << /S /Transparency /CS /DeviceGray >>", "1 g 0 0 100 100 re f"),
+		"<< /Type /ExtGState /SMask 60 0 R >>",
+		testStream("/Type /XObject /Subtype /Image /Width 1 /Height 1 /ColorSpace /DeviceRGB /BitsPerComponent 8 /SMask 10 0 R", "\xff\x00\x00"),
+		testStream("/Type /XObject /Subtype /Image /Width 1 /Height 1 /ColorSpace /DeviceGray /BitsPerComponent 8", "\x80"),
+	)
+
+	objects := checkReferences(t, importTestDocument(t, data, nil))
+	// The form xobject, the two graphics states, the mask, its group and the
+	// two images, and the catalog, page tree, page and page content of the
+	// document
+	if objects != 11 {
+		t.Errorf("document has %d objects, want 11", objects)
+	}
+
+	importer := newTestImporter(t, data)
+	if _, err := importer.ImportPage(1, MediaBox); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := importer.PutFormXobjects(); err != nil {
+		t.Fatal(err)
+	}
+	warnings := importer.GetWarnings()
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "Soft mask 60 0 R") {
+		t.Errorf("got warnings %q", warnings)
+	}
+}
+
+// Tiling and shading patterns are copied with the resources, shadings and
+// functions they use, and their matrices are kept: they map to the space of
+// the form, which follows the template wherever it is placed
+func TestPatternImport(t *testing.T) {
+	data := buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 200] /Contents 4 0 R "+
+			"/Resources << /Pattern << /P1 5 0 R /P2 7 0 R >> /Shading << /Sh1 8 0 R >> >> >>",
+		testStream("", "/Pattern cs /P1 scn 0 0 100 100 re f /P2 scn 100 100 100 100 re f /Sh1 sh"),
+		// A tiling pattern that draws an image of its own resources
+		testStream("/Type /Pattern /PatternType 1 /PaintType 1 /TilingType 1 /BBox [0 0 10 10] /XStep 10 /YStep 10 "+
+			"/Matrix [0.5 0 0 0.5 3 4] /Resources << /XObject << /Im1 6 0 R >> >>", "q 10 0 0 10 0 0 cm /Im1 Do Q"),
+		testStream("/Type /XObject /Subtype /Image /Width 1 /Height 1 /ColorSpace /DeviceRGB /BitsPerComponent 8", "\x00\x80\xff"),
+		// A shading pattern and a shading that share a shading and its function
+		"<< /Type /Pattern /PatternType 2 /Shading 8 0 R /Matrix [2 0 0 2 0 0] >>",
+		"<< /ShadingType 2 /ColorSpace /DeviceRGB /Coords [0 0 100 0] /Function 9 0 R /Extend [true true] >>",
+		"<< /FunctionType 2 /Domain [0 1] /C0 [1 0 0] /C1 [0 0 1] /N 1 >>",
+	)
+
+	output := importTestDocument(t, data, nil)
+	checkReferences(t, output)
+	reader, form := outputForm(t, output, 1)
+
+	tiling := resolvePath(t, reader, form, "/Resources", "/Pattern", "/P1")
+	if tiling.Type != PDF_TYPE_STREAM || tiling.Value.Dictionary["/PatternType"].Int != 1 {
+		t.Fatalf("/P1 is not a tiling pattern: %v", tiling)
+	}
+	if got := string(decodedStream(t, reader, tiling)); got != "q 10 0 0 10 0 0 cm /Im1 Do Q" {
+		t.Errorf("content of /P1 is %q", got)
+	}
+	image := resolvePath(t, reader, tiling, "/Resources", "/XObject", "/Im1")
+	if got := string(decodedStream(t, reader, image)); got != "\x00\x80\xff" {
+		t.Errorf("image of /P1 is %q", got)
+	}
+	if got := testNumbers(t, reader, resolvePath(t, reader, tiling, "/Matrix")); got != "[0.5 0 0 0.5 3 4]" {
+		t.Errorf("/Matrix of /P1 is %s", got)
+	}
+
+	shading := resolvePath(t, reader, form, "/Resources", "/Pattern", "/P2", "/Shading")
+	if got := testNumbers(t, reader, resolvePath(t, reader, form, "/Resources", "/Pattern", "/P2", "/Matrix")); got != "[2 0 0 2 0 0]" {
+		t.Errorf("/Matrix of /P2 is %s", got)
+	}
+	if got := testNumbers(t, reader, resolvePath(t, reader, shading, "/Function", "/C1")); got != "[0 0 1]" {
+		t.Errorf("/C1 of the function of /P2 is %s", got)
+	}
+
+	// The shading of the pattern and of /Sh1 is copied once
+	ref := form.Value.Dictionary["/Resources"].Dictionary["/Shading"].Dictionary["/Sh1"]
+	patternRef := resolvePath(t, reader, form, "/Resources", "/Pattern", "/P2").Dictionary["/Shading"]
+	if ref.Type != PDF_TYPE_OBJREF || patternRef.Type != PDF_TYPE_OBJREF || ref.Id != patternRef.Id {
+		t.Errorf("/Sh1 is %v, the shading of /P2 %v", ref, patternRef)
+	}
+}
+
+// Format an array of numbers as it is written, e.g. [1 0 0 1 0 0]
+func testNumbers(t testing.TB, reader *PdfReader, array *PdfValue) string {
+	t.Helper()
+
+	if array.Type != PDF_TYPE_ARRAY {
+		t.Fatalf("%v is not an array", array)
+	}
+	numbers := make([]string, len(array.Array))
+	for i, v := range array.Array {
+		v, err := reader.Resolve(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		numbers[i] = strconv.FormatFloat(v.Real, 'f', -1, 64)
+	}
+	return "[" + strings.Join(numbers, " ") + "]"
+}
+
+// A page drawing a form that draws a form, which draws the first one again
+// and an image, and a form without resources of its own
+func nestedFormDocument() []byte {
+	return buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 200] /Contents 4 0 R "+
+			"/Resources << /XObject << /Fm1 5 0 R /Fm3 8 0 R >> /Font << /F1 9 0 R >> >> >>",
+		testStream("", "/Fm1 Do /Fm3 Do"),
+		testStream("/Type /XObject /Subtype /Form /BBox [0 0 100 100] /Resources << /XObject << /Fm2 6 0 R >> >>", "/Fm2 Do"),
+		testStream("/Type /XObject /Subtype /Form /BBox [0 0 100 100] /Resources << /XObject << /Fm1 5 0 R /Im1 7 0 R >> >>", "/Im1 Do"),
+		testStream("/Type /XObject /Subtype /Image /Width 1 /Height 1 /ColorSpace /DeviceGray /BitsPerComponent 8", "\x40"),
+		testStream("/Type /XObject /Subtype /Form /BBox [0 0 100 100]", "BT /F1 12 Tf (inherited) Tj ET"),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	)
+}
+
+// Nested forms are copied completely, once each, even when they draw each
+// other
+func TestNestedForms(t *testing.T) {
+	output := importTestDocument(t, nestedFormDocument(), nil)
+	checkReferences(t, output)
+	reader, form := outputForm(t, output, 1)
+
+	fm1 := form.Value.Dictionary["/Resources"].Dictionary["/XObject"].Dictionary["/Fm1"]
+	again := resolvePath(t, reader, form, "/Resources", "/XObject", "/Fm1", "/Resources", "/XObject", "/Fm2", "/Resources", "/XObject").Dictionary["/Fm1"]
+	if fm1.Type != PDF_TYPE_OBJREF || again.Type != PDF_TYPE_OBJREF || fm1.Id != again.Id {
+		t.Errorf("/Fm1 is %v, and %v as drawn by /Fm2", fm1, again)
+	}
+
+	image := resolvePath(t, reader, form, "/Resources", "/XObject", "/Fm1", "/Resources", "/XObject", "/Fm2", "/Resources", "/XObject", "/Im1")
+	if got := string(decodedStream(t, reader, image)); got != "\x40" {
+		t.Errorf("image drawn by /Fm2 is %q", got)
+	}
+
+	// The form without resources gets those of the imported page, as it
+	// would use the resources of the page of the document otherwise
+	font := resolvePath(t, reader, form, "/Resources", "/XObject", "/Fm3", "/Resources", "/Font", "/F1", "/BaseFont")
+	if font.Token != "/Helvetica" {
+		t.Errorf("/F1 of /Fm3 is %v", font)
+	}
+}
+
+// Nested forms are written in the same order with the same numbers every time
+func TestNestedFormsReproducible(t *testing.T) {
+	reproducible := func(importer *Importer) {
+		importer.SetReproducible(true)
+	}
+
+	want := importTestDocument(t, nestedFormDocument(), reproducible)
+	for i := 0; i < 10; i++ {
+		got := importTestDocument(t, nestedFormDocument(), reproducible)
+		if !bytes.Equal(got, want) {
+			t.Fatalf("import %d wrote\n%s\nthe first one\n%s", i+2, got, want)
+		}
+	}
+}
+
+// Documents and pipelines in reproducible mode write the same bytes for the
+// same sources every time, also when pages are imported in parallel
+func TestReproducibleOutput(t *testing.T) {
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "a.pdf"), filepath.Join(dir, "nested.pdf"), filepath.Join("testdata", "type3.pdf")}
+	writeTestFile(t, paths[0], testDocument(4))
+	writeTestFile(t, paths[1], nestedFormDocument())
+
+	document := func() []byte {
+		t.Helper()
+		doc := NewDocument()
+		importer := doc.Importer()
+		importer.SetReproducible(true)
+		for _, path := range paths {
+			if err := importer.SetSourceFile(path); err != nil {
+				t.Fatal(err)
+			}
+			pages, err := importer.GetNumPages()
+			if err != nil {
+				t.Fatal(err)
+			}
+			pagenos := make([]int, pages)
+			for i := range pagenos {
+				pagenos[i] = i + 1
+			}
+			tplids, err := importer.ImportPages(pagenos, MediaBox, 4)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, tplid := range tplids {
+				if err := doc.AddTemplatePage(tplid); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		var buf bytes.Buffer
+		if _, err := doc.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	pipeline := func() []byte {
+		t.Helper()
+		p := NewPipeline()
+		p.Importer().SetReproducible(true)
+		var buf bytes.Buffer
+		if _, err := p.Run(&buf, SourceFiles(paths...)); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	for name, write := range map[string]func() []byte{"document": document, "pipeline": pipeline} {
+		want := write()
+		checkReferences(t, want)
+		for i := 0; i < 5; i++ {
+			if got := write(); !bytes.Equal(got, want) {
+				t.Fatalf("%s %d wrote\n%s\nthe first one\n%s", name, i+2, got, want)
+			}
+		}
+	}
+}
+
+// Binary strings and stream data are copied byte for byte, also streams that
+// are left in the source and copied when they are written
+func TestBinaryRoundTrip(t *testing.T) {
+	// Every byte value, the keywords that end streams and objects, and ends
+	// of lines that must not be normalized
+	var data bytes.Buffer
+	for i := 0; i < 256; i++ {
+		data.WriteByte(byte(i))
+	}
+	data.WriteString("\nendstream\nendobj\r\n%%EOF\r")
+	binary := []byte("\x00\x01\xff\xfe\x80 \\(\\)\\\\ \\r\\n \\377 end")
+	hex := "00FF7F80"
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 100] /Contents 4 0 R /Resources << /XObject << /Im1 5 0 R >> >> >>",
+		testStream("", "q 10 0 0 10 0 0 cm /Im1 Do Q"),
+		testStream("/Type /XObject /Subtype /Image /Width 1 /Height 1 /ColorSpace /DeviceGray /BitsPerComponent 8 "+
+			"/Binary ("+string(binary)+") /Hex <"+hex+">", data.String()),
+	}
+	source := buildPDF(objects...)
+
+	for _, test := range []struct {
+		name            string
+		largeStreamSize int
+	}{
+		{"in memory", 0},
+		{"left in the source", 16},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			out := importTestDocument(t, source, func(importer *Importer) {
+				importer.SetLargeStreamSize(test.largeStreamSize)
+			})
+			reader, form := outputForm(t, out, 1)
+			image := resolvePath(t, reader, form, "/Resources", "/XObject", "/Im1")
+
+			if image.Stream == nil {
+				t.Fatal("/Im1 is not a stream")
+			}
+			if !bytes.Equal(image.Stream.Bytes, data.Bytes()) {
+				t.Errorf("stream data is %q, want %q", image.Stream.Bytes, data.Bytes())
+			}
+			if got, want := stringBytes(image.Value.Dictionary["/Binary"]), decodeLiteralString(string(binary)); !bytes.Equal(got, want) {
+				t.Errorf("/Binary is %q, want %q", got, want)
+			}
+			if got := stringBytes(image.Value.Dictionary["/Hex"]); !bytes.Equal(got, []byte("\x00\xff\x7f\x80")) {
+				t.Errorf("/Hex is %q, want %q", got, "\x00\xff\x7f\x80")
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package gofpdi
+
+import (
+	"github.com/pkg/errors"
+)
+
+// How a document uses XFA (the XML Forms Architecture), see GetXFAForm
+type XFAForm int
+
+const (
+	// The document has no XFA form
+	XFANone XFAForm = iota
+	// The pages hold the form as it is drawn; the XFA form next to them is
+	// not imported
+	XFAStatic
+	// The form is laid out from the XFA by viewers that support it
+	// (/NeedsRendering); the pages are placeholders, often a message that
+	// the viewer cannot display the document
+	XFADynamic
+)
+
+func (form XFAForm) String() string {
+	switch form {
+	case XFAStatic:
+		return "static XFA"
+	case XFADynamic:
+		return "dynamic XFA"
+	}
+	return "no XFA"
+}
+
+// Find out whether the document has an XFA form (an /XFA entry in its
+// /AcroForm) and whether its pages need it to be rendered
+func (pdfReader *PdfReader) GetXFAForm() (XFAForm, error) {
+	catalog := pdfReader.catalog.Value
+	acroForm, err := pdfReader.resolveDirect(catalog.Dictionary["/AcroForm"])
+	if err != nil {
+		return XFANone, errors.Wrap(err, "Failed to resolve /AcroForm")
+	}
+	if acroForm == nil || acroForm.Type != PDF_TYPE_DICTIONARY {
+		return XFANone, nil
+	}
+
+	xfa, err := pdfReader.resolveDirect(acroForm.Dictionary["/XFA"])
+	if err != nil {
+		return XFANone, errors.Wrap(err, "Failed to resolve /XFA")
+	}
+	if xfa == nil || xfa.Type == PDF_TYPE_NULL {
+		return XFANone, nil
+	}
+
+	needsRendering, err := pdfReader.resolveDirect(catalog.Dictionary["/NeedsRendering"])
+	if err != nil {
+		return XFANone, errors.Wrap(err, "Failed to resolve /NeedsRendering")
+	}
+	if needsRendering != nil && needsRendering.Type == PDF_TYPE_BOOLEAN && needsRendering.Bool {
+		return XFADynamic, nil
+	}
+	return XFAStatic, nil
+}
+
+// Add a warning if the pages of the source may not show what viewers do,
+// because it has an XFA form
+func (pdfReader *PdfReader) warnXFA() {
+	form, err := pdfReader.GetXFAForm()
+	switch {
+	case err != nil:
+		pdfReader.warnings.add("XFA form could not be read: " + err.Error())
+	case form == XFADynamic:
+		pdfReader.warnings.add("Document has a dynamic XFA form: its pages are placeholders, not the form viewers display")
+	case form == XFAStatic:
+		pdfReader.warnings.add("Document has a static XFA form: the pages are imported without it")
+	}
+}
+
+// Find out whether the current source has an XFA form, see
+// PdfReader.GetXFAForm, e.g. to reject documents with a dynamic form, whose
+// imported pages would be placeholders.  Sources with an XFA form also get a
+// warning, see GetWarnings.
+func (importer *Importer) GetXFAForm() (XFAForm, error) {
+	return importer.GetReader().GetXFAForm()
+}
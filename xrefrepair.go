@@ -0,0 +1,314 @@
+package gofpdi
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// The distance around a startxref or /Prev offset in which the cross
+// reference section is searched when the offset is wrong
+const xrefSearchWindow = 1024
+
+// The size of the chunks in which the file is scanned for objects when the
+// cross reference table is reconstructed
+const reconstructChunkSize = 1 << 20
+
+var (
+	objectHeaderRegexp  = regexp.MustCompile(`(\d{1,10})[ \t\r\n\f\x00]+(\d{1,5})[ \t\r\n\f\x00]+obj\b`)
+	xrefKeywordRegexp   = regexp.MustCompile(`(?:^|[^a-z])xref[ \t\r\n\f\x00]`)
+	trailerKeywordRegex = regexp.MustCompile(`trailer[ \t\r\n\f\x00]*<<`)
+)
+
+// Get the offset of the cross reference section at or near offset.  Editors
+// that append bytes to a file or change line endings leave startxref offsets
+// that are off by a few bytes.  Returns offset if nothing is found near it.
+func (pdfReader *PdfReader) locateXref(offset int) int {
+	start := int64(offset) - xrefSearchWindow
+	if start < 0 {
+		start = 0
+	}
+	end := int64(offset) + xrefSearchWindow
+	if end > pdfReader.nBytes {
+		end = pdfReader.nBytes
+	}
+	if start >= end {
+		return offset
+	}
+
+	window := make([]byte, end-start)
+	_, err := pdfReader.f.Seek(start, 0)
+	if err != nil {
+		return offset
+	}
+	_, err = io.ReadFull(pdfReader.f, window)
+	if err != nil {
+		return offset
+	}
+
+	at := int(int64(offset) - start)
+	if at >= 0 && at < len(window) {
+		rest := window[at:]
+		if bytes.HasPrefix(rest, []byte("xref")) {
+			return offset
+		}
+		if loc := objectHeaderRegexp.FindIndex(rest); loc != nil && loc[0] == 0 {
+			return offset
+		}
+	}
+
+	// Find the closest xref keyword or xref stream
+	best := -1
+	closer := func(pos int) {
+		if best < 0 || abs(pos-at) < abs(best-at) {
+			best = pos
+		}
+	}
+	for _, loc := range xrefKeywordRegexp.FindAllIndex(window, -1) {
+		pos := loc[0]
+		if window[pos] != 'x' {
+			pos++
+		}
+		// Not the xref of startxref
+		if pos >= 5 && string(window[pos-5:pos]) == "start" {
+			continue
+		}
+		closer(pos)
+	}
+	for _, loc := range objectHeaderRegexp.FindAllIndex(window, -1) {
+		// An xref stream has /Type /XRef before its data
+		dict := window[loc[1]:]
+		if i := bytes.Index(dict, []byte("stream")); i >= 0 {
+			dict = dict[:i]
+		}
+		if bytes.Contains(dict, []byte("/XRef")) {
+			closer(loc[0])
+		}
+	}
+
+	if best < 0 {
+		return offset
+	}
+
+	return int(start) + best
+}
+
+// Rebuild the cross reference table from the object headers in the file, for
+// files whose cross reference sections cannot be read.  Objects that appear
+// more than once are taken from their last definition, which is the newest
+// revision; objects in object streams are only used if they are not defined
+// outside of one.  The trailer is the last trailer dictionary with /Root, or the
+// last xref stream with /Root, or else made up from the last catalog.
+func (pdfReader *PdfReader) reconstructXref() error {
+	pdfReader.xref = make(map[int]map[int]int, 0)
+	pdfReader.xrefStream = make(map[int][2]int, 0)
+	pdfReader.objects = newObjectCache(pdfReader.objects.size)
+	pdfReader.objStreams = newObjectCache(pdfReader.objStreams.size)
+	pdfReader.trailer = nil
+	pdfReader.stack = nil
+
+	objects, trailers, err := pdfReader.scanObjectHeaders()
+	if err != nil {
+		return err
+	}
+
+	if len(objects) == 0 {
+		return errors.New("No objects found")
+	}
+
+	offsets := make([]int64, 0, len(objects))
+	for offset := range objects {
+		offsets = append(offsets, offset)
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+
+	for _, offset := range offsets {
+		obj := objects[offset]
+		pdfReader.xref[obj[0]] = map[int]int{obj[1]: int(offset)}
+	}
+
+	// Use the last trailer dictionary with /Root
+	trailerOffsets := make([]int64, 0, len(trailers))
+	for offset := range trailers {
+		trailerOffsets = append(trailerOffsets, offset)
+	}
+	sort.Slice(trailerOffsets, func(i, j int) bool { return trailerOffsets[i] > trailerOffsets[j] })
+	for _, offset := range trailerOffsets {
+		trailer, err := pdfReader.readValueAt(offset)
+		if err == nil && trailer.Type == PDF_TYPE_DICTIONARY && trailer.Dictionary["/Root"] != nil {
+			pdfReader.trailer = trailer
+			break
+		}
+	}
+
+	// Register the objects of object streams, and look for xref streams and
+	// catalogs in case there is no trailer dictionary
+	var catalog *PdfValue
+	for _, offset := range offsets {
+		id := objects[offset]
+		if pdfReader.xref[id[0]][id[1]] != int(offset) {
+			// Replaced by a later revision
+			continue
+		}
+
+		obj, err := pdfReader.readObject(&PdfValue{Type: PDF_TYPE_OBJREF, Id: id[0], Gen: id[1]})
+		if err != nil || obj.Value == nil || obj.Value.Type != PDF_TYPE_DICTIONARY {
+			continue
+		}
+
+		dict := obj.Value.Dictionary
+		if t, ok := dict["/Type"]; ok {
+			switch t.Token {
+			case "/ObjStm":
+				pdfReader.registerObjectStream(id[0], obj)
+			case "/XRef":
+				if pdfReader.trailer == nil && dict["/Root"] != nil {
+					pdfReader.trailer = obj.Value
+				}
+			case "/Catalog":
+				catalog = &PdfValue{Type: PDF_TYPE_OBJREF, Id: id[0], Gen: id[1]}
+			}
+		}
+	}
+
+	if pdfReader.trailer == nil {
+		if catalog == nil {
+			return errors.New("No catalog found")
+		}
+		pdfReader.trailer = &PdfValue{Type: PDF_TYPE_DICTIONARY, Dictionary: map[string]*PdfValue{"/Root": catalog}}
+	}
+
+	return nil
+}
+
+// Find the object headers and trailer keywords in the file.  Returns the
+// object number and generation of the headers, and the offsets following the
+// trailer keywords.
+func (pdfReader *PdfReader) scanObjectHeaders() (objects map[int64][2]int, trailers map[int64]bool, err error) {
+	objects = make(map[int64][2]int, 0)
+	trailers = make(map[int64]bool, 0)
+
+	// Scan the file in chunks that overlap by the longest object header
+	const overlap = 64
+	for pos := int64(0); pos < pdfReader.nBytes; pos += reconstructChunkSize - overlap {
+		n := int64(reconstructChunkSize)
+		if pos+n > pdfReader.nBytes {
+			n = pdfReader.nBytes - pos
+		}
+		if err := pdfReader.checkDeadline(); err != nil {
+			return nil, nil, err
+		}
+
+		_, err := pdfReader.f.Seek(pos, 0)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "Failed to set position of file")
+		}
+		chunk := make([]byte, n)
+		_, err = io.ReadFull(pdfReader.f, chunk)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "Failed to read file")
+		}
+
+		for _, m := range objectHeaderRegexp.FindAllSubmatchIndex(chunk, -1) {
+			// The object number must not be the end of another token.  Headers
+			// at the start of a chunk were found in the overlap of the previous one.
+			if m[0] > 0 && !isWhitespace(chunk[m[0]-1]) && !isDelimiter(chunk[m[0]-1]) {
+				continue
+			}
+			if m[0] == 0 && pos > 0 {
+				continue
+			}
+			id, _ := strconv.Atoi(string(chunk[m[2]:m[3]]))
+			gen, _ := strconv.Atoi(string(chunk[m[4]:m[5]]))
+			objects[pos+int64(m[0])] = [2]int{id, gen}
+		}
+		for _, loc := range trailerKeywordRegex.FindAllIndex(chunk, -1) {
+			trailers[pos+int64(loc[0])+int64(len("trailer"))] = true
+		}
+
+		if pos+n >= pdfReader.nBytes {
+			break
+		}
+	}
+
+	return objects, trailers, nil
+}
+
+// Get the offset of the last definition of an object in the file, for objects
+// whose xref entry does not point at them.  The file is scanned once, when this
+// is first needed.
+func (pdfReader *PdfReader) lastDefinition(id, gen int) (int, bool) {
+	if pdfReader.definitions == nil {
+		pdfReader.definitions = make(map[[2]int]int, 0)
+
+		objects, _, err := pdfReader.scanObjectHeaders()
+		if err != nil {
+			return 0, false
+		}
+		for offset, obj := range objects {
+			if last, ok := pdfReader.definitions[obj]; !ok || int(offset) > last {
+				pdfReader.definitions[obj] = int(offset)
+			}
+		}
+	}
+
+	offset, ok := pdfReader.definitions[[2]int{id, gen}]
+	return offset, ok
+}
+
+// Read a value at an offset of the file
+func (pdfReader *PdfReader) readValueAt(offset int64) (*PdfValue, error) {
+	_, err := pdfReader.f.Seek(offset, 0)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to set position of file")
+	}
+
+	pdfReader.stack = nil
+	r := bufio.NewReader(pdfReader.f)
+	t, err := pdfReader.readToken(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read token")
+	}
+
+	return pdfReader.readValue(r, t)
+}
+
+// Add the objects stored in an object stream to xrefStream, unless they are
+// stored outside of object streams
+func (pdfReader *PdfReader) registerObjectStream(id int, obj *PdfValue) {
+	n := obj.Value.Dictionary["/N"]
+	if n == nil || n.Int <= 0 {
+		return
+	}
+
+	data, err := pdfReader.objectStreamData(id, obj)
+	if err != nil {
+		return
+	}
+
+	r := bufio.NewReader(bytes.NewReader(data))
+	for i := 0; i < n.Int; i++ {
+		t, err := pdfReader.readToken(r)
+		if err != nil {
+			return
+		}
+		subObjId, err := strconv.Atoi(t)
+		if err != nil {
+			return
+		}
+
+		// Skip the offset
+		if _, err := pdfReader.readToken(r); err != nil {
+			return
+		}
+
+		if _, ok := pdfReader.xref[subObjId]; !ok {
+			pdfReader.xrefStream[subObjId] = [2]int{id, i}
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package gofpdi
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Broken cross-reference sections are repaired, and each object is read from
+// the definition the repair chose
+func TestXrefRepair(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		rebuilt bool
+		// Media box size and content of each page
+		pages [][2]string
+	}{
+		{"startxref-off", false, [][2]string{{"612 x 792", "0 0 306 396 re f"}, {"300 x 200", "0 0 150 100 re f"}}},
+		{"truncated", true, [][2]string{{"612 x 792", "0 0 306 396 re f"}, {"300 x 200", "0 0 150 100 re f"}}},
+		{"garbage-offsets", false, [][2]string{{"612 x 792", "0 0 306 396 re f"}, {"300 x 200", "0 0 150 100 re f"}}},
+		// The entry of object 5 points at its first definition, which is
+		// used; the one of object 6 at neither, so the last one is used
+		{"duplicate-objects", false, [][2]string{{"612 x 792", "0 0 306 396 re f"}, {"300 x 200", "0 0 200 150 re f"}}},
+		// The rebuilt table has the last definition of each object
+		{"duplicate-objects-rebuilt", true, [][2]string{{"612 x 792", "0 0 306 396 re f"}, {"400 x 300", "0 0 200 150 re f"}}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", "xref", test.name+".pdf"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			reader := readTestPDF(t, data)
+			if reader.xrefRebuilt != test.rebuilt {
+				t.Errorf("cross-reference table rebuilt: %v, want %v", reader.xrefRebuilt, test.rebuilt)
+			}
+			if len(reader.pages) != len(test.pages) {
+				t.Fatalf("got %d pages, want %d", len(reader.pages), len(test.pages))
+			}
+
+			for i, want := range test.pages {
+				page, err := reader.getPage(i + 1)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := boxSize(t, resolvePath(t, reader, page, "/MediaBox")); got != want[0] {
+					t.Errorf("page %d: media box is %s, want %s", i+1, got, want[0])
+				}
+				contents := resolvePath(t, reader, page, "/Contents")
+				if got := string(contents.Stream.Bytes); got != want[1] {
+					t.Errorf("page %d: content is %q, want %q", i+1, got, want[1])
+				}
+			}
+
+			out := importTestDocument(t, data, nil)
+			checkReferences(t, out)
+			for i, want := range test.pages {
+				output, form := outputForm(t, out, i+1)
+				if got := string(decodedStream(t, output, form)); got != want[1] {
+					t.Errorf("page %d: form content is %q, want %q", i+1, got, want[1])
+				}
+				if got := boxSize(t, resolvePath(t, output, form, "/BBox")); got != want[0] {
+					t.Errorf("page %d: form bounding box is %s, want %s", i+1, got, want[0])
+				}
+			}
+		})
+	}
+}
+
+// Get the width and height of a box that starts at 0 0, e.g. "612 x 792"
+func boxSize(t *testing.T, box *PdfValue) string {
+	t.Helper()
+
+	if len(box.Array) != 4 {
+		t.Fatalf("box %v does not have 4 numbers", box)
+	}
+	size := func(v *PdfValue) string {
+		return strings.TrimSpace(valueText(&PdfValue{Value: v}))
+	}
+	return size(box.Array[2]) + " x " + size(box.Array[3])
+}